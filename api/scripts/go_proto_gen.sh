@@ -39,3 +39,7 @@ cp -f bazel-bin/api/envoy/v10/http/path_rewrite/config_go_proto_/github.com/Goog
 bazel build //api/envoy/v10/http/backend_auth:config_go_proto
 mkdir -p src/go/proto/api/envoy/v10/http/backend_auth
 cp -f bazel-bin/api/envoy/v10/http/backend_auth/config_go_proto_/github.com/GoogleCloudPlatform/esp-v2/src/go/proto/api/envoy/v10/http/backend_auth/* src/go/proto/api/envoy/v10/http/backend_auth
+# HTTP filter header_allowlist
+bazel build //api/envoy/v10/http/header_allowlist:config_go_proto
+mkdir -p src/go/proto/api/envoy/v10/http/header_allowlist
+cp -f bazel-bin/api/envoy/v10/http/header_allowlist/config_go_proto_/github.com/GoogleCloudPlatform/esp-v2/src/go/proto/api/envoy/v10/http/header_allowlist/* src/go/proto/api/envoy/v10/http/header_allowlist
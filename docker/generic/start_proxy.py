@@ -61,11 +61,37 @@ def gen_bootstrap_conf(args):
     cmd = [BOOTSTRAP_CMD, "--logtostderr"]
 
     cmd.extend(["--admin_port", str(args.status_port)])
+    if args.admin_address:
+        cmd.extend(["--admin_address", args.admin_address])
     if args.http_request_timeout_s:
         cmd.extend(
             ["--http_request_timeout_s",
              str(args.http_request_timeout_s)])
 
+    if args.overload_max_heap_size_bytes:
+        cmd.extend(["--overload_max_heap_size_bytes",
+                    args.overload_max_heap_size_bytes])
+    if args.overload_stop_accepting_requests_threshold:
+        cmd.extend(["--overload_stop_accepting_requests_threshold",
+                    args.overload_stop_accepting_requests_threshold])
+    if args.overload_stop_accepting_connections_threshold:
+        cmd.extend(["--overload_stop_accepting_connections_threshold",
+                    args.overload_stop_accepting_connections_threshold])
+
+    if args.envoy_runtime:
+        cmd.extend(["--envoy_runtime", ";".join(args.envoy_runtime)])
+
+    if args.statsd_address:
+        cmd.extend(["--statsd_address", args.statsd_address])
+    if args.statsd_prefix:
+        cmd.extend(["--statsd_prefix", args.statsd_prefix])
+    if args.enable_dog_statsd:
+        cmd.append("--enable_dog_statsd")
+
+    if args.node_id:
+        cmd.extend(["--node", args.node_id])
+    if args.node_cluster:
+        cmd.extend(["--node_cluster", args.node_cluster])
 
     bootstrap_file = DEFAULT_CONFIG_DIR + BOOTSTRAP_CONFIG
     cmd.append(bootstrap_file)
@@ -123,6 +149,52 @@ environment variable or by passing "-k" flag to this script.
            --service, --version, and --rollout_strategy.
         ''')
 
+    parser.add_argument(
+        '--config_dump_path',
+        default=None,
+        help='''
+        Specify a path for ESPv2 to write the generated Envoy config to, for
+        debugging. It is written as an Envoy bootstrap config in JSON, and
+        rewritten on each service config update.
+        ''')
+
+    parser.add_argument(
+        '--config_swap_grace_period',
+        default=None,
+        help='''
+        The period, e.g. "30s", after a new service config is rolled out
+        during which the previous config is kept. If Envoy rejects the new
+        config within the period, the previous config keeps being served and
+        the new config is not applied again. Disabled by default.
+        ''')
+
+    parser.add_argument(
+        '--auth_bypass_file',
+        default=None,
+        help='''
+        Path to a file listing the selectors of operations, one per line,
+        whose JWT and API key checks are removed at runtime to make them
+        public, e.g. during an incident. The file is checked every 10s, and
+        each change is logged as a warning. A file listing operations that
+        are not in the service config is rejected. Empty or remove the file
+        to revert the bypass. Not set by default, which disables the bypass.
+        ''')
+
+    parser.add_argument(
+        '--additional_services',
+        default=None,
+        help='''
+        Additional services served on their own listeners, as comma-separated
+        pairs of service_json_path=listener_port, e.g.
+        "/etc/espv2/second_service.json=8081". Each service config is only
+        read from its file, once at startup. It is neither fetched from
+        Service Management nor rolled out, and changes to the file take effect
+        after a restart, while its Envoy config is regenerated with each update
+        of the main service. The services share
+        all other flags, such as --backend, with the main service, and must use
+        the same service control environment.
+        ''')
+
     parser.add_argument(
         '-a',
         '--backend',
@@ -133,6 +205,8 @@ environment variable or by passing "-k" flag to this script.
 
         Default value is {backend}. Follow the same format when setting
         manually. Valid schemes are `http`, `https`, `grpc`, and `grpcs`.
+        The address may reference environment variables, e.g.
+        `http://${{BACKEND_HOST}}:8080`, which are resolved at startup.
         
         See the flag --enable_backend_address_override for details on how ESPv2
         decides between using this flag vs using the backend addresses specified
@@ -168,11 +242,31 @@ environment variable or by passing "-k" flag to this script.
         It supports HTTP/1.x, HTTP/2, and gRPC connections.
         Default is {port}'''.format(port=DEFAULT_LISTENER_PORT))
 
+    parser.add_argument('--listener_address', default=None, help='''
+        The IP address the listener binds to, e.g. "127.0.0.1" to only accept
+        connections on the loopback interface when ESPv2 runs as a sidecar.
+        Default is 0.0.0.0, all interfaces.''')
+
     parser.add_argument('-N', '--status_port', '--admin_port', default=0,
         type=int, help=''' Enable ESPv2 Envoy admin on this port. Please refer
         to https://www.envoyproxy.io/docs/envoy/latest/operations/admin.
         By default the admin port is disabled.''')
 
+    parser.add_argument('--admin_address', default=None, help='''
+        The IP address the Envoy admin interface binds to. The admin interface
+        is unauthenticated, set it to "127.0.0.1" to only accept connections
+        on the loopback interface so that local health checks and stats
+        scraping keep working while external access is refused.
+        Default is 0.0.0.0, all interfaces.''')
+
+    parser.add_argument('--node_id', default=None, help='''
+        The Envoy node id, reported in the stats and admin interface of Envoy
+        to identify this ESPv2 instance. Default is "ESPv2".''')
+
+    parser.add_argument('--node_cluster', default=None, help='''
+        The Envoy node cluster, to identify the fleet this ESPv2 instance
+        belongs to. Default is the node id with the suffix "_cluster".''')
+
     parser.add_argument('--ssl_server_cert_path', default=None, help='''
         Proxy's server cert path. When configured, ESPv2 only accepts HTTP/1.x and
         HTTP/2 secure connections on listener_port. Requires the certificate and
@@ -183,6 +277,40 @@ environment variable or by passing "-k" flag to this script.
         and GCE with GCLB configured terminates TLS before ESPv2. If that's the case,
         please don't set up flag. 
         ''')
+    parser.add_argument('--ssl_server_spiffe_trust_bundle_path', default=None, help='''
+        The file path of the SPIFFE trust bundle that ESPv2 uses to verify downstream
+        client SVIDs. When configured, client certificates are required. Cannot be
+        used together with --ssl_server_root_cert_path.
+        ''')
+    parser.add_argument('--ssl_server_allowed_spiffe_ids', default=None, help='''
+        A comma-separated list of SPIFFE IDs allowed to connect to ESPv2, e.g.
+        spiffe://example.org/ns/default/sa/client. Client SVIDs whose URI SAN
+        doesn't match one of them are rejected. Requires
+        --ssl_server_spiffe_trust_bundle_path.
+        ''')
+    parser.add_argument('--client_crl_file', default=None, help='''
+        The file path of a certificate revocation list in PEM. Downstream client
+        certificates revoked by it are rejected. The file is checked for changes
+        every minute and reloaded without a restart or draining the listeners.
+        Requires
+        --ssl_server_root_cert_path or --ssl_server_spiffe_trust_bundle_path.
+        ''')
+    parser.add_argument('--forward_client_cert_details', default=None,
+        choices=['sanitize', 'forward_only', 'append_forward', 'sanitize_set',
+                 'always_forward_only'],
+        help='''
+        How to handle the X-Forwarded-Client-Cert (XFCC) header sent to the
+        backend for mTLS client connections. The default is "sanitize", which
+        removes the header. Use "sanitize_set" or "append_forward" together with
+        --set_current_client_cert_details to pass the client identity to the
+        backend.
+        ''')
+    parser.add_argument('--set_current_client_cert_details', default=None, help='''
+        The fields of the client certificate added to the X-Forwarded-Client-Cert
+        header, as a comma-separated list of "subject", "uri", "dns", "cert" and
+        "chain", e.g. "subject,uri". Requires --forward_client_cert_details to be
+        "append_forward" or "sanitize_set".
+        ''')
 
     parser.add_argument('--ssl_server_cipher_suites', default=None, help='''
         Cipher suites to use for downstream connections as a comma-separated list.
@@ -300,6 +428,53 @@ environment variable or by passing "-k" flag to this script.
         implementation detail and is not guaranteed to be consistent.
         '''
     )
+    parser.add_argument(
+        '--response_headers_allowlist',
+        default=None,
+        help='''
+        Comma-separated response headers, e.g. "x-request-id,cache-control",
+        forwarded to clients. If set, all other response headers from the
+        backends are removed, except the essential ones like content-type,
+        content-length and the gRPC status headers grpc-status, grpc-message
+        and grpc-status-details-bin. Response trailers are not changed.
+        ''')
+
+    parser.add_argument(
+        '--enable_response_compression',
+        action='store_true',
+        help='''
+        Compress responses with gzip if the request has
+        "Accept-Encoding: gzip", including the JSON responses transcoded from
+        gRPC backends. Only text content types like application/json are
+        compressed, gRPC responses are not.
+        ''')
+
+    parser.add_argument(
+        '--enable_operation_stats',
+        action='store_true',
+        help='''
+        When enabled, ESPv2 will emit request counts and latencies per
+        operation as Envoy virtual cluster stats. They are available from the
+        Envoy admin stats endpoint with the prefix
+        `vhost.backend.vcluster.<operation>`, where `.` in the operation name
+        is replaced by `_`. Request latency percentiles per operation are
+        available from the `vhost.backend.vcluster.<operation>.upstream_rq_time`
+        histogram.
+        '''
+    )
+
+    parser.add_argument(
+        '--enable_consumer_stats',
+        action='store_true',
+        help='''
+        When enabled, ESPv2 will emit request counts and latencies per
+        consumer, as Envoy stats named by the consumer project number from the
+        Service Control check response, e.g.
+        `http.ingress_http.service_control.consumer.<project_number>.rq_total`
+        and `...request_time`. Requests without a consumer, e.g. without an
+        API key, are not counted.
+        '''
+    )
 
     parser.add_argument(
         '-R',
@@ -385,6 +560,20 @@ environment variable or by passing "-k" flag to this script.
         help='''
         Only works when --cors_preset is in use. Enable the CORS header
         Access-Control-Allow-Credentials. By default, this header is disabled.
+        Access-Control-Allow-Origin always echoes the request origin, never "*",
+        so credentialed requests are accepted by browsers.
+        ''')
+    parser.add_argument(
+        '--cors_disabled_operations',
+        default=None,
+        help='''
+        Comma-separated selectors of operations with CORS disabled, e.g.
+        "1.echo_api.CreateShelf,1.echo_api.DeleteShelf". When the endpoint in
+        the service config sets allow_cors, their CORS preflight requests are
+        not passed to the backend. When --cors_preset is in use, the CORS
+        policy doesn't apply to them. Either way, their preflight requests are
+        rejected without CORS headers, so browsers don't send the cross-origin
+        request.
         ''')
     parser.add_argument(
         '--cors_max_age',
@@ -407,6 +596,16 @@ environment variable or by passing "-k" flag to this script.
         help='''Allow headers contain underscores to pass through. By default
         ESPv2 rejects requests that have headers with underscores.''')
 
+    parser.add_argument('--max_request_headers_kb', default=None, type=int,
+        help='''The maximum size of all request headers in KB, up to 8192.
+        Requests with larger headers, e.g. due to a large JWT, are rejected
+        with 413 before authentication. Default is 60.''')
+
+    parser.add_argument('--max_request_duration', default=None,
+        help='''The maximum duration of a request regardless of upstream
+        activity, e.g. 5m. Requests lasting longer are aborted with 504, or
+        reset if the response has already started. No limit by default.''')
+
     parser.add_argument('--disable_normalize_path', action='store_true',
         help='''Disable normalization of the `path` HTTP header according to
         RFC 3986. It is recommended to keep this option enabled if your backend
@@ -483,6 +682,35 @@ environment variable or by passing "-k" flag to this script.
         https://github.com/envoyproxy/envoy/security/advisories/GHSA-4987-27fx-x6cf
         ''')
 
+    parser.add_argument('--decode_escaped_slashes_in_path',
+        action='store_true',
+        help='''
+        Decodes escaped percent-encoded slash characters (%%2F, %%2f, %%5C,
+        %%5c) in the request path, and forwards the request to the backend
+        with the decoded path instead of redirecting it.
+        
+        By default, escaped slashes are forwarded unchanged. Cannot be used
+        with --disallow_escaped_slashes_in_path.
+        ''')
+
+    parser.add_argument('--require_request_headers',
+        default=None,
+        help='''
+        Comma-separated headers that every request must carry, e.g.
+        "X-Correlation-Id". Requests missing any of them are rejected with
+        400 before they are routed. CORS preflight requests are exempted.
+        ''')
+
+    parser.add_argument('--allow_duplicate_http_patterns',
+        action='store_true',
+        help='''
+        When multiple http rules have the same http method and path, keep the
+        one of the operation listed first in the service config and ignore the
+        others with a warning, instead of failing to start. Overlapping but
+        different paths are always matched from the most specific to the least
+        specific, e.g. "/shelves/featured" before "/shelves/{shelf}".
+        ''')
+
     parser.add_argument(
         '--envoy_use_remote_address',
         action='store_true',
@@ -507,6 +735,139 @@ environment variable or by passing "-k" flag to this script.
         https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/listener/v3/listener.proto
         ''')
 
+    parser.add_argument(
+        '--enable_proxy_protocol', action='store_true',
+        help='''
+        Expect a PROXY protocol (v1 or v2) header on every downstream
+        connection, as prepended by some network load balancers. The client
+        address it carries is used in logs and reports instead of the load
+        balancer's address. Connections without the header are rejected.
+        ''')
+
+    parser.add_argument(
+        '--accept_http_10', action='store_true',
+        help='''
+        Accept HTTP/1.0 requests from legacy clients. Otherwise they are
+        rejected with 426 Upgrade Required. The requests are sent to the
+        backend using HTTP/1.1.
+        ''')
+    parser.add_argument(
+        '--default_host_for_http_10', default=None,
+        help='''
+        The host used for HTTP/1.0 requests without a Host header, e.g.
+        "api.example.com". Only used with `--accept_http_10`.
+        ''')
+
+    parser.add_argument(
+        '--listener_tcp_keepalive_time', default=None,
+        help='''
+        Enable TCP keepalive on downstream connections, sending the first
+        probe after the connection has been idle for this long, e.g. "60s".
+        Must be at least 1s. Disabled if not set.
+        ''')
+    parser.add_argument(
+        '--listener_tcp_keepalive_interval', default=None,
+        help='''
+        The interval between TCP keepalive probes on downstream connections,
+        e.g. "10s". If not set, the system default is used. Requires
+        `--listener_tcp_keepalive_time`.
+        ''')
+    parser.add_argument(
+        '--listener_tcp_keepalive_probes', default=None,
+        help='''
+        The number of unanswered TCP keepalive probes after which a downstream
+        connection is dropped. If not set, the system default is used.
+        Requires `--listener_tcp_keepalive_time`.
+        ''')
+    parser.add_argument(
+        '--listener_http2_keepalive_interval', default=None,
+        help='''
+        Send HTTP/2 PING frames to idle downstream connections at this
+        interval, e.g. "30s", so that long-lived gRPC streams are not dropped
+        by intermediaries. Must be at least 1s. Disabled if not set.
+        ''')
+    parser.add_argument(
+        '--listener_http2_keepalive_timeout', default=None,
+        help='''
+        How long to wait for a response to an HTTP/2 keepalive PING before the
+        downstream connection is closed, e.g. "10s". Default is 20s. Requires
+        `--listener_http2_keepalive_interval`.
+        ''')
+    parser.add_argument(
+        '--listener_http2_max_concurrent_streams', default=None,
+        help='''
+        The maximum number of concurrent streams per downstream HTTP/2
+        connection, so that a single gRPC client cannot starve the others. If
+        not set, the Envoy default of 2147483647 is used.
+        ''')
+    parser.add_argument(
+        '--enable_reuse_port', action='store_true',
+        help='''
+        Set SO_REUSEPORT on the listener so that each Envoy worker thread gets
+        its own listening socket and the kernel balances new connections
+        across them.
+        ''')
+    parser.add_argument(
+        '--backend_unavailable_message', default=None,
+        help='''
+        The message of the 503 responses when the backend is unavailable, i.e.
+        the connection to it fails, none of its hosts is healthy, or its
+        circuit breaker is open, e.g. "Bookstore is temporarily unavailable".
+        If not set, the Envoy default message is used.
+        ''')
+
+    parser.add_argument(
+        '--overload_max_heap_size_bytes', default=None,
+        help='''
+        Enable the Envoy overload manager to shed load under memory pressure.
+        The heap usage of Envoy is compared against this size, in bytes, to
+        decide when to stop accepting new requests and connections. Disabled
+        if not set.
+        ''')
+    parser.add_argument(
+        '--overload_stop_accepting_requests_threshold', default=None,
+        help='''
+        The fraction of `--overload_max_heap_size_bytes` above which new
+        requests are rejected with 503. The default is 0.95.
+        ''')
+    parser.add_argument(
+        '--overload_stop_accepting_connections_threshold', default=None,
+        help='''
+        The fraction of `--overload_max_heap_size_bytes` above which new
+        downstream connections are no longer accepted. The default is 0.98.
+        ''')
+
+    parser.add_argument(
+        '--envoy_runtime',
+        action='append',
+        help='''
+        Set a static Envoy runtime value in the format key=value, for
+        example to toggle an Envoy runtime feature flag. Values of `true` and
+        `false` are set as booleans and numeric values as numbers. They
+        override the default runtime values set by ESPv2. This argument can be
+        repeated multiple times to set multiple values. For example:
+        --envoy_runtime=key1=value1 --envoy_runtime=key2=value2.
+        ''')
+
+    parser.add_argument(
+        '--statsd_address', default=None,
+        help='''
+        If set, Envoy flushes its stats to the statsd server at this UDP
+        address, e.g. 127.0.0.1:8125. The host must be an IP address.
+        ''')
+    parser.add_argument(
+        '--statsd_prefix', default=None,
+        help='''
+        The prefix of the stats sent to the statsd server. If not set, Envoy
+        uses "envoy".
+        ''')
+    parser.add_argument(
+        '--enable_dog_statsd', action='store_true',
+        help='''
+        Send the stats to the statsd server in the DogStatsD format, with tags.
+        Only used with `--statsd_address`.
+        ''')
+
     parser.add_argument(
         '--log_request_headers',
         default=None,
@@ -525,6 +886,97 @@ environment variable or by passing "-k" flag to this script.
         response_headers: foo=foo_value;bar=bar_value if values are available;
         ''')
 
+    parser.add_argument(
+        '--service_control_extra_labels',
+        default=None,
+        help='''Report request headers as labels through service control,
+        as comma-separated pairs of header=label. Example, when
+        --service_control_extra_labels=X-Client-Version=/client_version,
+        the report will have the label /client_version with the header value.
+        The label is omitted if the header is not in the request. Labels
+        reported by ESPv2 itself, such as /response_code or any label in a
+        googleapis.com namespace, are rejected.
+        ''')
+
+    parser.add_argument(
+        '--service_control_jwt_claim_labels',
+        default=None,
+        help='''Report JWT claims as labels through service control,
+        as comma-separated pairs of claim=label. Example, when
+        --service_control_jwt_claim_labels=sub=/jwt_sub,email=/jwt_email,
+        the report will have the labels /jwt_sub and /jwt_email with the
+        claim values from the verified JWT. Only top-level string claims are
+        reported; the label is omitted if the claim is missing.
+        ''')
+
+    parser.add_argument(
+        '--service_control_credential_id_prefix',
+        default=None,
+        help='''Prefix prepended to the /credential_id label reported to
+        service control. Example, when
+        --service_control_credential_id_prefix=billing-, API key requests
+        report billing-apikey:KEY and JWT requests report
+        billing-jwtauth:issuer=...
+        ''')
+
+    parser.add_argument(
+        '--service_control_success_status_codes',
+        default=None,
+        help='''HTTP response codes reported to service control as
+        successful, separated by comma. Example, when
+        --service_control_success_status_codes=404, a 404 response is
+        reported without the /error_type label, with /response_code_class
+        2xx and with an INFO log entry. Only backend responses are
+        considered, and gRPC statuses are mapped to HTTP codes, e.g. NOT_FOUND
+        to 404. Errors replied by ESPv2 itself are still reported as errors.
+        ''')
+
+    parser.add_argument(
+        '--service_control_stats_report',
+        default=None,
+        help='''Envoy stats periodically reported to service control as log
+        entries, as comma-separated full names of counters and gauges. Example,
+        --service_control_stats_report=server.live,http.ingress_http.downstream_rq_total.
+        ''')
+
+    parser.add_argument(
+        '--service_control_stats_report_interval',
+        default=None,
+        help='''The interval between two reports of the Envoy stats selected
+        by --service_control_stats_report, e.g. "30s". Default is 1m.
+        ''')
+
+    parser.add_argument(
+        '--service_control_unmatched_operation',
+        default=None,
+        help='''The operation name reported to service control for requests
+        that don't match any operation, e.g. unregistered paths. Example,
+        --service_control_unmatched_operation="<unregistered>".
+        Default is "<Unknown Operation Name>".
+        ''')
+
+    parser.add_argument(
+        '--remove_api_key_from_request',
+        action='store_true',
+        help='''Remove the API key headers and query parameters from the
+        request once the API key is extracted, so that they are not forwarded
+        to the backend. By default, they are forwarded. API keys in cookies
+        are always forwarded.
+        ''')
+
+    parser.add_argument(
+        '--log_entry_fields',
+        default=None,
+        help='''Add optional request fields to the service control log
+        entry, separated by comma. Supported fields are
+        consumer_project_number, http_method, request_id,
+        request_latency_in_ms and user_agent. The consumer project number is
+        resolved by the service control Check, so it is only added to
+        requests that are checked. Example, when
+        --log_entry_fields=http_method,user_agent, endpoint log will have
+        http_method and user_agent in its payload.
+        ''')
+
     parser.add_argument(
         '--log_jwt_payloads',
         default=None,
@@ -550,12 +1002,51 @@ environment variable or by passing "-k" flag to this script.
         If disabled, JWKS fetching is done when authenticating the JWT, the fetching will add
         to the request processing latency. Default is enabled.'''
     )
+    parser.add_argument(
+        '--jwks_warm_on_startup',
+        default=None,
+        choices=['optional', 'required'],
+        help='''
+        Fetch the JWKS of all authentication providers at startup. When
+        "required", startup fails if any JWKS cannot be fetched. When
+        "optional", a warning is logged instead. Disabled by default.
+        ''')
     parser.add_argument(
         '--jwks_cache_duration_in_s',
         default=None,
         help='''
         Specify JWT public key cache duration in seconds. The default is 5 minutes.'''
     )
+    parser.add_argument(
+        '--jwks_cache_duration_by_provider',
+        default=None,
+        help='''
+        Override the JWT public key cache duration for specific authentication
+        providers, as comma-separated pairs of provider_id=seconds, e.g.
+        "google_id_token=3600,auth0=60". Providers that are not listed use
+        --jwks_cache_duration_in_s.
+        ''')
+    parser.add_argument(
+        '--jwt_skip_audience_check',
+        default=None,
+        help='''
+        Skip the audience check of the JWTs issued by specific authentication
+        providers, as comma-separated provider ids, e.g. "internal_provider".
+        The signature and issuer of their JWTs are still verified. Use it for
+        providers that issue JWTs without an "aud" claim.
+        ''')
+    parser.add_argument(
+        '--jwt_provider_additional_issuers',
+        default=None,
+        help='''
+        Let an authentication provider also accept JWTs from other issuers, as
+        comma-separated provider_id=issuer|jwks_uri entries, e.g.
+        "auth0=https://new-tenant.auth0.com/|https://new-tenant.auth0.com/.well-known/jwks.json".
+        The jwks_uri is optional and is found by OpenID Connect Discovery when
+        omitted. Operations requiring the provider accept JWTs from any of its
+        issuers. The n-th additional issuer of a provider gets the provider id
+        "<provider_id>_issuer_<n>".
+        ''')
     parser.add_argument(
         '--jwks_fetch_num_retries',
         default=None,
@@ -574,6 +1065,28 @@ environment variable or by passing "-k" flag to this script.
         help='''
         Specify JWKS fetch retry exponential back off maximum interval in milliseconds. default 32s if not set.'''
     )
+    parser.add_argument(
+        '--trust_preauthenticated_jwt_header',
+        default=None,
+        help='''
+        The request header carrying a JWT that was already validated by an upstream
+        hop, such as a load balancer or another proxy. When a request carries this
+        header, ESPv2 does NOT verify its JWT and skips JWT authentication; its
+        payload is forwarded to the backend and used for reporting. Requests without
+        this header still need a JWT verified by ESPv2. Only use it if all requests
+        reach ESPv2 through the trusted hop, otherwise callers can forge their identity.'''
+    )
+    parser.add_argument(
+        '--check_api_key_before_jwt',
+        action='store_true',
+        help='''
+        For operations requiring both an API key and a JWT, check the API key
+        before verifying the JWT. By default the JWT is verified first, so a
+        request missing both is rejected with 401 "Jwt is missing". With this
+        flag, it is rejected with 401 for the missing API key instead, and
+        requests with an invalid JWT are checked with service control before
+        they are rejected. Cannot be used with --jwt_claim_backend_routes.'''
+    )
 
     parser.add_argument(
         '--http_request_timeout_s',
@@ -591,6 +1104,15 @@ environment variable or by passing "-k" flag to this script.
         Set the timeout in millisecond for service control Check request.
         Must be > 0 and the default is 1000 if not set. Default
         ''')
+    parser.add_argument(
+        '--service_control_check_timeout',
+        default=None,
+        help='''
+        Set the timeout for service control Check request, e.g. "500ms". If
+        set, it overrides --service_control_check_timeout_ms. After the
+        timeout, the request fails, or is allowed with the network fail open
+        policy of the service config.
+        ''')
     parser.add_argument(
         '--service_control_quota_timeout_ms',
         default=None,
@@ -626,6 +1148,57 @@ environment variable or by passing "-k" flag to this script.
         Set the retry times for service control Report request.
         Must be >= 0 and the default is 5 if not set.
         ''')
+    parser.add_argument(
+        '--service_control_report_retry_backoff_ms',
+        default=None,
+        help='''
+        Set the initial backoff in millisecond between retries of service
+        control Report request, it doubles on each retry.
+        Must be >= 0 and the default is 100 if not set.
+        ''')
+    parser.add_argument(
+        '--service_control_max_pending_reports',
+        default=None,
+        help='''
+        Set the maximum number of service control Report requests in flight,
+        including the ones waiting to be retried. Further reports are dropped
+        until some requests finish. The bound applies to each Envoy worker
+        thread separately. Must be > 0 and the default is 1000 if not set.
+        ''')
+    parser.add_argument(
+        '--service_control_report_flush_jitter_ms',
+        default=None,
+        help='''
+        Set the maximum random delay in millisecond added to each periodic
+        flush of the aggregated service control Report requests, so that
+        instances started at the same time don't flush at the same time. The
+        default is 0, i.e. no jitter.
+        ''')
+    parser.add_argument(
+        '--service_control_operation_name_strip_prefix',
+        default=None,
+        help='''
+        Strip this prefix from the operation names in service control Report
+        calls, e.g. `1.echo_api_endpoints_cloudesf_testing_cloud_goog.`.
+        Routing, Check and Quota calls still use the full selectors.
+        ''')
+    parser.add_argument(
+        '--service_control_operation_name_map',
+        default=None,
+        help='''
+        Report operations under different names in service control Report
+        calls, as comma-separated pairs of selector=name, e.g.
+        `1.echo_api.Echo=Echo,1.echo_api.Root=Root`. It takes precedence over
+        --service_control_operation_name_strip_prefix.
+        ''')
+    parser.add_argument(
+        '--service_control_report_api_version_from_path',
+        action='store_true',
+        help='''
+        Report the version segment of the route path, e.g. `v2` for
+        `/v2/shelves`, as the API version to service control. Routes without
+        such a segment report the version of their API.
+        ''')
     parser.add_argument(
         '--backend_retry_ons',
         default=None,
@@ -648,6 +1221,183 @@ environment variable or by passing "-k" flag to this script.
         policies set in `--backend_retry_ons`. 
         The format is a comma-delimited String, like "501, 503".
         ''')
+    parser.add_argument(
+        '--backend_auth_static_token_files',
+        default=None,
+        help='''
+        Send a static bearer token read from a file as the Authorization
+        header to the backend, as comma-separated pairs of selector=path,
+        e.g. "1.echo_api.Echo=/etc/token". The file is reloaded whenever it
+        changes, so the token can be rotated without restarting ESPv2. It
+        takes precedence over the jwt_audience of the backend rule for the
+        given operations.
+        ''')
+    parser.add_argument(
+        '--backend_auth_token_headers',
+        default=None,
+        help='''
+        Send the backend auth token in a custom request header instead of the
+        Authorization header, as comma-separated pairs of selector=header,
+        e.g. "1.echo_api.Echo=X-Serverless-Authorization". The token is sent
+        as "Bearer <token>" and the Authorization header is left unchanged.
+        ''')
+    parser.add_argument(
+        '--backend_host_rewrite',
+        default=None,
+        help='''
+        Rewrite the Host header sent to all backends to the given host, e.g.
+        "api.example.com". Service control and routing still use the Host
+        header sent by the client. --backend_host_rewrite_by_operation takes
+        precedence for the selectors it lists.
+        ''')
+    parser.add_argument(
+        '--backend_host_rewrite_by_operation',
+        default=None,
+        help='''
+        Rewrite the Host header sent to the backend, as comma-separated pairs
+        of selector=host, e.g. "1.echo_api.Echo=api.example.com". By default,
+        the Host header is rewritten to the hostname of the backend rule
+        address for remote backends, and kept as is for the local backend.
+        ''')
+    parser.add_argument(
+        '--jwt_claim_backend_routes',
+        default=None,
+        help='''
+        Route requests whose verified JWT has a string claim with the given
+        value to a dedicated backend, as comma-separated triples of
+        claim:value=backend_address, e.g.
+        "tier:enterprise=https://enterprise-backend.example.com". Other
+        requests are sent to the usual backend.
+        ''')
+    parser.add_argument(
+        '--mirror_backend_address',
+        default=None,
+        help='''
+        Mirror requests to backends to this address, e.g.
+        "https://shadow-backend.example.com", to test a new backend with
+        production traffic. Responses from the mirror backend are discarded
+        and do not affect the client.
+        ''')
+    parser.add_argument(
+        '--mirror_percent',
+        default=None,
+        help='''
+        The percentage of requests, from 0 to 100, that are mirrored to
+        `--mirror_backend_address`. The default is 100.
+        ''')
+    parser.add_argument(
+        '--backend_fallback_address',
+        default=None,
+        help='''
+        Route requests for `--backend` to this address when none of its hosts
+        is healthy, e.g. "http://read-only-backend:8080". Both backends are
+        health checked by TCP connect, unless `--backend_health_check_path` is
+        set, and responses from the fallback backend carry the
+        `X-Endpoint-Backend-Fallback` header. It must use the same scheme as
+        `--backend`.
+        ''')
+    parser.add_argument(
+        '--backend_fallback_address_by_operation',
+        default=None,
+        help='''
+        Route requests of operations to these addresses when none of the hosts
+        of their backend is healthy, separated by comma, e.g.
+        "selector1=https://read-only-backend:8443". The fallback is set on the
+        backend cluster, so operations sharing a backend must use the same
+        fallback address, and it shares the protocol and TLS settings of the
+        backend.
+        ''')
+    parser.add_argument(
+        '--backend_health_check_path',
+        default=None,
+        help='''
+        If set, the backends with a fallback are health checked by HTTP GET
+        requests to this path, e.g. "/healthz", instead of TCP connect.
+        ''')
+    parser.add_argument(
+        '--backend_health_check_host',
+        default=None,
+        help='''
+        The Host header of the health checks by `--backend_health_check_path`.
+        Defaults to the cluster name of the backend.
+        ''')
+    parser.add_argument(
+        '--backend_health_check_expected_statuses',
+        default=None,
+        help='''
+        Comma-separated HTTP status codes, e.g. "200,204", of the health checks
+        by `--backend_health_check_path` that mark a backend healthy. Defaults
+        to 200.
+        ''')
+    parser.add_argument(
+        '--disable_chunked_encoding_backends',
+        default=None,
+        help='''
+        Comma-separated backend addresses, e.g.
+        "https://legacy-backend.example.com", that cannot handle chunked
+        requests. Requests to them are buffered, up to 10 MB, and sent with a
+        Content-Length. The addresses are either `--backend` or backend
+        addresses in the service config.
+        ''')
+    parser.add_argument(
+        '--streaming_passthrough_operations',
+        default=None,
+        help='''
+        Comma-separated selectors of operations whose requests are streamed to
+        the backend, e.g. large uploads. Their requests are neither buffered,
+        even if the backend is in `--disable_chunked_encoding_backends`, nor
+        transcoded to gRPC.
+        ''')
+    parser.add_argument(
+        '--streaming_response_operations',
+        default=None,
+        help='''
+        Comma-separated selectors of operations whose responses are streamed
+        to the client as they arrive, e.g. Server-Sent Events. Their responses
+        are not held back by `--enable_response_compression`, and the deadline
+        only bounds the wait for each chunk, through the stream idle timeout,
+        instead of the whole response.
+        ''')
+    parser.add_argument(
+        '--max_request_bytes_by_operation',
+        default=None,
+        help='''
+        Limit the request body size of operations in bytes, separated by
+        comma, e.g. "selector1=1048576,selector2=52428800". Requests of these
+        operations are buffered up to the limit, and larger ones are rejected
+        with 413. It is not supported for `--streaming_passthrough_operations`.
+        ''')
+    parser.add_argument(
+        '--query_parameter_allowlist_by_operation',
+        default=None,
+        help='''
+        Allow only the listed query parameters in requests of operations,
+        separated by comma, e.g.
+        "selector1=shelf|page_size,selector2=filter". Requests with any other
+        query parameter are rejected with 400. The API key and JWT query
+        parameters, and the ones in `--transcoding_ignore_query_parameters`,
+        are always allowed.
+        ''')
+    parser.add_argument(
+        '--skip_service_control_paths',
+        default=None,
+        help='''
+        Comma-separated paths, e.g. "/internal/health", that are routed to
+        the backend for any HTTP method without service control Check and
+        Report, and without requiring an API key. The paths must not be
+        defined by the service config.
+        ''')
+    parser.add_argument(
+        '--static_responses',
+        default=None,
+        help='''
+        Comma-separated paths, each optionally followed by "=" and a local
+        file, e.g. "/favicon.ico=/etc/espv2/favicon.ico,/robots.txt", that
+        are served directly for any HTTP method without the backend and
+        without service control. A path with a file responds 200 with the
+        file content, otherwise 204. The paths must not be defined by the
+        service config.
+        ''')
     parser.add_argument(
         '--backend_retry_num',
         default=None,
@@ -681,6 +1431,29 @@ environment variable or by passing "-k" flag to this script.
         https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log#default-format-string
         For the detailed format grammar, please refer to the following document.
         https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log#format-strings
+        To diagnose failed requests, log %%RESPONSE_FLAGS%% and
+        %%UPSTREAM_CLUSTER%%. The common response flags are UF (upstream
+        connection failure), UH (no healthy upstream host), UT (upstream
+        request timeout), UC (upstream connection termination), UR (upstream
+        remote reset), URX (upstream retry limit exceeded) and NR (no route
+        configured).
+        '''
+    )
+    parser.add_argument(
+        '--access_log_grpc_address',
+        help='''
+        Address of an Envoy gRPC access log service to which the access log
+        entries will be streamed, e.g. "grpc://als.example.com:9001", or
+        "grpcs://als.example.com" to use TLS. It is independent of
+        --access_log.
+        '''
+    )
+    parser.add_argument(
+        '--access_log_grpc_log_name',
+        help='''
+        The log name sent with the entries to the service of
+        --access_log_grpc_address, to tell ESPv2 apart from other Envoy
+        proxies logging to the same service. Default is "espv2".
         '''
     )
 
@@ -758,6 +1531,14 @@ environment variable or by passing "-k" flag to this script.
         This will also disable the following features:
         - Backend authentication
         ''')
+    parser.add_argument(
+        '--service_control_platform_override',
+        default=None,
+        help='''
+        The platform label, e.g. "GKE", "Cloud Run" or "On-Prem", reported
+        to service control. By default, the platform is detected from the
+        GCP metadata server, or is "UNKNOWN" with `--non_gcp`.
+        ''')
     parser.add_argument(
         '--service_account_key',
         help='''
@@ -785,6 +1566,138 @@ environment variable or by passing "-k" flag to this script.
         help='''
         Define the dns lookup family for all backends. The options are "auto", "v4only" and "v6only". The default is "auto".
         ''')
+    parser.add_argument(
+        '--backend_connect_timeout',
+        default=None,
+        help='''
+        Connect timeout for all backends, e.g. "5s". For HTTPS backends, it
+        includes the TLS handshake. Backends that are slow to accept
+        connections fail with 503 if it is too short. It only applies to
+        backend clusters and is overridden by
+        --backend_connect_timeout_by_operation. The default is the connect
+        timeout of all other clusters, 20s.
+        ''')
+    parser.add_argument(
+        '--backend_connect_timeout_by_operation',
+        default=None,
+        help='''
+        Override the backend connect timeout for operations, separated by
+        comma, e.g. "selector1=5s,selector2=30s". It takes precedence over
+        --backend_connect_timeout. The timeout applies to all operations
+        sharing the backend address, so they must not set different timeouts.
+        ''')
+    parser.add_argument(
+        '--backend_sni_by_operation',
+        default=None,
+        help='''
+        Override the TLS SNI sent to the HTTPS backend of operations,
+        separated by comma, e.g. "selector1=backend.example.com". By default,
+        the SNI is the hostname of the backend address. The SNI applies to all
+        operations sharing the backend address, so they must not set
+        different SNIs.
+        ''')
+    parser.add_argument(
+        '--backend_alpn_by_operation',
+        default=None,
+        help='''
+        Override the ALPN protocol advertised to the HTTPS backend of
+        operations, separated by comma, e.g. "selector1=http/1.1". It is
+        either "h2" or "http/1.1", and the backend is called with that
+        protocol. It can also be "auto" to advertise both and use the one
+        the backend selects, for backends that don't all support h2. gRPC
+        backends only support "h2". The ALPN applies to all
+        operations sharing the backend address, so they must not set
+        different ALPNs.
+        ''')
+    parser.add_argument(
+        '--backend_strip_prefix_by_operation',
+        default=None,
+        help='''
+        Remove a path prefix from the request path before it is sent to the
+        backend of operations, separated by comma, e.g. "selector1=/api/v1"
+        sends "/api/v1/books" to the backend as "/books". The path of the
+        backend address, if any, is prepended after the prefix is removed.
+        It is not supported for operations with CONSTANT_ADDRESS path
+        translation.
+        ''')
+    parser.add_argument(
+        '--backend_regex_rewrite_by_operation',
+        default=None,
+        help='''
+        Rewrite the path sent to the backend of operations with a RE2 regex,
+        separated by comma, e.g.
+        "selector1=^/v1/users/([^/]+)/profile$=/profile?user=\\1" sends
+        "/v1/users/123/profile?key=abc" to the backend as
+        "/profile?user=123&key=abc". Commas and equal signs in the pattern
+        must be escaped as "\\," and "\\=", except for the commas of
+        repetitions like "{1,3}". The pattern is matched against the path
+        without its query string, which is joined to the query string of the
+        substitution, if any. The path of the backend address, if any, is
+        prepended after the substitution. It is not supported for operations
+        with CONSTANT_ADDRESS path translation or
+        --backend_strip_prefix_by_operation.
+        ''')
+    parser.add_argument(
+        '--max_grpc_timeout',
+        default=None,
+        help='''
+        If set, gRPC requests are timed out after their grpc-timeout, capped
+        by this value, e.g. "30s", with DEADLINE_EXCEEDED. The deadline of the
+        backend still applies. Disabled by default.
+        ''')
+    parser.add_argument(
+        '--fault_abort_percent',
+        default=None,
+        help='''
+        For chaos testing, the percentage of requests, from 0 to 100, that are
+        aborted by ESPv2 with `--fault_abort_status` instead of being sent to
+        the backend. Disabled by default.
+        ''')
+    parser.add_argument(
+        '--fault_abort_status',
+        default=None,
+        help='''
+        The HTTP status code of requests aborted by `--fault_abort_percent`.
+        The default is 503.
+        ''')
+    parser.add_argument(
+        '--fault_delay_percent',
+        default=None,
+        help='''
+        For chaos testing, the percentage of requests, from 0 to 100, that are
+        delayed by `--fault_delay_duration` before being sent to the backend.
+        Disabled by default.
+        ''')
+    parser.add_argument(
+        '--fault_delay_duration',
+        default=None,
+        help='''
+        The delay injected into requests selected by `--fault_delay_percent`,
+        e.g. "2s".
+        ''')
+    parser.add_argument(
+        '--fault_header',
+        default=None,
+        help='''
+        If set, faults are only injected into requests with this header, e.g.
+        "x-inject-fault". Otherwise faults are injected into all requests,
+        except health checks.
+        ''')
+    parser.add_argument(
+        '--fault_api_keys',
+        default=None,
+        help='''
+        Comma-separated API keys, e.g. "qa-test-key". If set, faults are only
+        injected into requests with one of these API keys, so that a test
+        client can trigger faults without affecting other clients. API keys in
+        cookies are not matched.
+        ''')
+    parser.add_argument('--envoy_concurrency', default=None, type=int,
+        help='''
+        The number of Envoy worker threads. By default, Envoy starts one worker
+        thread per hardware thread, which wastes memory on small instances
+        that report many hardware threads.
+        ''')
     parser.add_argument('--enable_debug', action='store_true', default=False,
         help='''
         Enables a variety of debug features in both Config Manager and Envoy, such as:
@@ -824,6 +1737,36 @@ environment variable or by passing "-k" flag to this script.
          unknown/invalid query parameters.
          ''')
 
+    parser.add_argument(
+        '--transcoding_match_incoming_request_route', action='store_true',
+        help='''
+        Whether to keep the route matched by the incoming HTTP request after
+        grpc-json transcoding, instead of re-matching the route with the
+        transcoded gRPC path. Use this if multiple HTTP rules share a prefix
+        and the route configuration of the HTTP rule should be applied.
+        Defaults to false.
+        ''')
+
+    parser.add_argument(
+        '--transcoding_max_message_bytes', default=None,
+        help='''
+        The maximum size of a request or response message in grpc-json
+        transcoding, in bytes. The transcoder buffers whole messages within
+        the request buffer limit, which is raised to this size on the routes
+        to gRPC backends. If not set, messages are limited by
+        --envoy_connection_buffer_limit_bytes. Cannot be used with
+        --disable_transcoding.
+        ''')
+
+    parser.add_argument(
+        '--disable_transcoding', action='store_true',
+        help='''
+        When enabled, ESPv2 will not transcode HTTP/JSON requests to gRPC for
+        gRPC backends. Only native gRPC and gRPC-Web requests are supported;
+        HTTP/JSON requests are forwarded to the backend as-is. Service control
+        and authentication are still applied. Defaults to false.
+        ''')
+
     parser.add_argument(
         '--transcoding_ignore_unknown_query_parameters', action='store_true',
         help='''
@@ -962,6 +1905,16 @@ def enforce_conflict_args(args):
     if not args.access_log and args.access_log_format:
         return "Flag --access_log_format has to be used together with --access_log."
 
+    if not args.access_log_grpc_address and args.access_log_grpc_log_name:
+        return "Flag --access_log_grpc_log_name has to be used together with --access_log_grpc_address."
+
+    if not args.backend_fallback_address and not args.backend_fallback_address_by_operation and (
+            args.backend_health_check_path or args.backend_health_check_host or
+            args.backend_health_check_expected_statuses):
+        return "Flags --backend_health_check_path, --backend_health_check_host and" \
+               " --backend_health_check_expected_statuses have to be used together with" \
+               " --backend_fallback_address or --backend_fallback_address_by_operation."
+
     if args.ssl_port and args.ssl_server_cert_path:
         return "Flag --ssl_port is going to be deprecated, please use --ssl_server_cert_path only."
     if args.tls_mutual_auth and (args.ssl_backend_client_cert_path or args.ssl_client_cert_path):
@@ -1001,6 +1954,10 @@ def enforce_conflict_args(args):
         return "Flag --transcoding_ignore_query_parameters cannot be used" \
                " together with --transcoding_ignore_unknown_query_parameters."
 
+    if args.transcoding_max_message_bytes and args.disable_transcoding:
+        return "Flag --transcoding_max_message_bytes cannot be used" \
+               " together with --disable_transcoding."
+
     if args.dns_resolver_addresses and args.dns:
         return "Flag --dns_resolver_addresses cannot be used together with" \
                " together with --dns."
@@ -1027,6 +1984,7 @@ def gen_proxy_config(args):
         "--rollout_strategy", args.rollout_strategy,
     ]
 
+    # Environment variables in the address are resolved by the config manager.
     if "://" not in args.backend:
       proxy_conf.extend(["--backend_address", "http://" + args.backend])
     else:
@@ -1049,14 +2007,26 @@ def gen_proxy_config(args):
 
     if args.disable_jwks_async_fetch:
         proxy_conf.append("--disable_jwks_async_fetch")
+    if args.jwks_warm_on_startup:
+        proxy_conf.extend(["--jwks_warm_on_startup", args.jwks_warm_on_startup])
     if args.jwks_cache_duration_in_s:
          proxy_conf.extend(["--jwks_cache_duration_in_s", args.jwks_cache_duration_in_s])
+    if args.jwks_cache_duration_by_provider:
+        proxy_conf.extend(["--jwks_cache_duration_by_provider", args.jwks_cache_duration_by_provider])
+    if args.jwt_skip_audience_check:
+        proxy_conf.extend(["--jwt_skip_audience_check", args.jwt_skip_audience_check])
+    if args.jwt_provider_additional_issuers:
+        proxy_conf.extend(["--jwt_provider_additional_issuers", args.jwt_provider_additional_issuers])
     if args.jwks_fetch_num_retries:
          proxy_conf.extend(["--jwks_fetch_num_retries", args.jwks_fetch_num_retries])
     if args.jwks_fetch_retry_back_off_base_interval_ms:
          proxy_conf.extend(["--jwks_fetch_retry_back_off_base_interval_ms", args.jwks_fetch_retry_back_off_base_interval_ms])
     if args.jwks_fetch_retry_back_off_max_interval_ms:
          proxy_conf.extend(["--jwks_fetch_retry_back_off_max_interval_ms", args.jwks_fetch_retry_back_off_max_interval_ms])
+    if args.trust_preauthenticated_jwt_header:
+         proxy_conf.extend(["--trust_preauthenticated_jwt_header", args.trust_preauthenticated_jwt_header])
+    if args.check_api_key_before_jwt:
+        proxy_conf.append("--check_api_key_before_jwt")
 
     if args.management:
         proxy_conf.extend(["--service_management_url", args.management])
@@ -1067,6 +2037,12 @@ def gen_proxy_config(args):
     if args.log_response_headers:
         proxy_conf.extend(["--log_response_headers", args.log_response_headers])
 
+    if args.service_control_extra_labels:
+        proxy_conf.extend(["--service_control_extra_labels", args.service_control_extra_labels])
+
+    if args.log_entry_fields:
+        proxy_conf.extend(["--log_entry_fields", args.log_entry_fields])
+
     if args.log_jwt_payloads:
         proxy_conf.extend(["--log_jwt_payloads", args.log_jwt_payloads])
 
@@ -1080,6 +2056,16 @@ def gen_proxy_config(args):
         proxy_conf.extend(["--ssl_server_cert_path", str(args.ssl_server_cert_path)])
     if args.ssl_server_root_cert_path:
         proxy_conf.extend(["--ssl_server_root_cert_path", str(args.ssl_server_root_cert_path)])
+    if args.ssl_server_spiffe_trust_bundle_path:
+        proxy_conf.extend(["--ssl_server_spiffe_trust_bundle_path", str(args.ssl_server_spiffe_trust_bundle_path)])
+    if args.ssl_server_allowed_spiffe_ids:
+        proxy_conf.extend(["--ssl_server_allowed_spiffe_ids", str(args.ssl_server_allowed_spiffe_ids)])
+    if args.client_crl_file:
+        proxy_conf.extend(["--client_crl_file", str(args.client_crl_file)])
+    if args.forward_client_cert_details:
+        proxy_conf.extend(["--forward_client_cert_details", args.forward_client_cert_details])
+    if args.set_current_client_cert_details:
+        proxy_conf.extend(["--set_current_client_cert_details", args.set_current_client_cert_details])
     if args.ssl_port:
         proxy_conf.extend(["--ssl_server_cert_path", "/etc/nginx/ssl"])
         proxy_conf.extend(["--listener_port", str(args.ssl_port)])
@@ -1126,6 +2112,17 @@ def gen_proxy_config(args):
     if args.enable_operation_name_header:
         proxy_conf.append("--enable_operation_name_header")
 
+    if args.response_headers_allowlist:
+        proxy_conf.extend(["--response_headers_allowlist", args.response_headers_allowlist])
+
+    if args.enable_response_compression:
+        proxy_conf.append("--enable_response_compression")
+
+    if args.enable_operation_stats:
+        proxy_conf.append("--enable_operation_stats")
+    if args.enable_consumer_stats:
+        proxy_conf.append("--enable_consumer_stats")
+
     # Generate self-signed cert if needed
     if args.generate_self_signed_cert:
         if not os.path.exists("/tmp/ssl/endpoints"):
@@ -1146,6 +2143,12 @@ def gen_proxy_config(args):
     if args.http_request_timeout_s:
         proxy_conf.extend( ["--http_request_timeout_s", str(args.http_request_timeout_s)])
 
+    # The config manager serves the xDS config to the node id in the bootstrap.
+    if args.node_id:
+        proxy_conf.extend(["--node", args.node_id])
+    if args.node_cluster:
+        proxy_conf.extend(["--node_cluster", args.node_cluster])
+
     if args.service_control_check_retries:
         proxy_conf.extend([
             "--service_control_check_retries",
@@ -1164,12 +2167,51 @@ def gen_proxy_config(args):
             args.service_control_report_retries
         ])
 
+    if args.service_control_report_retry_backoff_ms:
+        proxy_conf.extend([
+            "--service_control_report_retry_backoff_ms",
+            args.service_control_report_retry_backoff_ms
+        ])
+
+    if args.service_control_max_pending_reports:
+        proxy_conf.extend([
+            "--service_control_max_pending_reports",
+            args.service_control_max_pending_reports
+        ])
+
+    if args.service_control_report_flush_jitter_ms:
+        proxy_conf.extend([
+            "--service_control_report_flush_jitter_ms",
+            args.service_control_report_flush_jitter_ms
+        ])
+
+    if args.service_control_operation_name_strip_prefix:
+        proxy_conf.extend([
+            "--service_control_operation_name_strip_prefix",
+            args.service_control_operation_name_strip_prefix
+        ])
+
+    if args.service_control_operation_name_map:
+        proxy_conf.extend([
+            "--service_control_operation_name_map",
+            args.service_control_operation_name_map
+        ])
+
+    if args.service_control_report_api_version_from_path:
+        proxy_conf.append("--service_control_report_api_version_from_path")
+
     if args.service_control_check_timeout_ms:
         proxy_conf.extend([
             "--service_control_check_timeout_ms",
             args.service_control_check_timeout_ms
         ])
 
+    if args.service_control_check_timeout:
+        proxy_conf.extend([
+            "--service_control_check_timeout",
+            args.service_control_check_timeout
+        ])
+
     if args.service_control_quota_timeout_ms:
         proxy_conf.extend([
             "--service_control_quota_timeout_ms",
@@ -1192,6 +2234,18 @@ def gen_proxy_config(args):
     if args.service_json_path:
         proxy_conf.extend(["--service_json_path", args.service_json_path])
 
+    if args.config_dump_path:
+        proxy_conf.extend(["--config_dump_path", args.config_dump_path])
+
+    if args.config_swap_grace_period:
+        proxy_conf.extend(["--config_swap_grace_period", args.config_swap_grace_period])
+
+    if args.auth_bypass_file:
+        proxy_conf.extend(["--auth_bypass_file", args.auth_bypass_file])
+
+    if args.additional_services:
+        proxy_conf.extend(["--additional_services", args.additional_services])
+
     if args.check_metadata:
         proxy_conf.append("--check_metadata")
 
@@ -1203,6 +2257,12 @@ def gen_proxy_config(args):
         proxy_conf.append("--merge_slashes_in_path=false")
     if args.disallow_escaped_slashes_in_path:
         proxy_conf.append("--disallow_escaped_slashes_in_path")
+    if args.decode_escaped_slashes_in_path:
+        proxy_conf.append("--decode_escaped_slashes_in_path")
+    if args.require_request_headers:
+        proxy_conf.extend(["--require_request_headers", args.require_request_headers])
+    if args.allow_duplicate_http_patterns:
+        proxy_conf.append("--allow_duplicate_http_patterns")
 
     if args.backend_retry_ons:
         proxy_conf.extend(["--backend_retry_ons", args.backend_retry_ons])
@@ -1214,6 +2274,86 @@ def gen_proxy_config(args):
     if args.backend_per_try_timeout:
         proxy_conf.extend(["--backend_per_try_timeout", args.backend_per_try_timeout])
 
+    if args.backend_auth_static_token_files:
+        proxy_conf.extend(["--backend_auth_static_token_files", args.backend_auth_static_token_files])
+    if args.backend_auth_token_headers:
+        proxy_conf.extend(["--backend_auth_token_headers", args.backend_auth_token_headers])
+
+    if args.backend_host_rewrite:
+        proxy_conf.extend(["--backend_host_rewrite", args.backend_host_rewrite])
+    if args.backend_host_rewrite_by_operation:
+        proxy_conf.extend(["--backend_host_rewrite_by_operation", args.backend_host_rewrite_by_operation])
+
+    if args.jwt_claim_backend_routes:
+        proxy_conf.extend(["--jwt_claim_backend_routes", args.jwt_claim_backend_routes])
+
+    if args.mirror_backend_address:
+        proxy_conf.extend(["--mirror_backend_address", args.mirror_backend_address])
+    if args.mirror_percent:
+        proxy_conf.extend(["--mirror_percent", args.mirror_percent])
+
+    if args.skip_service_control_paths:
+        proxy_conf.extend(["--skip_service_control_paths", args.skip_service_control_paths])
+
+    if args.static_responses:
+        proxy_conf.extend(["--static_responses", args.static_responses])
+
+    if args.listener_address:
+        proxy_conf.extend(["--listener_address", args.listener_address])
+
+    if args.max_request_headers_kb:
+        proxy_conf.extend(["--max_request_headers_kb", str(args.max_request_headers_kb)])
+
+    if args.max_request_duration:
+        proxy_conf.extend(["--max_request_duration", args.max_request_duration])
+
+    if args.service_control_jwt_claim_labels:
+        proxy_conf.extend(["--service_control_jwt_claim_labels", args.service_control_jwt_claim_labels])
+
+    if args.service_control_credential_id_prefix:
+        proxy_conf.extend(["--service_control_credential_id_prefix", args.service_control_credential_id_prefix])
+
+    if args.service_control_success_status_codes:
+        proxy_conf.extend(["--service_control_success_status_codes", args.service_control_success_status_codes])
+
+    if args.service_control_stats_report:
+        proxy_conf.extend(["--service_control_stats_report", args.service_control_stats_report])
+
+    if args.service_control_stats_report_interval:
+        proxy_conf.extend(["--service_control_stats_report_interval", args.service_control_stats_report_interval])
+
+    if args.service_control_unmatched_operation:
+        proxy_conf.extend(["--service_control_unmatched_operation", args.service_control_unmatched_operation])
+
+    if args.remove_api_key_from_request:
+        proxy_conf.append("--remove_api_key_from_request")
+
+    if args.backend_fallback_address:
+        proxy_conf.extend(["--backend_fallback_address", args.backend_fallback_address])
+    if args.backend_fallback_address_by_operation:
+        proxy_conf.extend(["--backend_fallback_address_by_operation", args.backend_fallback_address_by_operation])
+    if args.backend_health_check_path:
+        proxy_conf.extend(["--backend_health_check_path", args.backend_health_check_path])
+    if args.backend_health_check_host:
+        proxy_conf.extend(["--backend_health_check_host", args.backend_health_check_host])
+    if args.backend_health_check_expected_statuses:
+        proxy_conf.extend(["--backend_health_check_expected_statuses", args.backend_health_check_expected_statuses])
+
+    if args.disable_chunked_encoding_backends:
+        proxy_conf.extend(["--disable_chunked_encoding_backends", args.disable_chunked_encoding_backends])
+
+    if args.streaming_passthrough_operations:
+        proxy_conf.extend(["--streaming_passthrough_operations", args.streaming_passthrough_operations])
+
+    if args.streaming_response_operations:
+        proxy_conf.extend(["--streaming_response_operations", args.streaming_response_operations])
+
+    if args.max_request_bytes_by_operation:
+        proxy_conf.extend(["--max_request_bytes_by_operation", args.max_request_bytes_by_operation])
+
+    if args.query_parameter_allowlist_by_operation:
+        proxy_conf.extend(["--query_parameter_allowlist_by_operation", args.query_parameter_allowlist_by_operation])
+
     if args.access_log:
         proxy_conf.extend(["--access_log",
                            args.access_log])
@@ -1221,6 +2361,14 @@ def gen_proxy_config(args):
         proxy_conf.extend(["--access_log_format",
                            args.access_log_format])
 
+    if args.access_log_grpc_address:
+        proxy_conf.extend(["--access_log_grpc_address",
+                           args.access_log_grpc_address])
+
+    if args.access_log_grpc_log_name:
+        proxy_conf.extend(["--access_log_grpc_log_name",
+                           args.access_log_grpc_log_name])
+
     if args.disable_tracing:
         proxy_conf.append("--disable_tracing")
     else:
@@ -1258,7 +2406,20 @@ def gen_proxy_config(args):
     if args.transcoding_ignore_unknown_query_parameters:
         proxy_conf.append("--transcoding_ignore_unknown_query_parameters")
 
-    if args.on_serverless:
+    if args.transcoding_match_incoming_request_route:
+        proxy_conf.append("--transcoding_match_incoming_request_route")
+
+    if args.transcoding_max_message_bytes:
+        proxy_conf.extend(["--transcoding_max_message_bytes",
+                           args.transcoding_max_message_bytes])
+
+    if args.disable_transcoding:
+        proxy_conf.append("--disable_transcoding")
+
+    if args.service_control_platform_override:
+        proxy_conf.extend([
+            "--compute_platform_override", args.service_control_platform_override])
+    elif args.on_serverless:
         proxy_conf.extend([
             "--compute_platform_override", SERVERLESS_PLATFORM])
 
@@ -1274,6 +2435,44 @@ def gen_proxy_config(args):
             ["--dns_resolver_addresses", args.dns]
         )
 
+    if args.backend_connect_timeout:
+        proxy_conf.extend(
+            ["--backend_connect_timeout", args.backend_connect_timeout])
+    if args.backend_connect_timeout_by_operation:
+        proxy_conf.extend(
+            ["--backend_connect_timeout_by_operation",
+             args.backend_connect_timeout_by_operation])
+
+    if args.backend_sni_by_operation:
+        proxy_conf.extend(
+            ["--backend_sni_by_operation", args.backend_sni_by_operation])
+    if args.backend_alpn_by_operation:
+        proxy_conf.extend(
+            ["--backend_alpn_by_operation", args.backend_alpn_by_operation])
+    if args.backend_strip_prefix_by_operation:
+        proxy_conf.extend(
+            ["--backend_strip_prefix_by_operation",
+             args.backend_strip_prefix_by_operation])
+    if args.backend_regex_rewrite_by_operation:
+        proxy_conf.extend(
+            ["--backend_regex_rewrite_by_operation",
+             args.backend_regex_rewrite_by_operation])
+    if args.max_grpc_timeout:
+        proxy_conf.extend(["--max_grpc_timeout", args.max_grpc_timeout])
+
+    if args.fault_abort_percent:
+        proxy_conf.extend(["--fault_abort_percent", args.fault_abort_percent])
+    if args.fault_abort_status:
+        proxy_conf.extend(["--fault_abort_status", args.fault_abort_status])
+    if args.fault_delay_percent:
+        proxy_conf.extend(["--fault_delay_percent", args.fault_delay_percent])
+    if args.fault_delay_duration:
+        proxy_conf.extend(["--fault_delay_duration", args.fault_delay_duration])
+    if args.fault_header:
+        proxy_conf.extend(["--fault_header", args.fault_header])
+    if args.fault_api_keys:
+        proxy_conf.extend(["--fault_api_keys", args.fault_api_keys])
+
     if args.envoy_use_remote_address:
         proxy_conf.append("--envoy_use_remote_address")
 
@@ -1296,6 +2495,8 @@ def gen_proxy_config(args):
         ])
         if args.cors_allow_credentials:
             proxy_conf.append("--cors_allow_credentials")
+    if args.cors_disabled_operations:
+        proxy_conf.extend(["--cors_disabled_operations", args.cors_disabled_operations])
 
     # Set credentials file from the environment variable
     if args.service_account_key is None and GOOGLE_CREDS_KEY in os.environ:
@@ -1316,6 +2517,31 @@ def gen_proxy_config(args):
     if args.enable_backend_address_override:
         proxy_conf.append("--enable_backend_address_override")
 
+    if args.enable_proxy_protocol:
+        proxy_conf.append("--enable_proxy_protocol")
+
+    if args.accept_http_10:
+        proxy_conf.append("--accept_http_10")
+    if args.default_host_for_http_10:
+        proxy_conf.extend(["--default_host_for_http_10", args.default_host_for_http_10])
+
+    if args.listener_tcp_keepalive_time:
+        proxy_conf.extend(["--listener_tcp_keepalive_time", args.listener_tcp_keepalive_time])
+    if args.listener_tcp_keepalive_interval:
+        proxy_conf.extend(["--listener_tcp_keepalive_interval", args.listener_tcp_keepalive_interval])
+    if args.listener_tcp_keepalive_probes:
+        proxy_conf.extend(["--listener_tcp_keepalive_probes", args.listener_tcp_keepalive_probes])
+    if args.listener_http2_keepalive_interval:
+        proxy_conf.extend(["--listener_http2_keepalive_interval", args.listener_http2_keepalive_interval])
+    if args.listener_http2_keepalive_timeout:
+        proxy_conf.extend(["--listener_http2_keepalive_timeout", args.listener_http2_keepalive_timeout])
+    if args.listener_http2_max_concurrent_streams:
+        proxy_conf.extend(["--listener_http2_max_concurrent_streams", args.listener_http2_max_concurrent_streams])
+    if args.enable_reuse_port:
+        proxy_conf.append("--enable_reuse_port")
+    if args.backend_unavailable_message:
+        proxy_conf.extend(["--backend_unavailable_message", args.backend_unavailable_message])
+
     return proxy_conf
 
 def gen_envoy_args(args):
@@ -1326,6 +2552,9 @@ def gen_envoy_args(args):
            "--log-format %L%m%d %T.%e %t envoy] [%t][%n]%v",
            "--log-format-escaped"]
 
+    if args.envoy_concurrency:
+        cmd.extend(["--concurrency", str(args.envoy_concurrency)])
+
     if args.enable_debug:
         # Enable debug logging, but not for everything... too noisy otherwise.
         cmd.append("-l debug")
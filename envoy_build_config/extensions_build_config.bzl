@@ -11,18 +11,27 @@
 EXTENSIONS = {
     # All extensions explicitly referenced by config generator and our tests.
     "envoy.access_loggers.file": "//source/extensions/access_loggers/file:config",
+    "envoy.compression.gzip.compressor": "//source/extensions/compression/gzip/compressor:config",
+    "envoy.filters.http.buffer": "//source/extensions/filters/http/buffer:config",
+    "envoy.filters.http.compressor": "//source/extensions/filters/http/compressor:config",
     "envoy.filters.http.cors": "//source/extensions/filters/http/cors:config",
+    "envoy.filters.http.fault": "//source/extensions/filters/http/fault:config",
     "envoy.filters.http.grpc_json_transcoder": "//source/extensions/filters/http/grpc_json_transcoder:config",
     "envoy.filters.http.grpc_web": "//source/extensions/filters/http/grpc_web:config",
     "envoy.filters.http.health_check": "//source/extensions/filters/http/health_check:config",
     "envoy.filters.http.jwt_authn": "//source/extensions/filters/http/jwt_authn:config",
     "envoy.filters.http.router": "//source/extensions/filters/http/router:config",
+    "envoy.filters.listener.proxy_protocol": "//source/extensions/filters/listener/proxy_protocol:config",
     "envoy.filters.network.http_connection_manager": "//source/extensions/filters/network/http_connection_manager:config",
+    "envoy.resource_monitors.fixed_heap": "//source/extensions/resource_monitors/fixed_heap:config",
     "envoy.tracers.opencensus": "//source/extensions/tracers/opencensus:config",
 
     # Implicitly needed for TLS config.
     "envoy.transport_sockets.raw_buffer": "//source/extensions/transport_sockets/raw_buffer:config",
 
+    # Needed for --statsd_address with --enable_dog_statsd.
+    "envoy.stat_sinks.dog_statsd": "//source/extensions/stat_sinks/dog_statsd:config",
+
     # Remaining items are for API Gateway and not covered by our tests. Do not remove.
     "envoy.access_loggers.http_grpc": "//source/extensions/access_loggers/grpc:http_config",
     "envoy.filters.http.header_to_metadata": "//source/extensions/filters/http/header_to_metadata:config",
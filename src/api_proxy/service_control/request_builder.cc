@@ -371,6 +371,11 @@ unsigned int get_status_code(const ReportRequestInfo& info) {
   return info.http_response_code;
 }
 
+// Returns true if the response should be reported as an error.
+bool is_error_response(const ReportRequestInfo& info) {
+  return !info.success_status_code && get_status_code(info) >= 400;
+}
+
 // /credential_id
 Status set_credential_id(const SupportedLabel& l, const ReportRequestInfo& info,
                          Map<std::string, std::string>* labels) {
@@ -378,17 +383,19 @@ Status set_credential_id(const SupportedLabel& l, const ReportRequestInfo& info,
   // 1) If api_key is available and valid, set it as apiKey:API-KEY
   // 2) If auth issuer and audience both are available, set it as:
   //    jwtAuth:issuer=base64(issuer)&audience=base64(audience)
+  // Either form is prefixed with the configured credential_id_prefix.
   if (info.check_response_info.api_key_state ==
       api_key::ApiKeyState::VERIFIED) {
     ASSERT(!info.api_key.empty(),
            "API Key must be set, otherwise consumer would not be verified.");
-    std::string credential_id("apikey:");
-    credential_id += info.api_key;
+    std::string credential_id =
+        absl::StrCat(info.credential_id_prefix, "apikey:", info.api_key);
     (*labels)[l.name] = credential_id;
   } else if (!info.auth_issuer.empty()) {
     std::string base64_issuer = Envoy::Base64Url::encode(
         info.auth_issuer.data(), info.auth_issuer.size());
-    std::string credential_id = absl::StrCat("jwtauth:issuer=", base64_issuer);
+    std::string credential_id = absl::StrCat(info.credential_id_prefix,
+                                             "jwtauth:issuer=", base64_issuer);
     // auth audience is optional
     if (!info.auth_audience.empty()) {
       std::string base64_audience = Envoy::Base64Url::encode(
@@ -406,9 +413,8 @@ constexpr const char* error_types[10] = {"0xx", "1xx", "2xx", "3xx", "4xx",
 // /error_type
 Status set_error_type(const SupportedLabel& l, const ReportRequestInfo& info,
                       Map<std::string, std::string>* labels) {
-  int status_code = get_status_code(info);
-  if (status_code >= 400) {
-    int code = (status_code / 100) % 10;
+  if (is_error_response(info)) {
+    int code = (get_status_code(info) / 100) % 10;
     if (error_types[code]) {
       (*labels)[l.name] = error_types[code];
     }
@@ -466,6 +472,10 @@ Status set_response_code(const SupportedLabel& l, const ReportRequestInfo& info,
 Status set_response_code_class(const SupportedLabel& l,
                                const ReportRequestInfo& info,
                                Map<std::string, std::string>* labels) {
+  if (info.success_status_code) {
+    (*labels)[l.name] = error_types[2];
+    return OkStatus();
+  }
   (*labels)[l.name] = error_types[(get_status_code(info) / 100) % 10];
   return OkStatus();
 }
@@ -775,11 +785,19 @@ constexpr char kLogFieldNameResponseHeaders[] = "response_headers";
 constexpr char kLogFieldNameServiceAgent[] = "service_agent";
 constexpr char kLogFieldNameConfigId[] = "service_config_id";
 constexpr char kLogFieldNameTimestamp[] = "timestamp";
+constexpr char kLogFieldNameStats[] = "stats";
 constexpr char kLogFieldNameApiKeyState[] = "api_key_state";
 constexpr char kLogFieldNameResponseCodeDetail[] = "response_code_detail";
 constexpr char kLogFieldNameHttpStatusCode[] = "http_status_code";
 constexpr char kLogFieldNameGrpcStatusCode[] = "grpc_status_code";
 
+// Optional log field names, only added when configured.
+constexpr char kLogFieldNameConsumerProjectNumber[] = "consumer_project_number";
+constexpr char kLogFieldNameHttpMethod[] = "http_method";
+constexpr char kLogFieldNameRequestId[] = "request_id";
+constexpr char kLogFieldNameRequestLatencyInMs[] = "request_latency_in_ms";
+constexpr char kLogFieldNameUserAgent[] = "user_agent";
+
 // Convert time point to proto Timestamp
 Timestamp CreateTimestamp(std::chrono::system_clock::time_point tp) {
   long long timestamp_ns = std::chrono::duration_cast<std::chrono::nanoseconds>(
@@ -825,8 +843,8 @@ void FillLogEntry(const ReportRequestInfo& info, const std::string& name,
                   LogEntry* log_entry) {
   log_entry->set_name(name);
   *log_entry->mutable_timestamp() = current_time;
-  auto severity = (get_status_code(info) >= 400) ? google::logging::type::ERROR
-                                                 : google::logging::type::INFO;
+  auto severity = is_error_response(info) ? google::logging::type::ERROR
+                                          : google::logging::type::INFO;
   log_entry->set_severity(severity);
 
   // Add trace if available.
@@ -927,6 +945,33 @@ void FillLogEntry(const ReportRequestInfo& info, const std::string& name,
                 info.grpc_response_code.value()));
     (*fields)[kLogFieldNameGrpcStatusCode].set_string_value(grpc_status_string);
   }
+
+  for (const auto& field : info.log_entry_fields) {
+    if (field == kLogFieldNameConsumerProjectNumber) {
+      // Resolved by the Check, so it is missing if the Check is skipped.
+      if (!info.check_response_info.consumer_project_number.empty()) {
+        (*fields)[kLogFieldNameConsumerProjectNumber].set_string_value(
+            info.check_response_info.consumer_project_number);
+      }
+    } else if (field == kLogFieldNameHttpMethod) {
+      if (!info.method.empty()) {
+        (*fields)[kLogFieldNameHttpMethod].set_string_value(info.method);
+      }
+    } else if (field == kLogFieldNameRequestId) {
+      if (!info.request_id.empty()) {
+        (*fields)[kLogFieldNameRequestId].set_string_value(info.request_id);
+      }
+    } else if (field == kLogFieldNameRequestLatencyInMs) {
+      if (info.latency.request_time_ms >= 0) {
+        (*fields)[kLogFieldNameRequestLatencyInMs].set_number_value(
+            info.latency.request_time_ms);
+      }
+    } else if (field == kLogFieldNameUserAgent) {
+      if (!info.user_agent.empty()) {
+        (*fields)[kLogFieldNameUserAgent].set_string_value(info.user_agent);
+      }
+    }
+  }
 }
 
 template <class Element>
@@ -1108,6 +1153,9 @@ Status RequestBuilder::FillReportRequest(const ReportRequestInfo& info,
         if (!status.ok()) return status;
       }
     }
+    for (const auto& extra_label : info.extra_labels) {
+      (*labels)[extra_label.first] = extra_label.second;
+    }
 
     // Report will reject consumer metric if it's based on a invalid/unknown api
     // key, or if the service is not activated in the consumer project.
@@ -1139,6 +1187,42 @@ Status RequestBuilder::FillReportRequest(const ReportRequestInfo& info,
   return OkStatus();
 }
 
+Status RequestBuilder::FillStatsReportRequest(const StatsReportInfo& info,
+                                              ReportRequest* request) const {
+  request->set_service_name(service_name_);
+  request->set_service_config_id(service_config_id_);
+
+  Timestamp current_time = CreateTimestamp(info.current_time);
+  Operation* op = request->add_operations();
+  SetOperationCommonFields(info, current_time, op);
+
+  for (auto it = logs_.begin(), end = logs_.end(); it != end; it++) {
+    LogEntry* log_entry = op->add_log_entries();
+    log_entry->set_name(*it);
+    *log_entry->mutable_timestamp() = current_time;
+    log_entry->set_severity(google::logging::type::INFO);
+
+    auto* fields = log_entry->mutable_struct_payload()->mutable_fields();
+    (*fields)[kLogFieldNameTimestamp].set_number_value(
+        static_cast<double>(current_time.seconds()) +
+        static_cast<double>(current_time.nanos()) / 1000000000.0);
+    (*fields)[kLogFieldNameConfigId].set_string_value(service_config_id_);
+    (*fields)[kLogFieldNameServiceAgent].set_string_value(
+        kServiceAgentPrefix + utils::Version::instance().get());
+    if (!info.producer_project_id.empty()) {
+      (*fields)[kLogFieldNameProducerProjectId].set_string_value(
+          info.producer_project_id);
+    }
+
+    auto* stats =
+        (*fields)[kLogFieldNameStats].mutable_struct_value()->mutable_fields();
+    for (const auto& stat : info.stats) {
+      (*stats)[stat.first].set_number_value(stat.second);
+    }
+  }
+  return OkStatus();
+}
+
 Status RequestBuilder::AppendByConsumerOperations(
     const ReportRequestInfo& info,
     ::google::api::servicecontrol::v1::ReportRequest* request,
@@ -1166,6 +1250,9 @@ Status RequestBuilder::AppendByConsumerOperations(
         if (!status.ok()) return status;
       }
     }
+    for (const auto& extra_label : info.extra_labels) {
+      (*labels)[extra_label.first] = extra_label.second;
+    }
 
     // Populate all metrics.
     for (auto it = metrics_.begin(), end = metrics_.end(); it != end; it++) {
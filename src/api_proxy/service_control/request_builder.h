@@ -65,6 +65,11 @@ class RequestBuilder final {
       const ReportRequestInfo& info,
       ::google::api::servicecontrol::v1::ReportRequest* request) const;
 
+  // Fills the ReportRequest protobuf with a log entry of sampled Envoy stats.
+  ::google::protobuf::util::Status FillStatsReportRequest(
+      const StatsReportInfo& info,
+      ::google::api::servicecontrol::v1::ReportRequest* request) const;
+
   // Append a new consumer project Operations to the ReportRequest, if customer
   // project id from the CheckResponse is not empty
   ::google::protobuf::util::Status AppendByConsumerOperations(
@@ -462,6 +462,121 @@ TEST_F(RequestBuilderTest, CredentailIdIssuerAudienceTest) {
             "jwtauth:issuer=YXV0aC1pc3N1ZXI&audience=YXV0aC1hdWRpZW5jZQ");
 }
 
+TEST_F(RequestBuilderTest, CredentailIdPrefixTest) {
+  ReportRequestInfo info;
+  FillOperationInfo(&info);
+  info.credential_id_prefix = "billing-";
+
+  // API key credential.
+  info.check_response_info.api_key_state = api_key::ApiKeyState::VERIFIED;
+  gasv1::ReportRequest api_key_request;
+  ASSERT_TRUE(scp_.FillReportRequest(info, &api_key_request).ok());
+  ASSERT_EQ(api_key_request.operations(0).labels().at("/credential_id"),
+            "billing-apikey:api_key_x");
+
+  // JWT credential.
+  info.check_response_info.api_key_state = api_key::ApiKeyState::NOT_CHECKED;
+  info.api_key = "";
+  info.auth_issuer = "auth-issuer";
+  info.auth_audience = "auth-audience";
+  gasv1::ReportRequest jwt_request;
+  ASSERT_TRUE(scp_.FillReportRequest(info, &jwt_request).ok());
+  ASSERT_EQ(jwt_request.operations(0).labels().at("/credential_id"),
+            "billing-jwtauth:issuer=YXV0aC1pc3N1ZXI&audience="
+            "YXV0aC1hdWRpZW5jZQ");
+}
+
+TEST_F(RequestBuilderTest, SuccessStatusCodeTest) {
+  ReportRequestInfo info;
+  FillOperationInfo(&info);
+  FillReportRequestInfo(&info);
+  info.http_response_code = 404;
+
+  // By default, 404 is reported as an error.
+  gasv1::ReportRequest error_request;
+  ASSERT_TRUE(scp_.FillReportRequest(info, &error_request).ok());
+  const auto& error_operation = error_request.operations(0);
+  ASSERT_EQ(error_operation.labels().at("/error_type"), "4xx");
+  ASSERT_EQ(error_operation.labels().at("/response_code_class"), "4xx");
+  ASSERT_EQ(error_operation.labels().at("/response_code"), "404");
+  ASSERT_EQ(error_operation.log_entries(0).severity(),
+            google::logging::type::ERROR);
+
+  // A configured success status code is not reported as an error.
+  info.success_status_code = true;
+  gasv1::ReportRequest success_request;
+  ASSERT_TRUE(scp_.FillReportRequest(info, &success_request).ok());
+  const auto& success_operation = success_request.operations(0);
+  ASSERT_EQ(success_operation.labels().count("/error_type"), 0);
+  ASSERT_EQ(success_operation.labels().at("/response_code_class"), "2xx");
+  ASSERT_EQ(success_operation.labels().at("/response_code"), "404");
+  ASSERT_EQ(success_operation.log_entries(0).severity(),
+            google::logging::type::INFO);
+}
+
+TEST_F(RequestBuilderTest, FillStatsReportRequestTest) {
+  StatsReportInfo info;
+  FillOperationInfo(&info);
+  info.stats["server.live"] = 1;
+  info.stats["http.ingress_http.downstream_rq_total"] = 42;
+
+  gasv1::ReportRequest request;
+  ASSERT_TRUE(scp_.FillStatsReportRequest(info, &request).ok());
+  ASSERT_EQ(request.service_name(), "test_service");
+  ASSERT_EQ(request.service_config_id(), "2016-09-19r0");
+  ASSERT_EQ(request.operations_size(), 1);
+
+  const auto& operation = request.operations(0);
+  ASSERT_EQ(operation.operation_name(), "operation_name");
+  ASSERT_EQ(operation.labels_size(), 0);
+  ASSERT_EQ(operation.metric_value_sets_size(), 0);
+  ASSERT_EQ(operation.log_entries_size(), 1);
+
+  const auto& log_entry = operation.log_entries(0);
+  ASSERT_EQ(log_entry.name(), "local_test_log");
+  ASSERT_EQ(log_entry.severity(), google::logging::type::INFO);
+  const auto& fields = log_entry.struct_payload().fields();
+  ASSERT_EQ(fields.at("producer_project_id").string_value(), "project_id");
+  const auto& stats = fields.at("stats").struct_value().fields();
+  ASSERT_EQ(stats.size(), 2);
+  ASSERT_EQ(stats.at("server.live").number_value(), 1);
+  ASSERT_EQ(stats.at("http.ingress_http.downstream_rq_total").number_value(),
+            42);
+}
+
+TEST_F(RequestBuilderTest, ReportLogEntryFieldsTest) {
+  ReportRequestInfo info;
+  FillOperationInfo(&info);
+  FillReportRequestInfo(&info);
+  info.method = "GET";
+  info.user_agent = "test-agent";
+  info.request_id = "test-request-id";
+  info.check_response_info.consumer_project_number = "12345";
+
+  // Optional fields are not added by default.
+  gasv1::ReportRequest request;
+  ASSERT_TRUE(scp_.FillReportRequest(info, &request).ok());
+  auto fields = request.operations(0).log_entries(0).struct_payload().fields();
+  EXPECT_FALSE(fields.contains("consumer_project_number"));
+  EXPECT_FALSE(fields.contains("http_method"));
+  EXPECT_FALSE(fields.contains("request_id"));
+  EXPECT_FALSE(fields.contains("request_latency_in_ms"));
+  EXPECT_FALSE(fields.contains("user_agent"));
+
+  info.log_entry_fields = {"consumer_project_number", "http_method",
+                           "request_id", "request_latency_in_ms", "user_agent",
+                           "unknown_field"};
+  request.Clear();
+  ASSERT_TRUE(scp_.FillReportRequest(info, &request).ok());
+  fields = request.operations(0).log_entries(0).struct_payload().fields();
+  EXPECT_EQ(fields.at("consumer_project_number").string_value(), "12345");
+  EXPECT_EQ(fields.at("http_method").string_value(), "GET");
+  EXPECT_EQ(fields.at("request_id").string_value(), "test-request-id");
+  EXPECT_EQ(fields.at("request_latency_in_ms").number_value(), 123);
+  EXPECT_EQ(fields.at("user_agent").string_value(), "test-agent");
+  EXPECT_FALSE(fields.contains("unknown_field"));
+}
+
 }  // namespace
 
 }  // namespace service_control
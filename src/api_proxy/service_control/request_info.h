@@ -15,8 +15,10 @@
 #pragma once
 
 #include <chrono>
+#include <map>
 #include <memory>
 #include <string>
+#include <vector>
 
 #include "absl/types/optional.h"
 #include "google/api/quota.pb.h"
@@ -267,13 +269,38 @@ struct ReportRequestInfo : public OperationInfo {
   // Trace id (in hex) the request is tied to.
   std::string trace_id;
 
+  // Additional labels to report, keyed by label name.
+  std::map<std::string, std::string> extra_labels;
+
+  // The User-Agent header of the request.
+  std::string user_agent;
+
+  // The X-Request-Id header of the request, generated by Envoy if absent.
+  std::string request_id;
+
+  // Optional fields to add to the struct payload of the log entry.
+  std::vector<std::string> log_entry_fields;
+
+  // Prefix prepended to the reported /credential_id label.
+  std::string credential_id_prefix;
+
+  // Whether the response code is configured to be reported as successful.
+  bool success_status_code;
+
   ReportRequestInfo()
       : http_response_code(0),
         request_size(-1),
         response_size(-1),
         frontend_protocol(protocol::UNKNOWN),
         backend_protocol(protocol::UNKNOWN),
-        compute_platform("UNKNOWN(ESPv2)") {}
+        compute_platform("UNKNOWN(ESPv2)"),
+        success_status_code(false) {}
+};
+
+// Information to fill a Report request with sampled Envoy stats.
+struct StatsReportInfo : public OperationInfo {
+  // The sampled stat values, keyed by the full stat name.
+  std::map<std::string, uint64_t> stats;
 };
 
 }  // namespace service_control
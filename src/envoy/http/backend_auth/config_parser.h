@@ -18,6 +18,8 @@
 #include "absl/container/flat_hash_map.h"
 #include "absl/strings/str_cat.h"
 #include "api/envoy/v10/http/backend_auth/config.pb.h"
+#include "absl/types/optional.h"
+#include "envoy/http/header_map.h"
 #include "envoy/thread_local/thread_local.h"
 #include "src/envoy/token/token_subscriber_factory.h"
 
@@ -39,6 +41,9 @@ class FilterConfigParser {
 
   virtual const TokenSharedPtr getJwtToken(
       absl::string_view audience) const PURE;
+
+  virtual const TokenSharedPtr getStaticToken(
+      absl::string_view token_file) const PURE;
 };
 
 using FilterConfigParserPtr = std::unique_ptr<FilterConfigParser>;
@@ -48,12 +53,27 @@ class PerRouteFilterConfig : public Envoy::Router::RouteSpecificFilterConfig {
   PerRouteFilterConfig(
       const ::espv2::api::envoy::v10::http::backend_auth::PerRouteFilterConfig&
           per_route)
-      : jwt_audience_(per_route.jwt_audience()) {}
+      : jwt_audience_(per_route.jwt_audience()),
+        static_token_file_(per_route.static_token_file()) {
+    if (!per_route.token_header().empty()) {
+      token_header_.emplace(per_route.token_header());
+    }
+  }
 
   absl::string_view jwt_audience() const { return jwt_audience_; }
 
+  // Empty if the route uses a JWT token instead of a static token.
+  absl::string_view static_token_file() const { return static_token_file_; }
+
+  // Unset if the token is sent in the `Authorization` header.
+  const absl::optional<Envoy::Http::LowerCaseString>& token_header() const {
+    return token_header_;
+  }
+
  private:
   std::string jwt_audience_;
+  std::string static_token_file_;
+  absl::optional<Envoy::Http::LowerCaseString> token_header_;
 };
 
 using PerRouteFilterConfigSharedPtr = std::shared_ptr<PerRouteFilterConfig>;
@@ -16,6 +16,8 @@
 
 #include <memory>
 
+#include "absl/strings/ascii.h"
+#include "envoy/common/exception.h"
 #include "google/protobuf/util/time_util.h"
 #include "source/common/common/assert.h"
 
@@ -89,6 +91,61 @@ AudienceContext::AudienceContext(
   }
 }
 
+StaticTokenContext::StaticTokenContext(
+    const std::string& token_file,
+    Envoy::Server::Configuration::FactoryContext& context)
+    : token_file_(token_file),
+      api_(context.api()),
+      tls_(context.threadLocal()) {
+  tls_.set(
+      [](Envoy::Event::Dispatcher&) { return std::make_shared<TokenCache>(); });
+
+  if (!loadToken()) {
+    throw Envoy::EnvoyException(
+        absl::StrCat("Failed to load static token from file: ", token_file_));
+  }
+
+  // Watch for both in-place writes and atomic replacement via rename.
+  watcher_ = context.dispatcher().createFilesystemWatcher();
+  watcher_->addWatch(token_file_,
+                     Envoy::Filesystem::Watcher::Events::MovedTo |
+                         Envoy::Filesystem::Watcher::Events::Modified,
+                     [this](uint32_t) {
+                       // Keep serving the previous token if the new one is
+                       // not readable.
+                       if (!loadToken()) {
+                         ENVOY_LOG(warn,
+                                   "Failed to reload static token from file "
+                                   "{}, keeping the previous token",
+                                   token_file_);
+                       }
+                     });
+}
+
+bool StaticTokenContext::loadToken() {
+  std::string content;
+  try {
+    content = api_.fileSystem().fileReadToEnd(token_file_);
+  } catch (const Envoy::EnvoyException& e) {
+    ENVOY_LOG(error, "Failed to read static token file {}: {}", token_file_,
+              e.what());
+    return false;
+  }
+
+  const absl::string_view token = absl::StripAsciiWhitespace(content);
+  if (token.empty()) {
+    ENVOY_LOG(error, "Static token file {} is empty", token_file_);
+    return false;
+  }
+
+  TokenSharedPtr new_token = std::make_shared<std::string>(token);
+  tls_.runOnAllThreads([new_token](Envoy::OptRef<TokenCache> obj) {
+    obj->token_ = new_token;
+  });
+  ENVOY_LOG(debug, "Loaded static token from file {}", token_file_);
+  return true;
+}
+
 FilterConfigParserImpl::FilterConfigParserImpl(
     const FilterConfig& config,
     Envoy::Server::Configuration::FactoryContext& context,
@@ -123,6 +180,11 @@ FilterConfigParserImpl::FilterConfigParserImpl(
         jwt_audience, context, config, token_subscriber_factory,
         [this]() { return access_token_; }));
   }
+
+  for (const auto& token_file : config.static_token_files()) {
+    static_token_map_[token_file] =
+        std::make_unique<StaticTokenContext>(token_file, context);
+  }
 }
 }  // namespace backend_auth
 }  // namespace http_filters
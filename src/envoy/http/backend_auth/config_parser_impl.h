@@ -18,6 +18,7 @@
 #include "absl/container/flat_hash_map.h"
 #include "absl/strings/str_cat.h"
 #include "api/envoy/v10/http/backend_auth/config.pb.h"
+#include "envoy/filesystem/watcher.h"
 #include "envoy/thread_local/thread_local.h"
 #include "source/common/common/empty_string.h"
 #include "src/envoy/http/backend_auth/config_parser.h"
@@ -56,6 +57,33 @@ class AudienceContext {
 
 using AudienceContextPtr = std::unique_ptr<AudienceContext>;
 
+// Holds a static token read from a file. The file is watched and the token is
+// reloaded whenever the file is modified or replaced.
+class StaticTokenContext
+    : public Envoy::Logger::Loggable<Envoy::Logger::Id::filter> {
+ public:
+  StaticTokenContext(const std::string& token_file,
+                     Envoy::Server::Configuration::FactoryContext& context);
+  TokenSharedPtr token() const {
+    if (tls_->token_) {
+      return tls_->token_;
+    }
+    return nullptr;
+  }
+
+ private:
+  // Reads the token file and updates the token on all threads. Returns false
+  // if the file could not be read or is empty.
+  bool loadToken();
+
+  const std::string token_file_;
+  Envoy::Api::Api& api_;
+  Envoy::ThreadLocal::TypedSlot<TokenCache> tls_;
+  Envoy::Filesystem::WatcherPtr watcher_;
+};
+
+using StaticTokenContextPtr = std::unique_ptr<StaticTokenContext>;
+
 class FilterConfigParserImpl
     : public FilterConfigParser,
       public Envoy::Logger::Loggable<Envoy::Logger::Id::filter> {
@@ -73,12 +101,22 @@ class FilterConfigParserImpl
     return audience_it->second->token();
   }
 
+  const TokenSharedPtr getStaticToken(
+      absl::string_view token_file) const override {
+    auto token_file_it = static_token_map_.find(token_file);
+    if (token_file_it == static_token_map_.end()) {
+      return nullptr;
+    }
+    return token_file_it->second->token();
+  }
+
  private:
   //  access_token_ is required for authentication during fetching id_token from
   //  IAM server.
   std::string access_token_;
   token::TokenSubscriberPtr access_token_sub_ptr_;
   absl::flat_hash_map<std::string, AudienceContextPtr> audience_map_;
+  absl::flat_hash_map<std::string, StaticTokenContextPtr> static_token_map_;
 };
 
 }  // namespace backend_auth
@@ -18,11 +18,14 @@
 #include "gtest/gtest.h"
 #include "source/common/common/empty_string.h"
 #include "src/envoy/token/mocks.h"
+#include "test/mocks/filesystem/mocks.h"
 #include "test/mocks/server/mocks.h"
+#include "test/test_common/utility.h"
 
 using ::testing::_;
 using ::testing::Invoke;
 using ::testing::Return;
+using ::testing::SaveArg;
 namespace espv2 {
 namespace envoy {
 namespace http_filters {
@@ -177,6 +180,87 @@ iam_token {
   EXPECT_EQ(*config_parser_->getJwtToken("audience-bar"), "id-token-bar");
 }
 
+TEST_F(ConfigParserImplTest, GetStaticTokenFromFile) {
+  const char filter_config[] = R"(
+static_token_files: ["/path/to/token"]
+imds_token {
+  uri: "this-is-uri"
+  cluster: "this-is-cluster"
+  timeout: {
+    seconds: 20
+  }
+}
+)";
+
+  EXPECT_CALL(mock_token_subscriber_factory_, createImdsTokenSubscriber)
+      .Times(0);
+  EXPECT_CALL(mock_factory_context_.api_.file_system_,
+              fileReadToEnd("/path/to/token"))
+      .WillOnce(Return("token-foo\n"))
+      .WillOnce(Return("token-bar\n"));
+
+  Envoy::Filesystem::Watcher::OnChangedCb on_changed;
+  auto* watcher = new testing::NiceMock<Envoy::Filesystem::MockWatcher>();
+  EXPECT_CALL(mock_factory_context_.dispatcher_, createFilesystemWatcher_())
+      .WillOnce(Return(watcher));
+  const uint32_t events = Envoy::Filesystem::Watcher::Events::MovedTo |
+                          Envoy::Filesystem::Watcher::Events::Modified;
+  EXPECT_CALL(*watcher, addWatch("/path/to/token", events, _))
+      .WillOnce(SaveArg<2>(&on_changed));
+
+  setUp(filter_config);
+  EXPECT_EQ(*config_parser_->getStaticToken("/path/to/token"), "token-foo");
+  EXPECT_EQ(config_parser_->getStaticToken("/path/to/non-existent"), nullptr);
+
+  // The token is reloaded when the file changes.
+  on_changed(Envoy::Filesystem::Watcher::Events::MovedTo);
+  EXPECT_EQ(*config_parser_->getStaticToken("/path/to/token"), "token-bar");
+}
+
+TEST_F(ConfigParserImplTest, StaticTokenKeptOnReloadFailure) {
+  const char filter_config[] = R"(
+static_token_files: ["/path/to/token"]
+imds_token {
+  uri: "this-is-uri"
+  cluster: "this-is-cluster"
+}
+)";
+
+  EXPECT_CALL(mock_factory_context_.api_.file_system_,
+              fileReadToEnd("/path/to/token"))
+      .WillOnce(Return("token-foo"))
+      .WillOnce(Return(""));
+
+  Envoy::Filesystem::Watcher::OnChangedCb on_changed;
+  auto* watcher = new testing::NiceMock<Envoy::Filesystem::MockWatcher>();
+  EXPECT_CALL(mock_factory_context_.dispatcher_, createFilesystemWatcher_())
+      .WillOnce(Return(watcher));
+  EXPECT_CALL(*watcher, addWatch("/path/to/token", _, _))
+      .WillOnce(SaveArg<2>(&on_changed));
+
+  setUp(filter_config);
+  on_changed(Envoy::Filesystem::Watcher::Events::Modified);
+  EXPECT_EQ(*config_parser_->getStaticToken("/path/to/token"), "token-foo");
+}
+
+TEST_F(ConfigParserImplTest, StaticTokenFileUnreadable) {
+  const char filter_config[] = R"(
+static_token_files: ["/path/to/token"]
+imds_token {
+  uri: "this-is-uri"
+  cluster: "this-is-cluster"
+}
+)";
+
+  EXPECT_CALL(mock_factory_context_.api_.file_system_,
+              fileReadToEnd("/path/to/token"))
+      .WillOnce(testing::Throw(Envoy::EnvoyException("no such file")));
+
+  EXPECT_THROW_WITH_MESSAGE(
+      setUp(filter_config), Envoy::EnvoyException,
+      "Failed to load static token from file: /path/to/token");
+}
+
 }  // namespace backend_auth
 }  // namespace http_filters
 }  // namespace envoy
@@ -69,19 +69,36 @@ FilterHeadersStatus Filter::decodeHeaders(RequestHeaderMap& headers, bool) {
     return FilterHeadersStatus::Continue;
   }
 
-  const auto& audience = per_route->jwt_audience();
-  ENVOY_LOG(debug, "Found jwt_audience: {}", audience);
-  const TokenSharedPtr jwt_token = config_->cfg_parser().getJwtToken(audience);
+  TokenSharedPtr jwt_token;
+  std::string missing_token_msg;
+  const auto& token_file = per_route->static_token_file();
+  if (!token_file.empty()) {
+    ENVOY_LOG(debug, "Found static_token_file: {}", token_file);
+    jwt_token = config_->cfg_parser().getStaticToken(token_file);
+    missing_token_msg = absl::StrCat("Token not found for file: ", token_file);
+  } else {
+    const auto& audience = per_route->jwt_audience();
+    ENVOY_LOG(debug, "Found jwt_audience: {}", audience);
+    jwt_token = config_->cfg_parser().getJwtToken(audience);
+    missing_token_msg =
+        absl::StrCat("Token not found for audience: ", audience);
+  }
+
   if (!jwt_token) {
     config_->stats().denied_by_no_token_.inc();
     rejectRequest(
-        Envoy::Http::Code::InternalServerError,
-        absl::StrCat("Token not found for audience: ", audience),
+        Envoy::Http::Code::InternalServerError, missing_token_msg,
         utils::generateRcDetails(utils::kRcDetailFilterBackendAuth,
                                  utils::kRcDetailErrorTypeMissingBackendToken));
     return FilterHeadersStatus::StopIteration;
   }
 
+  if (per_route->token_header().has_value()) {
+    headers.setCopy(per_route->token_header().value(), kBearer + *jwt_token);
+    config_->stats().token_added_.inc();
+    return FilterHeadersStatus::Continue;
+  }
+
   // Copy the existing `Authorization` header to `x-forwarded-authorization`
   // header.
   const Envoy::Http::HeaderEntry* existAuthToken =
@@ -64,6 +64,28 @@ class BackendAuthFilterTest : public ::testing::Test {
     ::espv2::api::envoy::v10::http::backend_auth::PerRouteFilterConfig
         per_route_cfg;
     per_route_cfg.set_jwt_audience(jwt_audience);
+    setPerRouteConfig(per_route_cfg);
+  }
+
+  void setPerRouteStaticTokenFile(const std::string& static_token_file) {
+    ::espv2::api::envoy::v10::http::backend_auth::PerRouteFilterConfig
+        per_route_cfg;
+    per_route_cfg.set_static_token_file(static_token_file);
+    setPerRouteConfig(per_route_cfg);
+  }
+
+  void setPerRouteJwtAudienceAndTokenHeader(const std::string& jwt_audience,
+                                            const std::string& token_header) {
+    ::espv2::api::envoy::v10::http::backend_auth::PerRouteFilterConfig
+        per_route_cfg;
+    per_route_cfg.set_jwt_audience(jwt_audience);
+    per_route_cfg.set_token_header(token_header);
+    setPerRouteConfig(per_route_cfg);
+  }
+
+  void setPerRouteConfig(
+      const ::espv2::api::envoy::v10::http::backend_auth::PerRouteFilterConfig&
+          per_route_cfg) {
     auto per_route = std::make_shared<PerRouteFilterConfig>(per_route_cfg);
     EXPECT_CALL(mock_decoder_callbacks_, route())
         .WillRepeatedly(Return(mock_route_));
@@ -236,6 +258,99 @@ TEST_F(BackendAuthFilterTest, SucceedTokenOverridden) {
   EXPECT_EQ(counter->value(), 1);
 }
 
+TEST_F(BackendAuthFilterTest, SucceedAppendTokenToCustomHeader) {
+  Envoy::Http::TestRequestHeaderMapImpl headers{
+      {":method", "GET"},
+      {":path", "/books/1"},
+      {"authorization", "Bearer origin-token"}};
+
+  setPerRouteJwtAudienceAndTokenHeader("this-is-audience",
+                                       "X-Serverless-Authorization");
+
+  EXPECT_CALL(*mock_filter_config_parser_, getJwtToken("this-is-audience"))
+      .Times(1)
+      .WillRepeatedly(Return(std::make_shared<std::string>("new-id-token")));
+
+  Envoy::Http::FilterHeadersStatus status =
+      filter_->decodeHeaders(headers, false);
+
+  ASSERT_EQ(headers.get(Envoy::Http::LowerCaseString(
+                            "x-serverless-authorization"))
+                .size(),
+            1);
+  EXPECT_EQ(headers
+                .get(Envoy::Http::LowerCaseString(
+                    "x-serverless-authorization"))[0]
+                ->value()
+                .getStringView(),
+            "Bearer new-id-token");
+
+  // The `Authorization` header is left unchanged.
+  ASSERT_EQ(headers.get(Envoy::Http::CustomHeaders::get().Authorization).size(),
+            1);
+  EXPECT_EQ(headers.get(Envoy::Http::CustomHeaders::get().Authorization)[0]
+                ->value()
+                .getStringView(),
+            "Bearer origin-token");
+  EXPECT_TRUE(headers.get(kXForwardedAuthorization).empty());
+  EXPECT_EQ(status, Envoy::Http::FilterHeadersStatus::Continue);
+
+  // Stats.
+  const Envoy::Stats::CounterSharedPtr counter =
+      Envoy::TestUtility::findCounter(scope_, "backend_auth.token_added");
+  ASSERT_NE(counter, nullptr);
+  EXPECT_EQ(counter->value(), 1);
+}
+
+TEST_F(BackendAuthFilterTest, MissingStaticTokenRejected) {
+  Envoy::Http::TestRequestHeaderMapImpl headers{{":method", "GET"},
+                                                {":path", "/books/1"}};
+  setPerRouteStaticTokenFile("/path/to/token");
+
+  EXPECT_CALL(*mock_filter_config_parser_, getJwtToken(_)).Times(0);
+  EXPECT_CALL(*mock_filter_config_parser_, getStaticToken("/path/to/token"))
+      .WillOnce(Return(nullptr));
+  EXPECT_CALL(mock_decoder_callbacks_,
+              sendLocalReply(Envoy::Http::Code::InternalServerError,
+                             "Token not found for file: /path/to/token", _, _,
+                             "backend_auth_missing_backend_token"));
+
+  Envoy::Http::FilterHeadersStatus status =
+      filter_->decodeHeaders(headers, false);
+
+  ASSERT_EQ(status, Envoy::Http::FilterHeadersStatus::StopIteration);
+}
+
+TEST_F(BackendAuthFilterTest, SucceedAppendStaticToken) {
+  Envoy::Http::TestRequestHeaderMapImpl headers{
+      {":method", "GET"},
+      {":path", "/books/1"},
+      {"authorization", "Bearer origin-token"}};
+  setPerRouteStaticTokenFile("/path/to/token");
+
+  EXPECT_CALL(*mock_filter_config_parser_, getJwtToken(_)).Times(0);
+  EXPECT_CALL(*mock_filter_config_parser_, getStaticToken("/path/to/token"))
+      .WillOnce(Return(std::make_shared<std::string>("static-token")));
+
+  Envoy::Http::FilterHeadersStatus status =
+      filter_->decodeHeaders(headers, false);
+
+  EXPECT_EQ(headers.get(Envoy::Http::CustomHeaders::get().Authorization)[0]
+                ->value()
+                .getStringView(),
+            "Bearer static-token");
+  ASSERT_EQ(headers.get(kXForwardedAuthorization).size(), 1);
+  EXPECT_EQ(headers.get(kXForwardedAuthorization)[0]->value().getStringView(),
+            "Bearer origin-token");
+  EXPECT_EQ(status, Envoy::Http::FilterHeadersStatus::Continue);
+
+  // Stats.
+  const Envoy::Stats::CounterSharedPtr counter =
+      Envoy::TestUtility::findCounter(scope_, "backend_auth.token_added");
+  ASSERT_NE(counter, nullptr);
+  EXPECT_EQ(counter->value(), 1);
+}
+
 }  // namespace backend_auth
 }  // namespace http_filters
 }  // namespace envoy
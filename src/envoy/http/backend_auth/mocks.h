@@ -24,6 +24,8 @@ class MockFilterConfigParser : public FilterConfigParser {
  public:
   MOCK_METHOD(const TokenSharedPtr, getJwtToken, (absl::string_view audience),
               (const));
+  MOCK_METHOD(const TokenSharedPtr, getStaticToken,
+              (absl::string_view token_file), (const));
 };
 
 class MockFilterConfig : public FilterConfig {
@@ -0,0 +1,45 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "src/envoy/http/header_allowlist/filter.h"
+
+#include <string>
+
+namespace espv2 {
+namespace envoy {
+namespace http_filters {
+namespace header_allowlist {
+
+Envoy::Http::FilterHeadersStatus Filter::encodeHeaders(
+    Envoy::Http::ResponseHeaderMap& headers, bool) {
+  ENVOY_LOG(debug, "Filter::encodeHeaders is called.");
+  config_->stats().all_.inc();
+
+  const size_t removed =
+      headers.removeIf([this](const Envoy::Http::HeaderEntry& entry) -> bool {
+        return !config_->isAllowed(entry.key().getStringView());
+      });
+  if (removed > 0) {
+    ENVOY_LOG(debug, "{} response headers not in the allowlist are removed",
+              removed);
+    config_->stats().removed_.add(removed);
+  }
+
+  return Envoy::Http::FilterHeadersStatus::Continue;
+}
+
+}  // namespace header_allowlist
+}  // namespace http_filters
+}  // namespace envoy
+}  // namespace espv2
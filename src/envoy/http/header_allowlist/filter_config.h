@@ -0,0 +1,86 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#pragma once
+
+#include <string>
+
+#include "absl/container/flat_hash_set.h"
+#include "absl/strings/ascii.h"
+#include "api/envoy/v10/http/header_allowlist/config.pb.h"
+#include "envoy/server/filter_config.h"
+
+namespace espv2 {
+namespace envoy {
+namespace http_filters {
+namespace header_allowlist {
+
+/**
+ * All stats for the header allowlist filter. @see stats_macros.h
+ */
+
+#define ALL_HEADER_ALLOWLIST_FILTER_STATS(COUNTER) \
+  COUNTER(all)                                     \
+  COUNTER(removed)
+
+/**
+ * Wrapper struct for header allowlist filter stats. @see stats_macros.h
+ */
+struct FilterStats {
+  ALL_HEADER_ALLOWLIST_FILTER_STATS(GENERATE_COUNTER_STRUCT)
+};
+
+class FilterConfig {
+ public:
+  FilterConfig(
+      const ::espv2::api::envoy::v10::http::header_allowlist::FilterConfig&
+          proto_config,
+      const std::string& stats_prefix,
+      Envoy::Server::Configuration::FactoryContext& context)
+      : stats_(generateStats(stats_prefix, context.scope())),
+        // The gRPC status headers are sent in the headers of trailers-only
+        // responses, so they are always allowed.
+        allowed_headers_({":status", "content-type", "content-length",
+                          "content-encoding", "grpc-status", "grpc-message",
+                          "grpc-status-details-bin"}) {
+    for (const auto& header : proto_config.response_headers()) {
+      allowed_headers_.insert(absl::AsciiStrToLower(header));
+    }
+  }
+
+  FilterStats& stats() { return stats_; }
+
+  bool isAllowed(absl::string_view header) const {
+    return allowed_headers_.contains(header);
+  }
+
+ private:
+  FilterStats generateStats(const std::string& prefix,
+                            Envoy::Stats::Scope& scope) {
+    const std::string final_prefix = prefix + "header_allowlist.";
+    return {ALL_HEADER_ALLOWLIST_FILTER_STATS(
+        POOL_COUNTER_PREFIX(scope, final_prefix))};
+  }
+
+  FilterStats stats_;
+  // Lower-cased names of the response headers forwarded to clients.
+  absl::flat_hash_set<std::string> allowed_headers_;
+};
+
+using FilterConfigSharedPtr = std::shared_ptr<FilterConfig>;
+
+}  // namespace header_allowlist
+}  // namespace http_filters
+}  // namespace envoy
+}  // namespace espv2
@@ -0,0 +1,64 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "api/envoy/v10/http/header_allowlist/config.pb.h"
+#include "api/envoy/v10/http/header_allowlist/config.pb.validate.h"
+#include "envoy/registry/registry.h"
+#include "source/extensions/filters/http/common/factory_base.h"
+#include "src/envoy/http/header_allowlist/filter.h"
+
+namespace espv2 {
+namespace envoy {
+namespace http_filters {
+namespace header_allowlist {
+
+constexpr char kHeaderAllowlistFilterName[] =
+    "com.google.espv2.filters.http.header_allowlist";
+
+/**
+ * Config registration for ESPv2 header allowlist filter.
+ */
+class FilterFactory
+    : public Envoy::Extensions::HttpFilters::Common::FactoryBase<
+          ::espv2::api::envoy::v10::http::header_allowlist::FilterConfig> {
+ public:
+  FilterFactory() : FactoryBase(kHeaderAllowlistFilterName) {}
+
+ private:
+  Envoy::Http::FilterFactoryCb createFilterFactoryFromProtoTyped(
+      const ::espv2::api::envoy::v10::http::header_allowlist::
+          FilterConfig& proto_config,
+      const std::string& stats_prefix,
+      Envoy::Server::Configuration::FactoryContext& context) override {
+    auto filter_config = std::make_shared<FilterConfig>(proto_config,
+                                                        stats_prefix, context);
+    return [filter_config](
+               Envoy::Http::FilterChainFactoryCallbacks& callbacks) -> void {
+      auto filter = std::make_shared<Filter>(filter_config);
+      callbacks.addStreamEncoderFilter(
+          Envoy::Http::StreamEncoderFilterSharedPtr(filter));
+    };
+  }
+};
+/**
+ * Static registration for the filter. @see RegisterFactory.
+ */
+static Envoy::Registry::RegisterFactory<
+    FilterFactory, Envoy::Server::Configuration::NamedHttpFilterConfigFactory>
+    register_;
+
+}  // namespace header_allowlist
+}  // namespace http_filters
+}  // namespace envoy
+}  // namespace espv2
@@ -0,0 +1,114 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "src/envoy/http/header_allowlist/filter.h"
+
+#include "gmock/gmock.h"
+#include "gtest/gtest.h"
+#include "source/common/common/empty_string.h"
+#include "test/mocks/http/mocks.h"
+#include "test/mocks/server/mocks.h"
+#include "test/test_common/utility.h"
+
+namespace espv2 {
+namespace envoy {
+namespace http_filters {
+namespace header_allowlist {
+namespace {
+
+using ::espv2::api::envoy::v10::http::header_allowlist::FilterConfig;
+using Envoy::Http::MockStreamEncoderFilterCallbacks;
+using Envoy::Server::Configuration::MockFactoryContext;
+
+class HeaderAllowlistFilterTest : public ::testing::Test {
+ protected:
+  void SetUp() override {
+    FilterConfig proto_config;
+    proto_config.add_response_headers("X-Allowed");
+    config_ = std::make_shared<
+        ::espv2::envoy::http_filters::header_allowlist::FilterConfig>(
+        proto_config, Envoy::EMPTY_STRING, mock_factory_context_);
+    filter_ = std::make_unique<Filter>(config_);
+    filter_->setEncoderFilterCallbacks(mock_cb_);
+  }
+
+  std::unique_ptr<Filter> filter_;
+  FilterConfigSharedPtr config_;
+  testing::NiceMock<MockFactoryContext> mock_factory_context_;
+  testing::NiceMock<MockStreamEncoderFilterCallbacks> mock_cb_;
+};
+
+TEST_F(HeaderAllowlistFilterTest, HeadersNotInAllowlistRemoved) {
+  Envoy::Http::TestResponseHeaderMapImpl headers{
+      {":status", "200"},        {"content-type", "application/json"},
+      {"content-length", "100"}, {"x-allowed", "a"},
+      {"x-not-allowed", "b"},    {"server", "backend"}};
+  EXPECT_EQ(Envoy::Http::FilterHeadersStatus::Continue,
+            filter_->encodeHeaders(headers, false));
+
+  EXPECT_EQ(Envoy::TestUtility::findCounter(mock_factory_context_.scope_,
+                                            "header_allowlist.all")
+                ->value(),
+            1L);
+  EXPECT_EQ(Envoy::TestUtility::findCounter(mock_factory_context_.scope_,
+                                            "header_allowlist.removed")
+                ->value(),
+            2L);
+
+  // Essential and allowlisted headers are kept.
+  EXPECT_EQ(headers.getStatusValue(), "200");
+  EXPECT_EQ(headers.getContentTypeValue(), "application/json");
+  EXPECT_EQ(headers.getContentLengthValue(), "100");
+  EXPECT_EQ(headers.get_("x-allowed"), "a");
+  EXPECT_FALSE(headers.has("x-not-allowed"));
+  EXPECT_FALSE(headers.has("server"));
+}
+
+TEST_F(HeaderAllowlistFilterTest, GrpcStatusHeadersKept) {
+  // A trailers-only gRPC error response.
+  Envoy::Http::TestResponseHeaderMapImpl headers{
+      {":status", "200"},
+      {"content-type", "application/grpc"},
+      {"grpc-status", "3"},
+      {"grpc-message", "invalid shelf"},
+      {"grpc-status-details-bin", "CAMSDWludmFsaWQgc2hlbGY"},
+      {"x-not-allowed", "b"}};
+  EXPECT_EQ(Envoy::Http::FilterHeadersStatus::Continue,
+            filter_->encodeHeaders(headers, true));
+
+  EXPECT_EQ(headers.getGrpcStatusValue(), "3");
+  EXPECT_EQ(headers.getGrpcMessageValue(), "invalid shelf");
+  EXPECT_EQ(headers.get_("grpc-status-details-bin"), "CAMSDWludmFsaWQgc2hlbGY");
+  EXPECT_FALSE(headers.has("x-not-allowed"));
+}
+
+TEST_F(HeaderAllowlistFilterTest, NoHeadersRemoved) {
+  Envoy::Http::TestResponseHeaderMapImpl headers{{":status", "200"},
+                                                 {"x-allowed", "a"}};
+  EXPECT_EQ(Envoy::Http::FilterHeadersStatus::Continue,
+            filter_->encodeHeaders(headers, false));
+
+  EXPECT_EQ(Envoy::TestUtility::findCounter(mock_factory_context_.scope_,
+                                            "header_allowlist.removed")
+                ->value(),
+            0L);
+  EXPECT_EQ(headers.size(), 2);
+}
+
+}  // namespace
+
+}  // namespace header_allowlist
+}  // namespace http_filters
+}  // namespace envoy
+}  // namespace espv2
@@ -14,8 +14,11 @@
 
 #include "src/envoy/http/path_rewrite/config_parser_impl.h"
 
+#include "absl/strings/match.h"
 #include "absl/strings/str_cat.h"
+#include "envoy/type/matcher/v3/regex.pb.h"
 #include "source/common/common/empty_string.h"
+#include "source/common/common/regex.h"
 #include "src/api_proxy/path_matcher/variable_binding_utils.h"
 
 namespace espv2 {
@@ -56,6 +59,34 @@ ConfigParserImpl::ConfigParserImpl(
       config_.mutable_constant_path()->set_path(
           path.substr(0, path.size() - 1));
     }
+  } else if (config_.has_strip_prefix()) {
+    // Remove the last slash of both prefixes, even for "/" in path_prefix.
+    const std::string& prefix = config_.strip_prefix().prefix();
+    if (prefix.size() > 1 && prefix[prefix.size() - 1] == '/') {
+      ENVOY_LOG(warn, "Remove last slash of strip_prefix.prefix: {}", prefix);
+      config_.mutable_strip_prefix()->set_prefix(
+          prefix.substr(0, prefix.size() - 1));
+    }
+    const std::string& path = config_.strip_prefix().path_prefix();
+    if (path.size() > 0 && path[path.size() - 1] == '/') {
+      ENVOY_LOG(warn, "Remove last slash of strip_prefix.path_prefix: {}",
+                path);
+      config_.mutable_strip_prefix()->set_path_prefix(
+          path.substr(0, path.size() - 1));
+    }
+  } else if (config_.has_regex_rewrite()) {
+    ::envoy::type::matcher::v3::RegexMatcher matcher;
+    matcher.mutable_google_re2();
+    matcher.set_regex(config_.regex_rewrite().pattern());
+    regex_rewrite_pattern_ = Envoy::Regex::Utility::parseRegex(matcher);
+
+    const std::string& path = config_.regex_rewrite().path_prefix();
+    if (path.size() > 0 && path[path.size() - 1] == '/') {
+      ENVOY_LOG(warn, "Remove last slash of regex_rewrite.path_prefix: {}",
+                path);
+      config_.mutable_regex_rewrite()->set_path_prefix(
+          path.substr(0, path.size() - 1));
+    }
   } else {
     // even "/" should be removed
     const std::string& path = config_.path_prefix();
@@ -71,6 +102,12 @@ bool ConfigParserImpl::rewrite(absl::string_view origin_path,
   if (config_.has_constant_path()) {
     return constPath(std::string(origin_path), new_path);
   }
+  if (config_.has_strip_prefix()) {
+    return stripPrefix(origin_path, new_path);
+  }
+  if (config_.has_regex_rewrite()) {
+    return regexRewrite(origin_path, new_path);
+  }
 
   new_path = absl::StrCat(config_.path_prefix(), origin_path);
   ENVOY_LOG(debug, "Use path prefix: new path: {}", new_path);
@@ -142,6 +179,56 @@ bool ConfigParserImpl::constPath(const std::string& origin_path,
   return true;
 }
 
+bool ConfigParserImpl::stripPrefix(absl::string_view origin_path,
+                                   std::string& new_path) const {
+  const auto& strip_cfg = config_.strip_prefix();
+  const std::string& prefix = strip_cfg.prefix();
+
+  // Only strip the prefix on a segment boundary.
+  if (!absl::StartsWith(origin_path, prefix) ||
+      (origin_path.size() > prefix.size() &&
+       origin_path[prefix.size()] != '/' &&
+       origin_path[prefix.size()] != '?')) {
+    ENVOY_LOG(debug, "Request path: {} doesn't start with prefix: {}",
+              origin_path, prefix);
+    new_path = std::string(origin_path);
+    return true;
+  }
+
+  absl::string_view remaining = origin_path.substr(prefix.size());
+  if (remaining.empty() || remaining[0] == '?') {
+    new_path = absl::StrCat(strip_cfg.path_prefix(), "/", remaining);
+  } else {
+    new_path = absl::StrCat(strip_cfg.path_prefix(), remaining);
+  }
+  ENVOY_LOG(debug, "Use strip prefix: new path: {}", new_path);
+  return true;
+}
+
+bool ConfigParserImpl::regexRewrite(absl::string_view origin_path,
+                                    std::string& new_path) const {
+  const auto& regex_cfg = config_.regex_rewrite();
+
+  absl::string_view path = origin_path;
+  absl::string_view query;
+  const std::size_t query_pos = origin_path.find('?');
+  if (query_pos != absl::string_view::npos) {
+    path = origin_path.substr(0, query_pos);
+    query = origin_path.substr(query_pos + 1);
+  }
+
+  new_path = absl::StrCat(
+      regex_cfg.path_prefix(),
+      regex_rewrite_pattern_->replaceAll(path, regex_cfg.substitution()));
+  if (!query.empty()) {
+    // The substitution may have added query parameters already.
+    const char* separator = new_path.find('?') == std::string::npos ? "?" : "&";
+    absl::StrAppend(&new_path, separator, query);
+  }
+  ENVOY_LOG(debug, "Use regex rewrite: new path: {}", new_path);
+  return true;
+}
+
 }  // namespace path_rewrite
 }  // namespace http_filters
 }  // namespace envoy
@@ -15,6 +15,7 @@
 
 #include "api/envoy/v10/http/path_rewrite/config.pb.h"
 #include "api/envoy/v10/http/path_rewrite/config.pb.validate.h"
+#include "envoy/common/regex.h"
 #include "source/common/common/logger.h"
 #include "src/api_proxy/path_matcher/path_matcher.h"
 #include "src/envoy/http/path_rewrite/config_parser.h"
@@ -40,6 +41,10 @@ class ConfigParserImpl
  private:
   // rewrite const path.
   bool constPath(const std::string& origin_path, std::string& new_path) const;
+  // rewrite by removing a path prefix.
+  bool stripPrefix(absl::string_view origin_path, std::string& new_path) const;
+  // rewrite with a regex.
+  bool regexRewrite(absl::string_view origin_path, std::string& new_path) const;
   // extract query parameters from variable bindings
   bool getVariableBindings(const std::string& origin_path,
                            std::string& query) const;
@@ -50,6 +55,8 @@ class ConfigParserImpl
   ::espv2::api_proxy::path_matcher::PathMatcherPtr<
       const ::espv2::api::envoy::v10::http::path_rewrite::PerRouteFilterConfig*>
       path_matcher_;
+  // compiled pattern of the regex rewrite.
+  Envoy::Regex::CompiledMatcherPtr regex_rewrite_pattern_;
 };
 
 }  // namespace path_rewrite
@@ -105,6 +105,27 @@ TEST_F(ConfigParserImplTest, ValidateConstPathWithFragment) {
                           "Proto constraint validation failed");
 }
 
+TEST_F(ConfigParserImplTest, ValidateStripPrefixWithRoot) {
+  EXPECT_THROW_WITH_REGEX(validateConfig(R"(
+    strip_prefix: {
+      prefix: "/"
+    }
+  )"),
+                          Envoy::ProtoValidationException,
+                          "Proto constraint validation failed");
+}
+
+TEST_F(ConfigParserImplTest, ValidateStripPrefixWithQuestionMark) {
+  EXPECT_THROW_WITH_REGEX(validateConfig(R"(
+    strip_prefix: {
+      prefix: "/api"
+      path_prefix: "/foo?a=1"
+    }
+  )"),
+                          Envoy::ProtoValidationException,
+                          "Proto constraint validation failed");
+}
+
 TEST_F(ConfigParserImplTest, PathPrefixBasic) {
   setUp(R"(
   path_prefix: "/foo"
@@ -210,6 +231,126 @@ TEST_F(ConfigParserImplTest, ConstantPathNoUrlTemplateRoot) {
   EXPECT_EQ(new_path_, "/?xyz=123");
 }
 
+TEST_F(ConfigParserImplTest, StripPrefixBasic) {
+  setUp(R"(
+  strip_prefix: {
+    prefix: "/api/v1"
+  }
+)");
+
+  // /api/v1/books => /books
+  EXPECT_TRUE(obj_->rewrite("/api/v1/books", new_path_));
+  EXPECT_EQ(new_path_, "/books");
+
+  // /api/v1/books?xyz=123 => /books?xyz=123
+  EXPECT_TRUE(obj_->rewrite("/api/v1/books?xyz=123", new_path_));
+  EXPECT_EQ(new_path_, "/books?xyz=123");
+
+  // /api/v1 => /
+  EXPECT_TRUE(obj_->rewrite("/api/v1", new_path_));
+  EXPECT_EQ(new_path_, "/");
+
+  // /api/v1?xyz=123 => /?xyz=123
+  EXPECT_TRUE(obj_->rewrite("/api/v1?xyz=123", new_path_));
+  EXPECT_EQ(new_path_, "/?xyz=123");
+}
+
+TEST_F(ConfigParserImplTest, StripPrefixNotMatched) {
+  setUp(R"(
+  strip_prefix: {
+    prefix: "/api/v1"
+  }
+)");
+
+  // Not on a segment boundary, unchanged.
+  EXPECT_TRUE(obj_->rewrite("/api/v10/books", new_path_));
+  EXPECT_EQ(new_path_, "/api/v10/books");
+
+  // Different prefix, unchanged.
+  EXPECT_TRUE(obj_->rewrite("/books?xyz=123", new_path_));
+  EXPECT_EQ(new_path_, "/books?xyz=123");
+}
+
+TEST_F(ConfigParserImplTest, StripPrefixWithPathPrefix) {
+  setUp(R"(
+  strip_prefix: {
+    prefix: "/api/v1/"
+    path_prefix: "/v2/"
+  }
+)");
+
+  // /api/v1/books => /v2/books
+  EXPECT_TRUE(obj_->rewrite("/api/v1/books", new_path_));
+  EXPECT_EQ(new_path_, "/v2/books");
+
+  // /api/v1?xyz=123 => /v2/?xyz=123
+  EXPECT_TRUE(obj_->rewrite("/api/v1?xyz=123", new_path_));
+  EXPECT_EQ(new_path_, "/v2/?xyz=123");
+}
+
+TEST_F(ConfigParserImplTest, ValidateRegexRewriteEmptySubstitution) {
+  EXPECT_THROW_WITH_REGEX(validateConfig(R"(
+    regex_rewrite: {
+      pattern: "^/v1/(.*)$"
+    }
+  )"),
+                          Envoy::ProtoValidationException,
+                          "Proto constraint validation failed");
+}
+
+TEST_F(ConfigParserImplTest, RegexRewriteBasic) {
+  setUp(R"(
+  regex_rewrite: {
+    pattern: "^/v1/users/([^/]+)/profile$"
+    substitution: "/profile/\\1"
+  }
+)");
+
+  // /v1/users/123/profile => /profile/123
+  EXPECT_TRUE(obj_->rewrite("/v1/users/123/profile", new_path_));
+  EXPECT_EQ(new_path_, "/profile/123");
+
+  // /v1/users/123/profile?key=abc => /profile/123?key=abc
+  EXPECT_TRUE(obj_->rewrite("/v1/users/123/profile?key=abc", new_path_));
+  EXPECT_EQ(new_path_, "/profile/123?key=abc");
+
+  // Not matched, unchanged.
+  EXPECT_TRUE(obj_->rewrite("/v1/books?key=abc", new_path_));
+  EXPECT_EQ(new_path_, "/v1/books?key=abc");
+}
+
+TEST_F(ConfigParserImplTest, RegexRewriteWithQueryInSubstitution) {
+  setUp(R"(
+  regex_rewrite: {
+    pattern: "^/v1/users/([^/]+)/profile$"
+    substitution: "/profile?user=\\1"
+  }
+)");
+
+  // /v1/users/123/profile => /profile?user=123
+  EXPECT_TRUE(obj_->rewrite("/v1/users/123/profile", new_path_));
+  EXPECT_EQ(new_path_, "/profile?user=123");
+
+  // The query parameters of the request are joined with '&'.
+  EXPECT_TRUE(obj_->rewrite("/v1/users/123/profile?key=abc&view=full",
+                            new_path_));
+  EXPECT_EQ(new_path_, "/profile?user=123&key=abc&view=full");
+}
+
+TEST_F(ConfigParserImplTest, RegexRewriteWithPathPrefix) {
+  setUp(R"(
+  regex_rewrite: {
+    pattern: "^/v1/users/([^/]+)/profile$"
+    substitution: "/profile?user=\\1"
+    path_prefix: "/api/"
+  }
+)");
+
+  // /v1/users/123/profile?key=abc => /api/profile?user=123&key=abc
+  EXPECT_TRUE(obj_->rewrite("/v1/users/123/profile?key=abc", new_path_));
+  EXPECT_EQ(new_path_, "/api/profile?user=123&key=abc");
+}
+
 }  // namespace path_rewrite
 }  // namespace http_filters
 }  // namespace envoy
@@ -14,6 +14,7 @@
 
 #include "src/envoy/http/service_control/client_cache.h"
 
+#include "absl/random/random.h"
 #include "source/common/tracing/http_tracer_impl.h"
 #include "src/api_proxy/service_control/check_response_convert_utils.h"
 #include "src/api_proxy/service_control/request_builder.h"
@@ -79,6 +80,13 @@ constexpr uint32_t kAllocateQuotaDefaultNumberOfRetries = 1;
 // The default number of retries for report calls.
 constexpr uint32_t kReportDefaultNumberOfRetries = 5;
 
+// The default initial backoff between retries of report calls.
+constexpr uint32_t kReportDefaultRetryBackoffMs = 100;
+// The default maximum number of report calls in flight on each worker thread.
+constexpr uint32_t kReportDefaultMaxPendingCalls = 1000;
+// The default maximum random delay added to each periodic flush.
+constexpr uint32_t kReportDefaultFlushJitterMs = 0;
+
 // The default value for network_fail_open flag.
 constexpr bool kDefaultNetworkFailOpen = true;
 
@@ -109,28 +117,41 @@ ReportAggregationOptions getReportAggregationOptions() {
                                   kReportAggregationFlushIntervalMs);
 }
 
-// A timer object to wrap PeriodicTimer
+// A timer object to wrap PeriodicTimer. A random delay of up to jitter_ms is
+// added to each interval.
 class EnvoyPeriodicTimer
     : public ::google::service_control_client::PeriodicTimer {
  public:
   EnvoyPeriodicTimer(Envoy::Event::Dispatcher& dispatcher, int interval_ms,
-                     std::function<void()> callback)
+                     uint32_t jitter_ms, std::function<void()> callback)
       : interval_ms_(interval_ms),
+        jitter_ms_(jitter_ms),
         callback_(callback),
         timer_(dispatcher.createTimer([this]() { call(); })) {
-    timer_->enableTimer(std::chrono::milliseconds(interval_ms_));
+    timer_->enableTimer(nextInterval());
   }
 
   void call() {
     callback_();
-    timer_->enableTimer(std::chrono::milliseconds(interval_ms_));
+    timer_->enableTimer(nextInterval());
   }
 
   // Cancels the timer.
   virtual void Stop() override { timer_.reset(); }
 
  private:
+  std::chrono::milliseconds nextInterval() {
+    if (jitter_ms_ == 0) {
+      return std::chrono::milliseconds(interval_ms_);
+    }
+    return std::chrono::milliseconds(
+        interval_ms_ + absl::Uniform<uint32_t>(absl::IntervalClosedClosed,
+                                               bitgen_, 0, jitter_ms_));
+  }
+
   int interval_ms_;
+  uint32_t jitter_ms_;
+  absl::BitGen bitgen_;
   std::function<void()> callback_;
   Envoy::Event::TimerPtr timer_;
 };
@@ -174,6 +195,9 @@ void ClientCache::initHttpRequestSetting(const FilterConfig& filter_config) {
     check_retries_ = kCheckDefaultNumberOfRetries;
     quota_retries_ = kAllocateQuotaDefaultNumberOfRetries;
     report_retries_ = kReportDefaultNumberOfRetries;
+    report_retry_backoff_ms_ = kReportDefaultRetryBackoffMs;
+    max_pending_reports_ = kReportDefaultMaxPendingCalls;
+    report_flush_jitter_ms_ = kReportDefaultFlushJitterMs;
     return;
   }
   const auto& sc_calling_config = filter_config.sc_calling_config();
@@ -199,6 +223,18 @@ void ClientCache::initHttpRequestSetting(const FilterConfig& filter_config) {
   report_retries_ = sc_calling_config.has_report_retries()
                         ? sc_calling_config.report_retries().value()
                         : kReportDefaultNumberOfRetries;
+
+  report_retry_backoff_ms_ =
+      sc_calling_config.has_report_retry_backoff_ms()
+          ? sc_calling_config.report_retry_backoff_ms().value()
+          : kReportDefaultRetryBackoffMs;
+  max_pending_reports_ = sc_calling_config.has_max_pending_reports()
+                             ? sc_calling_config.max_pending_reports().value()
+                             : kReportDefaultMaxPendingCalls;
+  report_flush_jitter_ms_ =
+      sc_calling_config.has_report_flush_jitter_ms()
+          ? sc_calling_config.report_flush_jitter_ms().value()
+          : kReportDefaultFlushJitterMs;
 }
 
 void ClientCache::collectCallStatus(CallStatusStats& call_stats,
@@ -215,6 +251,7 @@ ClientCache::ClientCache(
     std::function<const std::string&()> quota_token_fn)
     : config_(config),
       filter_stats_(ServiceControlFilterStats::create(stats_prefix, scope)),
+      pending_reports_(0),
       time_source_(time_source) {
   ServiceControlClientOptions options(getCheckAggregationOptions(),
                                       getQuotaAggregationOptions(),
@@ -235,7 +272,7 @@ ClientCache::ClientCache(
       cm, dispatcher, filter_config.service_control_uri(),
       absl::StrCat("/", config_.service_name(), ":report"), sc_token_fn,
       report_timeout_ms_, report_retries_, time_source,
-      "Service Control remote call: Report");
+      "Service Control remote call: Report", report_retry_backoff_ms_);
 
   // Note: Check transport is also defined per request.
   // But this must be defined, it will be called on each flush of the cache
@@ -278,12 +315,24 @@ ClientCache::ClientCache(
   options.report_transport = [this](const ReportRequest& request,
                                     ReportResponse* response,
                                     TransportDoneFunc on_done) {
+    // Bound the reports held in memory while the calls are retried.
+    if (pending_reports_ >= max_pending_reports_) {
+      ENVOY_LOG(warn, "Dropping report, {} report calls are already pending",
+                pending_reports_);
+      collectCallStatus(filter_stats_.report_, StatusCode::kResourceExhausted);
+      on_done(Status(StatusCode::kResourceExhausted,
+                     "Too many pending report calls"));
+      return;
+    }
+
     // Don't support tracing on this transport
     auto& null_span = Envoy::Tracing::NullSpan::instance();
+    pending_reports_++;
     auto* call = report_call_factory_->createHttpCall(
         request, null_span,
         [this, response, on_done](const Status& status,
                                   const std::string& body) {
+          pending_reports_--;
           Status final_status = processScCallTransportStatus<ReportResponse>(
               status, response, body);
           collectCallStatus(filter_stats_.report_, final_status.code());
@@ -293,11 +342,12 @@ ClientCache::ClientCache(
     call->call();
   };
 
-  options.periodic_timer = [&dispatcher](int interval_ms,
-                                         std::function<void()> callback)
+  options.periodic_timer = [this, &dispatcher](int interval_ms,
+                                               std::function<void()> callback)
       -> std::unique_ptr<::google::service_control_client::PeriodicTimer> {
     return std::unique_ptr<::google::service_control_client::PeriodicTimer>(
-        new EnvoyPeriodicTimer(dispatcher, interval_ms, callback));
+        new EnvoyPeriodicTimer(dispatcher, interval_ms, report_flush_jitter_ms_,
+                               callback));
   };
 
   client_ = ::google::service_control_client::CreateServiceControlClient(
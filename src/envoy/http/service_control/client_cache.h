@@ -118,6 +118,16 @@ class ClientCache : public Envoy::Logger::Loggable<Envoy::Logger::Id::filter> {
   uint32_t report_retries_;
   uint32_t quota_retries_;
 
+  // the backoff between report retries
+  uint32_t report_retry_backoff_ms_;
+
+  // the bound and current number of report calls in flight
+  uint32_t max_pending_reports_;
+  uint32_t pending_reports_;
+
+  // the maximum random delay added to each periodic flush
+  uint32_t report_flush_jitter_ms_;
+
   // Used to retrieve the current time for tracing.
   Envoy::TimeSource& time_source_;
 
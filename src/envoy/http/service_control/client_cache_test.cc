@@ -54,6 +54,7 @@ using ::google::protobuf::util::StatusCode;
 
 using ::testing::_;
 using ::testing::InSequence;
+using ::testing::Invoke;
 using ::testing::NiceMock;
 using ::testing::Return;
 
@@ -318,6 +319,43 @@ TEST_F(ClientCacheQuotaResponseErrorTypeTest, ApiKeyInvalid) {
   checkAndReset(stats_.filter_.denied_consumer_error_, 1);
 }
 
+class ClientCacheFlushJitterTest : public ClientCacheTestBase {
+ public:
+  void SetUp() override {}
+};
+
+// The periodic flush is delayed by a random jitter, so the intervals between
+// flushes vary within the configured jitter.
+TEST_F(ClientCacheFlushJitterTest, FlushIntervalsAreJittered) {
+  filter_config_.mutable_sc_calling_config()
+      ->mutable_report_flush_jitter_ms()
+      ->set_value(500);
+
+  auto* timer = new NiceMock<Envoy::Event::MockTimer>(&dispatcher_);
+  std::vector<int64_t> intervals_ms;
+  EXPECT_CALL(*timer, enableTimer(_, _))
+      .WillRepeatedly(Invoke([timer, &intervals_ms](
+                                 std::chrono::milliseconds ms,
+                                 const Envoy::ScopeTrackedObject*) {
+        timer->enabled_ = true;
+        intervals_ms.push_back(ms.count());
+      }));
+
+  cache_ = std::make_unique<ClientCache>(
+      service_config_, filter_config_, "test", context_.scope_, cm_,
+      time_source_, dispatcher_, token_fn_, token_fn_);
+  for (int i = 0; i < 20; i++) {
+    timer->invokeCallback();
+  }
+  cache_.reset(nullptr);
+
+  ASSERT_EQ(intervals_ms.size(), 21u);
+  const auto [min_ms, max_ms] =
+      std::minmax_element(intervals_ms.begin(), intervals_ms.end());
+  EXPECT_LE(*max_ms - *min_ms, 500);
+  EXPECT_LT(*min_ms, *max_ms);
+}
+
 class ClientCacheHttpRequestTest : public ClientCacheTestBase {
  public:
   void SetUp() override {
@@ -66,7 +66,10 @@ FilterConfigParser::FilterConfigParser(const FilterConfig& config,
 
   // Construct a requirement for non matched requests
   non_match_rqm_cfg_.set_service_name(first_srv_ctx->config().service_name());
-  non_match_rqm_cfg_.set_operation_name(kUnrecognizedOperation);
+  non_match_rqm_cfg_.set_operation_name(
+      config_.unmatched_operation_name().empty()
+          ? kUnrecognizedOperation
+          : config_.unmatched_operation_name());
   non_match_rqm_ctx_.reset(
       new RequirementContext(non_match_rqm_cfg_, *first_srv_ctx));
 
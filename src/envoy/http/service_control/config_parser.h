@@ -156,12 +156,15 @@ class PerRouteFilterConfig : public Envoy::Router::RouteSpecificFilterConfig {
  public:
   PerRouteFilterConfig(const ::espv2::api::envoy::v10::http::service_control::
                            PerRouteFilterConfig& per_route)
-      : operation_name_(per_route.operation_name()) {}
+      : operation_name_(per_route.operation_name()),
+        api_version_(per_route.api_version()) {}
 
   absl::string_view operation_name() const { return operation_name_; }
+  absl::string_view api_version() const { return api_version_; }
 
  private:
   std::string operation_name_;
+  std::string api_version_;
 };
 
 using PerRouteFilterConfigSharedPtr = std::shared_ptr<PerRouteFilterConfig>;
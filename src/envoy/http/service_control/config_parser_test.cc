@@ -73,6 +73,24 @@ requirements {
             "echo111");
 
   EXPECT_FALSE(parser.find_requirement("non-existing-operation"));
+  EXPECT_EQ(parser.non_match_rqm_ctx()->config().operation_name(),
+            "<Unknown Operation Name>");
+}
+
+TEST(ConfigParserTest, UnmatchedOperationName) {
+  FilterConfig config;
+  const char kFilterConfig[] = R"(
+services {
+  service_name: "echo"
+}
+unmatched_operation_name: "<unregistered>")";
+  ASSERT_TRUE(TextFormat::ParseFromString(kFilterConfig, &config));
+  testing::NiceMock<MockServiceControlCallFactory> mock_factory;
+  FilterConfigParser parser(config, mock_factory);
+
+  EXPECT_EQ(parser.non_match_rqm_ctx()->config().operation_name(),
+            "<unregistered>");
+  EXPECT_EQ(parser.non_match_rqm_ctx()->config().service_name(), "echo");
 }
 
 TEST(ConfigParserTest, DuplicatedServiceNames) {
@@ -18,7 +18,9 @@
 
 #include "envoy/http/header_map.h"
 #include "source/common/grpc/status.h"
+#include "src/envoy/http/service_control/config_parser.h"
 #include "src/envoy/http/service_control/handler.h"
+#include "src/envoy/http/service_control/handler_utils.h"
 #include "src/envoy/utils/http_header_utils.h"
 #include "src/envoy/utils/rc_detail_utils.h"
 
@@ -60,6 +62,15 @@ Envoy::Http::FilterHeadersStatus ServiceControlFilter::decodeHeaders(
     decoder_callbacks_->clearRouteCache();
   }
 
+  // Routes may match on the claim headers, so recalculate the route if they
+  // were changed from the ones the route was selected with.
+  if (config_.has_jwt_claim_headers() &&
+      fillJwtClaimHeaders(decoder_callbacks_->streamInfo().dynamicMetadata(),
+                          config_.jwt_claim_headers(), headers)) {
+    ENVOY_LOG(debug, "JWT claim headers changed, recalculating route");
+    decoder_callbacks_->clearRouteCache();
+  }
+
   // Make sure route is calculated
   auto route = decoder_callbacks_->route();
 
@@ -69,6 +80,18 @@ Envoy::Http::FilterHeadersStatus ServiceControlFilter::decodeHeaders(
     return Envoy::Http::FilterHeadersStatus::Continue;
   }
 
+  // Operations skipping service control, e.g. static responses, are neither
+  // checked nor reported.
+  const auto* per_route =
+      route->perFilterConfigTyped<PerRouteFilterConfig>(kFilterName);
+  if (per_route != nullptr &&
+      factory_.skipServiceControl(per_route->operation_name())) {
+    ENVOY_LOG(debug, "Operation {} skips service control",
+              per_route->operation_name());
+    skipped_ = true;
+    return Envoy::Http::FilterHeadersStatus::Continue;
+  }
+
   handler_ =
       factory_.createHandler(headers, decoder_callbacks_->streamInfo(), stats_);
   handler_->fillFilterState(*decoder_callbacks_->streamInfo().filterState());
@@ -146,6 +169,9 @@ void ServiceControlFilter::log(
     const Envoy::Http::ResponseTrailerMap* response_trailers,
     const Envoy::StreamInfo::StreamInfo& stream_info) {
   ENVOY_LOG(debug, "Called ServiceControl Filter : {}", __func__);
+  if (skipped_) {
+    return;
+  }
   if (!handler_) {
     if (!request_headers) return;
     handler_ = factory_.createHandler(*request_headers, stream_info, stats_);
@@ -21,6 +21,7 @@
 #include "envoy/http/header_map.h"
 #include "source/common/common/logger.h"
 #include "source/extensions/filters/http/common/pass_through_filter.h"
+#include "api/envoy/v10/http/service_control/config.pb.h"
 #include "src/envoy/http/service_control/filter_stats.h"
 #include "src/envoy/http/service_control/handler.h"
 
@@ -36,9 +37,12 @@ class ServiceControlFilter
       public ServiceControlHandler::CheckDoneCallback,
       public Envoy::Logger::Loggable<Envoy::Logger::Id::filter> {
  public:
-  ServiceControlFilter(ServiceControlFilterStats& stats,
-                       const ServiceControlHandlerFactory& factory)
-      : stats_(stats), factory_(factory) {}
+  ServiceControlFilter(
+      ServiceControlFilterStats& stats,
+      const ServiceControlHandlerFactory& factory,
+      const ::espv2::api::envoy::v10::http::service_control::FilterConfig&
+          config)
+      : stats_(stats), factory_(factory), config_(config) {}
 
   void onDestroy() override;
 
@@ -66,6 +70,7 @@ class ServiceControlFilter
 
   ServiceControlFilterStats& stats_;
   const ServiceControlHandlerFactory& factory_;
+  const ::espv2::api::envoy::v10::http::service_control::FilterConfig& config_;
 
   // The service control request handler
   std::unique_ptr<ServiceControlHandler> handler_;
@@ -75,6 +80,8 @@ class ServiceControlFilter
   State state_ = Init;
   // Mark if request has been stopped.
   bool stopped_ = false;
+  // Mark if service control is skipped for the operation.
+  bool skipped_ = false;
 };
 
 }  // namespace service_control
@@ -46,7 +46,12 @@ class ServiceControlFilterConfig
         call_factory_(proto_config_, stats_prefix, context),
         config_parser_(*proto_config_, call_factory_),
         handler_factory_(context.api().randomGenerator(), config_parser_,
-                         context.timeSource()) {}
+                         context.timeSource()) {
+    if (proto_config_->enable_consumer_stats()) {
+      filter_stats_.consumer_ =
+          std::make_unique<ConsumerStats>(stats_prefix, context.scope());
+    }
+  }
 
   const ServiceControlHandlerFactory& handler_factory() const {
     return handler_factory_;
@@ -54,6 +59,11 @@ class ServiceControlFilterConfig
 
   ServiceControlFilterStats& stats() { return filter_stats_; }
 
+  const ::espv2::api::envoy::v10::http::service_control::FilterConfig& config()
+      const {
+    return *proto_config_;
+  }
+
  private:
   ServiceControlFilterStats filter_stats_;
   FilterConfigProtoSharedPtr proto_config_;
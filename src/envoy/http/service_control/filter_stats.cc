@@ -15,6 +15,7 @@
 #include "src/envoy/http/service_control/filter_stats.h"
 
 #include "google/protobuf/stubs/status.h"
+#include "source/common/stats/utility.h"
 
 using ::google::protobuf::util::Status;
 using ::google::protobuf::util::StatusCode;
@@ -85,6 +86,29 @@ void ServiceControlFilterStats::collectCallStatus(CallStatusStats& stats,
   }
 }
 
+ConsumerStats::ConsumerStats(const std::string& prefix,
+                             Envoy::Stats::Scope& scope)
+    : scope_(scope),
+      pool_(scope.symbolTable()),
+      prefix_(pool_.add(prefix + "service_control.consumer")),
+      rq_total_(pool_.add("rq_total")),
+      request_time_(pool_.add("request_time")) {}
+
+void ConsumerStats::recordRequest(absl::string_view consumer_number,
+                                  int64_t request_time_ms) {
+  Envoy::Stats::Utility::counterFromElements(
+      scope_,
+      {prefix_, Envoy::Stats::DynamicName(consumer_number), rq_total_})
+      .inc();
+  if (request_time_ms >= 0) {
+    Envoy::Stats::Utility::histogramFromElements(
+        scope_,
+        {prefix_, Envoy::Stats::DynamicName(consumer_number), request_time_},
+        Envoy::Stats::Histogram::Unit::Milliseconds)
+        .recordValue(request_time_ms);
+  }
+}
+
 }  // namespace service_control
 }  // namespace http_filters
 }  // namespace envoy
@@ -17,6 +17,7 @@
 #include "envoy/stats/scope.h"
 #include "envoy/stats/stats_macros.h"
 #include "google/protobuf/stubs/status.h"
+#include "source/common/stats/symbol_table_impl.h"
 
 namespace espv2 {
 namespace envoy {
@@ -80,6 +81,29 @@ struct CallStatusStats {
   CALL_STATUS_STATS(GENERATE_COUNTER_STRUCT);
 };
 
+/**
+ * Per-consumer stats, named by the consumer project number from the check
+ * response, e.g. "service_control.consumer.123456.rq_total".
+ */
+class ConsumerStats {
+ public:
+  ConsumerStats(const std::string& prefix, Envoy::Stats::Scope& scope);
+
+  // Record a request of the consumer. The request time is only recorded if it
+  // is known, i.e. not negative.
+  void recordRequest(absl::string_view consumer_number,
+                     int64_t request_time_ms);
+
+ private:
+  Envoy::Stats::Scope& scope_;
+  Envoy::Stats::StatNamePool pool_;
+  const Envoy::Stats::StatName prefix_;
+  const Envoy::Stats::StatName rq_total_;
+  const Envoy::Stats::StatName request_time_;
+};
+
+using ConsumerStatsPtr = std::unique_ptr<ConsumerStats>;
+
 /**
  * Wrapper struct for all the stats structs of service control filter .
  */
@@ -92,6 +116,8 @@ struct ServiceControlFilterStats {
   CallStatusStats allocate_quota_;
   // The stats of service control report call status.
   CallStatusStats report_;
+  // The per-consumer stats, only set if they are enabled.
+  ConsumerStatsPtr consumer_;
 
   // Collect service control call status.
   static void collectCallStatus(
@@ -110,7 +136,8 @@ struct ServiceControlFilterStats {
             {CALL_STATUS_STATS(
                 POOL_COUNTER_PREFIX(scope, final_prefix + "allocate_quota."))},
             {CALL_STATUS_STATS(
-                POOL_COUNTER_PREFIX(scope, final_prefix + "report."))}};
+                POOL_COUNTER_PREFIX(scope, final_prefix + "report."))},
+            nullptr};
   }
 };
 
@@ -14,6 +14,7 @@
 
 #include "src/envoy/http/service_control/filter_stats.h"
 
+#include "source/common/stats/isolated_store_impl.h"
 #include "test/mocks/server/mocks.h"
 #include "test/test_common/utility.h"
 
@@ -89,6 +90,24 @@ TEST_F(FilterStatsTest, CollectCallStatus) {
   runTest(mappings, ServiceControlFilterStats::collectCallStatus);
 }
 
+TEST(ConsumerStatsTest, RecordRequest) {
+  Envoy::Stats::IsolatedStoreImpl store;
+  ConsumerStats stats("prefix.", store);
+
+  stats.recordRequest("123456", 10);
+  stats.recordRequest("123456", -1);
+  stats.recordRequest("654321", 20);
+
+  EXPECT_EQ(
+      store.counterFromString("prefix.service_control.consumer.123456.rq_total")
+          .value(),
+      2);
+  EXPECT_EQ(
+      store.counterFromString("prefix.service_control.consumer.654321.rq_total")
+          .value(),
+      1);
+}
+
 }  // namespace
 }  // namespace service_control
 }  // namespace http_filters
@@ -53,8 +53,8 @@ class ServiceControlFilterTest : public ::testing::Test {
         req_headers_{{":method", "GET"}, {":path", "/bar"}} {}
 
   void SetUp() override {
-    filter_ =
-        std::make_unique<ServiceControlFilter>(stats_, mock_handler_factory_);
+    filter_ = std::make_unique<ServiceControlFilter>(
+        stats_, mock_handler_factory_, proto_config_);
     filter_->setDecoderFilterCallbacks(mock_decoder_callbacks_);
 
     mock_handler_ = new testing::NiceMock<MockServiceControlHandler>();
@@ -66,6 +66,7 @@ class ServiceControlFilterTest : public ::testing::Test {
     mock_span_ = std::make_unique<Envoy::Tracing::MockSpan>();
   }
 
+  ::espv2::api::envoy::v10::http::service_control::FilterConfig proto_config_;
   std::unique_ptr<ServiceControlFilter> filter_;
   testing::NiceMock<MockStreamDecoderFilterCallbacks> mock_decoder_callbacks_;
   testing::NiceMock<MockFactoryContext> mock_factory_context_;
@@ -129,6 +130,79 @@ TEST_F(ServiceControlFilterTest, DecodeHeadersSyncOKStatus) {
   filter_->onDestroy();
 }
 
+TEST_F(ServiceControlFilterTest, DecodeHeadersSetsJwtClaimHeaders) {
+  // Test: claim headers are set from the jwt payload and the route is
+  // recalculated.
+  ASSERT_TRUE(google::protobuf::TextFormat::ParseFromString(R"(
+jwt_claim_headers {
+  jwt_payload_metadata_name: "jwt_payloads"
+  claim_headers { key: "tier" value: "x-jwt-claim-tier" }
+}
+)",
+                                                            &proto_config_));
+  ASSERT_TRUE(google::protobuf::TextFormat::ParseFromString(
+      R"(
+filter_metadata {
+  key: "envoy.filters.http.jwt_authn"
+  value {
+    fields {
+      key: "jwt_payloads"
+      value {
+        struct_value {
+          fields { key: "tier" value { string_value: "enterprise" } }
+        }
+      }
+    }
+  }
+}
+)",
+      &mock_decoder_callbacks_.stream_info_.metadata_));
+
+  req_headers_.addCopy("x-jwt-claim-tier", "free");
+  EXPECT_CALL(mock_decoder_callbacks_, clearRouteCache()).Times(1);
+  EXPECT_CALL(*mock_handler_, callCheck(_, _, _))
+      .WillOnce(Invoke([](Envoy::Http::RequestHeaderMap&, Envoy::Tracing::Span&,
+                          ServiceControlHandler::CheckDoneCallback& callback) {
+        callback.onCheckDone(OkStatus(), "");
+      }));
+  EXPECT_EQ(Envoy::Http::FilterHeadersStatus::Continue,
+            filter_->decodeHeaders(req_headers_, true));
+  EXPECT_EQ(req_headers_.get_("x-jwt-claim-tier"), "enterprise");
+}
+
+TEST_F(ServiceControlFilterTest, DecodeHeadersWithoutJwtClaims) {
+  // Test: the route is not recalculated if no claim headers are changed.
+  ASSERT_TRUE(google::protobuf::TextFormat::ParseFromString(R"(
+jwt_claim_headers {
+  jwt_payload_metadata_name: "jwt_payloads"
+  claim_headers { key: "tier" value: "x-jwt-claim-tier" }
+}
+)",
+                                                            &proto_config_));
+
+  EXPECT_CALL(mock_decoder_callbacks_, clearRouteCache()).Times(0);
+  EXPECT_CALL(*mock_handler_, callCheck(_, _, _))
+      .WillOnce(Invoke([](Envoy::Http::RequestHeaderMap&, Envoy::Tracing::Span&,
+                          ServiceControlHandler::CheckDoneCallback& callback) {
+        callback.onCheckDone(OkStatus(), "");
+      }));
+  EXPECT_EQ(Envoy::Http::FilterHeadersStatus::Continue,
+            filter_->decodeHeaders(req_headers_, true));
+  EXPECT_FALSE(req_headers_.has("x-jwt-claim-tier"));
+}
+
+TEST_F(ServiceControlFilterTest, DecodeHeadersWithoutJwtClaimHeaders) {
+  // Test: the route is not recalculated if no claim headers are configured.
+  EXPECT_CALL(mock_decoder_callbacks_, clearRouteCache()).Times(0);
+  EXPECT_CALL(*mock_handler_, callCheck(_, _, _))
+      .WillOnce(Invoke([](Envoy::Http::RequestHeaderMap&, Envoy::Tracing::Span&,
+                          ServiceControlHandler::CheckDoneCallback& callback) {
+        callback.onCheckDone(OkStatus(), "");
+      }));
+  EXPECT_EQ(Envoy::Http::FilterHeadersStatus::Continue,
+            filter_->decodeHeaders(req_headers_, true));
+}
+
 TEST_F(ServiceControlFilterTest, OnDestoryWithoutHandler) {
   // Test: calling filter::onDestroy() without handler
   EXPECT_CALL(mock_handler_factory_, createHandler(_, _, _)).Times(0);
@@ -215,6 +289,47 @@ TEST_F(ServiceControlFilterTest, DecodeHeadersAsyncBadStatus) {
       kBadStatus, "service_control_check_error{API_KEY_INVALID}");
 }
 
+TEST_F(ServiceControlFilterTest, SkippedOperationSkipsServiceControl) {
+  // Test: An operation skipping service control neither checks nor reports
+  ::espv2::api::envoy::v10::http::service_control::PerRouteFilterConfig
+      per_route_proto;
+  per_route_proto.set_operation_name("test-operation");
+  PerRouteFilterConfig per_route(per_route_proto);
+  ON_CALL(*mock_route_, routeEntry()).WillByDefault(Return(nullptr));
+  ON_CALL(*mock_route_, perFilterConfig(kFilterName))
+      .WillByDefault(Return(&per_route));
+  ON_CALL(mock_decoder_callbacks_, route())
+      .WillByDefault(Return(mock_route_));
+  ON_CALL(mock_handler_factory_, skipServiceControl("test-operation"))
+      .WillByDefault(Return(true));
+
+  EXPECT_CALL(mock_handler_factory_, createHandler(_, _, _)).Times(0);
+  EXPECT_EQ(Envoy::Http::FilterHeadersStatus::Continue,
+            filter_->decodeHeaders(req_headers_, true));
+  filter_->log(&req_headers_, &resp_headers_, &resp_trailer_,
+               mock_decoder_callbacks_.stream_info_);
+}
+
+TEST_F(ServiceControlFilterTest, DirectResponseCallsServiceControl) {
+  // Test: A direct response whose operation doesn't skip service control is
+  // checked
+  ::espv2::api::envoy::v10::http::service_control::PerRouteFilterConfig
+      per_route_proto;
+  per_route_proto.set_operation_name("test-operation");
+  PerRouteFilterConfig per_route(per_route_proto);
+  ON_CALL(*mock_route_, routeEntry()).WillByDefault(Return(nullptr));
+  ON_CALL(*mock_route_, perFilterConfig(kFilterName))
+      .WillByDefault(Return(&per_route));
+  ON_CALL(mock_decoder_callbacks_, route())
+      .WillByDefault(Return(mock_route_));
+  ON_CALL(mock_handler_factory_, skipServiceControl("test-operation"))
+      .WillByDefault(Return(false));
+
+  EXPECT_CALL(mock_handler_factory_, createHandler(_, _, _)).Times(1);
+  EXPECT_CALL(*mock_handler_, callCheck(_, _, _));
+  filter_->decodeHeaders(req_headers_, true);
+}
+
 TEST_F(ServiceControlFilterTest, LogWithoutHandlerOrHeaders) {
   // Test: If no handler and no headers, a handler is not created
   EXPECT_CALL(mock_handler_factory_, createHandler(_, _, _)).Times(0);
@@ -14,6 +14,7 @@
 
 #pragma once
 
+#include "absl/strings/string_view.h"
 #include "envoy/buffer/buffer.h"
 #include "envoy/common/pure.h"
 #include "envoy/http/header_map.h"
@@ -68,6 +69,9 @@ class ServiceControlHandlerFactory {
       const Envoy::Http::RequestHeaderMap& headers,
       const Envoy::StreamInfo::StreamInfo& stream_info,
       ServiceControlFilterStats& filter_stats) const PURE;
+
+  // Returns true if the operation is configured to skip service control.
+  virtual bool skipServiceControl(absl::string_view operation) const PURE;
 };
 
 }  // namespace service_control
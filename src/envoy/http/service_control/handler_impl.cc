@@ -20,6 +20,7 @@
 #include "source/common/common/empty_string.h"
 #include "source/common/http/headers.h"
 #include "source/common/http/utility.h"
+#include "source/extensions/filters/http/well_known_names.h"
 #include "src/envoy/http/service_control/handler_utils.h"
 #include "src/envoy/utils/filter_state_utils.h"
 #include "src/envoy/utils/http_header_utils.h"
@@ -51,6 +52,10 @@ RegisterCustomInlineHeader<CustomInlineHeaderRegistry::Type::RequestHeaders>
 constexpr char kConsumerTypeHeaderSuffix[] = "api-consumer-type";
 constexpr char kConsumerNumberHeaderSuffix[] = "api-consumer-number";
 
+// The HTTP header suffix to forward the trusted JWT payload to backend. It is
+// the same header jwt_authn filter uses to forward the payload.
+constexpr char kJwtPayloadForwardHeaderSuffix[] = "API-UserInfo";
+
 // CheckRequest headers
 const Envoy::Http::LowerCaseString kIosBundleIdHeader{
     "x-ios-bundle-identifier"};
@@ -82,12 +87,14 @@ ServiceControlHandlerImpl::ServiceControlHandlerImpl(
   http_method_ = std::string(utils::readHeaderEntry(headers.Method()));
   path_ = std::string(utils::readHeaderEntry(headers.Path()));
 
-  const auto operation = getOperationFromPerRoute(stream_info_);
-  if (!operation.empty()) {
-    require_ctx_ = cfg_parser_.find_requirement(operation);
+  const PerRouteFilterConfig* per_route = getPerRouteConfig(stream_info_);
+  if (per_route != nullptr && !per_route->operation_name().empty()) {
+    ENVOY_LOG(debug, "get operation_name: {}", per_route->operation_name());
+    require_ctx_ = cfg_parser_.find_requirement(per_route->operation_name());
     if (!require_ctx_) {
       ENVOY_LOG(debug, "No requirement matched!");
     }
+    route_api_version_ = std::string(per_route->api_version());
   } else {
     ENVOY_LOG(debug, "No operation found");
   }
@@ -96,22 +103,47 @@ ServiceControlHandlerImpl::ServiceControlHandlerImpl(
     require_ctx_ = cfg_parser_.non_match_rqm_ctx();
   }
 
-  if (require_ctx_->config().api_key().locations_size() > 0) {
-    extractAPIKey(headers, require_ctx_->config().api_key().locations(),
-                  api_key_);
-  } else {
-    extractAPIKey(headers, cfg_parser_.default_api_keys().locations(),
-                  api_key_);
+  extractAPIKey(headers, apiKeyLocations(), api_key_);
+
+  if (isConfigured()) {
+    extractTrustedJwt(headers);
+  }
+}
+
+void ServiceControlHandlerImpl::extractTrustedJwt(
+    const Envoy::Http::RequestHeaderMap& headers) {
+  const auto& service = require_ctx_->service_ctx().config();
+  if (service.trusted_jwt_header().empty()) {
+    return;
+  }
+
+  const absl::string_view jwt = utils::extractHeader(
+      headers, Envoy::Http::LowerCaseString(service.trusted_jwt_header()));
+  if (jwt.empty()) {
+    return;
   }
+
+  Envoy::ProtobufWkt::Struct payload;
+  if (!decodeTrustedJwtPayload(jwt, trusted_jwt_payload_, payload)) {
+    ENVOY_LOG(debug, "Malformed JWT in trusted header {}, ignored.",
+              service.trusted_jwt_header());
+    return;
+  }
+
+  Envoy::ProtobufWkt::Struct& metadata =
+      (*trusted_jwt_metadata_.mutable_filter_metadata())
+          [Envoy::Extensions::HttpFilters::HttpFilterNames::get().JwtAuthn];
+  *(*metadata.mutable_fields())[service.jwt_payload_metadata_name()]
+       .mutable_struct_value() = std::move(payload);
 }
 
 ServiceControlHandlerImpl::~ServiceControlHandlerImpl() {}
 
-absl::string_view ServiceControlHandlerImpl::getOperationFromPerRoute(
+const PerRouteFilterConfig* ServiceControlHandlerImpl::getPerRouteConfig(
     const Envoy::StreamInfo::StreamInfo& stream_info) {
   if (stream_info_.routeEntry() == nullptr) {
     ENVOY_LOG(debug, "No route entry");
-    return Envoy::EMPTY_STRING;
+    return nullptr;
   }
 
   const auto* per_route =
@@ -119,10 +151,8 @@ absl::string_view ServiceControlHandlerImpl::getOperationFromPerRoute(
           kFilterName);
   if (per_route == nullptr) {
     ENVOY_LOG(debug, "no per-route config");
-    return Envoy::EMPTY_STRING;
   }
-  ENVOY_LOG(debug, "get operation_name: {}", per_route->operation_name());
-  return per_route->operation_name();
+  return per_route;
 }
 
 void ServiceControlHandlerImpl::fillFilterState(FilterState& filter_state) {
@@ -162,12 +192,18 @@ void ServiceControlHandlerImpl::fillOperationInfo(
 void ServiceControlHandlerImpl::prepareReportRequest(
     ::espv2::api_proxy::service_control::ReportRequestInfo& info) {
   fillOperationInfo(info);
+  // Only Report calls use the transformed name, Check and Quota calls keep the
+  // selector that the service config declares.
+  info.operation_name = reportedOperationName();
 
   info.url = path_;
   info.method = http_method_;
-  info.api_method = require_ctx_->config().operation_name();
+  info.api_method = reportedOperationName();
   info.api_name = require_ctx_->config().api_name();
-  info.api_version = require_ctx_->config().api_version();
+  // The version parsed from the route path takes precedence.
+  info.api_version = route_api_version_.empty()
+                         ? require_ctx_->config().api_version()
+                         : route_api_version_;
   info.log_message = info.api_method + " is called";
 
   info.check_response_info = check_response_info_;
@@ -187,6 +223,18 @@ void ServiceControlHandlerImpl::callCheck(
   }
   check_callback_ = &callback;
 
+  if (!trusted_jwt_payload_.empty()) {
+    headers.setCopy(Envoy::Http::LowerCaseString(
+                        cfg_parser_.config().generated_header_prefix() +
+                        kJwtPayloadForwardHeaderSuffix),
+                    trusted_jwt_payload_);
+  }
+
+  if (cfg_parser_.config().remove_api_key()) {
+    // The API key is already extracted, the backend doesn't need it.
+    removeAPIKey(headers, apiKeyLocations());
+  }
+
   if (!isCheckRequired()) {
     callQuota();
     return;
@@ -317,19 +365,31 @@ void ServiceControlHandlerImpl::callReport(
   fillLoggedHeader(response_headers,
                    require_ctx_->service_ctx().config().log_response_headers(),
                    info.response_headers);
+  fillExtraLabels(request_headers,
+                  require_ctx_->service_ctx().config().extra_labels(),
+                  info.extra_labels);
+  // The payload of a trusted JWT takes the place of the jwt_authn metadata.
+  const auto& jwt_metadata = trusted_jwt_payload_.empty()
+                                 ? stream_info_.dynamicMetadata()
+                                 : trusted_jwt_metadata_;
   fillJwtPayloads(
-      stream_info_.dynamicMetadata(),
+      jwt_metadata,
       require_ctx_->service_ctx().config().jwt_payload_metadata_name(),
       require_ctx_->service_ctx().config().log_jwt_payloads(),
       info.jwt_payloads);
+  fillJwtClaimLabels(
+      jwt_metadata,
+      require_ctx_->service_ctx().config().jwt_payload_metadata_name(),
+      require_ctx_->service_ctx().config().jwt_claim_labels(),
+      info.extra_labels);
 
   fillJwtPayload(
-      stream_info_.dynamicMetadata(),
+      jwt_metadata,
       require_ctx_->service_ctx().config().jwt_payload_metadata_name(),
       JwtPayloadIssuerPath, info.auth_issuer);
 
   fillJwtPayload(
-      stream_info_.dynamicMetadata(),
+      jwt_metadata,
       require_ctx_->service_ctx().config().jwt_payload_metadata_name(),
       JwtPayloadAudiencePath, info.auth_audience);
 
@@ -340,10 +400,26 @@ void ServiceControlHandlerImpl::callReport(
   if (request_headers) {
     info.referer = std::string(utils::readHeaderEntry(
         request_headers->getInline(referer_handle.handle())));
+    info.user_agent =
+        std::string(utils::readHeaderEntry(request_headers->UserAgent()));
+    info.request_id =
+        std::string(utils::readHeaderEntry(request_headers->RequestId()));
   }
+  info.log_entry_fields.assign(
+      require_ctx_->service_ctx().config().log_entry_fields().begin(),
+      require_ctx_->service_ctx().config().log_entry_fields().end());
+  info.credential_id_prefix =
+      require_ctx_->service_ctx().config().credential_id_prefix();
 
   fillLatency(stream_info_, info.latency, filter_stats_);
+  if (filter_stats_.consumer_ &&
+      !check_response_info_.consumer_number.empty()) {
+    filter_stats_.consumer_->recordRequest(check_response_info_.consumer_number,
+                                           info.latency.request_time_ms);
+  }
   fillStatus(response_headers, response_trailers, stream_info_, info);
+  info.success_status_code = isSuccessStatusCode(
+      require_ctx_->service_ctx().config(), stream_info_, info);
 
   info.request_size = stream_info_.bytesReceived() + request_header_size_;
 
@@ -20,6 +20,7 @@
 #include "envoy/buffer/buffer.h"
 #include "envoy/common/random_generator.h"
 #include "envoy/common/time.h"
+#include "envoy/config/core/v3/base.pb.h"
 #include "envoy/http/header_map.h"
 #include "envoy/http/query_params.h"
 #include "envoy/runtime/runtime.h"
@@ -64,11 +65,14 @@ class ServiceControlHandlerImpl
   void onDestroy() override;
 
  private:
-  absl::string_view getOperationFromPerRoute(
+  const PerRouteFilterConfig* getPerRouteConfig(
       const Envoy::StreamInfo::StreamInfo& stream_info);
 
   void callQuota();
 
+  // Decodes the JWT in the trusted header, if configured.
+  void extractTrustedJwt(const Envoy::Http::RequestHeaderMap& headers);
+
   void fillOperationInfo(
       ::espv2::api_proxy::service_control::OperationInfo& info);
   void prepareReportRequest(
@@ -94,6 +98,21 @@ class ServiceControlHandlerImpl
 
   bool hasApiKey() const { return !api_key_.empty(); }
 
+  // The API key locations of the matched requirement, or the default ones.
+  const ::google::protobuf::RepeatedPtrField<
+      ::espv2::api::envoy::v10::http::service_control::ApiKeyLocation>&
+  apiKeyLocations() const {
+    return require_ctx_->config().api_key().locations_size() > 0
+               ? require_ctx_->config().api_key().locations()
+               : cfg_parser_.default_api_keys().locations();
+  }
+
+  const std::string& reportedOperationName() const {
+    return require_ctx_->config().reported_operation_name().empty()
+               ? require_ctx_->config().operation_name()
+               : require_ctx_->config().reported_operation_name();
+  }
+
   void onCheckResponse(
       Envoy::Http::RequestHeaderMap& headers,
       const ::google::protobuf::util::Status& status,
@@ -114,9 +133,16 @@ class ServiceControlHandlerImpl
 
   std::string path_;
   std::string http_method_;
+  // The API version of the matched route, if set by its per-route config.
+  std::string route_api_version_;
   std::string uuid_;
   std::string api_key_;
 
+  // The base64url encoded payload of the JWT in the trusted header, and the
+  // decoded payload laid out as the jwt_authn filter's metadata.
+  std::string trusted_jwt_payload_;
+  ::envoy::config::core::v3::Metadata trusted_jwt_metadata_;
+
   // Considering the request headers can be modified, the original downstream
   // header should be used as request_header_size. This variable is used to
   // remember the downstream header size when HandlerImpl object is created.
@@ -159,6 +185,13 @@ class ServiceControlHandlerFactoryImpl : public ServiceControlHandlerFactory {
         filter_stats);
   }
 
+  bool skipServiceControl(absl::string_view operation) const override {
+    const RequirementContext* require_ctx =
+        cfg_parser_.find_requirement(operation);
+    return require_ctx != nullptr &&
+           require_ctx->config().skip_service_control();
+  }
+
  private:
   // Random object.
   Envoy::Random::RandomGenerator& random_;
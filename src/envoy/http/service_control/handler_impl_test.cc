@@ -15,6 +15,7 @@
 
 #include "src/envoy/http/service_control/handler_impl.h"
 
+#include "absl/strings/str_cat.h"
 #include "envoy/http/header_map.h"
 #include "gmock/gmock.h"
 #include "google/protobuf/text_format.h"
@@ -196,10 +197,12 @@ class HandlerTest : public ::testing::Test {
     counter.reset();
   }
 
-  void setPerRouteOperation(const std::string& operation) {
+  void setPerRouteOperation(const std::string& operation,
+                            const std::string& api_version = "") {
     ::espv2::api::envoy::v10::http::service_control::PerRouteFilterConfig
         per_route_cfg;
     per_route_cfg.set_operation_name(operation);
+    per_route_cfg.set_api_version(api_version);
     auto per_route = std::make_shared<PerRouteFilterConfig>(per_route_cfg);
     EXPECT_CALL(mock_stream_info_, routeEntry())
         .WillRepeatedly(Return(&mock_route_entry_));
@@ -338,6 +341,12 @@ MATCHER_P(MatchesSimpleReportInfo, expect, Envoy::EMPTY_STRING) {
   return true;
 }
 
+MATCHER_P2(MatchesReportSizes, request_size, response_size,
+           Envoy::EMPTY_STRING) {
+  return arg.request_size == request_size &&
+         arg.response_size == response_size;
+}
+
 MATCHER_P(MatchesDataReportInfo, expect, Envoy::EMPTY_STRING) {
   std::string operation_name =
       (expect.operation_name.empty() ? "get_header_key"
@@ -591,6 +600,52 @@ TEST_F(HandlerTest, HandlerSuccessfulCheckSyncWithoutApiKeyRestrictionFields) {
   handler.callReport(&headers, &response_headers, &resp_trailer_, mock_span_);
 }
 
+TEST_F(HandlerTest, HandlerCheckForwardsApiKey) {
+  // Test: The api key is kept in the request by default.
+  setPerRouteOperation("get_header_key");
+  TestRequestHeaderMapImpl headers{
+      {":method", "GET"}, {":path", "/echo"}, {"x-api-key", "foobar"}};
+  ServiceControlHandlerImpl handler(headers, mock_stream_info_, "test-uuid",
+                                    *cfg_parser_, test_time_, stats_);
+
+  EXPECT_CALL(*mock_call_, callCheck(_, _, _))
+      .WillOnce(Invoke([](const CheckRequestInfo&, Envoy::Tracing::Span&,
+                          CheckDoneFunc on_done) {
+        on_done(OkStatus(), CheckResponseInfo());
+        return nullptr;
+      }));
+  EXPECT_CALL(mock_check_done_callback_, onCheckDone(OkStatus(), ""));
+  handler.callCheck(headers, mock_span_, mock_check_done_callback_);
+
+  EXPECT_EQ(headers.get_("x-api-key"), "foobar");
+}
+
+TEST_F(HandlerTest, HandlerCheckRemovesApiKey) {
+  // Test: The api key is removed from the request if remove_api_key is set.
+  const std::string filter_config =
+      absl::StrCat(kFilterConfig, "remove_api_key: true");
+  setUp(filter_config.c_str());
+  setPerRouteOperation("get_query_key");
+  TestRequestHeaderMapImpl headers{{":method", "GET"},
+                                   {":path", "/echo?key=foobar&lang=en"}};
+  ServiceControlHandlerImpl handler(headers, mock_stream_info_, "test-uuid",
+                                    *cfg_parser_, test_time_, stats_);
+
+  CheckRequestInfo expected_check_info;
+  expected_check_info.api_key = "foobar";
+  EXPECT_CALL(*mock_call_,
+              callCheck(MatchesCheckInfo(expected_check_info), _, _))
+      .WillOnce(Invoke([](const CheckRequestInfo&, Envoy::Tracing::Span&,
+                          CheckDoneFunc on_done) {
+        on_done(OkStatus(), CheckResponseInfo());
+        return nullptr;
+      }));
+  EXPECT_CALL(mock_check_done_callback_, onCheckDone(OkStatus(), ""));
+  handler.callCheck(headers, mock_span_, mock_check_done_callback_);
+
+  EXPECT_EQ(headers.getPathValue(), "/echo?lang=en");
+}
+
 TEST_F(HandlerTest, HandlerSuccessfulQuotaSync) {
   // Test: Quota is required and succeeds.
   setPerRouteOperation("get_header_key_quota");
@@ -1091,6 +1146,49 @@ TEST_F(HandlerTest, HandlerReportWithTrace) {
   handler.callReport(&headers, &response_headers, &resp_trailer_, mock_span_);
 }
 
+TEST_F(HandlerTest, HandlerReportWithRouteApiVersion) {
+  // Test: Test that the API version of the route overrides the one of the API.
+  setPerRouteOperation("get_header_key", "v2");
+  TestRequestHeaderMapImpl headers{
+      {":method", "GET"}, {":path", "/echo"}, {"x-api-key", "foobar"}};
+  TestResponseHeaderMapImpl response_headers{
+      {"content-type", "application/grpc"}};
+  ServiceControlHandlerImpl handler(headers, mock_stream_info_, "test-uuid",
+                                    *cfg_parser_, test_time_, stats_);
+
+  ReportRequestInfo expected_report_info;
+  initExpectedReportInfo(expected_report_info);
+  expected_report_info.api_key = "foobar";
+  expected_report_info.status = OkStatus();
+  expected_report_info.api_version = "v2";
+  EXPECT_CALL(*mock_call_,
+              callReport(MatchesReportInfo(expected_report_info, headers,
+                                           response_headers, resp_trailer_)));
+  handler.callReport(&headers, &response_headers, &resp_trailer_, mock_span_);
+}
+
+TEST_F(HandlerTest, HandlerReportWithBodySizes) {
+  // Test: Test that the reported sizes include the request and response
+  // bodies, in addition to the headers and trailers.
+  EXPECT_CALL(mock_stream_info_, bytesReceived()).WillRepeatedly(Return(1000));
+  EXPECT_CALL(mock_stream_info_, bytesSent()).WillRepeatedly(Return(2000));
+
+  setPerRouteOperation("get_header_key");
+  TestRequestHeaderMapImpl headers{
+      {":method", "POST"}, {":path", "/echo"}, {"x-api-key", "foobar"}};
+  TestResponseHeaderMapImpl response_headers{
+      {"content-type", "application/grpc"}};
+  ServiceControlHandlerImpl handler(headers, mock_stream_info_, "test-uuid",
+                                    *cfg_parser_, test_time_, stats_);
+
+  const int64_t request_size = headers.byteSize() + 1000;
+  const int64_t response_size =
+      response_headers.byteSize() + resp_trailer_.byteSize() + 2000;
+  EXPECT_CALL(*mock_call_,
+              callReport(MatchesReportSizes(request_size, response_size)));
+  handler.callReport(&headers, &response_headers, &resp_trailer_, mock_span_);
+}
+
 class HandlerReportStatusTest : public HandlerTest {
  protected:
   void runTest(unsigned int http_response_code,
@@ -14,24 +14,32 @@
 
 #include "src/envoy/http/service_control/handler_utils.h"
 
+#include <algorithm>
 #include <sstream>
 #include <vector>
 
+#include "absl/container/flat_hash_set.h"
 #include "absl/strings/str_cat.h"
+#include "absl/strings/str_join.h"
+#include "absl/strings/strip.h"
 #include "absl/strings/str_split.h"
 #include "absl/types/optional.h"
 #include "api/envoy/v10/http/service_control/config.pb.h"
 #include "envoy/grpc/status.h"
+#include "google/protobuf/util/json_util.h"
 #include "envoy/http/header_map.h"
 #include "envoy/server/filter_config.h"
+#include "source/common/common/base64.h"
 #include "source/common/common/logger.h"
 #include "source/common/grpc/common.h"
+#include "source/common/grpc/status.h"
 #include "source/common/http/header_utility.h"
 #include "source/common/http/utility.h"
 #include "source/extensions/filters/http/well_known_names.h"
 #include "src/api_proxy/service_control/request_builder.h"
 
 using ::espv2::api::envoy::v10::http::service_control::ApiKeyLocation;
+using ::espv2::api::envoy::v10::http::service_control::JwtClaimHeaders;
 using ::espv2::api::envoy::v10::http::service_control::Service;
 using ::espv2::api_proxy::service_control::LatencyInfo;
 using ::espv2::api_proxy::service_control::protocol::Protocol;
@@ -171,6 +179,22 @@ void fillLoggedHeader(
   }
 }
 
+void fillExtraLabels(
+    const Envoy::Http::HeaderMap* headers,
+    const ::google::protobuf::Map<std::string, std::string>& extra_labels,
+    std::map<std::string, std::string>& info_labels) {
+  if (headers == nullptr) {
+    return;
+  }
+  for (const auto& extra_label : extra_labels) {
+    const auto entry = Envoy::Http::HeaderUtility::getAllOfHeaderAsString(
+        *headers, Envoy::Http::LowerCaseString(extra_label.first));
+    if (entry.result().has_value()) {
+      info_labels[extra_label.second] = std::string(entry.result().value());
+    }
+  }
+}
+
 void fillLatency(const Envoy::StreamInfo::StreamInfo& stream_info,
                  LatencyInfo& latency,
                  ServiceControlFilterStats& filter_stats) {
@@ -291,6 +315,74 @@ void fillJwtPayload(const ::envoy::config::core::v3::Metadata& metadata,
   }
 }
 
+void fillJwtClaimLabels(
+    const ::envoy::config::core::v3::Metadata& metadata,
+    const std::string& jwt_payload_metadata_name,
+    const ::google::protobuf::Map<std::string, std::string>& jwt_claim_labels,
+    std::map<std::string, std::string>& info_labels) {
+  for (const auto& claim_label : jwt_claim_labels) {
+    std::vector<std::string> steps = {jwt_payload_metadata_name,
+                                      claim_label.first};
+    const Envoy::ProtobufWkt::Value& value =
+        Envoy::Config::Metadata::metadataValue(
+            &metadata,
+            Envoy::Extensions::HttpFilters::HttpFilterNames::get().JwtAuthn,
+            steps);
+    if (value.kind_case() != Envoy::ProtobufWkt::Value::kStringValue ||
+        value.string_value().empty()) {
+      continue;
+    }
+    info_labels[claim_label.second] = value.string_value();
+  }
+}
+
+bool fillJwtClaimHeaders(const ::envoy::config::core::v3::Metadata& metadata,
+                         const JwtClaimHeaders& jwt_claim_headers,
+                         Envoy::Http::RequestHeaderMap& headers) {
+  bool changed = false;
+  for (const auto& claim_header : jwt_claim_headers.claim_headers()) {
+    const Envoy::Http::LowerCaseString header(claim_header.second);
+    if (!headers.get(header).empty()) {
+      headers.remove(header);
+      changed = true;
+    }
+
+    std::vector<std::string> steps = {
+        jwt_claim_headers.jwt_payload_metadata_name(), claim_header.first};
+    const Envoy::ProtobufWkt::Value& value =
+        Envoy::Config::Metadata::metadataValue(
+            &metadata,
+            Envoy::Extensions::HttpFilters::HttpFilterNames::get().JwtAuthn,
+            steps);
+    if (value.kind_case() != Envoy::ProtobufWkt::Value::kStringValue ||
+        value.string_value().empty() ||
+        !Envoy::Http::HeaderUtility::headerValueIsValid(value.string_value())) {
+      continue;
+    }
+    headers.setCopy(header, value.string_value());
+    changed = true;
+  }
+  return changed;
+}
+
+bool decodeTrustedJwtPayload(absl::string_view jwt,
+                             std::string& encoded_payload,
+                             Envoy::ProtobufWkt::Struct& payload) {
+  absl::ConsumePrefix(&jwt, "Bearer ");
+  const std::vector<absl::string_view> segments = absl::StrSplit(jwt, '.');
+  if (segments.size() != 3 || segments[1].empty()) {
+    return false;
+  }
+
+  const std::string json = Envoy::Base64Url::decode(std::string(segments[1]));
+  if (json.empty() ||
+      !::google::protobuf::util::JsonStringToMessage(json, &payload).ok()) {
+    return false;
+  }
+  encoded_payload = std::string(segments[1]);
+  return true;
+}
+
 bool extractAPIKey(
     const Envoy::Http::RequestHeaderMap& headers,
     const ::google::protobuf::RepeatedPtrField<
@@ -324,6 +416,58 @@ bool extractAPIKey(
   return false;
 }
 
+void removeAPIKey(
+    Envoy::Http::RequestHeaderMap& headers,
+    const ::google::protobuf::RepeatedPtrField<
+        ::espv2::api::envoy::v10::http::service_control::ApiKeyLocation>&
+        locations) {
+  absl::flat_hash_set<std::string> query_names;
+  for (const auto& location : locations) {
+    switch (location.key_case()) {
+      case ApiKeyLocation::kQuery:
+        query_names.insert(location.query());
+        break;
+      case ApiKeyLocation::kHeader:
+        headers.remove(Envoy::Http::LowerCaseString(location.header()));
+        break;
+      default:
+        break;
+    }
+  }
+
+  if (query_names.empty() || headers.Path() == nullptr) {
+    return;
+  }
+  const absl::string_view path = headers.Path()->value().getStringView();
+  const size_t query_start = path.find('?');
+  if (query_start == absl::string_view::npos) {
+    return;
+  }
+
+  std::vector<absl::string_view> kept_params;
+  bool removed = false;
+  for (absl::string_view param :
+       absl::StrSplit(path.substr(query_start + 1), '&')) {
+    // Names are compared decoded, e.g. "%6Bey" is "key".
+    const std::string name = Envoy::Http::Utility::PercentEncoding::decode(
+        param.substr(0, param.find('=')));
+    if (query_names.contains(name)) {
+      removed = true;
+      continue;
+    }
+    kept_params.push_back(param);
+  }
+  if (!removed) {
+    return;
+  }
+
+  std::string new_path(path.substr(0, query_start));
+  if (!kept_params.empty()) {
+    absl::StrAppend(&new_path, "?", absl::StrJoin(kept_params, "&"));
+  }
+  headers.setPath(new_path);
+}
+
 void fillStatus(const Envoy::Http::ResponseHeaderMap* response_headers,
                 const Envoy::Http::ResponseTrailerMap* response_trailers,
                 const Envoy::StreamInfo::StreamInfo& stream_info,
@@ -357,6 +501,27 @@ void fillStatus(const Envoy::Http::ResponseHeaderMap* response_headers,
   info.grpc_response_code = static_cast<StatusCode>(status.value());
 }
 
+bool isSuccessStatusCode(
+    const Service& service, const Envoy::StreamInfo::StreamInfo& stream_info,
+    const ::espv2::api_proxy::service_control::ReportRequestInfo& info) {
+  const auto& success_status_codes = service.success_status_codes();
+  if (success_status_codes.empty() ||
+      stream_info.responseCodeDetails().value_or("") !=
+          Envoy::StreamInfo::ResponseCodeDetails::get().ViaUpstream) {
+    return false;
+  }
+
+  // Same as the status code reported: the gRPC status is mapped to HTTP.
+  uint32_t status_code = info.http_response_code;
+  if (info.grpc_response_code.has_value()) {
+    status_code = Envoy::Grpc::Utility::grpcToHttpStatus(
+        static_cast<Envoy::Grpc::Status::GrpcStatus>(
+            info.grpc_response_code.value()));
+  }
+  return std::find(success_status_codes.begin(), success_status_codes.end(),
+                   status_code) != success_status_codes.end();
+}
+
 }  // namespace service_control
 }  // namespace http_filters
 }  // namespace envoy
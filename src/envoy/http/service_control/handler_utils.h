@@ -38,6 +38,14 @@ bool extractAPIKey(
         locations,
     std::string& api_key);
 
+// Removes the headers and query parameters at the given locations, so that the
+// API key is not forwarded to the backend. Cookies are left unchanged.
+void removeAPIKey(
+    Envoy::Http::RequestHeaderMap& headers,
+    const ::google::protobuf::RepeatedPtrField<
+        ::espv2::api::envoy::v10::http::service_control::ApiKeyLocation>&
+        locations);
+
 // Adds information from the `FilterConfig`'s gcp_attributes to the given info.
 void fillGCPInfo(
     const ::espv2::api::envoy::v10::http::service_control::FilterConfig&
@@ -51,6 +59,13 @@ void fillLoggedHeader(
     const ::google::protobuf::RepeatedPtrField<::std::string>& log_headers,
     std::string& info_header_field);
 
+// Searches the `headers` for the keys of `extra_labels` and adds the values
+// of all matches to `info_labels`, keyed by the label names.
+void fillExtraLabels(
+    const Envoy::Http::HeaderMap* headers,
+    const ::google::protobuf::Map<std::string, std::string>& extra_labels,
+    std::map<std::string, std::string>& info_labels);
+
 // Fills the `request_time_ms`, `backend_time_ms`, and `overhead_time_ms` of the
 // info provided.
 void fillLatency(const Envoy::StreamInfo::StreamInfo& stream_info,
@@ -69,6 +84,33 @@ void fillJwtPayload(const ::envoy::config::core::v3::Metadata& metadata,
                     const std::string& jwt_payload_path,
                     std::string& info_iss_or_aud);
 
+// Searches the jwt payload for the claims of `jwt_claim_labels` and adds the
+// values of all string claims to `info_labels`, keyed by the label names.
+void fillJwtClaimLabels(
+    const ::envoy::config::core::v3::Metadata& metadata,
+    const std::string& jwt_payload_metadata_name,
+    const ::google::protobuf::Map<std::string, std::string>& jwt_claim_labels,
+    std::map<std::string, std::string>& info_labels);
+
+// Removes the headers of `jwt_claim_headers` from the request, then sets each
+// of them to its claim in the jwt payload if the claim is a non-empty string.
+//
+// Returns whether any header was removed or set.
+bool fillJwtClaimHeaders(
+    const ::envoy::config::core::v3::Metadata& metadata,
+    const ::espv2::api::envoy::v10::http::service_control::JwtClaimHeaders&
+        jwt_claim_headers,
+    Envoy::Http::RequestHeaderMap& headers);
+
+// Decodes the payload of the given JWT without verifying its signature. An
+// optional "Bearer " prefix is allowed. Sets the base64url encoded payload
+// and the decoded claims.
+//
+// Returns whether the JWT was well formed.
+bool decodeTrustedJwtPayload(absl::string_view jwt,
+                             std::string& encoded_payload,
+                             Envoy::ProtobufWkt::Struct& payload);
+
 // Returns the protocol of the frontend request or UNKNOWN if not found
 ::espv2::api_proxy::service_control::protocol::Protocol getFrontendProtocol(
     const Envoy::Http::ResponseHeaderMap* response_headers,
@@ -84,6 +126,14 @@ void fillStatus(const Envoy::Http::ResponseHeaderMap* response_headers,
                 const Envoy::StreamInfo::StreamInfo& stream_info,
                 ::espv2::api_proxy::service_control::ReportRequestInfo& info);
 
+// Returns true if the status reported for the response is one of the
+// configured success status codes. Only backend responses are considered, not
+// the local replies of Envoy and ESPv2. The status must be filled first.
+bool isSuccessStatusCode(
+    const ::espv2::api::envoy::v10::http::service_control::Service& service,
+    const Envoy::StreamInfo::StreamInfo& stream_info,
+    const ::espv2::api_proxy::service_control::ReportRequestInfo& info);
+
 }  // namespace service_control
 }  // namespace http_filters
 }  // namespace envoy
@@ -27,6 +27,7 @@
 
 using ::espv2::api::envoy::v10::http::service_control::ApiKeyRequirement;
 using ::espv2::api::envoy::v10::http::service_control::FilterConfig;
+using ::espv2::api::envoy::v10::http::service_control::JwtClaimHeaders;
 using ::espv2::api::envoy::v10::http::service_control::Service;
 using ::espv2::api_proxy::service_control::LatencyInfo;
 using ::espv2::api_proxy::service_control::ReportRequestInfo;
@@ -163,6 +164,28 @@ TEST(ServiceControlUtils, FillLoggedHeader) {
   EXPECT_TRUE(output == "log-this=bar,foo;" || output == "log-this=foo,bar;");
 }
 
+TEST(ServiceControlUtils, FillExtraLabels) {
+  Service service;
+  ASSERT_TRUE(TextFormat::ParseFromString(
+      R"(
+extra_labels { key: "x-client-version" value: "/client_version" }
+extra_labels { key: "x-client-os" value: "/client_os" }
+)",
+      &service));
+
+  // The function can accept null headers
+  std::map<std::string, std::string> labels;
+  fillExtraLabels(nullptr, service.extra_labels(), labels);
+  EXPECT_TRUE(labels.empty());
+
+  // Missing headers are omitted.
+  Envoy::Http::TestRequestHeaderMapImpl headers{{"x-client-version", "1.2.3"},
+                                                {"ignore-this", "foo"}};
+  fillExtraLabels(&headers, service.extra_labels(), labels);
+  EXPECT_EQ(labels, (std::map<std::string, std::string>{
+                        {"/client_version", "1.2.3"}}));
+}
+
 TEST(ServiceControlUtils, ExtractApiKey) {
   struct TestCase {
     std::string requirement_proto;
@@ -282,6 +305,70 @@ TEST(ServiceControlUtils, ExtractApiKey) {
   }
 }
 
+TEST(ServiceControlUtils, RemoveApiKey) {
+  struct TestCase {
+    std::string requirement_proto;
+    Envoy::Http::TestRequestHeaderMapImpl headers;
+    Envoy::Http::TestRequestHeaderMapImpl expected_headers;
+  };
+
+  const TestCase test_cases[] = {
+      // Test: remove the apikey header
+      {
+          R"(locations: { header: "apikey" } )",
+          {{":path", "/echo"}, {"apikey", "foobar"}, {"other", "value"}},
+          {{":path", "/echo"}, {"other", "value"}},
+      },
+
+      // Test: remove the only apikey query parameter
+      {
+          R"(locations: { query: "apikey" } )",
+          {{":path", "/echo?apikey=foobar"}},
+          {{":path", "/echo"}},
+      },
+
+      // Test: remove the apikey query parameters and keep the others
+      {
+          R"(
+            locations: { query: "apikey" }
+            locations: { query: "apikey2" } )",
+          {{":path", "/echo?a=1&apikey=foobar&b&apikey2=foobar"}},
+          {{":path", "/echo?a=1&b"}},
+      },
+
+      // Test: remove the percent-encoded apikey query parameter
+      {
+          R"(locations: { query: "apikey" } )",
+          {{":path", "/echo?%61pikey=foobar&lang=en"}},
+          {{":path", "/echo?lang=en"}},
+      },
+
+      // Test: query parameters with the apikey name as prefix are kept
+      {
+          R"(locations: { query: "apikey" } )",
+          {{":path", "/echo?apikey_hint=1"}},
+          {{":path", "/echo?apikey_hint=1"}},
+      },
+
+      // Test: cookies are kept
+      {
+          R"(locations: { cookie: "apikey" } )",
+          {{":path", "/echo"}, {"cookie", "apikey=foobar"}},
+          {{":path", "/echo"}, {"cookie", "apikey=foobar"}},
+      }};
+
+  for (const auto& test : test_cases) {
+    ApiKeyRequirement requirement;
+    ASSERT_TRUE(
+        TextFormat::ParseFromString(test.requirement_proto, &requirement));
+
+    Envoy::Http::TestRequestHeaderMapImpl headers = test.headers;
+    removeAPIKey(headers, requirement.locations());
+
+    EXPECT_THAT(headers, Envoy::HeaderMapEqualRef(&test.expected_headers));
+  }
+}
+
 TEST(ServiceControlUtils, FillLatency) {
   struct TestCase {
     std::chrono::nanoseconds end_time;
@@ -410,6 +497,130 @@ TEST(ServiceControlUtils, FillLatency) {
   }
 }
 
+TEST(ServiceControlUtils, DecodeTrustedJwtPayload) {
+  const std::string encoded_payload =
+      "eyJpc3MiOiJ0ZXN0LWlzc3VlciIsInN1YiI6InRlc3QtdXNlciJ9";
+
+  for (const std::string& jwt :
+       {"header." + encoded_payload + ".signature",
+        "Bearer header." + encoded_payload + ".signature"}) {
+    std::string got_encoded_payload;
+    Envoy::ProtobufWkt::Struct got_payload;
+    EXPECT_TRUE(decodeTrustedJwtPayload(jwt, got_encoded_payload, got_payload));
+    EXPECT_EQ(got_encoded_payload, encoded_payload);
+    EXPECT_EQ(got_payload.fields().at("iss").string_value(), "test-issuer");
+    EXPECT_EQ(got_payload.fields().at("sub").string_value(), "test-user");
+  }
+
+  for (const std::string& jwt :
+       {"", "header.signature", "header..signature", "header.!!!.signature",
+        // Payload is not JSON.
+        "header.bm90LWpzb24.signature"}) {
+    std::string got_encoded_payload;
+    Envoy::ProtobufWkt::Struct got_payload;
+    EXPECT_FALSE(
+        decodeTrustedJwtPayload(jwt, got_encoded_payload, got_payload));
+    EXPECT_TRUE(got_encoded_payload.empty());
+  }
+}
+
+TEST(ServiceControlUtils, FillJwtClaimLabels) {
+  Service service;
+  ASSERT_TRUE(TextFormat::ParseFromString(
+      R"(
+jwt_payload_metadata_name: "jwt_payloads"
+jwt_claim_labels { key: "sub" value: "/jwt_sub" }
+jwt_claim_labels { key: "email" value: "/jwt_email" }
+jwt_claim_labels { key: "admin" value: "/jwt_admin" }
+)",
+      &service));
+
+  ::envoy::config::core::v3::Metadata metadata;
+  ASSERT_TRUE(TextFormat::ParseFromString(R"(
+filter_metadata {
+  key: "envoy.filters.http.jwt_authn"
+  value {
+    fields {
+      key: "jwt_payloads"
+      value {
+        struct_value {
+          fields { key: "sub" value { string_value: "user-123" } }
+          fields { key: "admin" value { bool_value: true } }
+        }
+      }
+    }
+  }
+}
+)",
+                                          &metadata));
+
+  // Missing and non-string claims are omitted.
+  std::map<std::string, std::string> labels;
+  fillJwtClaimLabels(metadata, service.jwt_payload_metadata_name(),
+                     service.jwt_claim_labels(), labels);
+  EXPECT_EQ(labels,
+            (std::map<std::string, std::string>{{"/jwt_sub", "user-123"}}));
+
+  // Without a verified JWT, no labels are added.
+  std::map<std::string, std::string> no_jwt_labels;
+  fillJwtClaimLabels(::envoy::config::core::v3::Metadata(),
+                     service.jwt_payload_metadata_name(),
+                     service.jwt_claim_labels(), no_jwt_labels);
+  EXPECT_TRUE(no_jwt_labels.empty());
+}
+
+TEST(ServiceControlUtils, FillJwtClaimHeaders) {
+  JwtClaimHeaders jwt_claim_headers;
+  ASSERT_TRUE(TextFormat::ParseFromString(R"(
+jwt_payload_metadata_name: "jwt_payloads"
+claim_headers { key: "tier" value: "x-jwt-claim-tier" }
+claim_headers { key: "region" value: "x-jwt-claim-region" }
+claim_headers { key: "admin" value: "x-jwt-claim-admin" }
+)",
+                                          &jwt_claim_headers));
+
+  ::envoy::config::core::v3::Metadata metadata;
+  ASSERT_TRUE(TextFormat::ParseFromString(R"(
+filter_metadata {
+  key: "envoy.filters.http.jwt_authn"
+  value {
+    fields {
+      key: "jwt_payloads"
+      value {
+        struct_value {
+          fields { key: "tier" value { string_value: "enterprise" } }
+          fields { key: "admin" value { bool_value: true } }
+        }
+      }
+    }
+  }
+}
+)",
+                                          &metadata));
+
+  // Client supplied claim headers are always removed.
+  Envoy::Http::TestRequestHeaderMapImpl headers{
+      {"x-jwt-claim-tier", "free"}, {"x-jwt-claim-region", "us"}};
+  EXPECT_TRUE(fillJwtClaimHeaders(metadata, jwt_claim_headers, headers));
+
+  EXPECT_EQ(headers.get_("x-jwt-claim-tier"), "enterprise");
+  EXPECT_FALSE(headers.has("x-jwt-claim-region"));
+  // Non-string claims are not copied.
+  EXPECT_FALSE(headers.has("x-jwt-claim-admin"));
+
+  // Without a verified JWT, no claim headers are set.
+  Envoy::Http::TestRequestHeaderMapImpl no_jwt_headers{
+      {"x-jwt-claim-tier", "enterprise"}};
+  EXPECT_TRUE(fillJwtClaimHeaders(::envoy::config::core::v3::Metadata(),
+                                  jwt_claim_headers, no_jwt_headers));
+  EXPECT_FALSE(no_jwt_headers.has("x-jwt-claim-tier"));
+
+  // Nothing changes without a verified JWT or client supplied claim headers.
+  Envoy::Http::TestRequestHeaderMapImpl unchanged_headers{{"x-other", "a"}};
+  EXPECT_FALSE(fillJwtClaimHeaders(::envoy::config::core::v3::Metadata(),
+                                   jwt_claim_headers, unchanged_headers));
+}
+
 TEST(ServiceControlUtils, GetBackendProtocol) {
   Service service;
 
@@ -462,6 +673,42 @@ TEST(ServiceControlUtils, GetFrontendProtocol) {
   EXPECT_EQ(Protocol::HTTP, getFrontendProtocol(nullptr, mock_stream_info));
 }
 
+TEST(ServiceControlUtils, IsSuccessStatusCode) {
+  Service service;
+  testing::NiceMock<Envoy::StreamInfo::MockStreamInfo> mock_stream_info;
+  mock_stream_info.response_code_details_ =
+      Envoy::StreamInfo::ResponseCodeDetails::get().ViaUpstream;
+  ReportRequestInfo info;
+  info.http_response_code = 404;
+
+  // Test: no success status codes
+  EXPECT_FALSE(isSuccessStatusCode(service, mock_stream_info, info));
+
+  // Test: the backend responds with a success status code
+  service.add_success_status_codes(404);
+  EXPECT_TRUE(isSuccessStatusCode(service, mock_stream_info, info));
+
+  // Test: the backend responds with another status code
+  info.http_response_code = 409;
+  EXPECT_FALSE(isSuccessStatusCode(service, mock_stream_info, info));
+
+  // Test: the gRPC status is mapped to the HTTP status that is reported
+  info.http_response_code = 200;
+  info.grpc_response_code = ::google::protobuf::util::StatusCode::kNotFound;
+  EXPECT_TRUE(isSuccessStatusCode(service, mock_stream_info, info));
+
+  // Test: a gRPC status mapped to another HTTP status
+  info.grpc_response_code =
+      ::google::protobuf::util::StatusCode::kAlreadyExists;
+  EXPECT_FALSE(isSuccessStatusCode(service, mock_stream_info, info));
+
+  // Test: a local reply with a success status code
+  info.grpc_response_code.reset();
+  info.http_response_code = 404;
+  mock_stream_info.response_code_details_ = "path_not_matched";
+  EXPECT_FALSE(isSuccessStatusCode(service, mock_stream_info, info));
+}
+
 }  // namespace
 }  // namespace service_control
 }  // namespace http_filters
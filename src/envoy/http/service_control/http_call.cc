@@ -14,9 +14,11 @@
 
 #include "src/envoy/http/service_control/http_call.h"
 
+#include <algorithm>
 #include <memory>
 
 #include "envoy/event/deferred_deletable.h"
+#include "envoy/event/timer.h"
 #include "source/common/common/empty_string.h"
 #include "source/common/common/enum_to_int.h"
 #include "source/common/grpc/status.h"
@@ -41,6 +43,9 @@ namespace {
 
 constexpr absl::string_view KApplicationProto = "application/x-protobuf";
 
+// The retry backoff doubles on each retry, up to this many times.
+constexpr uint32_t kMaxRetryBackoffShift = 5;
+
 RegisterCustomInlineHeader<CustomInlineHeaderRegistry::Type::RequestHeaders>
     authorization_handle(CustomHeaders::get().Authorization);
 
@@ -56,13 +61,15 @@ class HttpCallImpl : public HttpCall,
                const Envoy::Protobuf::Message& body, uint32_t timeout_ms,
                uint32_t retries, Envoy::Tracing::Span& parent_span,
                Envoy::TimeSource& time_source,
-               const std::string& trace_operation_name)
+               const std::string& trace_operation_name,
+               uint32_t retry_backoff_ms)
       : cm_(cm),
         dispatcher_(dispatcher),
         http_uri_(uri),
         retries_(retries),
         request_count_(0),
         timeout_ms_(timeout_ms),
+        retry_backoff_ms_(retry_backoff_ms),
         cancelled(false),
         token_fn_(token_fn),
         parent_span_(parent_span),
@@ -176,7 +183,21 @@ class HttpCallImpl : public HttpCall,
               request_count_, uri_, retries_);
 
     reset();
-    makeOneCall();
+    if (retry_backoff_ms_ == 0) {
+      makeOneCall();
+      return true;
+    }
+
+    // Back off exponentially before the next attempt.
+    const uint32_t backoff_ms =
+        retry_backoff_ms_
+        << std::min(request_count_ - 1, kMaxRetryBackoffShift);
+    ENVOY_LOG(debug, "http call [uri = {}]: retrying in {} ms", uri_,
+              backoff_ms);
+    if (!retry_timer_) {
+      retry_timer_ = dispatcher_.createTimer([this]() { makeOneCall(); });
+    }
+    retry_timer_->enableTimer(std::chrono::milliseconds(backoff_ms));
     return true;
   }
 
@@ -226,6 +247,9 @@ class HttpCallImpl : public HttpCall,
     }
     cancelled = true;
     ENVOY_LOG(debug, "Http call [uri = {}]: canceled", uri_);
+    if (retry_timer_) {
+      retry_timer_->disableTimer();
+    }
     if (request_span_) {
       request_span_->setTag(Envoy::Tracing::Tags::get().Error,
                             Envoy::Tracing::Tags::get().Canceled);
@@ -297,6 +321,10 @@ class HttpCallImpl : public HttpCall,
   uint32_t request_count_;
   // The timeout
   uint32_t timeout_ms_;
+  // The initial backoff between retries, 0 to retry immediately
+  uint32_t retry_backoff_ms_;
+  // The timer to make the next attempt after backing off
+  Envoy::Event::TimerPtr retry_timer_;
   // whether this call has been cancelled
   bool cancelled;
 
@@ -317,7 +345,7 @@ HttpCallFactoryImpl::HttpCallFactoryImpl(
     const ::espv2::api::envoy::v10::http::common::HttpUri& uri,
     const std::string& suffix_url, std::function<const std::string&()> token_fn,
     uint32_t timeout_ms, uint32_t retries, Envoy::TimeSource& time_source,
-    const std::string& trace_operation_name)
+    const std::string& trace_operation_name, uint32_t retry_backoff_ms)
     : cm_(cm),
       dispatcher_(dispatcher),
       uri_(uri),
@@ -325,6 +353,7 @@ HttpCallFactoryImpl::HttpCallFactoryImpl(
       token_fn_(token_fn),
       timeout_ms_(timeout_ms),
       retries_(retries),
+      retry_backoff_ms_(retry_backoff_ms),
       destruct_mode_(false),
       time_source_(time_source),
       trace_operation_name_(trace_operation_name){};
@@ -335,7 +364,8 @@ HttpCall* HttpCallFactoryImpl::createHttpCall(
   ENVOY_LOG(debug, "{} is created", trace_operation_name_);
   HttpCallImpl* http_call = new HttpCallImpl(
       cm_, dispatcher_, uri_, suffix_url_, token_fn_, body, timeout_ms_,
-      retries_, parent_span, time_source_, trace_operation_name_);
+      retries_, parent_span, time_source_, trace_operation_name_,
+      retry_backoff_ms_);
   http_call->setDoneFunc([this, on_done, http_call](const Status& status,
                                                     const std::string& body) {
     // When the call is finished, it should be removed from active_calls_ .
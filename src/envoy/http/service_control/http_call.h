@@ -59,7 +59,8 @@ class HttpCallFactoryImpl : public HttpCallFactory {
                       std::function<const std::string&()> token_fn,
                       uint32_t timeout_ms, uint32_t retries,
                       Envoy::TimeSource& time_source,
-                      const std::string& trace_operation_name);
+                      const std::string& trace_operation_name,
+                      uint32_t retry_backoff_ms = 0);
 
   HttpCall* createHttpCall(const Envoy::Protobuf::Message& body,
                            Envoy::Tracing::Span& parent_span,
@@ -85,6 +86,8 @@ class HttpCallFactoryImpl : public HttpCallFactory {
   // call setting
   uint32_t timeout_ms_;
   uint32_t retries_;
+  // The initial backoff between retries, 0 to retry immediately.
+  uint32_t retry_backoff_ms_;
 
   // whether the factory is being destructed
   bool destruct_mode_;
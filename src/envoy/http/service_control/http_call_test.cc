@@ -378,6 +378,54 @@ TEST_F(HttpCallTest, TestThreeRetriesWithLastFailure) {
                                  makeResponseWithStatus(504));
 }
 
+TEST_F(HttpCallTest, TestRetryWithBackoff) {
+  // Set request to retry 2 more times, backing off from 100ms
+  retries_ = 2;
+  http_call_factory_ = std::make_unique<HttpCallFactoryImpl>(
+      cm_, dispatcher_, http_uri_, fake_suffix_url_, fake_token_fn_,
+      timeout_ms_, retries_, mock_time_source_, fake_trace_operation_name_,
+      /*retry_backoff_ms=*/100);
+  auto* retry_timer = new NiceMock<Envoy::Event::MockTimer>(&dispatcher_);
+
+  // Phase 1: Create HttpCall and send the request
+  auto mock_child_span_1 = makeMockChildSpan();
+  EXPECT_CALL(mock_done_fn_, Call(_, _))
+      .Times(0);  // Callback does not occur until response
+
+  HttpCall* call = http_call_factory_->createHttpCall(
+      fake_request_, mock_parent_span_, mock_done_fn_.AsStdFunction());
+  call->call();
+  EXPECT_EQ(1, async_callbacks_.size());
+
+  // Phase 2: Emulate a network failure, the retry is delayed by the backoff
+  EXPECT_CALL(*mock_child_span_1, finishSpan()).Times(1);
+  EXPECT_CALL(*retry_timer, enableTimer(std::chrono::milliseconds(100), _));
+  async_callbacks_[0]->onFailure(
+      lastHttpRequest(), Envoy::Http::AsyncClient::FailureReason::Reset);
+  EXPECT_EQ(1, async_callbacks_.size());
+
+  auto mock_child_span_2 = makeMockChildSpan();
+  retry_timer->invokeCallback();
+  EXPECT_EQ(2, async_callbacks_.size());
+
+  // Phase 3: Emulate another failure, the backoff doubles
+  EXPECT_CALL(*mock_child_span_2, finishSpan()).Times(1);
+  EXPECT_CALL(*retry_timer, enableTimer(std::chrono::milliseconds(200), _));
+  async_callbacks_[1]->onSuccess(lastHttpRequest(),
+                                 makeResponseWithStatus(503));
+  EXPECT_EQ(2, async_callbacks_.size());
+
+  auto mock_child_span_3 = makeMockChildSpan();
+  retry_timer->invokeCallback();
+  EXPECT_EQ(3, async_callbacks_.size());
+
+  // Phase 4: Emulate successful http response on last retry
+  EXPECT_CALL(*mock_child_span_3, finishSpan()).Times(1);
+  EXPECT_CALL(mock_done_fn_, Call(OkStatus(), _)).Times(1);
+  async_callbacks_[2]->onSuccess(lastHttpRequest(),
+                                 makeResponseWithStatus(200));
+}
+
 TEST_F(HttpCallTest, TestActiveCallCancel) {
   // Phase 1: Create HttpCall and send the request
   auto mock_child_span = makeMockChildSpan();
@@ -52,6 +52,9 @@ class MockServiceControlHandlerFactory : public ServiceControlHandlerFactory {
                const Envoy::StreamInfo::StreamInfo& stream_info,
                ServiceControlFilterStats& filter_stats),
               (const, override));
+
+  MOCK_METHOD(bool, skipServiceControl, (absl::string_view operation),
+              (const, override));
 };
 
 class MockServiceControlCall : public ServiceControlCall {
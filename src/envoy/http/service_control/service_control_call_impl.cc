@@ -14,6 +14,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+#include "envoy/singleton/manager.h"
 #include "google/protobuf/util/time_util.h"
 #include "source/common/common/assert.h"
 #include "src/api_proxy/service_control/logs_metrics_loader.h"
@@ -30,10 +31,13 @@ using ::espv2::api::envoy::v10::http::service_control::FilterConfig;
 using ::espv2::api::envoy::v10::http::service_control::Service;
 using ::espv2::api_proxy::service_control::LogsMetricsLoader;
 using ::espv2::api_proxy::service_control::RequestBuilder;
+using ::espv2::api_proxy::service_control::StatsReportInfo;
 using ::google::protobuf::util::TimeUtil;
 using token::TokenSubscriber;
 using token::TokenType;
 
+SINGLETON_MANAGER_REGISTRATION(espv2_stats_reporter);
+
 void ServiceControlCallImpl::createImdsTokenSub() {
   const std::string& token_cluster = filter_config_.imds_token().cluster();
   const std::string& token_uri = filter_config_.imds_token().uri();
@@ -106,8 +110,12 @@ ServiceControlCallImpl::ServiceControlCallImpl(
     const std::string& stats_prefix,
     Envoy::Server::Configuration::FactoryContext& context)
     : filter_config_(*proto_config),
+      config_(config),
       token_subscriber_factory_(context),
-      tls_(context.threadLocal()) {
+      tls_(context.threadLocal()),
+      server_scope_(context.getServerFactoryContext().scope()),
+      random_(context.api().randomGenerator()),
+      time_source_(context.timeSource()) {
   // Pass shared_ptr of proto_config to the function capture so that
   // it will not be released when the function is called.
   tls_.set([proto_config, &config, stats_prefix, &scope = context.scope(),
@@ -141,8 +149,27 @@ ServiceControlCallImpl::ServiceControlCallImpl(
     request_builder_.reset(new RequestBuilder(
         {"endpoints_log"}, config.service_name(), config.service_config_id()));
   }
+
+  if (config.has_stats_report()) {
+    // The timer runs on the main thread, which has its own thread local cache.
+    stats_reporter_ = context.singletonManager().getTyped<StatsReporter>(
+        SINGLETON_MANAGER_REGISTERED_NAME(espv2_stats_reporter),
+        [&dispatcher = context.dispatcher()] {
+          return std::make_shared<StatsReporter>(dispatcher);
+        });
+    stats_reporter_->registerReportFunc(
+        config.service_name(), this,
+        std::chrono::milliseconds(config.stats_report().interval_ms()),
+        [this]() { reportStats(); });
+  }
 }  // namespace ServiceControl
 
+ServiceControlCallImpl::~ServiceControlCallImpl() {
+  if (stats_reporter_) {
+    stats_reporter_->unregisterReportFunc(config_.service_name(), this);
+  }
+}
+
 CancelFunc ServiceControlCallImpl::callCheck(
     const ::espv2::api_proxy::service_control::CheckRequestInfo& request_info,
     Envoy::Tracing::Span& parent_span, CheckDoneFunc on_done) {
@@ -170,6 +197,44 @@ void ServiceControlCallImpl::callReport(
   getTLCache().client_cache().callReport(request);
 }
 
+void ServiceControlCallImpl::reportStats() {
+  const std::set<std::string> stat_names(
+      config_.stats_report().stat_names().begin(),
+      config_.stats_report().stat_names().end());
+
+  StatsReportInfo info;
+  server_scope_.iterate(
+      [&stat_names, &info](const Envoy::Stats::CounterSharedPtr& counter) {
+        const std::string name = counter->name();
+        if (stat_names.count(name) > 0) {
+          info.stats[name] = counter->value();
+        }
+        return true;
+      });
+  server_scope_.iterate(
+      [&stat_names, &info](const Envoy::Stats::GaugeSharedPtr& gauge) {
+        const std::string name = gauge->name();
+        if (stat_names.count(name) > 0) {
+          info.stats[name] = gauge->value();
+        }
+        return true;
+      });
+  if (info.stats.empty()) {
+    ENVOY_LOG(debug, "None of the Envoy stats to report is found");
+    return;
+  }
+
+  info.operation_id = random_.uuid();
+  info.operation_name = kStatsReportOperationName;
+  info.producer_project_id = config_.producer_project_id();
+  info.current_time = time_source_.systemTime();
+
+  ::google::api::servicecontrol::v1::ReportRequest request;
+  (void)request_builder_->FillStatsReportRequest(info, &request);
+  ENVOY_LOG(debug, "Sending stats report : {}", request.DebugString());
+  getTLCache().client_cache().callReport(request);
+}
+
 }  // namespace service_control
 }  // namespace http_filters
 }  // namespace envoy
@@ -15,6 +15,7 @@
 #pragma once
 
 #include "api/envoy/v10/http/service_control/config.pb.h"
+#include "envoy/common/random_generator.h"
 #include "envoy/server/filter_config.h"
 #include "envoy/thread_local/thread_local.h"
 #include "envoy/upstream/cluster_manager.h"
@@ -24,6 +25,7 @@
 #include "src/api_proxy/service_control/request_builder.h"
 #include "src/envoy/http/service_control/client_cache.h"
 #include "src/envoy/http/service_control/service_control_call.h"
+#include "src/envoy/http/service_control/stats_reporter.h"
 #include "src/envoy/token/token_subscriber_factory_impl.h"
 
 namespace espv2 {
@@ -38,6 +40,9 @@ using TokenSharedPtr = std::shared_ptr<std::string>;
 constexpr char kServiceControlScope[] =
     "https://www.googleapis.com/auth/servicecontrol";
 
+// The operation name of the periodic Envoy stats reports.
+constexpr char kStatsReportOperationName[] = "<Envoy Stats>";
+
 class ThreadLocalCache : public Envoy::ThreadLocal::ThreadLocalObject {
  public:
   ThreadLocalCache(
@@ -85,6 +90,8 @@ class ServiceControlCallImpl
       const std::string& stats_prefix,
       Envoy::Server::Configuration::FactoryContext& context);
 
+  ~ServiceControlCallImpl() override;
+
   CancelFunc callCheck(
       const ::espv2::api_proxy::service_control::CheckRequestInfo& request_info,
       Envoy::Tracing::Span& parent_span, CheckDoneFunc on_done) override;
@@ -103,8 +110,12 @@ class ServiceControlCallImpl
   void createImdsTokenSub();
   void createIamTokenSub();
 
+  // Samples the configured Envoy stats and reports them.
+  void reportStats();
+
   const ::espv2::api::envoy::v10::http::service_control::FilterConfig&
       filter_config_;
+  const ::espv2::api::envoy::v10::http::service_control::Service& config_;
   std::unique_ptr<::espv2::api_proxy::service_control::RequestBuilder>
       request_builder_;
 
@@ -121,6 +132,12 @@ class ServiceControlCallImpl
   token::TokenSubscriberPtr iam_token_sub_;
 
   Envoy::ThreadLocal::TypedSlot<ThreadLocalCache> tls_;
+
+  // Used by the periodic Envoy stats reports.
+  Envoy::Stats::Scope& server_scope_;
+  Envoy::Random::RandomGenerator& random_;
+  Envoy::TimeSource& time_source_;
+  StatsReporterSharedPtr stats_reporter_;
 };  // namespace ServiceControl
 
 class ServiceControlCallFactoryImpl : public ServiceControlCallFactory {
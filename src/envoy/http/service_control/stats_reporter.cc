@@ -0,0 +1,79 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "src/envoy/http/service_control/stats_reporter.h"
+
+namespace espv2 {
+namespace envoy {
+namespace http_filters {
+namespace service_control {
+
+StatsReporter::StatsReporter(Envoy::Event::Dispatcher& dispatcher)
+    : dispatcher_(dispatcher) {}
+
+void StatsReporter::registerReportFunc(const std::string& service_name,
+                                       const void* owner,
+                                       std::chrono::milliseconds interval,
+                                       ReportFunc report_func) {
+  auto& reports = services_[service_name];
+  if (!reports) {
+    reports = std::make_unique<ServiceReports>();
+    reports->timer = dispatcher_.createTimer([reports = reports.get()]() {
+      if (reports->registrations.empty()) {
+        return;
+      }
+      reports->registrations.back().report_func();
+      reports->timer->enableTimer(reports->registrations.back().interval);
+    });
+  }
+
+  reports->registrations.remove_if([owner](const Registration& registration) {
+    return registration.owner == owner;
+  });
+  reports->registrations.push_back({owner, interval, std::move(report_func)});
+  resetTimer(*reports);
+}
+
+void StatsReporter::unregisterReportFunc(const std::string& service_name,
+                                         const void* owner) {
+  auto it = services_.find(service_name);
+  if (it == services_.end()) {
+    return;
+  }
+  auto& reports = *it->second;
+  const bool was_current = !reports.registrations.empty() &&
+                           reports.registrations.back().owner == owner;
+  reports.registrations.remove_if([owner](const Registration& registration) {
+    return registration.owner == owner;
+  });
+  if (reports.registrations.empty()) {
+    services_.erase(it);
+    return;
+  }
+  if (was_current) {
+    resetTimer(reports);
+  }
+}
+
+void StatsReporter::resetTimer(ServiceReports& reports) {
+  reports.timer->disableTimer();
+  if (!reports.registrations.empty()) {
+    reports.timer->enableTimer(reports.registrations.back().interval);
+  }
+}
+
+}  // namespace service_control
+}  // namespace http_filters
+}  // namespace envoy
+}  // namespace espv2
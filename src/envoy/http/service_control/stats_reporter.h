@@ -0,0 +1,81 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#pragma once
+
+#include <chrono>
+#include <functional>
+#include <list>
+#include <memory>
+#include <string>
+
+#include "absl/container/flat_hash_map.h"
+#include "envoy/event/dispatcher.h"
+#include "envoy/event/timer.h"
+#include "envoy/singleton/instance.h"
+
+namespace espv2 {
+namespace envoy {
+namespace http_filters {
+namespace service_control {
+
+// The Envoy stats are periodically reported by a timer per service on the main
+// thread. Each filter config creates its own ServiceControlCall per service, so
+// during an LDS update the draining and the new filter configs both exist.
+// Only the most recently registered report function of each service is
+// called, so that the stats are not reported twice.
+class StatsReporter : public Envoy::Singleton::Instance {
+ public:
+  using ReportFunc = std::function<void()>;
+
+  explicit StatsReporter(Envoy::Event::Dispatcher& dispatcher);
+
+  // Registers the report function of the owner for the service, which
+  // replaces the previously registered ones of the service until it is
+  // unregistered.
+  void registerReportFunc(const std::string& service_name, const void* owner,
+                          std::chrono::milliseconds interval,
+                          ReportFunc report_func);
+
+  // Unregisters the report function of the owner for the service. The
+  // previously registered one of the service, if any, is called again.
+  void unregisterReportFunc(const std::string& service_name,
+                            const void* owner);
+
+ private:
+  struct Registration {
+    const void* owner;
+    std::chrono::milliseconds interval;
+    ReportFunc report_func;
+  };
+
+  struct ServiceReports {
+    Envoy::Event::TimerPtr timer;
+    // The last one is the current registration.
+    std::list<Registration> registrations;
+  };
+
+  // Restarts the timer with the interval of the current registration.
+  static void resetTimer(ServiceReports& reports);
+
+  Envoy::Event::Dispatcher& dispatcher_;
+  absl::flat_hash_map<std::string, std::unique_ptr<ServiceReports>> services_;
+};
+
+using StatsReporterSharedPtr = std::shared_ptr<StatsReporter>;
+
+}  // namespace service_control
+}  // namespace http_filters
+}  // namespace envoy
+}  // namespace espv2
@@ -0,0 +1,127 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "src/envoy/http/service_control/stats_reporter.h"
+
+#include "gmock/gmock.h"
+#include "gtest/gtest.h"
+#include "test/mocks/event/mocks.h"
+
+using ::testing::NiceMock;
+
+namespace espv2 {
+namespace envoy {
+namespace http_filters {
+namespace service_control {
+namespace {
+
+TEST(StatsReporterTest, OnlyTheLastRegistrationReports) {
+  NiceMock<Envoy::Event::MockDispatcher> dispatcher;
+  auto* timer = new NiceMock<Envoy::Event::MockTimer>(&dispatcher);
+  StatsReporter reporter(dispatcher);
+
+  int old_reports = 0;
+  int new_reports = 0;
+  const int old_owner = 0;
+  const int new_owner = 0;
+
+  EXPECT_CALL(*timer, enableTimer(std::chrono::milliseconds(1000), nullptr))
+      .Times(2);
+  reporter.registerReportFunc("service", &old_owner,
+                              std::chrono::milliseconds(1000),
+                              [&old_reports]() { ++old_reports; });
+  timer->invokeCallback();
+  EXPECT_EQ(old_reports, 1);
+
+  // A new filter config is created while the old one is draining.
+  EXPECT_CALL(*timer, enableTimer(std::chrono::milliseconds(2000), nullptr))
+      .Times(2);
+  reporter.registerReportFunc("service", &new_owner,
+                              std::chrono::milliseconds(2000),
+                              [&new_reports]() { ++new_reports; });
+  timer->invokeCallback();
+  EXPECT_EQ(old_reports, 1);
+  EXPECT_EQ(new_reports, 1);
+
+  // The old filter config is drained.
+  reporter.unregisterReportFunc("service", &old_owner);
+  EXPECT_CALL(*timer, enableTimer(std::chrono::milliseconds(2000), nullptr));
+  timer->invokeCallback();
+  EXPECT_EQ(old_reports, 1);
+  EXPECT_EQ(new_reports, 2);
+
+  // The timer is removed after all the filter configs are gone.
+  EXPECT_CALL(*timer, disableTimer()).Times(0);
+  reporter.unregisterReportFunc("service", &new_owner);
+}
+
+TEST(StatsReporterTest, PreviousRegistrationReportsAgain) {
+  NiceMock<Envoy::Event::MockDispatcher> dispatcher;
+  auto* timer = new NiceMock<Envoy::Event::MockTimer>(&dispatcher);
+  StatsReporter reporter(dispatcher);
+
+  int old_reports = 0;
+  int new_reports = 0;
+  const int old_owner = 0;
+  const int new_owner = 0;
+
+  reporter.registerReportFunc("service", &old_owner,
+                              std::chrono::milliseconds(1000),
+                              [&old_reports]() { ++old_reports; });
+  reporter.registerReportFunc("service", &new_owner,
+                              std::chrono::milliseconds(1000),
+                              [&new_reports]() { ++new_reports; });
+
+  // The new filter config is rejected and removed.
+  reporter.unregisterReportFunc("service", &new_owner);
+  timer->invokeCallback();
+  EXPECT_EQ(old_reports, 1);
+  EXPECT_EQ(new_reports, 0);
+}
+
+TEST(StatsReporterTest, EachServiceReports) {
+  NiceMock<Envoy::Event::MockDispatcher> dispatcher;
+  StatsReporter reporter(dispatcher);
+
+  int first_reports = 0;
+  int second_reports = 0;
+  const int owner = 0;
+
+  // The same filter config reports the stats of both of its services.
+  auto* first_timer = new NiceMock<Envoy::Event::MockTimer>(&dispatcher);
+  reporter.registerReportFunc("first-service", &owner,
+                              std::chrono::milliseconds(1000),
+                              [&first_reports]() { ++first_reports; });
+  auto* second_timer = new NiceMock<Envoy::Event::MockTimer>(&dispatcher);
+  reporter.registerReportFunc("second-service", &owner,
+                              std::chrono::milliseconds(2000),
+                              [&second_reports]() { ++second_reports; });
+
+  first_timer->invokeCallback();
+  second_timer->invokeCallback();
+  EXPECT_EQ(first_reports, 1);
+  EXPECT_EQ(second_reports, 1);
+
+  // Unregistering one service does not stop the other one.
+  reporter.unregisterReportFunc("first-service", &owner);
+  second_timer->invokeCallback();
+  EXPECT_EQ(first_reports, 1);
+  EXPECT_EQ(second_reports, 2);
+}
+
+}  // namespace
+}  // namespace service_control
+}  // namespace http_filters
+}  // namespace envoy
+}  // namespace espv2
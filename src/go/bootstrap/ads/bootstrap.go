@@ -34,6 +34,21 @@ func CreateBootstrapConfig(opts options.AdsBootstrapperOptions) (string, error)
 	// Parse ADS connect timeout
 	connectTimeoutProto := ptypes.DurationProto(opts.AdsConnectTimeout)
 
+	overloadManager, err := bt.CreateOverloadManager(opts.CommonOptions)
+	if err != nil {
+		return "", err
+	}
+
+	layeredRuntime, err := bt.CreateLayeredRuntime(opts.CommonOptions)
+	if err != nil {
+		return "", err
+	}
+
+	statsSinks, err := bt.CreateStatsSinks(opts.CommonOptions)
+	if err != nil {
+		return "", err
+	}
+
 	bt := &bootstrappb.Bootstrap{
 		// Node info
 		Node: bt.CreateNode(opts.CommonOptions),
@@ -42,7 +57,13 @@ func CreateBootstrapConfig(opts options.AdsBootstrapperOptions) (string, error)
 		Admin: bt.CreateAdmin(opts.CommonOptions),
 
 		// layer runtime
-		LayeredRuntime: bt.CreateLayeredRuntime(),
+		LayeredRuntime: layeredRuntime,
+
+		// overload manager
+		OverloadManager: overloadManager,
+
+		// stats sinks
+		StatsSinks: statsSinks,
 
 		// Dynamic resource
 		DynamicResources: &bootstrappb.Bootstrap_DynamicResources{
@@ -203,6 +203,96 @@ func TestCreateBootstrapConfig(t *testing.T) {
       ]
    }
 }
+`,
+		},
+		{
+			desc: "bootstrap with node id and cluster",
+			args: map[string]string{
+				"node":         "fleet-a-instance-1",
+				"node_cluster": "fleet-a",
+			},
+			wantConfig: `
+{
+   "admin":{
+      "accessLogPath":"/dev/null",
+      "address":{
+         "socketAddress":{
+            "address":"0.0.0.0",
+            "portValue":8001
+         }
+      }
+   },
+   "dynamicResources":{
+      "adsConfig":{
+         "apiType":"GRPC",
+         "grpcServices":[
+            {
+               "envoyGrpc":{
+                  "clusterName":"@espv2-ads-cluster"
+               }
+            }
+         ],
+         "transportApiVersion":"V3"
+      },
+      "cdsConfig":{
+         "ads":{
+            
+         },
+         "resourceApiVersion":"V3"
+      },
+      "ldsConfig":{
+         "ads":{
+            
+         },
+         "resourceApiVersion":"V3"
+      }
+   },
+   "layeredRuntime":{
+      "layers":[
+         {
+            "name": "static-runtime",
+            "staticLayer": {
+              "envoy.reloadable_features.preserve_downstream_scheme": false,
+              "re2.max_program_size.error_level":1000
+            }
+         }
+      ]
+   },
+   "node":{
+      "cluster":"fleet-a",
+      "id":"fleet-a-instance-1"
+   },
+   "staticResources":{
+      "clusters":[
+         {
+            "connectTimeout":"10s",
+            "http2ProtocolOptions":{
+               
+            },
+            "loadAssignment":{
+               "clusterName":"@espv2-ads-cluster",
+               "endpoints":[
+                  {
+                     "lbEndpoints":[
+                        {
+                           "endpoint":{
+                              "address":{
+                                 "pipe":{
+                                    "path":"@espv2-ads-cluster"
+                                 }
+                              }
+                           }
+                        }
+                     ]
+                  }
+               ]
+            },
+            "name":"@espv2-ads-cluster",
+            "type":"STATIC"
+         }
+      ]
+   }
+}
 `,
 		},
 	}
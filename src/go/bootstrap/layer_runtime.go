@@ -15,14 +15,21 @@
 package bootstrap
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+
 	bootstrappb "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 )
 
-// CreateLayeredRuntime outputs LayeredRuntime struct for bootstrap config
-func CreateLayeredRuntime() *bootstrappb.LayeredRuntime {
-
-	return &bootstrappb.LayeredRuntime{
+// CreateLayeredRuntime outputs LayeredRuntime struct for bootstrap config.
+// The values from --envoy_runtime are added as a separate static layer after
+// the default one, so they override the default values.
+func CreateLayeredRuntime(opts options.CommonOptions) (*bootstrappb.LayeredRuntime, error) {
+	layeredRuntime := &bootstrappb.LayeredRuntime{
 		Layers: []*bootstrappb.RuntimeLayer{
 			//
 			{
@@ -47,4 +54,64 @@ func CreateLayeredRuntime() *bootstrappb.LayeredRuntime {
 			},
 		},
 	}
+
+	if opts.EnvoyRuntime == "" {
+		return layeredRuntime, nil
+	}
+
+	fields, err := parseEnvoyRuntime(opts.EnvoyRuntime)
+	if err != nil {
+		return nil, err
+	}
+	layeredRuntime.Layers = append(layeredRuntime.Layers, &bootstrappb.RuntimeLayer{
+		Name: "custom-static-runtime",
+		LayerSpecifier: &bootstrappb.RuntimeLayer_StaticLayer{
+			StaticLayer: &structpb.Struct{
+				Fields: fields,
+			},
+		},
+	})
+	return layeredRuntime, nil
+}
+
+// parseEnvoyRuntime parses runtime values in the format key1=value1;key2=value2.
+// Values of "true" and "false" are booleans, numeric values are numbers, and
+// all other values are strings.
+func parseEnvoyRuntime(envoyRuntime string) (map[string]*structpb.Value, error) {
+	fields := make(map[string]*structpb.Value)
+	for _, kv := range strings.Split(envoyRuntime, ";") {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+			return nil, fmt.Errorf("invalid envoy runtime %q, it should be in the format key=value", kv)
+		}
+
+		key, val := pair[0], pair[1]
+		if _, ok := fields[key]; ok {
+			return nil, fmt.Errorf("envoy runtime key (%v) is set more than once", key)
+		}
+
+		switch {
+		case val == "true" || val == "false":
+			fields[key] = &structpb.Value{
+				Kind: &structpb.Value_BoolValue{
+					BoolValue: val == "true",
+				},
+			}
+		default:
+			if num, err := strconv.ParseFloat(val, 64); err == nil {
+				fields[key] = &structpb.Value{
+					Kind: &structpb.Value_NumberValue{
+						NumberValue: num,
+					},
+				}
+				continue
+			}
+			fields[key] = &structpb.Value{
+				Kind: &structpb.Value_StringValue{
+					StringValue: val,
+				},
+			}
+		}
+	}
+	return fields, nil
 }
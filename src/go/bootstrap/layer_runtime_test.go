@@ -0,0 +1,102 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+func TestCreateLayeredRuntime(t *testing.T) {
+	defaultLayer := `
+    {
+      "name": "static-runtime",
+      "staticLayer": {
+        "envoy.reloadable_features.preserve_downstream_scheme": false,
+        "re2.max_program_size.error_level": 1000
+      }
+    }`
+
+	testData := []struct {
+		desc               string
+		envoyRuntime       string
+		wantLayeredRuntime string
+		wantError          string
+	}{
+		{
+			desc: "Only the default layer without --envoy_runtime",
+			wantLayeredRuntime: `
+{
+  "layers": [` + defaultLayer + `
+  ]
+}`,
+		},
+		{
+			desc:         "Custom layer with bool, number and string values",
+			envoyRuntime: "envoy.reloadable_features.preserve_downstream_scheme=true;re2.max_program_size.warn_level=500;upstream.healthy_panic_threshold=abc",
+			wantLayeredRuntime: `
+{
+  "layers": [` + defaultLayer + `,
+    {
+      "name": "custom-static-runtime",
+      "staticLayer": {
+        "envoy.reloadable_features.preserve_downstream_scheme": true,
+        "re2.max_program_size.warn_level": 500,
+        "upstream.healthy_panic_threshold": "abc"
+      }
+    }
+  ]
+}`,
+		},
+		{
+			desc:         "Missing value is rejected",
+			envoyRuntime: "key1=value1;key2",
+			wantError:    `invalid envoy runtime "key2", it should be in the format key=value`,
+		},
+		{
+			desc:         "Duplicated key is rejected",
+			envoyRuntime: "key1=value1;key1=value2",
+			wantError:    "envoy runtime key (key1) is set more than once",
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultCommonOptions()
+		opts.EnvoyRuntime = tc.envoyRuntime
+
+		got, err := CreateLayeredRuntime(opts)
+		if tc.wantError != "" {
+			if err == nil || err.Error() != tc.wantError {
+				t.Errorf("Test (%s): failed, got error: %v, want error: %v", tc.desc, err, tc.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): failed, got error: %v", tc.desc, err)
+		}
+
+		marshaler := &jsonpb.Marshaler{}
+		gotLayeredRuntime, err := marshaler.MarshalToString(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := util.JsonEqual(tc.wantLayeredRuntime, gotLayeredRuntime); err != nil {
+			t.Errorf("Test (%s): failed, \n %v", tc.desc, err)
+		}
+	}
+}
@@ -24,8 +24,12 @@ import (
 
 // CreateBootstrapConfig outputs Node struct for bootstrap config
 func CreateNode(opts options.CommonOptions) *corepb.Node {
+	cluster := opts.NodeCluster
+	if cluster == "" {
+		cluster = fmt.Sprintf("%s_cluster", opts.Node)
+	}
 	return &corepb.Node{
 		Id:      opts.Node,
-		Cluster: fmt.Sprintf("%s_cluster", opts.Node),
+		Cluster: cluster,
 	}
 }
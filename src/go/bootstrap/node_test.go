@@ -0,0 +1,71 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/golang/protobuf/proto"
+
+	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+func TestCreateNode(t *testing.T) {
+	testData := []struct {
+		desc        string
+		node        string
+		nodeCluster string
+		want        *corepb.Node
+	}{
+		{
+			desc: "Default node",
+			node: "ESPv2",
+			want: &corepb.Node{
+				Id:      "ESPv2",
+				Cluster: "ESPv2_cluster",
+			},
+		},
+		{
+			desc: "Cluster is derived from the node id",
+			node: "fleet-a-instance-1",
+			want: &corepb.Node{
+				Id:      "fleet-a-instance-1",
+				Cluster: "fleet-a-instance-1_cluster",
+			},
+		},
+		{
+			desc:        "Cluster is set",
+			node:        "fleet-a-instance-1",
+			nodeCluster: "fleet-a",
+			want: &corepb.Node{
+				Id:      "fleet-a-instance-1",
+				Cluster: "fleet-a",
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultCommonOptions()
+		opts.Node = tc.node
+		opts.NodeCluster = tc.nodeCluster
+
+		got := CreateNode(opts)
+
+		if !proto.Equal(got, tc.want) {
+			t.Errorf("Test (%s): failed, got: %v, want: %v", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/golang/protobuf/ptypes"
+
+	overloadpb "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	fixedheappb "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/fixed_heap/v3"
+)
+
+// CreateOverloadManager returns the overload manager that sheds load when the
+// heap usage approaches the configured maximum. It returns nil when no
+// maximum heap size is configured.
+func CreateOverloadManager(opts options.CommonOptions) (*overloadpb.OverloadManager, error) {
+	if opts.OverloadMaxHeapSizeBytes == 0 {
+		return nil, nil
+	}
+
+	thresholds := []struct {
+		flag   string
+		action string
+		value  float64
+	}{
+		{
+			flag:   "overload_stop_accepting_requests_threshold",
+			action: util.StopAcceptingRequestsOverloadAction,
+			value:  opts.OverloadStopAcceptingRequestsThreshold,
+		},
+		{
+			flag:   "overload_stop_accepting_connections_threshold",
+			action: util.StopAcceptingConnectionsOverloadAction,
+			value:  opts.OverloadStopAcceptingConnectionsThreshold,
+		},
+	}
+
+	var actions []*overloadpb.OverloadAction
+	for _, threshold := range thresholds {
+		if threshold.value <= 0 || threshold.value > 1 {
+			return nil, fmt.Errorf("flag --%s must be in (0, 1], got %v", threshold.flag, threshold.value)
+		}
+		actions = append(actions, &overloadpb.OverloadAction{
+			Name: threshold.action,
+			Triggers: []*overloadpb.Trigger{
+				{
+					Name: util.FixedHeapResourceMonitor,
+					TriggerOneof: &overloadpb.Trigger_Threshold{
+						Threshold: &overloadpb.ThresholdTrigger{
+							Value: threshold.value,
+						},
+					},
+				},
+			},
+		})
+	}
+
+	fixedHeap, err := ptypes.MarshalAny(&fixedheappb.FixedHeapConfig{
+		MaxHeapSizeBytes: opts.OverloadMaxHeapSizeBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &overloadpb.OverloadManager{
+		ResourceMonitors: []*overloadpb.ResourceMonitor{
+			{
+				Name: util.FixedHeapResourceMonitor,
+				ConfigType: &overloadpb.ResourceMonitor_TypedConfig{
+					TypedConfig: fixedHeap,
+				},
+			},
+		},
+		Actions: actions,
+	}, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+func TestCreateOverloadManager(t *testing.T) {
+	testData := []struct {
+		desc                              string
+		maxHeapSizeBytes                  uint64
+		stopAcceptingRequestsThreshold    float64
+		stopAcceptingConnectionsThreshold float64
+		wantOverloadManager               string
+		wantError                         string
+	}{
+		{
+			desc:                              "Overload manager is disabled by default",
+			stopAcceptingRequestsThreshold:    0.95,
+			stopAcceptingConnectionsThreshold: 0.98,
+		},
+		{
+			desc:                              "Overload manager is enabled with a max heap size",
+			maxHeapSizeBytes:                  1073741824,
+			stopAcceptingRequestsThreshold:    0.9,
+			stopAcceptingConnectionsThreshold: 0.95,
+			wantOverloadManager: `
+{
+  "actions": [
+    {
+      "name": "envoy.overload_actions.stop_accepting_requests",
+      "triggers": [
+        {
+          "name": "envoy.resource_monitors.fixed_heap",
+          "threshold": {
+            "value": 0.9
+          }
+        }
+      ]
+    },
+    {
+      "name": "envoy.overload_actions.stop_accepting_connections",
+      "triggers": [
+        {
+          "name": "envoy.resource_monitors.fixed_heap",
+          "threshold": {
+            "value": 0.95
+          }
+        }
+      ]
+    }
+  ],
+  "resourceMonitors": [
+    {
+      "name": "envoy.resource_monitors.fixed_heap",
+      "typedConfig": {
+        "@type": "type.googleapis.com/envoy.extensions.resource_monitors.fixed_heap.v3.FixedHeapConfig",
+        "maxHeapSizeBytes": "1073741824"
+      }
+    }
+  ]
+}`,
+		},
+		{
+			desc:                              "Threshold above one is rejected",
+			maxHeapSizeBytes:                  1073741824,
+			stopAcceptingRequestsThreshold:    1.5,
+			stopAcceptingConnectionsThreshold: 0.98,
+			wantError:                         "flag --overload_stop_accepting_requests_threshold must be in (0, 1], got 1.5",
+		},
+		{
+			desc:                              "Zero threshold is rejected",
+			maxHeapSizeBytes:                  1073741824,
+			stopAcceptingRequestsThreshold:    0.95,
+			stopAcceptingConnectionsThreshold: 0,
+			wantError:                         "flag --overload_stop_accepting_connections_threshold must be in (0, 1], got 0",
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultCommonOptions()
+		opts.OverloadMaxHeapSizeBytes = tc.maxHeapSizeBytes
+		opts.OverloadStopAcceptingRequestsThreshold = tc.stopAcceptingRequestsThreshold
+		opts.OverloadStopAcceptingConnectionsThreshold = tc.stopAcceptingConnectionsThreshold
+
+		got, err := CreateOverloadManager(opts)
+		if tc.wantError != "" {
+			if err == nil || err.Error() != tc.wantError {
+				t.Errorf("Test (%s): failed, got error: %v, want error: %v", tc.desc, err, tc.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): failed, got error: %v", tc.desc, err)
+		}
+
+		if tc.wantOverloadManager == "" {
+			if got != nil {
+				t.Errorf("Test (%s): failed, got: %v, want: nil", tc.desc, got)
+			}
+			continue
+		}
+
+		marshaler := &jsonpb.Marshaler{}
+		gotOverloadManager, err := marshaler.MarshalToString(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := util.JsonEqual(tc.wantOverloadManager, gotOverloadManager); err != nil {
+			t.Errorf("Test (%s): failed, \n %v", tc.desc, err)
+		}
+	}
+}
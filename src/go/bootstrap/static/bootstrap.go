@@ -30,12 +30,32 @@ import (
 // id is the service configuration ID. It is generated when deploying
 // service config to ServiceManagement Server, example: 2017-02-13r0.
 func ServiceToBootstrapConfig(serviceConfig *confpb.Service, id string, opts options.ConfigGeneratorOptions) (*bootstrappb.Bootstrap, error) {
+	overloadManager, err := bootstrap.CreateOverloadManager(opts.CommonOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	layeredRuntime, err := bootstrap.CreateLayeredRuntime(opts.CommonOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	statsSinks, err := bootstrap.CreateStatsSinks(opts.CommonOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	bt := &bootstrappb.Bootstrap{
-		Node:           bootstrap.CreateNode(opts.CommonOptions),
-		Admin:          bootstrap.CreateAdmin(opts.CommonOptions),
-		LayeredRuntime: bootstrap.CreateLayeredRuntime(),
+		Node:            bootstrap.CreateNode(opts.CommonOptions),
+		Admin:           bootstrap.CreateAdmin(opts.CommonOptions),
+		LayeredRuntime:  layeredRuntime,
+		OverloadManager: overloadManager,
+		StatsSinks:      statsSinks,
 	}
 
+	if err := gen.ResolveClientCrlSdsPath(&opts); err != nil {
+		return nil, err
+	}
 	serviceInfo, err := sc.NewServiceInfoFromServiceConfig(serviceConfig, id, opts)
 	if err != nil {
 		return nil, fmt.Errorf("fail to initialize ServiceInfo, %s", err)
@@ -45,6 +65,17 @@ func ServiceToBootstrapConfig(serviceConfig *confpb.Service, id string, opts opt
 	if err != nil {
 		return nil, err
 	}
+	// Without the config manager, the CRL is only written once and is not
+	// reloaded when --client_crl_file changes.
+	if opts.ClientCrlFile != "" {
+		crl, err := gen.ReadClientCrl(opts.ClientCrlFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := gen.WriteClientCrlSdsFile(opts.ClientCrlSdsPath, crl); err != nil {
+			return nil, err
+		}
+	}
 	listeners, err := gen.MakeListeners(serviceInfo)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,92 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+
+	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	statspb "github.com/envoyproxy/go-control-plane/envoy/config/metrics/v3"
+)
+
+// CreateStatsSinks returns the stats sinks that flush envoy stats to a statsd
+// server. It returns nil when no statsd address is configured.
+func CreateStatsSinks(opts options.CommonOptions) ([]*statspb.StatsSink, error) {
+	if opts.StatsdAddress == "" {
+		return nil, nil
+	}
+
+	host, port, err := net.SplitHostPort(opts.StatsdAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flag --statsd_address %q: %v", opts.StatsdAddress, err)
+	}
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("invalid flag --statsd_address %q: host must be an IP address", opts.StatsdAddress)
+	}
+	portValue, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flag --statsd_address %q: invalid port %q", opts.StatsdAddress, port)
+	}
+
+	address := &corepb.Address{
+		Address: &corepb.Address_SocketAddress{
+			SocketAddress: &corepb.SocketAddress{
+				Protocol: corepb.SocketAddress_UDP,
+				Address:  host,
+				PortSpecifier: &corepb.SocketAddress_PortValue{
+					PortValue: uint32(portValue),
+				},
+			},
+		},
+	}
+
+	name := util.StatsdSink
+	var sink proto.Message = &statspb.StatsdSink{
+		StatsdSpecifier: &statspb.StatsdSink_Address{
+			Address: address,
+		},
+		Prefix: opts.StatsdPrefix,
+	}
+	if opts.EnableDogStatsd {
+		name = util.DogStatsdSink
+		sink = &statspb.DogStatsdSink{
+			DogStatsdSpecifier: &statspb.DogStatsdSink_Address{
+				Address: address,
+			},
+			Prefix: opts.StatsdPrefix,
+		}
+	}
+
+	sinkAny, err := ptypes.MarshalAny(sink)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*statspb.StatsSink{
+		{
+			Name: name,
+			ConfigType: &statspb.StatsSink_TypedConfig{
+				TypedConfig: sinkAny,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/golang/protobuf/jsonpb"
+
+	bootstrappb "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+)
+
+func TestCreateStatsSinks(t *testing.T) {
+	testData := []struct {
+		desc            string
+		statsdAddress   string
+		statsdPrefix    string
+		enableDogStatsd bool
+		wantStatsSinks  string
+		wantError       string
+	}{
+		{
+			desc: "Stats sinks are disabled by default",
+		},
+		{
+			desc:          "Statsd sink with a prefix",
+			statsdAddress: "127.0.0.1:8125",
+			statsdPrefix:  "espv2",
+			wantStatsSinks: `
+{
+  "statsSinks": [
+    {
+      "name": "envoy.stat_sinks.statsd",
+      "typedConfig": {
+        "@type": "type.googleapis.com/envoy.config.metrics.v3.StatsdSink",
+        "address": {
+          "socketAddress": {
+            "protocol": "UDP",
+            "address": "127.0.0.1",
+            "portValue": 8125
+          }
+        },
+        "prefix": "espv2"
+      }
+    }
+  ]
+}`,
+		},
+		{
+			desc:            "DogStatsd sink with an IPv6 address",
+			statsdAddress:   "[::1]:8125",
+			enableDogStatsd: true,
+			wantStatsSinks: `
+{
+  "statsSinks": [
+    {
+      "name": "envoy.stat_sinks.dog_statsd",
+      "typedConfig": {
+        "@type": "type.googleapis.com/envoy.config.metrics.v3.DogStatsdSink",
+        "address": {
+          "socketAddress": {
+            "protocol": "UDP",
+            "address": "::1",
+            "portValue": 8125
+          }
+        }
+      }
+    }
+  ]
+}`,
+		},
+		{
+			desc:          "Address without a port is rejected",
+			statsdAddress: "127.0.0.1",
+			wantError:     `invalid flag --statsd_address "127.0.0.1": address 127.0.0.1: missing port in address`,
+		},
+		{
+			desc:          "Hostname is rejected",
+			statsdAddress: "statsd.local:8125",
+			wantError:     `invalid flag --statsd_address "statsd.local:8125": host must be an IP address`,
+		},
+		{
+			desc:          "Out of range port is rejected",
+			statsdAddress: "127.0.0.1:70000",
+			wantError:     `invalid flag --statsd_address "127.0.0.1:70000": invalid port "70000"`,
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultCommonOptions()
+		opts.StatsdAddress = tc.statsdAddress
+		opts.StatsdPrefix = tc.statsdPrefix
+		opts.EnableDogStatsd = tc.enableDogStatsd
+
+		got, err := CreateStatsSinks(opts)
+		if tc.wantError != "" {
+			if err == nil || err.Error() != tc.wantError {
+				t.Errorf("Test (%s): failed, got error: %v, want error: %v", tc.desc, err, tc.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): failed, got error: %v", tc.desc, err)
+		}
+
+		if tc.wantStatsSinks == "" {
+			if got != nil {
+				t.Errorf("Test (%s): failed, got: %v, want: nil", tc.desc, got)
+			}
+			continue
+		}
+
+		marshaler := &jsonpb.Marshaler{}
+		gotStatsSinks, err := marshaler.MarshalToString(&bootstrappb.Bootstrap{
+			StatsSinks: got,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := util.JsonEqual(tc.wantStatsSinks, gotStatsSinks); err != nil {
+			t.Errorf("Test (%s): failed, \n %v", tc.desc, err)
+		}
+	}
+}
@@ -27,12 +27,13 @@ var (
 	// Any flags in this file are used by both the ADS Bootstrapper (startup) and Config Generation via the static bootstrapper or config manager.
 	// These flags are kept in sync with options.CommonOptions.
 	// When adding or changing default values, update options.DefaultCommonOptions.
-	AdminAddress               = flag.String("admin_address", "0.0.0.0", "Address that envoy should serve the admin page on. Supports both ipv4 and ipv6 addresses.")
+	AdminAddress               = flag.String("admin_address", "0.0.0.0", "Address that envoy should serve the admin page on. Supports both ipv4 and ipv6 addresses. Use a loopback address, e.g. 127.0.0.1, to refuse external access to the unauthenticated admin page.")
 	AdsNamedPipe               = flag.String("ads_named_pipe", "@espv2-ads-cluster", "Unix domain socket to use internally for xDs between config manager and envoy.")
 	DisableTracing             = flag.Bool("disable_tracing", false, `Disable stackdriver tracing`)
 	AdminPort                  = flag.Int("admin_port", 8001, "Enables envoy's admin interface on this port if it is not 0. Not recommended for production use-cases, as the admin port is unauthenticated.")
 	HttpRequestTimeoutS        = flag.Int("http_request_timeout_s", 30, `Set the timeout in second for all requests. Must be > 0 and the default is 30 seconds if not set.`)
-	Node                       = flag.String("node", "ESPv2", "envoy node id")
+	Node                       = flag.String("node", "ESPv2", "envoy node id, also used by the config manager to serve the xDS config to envoy")
+	NodeCluster                = flag.String("node_cluster", "", "envoy node cluster. If empty, it is the node id with the suffix \"_cluster\".")
 	NonGCP                     = flag.Bool("non_gcp", false, `By default, the proxy tries to talk to GCP metadata server to get VM location in the first few requests. Setting this flag to true to skip this step`)
 	GeneratedHeaderPrefix      = flag.String("generated_header_prefix", "X-Endpoint-", "Set the header prefix for the generated headers. By default, it is `X-Endpoint-`")
 	TracingProjectId           = flag.String("tracing_project_id", "", "The Google project id required for Stack driver tracing. If not set, will automatically use fetch it from GCP Metadata server")
@@ -45,6 +46,17 @@ var (
 	TracingMaxNumMessageEvents = flag.Int64("tracing_max_num_message_events", 128, "Sets the maximum number of message events that each span can contain. Defaults to the maximum allowed by Stackdriver. In practice, the number of message events published will be much less.")
 	TracingMaxNumLinks         = flag.Int64("tracing_max_num_links", 128, "Sets the maximum number of links that each span can contain. Defaults to the maximum allowed by Stackdriver. In practice, the number of links published will be much less.")
 
+	EnvoyRuntime = flag.String("envoy_runtime", "", `Static Envoy runtime values to add to the bootstrap, separated by semicolons. For example, --envoy_runtime=key1=value1;key2=value2.
+	Values of "true" and "false" are set as booleans, numeric values as numbers, and other values as strings. They override the default runtime values set by ESPv2.`)
+
+	OverloadMaxHeapSizeBytes                  = flag.Uint64("overload_max_heap_size_bytes", 0, "Enables the envoy overload manager if it is not 0. The heap usage of envoy is compared against this size to decide when to shed load.")
+	OverloadStopAcceptingRequestsThreshold    = flag.Float64("overload_stop_accepting_requests_threshold", 0.95, "The fraction of --overload_max_heap_size_bytes above which new requests are rejected with 503.")
+	OverloadStopAcceptingConnectionsThreshold = flag.Float64("overload_stop_accepting_connections_threshold", 0.98, "The fraction of --overload_max_heap_size_bytes above which new downstream connections are no longer accepted.")
+
+	StatsdAddress   = flag.String("statsd_address", "", `If set, envoy flushes its stats to the statsd server at this UDP address, e.g. "127.0.0.1:8125". The host must be an IP address.`)
+	StatsdPrefix    = flag.String("statsd_prefix", "", `The prefix of the stats sent to the statsd server. If empty, envoy uses "envoy".`)
+	EnableDogStatsd = flag.Bool("enable_dog_statsd", false, "If enabled, the stats are sent to the statsd server in the DogStatsD format, with tags.")
+
 	//Suspected Envoy has listener initialization bug: if a http filter needs to use
 	//a cluster with DSN lookup for initialization, e.g. fetching a remote access
 	//token, the cluster is not ready so the whole listener is destroyed. ADS will
@@ -70,8 +82,10 @@ func DefaultCommonOptionsFromFlags() options.CommonOptions {
 		DisableTracing:             *DisableTracing,
 		HttpRequestTimeout:         time.Duration(*HttpRequestTimeoutS) * time.Second,
 		Node:                       *Node,
+		NodeCluster:                *NodeCluster,
 		NonGCP:                     *NonGCP,
 		GeneratedHeaderPrefix:      *GeneratedHeaderPrefix,
+		EnvoyRuntime:               *EnvoyRuntime,
 		TracingProjectId:           *TracingProjectId,
 		TracingStackdriverAddress:  *TracingStackdriverAddress,
 		TracingSamplingRate:        *TracingSamplingRate,
@@ -83,6 +97,14 @@ func DefaultCommonOptionsFromFlags() options.CommonOptions {
 		TracingMaxNumLinks:         *TracingMaxNumLinks,
 		MetadataURL:                *MetadataURL,
 		IamURL:                     *IamURL,
+
+		OverloadMaxHeapSizeBytes:                  *OverloadMaxHeapSizeBytes,
+		OverloadStopAcceptingRequestsThreshold:    *OverloadStopAcceptingRequestsThreshold,
+		OverloadStopAcceptingConnectionsThreshold: *OverloadStopAcceptingConnectionsThreshold,
+
+		StatsdAddress:   *StatsdAddress,
+		StatsdPrefix:    *StatsdPrefix,
+		EnableDogStatsd: *EnableDogStatsd,
 	}
 	if *BackendAuthIamServiceAccount != "" {
 		opts.BackendAuthCredentials = &options.IAMCredentialsOptions{
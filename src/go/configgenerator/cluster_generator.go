@@ -16,6 +16,8 @@ package configgenerator
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
@@ -26,6 +28,16 @@ import (
 	sc "github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	clusterpb "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	httpprotocolpb "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	typepb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	anypb "github.com/golang/protobuf/ptypes/any"
+	wrapperspb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+const (
+	// Health checks of backends with a fallback backend.
+	backendHealthCheckTimeout  = 1 * time.Second
+	backendHealthCheckInterval = 5 * time.Second
 )
 
 // MakeClusters provides dynamic cluster settings for Envoy
@@ -79,6 +91,14 @@ func MakeClusters(serviceInfo *sc.ServiceInfo) ([]*clusterpb.Cluster, error) {
 		clusters = append(clusters, scCluster)
 	}
 
+	alsCluster, err := makeAccessLogGrpcCluster(serviceInfo)
+	if err != nil {
+		return nil, err
+	}
+	if alsCluster != nil {
+		clusters = append(clusters, alsCluster)
+	}
+
 	brClusters, err := makeRemoteBackendClusters(serviceInfo)
 	if err != nil {
 		return nil, err
@@ -242,22 +262,63 @@ func makeJwtProviderClusters(serviceInfo *sc.ServiceInfo) ([]*clusterpb.Cluster,
 }
 
 func makeBackendCluster(opt *options.ConfigGeneratorOptions, brc *sc.BackendRoutingCluster) (*clusterpb.Cluster, error) {
+	connectTimeout := opt.ClusterConnectTimeout
+	if brc.ConnectTimeout != 0 {
+		connectTimeout = brc.ConnectTimeout
+	} else if opt.BackendConnectTimeout != 0 {
+		connectTimeout = opt.BackendConnectTimeout
+	}
+
 	c := &clusterpb.Cluster{
 		Name:                 brc.ClusterName,
 		LbPolicy:             clusterpb.Cluster_ROUND_ROBIN,
-		ConnectTimeout:       ptypes.DurationProto(opt.ClusterConnectTimeout),
+		ConnectTimeout:       ptypes.DurationProto(connectTimeout),
 		ClusterDiscoveryType: &clusterpb.Cluster_Type{Type: clusterpb.Cluster_LOGICAL_DNS},
 		LoadAssignment:       util.CreateLoadAssignment(brc.Hostname, brc.Port),
 	}
 
+	if brc.FallbackHostname != "" {
+		// LOGICAL_DNS clusters only support a single endpoint, and the failover
+		// to the fallback backend is driven by health checks of both.
+		c.ClusterDiscoveryType = &clusterpb.Cluster_Type{Type: clusterpb.Cluster_STRICT_DNS}
+		c.LoadAssignment = util.CreateFailoverLoadAssignment(brc.Hostname, brc.Port, brc.FallbackHostname, brc.FallbackPort)
+		hc := &corepb.HealthCheck{
+			Timeout:            ptypes.DurationProto(backendHealthCheckTimeout),
+			Interval:           ptypes.DurationProto(backendHealthCheckInterval),
+			UnhealthyThreshold: &wrapperspb.UInt32Value{Value: 2},
+			HealthyThreshold:   &wrapperspb.UInt32Value{Value: 1},
+			HealthChecker: &corepb.HealthCheck_TcpHealthCheck_{
+				TcpHealthCheck: &corepb.HealthCheck_TcpHealthCheck{},
+			},
+		}
+		if opt.BackendHealthCheckPath != "" {
+			httpHc, err := makeBackendHttpHealthCheck(opt, brc)
+			if err != nil {
+				return nil, err
+			}
+			hc.HealthChecker = &corepb.HealthCheck_HttpHealthCheck_{
+				HttpHealthCheck: httpHc,
+			}
+		}
+		c.HealthChecks = []*corepb.HealthCheck{hc}
+	}
+
 	isHttp2 := brc.Protocol == util.GRPC || brc.Protocol == util.HTTP2
 
 	if brc.UseTLS {
 		var alpnProtocols []string
-		if isHttp2 {
+		if brc.Alpn == util.AutoAlpn {
+			alpnProtocols = []string{"h2", "http/1.1"}
+		} else if brc.Alpn != "" {
+			alpnProtocols = []string{brc.Alpn}
+		} else if isHttp2 {
 			alpnProtocols = []string{"h2"}
 		}
-		transportSocket, err := util.CreateUpstreamTransportSocket(brc.Hostname, opt.SslBackendClientRootCertsPath, opt.SslBackendClientCertPath, alpnProtocols, opt.SslBackendClientCipherSuites)
+		sni := brc.Hostname
+		if brc.Sni != "" {
+			sni = brc.Sni
+		}
+		transportSocket, err := util.CreateUpstreamTransportSocket(sni, opt.SslBackendClientRootCertsPath, opt.SslBackendClientCertPath, alpnProtocols, opt.SslBackendClientCipherSuites)
 		if err != nil {
 			return nil, fmt.Errorf("error marshaling tls context to transport_socket config for cluster %s, err=%v",
 				brc.ClusterName, err)
@@ -265,7 +326,23 @@ func makeBackendCluster(opt *options.ConfigGeneratorOptions, brc *sc.BackendRout
 		c.TransportSocket = transportSocket
 	}
 
-	if isHttp2 {
+	if brc.Alpn == util.AutoAlpn {
+		// Envoy uses the protocol selected by the backend with ALPN.
+		protocolOptions, err := ptypes.MarshalAny(&httpprotocolpb.HttpProtocolOptions{
+			UpstreamProtocolOptions: &httpprotocolpb.HttpProtocolOptions_AutoConfig{
+				AutoConfig: &httpprotocolpb.HttpProtocolOptions_AutoHttpConfig{
+					HttpProtocolOptions:  &corepb.Http1ProtocolOptions{},
+					Http2ProtocolOptions: &corepb.Http2ProtocolOptions{},
+				},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling http protocol options for cluster %s, err=%v", brc.ClusterName, err)
+		}
+		c.TypedExtensionProtocolOptions = map[string]*anypb.Any{
+			util.UpstreamHttpProtocolOptions: protocolOptions,
+		}
+	} else if isHttp2 {
 		c.Http2ProtocolOptions = &corepb.Http2ProtocolOptions{}
 	}
 
@@ -282,6 +359,33 @@ func makeBackendCluster(opt *options.ConfigGeneratorOptions, brc *sc.BackendRout
 	return c, nil
 }
 
+func makeBackendHttpHealthCheck(opt *options.ConfigGeneratorOptions, brc *sc.BackendRoutingCluster) (*corepb.HealthCheck_HttpHealthCheck, error) {
+	if !strings.HasPrefix(opt.BackendHealthCheckPath, "/") {
+		return nil, fmt.Errorf("flag --backend_health_check_path must start with /, got %q", opt.BackendHealthCheckPath)
+	}
+	hc := &corepb.HealthCheck_HttpHealthCheck{
+		Path: opt.BackendHealthCheckPath,
+		Host: opt.BackendHealthCheckHost,
+	}
+	if brc.Protocol == util.GRPC || brc.Protocol == util.HTTP2 {
+		hc.CodecClientType = typepb.CodecClientType_HTTP2
+	}
+	if opt.BackendHealthCheckExpectedStatuses != "" {
+		for _, status := range strings.Split(opt.BackendHealthCheckExpectedStatuses, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(status))
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid status code %q in --backend_health_check_expected_statuses", status)
+			}
+			// The range end is exclusive.
+			hc.ExpectedStatuses = append(hc.ExpectedStatuses, &typepb.Int64Range{
+				Start: int64(code),
+				End:   int64(code + 1),
+			})
+		}
+	}
+	return hc, nil
+}
+
 func makeLocalBackendCluster(serviceInfo *sc.ServiceInfo) (*clusterpb.Cluster, error) {
 	c, err := makeBackendCluster(&serviceInfo.Options, serviceInfo.LocalBackendCluster)
 	if err != nil {
@@ -333,6 +437,47 @@ func makeServiceControlCluster(serviceInfo *sc.ServiceInfo) (*clusterpb.Cluster,
 	return c, nil
 }
 
+// makeAccessLogGrpcCluster makes the cluster of the gRPC access log service
+// set by --access_log_grpc_address.
+func makeAccessLogGrpcCluster(serviceInfo *sc.ServiceInfo) (*clusterpb.Cluster, error) {
+	address := serviceInfo.Options.AccessLogGrpcAddress
+	if address == "" {
+		return nil, nil
+	}
+	scheme, hostname, port, path, err := util.ParseURI(address)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing access log gRPC address: %v", err)
+	}
+	if path != "" {
+		return nil, fmt.Errorf("flag --access_log_grpc_address should not have path part, got %q", address)
+	}
+	protocol, tls, err := util.ParseBackendProtocol(scheme, "")
+	if err != nil || protocol != util.GRPC {
+		return nil, fmt.Errorf("flag --access_log_grpc_address must use the grpc or grpcs scheme, got %q", address)
+	}
+
+	c := &clusterpb.Cluster{
+		Name:                 util.AccessLogGrpcClusterName,
+		LbPolicy:             clusterpb.Cluster_ROUND_ROBIN,
+		ConnectTimeout:       ptypes.DurationProto(serviceInfo.Options.ClusterConnectTimeout),
+		DnsLookupFamily:      clusterpb.Cluster_V4_ONLY,
+		ClusterDiscoveryType: &clusterpb.Cluster_Type{Type: clusterpb.Cluster_LOGICAL_DNS},
+		LoadAssignment:       util.CreateLoadAssignment(hostname, port),
+		Http2ProtocolOptions: &corepb.Http2ProtocolOptions{},
+	}
+
+	if tls {
+		transportSocket, err := util.CreateUpstreamTransportSocket(hostname, serviceInfo.Options.SslSidestreamClientRootCertsPath, "", []string{"h2"}, "")
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling tls context to transport_socket config for cluster %s, err=%v",
+				c.Name, err)
+		}
+		c.TransportSocket = transportSocket
+	}
+
+	return c, nil
+}
+
 func makeRemoteBackendClusters(serviceInfo *sc.ServiceInfo) ([]*clusterpb.Cluster, error) {
 	var brClusters []*clusterpb.Cluster
 
@@ -29,6 +29,9 @@ import (
 
 	clusterpb "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	httpprotocolpb "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	typepb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	wrapperspb "github.com/golang/protobuf/ptypes/wrappers"
 	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 	apipb "google.golang.org/genproto/protobuf/api"
@@ -449,6 +452,40 @@ func TestMakeBackendRoutingCluster(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "Success for IPv6 backend",
+			fakeServiceConfig: &confpb.Service{
+				Name: testProjectName,
+				Apis: []*apipb.Api{
+					{
+						Name: "1.cloudesf_testing_cloud_goog",
+						Methods: []*apipb.Method{
+							{
+								Name: "Foo",
+							},
+						},
+					},
+				},
+				Backend: &confpb.Backend{
+					Rules: []*confpb.BackendRule{
+						{
+							Address:         "http://[2001:db8::1]:8080",
+							Selector:        "1.cloudesf_testing_cloud_goog.Foo",
+							PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+						},
+					},
+				},
+			},
+			BackendAddress: "http://[::1]:80",
+			wantedClusters: []*clusterpb.Cluster{
+				{
+					Name:                 "backend-cluster-[2001:db8::1]:8080",
+					ConnectTimeout:       ptypes.DurationProto(20 * time.Second),
+					ClusterDiscoveryType: &clusterpb.Cluster_Type{Type: clusterpb.Cluster_LOGICAL_DNS},
+					LoadAssignment:       util.CreateLoadAssignment("2001:db8::1", 8080),
+				},
+			},
+		},
 		{
 			desc:                   "Failure, providing incorrect backend_dns_lookup_family flag",
 			backendDnsLookupFamily: "v5only",
@@ -714,6 +751,88 @@ func TestMakeIamCluster(t *testing.T) {
 	}
 }
 
+func TestMakeAccessLogGrpcCluster(t *testing.T) {
+	testData := []struct {
+		desc                 string
+		accessLogGrpcAddress string
+		wantedCluster        *clusterpb.Cluster
+		wantedError          string
+	}{
+		{
+			desc: "Success, not generate an access log cluster by default",
+		},
+		{
+			desc:                 "Success, generate an access log cluster with grpc",
+			accessLogGrpcAddress: "grpc://127.0.0.1:9001",
+			wantedCluster: &clusterpb.Cluster{
+				Name:                 util.AccessLogGrpcClusterName,
+				ConnectTimeout:       ptypes.DurationProto(20 * time.Second),
+				DnsLookupFamily:      clusterpb.Cluster_V4_ONLY,
+				ClusterDiscoveryType: &clusterpb.Cluster_Type{Type: clusterpb.Cluster_LOGICAL_DNS},
+				LoadAssignment:       util.CreateLoadAssignment("127.0.0.1", 9001),
+				Http2ProtocolOptions: &corepb.Http2ProtocolOptions{},
+			},
+		},
+		{
+			desc:                 "Success, generate an access log cluster with grpcs",
+			accessLogGrpcAddress: "grpcs://als.example.com",
+			wantedCluster: &clusterpb.Cluster{
+				Name:                 util.AccessLogGrpcClusterName,
+				ConnectTimeout:       ptypes.DurationProto(20 * time.Second),
+				DnsLookupFamily:      clusterpb.Cluster_V4_ONLY,
+				ClusterDiscoveryType: &clusterpb.Cluster_Type{Type: clusterpb.Cluster_LOGICAL_DNS},
+				LoadAssignment:       util.CreateLoadAssignment("als.example.com", 443),
+				Http2ProtocolOptions: &corepb.Http2ProtocolOptions{},
+				TransportSocket:      createH2TransportSocket("als.example.com"),
+			},
+		},
+		{
+			desc:                 "Fail, the access log service must use gRPC",
+			accessLogGrpcAddress: "https://als.example.com",
+			wantedError:          `flag --access_log_grpc_address must use the grpc or grpcs scheme, got "https://als.example.com"`,
+		},
+		{
+			desc:                 "Fail, the access log address has a path",
+			accessLogGrpcAddress: "grpc://als.example.com:9001/logs",
+			wantedError:          `flag --access_log_grpc_address should not have path part, got "grpc://als.example.com:9001/logs"`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.AccessLogGrpcAddress = tc.accessLogGrpcAddress
+
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(&confpb.Service{
+				Name: testProjectName,
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+					},
+				},
+			}, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cluster, err := makeAccessLogGrpcCluster(fakeServiceInfo)
+			if tc.wantedError != "" {
+				if err == nil || err.Error() != tc.wantedError {
+					t.Fatalf("got error %v, want %v", err, tc.wantedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !proto.Equal(cluster, tc.wantedCluster) {
+				t.Errorf("got: %v,\nwant: %v", cluster, tc.wantedCluster)
+			}
+		})
+	}
+}
+
 func TestMakeTokenAgentCluster(t *testing.T) {
 	fakeServiceInfo, _ := configinfo.NewServiceInfoFromServiceConfig(&confpb.Service{
 		Apis: []*apipb.Api{
@@ -738,3 +857,304 @@ func TestMakeTokenAgentCluster(t *testing.T) {
 		t.Errorf("Test makeTokenAgentClusters, \ngot: %v,\nwant: %v", cluster, wantCluster)
 	}
 }
+
+func TestMakeLocalBackendClusterWithFallback(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendAddress = "http://127.0.0.1:8082"
+	opts.BackendFallbackAddress = "http://read-only.example.com:8080"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotCluster, err := makeLocalBackendCluster(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCluster := &clusterpb.Cluster{
+		Name:                 "backend-cluster-bookstore.endpoints.project123.cloud.goog_local",
+		LbPolicy:             clusterpb.Cluster_ROUND_ROBIN,
+		ConnectTimeout:       ptypes.DurationProto(20 * time.Second),
+		ClusterDiscoveryType: &clusterpb.Cluster_Type{Type: clusterpb.Cluster_STRICT_DNS},
+		LoadAssignment:       util.CreateFailoverLoadAssignment("127.0.0.1", 8082, "read-only.example.com", 8080),
+		HealthChecks: []*corepb.HealthCheck{
+			{
+				Timeout:            ptypes.DurationProto(time.Second),
+				Interval:           ptypes.DurationProto(5 * time.Second),
+				UnhealthyThreshold: &wrapperspb.UInt32Value{Value: 2},
+				HealthyThreshold:   &wrapperspb.UInt32Value{Value: 1},
+				HealthChecker: &corepb.HealthCheck_TcpHealthCheck_{
+					TcpHealthCheck: &corepb.HealthCheck_TcpHealthCheck{},
+				},
+			},
+		},
+	}
+	if !proto.Equal(gotCluster, wantCluster) {
+		t.Errorf("makeLocalBackendCluster got: %v, want: %v", gotCluster, wantCluster)
+	}
+
+	fallback := gotCluster.LoadAssignment.Endpoints[1]
+	if fallback.Priority != 1 {
+		t.Errorf("fallback endpoint priority got: %v, want: 1", fallback.Priority)
+	}
+	if got := fallback.LbEndpoints[0].Metadata.FilterMetadata[util.BackendFallbackMetadataNamespace].Fields[util.BackendFallbackMetadataKey].GetStringValue(); got != "true" {
+		t.Errorf("fallback endpoint metadata got: %v, want: true", got)
+	}
+}
+
+func TestMakeBackendClusterConnectTimeout(t *testing.T) {
+	testData := []struct {
+		desc                  string
+		backendConnectTimeout time.Duration
+		clusterTimeout        time.Duration
+		wantConnectTimeout    time.Duration
+	}{
+		{
+			desc:               "cluster connect timeout by default",
+			wantConnectTimeout: 20 * time.Second,
+		},
+		{
+			desc:                  "backend connect timeout overrides the cluster connect timeout",
+			backendConnectTimeout: 30 * time.Second,
+			wantConnectTimeout:    30 * time.Second,
+		},
+		{
+			desc:                  "per-operation connect timeout overrides the backend connect timeout",
+			backendConnectTimeout: 30 * time.Second,
+			clusterTimeout:        time.Minute,
+			wantConnectTimeout:    time.Minute,
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.BackendConnectTimeout = tc.backendConnectTimeout
+
+		gotCluster, err := makeBackendCluster(&opts, &configinfo.BackendRoutingCluster{
+			ClusterName:    "backend-cluster-api.example.com:443",
+			Hostname:       "api.example.com",
+			Port:           443,
+			ConnectTimeout: tc.clusterTimeout,
+		})
+		if err != nil {
+			t.Fatalf("Test (%s): makeBackendCluster got error: %v", tc.desc, err)
+		}
+
+		if want := ptypes.DurationProto(tc.wantConnectTimeout); !proto.Equal(gotCluster.ConnectTimeout, want) {
+			t.Errorf("Test (%s): makeBackendCluster got connect timeout: %v, want: %v", tc.desc, gotCluster.ConnectTimeout, want)
+		}
+	}
+}
+
+func TestMakeBackendClusterSni(t *testing.T) {
+	testData := []struct {
+		desc    string
+		sni     string
+		wantSni string
+	}{
+		{
+			desc:    "hostname as SNI by default",
+			wantSni: "api.example.com",
+		},
+		{
+			desc:    "per-operation SNI overrides the hostname",
+			sni:     "backend.example.com",
+			wantSni: "backend.example.com",
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultConfigGeneratorOptions()
+
+		gotCluster, err := makeBackendCluster(&opts, &configinfo.BackendRoutingCluster{
+			ClusterName: "backend-cluster-api.example.com:443",
+			Hostname:    "api.example.com",
+			Port:        443,
+			UseTLS:      true,
+			Sni:         tc.sni,
+		})
+		if err != nil {
+			t.Fatalf("Test (%s): makeBackendCluster got error: %v", tc.desc, err)
+		}
+
+		if want := createTransportSocket(tc.wantSni); !proto.Equal(gotCluster.TransportSocket, want) {
+			t.Errorf("Test (%s): makeBackendCluster got transport socket: %v, want: %v", tc.desc, gotCluster.TransportSocket, want)
+		}
+	}
+}
+
+func TestMakeBackendClusterAlpn(t *testing.T) {
+	testData := []struct {
+		desc              string
+		protocol          util.BackendProtocol
+		alpn              string
+		wantAlpnProtocols []string
+		wantHttp2         bool
+		wantAutoConfig    bool
+	}{
+		{
+			desc:     "no ALPN for HTTP/1.1 by default",
+			protocol: util.HTTP1,
+		},
+		{
+			desc:              "h2 ALPN for HTTP/2 by default",
+			protocol:          util.HTTP2,
+			wantAlpnProtocols: []string{"h2"},
+			wantHttp2:         true,
+		},
+		{
+			desc:              "per-operation ALPN for HTTP/1.1",
+			protocol:          util.HTTP1,
+			alpn:              "http/1.1",
+			wantAlpnProtocols: []string{"http/1.1"},
+		},
+		{
+			desc:              "auto ALPN advertises both protocols",
+			protocol:          util.HTTP1,
+			alpn:              "auto",
+			wantAlpnProtocols: []string{"h2", "http/1.1"},
+			wantAutoConfig:    true,
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultConfigGeneratorOptions()
+
+		gotCluster, err := makeBackendCluster(&opts, &configinfo.BackendRoutingCluster{
+			ClusterName: "backend-cluster-api.example.com:443",
+			Hostname:    "api.example.com",
+			Port:        443,
+			UseTLS:      true,
+			Protocol:    tc.protocol,
+			Alpn:        tc.alpn,
+		})
+		if err != nil {
+			t.Fatalf("Test (%s): makeBackendCluster got error: %v", tc.desc, err)
+		}
+
+		want, _ := util.CreateUpstreamTransportSocket("api.example.com", util.DefaultRootCAPaths, "", tc.wantAlpnProtocols, "")
+		if !proto.Equal(gotCluster.TransportSocket, want) {
+			t.Errorf("Test (%s): makeBackendCluster got transport socket: %v, want: %v", tc.desc, gotCluster.TransportSocket, want)
+		}
+		if gotHttp2 := gotCluster.Http2ProtocolOptions != nil; gotHttp2 != tc.wantHttp2 {
+			t.Errorf("Test (%s): makeBackendCluster got HTTP/2: %v, want: %v", tc.desc, gotHttp2, tc.wantHttp2)
+		}
+
+		gotAutoConfig := false
+		if protocolOptions, ok := gotCluster.TypedExtensionProtocolOptions[util.UpstreamHttpProtocolOptions]; ok {
+			httpProtocolOptions := &httpprotocolpb.HttpProtocolOptions{}
+			if err := ptypes.UnmarshalAny(protocolOptions, httpProtocolOptions); err != nil {
+				t.Fatalf("Test (%s): fail to unmarshal http protocol options: %v", tc.desc, err)
+			}
+			gotAutoConfig = httpProtocolOptions.GetAutoConfig() != nil
+		}
+		if gotAutoConfig != tc.wantAutoConfig {
+			t.Errorf("Test (%s): makeBackendCluster got auto protocol config: %v, want: %v", tc.desc, gotAutoConfig, tc.wantAutoConfig)
+		}
+	}
+}
+
+func TestMakeLocalBackendClusterWithHttpHealthCheck(t *testing.T) {
+	testData := []struct {
+		desc             string
+		backendAddress   string
+		healthCheckPath  string
+		healthCheckHost  string
+		expectedStatuses string
+		wantHealthCheck  *corepb.HealthCheck_HttpHealthCheck
+		wantError        string
+	}{
+		{
+			desc:            "Path and Host header of the health check",
+			backendAddress:  "http://127.0.0.1:8082",
+			healthCheckPath: "/healthz",
+			healthCheckHost: "health.example.com",
+			wantHealthCheck: &corepb.HealthCheck_HttpHealthCheck{
+				Path: "/healthz",
+				Host: "health.example.com",
+			},
+		},
+		{
+			desc:             "Expected statuses of the health check of a gRPC backend",
+			backendAddress:   "grpc://127.0.0.1:8082",
+			healthCheckPath:  "/healthz",
+			expectedStatuses: "200, 204",
+			wantHealthCheck: &corepb.HealthCheck_HttpHealthCheck{
+				Path: "/healthz",
+				ExpectedStatuses: []*typepb.Int64Range{
+					{
+						Start: 200,
+						End:   201,
+					},
+					{
+						Start: 204,
+						End:   205,
+					},
+				},
+				CodecClientType: typepb.CodecClientType_HTTP2,
+			},
+		},
+		{
+			desc:            "Path without the leading slash",
+			backendAddress:  "http://127.0.0.1:8082",
+			healthCheckPath: "healthz",
+			wantError:       `flag --backend_health_check_path must start with /, got "healthz"`,
+		},
+		{
+			desc:             "Invalid expected status",
+			backendAddress:   "http://127.0.0.1:8082",
+			healthCheckPath:  "/healthz",
+			expectedStatuses: "200,2xx",
+			wantError:        `invalid status code "2xx" in --backend_health_check_expected_statuses`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Name: testProjectName,
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+					},
+				},
+			}
+
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = tc.backendAddress
+			opts.BackendFallbackAddress = strings.Replace(tc.backendAddress, "127.0.0.1:8082", "read-only.example.com:8080", 1)
+			opts.BackendHealthCheckPath = tc.healthCheckPath
+			opts.BackendHealthCheckHost = tc.healthCheckHost
+			opts.BackendHealthCheckExpectedStatuses = tc.expectedStatuses
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotCluster, err := makeLocalBackendCluster(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := gotCluster.HealthChecks[0].GetHttpHealthCheck(); !proto.Equal(got, tc.wantHealthCheck) {
+				t.Errorf("makeLocalBackendCluster got health check: %v, want: %v", got, tc.wantHealthCheck)
+			}
+		})
+	}
+}
@@ -31,9 +31,17 @@ import (
 )
 
 var baPerRouteFilterConfigGen = func(method *ci.MethodInfo, httpRule *httppattern.Pattern) (*anypb.Any, error) {
-	auPerRoute := &aupb.PerRouteFilterConfig{
-		JwtAudience: method.BackendInfo.JwtAudience,
+	auPerRoute := &aupb.PerRouteFilterConfig{}
+	if method.BackendInfo.StaticTokenFile != "" {
+		auPerRoute.TokenSource = &aupb.PerRouteFilterConfig_StaticTokenFile{
+			StaticTokenFile: method.BackendInfo.StaticTokenFile,
+		}
+	} else {
+		auPerRoute.TokenSource = &aupb.PerRouteFilterConfig_JwtAudience{
+			JwtAudience: method.BackendInfo.JwtAudience,
+		}
 	}
+	auPerRoute.TokenHeader = method.BackendInfo.TokenHeader
 	aupr, err := ptypes.MarshalAny(auPerRoute)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling backend_auth per-route config to Any: %v", err)
@@ -42,17 +50,24 @@ var baPerRouteFilterConfigGen = func(method *ci.MethodInfo, httpRule *httppatter
 }
 
 var baFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*ci.MethodInfo, error) {
-	// Use map to collect list of unique jwt audiences.
+	// Use map to collect list of unique jwt audiences and static token files.
 	var perRouteConfigRequiredMethods []*ci.MethodInfo
 	audMap := make(map[string]bool)
+	tokenFileMap := make(map[string]bool)
 	for _, method := range serviceInfo.Methods {
-		if method.BackendInfo != nil && method.BackendInfo.JwtAudience != "" {
+		if method.BackendInfo == nil {
+			continue
+		}
+		if method.BackendInfo.StaticTokenFile != "" {
+			tokenFileMap[method.BackendInfo.StaticTokenFile] = true
+			perRouteConfigRequiredMethods = append(perRouteConfigRequiredMethods, method)
+		} else if method.BackendInfo.JwtAudience != "" {
 			audMap[method.BackendInfo.JwtAudience] = true
 			perRouteConfigRequiredMethods = append(perRouteConfigRequiredMethods, method)
 		}
 	}
-	// If audMap is empty, not need to add the filter.
-	if len(audMap) == 0 {
+	// If both maps are empty, not need to add the filter.
+	if len(audMap) == 0 && len(tokenFileMap) == 0 {
 		return nil, nil, nil
 	}
 
@@ -60,10 +75,16 @@ var baFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 	for aud := range audMap {
 		audList = append(audList, aud)
 	}
+	var tokenFileList []string
+	for tokenFile := range tokenFileMap {
+		tokenFileList = append(tokenFileList, tokenFile)
+	}
 	// This sort is just for unit-test to compare with expected result.
 	sort.Strings(audList)
+	sort.Strings(tokenFileList)
 	backendAuthConfig := &bapb.FilterConfig{
-		JwtAudienceList: audList,
+		JwtAudienceList:  audList,
+		StaticTokenFiles: tokenFileList,
 	}
 
 	depErrorBehaviorEnum, err := parseDepErrorBehavior(serviceInfo.Options.DependencyErrorBehavior)
@@ -36,6 +36,7 @@ func TestBackendAuthFilter(t *testing.T) {
 		fakeServiceConfig     *confpb.Service
 		delegates             []string
 		depErrorBehavior      string
+		staticTokenFiles      string
 		wantBackendAuthFilter string
 		wantError             string
 	}{
@@ -224,6 +225,63 @@ func TestBackendAuthFilter(t *testing.T) {
       "jwtAudienceList":["bar.com"]
    }
 }
+`,
+		},
+		{
+			desc: "Success, generate backend auth filter with static token files",
+			fakeServiceConfig: &confpb.Service{
+				Name: testProjectName,
+				Apis: []*apipb.Api{
+					{
+						Name: "testapipb",
+						Methods: []*apipb.Method{
+							{
+								Name: "foo",
+							},
+							{
+								Name: "bar",
+							},
+						},
+					},
+				},
+				Backend: &confpb.Backend{
+					Rules: []*confpb.BackendRule{
+						{
+							Selector:        "testapipb.foo",
+							Address:         "https://testapipb.com/foo",
+							PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+							Authentication: &confpb.BackendRule_JwtAudience{
+								JwtAudience: "foo.com",
+							},
+						},
+						{
+							Selector:        "testapipb.bar",
+							Address:         "https://testapipb.com/foo",
+							PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+							Authentication: &confpb.BackendRule_JwtAudience{
+								JwtAudience: "bar.com",
+							},
+						},
+					},
+				},
+			},
+			depErrorBehavior: commonpb.DependencyErrorBehavior_BLOCK_INIT_ON_ANY_ERROR.String(),
+			staticTokenFiles: "testapipb.bar=/etc/token",
+			wantBackendAuthFilter: `
+{
+   "name":"com.google.espv2.filters.http.backend_auth",
+   "typedConfig":{
+      "@type":"type.googleapis.com/espv2.api.envoy.v10.http.backend_auth.FilterConfig",
+      "depErrorBehavior":"BLOCK_INIT_ON_ANY_ERROR",
+      "imdsToken":{
+          "cluster":"metadata-cluster",
+          "timeout":"30s",
+          "uri":"http://169.254.169.254/computeMetadata/v1/instance/service-accounts/default/identity"
+      },
+      "jwtAudienceList":["foo.com"],
+      "staticTokenFiles":["/etc/token"]
+   }
+}
 `,
 		},
 		{
@@ -263,6 +321,7 @@ func TestBackendAuthFilter(t *testing.T) {
 			opts := options.DefaultConfigGeneratorOptions()
 			opts.BackendAddress = "grpc://127.0.0.1:80"
 			opts.DependencyErrorBehavior = tc.depErrorBehavior
+			opts.BackendAuthStaticTokenFiles = tc.staticTokenFiles
 			if tc.iamServiceAccount != "" {
 				opts.BackendAuthCredentials = &options.IAMCredentialsOptions{
 					ServiceAccountEmail: tc.iamServiceAccount,
@@ -295,3 +354,142 @@ func TestBackendAuthFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestBackendAuthPerRouteOptOut(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "testapipb",
+				Methods: []*apipb.Method{
+					{
+						Name: "authed",
+					},
+					{
+						Name: "disabled",
+					},
+					{
+						Name: "local",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Selector:        "testapipb.authed",
+					Address:         "https://testapipb.com/authed",
+					PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+					Authentication: &confpb.BackendRule_JwtAudience{
+						JwtAudience: "authed.com",
+					},
+				},
+				{
+					Selector:        "testapipb.disabled",
+					Address:         "https://testapipb.com/disabled",
+					PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+					Authentication: &confpb.BackendRule_DisableAuth{
+						DisableAuth: true,
+					},
+				},
+				{
+					// No address, so the local backend is used without auth.
+					Selector: "testapipb.local",
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendAddress = "grpc://127.0.0.1:80"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, perRouteMethods, err := baFilterGenFunc(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the authed method gets the per-route config, the filter passes
+	// through all other routes without touching the Authorization header.
+	if len(perRouteMethods) != 1 || perRouteMethods[0].Operation() != "testapipb.authed" {
+		var got []string
+		for _, method := range perRouteMethods {
+			got = append(got, method.Operation())
+		}
+		t.Fatalf("want per-route config only for [testapipb.authed], got %v", got)
+	}
+
+	perRoute, err := baPerRouteFilterConfigGen(perRouteMethods[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaler := &jsonpb.Marshaler{}
+	gotPerRoute, err := marshaler.MarshalToString(perRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPerRoute := `{
+  "@type":"type.googleapis.com/espv2.api.envoy.v10.http.backend_auth.PerRouteFilterConfig",
+  "jwtAudience":"authed.com"
+}`
+	if err := util.JsonEqual(wantPerRoute, gotPerRoute); err != nil {
+		t.Errorf("baPerRouteFilterConfigGen failed,\n %v", err)
+	}
+}
+
+func TestBackendAuthPerRouteTokenHeader(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "testapipb",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Selector:        "testapipb.foo",
+					Address:         "https://testapipb.com/foo",
+					PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+					Authentication: &confpb.BackendRule_JwtAudience{
+						JwtAudience: "foo.com",
+					},
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendAddress = "grpc://127.0.0.1:80"
+	opts.BackendAuthTokenHeaders = "testapipb.foo=X-Serverless-Authorization"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	perRoute, err := baPerRouteFilterConfigGen(fakeServiceInfo.Methods["testapipb.foo"], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaler := &jsonpb.Marshaler{}
+	gotPerRoute, err := marshaler.MarshalToString(perRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPerRoute := `{
+  "@type":"type.googleapis.com/espv2.api.envoy.v10.http.backend_auth.PerRouteFilterConfig",
+  "jwtAudience":"foo.com",
+  "tokenHeader":"X-Serverless-Authorization"
+}`
+	if err := util.JsonEqual(wantPerRoute, gotPerRoute); err != nil {
+		t.Errorf("baPerRouteFilterConfigGen failed,\n %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filterconfig
+
+import (
+	"fmt"
+
+	ci "github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util/httppattern"
+	bufferpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	hcmpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes"
+	anypb "github.com/golang/protobuf/ptypes/any"
+	wrapperspb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+// The buffer filter is disabled on the virtual host, and only enabled on the
+// routes of methods that buffer requests. Methods with a request size limit
+// buffer up to that limit instead of the default one.
+var bufPerRouteFilterConfigGen = func(method *ci.MethodInfo, httpRule *httppattern.Pattern) (*anypb.Any, error) {
+	bufConfig := makeBufferConfig()
+	if method.BackendInfo.MaxRequestBytes > 0 {
+		bufConfig.MaxRequestBytes = &wrapperspb.UInt32Value{
+			Value: method.BackendInfo.MaxRequestBytes,
+		}
+	}
+	perRouteConfig := &bufferpb.BufferPerRoute{
+		Override: &bufferpb.BufferPerRoute_Buffer{
+			Buffer: bufConfig,
+		},
+	}
+
+	bufAny, err := ptypes.MarshalAny(perRouteConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling buffer per-route config to Any: %v", err)
+	}
+	return bufAny, nil
+}
+
+var bufFilterGenFunc = func(sc *ci.ServiceInfo) (*hcmpb.HttpFilter, []*ci.MethodInfo, error) {
+	var perRouteConfigRequiredMethods []*ci.MethodInfo
+	for _, method := range sc.Methods {
+		if method.BuffersRequests() {
+			perRouteConfigRequiredMethods = append(perRouteConfigRequiredMethods, method)
+		}
+	}
+	if len(perRouteConfigRequiredMethods) == 0 {
+		return nil, nil, nil
+	}
+
+	bufConfig, err := ptypes.MarshalAny(makeBufferConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hcmpb.HttpFilter{
+		Name:       util.Buffer,
+		ConfigType: &hcmpb.HttpFilter_TypedConfig{TypedConfig: bufConfig},
+	}, perRouteConfigRequiredMethods, nil
+}
+
+// MakeBufferVirtualHostConfig disables the buffer filter on the virtual host,
+// so that the routes not enabling it, such as the catch-all route, don't
+// buffer requests. It returns nil if the buffer filter is not needed.
+func MakeBufferVirtualHostConfig(serviceInfo *ci.ServiceInfo) (*anypb.Any, error) {
+	needed := false
+	for _, method := range serviceInfo.Methods {
+		if method.BuffersRequests() {
+			needed = true
+		}
+	}
+	if !needed {
+		return nil, nil
+	}
+
+	bufAny, err := ptypes.MarshalAny(&bufferpb.BufferPerRoute{
+		Override: &bufferpb.BufferPerRoute_Disabled{
+			Disabled: true,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling buffer virtual host config to Any: %v", err)
+	}
+	return bufAny, nil
+}
+
+// makeBufferConfig buffers the whole request, the buffer filter then sets its
+// Content-Length so that it is not sent to the backend chunked.
+func makeBufferConfig() *bufferpb.Buffer {
+	return &bufferpb.Buffer{
+		MaxRequestBytes: &wrapperspb.UInt32Value{
+			Value: util.BufferMaxRequestBytes,
+		},
+	}
+}
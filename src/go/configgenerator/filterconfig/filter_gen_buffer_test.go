@@ -0,0 +1,199 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filterconfig
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/golang/protobuf/jsonpb"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+	apipb "google.golang.org/genproto/protobuf/api"
+)
+
+func TestBufferFilter(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "ListShelves",
+					},
+					{
+						Name: "CreateShelf",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Selector:        "endpoints.examples.bookstore.Bookstore.CreateShelf",
+					Address:         "https://legacy-backend.example.com",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testdata := []struct {
+		desc                  string
+		backends              string
+		passthroughOperations string
+		maxRequestBytes       string
+		wantBufferFilter      string
+		wantPerRouteConfig    map[string]string
+	}{
+		{
+			desc: "No buffer filter by default",
+		},
+		{
+			desc:     "Buffer requests to the legacy backend only",
+			backends: "https://legacy-backend.example.com",
+			wantBufferFilter: `{
+        "name": "envoy.filters.http.buffer",
+        "typedConfig": {
+          "@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.Buffer",
+          "maxRequestBytes": 10485760
+        }
+      }`,
+			wantPerRouteConfig: map[string]string{
+				"endpoints.examples.bookstore.Bookstore.CreateShelf": `{
+          "@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.BufferPerRoute",
+          "buffer": {
+            "maxRequestBytes": 10485760
+          }
+        }`,
+			},
+		},
+		{
+			desc:                  "Streaming passthrough operations are not buffered",
+			backends:              "https://legacy-backend.example.com",
+			passthroughOperations: "endpoints.examples.bookstore.Bookstore.CreateShelf",
+		},
+		{
+			desc:                  "Streaming passthrough operations are not buffered along with operations with a size limit",
+			passthroughOperations: "endpoints.examples.bookstore.Bookstore.CreateShelf",
+			maxRequestBytes:       "endpoints.examples.bookstore.Bookstore.ListShelves=1048576",
+			wantBufferFilter: `{
+        "name": "envoy.filters.http.buffer",
+        "typedConfig": {
+          "@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.Buffer",
+          "maxRequestBytes": 10485760
+        }
+      }`,
+			wantPerRouteConfig: map[string]string{
+				"endpoints.examples.bookstore.Bookstore.ListShelves": `{
+          "@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.BufferPerRoute",
+          "buffer": {
+            "maxRequestBytes": 1048576
+          }
+        }`,
+			},
+		},
+		{
+			desc:            "Buffer requests of operations with a size limit up to their limit",
+			maxRequestBytes: "endpoints.examples.bookstore.Bookstore.ListShelves=1048576",
+			wantBufferFilter: `{
+        "name": "envoy.filters.http.buffer",
+        "typedConfig": {
+          "@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.Buffer",
+          "maxRequestBytes": 10485760
+        }
+      }`,
+			wantPerRouteConfig: map[string]string{
+				"endpoints.examples.bookstore.Bookstore.ListShelves": `{
+          "@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.BufferPerRoute",
+          "buffer": {
+            "maxRequestBytes": 1048576
+          }
+        }`,
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableChunkedEncodingBackends = tc.backends
+			opts.StreamingPassthroughOperations = tc.passthroughOperations
+			opts.MaxRequestBytesByOperation = tc.maxRequestBytes
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filter, perRouteConfigRequiredMethods, err := bufFilterGenFunc(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			virtualHostConfig, err := MakeBufferVirtualHostConfig(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantBufferFilter == "" {
+				if filter != nil {
+					t.Errorf("got buffer filter %v, want none", filter)
+				}
+				if virtualHostConfig != nil {
+					t.Errorf("got buffer virtual host config %v, want none", virtualHostConfig)
+				}
+				return
+			}
+
+			marshaler := &jsonpb.Marshaler{}
+			gotVirtualHostConfig, err := marshaler.MarshalToString(virtualHostConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantVirtualHostConfig := `{
+        "@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.BufferPerRoute",
+        "disabled": true
+      }`
+			if err := util.JsonEqual(wantVirtualHostConfig, gotVirtualHostConfig); err != nil {
+				t.Errorf("buffer virtual host config mismatch,\n%v", err)
+			}
+			gotFilter, err := marshaler.MarshalToString(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := util.JsonEqual(tc.wantBufferFilter, gotFilter); err != nil {
+				t.Errorf("buffer filter mismatch,\n%v", err)
+			}
+
+			if len(perRouteConfigRequiredMethods) != len(tc.wantPerRouteConfig) {
+				t.Fatalf("got %d methods with per-route config, want %d", len(perRouteConfigRequiredMethods), len(tc.wantPerRouteConfig))
+			}
+			for _, method := range perRouteConfigRequiredMethods {
+				perRouteConfig, err := bufPerRouteFilterConfigGen(method, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotPerRouteConfig, err := marshaler.MarshalToString(perRouteConfig)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := util.JsonEqual(tc.wantPerRouteConfig[method.Operation()], gotPerRouteConfig); err != nil {
+					t.Errorf("buffer per-route config mismatch for %v,\n%v", method.Operation(), err)
+				}
+			}
+		})
+	}
+}
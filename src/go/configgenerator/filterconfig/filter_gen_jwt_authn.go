@@ -16,6 +16,7 @@ package filterconfig
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
@@ -27,9 +28,13 @@ import (
 
 	ci "github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 
+	commonmatcherpb "github.com/envoyproxy/go-control-plane/envoy/config/common/matcher/v3"
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extmatcherpb "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	actionpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/matcher/action/v3"
 	jwtpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
 	hcmpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherpb "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	durationpb "github.com/golang/protobuf/ptypes/duration"
 	emptypb "github.com/golang/protobuf/ptypes/empty"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
@@ -53,6 +58,27 @@ var jaFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 	if len(auth.GetProviders()) == 0 {
 		return nil, nil, nil
 	}
+	cacheDurations, err := parseJwksCacheDurationByProvider(serviceInfo.Options.JwksCacheDurationByProvider, auth.GetProviders())
+	if err != nil {
+		return nil, nil, err
+	}
+	skipAudienceCheck, err := parseJwtSkipAudienceCheck(serviceInfo.Options.JwtSkipAudienceCheck, auth.GetProviders())
+	if err != nil {
+		return nil, nil, err
+	}
+	// Providers added for additional issuers inherit the options of the
+	// provider they are copied from, unless set for them explicitly.
+	for aliasId, baseId := range serviceInfo.JwtProviderAdditionalIssuerBases {
+		if seconds, ok := cacheDurations[baseId]; ok {
+			if _, ok := cacheDurations[aliasId]; !ok {
+				cacheDurations[aliasId] = seconds
+			}
+		}
+		if skipAudienceCheck[baseId] {
+			skipAudienceCheck[aliasId] = true
+		}
+	}
+
 	providers := make(map[string]*jwtpb.JwtProvider)
 	for _, provider := range auth.GetProviders() {
 		addr, err := util.ExtractAddressFromURI(provider.GetJwksUri())
@@ -77,6 +103,11 @@ var jaFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 				Seconds: int64(serviceInfo.Options.JwksCacheDurationInS),
 			},
 		}
+		if seconds, ok := cacheDurations[provider.GetId()]; ok {
+			jwks.CacheDuration = &durationpb.Duration{
+				Seconds: seconds,
+			}
+		}
 		if !serviceInfo.Options.DisableJwksAsyncFetch {
 			jwks.AsyncFetch = &jwtpb.JwksAsyncFetch{}
 		}
@@ -105,7 +136,9 @@ var jaFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 			Forward:              true,
 		}
 
-		if len(provider.GetAudiences()) != 0 {
+		if skipAudienceCheck[provider.GetId()] {
+			// Envoy doesn't check the audiences of the JWT if none is configured.
+		} else if len(provider.GetAudiences()) != 0 {
 			for _, a := range strings.Split(provider.GetAudiences(), ",") {
 				jp.Audiences = append(jp.Audiences, strings.TrimSpace(a))
 			}
@@ -131,7 +164,7 @@ var jaFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 	requirements := make(map[string]*jwtpb.JwtRequirement)
 	for _, rule := range auth.GetRules() {
 		if len(rule.GetRequirements()) > 0 {
-			requirements[rule.GetSelector()] = makeJwtRequirement(rule.GetRequirements(), rule.GetAllowWithoutCredential())
+			requirements[rule.GetSelector()] = makeJwtRequirement(rule.GetRequirements(), rule.GetAllowWithoutCredential(), skipAudienceCheck)
 		}
 	}
 
@@ -148,6 +181,12 @@ var jaFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 	}
 
 	jas, _ := ptypes.MarshalAny(jwtAuthentication)
+	if serviceInfo.Options.TrustPreauthenticatedJwtHeader != "" {
+		jas, err = skipWhenHeaderPresent(util.JwtAuthn, jas, serviceInfo.Options.TrustPreauthenticatedJwtHeader)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	jwtAuthnFilter := &hcmpb.HttpFilter{
 		Name:       util.JwtAuthn,
 		ConfigType: &hcmpb.HttpFilter_TypedConfig{jas},
@@ -155,6 +194,70 @@ var jaFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 	return jwtAuthnFilter, perRouteConfigRequiredMethods, nil
 }
 
+// skipWhenHeaderPresent wraps the filter config so that the filter is skipped
+// for requests carrying the given header, and still runs for all others.
+func skipWhenHeaderPresent(filterName string, filterConfig *anypb.Any, header string) (*anypb.Any, error) {
+	input, err := ptypes.MarshalAny(&matcherpb.HttpRequestHeaderMatchInput{
+		HeaderName: header,
+	})
+	if err != nil {
+		return nil, err
+	}
+	skip, err := ptypes.MarshalAny(&actionpb.SkipFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	extensionWithMatcher := &extmatcherpb.ExtensionWithMatcher{
+		Matcher: &commonmatcherpb.Matcher{
+			MatcherType: &commonmatcherpb.Matcher_MatcherList_{
+				MatcherList: &commonmatcherpb.Matcher_MatcherList{
+					Matchers: []*commonmatcherpb.Matcher_MatcherList_FieldMatcher{
+						{
+							Predicate: &commonmatcherpb.Matcher_MatcherList_Predicate{
+								MatchType: &commonmatcherpb.Matcher_MatcherList_Predicate_SinglePredicate_{
+									SinglePredicate: &commonmatcherpb.Matcher_MatcherList_Predicate_SinglePredicate{
+										Input: &corepb.TypedExtensionConfig{
+											Name:        "trusted-jwt-header",
+											TypedConfig: input,
+										},
+										// Any value, the header only has to be present.
+										Matcher: &commonmatcherpb.Matcher_MatcherList_Predicate_SinglePredicate_ValueMatch{
+											ValueMatch: &matcherpb.StringMatcher{
+												MatchPattern: &matcherpb.StringMatcher_SafeRegex{
+													SafeRegex: &matcherpb.RegexMatcher{
+														EngineType: &matcherpb.RegexMatcher_GoogleRe2{
+															GoogleRe2: &matcherpb.RegexMatcher_GoogleRE2{},
+														},
+														Regex: ".*",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+							OnMatch: &commonmatcherpb.Matcher_OnMatch{
+								OnMatch: &commonmatcherpb.Matcher_OnMatch_Action{
+									Action: &corepb.TypedExtensionConfig{
+										Name:        "skip",
+										TypedConfig: skip,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		ExtensionConfig: &corepb.TypedExtensionConfig{
+			Name:        filterName,
+			TypedConfig: filterConfig,
+		},
+	}
+	return ptypes.MarshalAny(extensionWithMatcher)
+}
+
 func defaultJwtLocations() ([]*jwtpb.JwtHeader, []string, error) {
 	return []*jwtpb.JwtHeader{
 			{
@@ -195,7 +298,7 @@ func processJwtLocations(provider *confpb.AuthProvider) ([]*jwtpb.JwtHeader, []s
 	return jwtHeaders, jwtParams, nil
 }
 
-func makeJwtRequirement(requirements []*confpb.AuthRequirement, allow_missing bool) *jwtpb.JwtRequirement {
+func makeJwtRequirement(requirements []*confpb.AuthRequirement, allow_missing bool, skipAudienceCheck map[string]bool) *jwtpb.JwtRequirement {
 	// By default, if there are multi requirements, treat it as RequireAny.
 	requires := &jwtpb.JwtRequirement{
 		RequiresType: &jwtpb.JwtRequirement_RequiresAny{
@@ -205,7 +308,7 @@ func makeJwtRequirement(requirements []*confpb.AuthRequirement, allow_missing bo
 
 	for _, r := range requirements {
 		var require *jwtpb.JwtRequirement
-		if r.GetAudiences() == "" {
+		if r.GetAudiences() == "" || skipAudienceCheck[r.GetProviderId()] {
 			require = &jwtpb.JwtRequirement{
 				RequiresType: &jwtpb.JwtRequirement_ProviderName{
 					ProviderName: r.GetProviderId(),
@@ -244,3 +347,54 @@ func makeJwtRequirement(requirements []*confpb.AuthRequirement, allow_missing bo
 
 	return requires
 }
+
+// parseJwksCacheDurationByProvider parses comma-separated provider_id=seconds
+// pairs into a map of JWKS cache durations keyed by provider id.
+func parseJwksCacheDurationByProvider(durations string, providers []*confpb.AuthProvider) (map[string]int64, error) {
+	cacheDurations := make(map[string]int64)
+	if durations == "" {
+		return cacheDurations, nil
+	}
+
+	providerIds := make(map[string]bool)
+	for _, provider := range providers {
+		providerIds[provider.GetId()] = true
+	}
+
+	for _, pair := range strings.Split(durations, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid jwks cache duration %q, it should be in the format provider_id=seconds", pair)
+		}
+		seconds, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid jwks cache duration %q for provider (%v), it should be a positive number of seconds", kv[1], kv[0])
+		}
+		if !providerIds[kv[0]] {
+			return nil, fmt.Errorf("jwks cache duration is set for provider (%v), which is not an authentication provider in the service config", kv[0])
+		}
+		cacheDurations[kv[0]] = seconds
+	}
+	return cacheDurations, nil
+}
+
+func parseJwtSkipAudienceCheck(providerIds string, providers []*confpb.AuthProvider) (map[string]bool, error) {
+	skipAudienceCheck := make(map[string]bool)
+	if providerIds == "" {
+		return skipAudienceCheck, nil
+	}
+
+	knownProviderIds := make(map[string]bool)
+	for _, provider := range providers {
+		knownProviderIds[provider.GetId()] = true
+	}
+
+	for _, providerId := range strings.Split(providerIds, ",") {
+		providerId = strings.TrimSpace(providerId)
+		if !knownProviderIds[providerId] {
+			return nil, fmt.Errorf("jwt audience check is skipped for provider (%v), which is not an authentication provider in the service config", providerId)
+		}
+		skipAudienceCheck[providerId] = true
+	}
+	return skipAudienceCheck, nil
+}
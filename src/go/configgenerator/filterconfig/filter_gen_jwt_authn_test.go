@@ -15,13 +15,17 @@
 package filterconfig
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
 
+	extmatcherpb "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	jwtpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
 	anypb "github.com/golang/protobuf/ptypes/any"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 	apipb "google.golang.org/genproto/protobuf/api"
@@ -322,3 +326,376 @@ func TestJwtAuthnFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestJwtAuthnFilterCacheDurationByProvider(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "testapi",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+				},
+			},
+		},
+		Authentication: &confpb.Authentication{
+			Providers: []*confpb.AuthProvider{
+				{
+					Id:      "fast_rotating_provider",
+					Issuer:  "issuer-0",
+					JwksUri: "https://fake-jwks-0.com",
+				},
+				{
+					Id:      "slow_rotating_provider",
+					Issuer:  "issuer-1",
+					JwksUri: "https://fake-jwks-1.com",
+				},
+				{
+					Id:      "default_provider",
+					Issuer:  "issuer-2",
+					JwksUri: "https://fake-jwks-2.com",
+				},
+			},
+			Rules: []*confpb.AuthenticationRule{
+				{
+					Selector: "testapi.foo",
+					Requirements: []*confpb.AuthRequirement{
+						{
+							ProviderId: "fast_rotating_provider",
+						},
+						{
+							ProviderId: "slow_rotating_provider",
+						},
+						{
+							ProviderId: "default_provider",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc               string
+		cacheDurations     string
+		additionalIssuers  string
+		wantCacheDurations map[string]int64
+		wantError          string
+	}{
+		{
+			desc:           "Success. Each provider carries its own cache duration",
+			cacheDurations: "fast_rotating_provider=60, slow_rotating_provider=3600",
+			wantCacheDurations: map[string]int64{
+				"fast_rotating_provider": 60,
+				"slow_rotating_provider": 3600,
+				"default_provider":       300,
+			},
+		},
+		{
+			desc:              "Success. Providers of additional issuers inherit the cache duration",
+			cacheDurations:    "fast_rotating_provider=60",
+			additionalIssuers: "fast_rotating_provider=issuer-3|https://fake-jwks-3.com, default_provider=issuer-4|https://fake-jwks-4.com",
+			wantCacheDurations: map[string]int64{
+				"fast_rotating_provider":          60,
+				"fast_rotating_provider_issuer_1": 60,
+				"default_provider_issuer_1":       300,
+			},
+		},
+		{
+			desc:              "Success. Providers of additional issuers carry their own cache duration",
+			cacheDurations:    "fast_rotating_provider=60, fast_rotating_provider_issuer_1=30",
+			additionalIssuers: "fast_rotating_provider=issuer-3|https://fake-jwks-3.com",
+			wantCacheDurations: map[string]int64{
+				"fast_rotating_provider":          60,
+				"fast_rotating_provider_issuer_1": 30,
+			},
+		},
+		{
+			desc:           "Failure. Malformed pair",
+			cacheDurations: "fast_rotating_provider",
+			wantError:      `invalid jwks cache duration "fast_rotating_provider", it should be in the format provider_id=seconds`,
+		},
+		{
+			desc:           "Failure. Non-positive duration",
+			cacheDurations: "fast_rotating_provider=0",
+			wantError:      `invalid jwks cache duration "0" for provider (fast_rotating_provider), it should be a positive number of seconds`,
+		},
+		{
+			desc:           "Failure. Unknown provider",
+			cacheDurations: "unknown_provider=60",
+			wantError:      "jwks cache duration is set for provider (unknown_provider), which is not an authentication provider in the service config",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "grpc://127.0.0.0:80"
+			opts.JwksCacheDurationByProvider = tc.cacheDurations
+			opts.JwtProviderAdditionalIssuers = tc.additionalIssuers
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotFilter, _, err := jaFilterGenFunc(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("jaFilterGenFunc got error %v, want error %q", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jwtAuthn := &jwtpb.JwtAuthentication{}
+			if err := ptypes.UnmarshalAny(gotFilter.GetTypedConfig(), jwtAuthn); err != nil {
+				t.Fatal(err)
+			}
+			for providerId, wantSeconds := range tc.wantCacheDurations {
+				gotSeconds := jwtAuthn.GetProviders()[providerId].GetRemoteJwks().GetCacheDuration().GetSeconds()
+				if gotSeconds != wantSeconds {
+					t.Errorf("provider (%v) got cache duration %vs, want %vs", providerId, gotSeconds, wantSeconds)
+				}
+			}
+		})
+	}
+}
+
+func TestJwtAuthnFilterSkipAudienceCheck(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "testapi",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+				},
+			},
+		},
+		Authentication: &confpb.Authentication{
+			Providers: []*confpb.AuthProvider{
+				{
+					Id:        "internal_provider",
+					Issuer:    "issuer-0",
+					JwksUri:   "https://fake-jwks-0.com",
+					Audiences: "audience-0",
+				},
+				{
+					Id:      "default_provider",
+					Issuer:  "issuer-1",
+					JwksUri: "https://fake-jwks-1.com",
+				},
+			},
+			Rules: []*confpb.AuthenticationRule{
+				{
+					Selector: "testapi.foo",
+					Requirements: []*confpb.AuthRequirement{
+						{
+							ProviderId: "internal_provider",
+							Audiences:  "requirement-audience",
+						},
+						{
+							ProviderId: "default_provider",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc              string
+		skipAudienceCheck string
+		additionalIssuers string
+		wantAudiences     map[string][]string
+		wantRequirement   string
+		wantError         string
+	}{
+		{
+			desc: "Success. Audiences are checked by default",
+			wantAudiences: map[string][]string{
+				"internal_provider": {"audience-0"},
+				"default_provider":  {"https://" + testProjectName},
+			},
+			wantRequirement: `{"requiresAny":{"requirements":[{"providerAndAudiences":{"audiences":["requirement-audience"],"providerName":"internal_provider"}},{"providerName":"default_provider"}]}}`,
+		},
+		{
+			desc:              "Success. Audiences of the listed provider are not checked",
+			skipAudienceCheck: "internal_provider",
+			wantAudiences: map[string][]string{
+				"internal_provider": nil,
+				"default_provider":  {"https://" + testProjectName},
+			},
+			wantRequirement: `{"requiresAny":{"requirements":[{"providerName":"internal_provider"},{"providerName":"default_provider"}]}}`,
+		},
+		{
+			desc:              "Success. Audiences of the providers of additional issuers are not checked either",
+			skipAudienceCheck: "internal_provider",
+			additionalIssuers: "internal_provider=issuer-2|https://fake-jwks-2.com",
+			wantAudiences: map[string][]string{
+				"internal_provider":          nil,
+				"internal_provider_issuer_1": nil,
+				"default_provider":           {"https://" + testProjectName},
+			},
+			wantRequirement: `{"requiresAny":{"requirements":[{"providerName":"internal_provider"},{"providerName":"internal_provider_issuer_1"},{"providerName":"default_provider"}]}}`,
+		},
+		{
+			desc:              "Failure. Unknown provider",
+			skipAudienceCheck: "internal_provider, unknown_provider",
+			wantError:         "jwt audience check is skipped for provider (unknown_provider), which is not an authentication provider in the service config",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "grpc://127.0.0.0:80"
+			opts.JwtSkipAudienceCheck = tc.skipAudienceCheck
+			opts.JwtProviderAdditionalIssuers = tc.additionalIssuers
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotFilter, _, err := jaFilterGenFunc(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("jaFilterGenFunc got error %v, want error %q", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jwtAuthn := &jwtpb.JwtAuthentication{}
+			if err := ptypes.UnmarshalAny(gotFilter.GetTypedConfig(), jwtAuthn); err != nil {
+				t.Fatal(err)
+			}
+			for providerId, wantAudiences := range tc.wantAudiences {
+				gotAudiences := jwtAuthn.GetProviders()[providerId].GetAudiences()
+				if !reflect.DeepEqual(gotAudiences, wantAudiences) {
+					t.Errorf("provider (%v) got audiences %v, want %v", providerId, gotAudiences, wantAudiences)
+				}
+			}
+
+			gotRequirement, err := util.ProtoToJson(jwtAuthn.GetRequirementMap()["testapi.foo"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := util.JsonEqual(tc.wantRequirement, gotRequirement); err != nil {
+				t.Errorf("got requirement %v, want %v: %v", gotRequirement, tc.wantRequirement, err)
+			}
+		})
+	}
+}
+
+func TestJwtAuthnFilterTrustPreauthenticatedJwtHeader(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "testapi",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+				},
+			},
+		},
+		Authentication: &confpb.Authentication{
+			Providers: []*confpb.AuthProvider{
+				{
+					Id:      "auth_provider",
+					Issuer:  "issuer-0",
+					JwksUri: "https://fake-jwks-0.com",
+				},
+			},
+			Rules: []*confpb.AuthenticationRule{
+				{
+					Selector: "testapi.foo",
+					Requirements: []*confpb.AuthRequirement{
+						{
+							ProviderId: "auth_provider",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendAddress = "grpc://127.0.0.0:80"
+	opts.TrustPreauthenticatedJwtHeader = "X-Forwarded-Authorization"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotFilter, _, err := jaFilterGenFunc(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The filter still verifies JWTs, it is only skipped when the trusted
+	// header is present.
+	extensionWithMatcher := &extmatcherpb.ExtensionWithMatcher{}
+	if err := ptypes.UnmarshalAny(gotFilter.GetTypedConfig(), extensionWithMatcher); err != nil {
+		t.Fatal(err)
+	}
+	jwtAuthn := &jwtpb.JwtAuthentication{}
+	if err := ptypes.UnmarshalAny(extensionWithMatcher.GetExtensionConfig().GetTypedConfig(), jwtAuthn); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := jwtAuthn.GetRequirementMap()["testapi.foo"]; !ok {
+		t.Errorf("got requirement map %v, want a requirement for testapi.foo", jwtAuthn.GetRequirementMap())
+	}
+
+	gotMatcher, err := util.ProtoToJson(extensionWithMatcher.GetMatcher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMatcher := `
+{
+  "matcherList": {
+    "matchers": [
+      {
+        "onMatch": {
+          "action": {
+            "name": "skip",
+            "typedConfig": {
+              "@type": "type.googleapis.com/envoy.extensions.filters.common.matcher.action.v3.SkipFilter"
+            }
+          }
+        },
+        "predicate": {
+          "singlePredicate": {
+            "input": {
+              "name": "trusted-jwt-header",
+              "typedConfig": {
+                "@type": "type.googleapis.com/envoy.type.matcher.v3.HttpRequestHeaderMatchInput",
+                "headerName": "X-Forwarded-Authorization"
+              }
+            },
+            "valueMatch": {
+              "safeRegex": {
+                "googleRe2": {},
+                "regex": ".*"
+              }
+            }
+          }
+        }
+      }
+    ]
+  }
+}`
+	if err := util.JsonEqual(wantMatcher, gotMatcher); err != nil {
+		t.Errorf("got matcher %v, want %v: %v", gotMatcher, wantMatcher, err)
+	}
+}
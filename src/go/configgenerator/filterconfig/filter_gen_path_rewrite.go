@@ -69,6 +69,27 @@ func makePathRewriteConfig(method *ci.MethodInfo, httpRule *httppattern.Pattern)
 		return nil
 	}
 
+	if method.BackendInfo.StripPrefix != "" {
+		return &prpb.PerRouteFilterConfig{
+			PathTranslationSpecifier: &prpb.PerRouteFilterConfig_StripPrefix{
+				StripPrefix: &prpb.StripPrefix{
+					Prefix:     method.BackendInfo.StripPrefix,
+					PathPrefix: method.BackendInfo.Path,
+				},
+			},
+		}
+	}
+	if method.BackendInfo.RegexRewritePattern != "" {
+		return &prpb.PerRouteFilterConfig{
+			PathTranslationSpecifier: &prpb.PerRouteFilterConfig_RegexRewrite{
+				RegexRewrite: &prpb.RegexRewrite{
+					Pattern:      method.BackendInfo.RegexRewritePattern,
+					Substitution: method.BackendInfo.RegexRewriteSubstitution,
+					PathPrefix:   method.BackendInfo.Path,
+				},
+			},
+		}
+	}
 	if method.BackendInfo.TranslationType == confpb.BackendRule_APPEND_PATH_TO_ADDRESS {
 		if method.BackendInfo.Path != "" {
 			return &prpb.PerRouteFilterConfig{
@@ -0,0 +1,235 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filterconfig
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/golang/protobuf/jsonpb"
+
+	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+	apipb "google.golang.org/genproto/protobuf/api"
+)
+
+func TestPathRewriteStripPrefix(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "ListShelves",
+					},
+					{
+						Name: "CreateShelf",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/api/v1/shelves",
+					},
+				},
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.CreateShelf",
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/api/v1/shelves",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Selector:        "endpoints.examples.bookstore.Bookstore.CreateShelf",
+					Address:         "https://backend.example.com/v2",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testdata := []struct {
+		desc               string
+		stripPrefixes      string
+		wantPerRouteConfig map[string]string
+	}{
+		{
+			desc: "Path prefix of the backend address only",
+			wantPerRouteConfig: map[string]string{
+				"endpoints.examples.bookstore.Bookstore.CreateShelf": `{
+          "@type": "type.googleapis.com/espv2.api.envoy.v10.http.path_rewrite.PerRouteFilterConfig",
+          "pathPrefix": "/v2"
+        }`,
+			},
+		},
+		{
+			desc:          "Strip prefixes for remote and local backends",
+			stripPrefixes: "endpoints.examples.bookstore.Bookstore.CreateShelf=/api/v1,endpoints.examples.bookstore.Bookstore.ListShelves=/api/v1",
+			wantPerRouteConfig: map[string]string{
+				"endpoints.examples.bookstore.Bookstore.CreateShelf": `{
+          "@type": "type.googleapis.com/espv2.api.envoy.v10.http.path_rewrite.PerRouteFilterConfig",
+          "stripPrefix": {
+            "prefix": "/api/v1",
+            "pathPrefix": "/v2"
+          }
+        }`,
+				"endpoints.examples.bookstore.Bookstore.ListShelves": `{
+          "@type": "type.googleapis.com/espv2.api.envoy.v10.http.path_rewrite.PerRouteFilterConfig",
+          "stripPrefix": {
+            "prefix": "/api/v1"
+          }
+        }`,
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendStripPrefixByOperation = tc.stripPrefixes
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filter, perRouteConfigRequiredMethods, err := prFilterGenFunc(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filter.GetName() != util.PathRewrite {
+				t.Errorf("got filter %v, want %v", filter.GetName(), util.PathRewrite)
+			}
+
+			if len(perRouteConfigRequiredMethods) != len(tc.wantPerRouteConfig) {
+				t.Fatalf("got %d methods with per-route config, want %d", len(perRouteConfigRequiredMethods), len(tc.wantPerRouteConfig))
+			}
+			marshaler := &jsonpb.Marshaler{}
+			for _, method := range perRouteConfigRequiredMethods {
+				perRouteConfig, err := prPerRouteFilterConfigGen(method, method.HttpRule[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotPerRouteConfig, err := marshaler.MarshalToString(perRouteConfig)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := util.JsonEqual(tc.wantPerRouteConfig[method.Operation()], gotPerRouteConfig); err != nil {
+					t.Errorf("path rewrite per-route config mismatch for %v,\n%v", method.Operation(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestPathRewriteRegexRewrite(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "GetShelf",
+					},
+					{
+						Name: "ListShelves",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.GetShelf",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/v1/shelves/{shelf}",
+					},
+				},
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/v1/shelves",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Selector:        "endpoints.examples.bookstore.Bookstore.GetShelf",
+					Address:         "https://backend.example.com/api",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendRegexRewriteByOperation = `endpoints.examples.bookstore.Bookstore.GetShelf=^/v1/shelves/([^/]+)$=/shelf?id=\1, endpoints.examples.bookstore.Bookstore.ListShelves=^/v1/(.*)$=/\1`
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantPerRouteConfig := map[string]string{
+		"endpoints.examples.bookstore.Bookstore.GetShelf": `{
+  "@type": "type.googleapis.com/espv2.api.envoy.v10.http.path_rewrite.PerRouteFilterConfig",
+  "regexRewrite": {
+    "pattern": "^/v1/shelves/([^/]+)$",
+    "substitution": "/shelf?id=\\1",
+    "pathPrefix": "/api"
+  }
+}`,
+		"endpoints.examples.bookstore.Bookstore.ListShelves": `{
+  "@type": "type.googleapis.com/espv2.api.envoy.v10.http.path_rewrite.PerRouteFilterConfig",
+  "regexRewrite": {
+    "pattern": "^/v1/(.*)$",
+    "substitution": "/\\1"
+  }
+}`,
+	}
+
+	_, perRouteConfigRequiredMethods, err := prFilterGenFunc(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perRouteConfigRequiredMethods) != len(wantPerRouteConfig) {
+		t.Fatalf("got %d methods with per-route config, want %d", len(perRouteConfigRequiredMethods), len(wantPerRouteConfig))
+	}
+	marshaler := &jsonpb.Marshaler{}
+	for _, method := range perRouteConfigRequiredMethods {
+		perRouteConfig, err := prPerRouteFilterConfigGen(method, method.HttpRule[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotPerRouteConfig, err := marshaler.MarshalToString(perRouteConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := util.JsonEqual(wantPerRouteConfig[method.Operation()], gotPerRouteConfig); err != nil {
+			t.Errorf("path rewrite per-route config mismatch for %v,\n%v", method.Operation(), err)
+		}
+	}
+}
@@ -16,6 +16,8 @@ package filterconfig
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
 	ci "github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
@@ -31,15 +33,63 @@ import (
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 )
 
-var scPerRouteFilterConfigGen = func(method *ci.MethodInfo, httpRule *httppattern.Pattern) (*anypb.Any, error) {
-	scPerRoute := &scpb.PerRouteFilterConfig{
-		OperationName: method.Operation(),
+// supportedLogEntryFields are the optional request fields the service control
+// filter can add to the log entry payload.
+var supportedLogEntryFields = map[string]bool{
+	"consumer_project_number": true,
+	"http_method":             true,
+	"request_id":              true,
+	"request_latency_in_ms":   true,
+	"user_agent":              true,
+}
+
+// Matches the version segment of a path, e.g. "v1", "v2beta1".
+var apiVersionSegmentRegex = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]*)?$`)
+
+// makeScPerRouteFilterConfigGen returns the per-route config generator of the
+// Service Control filter. If reportApiVersionFromPath is set, the first
+// version segment of the route path is reported as the API version.
+func makeScPerRouteFilterConfigGen(reportApiVersionFromPath bool) ci.PerRouteConfigGenFunc {
+	return func(method *ci.MethodInfo, httpRule *httppattern.Pattern) (*anypb.Any, error) {
+		scPerRoute := &scpb.PerRouteFilterConfig{
+			OperationName: method.Operation(),
+		}
+		if reportApiVersionFromPath && httpRule != nil && httpRule.UriTemplate != nil {
+			scPerRoute.ApiVersion = apiVersionFromSegments(httpRule.UriTemplate.Segments)
+		}
+		scpr, err := ptypes.MarshalAny(scPerRoute)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling service_control per-route config to Any: %v", err)
+		}
+		return scpr, nil
 	}
-	scpr, err := ptypes.MarshalAny(scPerRoute)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling service_control per-route config to Any: %v", err)
+}
+
+func apiVersionFromSegments(segments []string) string {
+	for _, segment := range segments {
+		if apiVersionSegmentRegex.MatchString(segment) {
+			return segment
+		}
 	}
-	return scpr, nil
+	return ""
+}
+
+// The labels ESPv2 reports itself, user labels must not override them. All
+// labels in the googleapis.com namespaces are reserved as well.
+var reservedServiceControlLabels = map[string]bool{
+	"/credential_id":       true,
+	"/end_user":            true,
+	"/end_user_country":    true,
+	"/error_type":          true,
+	"/protocol":            true,
+	"/referer":             true,
+	"/response_code":       true,
+	"/response_code_class": true,
+	"/status_code":         true,
+}
+
+func isReservedServiceControlLabel(label string) bool {
+	return reservedServiceControlLabels[label] || strings.Contains(label, "googleapis.com/")
 }
 
 var scFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*ci.MethodInfo, error) {
@@ -84,10 +134,77 @@ var scFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 			service.LogJwtPayloads[i] = strings.TrimSpace(service.LogJwtPayloads[i])
 		}
 	}
+	if serviceInfo.Options.ServiceControlExtraLabels != "" {
+		service.ExtraLabels = make(map[string]string)
+		for _, pair := range strings.Split(serviceInfo.Options.ServiceControlExtraLabels, ",") {
+			kv := strings.Split(strings.TrimSpace(pair), "=")
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return nil, nil, fmt.Errorf("invalid service control extra label %q, it should be in the format header=label", pair)
+			}
+			if isReservedServiceControlLabel(kv[1]) {
+				return nil, nil, fmt.Errorf("invalid service control extra label %q, label %q is reserved for service control", pair, kv[1])
+			}
+			service.ExtraLabels[kv[0]] = kv[1]
+		}
+	}
+	if serviceInfo.Options.ServiceControlJwtClaimLabels != "" {
+		service.JwtClaimLabels = make(map[string]string)
+		for _, pair := range strings.Split(serviceInfo.Options.ServiceControlJwtClaimLabels, ",") {
+			kv := strings.Split(strings.TrimSpace(pair), "=")
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return nil, nil, fmt.Errorf("invalid service control jwt claim label %q, it should be in the format claim=label", pair)
+			}
+			if isReservedServiceControlLabel(kv[1]) {
+				return nil, nil, fmt.Errorf("invalid service control jwt claim label %q, label %q is reserved for service control", pair, kv[1])
+			}
+			service.JwtClaimLabels[kv[0]] = kv[1]
+		}
+	}
+	if serviceInfo.Options.LogEntryFields != "" {
+		for _, field := range strings.Split(serviceInfo.Options.LogEntryFields, ",") {
+			field = strings.TrimSpace(field)
+			if !supportedLogEntryFields[field] {
+				return nil, nil, fmt.Errorf("unsupported log entry field %q", field)
+			}
+			service.LogEntryFields = append(service.LogEntryFields, field)
+		}
+	}
+	service.CredentialIdPrefix = serviceInfo.Options.ServiceControlCredentialIdPrefix
+	if serviceInfo.Options.ServiceControlSuccessStatusCodes != "" {
+		for _, code := range strings.Split(serviceInfo.Options.ServiceControlSuccessStatusCodes, ",") {
+			statusCode, err := strconv.ParseUint(strings.TrimSpace(code), 10, 32)
+			if err != nil || statusCode < 100 || statusCode >= 600 {
+				return nil, nil, fmt.Errorf("invalid service control success status code %q, it should be an HTTP response code", code)
+			}
+			service.SuccessStatusCodes = append(service.SuccessStatusCodes, uint32(statusCode))
+		}
+	}
+	if serviceInfo.Options.ServiceControlStatsReport != "" {
+		if serviceInfo.Options.ServiceControlStatsReportInterval <= 0 {
+			return nil, nil, fmt.Errorf("invalid service control stats report interval %v, it should be positive", serviceInfo.Options.ServiceControlStatsReportInterval)
+		}
+		service.StatsReport = &scpb.StatsReport{
+			IntervalMs: uint64(serviceInfo.Options.ServiceControlStatsReportInterval.Milliseconds()),
+		}
+		for _, statName := range strings.Split(serviceInfo.Options.ServiceControlStatsReport, ",") {
+			statName = strings.TrimSpace(statName)
+			if statName == "" {
+				return nil, nil, fmt.Errorf("invalid service control stats report %q, the stat names should not be empty", serviceInfo.Options.ServiceControlStatsReport)
+			}
+			service.StatsReport.StatNames = append(service.StatsReport.StatNames, statName)
+		}
+	}
 	if serviceInfo.Options.MinStreamReportIntervalMs != 0 {
 		service.MinStreamReportIntervalMs = serviceInfo.Options.MinStreamReportIntervalMs
 	}
 	service.JwtPayloadMetadataName = util.JwtPayloadMetadataName
+	service.TrustedJwtHeader = serviceInfo.Options.TrustPreauthenticatedJwtHeader
+	if serviceInfo.Options.ScReportRetryBackoffMs < -1 {
+		return nil, nil, fmt.Errorf("flag --service_control_report_retry_backoff_ms must be >= 0, got %v", serviceInfo.Options.ScReportRetryBackoffMs)
+	}
+	if serviceInfo.Options.ScMaxPendingReports < 0 {
+		return nil, nil, fmt.Errorf("flag --service_control_max_pending_reports must be > 0, got %v", serviceInfo.Options.ScMaxPendingReports)
+	}
 	filterConfig := &scpb.FilterConfig{
 		Services:        []*scpb.Service{service},
 		ScCallingConfig: makeServiceControlCallingConfig(serviceInfo.Options),
@@ -98,6 +215,19 @@ var scFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 		},
 		GeneratedHeaderPrefix: serviceInfo.Options.GeneratedHeaderPrefix,
 	}
+	filterConfig.UnmatchedOperationName = serviceInfo.Options.ServiceControlUnmatchedOperation
+	filterConfig.RemoveApiKey = serviceInfo.Options.RemoveApiKeyFromRequest
+	filterConfig.EnableConsumerStats = serviceInfo.Options.EnableConsumerStats
+
+	if len(serviceInfo.JwtClaimRoutes) > 0 {
+		filterConfig.JwtClaimHeaders = &scpb.JwtClaimHeaders{
+			JwtPayloadMetadataName: util.JwtPayloadMetadataName,
+			ClaimHeaders:           make(map[string]string),
+		}
+		for _, claimRoute := range serviceInfo.JwtClaimRoutes {
+			filterConfig.JwtClaimHeaders.ClaimHeaders[claimRoute.Claim] = serviceInfo.JwtClaimHeader(claimRoute.Claim)
+		}
+	}
 
 	if serviceInfo.Options.ServiceControlCredentials != nil {
 		// Use access token fetched from Google Cloud IAM Server to talk to Service Controller
@@ -130,6 +260,11 @@ var scFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 		filterConfig.GcpAttributes.Platform = serviceInfo.Options.ComputePlatformOverride
 	}
 
+	operationNameMap, err := parseOperationNameMap(serviceInfo.Options.ScOperationNameMap)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var perRouteConfigRequiredMethods []*ci.MethodInfo
 	for _, operation := range serviceInfo.Operations {
 		method := serviceInfo.Methods[operation]
@@ -142,6 +277,12 @@ var scFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 			MetricCosts:        method.MetricCosts,
 		}
 
+		if name, ok := operationNameMap[operation]; ok {
+			requirement.ReportedOperationName = name
+		} else if prefix := serviceInfo.Options.ScOperationNameStripPrefix; prefix != "" && strings.HasPrefix(operation, prefix) && operation != prefix {
+			requirement.ReportedOperationName = strings.TrimPrefix(operation, prefix)
+		}
+
 		// For these OPTIONS methods, auth should be disabled and AllowWithoutApiKey
 		// should be true for each CORS.
 		if method.IsGenerated || method.AllowUnregisteredCalls {
@@ -178,6 +319,22 @@ var scFilterGenFunc = func(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, []*c
 	return filter, perRouteConfigRequiredMethods, nil
 }
 
+// parseOperationNameMap parses comma-separated selector=name pairs.
+func parseOperationNameMap(operationNameMap string) (map[string]string, error) {
+	names := make(map[string]string)
+	if operationNameMap == "" {
+		return names, nil
+	}
+	for _, pair := range strings.Split(operationNameMap, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid operation name mapping %q, it should be in the format selector=name", pair)
+		}
+		names[kv[0]] = kv[1]
+	}
+	return names, nil
+}
+
 func makeServiceControlCallingConfig(opts options.ConfigGeneratorOptions) *scpb.ServiceControlCallingConfig {
 	setting := &scpb.ServiceControlCallingConfig{}
 	setting.NetworkFailOpen = &wrapperspb.BoolValue{Value: opts.ServiceControlNetworkFailOpen}
@@ -201,6 +358,15 @@ func makeServiceControlCallingConfig(opts options.ConfigGeneratorOptions) *scpb.
 	if opts.ScReportRetries > -1 {
 		setting.ReportRetries = &wrapperspb.UInt32Value{Value: uint32(opts.ScReportRetries)}
 	}
+	if opts.ScReportRetryBackoffMs > -1 {
+		setting.ReportRetryBackoffMs = &wrapperspb.UInt32Value{Value: uint32(opts.ScReportRetryBackoffMs)}
+	}
+	if opts.ScMaxPendingReports > 0 {
+		setting.MaxPendingReports = &wrapperspb.UInt32Value{Value: uint32(opts.ScMaxPendingReports)}
+	}
+	if opts.ScReportFlushJitterMs > 0 {
+		setting.ReportFlushJitterMs = &wrapperspb.UInt32Value{Value: uint32(opts.ScReportFlushJitterMs)}
+	}
 	return setting
 }
 
@@ -16,11 +16,16 @@ package filterconfig
 
 import (
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util/httppattern"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
+
+	scpb "github.com/GoogleCloudPlatform/esp-v2/src/go/proto/api/envoy/v10/http/service_control"
 
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 	apipb "google.golang.org/genproto/protobuf/api"
@@ -47,6 +52,22 @@ func TestServiceControl(t *testing.T) {
 		desc                            string
 		serviceControlCredentials       *options.IAMCredentialsOptions
 		serviceAccountKey               string
+		trustPreauthenticatedJwtHeader  string
+		scOperationNameStripPrefix      string
+		scOperationNameMap              string
+		serviceControlExtraLabels       string
+		serviceControlJwtClaimLabels    string
+		logEntryFields                  string
+		credentialIdPrefix              string
+		successStatusCodes              string
+		unmatchedOperation              string
+		removeApiKeyFromRequest         bool
+		statsReport                     string
+		scReportRetryBackoffMs          int
+		scMaxPendingReports             int
+		scReportFlushJitterMs           int
+		jwtClaimBackendRoutes           string
+		enableConsumerStats             bool
 		wantPartialServiceControlFilter string
 	}{
 		{
@@ -95,6 +116,158 @@ func TestServiceControl(t *testing.T) {
       "uri": "http://127.0.0.1:8791/local/access_token"
     },`,
 		},
+		{
+			desc:                           "trust the JWT validated upstream",
+			trustPreauthenticatedJwtHeader: "X-Forwarded-Authorization",
+			wantPartialServiceControlFilter: `
+      "trustedJwtHeader": "X-Forwarded-Authorization"
+    }`,
+		},
+		{
+			desc:                      "report request headers as extra labels",
+			serviceControlExtraLabels: "X-Client-Version=/client_version, X-Client-Os=/client_os",
+			wantPartialServiceControlFilter: `
+      "extraLabels": {
+        "X-Client-Os": "/client_os",
+        "X-Client-Version": "/client_version"
+      },`,
+		},
+		{
+			desc:                         "report jwt claims as labels",
+			serviceControlJwtClaimLabels: "sub=/jwt_sub, email=/jwt_email",
+			wantPartialServiceControlFilter: `
+      "jwtClaimLabels": {
+        "email": "/jwt_email",
+        "sub": "/jwt_sub"
+      },`,
+		},
+		{
+			desc:               "prefix the reported credential id",
+			credentialIdPrefix: "billing-",
+			wantPartialServiceControlFilter: `
+      "credentialIdPrefix": "billing-",`,
+		},
+		{
+			desc:               "report unmatched requests with a custom operation name",
+			unmatchedOperation: "unregistered",
+			wantPartialServiceControlFilter: `
+    "unmatchedOperationName": "unregistered"
+  }`,
+		},
+		{
+			desc:                    "remove the api key from the request",
+			removeApiKeyFromRequest: true,
+			wantPartialServiceControlFilter: `
+    "removeApiKey": true,`,
+		},
+		{
+			desc:                "emit per-consumer stats",
+			enableConsumerStats: true,
+			wantPartialServiceControlFilter: `
+    "enableConsumerStats": true,`,
+		},
+		{
+			desc:        "report envoy stats periodically",
+			statsReport: "server.live, listener.0.0.0.0_8080.downstream_cx_total",
+			wantPartialServiceControlFilter: `
+      "statsReport": {
+        "intervalMs": "60000",
+        "statNames": [
+          "server.live",
+          "listener.0.0.0.0_8080.downstream_cx_total"
+        ]
+      }
+    }`,
+		},
+		{
+			desc:               "report status codes as successful",
+			successStatusCodes: "404, 409",
+			wantPartialServiceControlFilter: `
+      "successStatusCodes": [
+        404,
+        409
+      ]`,
+		},
+		{
+			desc:           "add optional fields to the log entry",
+			logEntryFields: "consumer_project_number, http_method, user_agent",
+			wantPartialServiceControlFilter: `
+      "logEntryFields": [
+        "consumer_project_number",
+        "http_method",
+        "user_agent"
+      ],`,
+		},
+		{
+			desc:                   "back off report retries and bound pending reports",
+			scReportRetryBackoffMs: 200,
+			scMaxPendingReports:    50,
+			wantPartialServiceControlFilter: `
+    "scCallingConfig": {
+      "maxPendingReports": 50,
+      "networkFailOpen": true,
+      "reportRetryBackoffMs": 200
+    },`,
+		},
+		{
+			desc:                  "jitter the periodic report flushes",
+			scReportFlushJitterMs: 500,
+			wantPartialServiceControlFilter: `
+    "scCallingConfig": {
+      "networkFailOpen": true,
+      "reportFlushJitterMs": 500
+    },`,
+		},
+		{
+			desc:                  "copy claims of JWT claim routes into headers",
+			jwtClaimBackendRoutes: "tier:enterprise=https://enterprise.example.com,tier:free=http://free.example.com",
+			wantPartialServiceControlFilter: `
+    "jwtClaimHeaders": {
+      "claimHeaders": {
+        "tier": "X-Endpoint-JWT-Claim-tier"
+      },
+      "jwtPayloadMetadataName": "jwt_payloads"
+    },`,
+		},
+		{
+			desc:                       "strip prefix from reported operation names",
+			scOperationNameStripPrefix: "endpoints.examples.bookstore.Bookstore.",
+			wantPartialServiceControlFilter: `
+    "requirements": [
+      {
+        "apiName": "endpoints.examples.bookstore.Bookstore",
+        "operationName": "endpoints.examples.bookstore.Bookstore.ListShelves",
+        "reportedOperationName": "ListShelves",
+        "serviceName": "bookstore.endpoints.project123.cloud.goog"
+      }
+    ],`,
+		},
+		{
+			desc:                       "mapping takes precedence over prefix stripping",
+			scOperationNameStripPrefix: "endpoints.examples.bookstore.Bookstore.",
+			scOperationNameMap:         "endpoints.examples.bookstore.Bookstore.ListShelves=Shelves.List",
+			wantPartialServiceControlFilter: `
+    "requirements": [
+      {
+        "apiName": "endpoints.examples.bookstore.Bookstore",
+        "operationName": "endpoints.examples.bookstore.Bookstore.ListShelves",
+        "reportedOperationName": "Shelves.List",
+        "serviceName": "bookstore.endpoints.project123.cloud.goog"
+      }
+    ],`,
+		},
+		{
+			desc:               "reported operation names are unchanged if no rule matches",
+			scOperationNameMap: "endpoints.examples.bookstore.Bookstore.GetShelf=Shelves.Get",
+			wantPartialServiceControlFilter: `
+    "requirements": [
+      {
+        "apiName": "endpoints.examples.bookstore.Bookstore",
+        "operationName": "endpoints.examples.bookstore.Bookstore.ListShelves",
+        "serviceName": "bookstore.endpoints.project123.cloud.goog"
+      }
+    ],`,
+		},
 	}
 	for _, tc := range testData {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -102,6 +275,24 @@ func TestServiceControl(t *testing.T) {
 			opts := options.DefaultConfigGeneratorOptions()
 			opts.ServiceControlCredentials = tc.serviceControlCredentials
 			opts.ServiceAccountKey = tc.serviceAccountKey
+			opts.TrustPreauthenticatedJwtHeader = tc.trustPreauthenticatedJwtHeader
+			opts.ScOperationNameStripPrefix = tc.scOperationNameStripPrefix
+			opts.ScOperationNameMap = tc.scOperationNameMap
+			opts.ServiceControlExtraLabels = tc.serviceControlExtraLabels
+			opts.ServiceControlJwtClaimLabels = tc.serviceControlJwtClaimLabels
+			opts.LogEntryFields = tc.logEntryFields
+			opts.ServiceControlCredentialIdPrefix = tc.credentialIdPrefix
+			opts.ServiceControlSuccessStatusCodes = tc.successStatusCodes
+			opts.ServiceControlUnmatchedOperation = tc.unmatchedOperation
+			opts.RemoveApiKeyFromRequest = tc.removeApiKeyFromRequest
+			opts.ServiceControlStatsReport = tc.statsReport
+			if tc.scReportRetryBackoffMs != 0 {
+				opts.ScReportRetryBackoffMs = tc.scReportRetryBackoffMs
+			}
+			opts.ScMaxPendingReports = tc.scMaxPendingReports
+			opts.ScReportFlushJitterMs = tc.scReportFlushJitterMs
+			opts.JwtClaimBackendRoutes = tc.jwtClaimBackendRoutes
+			opts.EnableConsumerStats = tc.enableConsumerStats
 
 			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
 			if err != nil {
@@ -125,3 +316,313 @@ func TestServiceControl(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceControlExtraLabelsError(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+		Control: &confpb.Control{
+			Environment: util.StatPrefix,
+		},
+	}
+
+	for _, extraLabels := range []string{"X-Client-Version", "=/client_version", "X-Client-Version=",
+		// Labels reported by ESPv2 itself cannot be overridden.
+		"X-Client-Version=/response_code", "X-Client-Version=cloud.googleapis.com/location"} {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.ServiceControlExtraLabels = extraLabels
+
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := scFilterGenFunc(fakeServiceInfo); err == nil {
+			t.Errorf("scFilterGenFunc with extra labels %q got no error, want error", extraLabels)
+		}
+	}
+}
+
+func TestServiceControlJwtClaimLabelsError(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+		Control: &confpb.Control{
+			Environment: util.StatPrefix,
+		},
+	}
+
+	for _, jwtClaimLabels := range []string{"sub", "=/jwt_sub", "sub=", "sub=/jwt_sub=x", "sub=/credential_id"} {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.ServiceControlJwtClaimLabels = jwtClaimLabels
+
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := scFilterGenFunc(fakeServiceInfo); err == nil {
+			t.Errorf("scFilterGenFunc with jwt claim labels %q got no error, want error", jwtClaimLabels)
+		}
+	}
+}
+
+func TestServiceControlCallingConfigError(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+		Control: &confpb.Control{
+			Environment: util.StatPrefix,
+		},
+	}
+
+	testData := []struct {
+		desc                   string
+		scReportRetryBackoffMs int
+		scMaxPendingReports    int
+		wantError              string
+	}{
+		{
+			desc:                   "negative report retry backoff",
+			scReportRetryBackoffMs: -2,
+			wantError:              "flag --service_control_report_retry_backoff_ms must be >= 0, got -2",
+		},
+		{
+			desc:                   "negative max pending reports",
+			scReportRetryBackoffMs: -1,
+			scMaxPendingReports:    -1,
+			wantError:              "flag --service_control_max_pending_reports must be > 0, got -1",
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.ScReportRetryBackoffMs = tc.scReportRetryBackoffMs
+			opts.ScMaxPendingReports = tc.scMaxPendingReports
+
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := scFilterGenFunc(fakeServiceInfo); err == nil || err.Error() != tc.wantError {
+				t.Errorf("scFilterGenFunc got error %v, want error %q", err, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestServiceControlLogEntryFieldsError(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+		Control: &confpb.Control{
+			Environment: util.StatPrefix,
+		},
+	}
+
+	for _, logEntryFields := range []string{"api_key", "http_method,", "http_method,referrer"} {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.LogEntryFields = logEntryFields
+
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := scFilterGenFunc(fakeServiceInfo); err == nil {
+			t.Errorf("scFilterGenFunc with log entry fields %q got no error, want error", logEntryFields)
+		}
+	}
+}
+
+func TestServiceControlStatsReportError(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+		Control: &confpb.Control{
+			Environment: util.StatPrefix,
+		},
+	}
+
+	testData := []struct {
+		desc                string
+		statsReport         string
+		statsReportInterval time.Duration
+	}{
+		{
+			desc:                "empty stat name",
+			statsReport:         "server.live,",
+			statsReportInterval: time.Minute,
+		},
+		{
+			desc:                "non-positive interval",
+			statsReport:         "server.live",
+			statsReportInterval: 0,
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.ServiceControlStatsReport = tc.statsReport
+		opts.ServiceControlStatsReportInterval = tc.statsReportInterval
+
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := scFilterGenFunc(fakeServiceInfo); err == nil {
+			t.Errorf("Test (%s): scFilterGenFunc got no error, want error", tc.desc)
+		}
+	}
+}
+
+func TestServiceControlSuccessStatusCodesError(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+		Control: &confpb.Control{
+			Environment: util.StatPrefix,
+		},
+	}
+
+	for _, successStatusCodes := range []string{"not_found", "404,", "99", "600"} {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.ServiceControlSuccessStatusCodes = successStatusCodes
+
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := scFilterGenFunc(fakeServiceInfo); err == nil {
+			t.Errorf("scFilterGenFunc with success status codes %q got no error, want error", successStatusCodes)
+		}
+	}
+}
+
+func TestServiceControlOperationNameMapError(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+		Control: &confpb.Control{
+			Environment: util.StatPrefix,
+		},
+	}
+
+	for _, operationNameMap := range []string{"ListShelves", "=Shelves.List", "ListShelves="} {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.ScOperationNameMap = operationNameMap
+
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := scFilterGenFunc(fakeServiceInfo); err == nil {
+			t.Errorf("scFilterGenFunc with operation name map %q got no error, want error", operationNameMap)
+		}
+	}
+}
+
+func TestServiceControlPerRouteApiVersion(t *testing.T) {
+	testCases := []struct {
+		desc                     string
+		uriTemplate              string
+		reportApiVersionFromPath bool
+		wantApiVersion           string
+	}{
+		{
+			desc:                     "Version segment is not reported by default",
+			uriTemplate:              "/v1/shelves",
+			reportApiVersionFromPath: false,
+			wantApiVersion:           "",
+		},
+		{
+			desc:                     "Version segment v1 is reported",
+			uriTemplate:              "/v1/shelves/{shelf}",
+			reportApiVersionFromPath: true,
+			wantApiVersion:           "v1",
+		},
+		{
+			desc:                     "Version segment v2 after a prefix is reported",
+			uriTemplate:              "/bookstore/v2/shelves",
+			reportApiVersionFromPath: true,
+			wantApiVersion:           "v2",
+		},
+		{
+			desc:                     "Version segment with a release level is reported",
+			uriTemplate:              "/v1beta2/shelves",
+			reportApiVersionFromPath: true,
+			wantApiVersion:           "v1beta2",
+		},
+		{
+			desc:                     "Path without a version segment reports no version",
+			uriTemplate:              "/shelves/version",
+			reportApiVersionFromPath: true,
+			wantApiVersion:           "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			uriTemplate, err := httppattern.ParseUriTemplate(tc.uriTemplate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			method := &configinfo.MethodInfo{
+				ShortName: "ListShelves",
+				ApiName:   testApiName,
+			}
+			httpRule := &httppattern.Pattern{
+				HttpMethod:  "GET",
+				UriTemplate: uriTemplate,
+			}
+
+			gotAny, err := makeScPerRouteFilterConfigGen(tc.reportApiVersionFromPath)(method, httpRule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &scpb.PerRouteFilterConfig{}
+			if err := ptypes.UnmarshalAny(gotAny, got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.ApiVersion != tc.wantApiVersion {
+				t.Errorf("got api version %q, want %q", got.ApiVersion, tc.wantApiVersion)
+			}
+			if want := method.Operation(); got.OperationName != want {
+				t.Errorf("got operation name %q, want %q", got.OperationName, want)
+			}
+		})
+	}
+}
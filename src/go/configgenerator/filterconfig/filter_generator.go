@@ -16,25 +16,55 @@ package filterconfig
 
 import (
 	"fmt"
+	"math"
+	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util/httppattern"
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/ptypes"
 
 	ci "github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	commonpb "github.com/GoogleCloudPlatform/esp-v2/src/go/proto/api/envoy/v10/http/common"
+	hapb "github.com/GoogleCloudPlatform/esp-v2/src/go/proto/api/envoy/v10/http/header_allowlist"
 
+	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	gzippb "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/gzip/compressor/v3"
+	commonfaultpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/fault/v3"
+	compressorpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	faultpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
 	transcoderpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_json_transcoder/v3"
 	hcpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/health_check/v3"
 	routerpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
 	hcmpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	typepb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	wrapperspb "github.com/golang/protobuf/ptypes/wrappers"
 	smpb "google.golang.org/genproto/googleapis/api/servicemanagement/v1"
+	errdetailspb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	descpb "google.golang.org/protobuf/types/descriptorpb"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 )
 
+// grpcStatusDescriptorFiles are needed by the transcoder to convert the
+// google.rpc.Status from the `grpc-status-details-bin` trailer, including the
+// standard error details, into the JSON error body. They are in dependency order.
+var grpcStatusDescriptorFiles = []protoreflect.FileDescriptor{
+	anypb.File_google_protobuf_any_proto,
+	durationpb.File_google_protobuf_duration_proto,
+	statuspb.File_google_rpc_status_proto,
+	errdetailspb.File_google_rpc_error_details_proto,
+}
+
 // A wrapper of filter generation logic.
 type FilterGenerator struct {
 	// The filter name.
@@ -84,22 +114,62 @@ func MakeFilterGenerators(serviceInfo *ci.ServiceInfo) ([]*FilterGenerator, erro
 		})
 	}
 
-	// Add JWT Authn filter if needed.
+	// Add Fault filter if needed. It is behind the Health Check filter so that
+	// health checks never fail due to injected faults. Negative percentages
+	// are not skipped here so that they are rejected.
+	if serviceInfo.Options.FaultAbortPercent != 0 || serviceInfo.Options.FaultDelayPercent != 0 {
+		faultFilters, err := makeFaultFilters(serviceInfo)
+		if err != nil {
+			return nil, err
+		}
+		for _, faultFilter := range faultFilters {
+			faultFilter := faultFilter
+			filterGenerators = append(filterGenerators, &FilterGenerator{
+				FilterName: util.Fault,
+				FilterGenFunc: func(sc *ci.ServiceInfo) (*hcmpb.HttpFilter, []*ci.MethodInfo, error) {
+					return faultFilter, nil, nil
+				},
+			})
+		}
+	}
+
+	// Add JWT Authn filter and Service Control filter if needed. Their order
+	// decides which error wins when both the JWT and the API key are invalid.
+	var authFilterGenerators []*FilterGenerator
+
 	if !serviceInfo.Options.SkipJwtAuthnFilter {
 		// TODO(b/176432170): Handle errors here, prevent startup.
-		filterGenerators = append(filterGenerators, &FilterGenerator{
+		authFilterGenerators = append(authFilterGenerators, &FilterGenerator{
 			FilterName:            util.JwtAuthn,
 			FilterGenFunc:         jaFilterGenFunc,
 			PerRouteConfigGenFunc: jaPerRouteFilterConfigGen,
 		})
 	}
 
-	// Add Service Control filter if needed.
 	if !serviceInfo.Options.SkipServiceControlFilter {
-		filterGenerators = append(filterGenerators, &FilterGenerator{
+		scFilterGenerator := &FilterGenerator{
 			FilterName:            util.ServiceControl,
 			FilterGenFunc:         scFilterGenFunc,
-			PerRouteConfigGenFunc: scPerRouteFilterConfigGen,
+			PerRouteConfigGenFunc: makeScPerRouteFilterConfigGen(serviceInfo.Options.ScReportApiVersionFromPath),
+		}
+		if serviceInfo.Options.CheckApiKeyBeforeJwt {
+			authFilterGenerators = append([]*FilterGenerator{scFilterGenerator}, authFilterGenerators...)
+		} else {
+			authFilterGenerators = append(authFilterGenerators, scFilterGenerator)
+		}
+	}
+	filterGenerators = append(filterGenerators, authFilterGenerators...)
+
+	// Add Compressor filter if needed. It must be before the gRPC Transcoder
+	// filter, so that it compresses the transcoded JSON responses instead of
+	// seeing the gRPC frames from the backend.
+	if serviceInfo.Options.EnableResponseCompression {
+		filterGenerators = append(filterGenerators, &FilterGenerator{
+			FilterName: util.Compressor,
+			FilterGenFunc: func(sc *ci.ServiceInfo) (*hcmpb.HttpFilter, []*ci.MethodInfo, error) {
+				f, err := makeCompressorFilter()
+				return f, nil, err
+			},
 		})
 	}
 
@@ -118,12 +188,21 @@ func MakeFilterGenerators(serviceInfo *ci.ServiceInfo) ([]*FilterGenerator, erro
 				}, nil, nil
 			},
 		})
+	}
 
+	if serviceInfo.GrpcSupportRequired && !serviceInfo.Options.DisableTranscoding {
 		filterGenerators = append(filterGenerators, &FilterGenerator{
 			FilterName: util.GRPCJSONTranscoder,
 			FilterGenFunc: func(sc *ci.ServiceInfo) (*hcmpb.HttpFilter, []*ci.MethodInfo, error) {
-				return makeTranscoderFilter(serviceInfo), nil, nil
+				var passthroughMethods []*ci.MethodInfo
+				for _, method := range sc.Methods {
+					if method.StreamingPassthrough {
+						passthroughMethods = append(passthroughMethods, method)
+					}
+				}
+				return makeTranscoderFilter(serviceInfo), passthroughMethods, nil
 			},
+			PerRouteConfigGenFunc: transcoderPerRouteFilterConfigGen,
 		})
 	}
 
@@ -139,6 +218,12 @@ func MakeFilterGenerators(serviceInfo *ci.ServiceInfo) ([]*FilterGenerator, erro
 		PerRouteConfigGenFunc: prPerRouteFilterConfigGen,
 	})
 
+	filterGenerators = append(filterGenerators, &FilterGenerator{
+		FilterName:            util.Buffer,
+		FilterGenFunc:         bufFilterGenFunc,
+		PerRouteConfigGenFunc: bufPerRouteFilterConfigGen,
+	})
+
 	if serviceInfo.Options.EnableGrpcForHttp1 {
 		// Add GrpcMetadataScrubber filter to retain gRPC trailers
 
@@ -152,6 +237,18 @@ func MakeFilterGenerators(serviceInfo *ci.ServiceInfo) ([]*FilterGenerator, erro
 		})
 	}
 
+	if serviceInfo.Options.ResponseHeadersAllowlist != "" {
+		// Add HeaderAllowlist filter right before the Router filter, so that it
+		// only removes the response headers from the backends.
+		filterGenerators = append(filterGenerators, &FilterGenerator{
+			FilterName: util.HeaderAllowlist,
+			FilterGenFunc: func(sc *ci.ServiceInfo) (*hcmpb.HttpFilter, []*ci.MethodInfo, error) {
+				f, err := makeHeaderAllowlistFilter(serviceInfo.Options)
+				return f, nil, err
+			},
+		})
+	}
+
 	// Add Envoy Router filter so requests are routed upstream.
 	// Router filter should be the last.
 	filterGenerators = append(filterGenerators, &FilterGenerator{
@@ -163,6 +260,66 @@ func MakeFilterGenerators(serviceInfo *ci.ServiceInfo) ([]*FilterGenerator, erro
 	return filterGenerators, nil
 }
 
+// addGrpcStatusDetailsToDescriptor adds the proto files of google.rpc.Status and
+// its standard error details to the descriptor set if it does not have them.
+// Otherwise the transcoder cannot convert error details, such as
+// BadRequest.FieldViolations, into the `details` of the JSON error body.
+// A file is skipped if the descriptor set already defines its messages, even
+// under a different file path, since duplicated message names are rejected
+// by the transcoder; the imports of the added files are pointed to that path.
+// The descriptor set is returned unchanged if it cannot be parsed.
+func addGrpcStatusDetailsToDescriptor(descriptorBin []byte) []byte {
+	descriptorSet := &descpb.FileDescriptorSet{}
+	if err := protov2.Unmarshal(descriptorBin, descriptorSet); err != nil {
+		glog.Warningf("Unable to parse the proto descriptor to add the gRPC status details, error details will not be transcoded: %v", err)
+		return descriptorBin
+	}
+
+	// Fully-qualified message name to the path of the file defining it.
+	existingMessages := make(map[string]string)
+	for _, file := range descriptorSet.GetFile() {
+		for _, msg := range file.GetMessageType() {
+			fullName := msg.GetName()
+			if file.GetPackage() != "" {
+				fullName = file.GetPackage() + "." + fullName
+			}
+			existingMessages[fullName] = file.GetName()
+		}
+	}
+
+	// Path of a skipped file to the path of the existing file defining its messages.
+	existingPaths := make(map[string]string)
+	added := false
+	for _, file := range grpcStatusDescriptorFiles {
+		msgs := file.Messages()
+		if msgs.Len() > 0 {
+			if existingPath, ok := existingMessages[string(msgs.Get(0).FullName())]; ok {
+				existingPaths[file.Path()] = existingPath
+				continue
+			}
+		}
+
+		fileProto := protodesc.ToFileDescriptorProto(file)
+		for i, dep := range fileProto.GetDependency() {
+			if existingPath, ok := existingPaths[dep]; ok {
+				fileProto.Dependency[i] = existingPath
+			}
+		}
+		descriptorSet.File = append(descriptorSet.File, fileProto)
+		added = true
+	}
+	if !added {
+		return descriptorBin
+	}
+
+	newDescriptorBin, err := protov2.Marshal(descriptorSet)
+	if err != nil {
+		glog.Warningf("Unable to marshal the proto descriptor with the gRPC status details, error details will not be transcoded: %v", err)
+		return descriptorBin
+	}
+	return newDescriptorBin
+}
+
 func makeTranscoderFilter(serviceInfo *ci.ServiceInfo) *hcmpb.HttpFilter {
 	for _, sourceFile := range serviceInfo.ServiceConfig().GetSourceInfo().GetSourceFiles() {
 		configFile := &smpb.ConfigFile{}
@@ -176,7 +333,7 @@ func makeTranscoderFilter(serviceInfo *ci.ServiceInfo) *hcmpb.HttpFilter {
 			}
 			sort.Sort(sort.StringSlice(ignoredQueryParameterList))
 
-			configContent := configFile.GetFileContents()
+			configContent := addGrpcStatusDetailsToDescriptor(configFile.GetFileContents())
 			transcodeConfig := &transcoderpb.GrpcJsonTranscoder{
 				DescriptorSet: &transcoderpb.GrpcJsonTranscoder_ProtoDescriptorBin{
 					ProtoDescriptorBin: configContent,
@@ -185,6 +342,7 @@ func makeTranscoderFilter(serviceInfo *ci.ServiceInfo) *hcmpb.HttpFilter {
 				ConvertGrpcStatus:            true,
 				IgnoredQueryParameters:       ignoredQueryParameterList,
 				IgnoreUnknownQueryParameters: serviceInfo.Options.TranscodingIgnoreUnknownQueryParameters,
+				MatchIncomingRequestRoute:    serviceInfo.Options.TranscodingMatchIncomingRequestRoute,
 				PrintOptions: &transcoderpb.GrpcJsonTranscoder_PrintOptions{
 					AlwaysPrintPrimitiveFields: serviceInfo.Options.TranscodingAlwaysPrintPrimitiveFields,
 					AlwaysPrintEnumsAsInts:     serviceInfo.Options.TranscodingAlwaysPrintEnumsAsInts,
@@ -217,6 +375,21 @@ func makeTranscoderFilter(serviceInfo *ci.ServiceInfo) *hcmpb.HttpFilter {
 	return nil
 }
 
+// The transcoder is disabled on the routes of streaming passthrough methods,
+// by a per-route config without any services.
+var transcoderPerRouteFilterConfigGen = func(method *ci.MethodInfo, httpRule *httppattern.Pattern) (*anypb.Any, error) {
+	perRouteConfig := &transcoderpb.GrpcJsonTranscoder{
+		DescriptorSet: &transcoderpb.GrpcJsonTranscoder_ProtoDescriptorBin{
+			ProtoDescriptorBin: []byte{},
+		},
+	}
+	transcoderAny, err := ptypes.MarshalAny(perRouteConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling transcoder per-route config to Any: %v", err)
+	}
+	return transcoderAny, nil
+}
+
 func makeHealthCheckFilter(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, error) {
 	hcFilterConfig := &hcpb.HealthCheck{
 		PassThroughMode: &wrapperspb.BoolValue{Value: false},
@@ -240,6 +413,188 @@ func makeHealthCheckFilter(serviceInfo *ci.ServiceInfo) (*hcmpb.HttpFilter, erro
 	}, nil
 }
 
+// makeFaultFilters makes one Fault filter per set of header matchers. Envoy
+// requires all the header matchers of a Fault filter to match, so API keys
+// from different locations need their own Fault filter. The Fault filters
+// for API keys in headers do not match requests with an API key in the query,
+// so that such requests are only subject to the fault percentages once.
+func makeFaultFilters(serviceInfo *ci.ServiceInfo) ([]*hcmpb.HttpFilter, error) {
+	opts := serviceInfo.Options
+	if opts.FaultAbortPercent < 0 || opts.FaultAbortPercent > 100 {
+		return nil, fmt.Errorf("flag --fault_abort_percent must be in [0, 100], got %v", opts.FaultAbortPercent)
+	}
+	if opts.FaultDelayPercent < 0 || opts.FaultDelayPercent > 100 {
+		return nil, fmt.Errorf("flag --fault_delay_percent must be in [0, 100], got %v", opts.FaultDelayPercent)
+	}
+
+	headerMatcherSets, err := makeFaultHeaderMatcherSets(serviceInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var faultFilters []*hcmpb.HttpFilter
+	for _, headerMatchers := range headerMatcherSets {
+		faultFilter, err := makeFaultFilter(serviceInfo.Options, headerMatchers)
+		if err != nil {
+			return nil, err
+		}
+		faultFilters = append(faultFilters, faultFilter)
+	}
+	return faultFilters, nil
+}
+
+func makeFaultHeaderMatcherSets(serviceInfo *ci.ServiceInfo) ([][]*routepb.HeaderMatcher, error) {
+	var baseMatchers []*routepb.HeaderMatcher
+	if serviceInfo.Options.FaultHeader != "" {
+		baseMatchers = append(baseMatchers, &routepb.HeaderMatcher{
+			Name: serviceInfo.Options.FaultHeader,
+			HeaderMatchSpecifier: &routepb.HeaderMatcher_PresentMatch{
+				PresentMatch: true,
+			},
+		})
+	}
+	if serviceInfo.Options.FaultApiKeys == "" {
+		return [][]*routepb.HeaderMatcher{baseMatchers}, nil
+	}
+
+	var quotedApiKeys []string
+	for _, apiKey := range strings.Split(serviceInfo.Options.FaultApiKeys, ",") {
+		apiKey = strings.TrimSpace(apiKey)
+		if apiKey == "" {
+			return nil, fmt.Errorf("flag --fault_api_keys must not have empty API keys, got %q", serviceInfo.Options.FaultApiKeys)
+		}
+		quotedApiKeys = append(quotedApiKeys, regexp.QuoteMeta(apiKey))
+	}
+	apiKeysRegex := strings.Join(quotedApiKeys, "|")
+
+	makeRegexMatcher := func(name, regex string) (*routepb.HeaderMatcher, error) {
+		if err := util.ValidateRegexProgramSize(regex, util.GoogleRE2MaxProgramSize); err != nil {
+			return nil, fmt.Errorf("invalid flag --fault_api_keys: %v", err)
+		}
+		return &routepb.HeaderMatcher{
+			Name: name,
+			HeaderMatchSpecifier: &routepb.HeaderMatcher_SafeRegexMatch{
+				SafeRegexMatch: &matcher.RegexMatcher{
+					EngineType: &matcher.RegexMatcher_GoogleRe2{
+						GoogleRe2: &matcher.RegexMatcher_GoogleRE2{},
+					},
+					Regex: regex,
+				},
+			},
+		}, nil
+	}
+
+	var headerMatcherSets [][]*routepb.HeaderMatcher
+	queryNames, headerNames := getApiKeyLocationNames(serviceInfo)
+	var pathMatcher *routepb.HeaderMatcher
+	if len(queryNames) > 0 {
+		var quotedQueryNames []string
+		for _, queryName := range queryNames {
+			quotedQueryNames = append(quotedQueryNames, regexp.QuoteMeta(queryName))
+		}
+		pathRegex := fmt.Sprintf(`[^?]*\?(.*&)?(%s)=(%s)(&.*)?`, strings.Join(quotedQueryNames, "|"), apiKeysRegex)
+		var err error
+		if pathMatcher, err = makeRegexMatcher(":path", pathRegex); err != nil {
+			return nil, err
+		}
+		headerMatcherSets = append(headerMatcherSets, append(append([]*routepb.HeaderMatcher{}, baseMatchers...), pathMatcher))
+	}
+	for _, headerName := range headerNames {
+		headerMatcher, err := makeRegexMatcher(headerName, apiKeysRegex)
+		if err != nil {
+			return nil, err
+		}
+		headerMatchers := append(append([]*routepb.HeaderMatcher{}, baseMatchers...), headerMatcher)
+		if pathMatcher != nil {
+			// The :path header is always present, so the inverted matcher only
+			// excludes requests already matched by the query Fault filter.
+			notPathMatcher := protov2.Clone(pathMatcher).(*routepb.HeaderMatcher)
+			notPathMatcher.InvertMatch = true
+			headerMatchers = append(headerMatchers, notPathMatcher)
+		}
+		headerMatcherSets = append(headerMatcherSets, headerMatchers)
+	}
+	return headerMatcherSets, nil
+}
+
+// getApiKeyLocationNames returns the sorted query parameter and header names
+// that API keys are extracted from, across all methods. Cookies are ignored.
+func getApiKeyLocationNames(serviceInfo *ci.ServiceInfo) ([]string, []string) {
+	queryNameSet := make(map[string]bool)
+	headerNameSet := make(map[string]bool)
+	for _, method := range serviceInfo.Methods {
+		if len(method.ApiKeyLocations) == 0 {
+			queryNameSet[util.DefaultApiKeyQueryParamKey] = true
+			queryNameSet[util.DefaultApiKeyQueryParamApiKey] = true
+			headerNameSet[util.DefaultApiKeyHeader] = true
+			continue
+		}
+		for _, location := range method.ApiKeyLocations {
+			if query := location.GetQuery(); query != "" {
+				queryNameSet[query] = true
+			}
+			if header := location.GetHeader(); header != "" {
+				headerNameSet[header] = true
+			}
+		}
+	}
+
+	var queryNames, headerNames []string
+	for queryName := range queryNameSet {
+		queryNames = append(queryNames, queryName)
+	}
+	for headerName := range headerNameSet {
+		headerNames = append(headerNames, headerName)
+	}
+	sort.Strings(queryNames)
+	sort.Strings(headerNames)
+	return queryNames, headerNames
+}
+
+func makeFaultFilter(opts options.ConfigGeneratorOptions, headerMatchers []*routepb.HeaderMatcher) (*hcmpb.HttpFilter, error) {
+	faultFilterConfig := &faultpb.HTTPFault{
+		Headers: headerMatchers,
+	}
+	if opts.FaultAbortPercent > 0 {
+		if opts.FaultAbortStatus < 200 || opts.FaultAbortStatus >= 600 {
+			return nil, fmt.Errorf("flag --fault_abort_status must be in [200, 600), got %v", opts.FaultAbortStatus)
+		}
+		faultFilterConfig.Abort = &faultpb.FaultAbort{
+			ErrorType: &faultpb.FaultAbort_HttpStatus{
+				HttpStatus: uint32(opts.FaultAbortStatus),
+			},
+			Percentage: makeFaultPercentage(opts.FaultAbortPercent),
+		}
+	}
+	if opts.FaultDelayPercent > 0 {
+		if opts.FaultDelayDuration <= 0 {
+			return nil, fmt.Errorf("flag --fault_delay_duration must be positive when --fault_delay_percent is set, got %v", opts.FaultDelayDuration)
+		}
+		faultFilterConfig.Delay = &commonfaultpb.FaultDelay{
+			FaultDelaySecifier: &commonfaultpb.FaultDelay_FixedDelay{
+				FixedDelay: ptypes.DurationProto(opts.FaultDelayDuration),
+			},
+			Percentage: makeFaultPercentage(opts.FaultDelayPercent),
+		}
+	}
+	faultFilterConfigStruc, err := ptypes.MarshalAny(faultFilterConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &hcmpb.HttpFilter{
+		Name:       util.Fault,
+		ConfigType: &hcmpb.HttpFilter_TypedConfig{TypedConfig: faultFilterConfigStruc},
+	}, nil
+}
+
+func makeFaultPercentage(percent float64) *typepb.FractionalPercent {
+	return &typepb.FractionalPercent{
+		// Fault percentages are accurate to 0.0001%.
+		Numerator:   uint32(math.Round(percent * 10000)),
+		Denominator: typepb.FractionalPercent_MILLION,
+	}
+}
+
 func makeRouterFilter(opts options.ConfigGeneratorOptions) *hcmpb.HttpFilter {
 	router, _ := ptypes.MarshalAny(&routerpb.Router{
 		SuppressEnvoyHeaders: opts.SuppressEnvoyHeaders,
@@ -253,6 +608,49 @@ func makeRouterFilter(opts options.ConfigGeneratorOptions) *hcmpb.HttpFilter {
 	return routerFilter
 }
 
+// makeCompressorFilter compresses responses with gzip if the client accepts it.
+// The default content types of the filter only include text formats like
+// application/json, so gRPC and gRPC-Web responses are never compressed.
+func makeCompressorFilter() (*hcmpb.HttpFilter, error) {
+	gzip, err := ptypes.MarshalAny(&gzippb.Gzip{})
+	if err != nil {
+		return nil, err
+	}
+	compressor, err := ptypes.MarshalAny(&compressorpb.Compressor{
+		CompressorLibrary: &corepb.TypedExtensionConfig{
+			Name:        util.GzipCompressor,
+			TypedConfig: gzip,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hcmpb.HttpFilter{
+		Name:       util.Compressor,
+		ConfigType: &hcmpb.HttpFilter_TypedConfig{TypedConfig: compressor},
+	}, nil
+}
+
+func makeHeaderAllowlistFilter(opts options.ConfigGeneratorOptions) (*hcmpb.HttpFilter, error) {
+	haConfig := &hapb.FilterConfig{}
+	for _, header := range strings.Split(opts.ResponseHeadersAllowlist, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			return nil, fmt.Errorf("invalid --response_headers_allowlist %q: empty header name", opts.ResponseHeadersAllowlist)
+		}
+		haConfig.ResponseHeaders = append(haConfig.ResponseHeaders, header)
+	}
+
+	haConfigStruct, err := ptypes.MarshalAny(haConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &hcmpb.HttpFilter{
+		Name:       util.HeaderAllowlist,
+		ConfigType: &hcmpb.HttpFilter_TypedConfig{TypedConfig: haConfigStruct},
+	}, nil
+}
+
 func parseDepErrorBehavior(stringVal string) (commonpb.DependencyErrorBehavior, error) {
 	depErrorBehaviorInt, ok := commonpb.DependencyErrorBehavior_value[stringVal]
 	if !ok {
@@ -15,9 +15,12 @@
 package filterconfig
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
@@ -30,6 +33,8 @@ import (
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 	smpb "google.golang.org/genproto/googleapis/api/servicemanagement/v1"
 	apipb "google.golang.org/genproto/protobuf/api"
+	protov2 "google.golang.org/protobuf/proto"
+	descpb "google.golang.org/protobuf/types/descriptorpb"
 )
 
 var (
@@ -59,6 +64,7 @@ func TestTranscoderFilter(t *testing.T) {
 		transcodingIgnoreQueryParameters        string
 		transcodingIgnoreUnknownQueryParameters bool
 		transcodingFilePath                     string
+		transcodingMatchIncomingRequestRoute    bool
 		wantTranscoderFilter                    string
 	}{
 		{
@@ -275,6 +281,47 @@ func TestTranscoderFilter(t *testing.T) {
          "%s"
       ]
    }
+}
+      `, testProtoDescriptorPath, testApiName),
+		},
+		{
+			desc: "Success. Generate transcoder filter that keeps the incoming request route",
+			fakeServiceConfig: &confpb.Service{
+				Name: testProjectName,
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+						Methods: []*apipb.Method{
+							{
+								Name: "foo",
+							},
+						},
+					},
+				},
+				SourceInfo: &confpb.SourceInfo{
+					SourceFiles: []*anypb.Any{content},
+				},
+			},
+			transcodingFilePath:                  testProtoDescriptorPath,
+			transcodingMatchIncomingRequestRoute: true,
+			wantTranscoderFilter: fmt.Sprintf(`
+{
+   "name":"envoy.filters.http.grpc_json_transcoder",
+   "typedConfig":{
+      "@type":"type.googleapis.com/envoy.extensions.filters.http.grpc_json_transcoder.v3.GrpcJsonTranscoder",
+      "autoMapping":true,
+      "convertGrpcStatus":true,
+      "ignoredQueryParameters":[
+         "api_key",
+         "key"
+      ],
+      "matchIncomingRequestRoute":true,
+      "printOptions":{},
+      "protoDescriptor":"%s",
+      "services":[
+         "%s"
+      ]
+   }
 }
       `, testProtoDescriptorPath, testApiName),
 		},
@@ -308,6 +355,7 @@ func TestTranscoderFilter(t *testing.T) {
 			opts.TranscodingIgnoreQueryParameters = tc.transcodingIgnoreQueryParameters
 			opts.TranscodingIgnoreUnknownQueryParameters = tc.transcodingIgnoreUnknownQueryParameters
 			opts.TranscodingFilePath = tc.transcodingFilePath
+			opts.TranscodingMatchIncomingRequestRoute = tc.transcodingMatchIncomingRequestRoute
 			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
 			if err != nil {
 				t.Fatal(err)
@@ -335,6 +383,184 @@ func TestTranscoderFilter(t *testing.T) {
 	}
 }
 
+func TestTranscoderPerRouteFilterConfig(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "ListShelves",
+					},
+					{
+						Name: "UploadBook",
+					},
+				},
+			},
+		},
+		SourceInfo: &confpb.SourceInfo{
+			SourceFiles: []*anypb.Any{content},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendAddress = "grpc://127.0.0.0:80"
+	opts.StreamingPassthroughOperations = "endpoints.examples.bookstore.Bookstore.UploadBook"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filterGenerators, err := MakeFilterGenerators(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var transcoderGen *FilterGenerator
+	for _, filterGenerator := range filterGenerators {
+		if filterGenerator.FilterName == util.GRPCJSONTranscoder {
+			transcoderGen = filterGenerator
+		}
+	}
+	if transcoderGen == nil {
+		t.Fatal("got no transcoder filter generator")
+	}
+
+	_, perRouteConfigRequiredMethods, err := transcoderGen.FilterGenFunc(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perRouteConfigRequiredMethods) != 1 || perRouteConfigRequiredMethods[0].Operation() != "endpoints.examples.bookstore.Bookstore.UploadBook" {
+		t.Fatalf("got methods with per-route config %v, want only UploadBook", perRouteConfigRequiredMethods)
+	}
+
+	perRouteConfig, err := transcoderGen.PerRouteConfigGenFunc(perRouteConfigRequiredMethods[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaler := &jsonpb.Marshaler{}
+	gotPerRouteConfig, err := marshaler.MarshalToString(perRouteConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPerRouteConfig := `{
+  "@type": "type.googleapis.com/envoy.extensions.filters.http.grpc_json_transcoder.v3.GrpcJsonTranscoder",
+  "protoDescriptorBin": ""
+}`
+	if err := util.JsonEqual(wantPerRouteConfig, gotPerRouteConfig); err != nil {
+		t.Errorf("transcoder per-route config mismatch,\n%v", err)
+	}
+}
+
+func TestAddGrpcStatusDetailsToDescriptor(t *testing.T) {
+	testData := []struct {
+		desc          string
+		existingFiles []*descpb.FileDescriptorProto
+		wantFiles     []string
+		wantDeps      map[string][]string
+	}{
+		{
+			desc: "Add all the files for gRPC status details",
+			existingFiles: []*descpb.FileDescriptorProto{
+				{
+					Name:        protov2.String("bookstore.proto"),
+					Package:     protov2.String("endpoints.examples.bookstore"),
+					MessageType: []*descpb.DescriptorProto{{Name: protov2.String("Shelf")}},
+				},
+			},
+			wantFiles: []string{
+				"bookstore.proto",
+				"google/protobuf/any.proto",
+				"google/protobuf/duration.proto",
+				"google/rpc/status.proto",
+				"google/rpc/error_details.proto",
+			},
+			wantDeps: map[string][]string{
+				"google/rpc/status.proto":        {"google/protobuf/any.proto"},
+				"google/rpc/error_details.proto": {"google/protobuf/duration.proto"},
+			},
+		},
+		{
+			desc: "Only add the files whose messages are missing",
+			existingFiles: []*descpb.FileDescriptorProto{
+				{
+					Name:        protov2.String("google/protobuf/any.proto"),
+					Package:     protov2.String("google.protobuf"),
+					MessageType: []*descpb.DescriptorProto{{Name: protov2.String("Any")}},
+				},
+				{
+					Name:        protov2.String("google/rpc/status.proto"),
+					Package:     protov2.String("google.rpc"),
+					Dependency:  []string{"google/protobuf/any.proto"},
+					MessageType: []*descpb.DescriptorProto{{Name: protov2.String("Status")}},
+				},
+			},
+			wantFiles: []string{
+				"google/protobuf/any.proto",
+				"google/rpc/status.proto",
+				"google/protobuf/duration.proto",
+				"google/rpc/error_details.proto",
+			},
+		},
+		{
+			desc: "Messages already defined under a different path are not added again",
+			existingFiles: []*descpb.FileDescriptorProto{
+				{
+					Name:        protov2.String("third_party/any.proto"),
+					Package:     protov2.String("google.protobuf"),
+					MessageType: []*descpb.DescriptorProto{{Name: protov2.String("Any")}},
+				},
+				{
+					Name:        protov2.String("third_party/duration.proto"),
+					Package:     protov2.String("google.protobuf"),
+					MessageType: []*descpb.DescriptorProto{{Name: protov2.String("Duration")}},
+				},
+			},
+			wantFiles: []string{
+				"third_party/any.proto",
+				"third_party/duration.proto",
+				"google/rpc/status.proto",
+				"google/rpc/error_details.proto",
+			},
+			wantDeps: map[string][]string{
+				"google/rpc/status.proto":        {"third_party/any.proto"},
+				"google/rpc/error_details.proto": {"third_party/duration.proto"},
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		descriptorSet := &descpb.FileDescriptorSet{
+			File: tc.existingFiles,
+		}
+		descriptorBin, err := protov2.Marshal(descriptorSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotDescriptorSet := &descpb.FileDescriptorSet{}
+		if err := protov2.Unmarshal(addGrpcStatusDetailsToDescriptor(descriptorBin), gotDescriptorSet); err != nil {
+			t.Fatalf("Test (%s): fail to unmarshal the descriptor set: %v", tc.desc, err)
+		}
+
+		var gotFiles []string
+		for _, file := range gotDescriptorSet.GetFile() {
+			gotFiles = append(gotFiles, file.GetName())
+			if wantDeps, ok := tc.wantDeps[file.GetName()]; ok && !reflect.DeepEqual(file.GetDependency(), wantDeps) {
+				t.Errorf("Test (%s): got dependencies of %s: %v, want: %v", tc.desc, file.GetName(), file.GetDependency(), wantDeps)
+			}
+		}
+		if !reflect.DeepEqual(gotFiles, tc.wantFiles) {
+			t.Errorf("Test (%s): got files: %v, want: %v", tc.desc, gotFiles, tc.wantFiles)
+		}
+	}
+
+	// An invalid descriptor set is returned unchanged.
+	if got := addGrpcStatusDetailsToDescriptor([]byte("rawDescriptor")); !bytes.Equal(got, []byte("rawDescriptor")) {
+		t.Errorf("got descriptor: %v, want the invalid descriptor unchanged", got)
+	}
+}
+
 func TestHealthCheckFilter(t *testing.T) {
 	testdata := []struct {
 		desc                  string
@@ -442,3 +668,639 @@ func TestHealthCheckFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestFaultFilters(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "ListShelves",
+					},
+					{
+						Name: "CreateShelf",
+					},
+				},
+			},
+		},
+	}
+	fakeServiceConfigWithApiKeyLocations := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "ListShelves",
+					},
+				},
+			},
+		},
+		SystemParameters: &confpb.SystemParameters{
+			Rules: []*confpb.SystemParameterRule{
+				{
+					Selector: fmt.Sprintf("%s.ListShelves", testApiName),
+					Parameters: []*confpb.SystemParameter{
+						{
+							Name:              "api_key",
+							HttpHeader:        "header_name",
+							UrlQueryParameter: "query_name",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	testdata := []struct {
+		desc              string
+		fakeServiceConfig *confpb.Service
+		optsMergeFunc     func(opts *options.ConfigGeneratorOptions)
+		wantFaultFilters  []string
+		wantError         string
+	}{
+		{
+			desc:              "Success, abort all requests",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultAbortPercent = 100
+				opts.FaultAbortStatus = 418
+			},
+			wantFaultFilters: []string{`{
+        "name": "envoy.filters.http.fault",
+        "typedConfig": {
+          "@type":"type.googleapis.com/envoy.extensions.filters.http.fault.v3.HTTPFault",
+          "abort": {
+            "httpStatus": 418,
+            "percentage": {
+              "numerator": 1000000,
+              "denominator": "MILLION"
+            }
+          }
+        }
+      }`},
+		},
+		{
+			desc:              "Success, delay some requests with the fault header",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultDelayPercent = 12.5
+				opts.FaultDelayDuration = 2 * time.Second
+				opts.FaultHeader = "x-inject-fault"
+			},
+			wantFaultFilters: []string{`{
+        "name": "envoy.filters.http.fault",
+        "typedConfig": {
+          "@type":"type.googleapis.com/envoy.extensions.filters.http.fault.v3.HTTPFault",
+          "delay": {
+            "fixedDelay": "2s",
+            "percentage": {
+              "numerator": 125000,
+              "denominator": "MILLION"
+            }
+          },
+          "headers": [
+            {
+              "name": "x-inject-fault",
+              "presentMatch": true
+            }
+          ]
+        }
+      }`},
+		},
+		{
+			desc:              "Success, abort requests with the API keys in the default locations",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultAbortPercent = 100
+				opts.FaultApiKeys = "qa-key,qa.key2"
+			},
+			wantFaultFilters: []string{`{
+        "name": "envoy.filters.http.fault",
+        "typedConfig": {
+          "@type":"type.googleapis.com/envoy.extensions.filters.http.fault.v3.HTTPFault",
+          "abort": {
+            "httpStatus": 503,
+            "percentage": {
+              "numerator": 1000000,
+              "denominator": "MILLION"
+            }
+          },
+          "headers": [
+            {
+              "name": ":path",
+              "safeRegexMatch": {
+                "googleRe2": {},
+                "regex": "[^?]*\\?(.*&)?(api_key|key)=(qa-key|qa\\.key2)(&.*)?"
+              }
+            }
+          ]
+        }
+      }`, `{
+        "name": "envoy.filters.http.fault",
+        "typedConfig": {
+          "@type":"type.googleapis.com/envoy.extensions.filters.http.fault.v3.HTTPFault",
+          "abort": {
+            "httpStatus": 503,
+            "percentage": {
+              "numerator": 1000000,
+              "denominator": "MILLION"
+            }
+          },
+          "headers": [
+            {
+              "name": "x-api-key",
+              "safeRegexMatch": {
+                "googleRe2": {},
+                "regex": "qa-key|qa\\.key2"
+              }
+            },
+            {
+              "name": ":path",
+              "invertMatch": true,
+              "safeRegexMatch": {
+                "googleRe2": {},
+                "regex": "[^?]*\\?(.*&)?(api_key|key)=(qa-key|qa\\.key2)(&.*)?"
+              }
+            }
+          ]
+        }
+      }`},
+		},
+		{
+			desc:              "Success, abort requests with the API key in the custom locations and the fault header",
+			fakeServiceConfig: fakeServiceConfigWithApiKeyLocations,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultAbortPercent = 100
+				opts.FaultApiKeys = "qa-key"
+				opts.FaultHeader = "x-inject-fault"
+			},
+			wantFaultFilters: []string{`{
+        "name": "envoy.filters.http.fault",
+        "typedConfig": {
+          "@type":"type.googleapis.com/envoy.extensions.filters.http.fault.v3.HTTPFault",
+          "abort": {
+            "httpStatus": 503,
+            "percentage": {
+              "numerator": 1000000,
+              "denominator": "MILLION"
+            }
+          },
+          "headers": [
+            {
+              "name": "x-inject-fault",
+              "presentMatch": true
+            },
+            {
+              "name": ":path",
+              "safeRegexMatch": {
+                "googleRe2": {},
+                "regex": "[^?]*\\?(.*&)?(query_name)=(qa-key)(&.*)?"
+              }
+            }
+          ]
+        }
+      }`, `{
+        "name": "envoy.filters.http.fault",
+        "typedConfig": {
+          "@type":"type.googleapis.com/envoy.extensions.filters.http.fault.v3.HTTPFault",
+          "abort": {
+            "httpStatus": 503,
+            "percentage": {
+              "numerator": 1000000,
+              "denominator": "MILLION"
+            }
+          },
+          "headers": [
+            {
+              "name": "x-inject-fault",
+              "presentMatch": true
+            },
+            {
+              "name": "header_name",
+              "safeRegexMatch": {
+                "googleRe2": {},
+                "regex": "qa-key"
+              }
+            },
+            {
+              "name": ":path",
+              "invertMatch": true,
+              "safeRegexMatch": {
+                "googleRe2": {},
+                "regex": "[^?]*\\?(.*&)?(query_name)=(qa-key)(&.*)?"
+              }
+            }
+          ]
+        }
+      }`},
+		},
+		{
+			desc:              "Failure, empty API key",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultAbortPercent = 100
+				opts.FaultApiKeys = "qa-key,"
+			},
+			wantError: `flag --fault_api_keys must not have empty API keys, got "qa-key,"`,
+		},
+		{
+			desc:              "Failure, abort percent is out of range",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultAbortPercent = 101
+			},
+			wantError: "flag --fault_abort_percent must be in [0, 100], got 101",
+		},
+		{
+			desc:              "Failure, delay percent is negative",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultDelayPercent = -10
+				opts.FaultDelayDuration = 2 * time.Second
+			},
+			wantError: "flag --fault_delay_percent must be in [0, 100], got -10",
+		},
+		{
+			desc:              "Failure, abort status is not an HTTP status",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultAbortPercent = 50
+				opts.FaultAbortStatus = 42
+			},
+			wantError: "flag --fault_abort_status must be in [200, 600), got 42",
+		},
+		{
+			desc:              "Failure, delay duration is not set",
+			fakeServiceConfig: fakeServiceConfig,
+			optsMergeFunc: func(opts *options.ConfigGeneratorOptions) {
+				opts.FaultDelayPercent = 50
+			},
+			wantError: "flag --fault_delay_duration must be positive when --fault_delay_percent is set, got 0s",
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			tc.optsMergeFunc(&opts)
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filters, err := makeFaultFilters(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("makeFaultFilters got error: %v, want error: %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(filters) != len(tc.wantFaultFilters) {
+				t.Fatalf("makeFaultFilters got %d filters, want %d", len(filters), len(tc.wantFaultFilters))
+			}
+			marshaler := &jsonpb.Marshaler{}
+			for i, filter := range filters {
+				gotFilter, err := marshaler.MarshalToString(filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := util.JsonEqual(tc.wantFaultFilters[i], gotFilter); err != nil {
+					t.Errorf("makeFaultFilters failed for filter %d,\n%v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeFilterGeneratorsNegativeFaultPercent(t *testing.T) {
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.FaultAbortPercent = -1
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(&confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+			},
+		},
+	}, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantError := "flag --fault_abort_percent must be in [0, 100], got -1"
+	if _, err := MakeFilterGenerators(fakeServiceInfo); err == nil || err.Error() != wantError {
+		t.Errorf("MakeFilterGenerators got error: %v, want error: %v", err, wantError)
+	}
+}
+
+func TestHeaderAllowlistFilter(t *testing.T) {
+	testdata := []struct {
+		desc                      string
+		responseHeadersAllowlist  string
+		wantHeaderAllowlistFilter string
+		wantError                 string
+	}{
+		{
+			desc:                     "Success, generate header allowlist filter",
+			responseHeadersAllowlist: "x-request-id, Cache-Control",
+			wantHeaderAllowlistFilter: `{
+        "name": "com.google.espv2.filters.http.header_allowlist",
+        "typedConfig": {
+          "@type":"type.googleapis.com/espv2.api.envoy.v10.http.header_allowlist.FilterConfig",
+          "responseHeaders": [
+            "x-request-id",
+            "Cache-Control"
+          ]
+        }
+      }`,
+		},
+		{
+			desc:                     "Failure, empty header name",
+			responseHeadersAllowlist: "x-request-id,,Cache-Control",
+			wantError:                `invalid --response_headers_allowlist "x-request-id,,Cache-Control": empty header name`,
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.ResponseHeadersAllowlist = tc.responseHeadersAllowlist
+
+			filter, err := makeHeaderAllowlistFilter(opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("makeHeaderAllowlistFilter got error: %v, want error: %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			marshaler := &jsonpb.Marshaler{}
+			gotFilter, err := marshaler.MarshalToString(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := util.JsonEqual(tc.wantHeaderAllowlistFilter, gotFilter); err != nil {
+				t.Errorf("makeHeaderAllowlistFilter failed,\n%v", err)
+			}
+		})
+	}
+}
+
+func TestCompressorFilter(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+				},
+			},
+		},
+	}
+
+	testdata := []struct {
+		desc                      string
+		enableResponseCompression bool
+		wantFilterNames           []string
+	}{
+		{
+			desc: "No compressor filter by default",
+			wantFilterNames: []string{
+				util.JwtAuthn,
+				util.ServiceControl,
+				util.GRPCWeb,
+				util.GRPCJSONTranscoder,
+				util.BackendAuth,
+				util.PathRewrite,
+				util.Buffer,
+				util.GrpcMetadataScrubber,
+				util.Router,
+			},
+		},
+		{
+			desc:                      "Compressor filter is before the gRPC transcoder filter",
+			enableResponseCompression: true,
+			wantFilterNames: []string{
+				util.JwtAuthn,
+				util.ServiceControl,
+				util.Compressor,
+				util.GRPCWeb,
+				util.GRPCJSONTranscoder,
+				util.BackendAuth,
+				util.PathRewrite,
+				util.Buffer,
+				util.GrpcMetadataScrubber,
+				util.Router,
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "grpc://127.0.0.0:80"
+			opts.EnableResponseCompression = tc.enableResponseCompression
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filterGenerators, err := MakeFilterGenerators(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotFilterNames []string
+			for _, filterGenerator := range filterGenerators {
+				gotFilterNames = append(gotFilterNames, filterGenerator.FilterName)
+			}
+			if !reflect.DeepEqual(gotFilterNames, tc.wantFilterNames) {
+				t.Errorf("MakeFilterGenerators got filters %v, want %v", gotFilterNames, tc.wantFilterNames)
+			}
+		})
+	}
+
+	filter, err := makeCompressorFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaler := &jsonpb.Marshaler{}
+	gotFilter, err := marshaler.MarshalToString(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFilter := `{
+    "name": "envoy.filters.http.compressor",
+    "typedConfig": {
+      "@type": "type.googleapis.com/envoy.extensions.filters.http.compressor.v3.Compressor",
+      "compressorLibrary": {
+        "name": "envoy.compression.gzip.compressor",
+        "typedConfig": {
+          "@type": "type.googleapis.com/envoy.extensions.compression.gzip.compressor.v3.Gzip"
+        }
+      }
+    }
+  }`
+	if err := util.JsonEqual(wantFilter, gotFilter); err != nil {
+		t.Errorf("makeCompressorFilter failed,\n%v", err)
+	}
+}
+
+func TestDisableTranscoding(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+				},
+			},
+		},
+	}
+
+	testdata := []struct {
+		desc               string
+		disableTranscoding bool
+		wantFilterNames    []string
+	}{
+		{
+			desc: "gRPC transcoder filter is added by default",
+			wantFilterNames: []string{
+				util.JwtAuthn,
+				util.ServiceControl,
+				util.GRPCWeb,
+				util.GRPCJSONTranscoder,
+				util.BackendAuth,
+				util.PathRewrite,
+				util.Buffer,
+				util.GrpcMetadataScrubber,
+				util.Router,
+			},
+		},
+		{
+			desc:               "gRPC transcoder filter is omitted, other filters are kept",
+			disableTranscoding: true,
+			wantFilterNames: []string{
+				util.JwtAuthn,
+				util.ServiceControl,
+				util.GRPCWeb,
+				util.BackendAuth,
+				util.PathRewrite,
+				util.Buffer,
+				util.GrpcMetadataScrubber,
+				util.Router,
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "grpc://127.0.0.0:80"
+			opts.DisableTranscoding = tc.disableTranscoding
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filterGenerators, err := MakeFilterGenerators(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotFilterNames []string
+			for _, filterGenerator := range filterGenerators {
+				gotFilterNames = append(gotFilterNames, filterGenerator.FilterName)
+			}
+			if !reflect.DeepEqual(gotFilterNames, tc.wantFilterNames) {
+				t.Errorf("MakeFilterGenerators got filters %v, want %v", gotFilterNames, tc.wantFilterNames)
+			}
+		})
+	}
+}
+
+func TestAuthFilterOrder(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+				},
+			},
+		},
+	}
+
+	testdata := []struct {
+		desc                 string
+		checkApiKeyBeforeJwt bool
+		wantFilterNames      []string
+	}{
+		{
+			desc: "JWT is verified before the API key is checked by default",
+			wantFilterNames: []string{
+				util.JwtAuthn,
+				util.ServiceControl,
+				util.BackendAuth,
+				util.PathRewrite,
+				util.Buffer,
+				util.GrpcMetadataScrubber,
+				util.Router,
+			},
+		},
+		{
+			desc:                 "API key is checked before the JWT is verified",
+			checkApiKeyBeforeJwt: true,
+			wantFilterNames: []string{
+				util.ServiceControl,
+				util.JwtAuthn,
+				util.BackendAuth,
+				util.PathRewrite,
+				util.Buffer,
+				util.GrpcMetadataScrubber,
+				util.Router,
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.CheckApiKeyBeforeJwt = tc.checkApiKeyBeforeJwt
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filterGenerators, err := MakeFilterGenerators(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotFilterNames []string
+			for _, filterGenerator := range filterGenerators {
+				gotFilterNames = append(gotFilterNames, filterGenerator.FilterName)
+			}
+			if !reflect.DeepEqual(gotFilterNames, tc.wantFilterNames) {
+				t.Errorf("MakeFilterGenerators got filters %v, want %v", gotFilterNames, tc.wantFilterNames)
+			}
+		})
+	}
+}
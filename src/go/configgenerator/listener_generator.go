@@ -15,7 +15,16 @@
 package configgenerator
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/configgenerator/filterconfig"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
@@ -31,6 +40,8 @@ import (
 	listenerpb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	facpb "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	gacpb "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	ppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/proxy_protocol/v3"
 	hcmpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	wrapperspb "github.com/golang/protobuf/ptypes/wrappers"
@@ -128,9 +139,36 @@ func MakeListener(serviceInfo *sc.ServiceInfo, filterGenerators []*filterconfig.
 	}
 
 	if serviceInfo.Options.SslServerCertPath != "" {
+		rootCertPath := serviceInfo.Options.SslServerRootCertPath
+		var allowedSpiffeIds []string
+		if serviceInfo.Options.SslServerSpiffeTrustBundlePath != "" {
+			if rootCertPath != "" {
+				return nil, fmt.Errorf("flags --ssl_server_root_cert_path and --ssl_server_spiffe_trust_bundle_path cannot be both set")
+			}
+			rootCertPath = serviceInfo.Options.SslServerSpiffeTrustBundlePath
+		}
+		if serviceInfo.Options.SslServerAllowedSpiffeIds != "" {
+			if serviceInfo.Options.SslServerSpiffeTrustBundlePath == "" {
+				return nil, fmt.Errorf("flag --ssl_server_allowed_spiffe_ids requires --ssl_server_spiffe_trust_bundle_path")
+			}
+			for _, spiffeId := range strings.Split(serviceInfo.Options.SslServerAllowedSpiffeIds, ",") {
+				allowedSpiffeIds = append(allowedSpiffeIds, strings.TrimSpace(spiffeId))
+			}
+		}
+
+		var crlSdsPath string
+		if serviceInfo.Options.ClientCrlFile != "" {
+			if rootCertPath == "" {
+				return nil, fmt.Errorf("flag --client_crl_file requires --ssl_server_root_cert_path or --ssl_server_spiffe_trust_bundle_path")
+			}
+			crlSdsPath = serviceInfo.Options.ClientCrlSdsPath
+		}
+
 		transportSocket, err := util.CreateDownstreamTransportSocket(
 			serviceInfo.Options.SslServerCertPath,
-			serviceInfo.Options.SslServerRootCertPath,
+			rootCertPath,
+			allowedSpiffeIds,
+			crlSdsPath,
 			serviceInfo.Options.SslMinimumProtocol,
 			serviceInfo.Options.SslMaximumProtocol,
 			serviceInfo.Options.SslServerCipherSuites,
@@ -141,6 +179,10 @@ func MakeListener(serviceInfo *sc.ServiceInfo, filterGenerators []*filterconfig.
 		filterChain.TransportSocket = transportSocket
 	}
 
+	if net.ParseIP(serviceInfo.Options.ListenerAddress) == nil {
+		return nil, fmt.Errorf("flag --listener_address must be an IP address, got %q", serviceInfo.Options.ListenerAddress)
+	}
+
 	listener := &listenerpb.Listener{
 		Name: util.IngressListenerName,
 		Address: &corepb.Address{
@@ -156,6 +198,28 @@ func MakeListener(serviceInfo *sc.ServiceInfo, filterGenerators []*filterconfig.
 		FilterChains: []*listenerpb.FilterChain{filterChain},
 	}
 
+	socketOptions, err := makeListenerSocketOptions(&serviceInfo.Options)
+	if err != nil {
+		return nil, err
+	}
+	listener.SocketOptions = socketOptions
+	listener.ReusePort = serviceInfo.Options.EnableReusePort
+
+	if serviceInfo.Options.EnableProxyProtocol {
+		proxyProtocol, err := ptypes.MarshalAny(&ppb.ProxyProtocol{})
+		if err != nil {
+			return nil, err
+		}
+		listener.ListenerFilters = []*listenerpb.ListenerFilter{
+			{
+				Name: util.ProxyProtocol,
+				ConfigType: &listenerpb.ListenerFilter_TypedConfig{
+					TypedConfig: proxyProtocol,
+				},
+			},
+		}
+	}
+
 	if serviceInfo.Options.ConnectionBufferLimitBytes >= 0 {
 		listener.PerConnectionBufferLimitBytes = &wrapperspb.UInt32Value{
 			Value: uint32(serviceInfo.Options.ConnectionBufferLimitBytes),
@@ -165,7 +229,100 @@ func MakeListener(serviceInfo *sc.ServiceInfo, filterGenerators []*filterconfig.
 	return listener, nil
 }
 
+// ReadClientCrl reads the certificate revocation list of --client_crl_file. It
+// is checked here as Envoy rejects the whole secret on an invalid CRL.
+func ReadClientCrl(path string) ([]byte, error) {
+	crl, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read flag --client_crl_file: %v", err)
+	}
+	block, _ := pem.Decode(crl)
+	if block == nil || block.Type != "X509 CRL" {
+		return nil, fmt.Errorf("flag --client_crl_file must be a PEM certificate revocation list, got %q", path)
+	}
+	if _, err := x509.ParseDERCRL(block.Bytes); err != nil {
+		return nil, fmt.Errorf("invalid flag --client_crl_file %q: %v", path, err)
+	}
+	return crl, nil
+}
+
+// Linux socket option levels and names used for TCP keepalive. Accepted
+// connections inherit these options from the listening socket.
+const (
+	solSocket    = 1
+	soKeepalive  = 9
+	ipprotoTcp   = 6
+	tcpKeepidle  = 4
+	tcpKeepintvl = 5
+	tcpKeepcnt   = 6
+)
+
+func makeListenerSocketOptions(opts *options.ConfigGeneratorOptions) ([]*corepb.SocketOption, error) {
+	if opts.ListenerTcpKeepaliveTime == 0 {
+		if opts.ListenerTcpKeepaliveInterval != 0 || opts.ListenerTcpKeepaliveProbes != 0 {
+			return nil, fmt.Errorf("flags --listener_tcp_keepalive_interval and --listener_tcp_keepalive_probes require --listener_tcp_keepalive_time")
+		}
+		return nil, nil
+	}
+	if opts.ListenerTcpKeepaliveTime < time.Second {
+		return nil, fmt.Errorf("flag --listener_tcp_keepalive_time must be at least 1s, got %v", opts.ListenerTcpKeepaliveTime)
+	}
+	if opts.ListenerTcpKeepaliveInterval != 0 && opts.ListenerTcpKeepaliveInterval < time.Second {
+		return nil, fmt.Errorf("flag --listener_tcp_keepalive_interval must be at least 1s, got %v", opts.ListenerTcpKeepaliveInterval)
+	}
+	if opts.ListenerTcpKeepaliveProbes < 0 {
+		return nil, fmt.Errorf("flag --listener_tcp_keepalive_probes cannot be negative, got %v", opts.ListenerTcpKeepaliveProbes)
+	}
+
+	socketOptions := []*corepb.SocketOption{
+		makeListenerSocketOption("SO_KEEPALIVE", solSocket, soKeepalive, 1),
+		makeListenerSocketOption("TCP_KEEPIDLE", ipprotoTcp, tcpKeepidle, int64(opts.ListenerTcpKeepaliveTime/time.Second)),
+	}
+	if opts.ListenerTcpKeepaliveInterval != 0 {
+		socketOptions = append(socketOptions, makeListenerSocketOption("TCP_KEEPINTVL", ipprotoTcp, tcpKeepintvl, int64(opts.ListenerTcpKeepaliveInterval/time.Second)))
+	}
+	if opts.ListenerTcpKeepaliveProbes != 0 {
+		socketOptions = append(socketOptions, makeListenerSocketOption("TCP_KEEPCNT", ipprotoTcp, tcpKeepcnt, int64(opts.ListenerTcpKeepaliveProbes)))
+	}
+	return socketOptions, nil
+}
+
+func makeListenerSocketOption(description string, level, name, value int64) *corepb.SocketOption {
+	return &corepb.SocketOption{
+		Description: description,
+		Level:       level,
+		Name:        name,
+		Value: &corepb.SocketOption_IntValue{
+			IntValue: value,
+		},
+		State: corepb.SocketOption_STATE_PREBIND,
+	}
+}
+
+// The Envoy default and maximum sizes of all request headers.
+const (
+	defaultMaxRequestHeadersKb = 60
+	maxMaxRequestHeadersKb     = 8192
+)
+
+// The values of --forward_client_cert_details.
+var forwardClientCertDetails = map[string]hcmpb.HttpConnectionManager_ForwardClientCertDetails{
+	"sanitize":            hcmpb.HttpConnectionManager_SANITIZE,
+	"forward_only":        hcmpb.HttpConnectionManager_FORWARD_ONLY,
+	"append_forward":      hcmpb.HttpConnectionManager_APPEND_FORWARD,
+	"sanitize_set":        hcmpb.HttpConnectionManager_SANITIZE_SET,
+	"always_forward_only": hcmpb.HttpConnectionManager_ALWAYS_FORWARD_ONLY,
+}
+
 func makeHttpConMgr(opts *options.ConfigGeneratorOptions, route *routepb.RouteConfiguration) (*hcmpb.HttpConnectionManager, error) {
+	if opts.MaxRequestHeadersKb < 0 || opts.MaxRequestHeadersKb > maxMaxRequestHeadersKb {
+		return nil, fmt.Errorf("flag --max_request_headers_kb must be in [0, %v], where 0 uses the default of %v, got %v", maxMaxRequestHeadersKb, defaultMaxRequestHeadersKb, opts.MaxRequestHeadersKb)
+	}
+	maxRequestHeadersKb := defaultMaxRequestHeadersKb
+	if opts.MaxRequestHeadersKb > 0 {
+		maxRequestHeadersKb = opts.MaxRequestHeadersKb
+	}
+
 	httpConMgr := &hcmpb.HttpConnectionManager{
 		UpgradeConfigs: []*hcmpb.HttpConnectionManager_UpgradeConfig{
 			{
@@ -201,16 +358,142 @@ func makeHttpConMgr(opts *options.ConfigGeneratorOptions, route *routepb.RouteCo
 					},
 				},
 			},
+			// Requests with oversized headers, usually a large JWT, are
+			// rejected by the codec before any filter. Reply with 413 and
+			// explain why, instead of the bare 431 "Request Header Fields Too
+			// Large". Only the codec rejection has both the 431 status and the
+			// downstream protocol error flag.
+			Mappers: []*hcmpb.ResponseMapper{
+				{
+					Filter: &acpb.AccessLogFilter{
+						FilterSpecifier: &acpb.AccessLogFilter_AndFilter{
+							AndFilter: &acpb.AndFilter{
+								Filters: []*acpb.AccessLogFilter{
+									{
+										FilterSpecifier: &acpb.AccessLogFilter_StatusCodeFilter{
+											StatusCodeFilter: &acpb.StatusCodeFilter{
+												Comparison: &acpb.ComparisonFilter{
+													Op: acpb.ComparisonFilter_EQ,
+													Value: &corepb.RuntimeUInt32{
+														DefaultValue: http.StatusRequestHeaderFieldsTooLarge,
+														RuntimeKey:   "espv2.request_headers_too_large",
+													},
+												},
+											},
+										},
+									},
+									{
+										FilterSpecifier: &acpb.AccessLogFilter_ResponseFlagFilter{
+											ResponseFlagFilter: &acpb.ResponseFlagFilter{
+												Flags: []string{"DPE"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					StatusCode: &wrapperspb.UInt32Value{Value: http.StatusRequestEntityTooLarge},
+					Body: &corepb.DataSource{
+						Specifier: &corepb.DataSource_InlineString{
+							InlineString: fmt.Sprintf("Request headers, including any JWT, exceed the %v KB limit.", maxRequestHeadersKb),
+						},
+					},
+				},
+				// Requests with oversized bodies are rejected with 413 by the
+				// filter buffering them. Explain why, instead of the bare
+				// "Payload Too Large".
+				{
+					Filter: &acpb.AccessLogFilter{
+						FilterSpecifier: &acpb.AccessLogFilter_StatusCodeFilter{
+							StatusCodeFilter: &acpb.StatusCodeFilter{
+								Comparison: &acpb.ComparisonFilter{
+									Op: acpb.ComparisonFilter_EQ,
+									Value: &corepb.RuntimeUInt32{
+										DefaultValue: http.StatusRequestEntityTooLarge,
+										RuntimeKey:   "espv2.request_body_too_large",
+									},
+								},
+							},
+						},
+					},
+					Body: &corepb.DataSource{
+						Specifier: &corepb.DataSource_InlineString{
+							InlineString: "Request body exceeds the buffer limit.",
+						},
+					},
+				},
+			},
 		},
 		// Security options for `path` header.
 		NormalizePath: &wrapperspb.BoolValue{Value: opts.NormalizePath},
 		MergeSlashes:  opts.MergeSlashesInPath,
 	}
 
+	if opts.ListenerHttp2KeepaliveInterval != 0 {
+		if opts.ListenerHttp2KeepaliveInterval < time.Second {
+			return nil, fmt.Errorf("flag --listener_http2_keepalive_interval must be at least 1s, got %v", opts.ListenerHttp2KeepaliveInterval)
+		}
+		if opts.ListenerHttp2KeepaliveTimeout <= 0 {
+			return nil, fmt.Errorf("flag --listener_http2_keepalive_timeout must be positive, got %v", opts.ListenerHttp2KeepaliveTimeout)
+		}
+		// Envoy sends PINGs on idle downstream HTTP/2 connections and closes
+		// the ones that don't answer in time.
+		httpConMgr.Http2ProtocolOptions = &corepb.Http2ProtocolOptions{
+			ConnectionKeepalive: &corepb.KeepaliveSettings{
+				Interval: ptypes.DurationProto(opts.ListenerHttp2KeepaliveInterval),
+				Timeout:  ptypes.DurationProto(opts.ListenerHttp2KeepaliveTimeout),
+			},
+		}
+	}
+
+	if opts.ListenerHttp2MaxConcurrentStreams != 0 {
+		if opts.ListenerHttp2MaxConcurrentStreams < 1 || opts.ListenerHttp2MaxConcurrentStreams > math.MaxInt32 {
+			return nil, fmt.Errorf("flag --listener_http2_max_concurrent_streams must be in [1, %v], got %v", math.MaxInt32, opts.ListenerHttp2MaxConcurrentStreams)
+		}
+		if httpConMgr.Http2ProtocolOptions == nil {
+			httpConMgr.Http2ProtocolOptions = &corepb.Http2ProtocolOptions{}
+		}
+		httpConMgr.Http2ProtocolOptions.MaxConcurrentStreams = &wrapperspb.UInt32Value{Value: uint32(opts.ListenerHttp2MaxConcurrentStreams)}
+	}
+
+	if opts.BackendUnavailableMessage != "" {
+		// Envoy replies 503 with these response flags: UF for upstream
+		// connection failure, UH for no healthy upstream and UO for upstream
+		// overflow, i.e. circuit breaking.
+		httpConMgr.LocalReplyConfig.Mappers = append(httpConMgr.LocalReplyConfig.Mappers, &hcmpb.ResponseMapper{
+			Filter: &acpb.AccessLogFilter{
+				FilterSpecifier: &acpb.AccessLogFilter_ResponseFlagFilter{
+					ResponseFlagFilter: &acpb.ResponseFlagFilter{
+						Flags: []string{"UF", "UH", "UO"},
+					},
+				},
+			},
+			Body: &corepb.DataSource{
+				Specifier: &corepb.DataSource_InlineString{
+					InlineString: opts.BackendUnavailableMessage,
+				},
+			},
+		})
+	}
+
+	if err := setClientCertDetails(httpConMgr, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.MaxRequestHeadersKb > 0 {
+		httpConMgr.MaxRequestHeadersKb = &wrapperspb.UInt32Value{Value: uint32(opts.MaxRequestHeadersKb)}
+	}
+
 	// https://github.com/envoyproxy/envoy/security/advisories/GHSA-4987-27fx-x6cf
-	if opts.DisallowEscapedSlashesInPath {
+	switch {
+	case opts.DisallowEscapedSlashesInPath && opts.DecodeEscapedSlashesInPath:
+		return nil, fmt.Errorf("flag --decode_escaped_slashes_in_path cannot be used with --disallow_escaped_slashes_in_path")
+	case opts.DisallowEscapedSlashesInPath:
 		httpConMgr.PathWithEscapedSlashesAction = hcmpb.HttpConnectionManager_UNESCAPE_AND_REDIRECT
-	} else {
+	case opts.DecodeEscapedSlashesInPath:
+		httpConMgr.PathWithEscapedSlashesAction = hcmpb.HttpConnectionManager_UNESCAPE_AND_FORWARD
+	default:
 		httpConMgr.PathWithEscapedSlashesAction = hcmpb.HttpConnectionManager_KEEP_UNCHANGED
 	}
 
@@ -242,6 +525,35 @@ func makeHttpConMgr(opts *options.ConfigGeneratorOptions, route *routepb.RouteCo
 		}
 	}
 
+	if opts.AccessLogGrpcAddress != "" {
+		if opts.AccessLogGrpcLogName == "" {
+			return nil, fmt.Errorf("flag --access_log_grpc_log_name must not be empty with --access_log_grpc_address")
+		}
+		grpcAccessLog, err := ptypes.MarshalAny(&gacpb.HttpGrpcAccessLogConfig{
+			CommonConfig: &gacpb.CommonGrpcAccessLogConfig{
+				LogName: opts.AccessLogGrpcLogName,
+				GrpcService: &corepb.GrpcService{
+					TargetSpecifier: &corepb.GrpcService_EnvoyGrpc_{
+						EnvoyGrpc: &corepb.GrpcService_EnvoyGrpc{
+							ClusterName: util.AccessLogGrpcClusterName,
+						},
+					},
+				},
+				TransportApiVersion: corepb.ApiVersion_V3,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		httpConMgr.AccessLog = append(httpConMgr.AccessLog, &acpb.AccessLog{
+			Name: util.AccessGrpcLogger,
+			ConfigType: &acpb.AccessLog_TypedConfig{
+				TypedConfig: grpcAccessLog,
+			},
+		})
+	}
+
 	if !opts.DisableTracing {
 		var err error
 		httpConMgr.Tracing, err = tracing.CreateTracing(opts.CommonOptions)
@@ -260,6 +572,15 @@ func makeHttpConMgr(opts *options.ConfigGeneratorOptions, route *routepb.RouteCo
 		}
 	}
 
+	if opts.MaxRequestDuration < 0 {
+		return nil, fmt.Errorf("flag --max_request_duration must not be negative, got %v", opts.MaxRequestDuration)
+	}
+	if opts.MaxRequestDuration > 0 {
+		// Envoy replies 504 to a stream reaching it after the whole request is
+		// received, 408 before that, and resets it once the response started.
+		httpConMgr.CommonHttpProtocolOptions.MaxStreamDuration = ptypes.DurationProto(opts.MaxRequestDuration)
+	}
+
 	if opts.EnableGrpcForHttp1 {
 		// Retain gRPC trailers if downstream is using http1.
 		httpConMgr.HttpProtocolOptions = &corepb.Http1ProtocolOptions{
@@ -267,5 +588,89 @@ func makeHttpConMgr(opts *options.ConfigGeneratorOptions, route *routepb.RouteCo
 		}
 	}
 
+	if opts.AcceptHttp10 {
+		if httpConMgr.HttpProtocolOptions == nil {
+			httpConMgr.HttpProtocolOptions = &corepb.Http1ProtocolOptions{}
+		}
+		// HTTP/1.0 requests may not have a Host header, the default host is
+		// used for them instead.
+		httpConMgr.HttpProtocolOptions.AcceptHttp_10 = true
+		httpConMgr.HttpProtocolOptions.DefaultHostForHttp_10 = opts.DefaultHostForHttp10
+	} else if opts.DefaultHostForHttp10 != "" {
+		return nil, fmt.Errorf("flag --default_host_for_http_10 requires --accept_http_10")
+	}
+
 	return httpConMgr, nil
 }
+
+// setClientCertDetails configures how the X-Forwarded-Client-Cert header is
+// sent to the backend.
+func setClientCertDetails(httpConMgr *hcmpb.HttpConnectionManager, opts *options.ConfigGeneratorOptions) error {
+	if opts.ForwardClientCertDetails == "" {
+		if opts.SetCurrentClientCertDetails != "" {
+			return fmt.Errorf("flag --set_current_client_cert_details requires --forward_client_cert_details to be append_forward or sanitize_set")
+		}
+		return nil
+	}
+
+	details, ok := forwardClientCertDetails[opts.ForwardClientCertDetails]
+	if !ok {
+		return fmt.Errorf("invalid value %q for --forward_client_cert_details", opts.ForwardClientCertDetails)
+	}
+	httpConMgr.ForwardClientCertDetails = details
+
+	if opts.SetCurrentClientCertDetails == "" {
+		return nil
+	}
+	if details != hcmpb.HttpConnectionManager_APPEND_FORWARD && details != hcmpb.HttpConnectionManager_SANITIZE_SET {
+		return fmt.Errorf("flag --set_current_client_cert_details requires --forward_client_cert_details to be append_forward or sanitize_set, got %v", opts.ForwardClientCertDetails)
+	}
+
+	setDetails := &hcmpb.HttpConnectionManager_SetCurrentClientCertDetails{}
+	for _, field := range strings.Split(opts.SetCurrentClientCertDetails, ",") {
+		switch strings.TrimSpace(field) {
+		case "subject":
+			setDetails.Subject = &wrapperspb.BoolValue{Value: true}
+		case "uri":
+			setDetails.Uri = true
+		case "dns":
+			setDetails.Dns = true
+		case "cert":
+			setDetails.Cert = true
+		case "chain":
+			setDetails.Chain = true
+		default:
+			return fmt.Errorf("invalid client cert field %q in --set_current_client_cert_details, it should be one of subject, uri, dns, cert or chain", field)
+		}
+	}
+	httpConMgr.SetCurrentClientCertDetails = setDetails
+	return nil
+}
+
+// ResolveClientCrlSdsPath sets --client_crl_sds_path to a file in a new
+// temporary directory if a CRL is configured without it, so that ESPv2
+// instances on the same host don't overwrite each other's CRL.
+func ResolveClientCrlSdsPath(opts *options.ConfigGeneratorOptions) error {
+	if opts.ClientCrlFile == "" || opts.ClientCrlSdsPath != "" {
+		return nil
+	}
+	dir, err := ioutil.TempDir("", "espv2-client-crl-")
+	if err != nil {
+		return fmt.Errorf("fail to create the directory of flag --client_crl_sds_path: %v", err)
+	}
+	opts.ClientCrlSdsPath = filepath.Join(dir, "client_crl_sds.json")
+	return nil
+}
+
+// WriteClientCrlSdsFile writes the CRL to the SDS file of --client_crl_sds_path
+// referenced by the listeners, which Envoy watches for changes.
+func WriteClientCrlSdsFile(path string, crl []byte) error {
+	content, err := util.CreateClientCrlSdsFile(crl)
+	if err != nil {
+		return err
+	}
+	if err := util.WriteFileAtomically(path, content); err != nil {
+		return fmt.Errorf("fail to write flag --client_crl_sds_path: %v", err)
+	}
+	return nil
+}
@@ -13,14 +13,30 @@
 package configgenerator
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 
+	acpb "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	gacpb "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	hcmpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tlspb "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	durationpb "github.com/golang/protobuf/ptypes/duration"
+	wrapperspb "github.com/golang/protobuf/ptypes/wrappers"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 	apipb "google.golang.org/genproto/protobuf/api"
 )
@@ -85,7 +101,53 @@ func TestMakeListeners(t *testing.T) {
                   "code": "%RESPONSE_CODE%",
                   "message": "%LOCAL_REPLY_BODY%"
                 }
-              }
+              },
+              "mappers": [
+                {
+                  "body": {
+                    "inlineString": "Request headers, including any JWT, exceed the 60 KB limit."
+                  },
+                  "filter": {
+                    "andFilter": {
+                      "filters": [
+                        {
+                          "statusCodeFilter": {
+                            "comparison": {
+                              "value": {
+                                "defaultValue": 431,
+                                "runtimeKey": "espv2.request_headers_too_large"
+                              }
+                            }
+                          }
+                        },
+                        {
+                          "responseFlagFilter": {
+                            "flags": [
+                              "DPE"
+                            ]
+                          }
+                        }
+                      ]
+                    }
+                  },
+                  "statusCode": 413
+                },
+                {
+                  "body": {
+                    "inlineString": "Request body exceeds the buffer limit."
+                  },
+                  "filter": {
+                    "statusCodeFilter": {
+                      "comparison": {
+                        "value": {
+                          "defaultValue": 413,
+                          "runtimeKey": "espv2.request_body_too_large"
+                        }
+                      }
+                    }
+                  }
+                }
+              ]
             },
             "mergeSlashes": true,
             "normalizePath": true,
@@ -218,7 +280,53 @@ func TestMakeHttpConMgr(t *testing.T) {
 							"code": "%RESPONSE_CODE%",
 							"message": "%LOCAL_REPLY_BODY%"
 						}
-					}
+					},
+					"mappers": [
+						{
+							"body": {
+								"inlineString": "Request headers, including any JWT, exceed the 60 KB limit."
+							},
+							"filter": {
+								"andFilter": {
+									"filters": [
+										{
+											"statusCodeFilter": {
+												"comparison": {
+													"value": {
+														"defaultValue": 431,
+														"runtimeKey": "espv2.request_headers_too_large"
+													}
+												}
+											}
+										},
+										{
+											"responseFlagFilter": {
+												"flags": [
+													"DPE"
+												]
+											}
+										}
+									]
+								}
+							},
+							"statusCode": 413
+						},
+						{
+							"body": {
+								"inlineString": "Request body exceeds the buffer limit."
+							},
+							"filter": {
+								"statusCodeFilter": {
+									"comparison": {
+										"value": {
+											"defaultValue": 413,
+											"runtimeKey": "espv2.request_body_too_large"
+										}
+									}
+								}
+							}
+						}
+					]
 				},
 				"normalizePath": false,
 				"pathWithEscapedSlashesAction": "KEEP_UNCHANGED",
@@ -262,7 +370,53 @@ func TestMakeHttpConMgr(t *testing.T) {
 								"code": "%RESPONSE_CODE%",
 								"message": "%LOCAL_REPLY_BODY%"
 							}
-						}
+						},
+						"mappers": [
+							{
+								"body": {
+									"inlineString": "Request headers, including any JWT, exceed the 60 KB limit."
+								},
+								"filter": {
+									"andFilter": {
+										"filters": [
+											{
+												"statusCodeFilter": {
+													"comparison": {
+														"value": {
+															"defaultValue": 431,
+															"runtimeKey": "espv2.request_headers_too_large"
+														}
+													}
+												}
+											},
+											{
+												"responseFlagFilter": {
+													"flags": [
+														"DPE"
+													]
+												}
+											}
+										]
+									}
+								},
+								"statusCode": 413
+							},
+							{
+								"body": {
+									"inlineString": "Request body exceeds the buffer limit."
+								},
+								"filter": {
+									"statusCodeFilter": {
+										"comparison": {
+											"value": {
+												"defaultValue": 413,
+												"runtimeKey": "espv2.request_body_too_large"
+											}
+										}
+									}
+								}
+							}
+						]
 					},
 					"normalizePath": false,
 					"pathWithEscapedSlashesAction": "KEEP_UNCHANGED",
@@ -297,7 +451,53 @@ func TestMakeHttpConMgr(t *testing.T) {
 								"code": "%RESPONSE_CODE%",
 								"message": "%LOCAL_REPLY_BODY%"
 							}
-						}
+						},
+						"mappers": [
+							{
+								"body": {
+									"inlineString": "Request headers, including any JWT, exceed the 60 KB limit."
+								},
+								"filter": {
+									"andFilter": {
+										"filters": [
+											{
+												"statusCodeFilter": {
+													"comparison": {
+														"value": {
+															"defaultValue": 431,
+															"runtimeKey": "espv2.request_headers_too_large"
+														}
+													}
+												}
+											},
+											{
+												"responseFlagFilter": {
+													"flags": [
+														"DPE"
+													]
+												}
+											}
+										]
+									}
+								},
+								"statusCode": 413
+							},
+							{
+								"body": {
+									"inlineString": "Request body exceeds the buffer limit."
+								},
+								"filter": {
+									"statusCodeFilter": {
+										"comparison": {
+											"value": {
+												"defaultValue": 413,
+												"runtimeKey": "espv2.request_body_too_large"
+											}
+										}
+									}
+								}
+							}
+						]
 					},
 					"normalizePath": false,
 					"pathWithEscapedSlashesAction": "KEEP_UNCHANGED",
@@ -346,7 +546,53 @@ func TestMakeHttpConMgr(t *testing.T) {
 								"code": "%RESPONSE_CODE%",
 								"message": "%LOCAL_REPLY_BODY%"
 							}
-						}
+						},
+						"mappers": [
+							{
+								"body": {
+									"inlineString": "Request headers, including any JWT, exceed the 60 KB limit."
+								},
+								"filter": {
+									"andFilter": {
+										"filters": [
+											{
+												"statusCodeFilter": {
+													"comparison": {
+														"value": {
+															"defaultValue": 431,
+															"runtimeKey": "espv2.request_headers_too_large"
+														}
+													}
+												}
+											},
+											{
+												"responseFlagFilter": {
+													"flags": [
+														"DPE"
+													]
+												}
+											}
+										]
+									}
+								},
+								"statusCode": 413
+							},
+							{
+								"body": {
+									"inlineString": "Request body exceeds the buffer limit."
+								},
+								"filter": {
+									"statusCodeFilter": {
+										"comparison": {
+											"value": {
+												"defaultValue": 413,
+												"runtimeKey": "espv2.request_body_too_large"
+											}
+										}
+									}
+								}
+							}
+						]
 					},
 					"normalizePath": false,
 					"pathWithEscapedSlashesAction": "KEEP_UNCHANGED",
@@ -379,8 +625,134 @@ func TestMakeHttpConMgr(t *testing.T) {
 								"code": "%RESPONSE_CODE%",
 								"message": "%LOCAL_REPLY_BODY%"
 							}
+						},
+						"mappers": [
+							{
+								"body": {
+									"inlineString": "Request headers, including any JWT, exceed the 60 KB limit."
+								},
+								"filter": {
+									"andFilter": {
+										"filters": [
+											{
+												"statusCodeFilter": {
+													"comparison": {
+														"value": {
+															"defaultValue": 431,
+															"runtimeKey": "espv2.request_headers_too_large"
+														}
+													}
+												}
+											},
+											{
+												"responseFlagFilter": {
+													"flags": [
+														"DPE"
+													]
+												}
+											}
+										]
+									}
+								},
+								"statusCode": 413
+							},
+							{
+								"body": {
+									"inlineString": "Request body exceeds the buffer limit."
+								},
+								"filter": {
+									"statusCodeFilter": {
+										"comparison": {
+											"value": {
+												"defaultValue": 413,
+												"runtimeKey": "espv2.request_body_too_large"
+											}
+										}
+									}
+								}
+							}
+						]
+					},
+					"normalizePath": false,
+					"pathWithEscapedSlashesAction": "KEEP_UNCHANGED",
+					"routeConfig": {},
+					"statPrefix": "ingress_http",
+					"upgradeConfigs": [
+						{
+							"upgradeType": "websocket"
 						}
+					],
+					"useRemoteAddress": false
+				}`,
+		},
+		{
+			desc: "Generate HttpConMgr when MaxRequestHeadersKb is defined",
+			opts: options.ConfigGeneratorOptions{
+				MaxRequestHeadersKb: 96,
+				CommonOptions: options.CommonOptions{
+					DisableTracing: true,
+				},
+			},
+			wantHttpConnMgr: `
+				{
+					"commonHttpProtocolOptions": {
+						"headersWithUnderscoresAction": "REJECT_REQUEST"
+					},
+					"localReplyConfig": {
+						"bodyFormat": {
+							"jsonFormat": {
+								"code": "%RESPONSE_CODE%",
+								"message": "%LOCAL_REPLY_BODY%"
+							}
+						},
+						"mappers": [
+							{
+								"body": {
+									"inlineString": "Request headers, including any JWT, exceed the 96 KB limit."
+								},
+								"filter": {
+									"andFilter": {
+										"filters": [
+											{
+												"statusCodeFilter": {
+													"comparison": {
+														"value": {
+															"defaultValue": 431,
+															"runtimeKey": "espv2.request_headers_too_large"
+														}
+													}
+												}
+											},
+											{
+												"responseFlagFilter": {
+													"flags": [
+														"DPE"
+													]
+												}
+											}
+										]
+									}
+								},
+								"statusCode": 413
+							},
+							{
+								"body": {
+									"inlineString": "Request body exceeds the buffer limit."
+								},
+								"filter": {
+									"statusCodeFilter": {
+										"comparison": {
+											"value": {
+												"defaultValue": 413,
+												"runtimeKey": "espv2.request_body_too_large"
+											}
+										}
+									}
+								}
+							}
+						]
 					},
+					"maxRequestHeadersKb": 96,
 					"normalizePath": false,
 					"pathWithEscapedSlashesAction": "KEEP_UNCHANGED",
 					"routeConfig": {},
@@ -413,3 +785,915 @@ func TestMakeHttpConMgr(t *testing.T) {
 		}
 	}
 }
+
+func TestMakeHttpConMgrWithInvalidMaxRequestHeadersKb(t *testing.T) {
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.MaxRequestHeadersKb = 10000
+	_, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+	wantError := "flag --max_request_headers_kb must be in [0, 8192], where 0 uses the default of 60, got 10000"
+	if err == nil || err.Error() != wantError {
+		t.Errorf("got error %v, want %v", err, wantError)
+	}
+}
+
+func TestMakeHttpConMgrWithHttp2Keepalive(t *testing.T) {
+	testdata := []struct {
+		desc              string
+		keepaliveInterval time.Duration
+		keepaliveTimeout  time.Duration
+		wantKeepalive     *corepb.KeepaliveSettings
+		wantError         string
+	}{
+		{
+			desc:             "no keepalive by default",
+			keepaliveTimeout: 20 * time.Second,
+		},
+		{
+			desc:              "ping idle clients",
+			keepaliveInterval: 30 * time.Second,
+			keepaliveTimeout:  10 * time.Second,
+			wantKeepalive: &corepb.KeepaliveSettings{
+				Interval: ptypes.DurationProto(30 * time.Second),
+				Timeout:  ptypes.DurationProto(10 * time.Second),
+			},
+		},
+		{
+			desc:              "interval too short",
+			keepaliveInterval: 500 * time.Millisecond,
+			keepaliveTimeout:  20 * time.Second,
+			wantError:         "flag --listener_http2_keepalive_interval must be at least 1s, got 500ms",
+		},
+		{
+			desc:              "no timeout",
+			keepaliveInterval: 30 * time.Second,
+			wantError:         "flag --listener_http2_keepalive_timeout must be positive, got 0s",
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.ListenerHttp2KeepaliveInterval = tc.keepaliveInterval
+			opts.ListenerHttp2KeepaliveTimeout = tc.keepaliveTimeout
+
+			hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hcm.GetHttp2ProtocolOptions().GetConnectionKeepalive(); !proto.Equal(got, tc.wantKeepalive) {
+				t.Errorf("got connection_keepalive %v, want %v", got, tc.wantKeepalive)
+			}
+		})
+	}
+}
+
+func TestMakeHttpConMgrWithMaxRequestDuration(t *testing.T) {
+	testdata := []struct {
+		desc                  string
+		maxRequestDuration    time.Duration
+		wantMaxStreamDuration *durationpb.Duration
+		wantError             string
+	}{
+		{
+			desc: "no max stream duration by default",
+		},
+		{
+			desc:                  "max stream duration is set",
+			maxRequestDuration:    5 * time.Minute,
+			wantMaxStreamDuration: ptypes.DurationProto(5 * time.Minute),
+		},
+		{
+			desc:               "negative duration",
+			maxRequestDuration: -time.Second,
+			wantError:          "flag --max_request_duration must not be negative, got -1s",
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.MaxRequestDuration = tc.maxRequestDuration
+
+			hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hcm.GetCommonHttpProtocolOptions().GetMaxStreamDuration(); !proto.Equal(got, tc.wantMaxStreamDuration) {
+				t.Errorf("got max_stream_duration %v, want %v", got, tc.wantMaxStreamDuration)
+			}
+		})
+	}
+}
+
+func TestMakeHttpConMgrWithAccessLogGrpc(t *testing.T) {
+	testdata := []struct {
+		desc                 string
+		accessLog            string
+		accessLogGrpcAddress string
+		accessLogGrpcLogName string
+		wantAccessLogs       []string
+		wantLogName          string
+		wantError            string
+	}{
+		{
+			desc:                 "no access log by default",
+			accessLogGrpcLogName: "espv2",
+		},
+		{
+			desc:                 "gRPC access log is added",
+			accessLogGrpcAddress: "grpc://127.0.0.1:9001",
+			accessLogGrpcLogName: "espv2",
+			wantAccessLogs:       []string{util.AccessGrpcLogger},
+			wantLogName:          "espv2",
+		},
+		{
+			desc:                 "gRPC access log is added after the file access log",
+			accessLog:            "/dev/stdout",
+			accessLogGrpcAddress: "grpc://127.0.0.1:9001",
+			accessLogGrpcLogName: "bookstore",
+			wantAccessLogs:       []string{util.AccessFileLogger, util.AccessGrpcLogger},
+			wantLogName:          "bookstore",
+		},
+		{
+			desc:                 "empty log name",
+			accessLogGrpcAddress: "grpc://127.0.0.1:9001",
+			wantError:            "flag --access_log_grpc_log_name must not be empty with --access_log_grpc_address",
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.AccessLog = tc.accessLog
+			opts.AccessLogGrpcAddress = tc.accessLogGrpcAddress
+			opts.AccessLogGrpcLogName = tc.accessLogGrpcLogName
+
+			hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotAccessLogs []string
+			for _, accessLog := range hcm.GetAccessLog() {
+				gotAccessLogs = append(gotAccessLogs, accessLog.GetName())
+				if accessLog.GetName() != util.AccessGrpcLogger {
+					continue
+				}
+				config := &gacpb.HttpGrpcAccessLogConfig{}
+				if err := ptypes.UnmarshalAny(accessLog.GetTypedConfig(), config); err != nil {
+					t.Fatal(err)
+				}
+				if got := config.GetCommonConfig().GetLogName(); got != tc.wantLogName {
+					t.Errorf("got log name %v, want %v", got, tc.wantLogName)
+				}
+				if got := config.GetCommonConfig().GetGrpcService().GetEnvoyGrpc().GetClusterName(); got != util.AccessLogGrpcClusterName {
+					t.Errorf("got cluster %v, want %v", got, util.AccessLogGrpcClusterName)
+				}
+			}
+			if !reflect.DeepEqual(gotAccessLogs, tc.wantAccessLogs) {
+				t.Errorf("got access logs %v, want %v", gotAccessLogs, tc.wantAccessLogs)
+			}
+		})
+	}
+}
+
+func TestMakeHttpConMgrWithHttp2MaxConcurrentStreams(t *testing.T) {
+	testdata := []struct {
+		desc                     string
+		maxConcurrentStreams     int
+		keepaliveInterval        time.Duration
+		wantMaxConcurrentStreams *wrapperspb.UInt32Value
+		wantError                string
+	}{
+		{
+			desc: "Envoy default by default",
+		},
+		{
+			desc:                     "cap the streams of each connection",
+			maxConcurrentStreams:     100,
+			wantMaxConcurrentStreams: &wrapperspb.UInt32Value{Value: 100},
+		},
+		{
+			desc:                     "cap the streams with keepalive",
+			maxConcurrentStreams:     100,
+			keepaliveInterval:        30 * time.Second,
+			wantMaxConcurrentStreams: &wrapperspb.UInt32Value{Value: 100},
+		},
+		{
+			desc:                 "negative cap",
+			maxConcurrentStreams: -1,
+			wantError:            "flag --listener_http2_max_concurrent_streams must be in [1, 2147483647], got -1",
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.ListenerHttp2MaxConcurrentStreams = tc.maxConcurrentStreams
+			opts.ListenerHttp2KeepaliveInterval = tc.keepaliveInterval
+
+			hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hcm.GetHttp2ProtocolOptions().GetMaxConcurrentStreams(); !proto.Equal(got, tc.wantMaxConcurrentStreams) {
+				t.Errorf("got max_concurrent_streams %v, want %v", got, tc.wantMaxConcurrentStreams)
+			}
+			if tc.keepaliveInterval != 0 && hcm.GetHttp2ProtocolOptions().GetConnectionKeepalive() == nil {
+				t.Errorf("got no connection_keepalive, want it kept along with max_concurrent_streams")
+			}
+		})
+	}
+}
+
+func TestMakeHttpConMgrWithBackendUnavailableMessage(t *testing.T) {
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+	opts.BackendUnavailableMessage = "Bookstore is temporarily unavailable"
+
+	hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMapper := &hcmpb.ResponseMapper{
+		Filter: &acpb.AccessLogFilter{
+			FilterSpecifier: &acpb.AccessLogFilter_ResponseFlagFilter{
+				ResponseFlagFilter: &acpb.ResponseFlagFilter{
+					Flags: []string{"UF", "UH", "UO"},
+				},
+			},
+		},
+		Body: &corepb.DataSource{
+			Specifier: &corepb.DataSource_InlineString{
+				InlineString: "Bookstore is temporarily unavailable",
+			},
+		},
+	}
+	mappers := hcm.GetLocalReplyConfig().GetMappers()
+	if got := mappers[len(mappers)-1]; !proto.Equal(got, wantMapper) {
+		t.Errorf("got local reply mapper %v, want %v", got, wantMapper)
+	}
+}
+
+func TestMakeHttpConMgrWithEscapedSlashesInPath(t *testing.T) {
+	testdata := []struct {
+		desc                         string
+		disallowEscapedSlashesInPath bool
+		decodeEscapedSlashesInPath   bool
+		wantAction                   hcmpb.HttpConnectionManager_PathWithEscapedSlashesAction
+		wantError                    string
+	}{
+		{
+			desc:       "escaped slashes are forwarded unchanged by default",
+			wantAction: hcmpb.HttpConnectionManager_KEEP_UNCHANGED,
+		},
+		{
+			desc:                         "escaped slashes are unescaped and redirected when disallowed",
+			disallowEscapedSlashesInPath: true,
+			wantAction:                   hcmpb.HttpConnectionManager_UNESCAPE_AND_REDIRECT,
+		},
+		{
+			desc:                       "escaped slashes are unescaped and forwarded when decoded",
+			decodeEscapedSlashesInPath: true,
+			wantAction:                 hcmpb.HttpConnectionManager_UNESCAPE_AND_FORWARD,
+		},
+		{
+			desc:                         "escaped slashes cannot be both disallowed and decoded",
+			disallowEscapedSlashesInPath: true,
+			decodeEscapedSlashesInPath:   true,
+			wantError:                    "flag --decode_escaped_slashes_in_path cannot be used with --disallow_escaped_slashes_in_path",
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.DisallowEscapedSlashesInPath = tc.disallowEscapedSlashesInPath
+			opts.DecodeEscapedSlashesInPath = tc.decodeEscapedSlashesInPath
+
+			hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %s", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hcm.GetPathWithEscapedSlashesAction(); got != tc.wantAction {
+				t.Errorf("got path_with_escaped_slashes_action %v, want %v", got, tc.wantAction)
+			}
+		})
+	}
+}
+
+func TestMakeHttpConMgrWithAcceptHttp10(t *testing.T) {
+	testdata := []struct {
+		desc                    string
+		enableGrpcForHttp1      bool
+		acceptHttp10            bool
+		defaultHostForHttp10    string
+		wantHttpProtocolOptions *corepb.Http1ProtocolOptions
+		wantError               string
+	}{
+		{
+			desc: "HTTP/1.0 is rejected by default",
+		},
+		{
+			desc:                 "accept HTTP/1.0 with a default host",
+			acceptHttp10:         true,
+			defaultHostForHttp10: "api.example.com",
+			wantHttpProtocolOptions: &corepb.Http1ProtocolOptions{
+				AcceptHttp_10:         true,
+				DefaultHostForHttp_10: "api.example.com",
+			},
+		},
+		{
+			desc:               "accept HTTP/1.0 while retaining gRPC trailers",
+			enableGrpcForHttp1: true,
+			acceptHttp10:       true,
+			wantHttpProtocolOptions: &corepb.Http1ProtocolOptions{
+				EnableTrailers: true,
+				AcceptHttp_10:  true,
+			},
+		},
+		{
+			desc:                 "default host without accepting HTTP/1.0",
+			defaultHostForHttp10: "api.example.com",
+			wantError:            "flag --default_host_for_http_10 requires --accept_http_10",
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.EnableGrpcForHttp1 = tc.enableGrpcForHttp1
+			opts.AcceptHttp10 = tc.acceptHttp10
+			opts.DefaultHostForHttp10 = tc.defaultHostForHttp10
+
+			hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hcm.GetHttpProtocolOptions(); !proto.Equal(got, tc.wantHttpProtocolOptions) {
+				t.Errorf("got http_protocol_options %v, want %v", got, tc.wantHttpProtocolOptions)
+			}
+		})
+	}
+}
+
+func TestMakeHttpConMgrWithClientCertDetails(t *testing.T) {
+	testdata := []struct {
+		desc                        string
+		forwardClientCertDetails    string
+		setCurrentClientCertDetails string
+		wantForwardClientCertDetail hcmpb.HttpConnectionManager_ForwardClientCertDetails
+		wantSetCurrentDetails       *hcmpb.HttpConnectionManager_SetCurrentClientCertDetails
+		wantError                   string
+	}{
+		{
+			desc:                        "not set by default",
+			wantForwardClientCertDetail: hcmpb.HttpConnectionManager_SANITIZE,
+		},
+		{
+			desc:                        "forward the subject and uri of the client cert",
+			forwardClientCertDetails:    "sanitize_set",
+			setCurrentClientCertDetails: "subject, uri",
+			wantForwardClientCertDetail: hcmpb.HttpConnectionManager_SANITIZE_SET,
+			wantSetCurrentDetails: &hcmpb.HttpConnectionManager_SetCurrentClientCertDetails{
+				Subject: &wrapperspb.BoolValue{Value: true},
+				Uri:     true,
+			},
+		},
+		{
+			desc:                        "forward the header as is",
+			forwardClientCertDetails:    "forward_only",
+			wantForwardClientCertDetail: hcmpb.HttpConnectionManager_FORWARD_ONLY,
+		},
+		{
+			desc:                     "invalid forward mode",
+			forwardClientCertDetails: "append",
+			wantError:                `invalid value "append" for --forward_client_cert_details`,
+		},
+		{
+			desc:                        "set current details without a forward mode",
+			setCurrentClientCertDetails: "subject",
+			wantError:                   "flag --set_current_client_cert_details requires --forward_client_cert_details to be append_forward or sanitize_set",
+		},
+		{
+			desc:                        "set current details with a forward mode that ignores them",
+			forwardClientCertDetails:    "forward_only",
+			setCurrentClientCertDetails: "subject",
+			wantError:                   "flag --set_current_client_cert_details requires --forward_client_cert_details to be append_forward or sanitize_set, got forward_only",
+		},
+		{
+			desc:                        "invalid client cert field",
+			forwardClientCertDetails:    "append_forward",
+			setCurrentClientCertDetails: "subject,issuer",
+			wantError:                   `invalid client cert field "issuer" in --set_current_client_cert_details, it should be one of subject, uri, dns, cert or chain`,
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.ForwardClientCertDetails = tc.forwardClientCertDetails
+			opts.SetCurrentClientCertDetails = tc.setCurrentClientCertDetails
+
+			hcm, err := makeHttpConMgr(&opts, &routepb.RouteConfiguration{})
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if hcm.ForwardClientCertDetails != tc.wantForwardClientCertDetail {
+				t.Errorf("got forward_client_cert_details %v, want %v", hcm.ForwardClientCertDetails, tc.wantForwardClientCertDetail)
+			}
+			if !proto.Equal(hcm.SetCurrentClientCertDetails, tc.wantSetCurrentDetails) {
+				t.Errorf("got set_current_client_cert_details %v, want %v", hcm.SetCurrentClientCertDetails, tc.wantSetCurrentDetails)
+			}
+		})
+	}
+}
+
+func TestMakeListenersWithProxyProtocol(t *testing.T) {
+	testdata := []struct {
+		desc                string
+		enableProxyProtocol bool
+		wantListenerFilters []string
+	}{
+		{
+			desc:                "No listener filters by default",
+			enableProxyProtocol: false,
+		},
+		{
+			desc:                "Proxy protocol listener filter is added when enabled",
+			enableProxyProtocol: true,
+			wantListenerFilters: []string{`
+{
+  "name": "envoy.filters.listener.proxy_protocol",
+  "typedConfig": {
+    "@type": "type.googleapis.com/envoy.extensions.filters.listener.proxy_protocol.v3.ProxyProtocol"
+  }
+}`,
+			},
+		},
+	}
+
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "endpoints.examples.bookstore.Bookstore",
+				Methods: []*apipb.Method{
+					{
+						Name: "CreateShelf",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.EnableProxyProtocol = tc.enableProxyProtocol
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listeners, err := MakeListeners(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotListenerFilters := listeners[0].ListenerFilters
+			if len(gotListenerFilters) != len(tc.wantListenerFilters) {
+				t.Fatalf("got %d listener filters, want %d", len(gotListenerFilters), len(tc.wantListenerFilters))
+			}
+
+			marshaler := &jsonpb.Marshaler{}
+			for i, wantListenerFilter := range tc.wantListenerFilters {
+				gotListenerFilter, err := marshaler.MarshalToString(gotListenerFilters[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := util.JsonEqual(wantListenerFilter, gotListenerFilter); err != nil {
+					t.Errorf("listener filter (%d) mismatch, \n %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeListenersWithSocketOptions(t *testing.T) {
+	testdata := []struct {
+		desc                 string
+		tcpKeepaliveTime     time.Duration
+		tcpKeepaliveInterval time.Duration
+		tcpKeepaliveProbes   int
+		enableReusePort      bool
+		wantSocketOptions    []string
+		wantReusePort        bool
+		wantError            string
+	}{
+		{
+			desc: "No socket options by default",
+		},
+		{
+			desc:             "Keepalive with only the idle time",
+			tcpKeepaliveTime: 30 * time.Second,
+			wantSocketOptions: []string{
+				`{"description":"SO_KEEPALIVE","level":"1","name":"9","intValue":"1"}`,
+				`{"description":"TCP_KEEPIDLE","level":"6","name":"4","intValue":"30"}`,
+			},
+		},
+		{
+			desc:                 "Keepalive with all settings and reuse port",
+			tcpKeepaliveTime:     time.Minute,
+			tcpKeepaliveInterval: 10 * time.Second,
+			tcpKeepaliveProbes:   3,
+			enableReusePort:      true,
+			wantSocketOptions: []string{
+				`{"description":"SO_KEEPALIVE","level":"1","name":"9","intValue":"1"}`,
+				`{"description":"TCP_KEEPIDLE","level":"6","name":"4","intValue":"60"}`,
+				`{"description":"TCP_KEEPINTVL","level":"6","name":"5","intValue":"10"}`,
+				`{"description":"TCP_KEEPCNT","level":"6","name":"6","intValue":"3"}`,
+			},
+			wantReusePort: true,
+		},
+		{
+			desc:               "Keepalive probes without the idle time",
+			tcpKeepaliveProbes: 3,
+			wantError:          "flags --listener_tcp_keepalive_interval and --listener_tcp_keepalive_probes require --listener_tcp_keepalive_time",
+		},
+		{
+			desc:             "Keepalive idle time below one second",
+			tcpKeepaliveTime: 500 * time.Millisecond,
+			wantError:        "flag --listener_tcp_keepalive_time must be at least 1s, got 500ms",
+		},
+	}
+
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "endpoints.examples.bookstore.Bookstore",
+				Methods: []*apipb.Method{
+					{
+						Name: "CreateShelf",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.ListenerTcpKeepaliveTime = tc.tcpKeepaliveTime
+			opts.ListenerTcpKeepaliveInterval = tc.tcpKeepaliveInterval
+			opts.ListenerTcpKeepaliveProbes = tc.tcpKeepaliveProbes
+			opts.EnableReusePort = tc.enableReusePort
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listeners, err := MakeListeners(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if listeners[0].ReusePort != tc.wantReusePort {
+				t.Errorf("got reuse port %v, want %v", listeners[0].ReusePort, tc.wantReusePort)
+			}
+
+			gotSocketOptions := listeners[0].SocketOptions
+			if len(gotSocketOptions) != len(tc.wantSocketOptions) {
+				t.Fatalf("got %d socket options, want %d", len(gotSocketOptions), len(tc.wantSocketOptions))
+			}
+
+			marshaler := &jsonpb.Marshaler{}
+			for i, wantSocketOption := range tc.wantSocketOptions {
+				gotSocketOption, err := marshaler.MarshalToString(gotSocketOptions[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := util.JsonEqual(wantSocketOption, gotSocketOption); err != nil {
+					t.Errorf("socket option (%d) mismatch, \n %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeListenersWithListenerAddress(t *testing.T) {
+	testdata := []struct {
+		desc            string
+		listenerAddress string
+		wantError       string
+	}{
+		{
+			desc:            "Binds to all interfaces by default",
+			listenerAddress: "0.0.0.0",
+		},
+		{
+			desc:            "Binds to the IPv4 loopback",
+			listenerAddress: "127.0.0.1",
+		},
+		{
+			desc:            "Binds to the IPv6 loopback",
+			listenerAddress: "::1",
+		},
+		{
+			desc:            "Hostname is not an IP address",
+			listenerAddress: "localhost",
+			wantError:       `flag --listener_address must be an IP address, got "localhost"`,
+		},
+	}
+
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "endpoints.examples.bookstore.Bookstore",
+				Methods: []*apipb.Method{
+					{
+						Name: "CreateShelf",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.ListenerAddress = tc.listenerAddress
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listeners, err := MakeListeners(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := listeners[0].GetAddress().GetSocketAddress().GetAddress(); got != tc.listenerAddress {
+				t.Errorf("got listener address %v, want %v", got, tc.listenerAddress)
+			}
+		})
+	}
+}
+
+func TestMakeListenersWithClientCrl(t *testing.T) {
+	testdata := []struct {
+		desc            string
+		sslRootCertPath string
+		clientCrlFile   string
+		wantError       string
+	}{
+		{
+			desc:            "CRL is set through the SDS file",
+			sslRootCertPath: "/etc/ssl/endpoints/root.crt",
+			clientCrlFile:   platform.GetFilePath(platform.TestClientCrl),
+		},
+		{
+			desc:          "CRL without root certificates",
+			clientCrlFile: platform.GetFilePath(platform.TestClientCrl),
+			wantError:     "flag --client_crl_file requires --ssl_server_root_cert_path or --ssl_server_spiffe_trust_bundle_path",
+		},
+	}
+
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "endpoints.examples.bookstore.Bookstore",
+				Methods: []*apipb.Method{
+					{
+						Name: "CreateShelf",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.SslServerCertPath = "/etc/ssl/endpoints/"
+			opts.SslServerRootCertPath = tc.sslRootCertPath
+			opts.ClientCrlFile = tc.clientCrlFile
+			opts.ClientCrlSdsPath = "/tmp/client_crl_sds.json"
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listeners, err := MakeListeners(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tlsContext := &tlspb.DownstreamTlsContext{}
+			if err := ptypes.UnmarshalAny(listeners[0].FilterChains[0].TransportSocket.GetTypedConfig(), tlsContext); err != nil {
+				t.Fatal(err)
+			}
+			validationContext := tlsContext.GetCommonTlsContext().GetCombinedValidationContext()
+			if got := validationContext.GetDefaultValidationContext().GetTrustedCa().GetFilename(); got != tc.sslRootCertPath {
+				t.Errorf("got trusted CA %q, want %q", got, tc.sslRootCertPath)
+			}
+			sdsConfig := validationContext.GetValidationContextSdsSecretConfig()
+			if sdsConfig.GetName() != util.ClientCrlSecret || sdsConfig.GetSdsConfig().GetPath() != opts.ClientCrlSdsPath {
+				t.Errorf("got CRL SDS config %v, want secret %q in %q", sdsConfig, util.ClientCrlSecret, opts.ClientCrlSdsPath)
+			}
+		})
+	}
+}
+
+func TestWriteClientCrlSdsFile(t *testing.T) {
+	testdata := []struct {
+		desc          string
+		clientCrlFile string
+		wantError     string
+	}{
+		{
+			desc:          "CRL is written to the SDS file",
+			clientCrlFile: platform.GetFilePath(platform.TestClientCrl),
+		},
+		{
+			desc:          "CRL file does not exist",
+			clientCrlFile: "/etc/ssl/endpoints/not_exist.crl",
+			wantError:     "fail to read flag --client_crl_file: open /etc/ssl/endpoints/not_exist.crl: no such file or directory",
+		},
+		{
+			desc:          "CRL file is not a CRL",
+			clientCrlFile: platform.GetFilePath(platform.TestRootCaCerts),
+			wantError:     fmt.Sprintf("flag --client_crl_file must be a PEM certificate revocation list, got %q", platform.GetFilePath(platform.TestRootCaCerts)),
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			crl, err := ReadClientCrl(tc.clientCrlFile)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sdsPath := filepath.Join(t.TempDir(), "client_crl_sds.json")
+			if err := WriteClientCrlSdsFile(sdsPath, crl); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(sdsPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := util.CreateClientCrlSdsFile(crl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("got SDS file %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestResolveClientCrlSdsPath(t *testing.T) {
+	testdata := []struct {
+		desc             string
+		clientCrlFile    string
+		clientCrlSdsPath string
+		wantSdsPath      string
+		wantTempSdsPath  bool
+	}{
+		{
+			desc: "No SDS path without CRL",
+		},
+		{
+			desc:             "The configured SDS path is kept",
+			clientCrlFile:    platform.GetFilePath(platform.TestClientCrl),
+			clientCrlSdsPath: "/tmp/client_crl_sds.json",
+			wantSdsPath:      "/tmp/client_crl_sds.json",
+		},
+		{
+			desc:            "The SDS path is in a new temporary directory by default",
+			clientCrlFile:   platform.GetFilePath(platform.TestClientCrl),
+			wantTempSdsPath: true,
+		},
+	}
+
+	for _, tc := range testdata {
+		t.Run(tc.desc, func(t *testing.T) {
+			resolve := func() string {
+				opts := options.DefaultConfigGeneratorOptions()
+				opts.ClientCrlFile = tc.clientCrlFile
+				opts.ClientCrlSdsPath = tc.clientCrlSdsPath
+				if err := ResolveClientCrlSdsPath(&opts); err != nil {
+					t.Fatal(err)
+				}
+				return opts.ClientCrlSdsPath
+			}
+
+			got := resolve()
+			if !tc.wantTempSdsPath {
+				if got != tc.wantSdsPath {
+					t.Errorf("got SDS path %q, want %q", got, tc.wantSdsPath)
+				}
+				return
+			}
+
+			defer os.RemoveAll(filepath.Dir(got))
+			if filepath.Base(got) != "client_crl_sds.json" {
+				t.Errorf("got SDS path %q, want a client_crl_sds.json file", got)
+			}
+			// Each ESPv2 instance gets its own directory.
+			other := resolve()
+			defer os.RemoveAll(filepath.Dir(other))
+			if filepath.Dir(other) == filepath.Dir(got) {
+				t.Errorf("got the same SDS directory %q twice, want a new one per call", filepath.Dir(got))
+			}
+		})
+	}
+}
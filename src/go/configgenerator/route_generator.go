@@ -16,16 +16,20 @@ package configgenerator
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/configgenerator/filterconfig"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util/httppattern"
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	typepb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
@@ -36,6 +40,9 @@ import (
 const (
 	routeName       = "local_route"
 	virtualHostName = "backend"
+
+	// Envoy rejects larger direct response bodies unless the limit is raised.
+	defaultMaxDirectResponseBodySizeBytes = 4096
 )
 
 func makeRouteConfig(serviceInfo *configinfo.ServiceInfo) (*routepb.RouteConfiguration, error) {
@@ -58,7 +65,7 @@ func makeRouteConfig(serviceInfo *configinfo.ServiceInfo) (*routepb.RouteConfigu
 	if err != nil {
 		return nil, err
 	}
-	host.Routes = backendRoutes
+	host.Routes = append(makeRequiredHeaderRoutes(serviceInfo), backendRoutes...)
 
 	cors, corsRoutes, err := makeRouteCors(serviceInfo)
 	if err != nil {
@@ -78,6 +85,24 @@ func makeRouteConfig(serviceInfo *configinfo.ServiceInfo) (*routepb.RouteConfigu
 
 	host.Routes = append(host.Routes, makeCatchAllNotFoundRoute())
 
+	bufConfig, err := filterconfig.MakeBufferVirtualHostConfig(serviceInfo)
+	if err != nil {
+		return nil, err
+	}
+	if bufConfig != nil {
+		host.TypedPerFilterConfig = map[string]*anypb.Any{
+			util.Buffer: bufConfig,
+		}
+	}
+
+	if serviceInfo.Options.EnableOperationStats {
+		virtualClusters, err := makeOperationVirtualClusters(serviceInfo)
+		if err != nil {
+			return nil, err
+		}
+		host.VirtualClusters = virtualClusters
+	}
+
 	virtualHosts = append(virtualHosts, &host)
 
 	requestHeaders, err := makeRequestHeadersToAdd(serviceInfo)
@@ -88,12 +113,23 @@ func makeRouteConfig(serviceInfo *configinfo.ServiceInfo) (*routepb.RouteConfigu
 	if err != nil {
 		return nil, err
 	}
-	return &routepb.RouteConfiguration{
+	routeConfig := &routepb.RouteConfiguration{
 		Name:                 routeName,
 		VirtualHosts:         virtualHosts,
 		RequestHeadersToAdd:  requestHeaders,
 		ResponseHeadersToAdd: responseHeaders,
-	}, nil
+	}
+
+	maxBodySize := 0
+	for _, method := range serviceInfo.Methods {
+		if method.StaticResponse != nil && len(method.StaticResponse.Body) > maxBodySize {
+			maxBodySize = len(method.StaticResponse.Body)
+		}
+	}
+	if maxBodySize > defaultMaxDirectResponseBodySizeBytes {
+		routeConfig.MaxDirectResponseBodySizeBytes = &wrapperspb.UInt32Value{Value: uint32(maxBodySize)}
+	}
+	return routeConfig, nil
 }
 
 func makeHeaders(headers string, a bool) ([]*corepb.HeaderValueOption, error) {
@@ -149,9 +185,37 @@ func makeResponseHeadersToAdd(serviceInfo *configinfo.ServiceInfo) ([]*corepb.He
 	}
 
 	l = append(l, m...)
+
+	if hasBackendFallback(serviceInfo) {
+		// Only the fallback endpoints have the metadata, otherwise the header
+		// is not added.
+		l = append(l, &corepb.HeaderValueOption{
+			Header: &corepb.HeaderValue{
+				Key:   serviceInfo.Options.GeneratedHeaderPrefix + util.BackendFallbackHeaderSuffix,
+				Value: fmt.Sprintf(`%%UPSTREAM_METADATA(["%s", "%s"])%%`, util.BackendFallbackMetadataNamespace, util.BackendFallbackMetadataKey),
+			},
+			Append: &wrapperspb.BoolValue{
+				Value: false,
+			},
+		})
+	}
 	return l, nil
 }
 
+// hasBackendFallback returns whether any backend cluster has a fallback
+// backend.
+func hasBackendFallback(serviceInfo *configinfo.ServiceInfo) bool {
+	if serviceInfo.LocalBackendCluster != nil && serviceInfo.LocalBackendCluster.FallbackHostname != "" {
+		return true
+	}
+	for _, cluster := range serviceInfo.RemoteBackendClusters {
+		if cluster.FallbackHostname != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func makeRouteCors(serviceInfo *configinfo.ServiceInfo) (*routepb.CorsPolicy, []*routepb.Route, error) {
 	var cors *routepb.CorsPolicy
 	originMatcher := &routepb.HeaderMatcher{
@@ -325,6 +389,14 @@ func MakeRouteTable(serviceInfo *configinfo.ServiceInfo) ([]*routepb.Route, []*r
 		return nil, nil, fmt.Errorf("fail to sort route match, %v", err)
 	}
 
+	if serviceInfo.Options.TranscodingMaxMessageBytes < 0 {
+		return nil, nil, fmt.Errorf("flag --transcoding_max_message_bytes must not be negative, got %v", serviceInfo.Options.TranscodingMaxMessageBytes)
+	}
+	if serviceInfo.Options.TranscodingMaxMessageBytes > 0 && serviceInfo.Options.DisableTranscoding {
+		return nil, nil, fmt.Errorf("flag --transcoding_max_message_bytes cannot be used with --disable_transcoding")
+	}
+	grpcClusters := grpcBackendClusters(serviceInfo)
+
 	seenUriTemplatesInRoute := map[string]bool{}
 	for _, httpPatternMethod := range *httpPatternMethods {
 		operation := httpPatternMethod.Operation
@@ -349,6 +421,16 @@ func MakeRouteTable(serviceInfo *configinfo.ServiceInfo) ([]*routepb.Route, []*r
 		}
 
 		for _, routeMatcher := range routeMatchers {
+			if method.StaticResponse != nil {
+				// None of the backend settings below apply to it.
+				r := makeStaticResponseRoute(routeMatcher, method)
+				if r.TypedPerFilterConfig, err = makePerRouteFilterConfig(operation, method, httpRule); err != nil {
+					return nil, nil, fmt.Errorf("fail to make per-route filter config for operation (%v): %v", operation, err)
+				}
+				backendRoutes = append(backendRoutes, r)
+				continue
+			}
+
 			r := makeRoute(routeMatcher, method)
 
 			r.TypedPerFilterConfig, err = makePerRouteFilterConfig(operation, method, httpRule)
@@ -356,10 +438,23 @@ func MakeRouteTable(serviceInfo *configinfo.ServiceInfo) ([]*routepb.Route, []*r
 				return nil, nil, fmt.Errorf("fail to make per-route filter config for operation (%v): %v", operation, err)
 			}
 
-			if method.BackendInfo.Hostname != "" {
+			if method.BackendInfo.HostRewrite != "" {
+				// The user configured Host header takes precedence.
+				r.GetRoute().HostRewriteSpecifier = &routepb.RouteAction_HostRewriteLiteral{
+					HostRewriteLiteral: method.BackendInfo.HostRewrite,
+				}
+			} else if method.BackendInfo.Hostname != "" {
 				// For routing to remote backends.
 				r.GetRoute().HostRewriteSpecifier = &routepb.RouteAction_HostRewriteLiteral{
-					HostRewriteLiteral: method.BackendInfo.Hostname,
+					HostRewriteLiteral: util.HostHeaderValue(method.BackendInfo.Hostname),
+				}
+			}
+
+			if serviceInfo.Options.MaxGrpcTimeout > 0 {
+				// Envoy times out gRPC requests with grpc-timeout, which is
+				// capped by the max, as their max stream duration.
+				r.GetRoute().MaxStreamDuration = &routepb.RouteAction_MaxStreamDuration{
+					GrpcTimeoutHeaderMax: ptypes.DurationProto(serviceInfo.Options.MaxGrpcTimeout),
 				}
 			}
 
@@ -374,6 +469,34 @@ func MakeRouteTable(serviceInfo *configinfo.ServiceInfo) ([]*routepb.Route, []*r
 				}
 			}
 
+			if serviceInfo.Options.TranscodingMaxMessageBytes > 0 && grpcClusters[method.BackendInfo.ClusterName] && !method.StreamingPassthrough {
+				// Envoy raises the buffer limit of the request to the one of its
+				// route, and the transcoder rejects messages that do not fit in it.
+				r.PerRequestBufferLimitBytes = &wrapperspb.UInt32Value{
+					Value: uint32(serviceInfo.Options.TranscodingMaxMessageBytes),
+				}
+			}
+
+			if method.StreamingResponses {
+				// The stream stays open until the backend ends it, so the
+				// deadline is dropped. The idle timeout, which is derived from
+				// the deadline, still bounds the wait for each chunk.
+				r.GetRoute().Timeout = ptypes.DurationProto(0)
+				if serviceInfo.Options.EnableResponseCompression {
+					// The compressor filter holds back data until its window is
+					// full, so it must skip these responses.
+					r.ResponseHeadersToAdd = append(r.ResponseHeadersToAdd, &corepb.HeaderValueOption{
+						Header: &corepb.HeaderValue{
+							Key:   util.CacheControlHeaderKey,
+							Value: util.CacheControlNoTransform,
+						},
+						Append: &wrapperspb.BoolValue{
+							Value: true,
+						},
+					})
+				}
+			}
+
 			if serviceInfo.Options.EnableOperationNameHeader {
 				r.RequestHeadersToAdd = []*corepb.HeaderValueOption{
 					{
@@ -388,19 +511,92 @@ func MakeRouteTable(serviceInfo *configinfo.ServiceInfo) ([]*routepb.Route, []*r
 				}
 			}
 
-			backendRoutes = append(backendRoutes, r)
+			if serviceInfo.MirrorBackendClusterName != "" {
+				r.GetRoute().RequestMirrorPolicies = []*routepb.RouteAction_RequestMirrorPolicy{
+					{
+						Cluster: serviceInfo.MirrorBackendClusterName,
+						RuntimeFraction: &corepb.RuntimeFractionalPercent{
+							DefaultValue: &typepb.FractionalPercent{
+								// Mirror percentages are accurate to 0.0001%.
+								Numerator:   uint32(math.Round(serviceInfo.Options.MirrorPercent * 10000)),
+								Denominator: typepb.FractionalPercent_MILLION,
+							},
+						},
+					},
+				}
+			}
+
+			if method.CorsDisabled && serviceInfo.Options.CorsPreset != "" {
+				// The route policy overrides the one of the virtual host.
+				r.GetRoute().Cors = makeDisabledCors()
+			}
 
-			jsonStr, err := util.ProtoToJson(r)
-			if err != nil {
-				return nil, nil, err
+			// Routes on JWT claims are more specific, so they go first.
+			routes := append(makeJwtClaimRoutes(serviceInfo, r), r)
+			if len(method.AllowedQueryParameters) > 0 {
+				// Requests with disallowed query parameters are rejected before
+				// any of the routes of the operation.
+				routes = append([]*routepb.Route{makeQueryParameterNotAllowedRoute(routeMatcher, method.AllowedQueryParameters)}, routes...)
+			}
+			if method.CorsDisabled && serviceInfo.Options.CorsPreset != "" && httpRule.HttpMethod != util.OPTIONS {
+				// Otherwise the preflight requests are matched by the catch-all
+				// CORS route, which has the policy of the virtual host.
+				routes = append(routes, makeCorsDisabledPreflightRoute(routeMatcher, httpRule.UriTemplate.Origin))
+			}
+			for _, route := range routes {
+				backendRoutes = append(backendRoutes, route)
+
+				jsonStr, err := util.ProtoToJson(route)
+				if err != nil {
+					return nil, nil, err
+				}
+				glog.Infof("adding route: %v", jsonStr)
 			}
-			glog.Infof("adding route: %v", jsonStr)
 		}
 	}
 
 	return backendRoutes, methodNotAllowedRoutes, nil
 }
 
+// grpcBackendClusters returns the names of the backend clusters using gRPC,
+// whose HTTP requests are transcoded.
+func grpcBackendClusters(serviceInfo *configinfo.ServiceInfo) map[string]bool {
+	clusters := make(map[string]bool)
+	if serviceInfo.LocalBackendCluster != nil && serviceInfo.LocalBackendCluster.Protocol == util.GRPC {
+		clusters[serviceInfo.LocalBackendCluster.ClusterName] = true
+	}
+	for _, cluster := range serviceInfo.RemoteBackendClusters {
+		if cluster.Protocol == util.GRPC {
+			clusters[cluster.ClusterName] = true
+		}
+	}
+	return clusters
+}
+
+// makeJwtClaimRoutes copies the given route for each JWT claim route. The
+// copies only match requests with the claim header set by the service control
+// filter, and are sent to the backend of the claim route.
+func makeJwtClaimRoutes(serviceInfo *configinfo.ServiceInfo, r *routepb.Route) []*routepb.Route {
+	var claimRoutes []*routepb.Route
+	for _, claimRoute := range serviceInfo.JwtClaimRoutes {
+		cr := proto.Clone(r).(*routepb.Route)
+		cr.Match.Headers = append(cr.Match.Headers, &routepb.HeaderMatcher{
+			Name: serviceInfo.JwtClaimHeader(claimRoute.Claim),
+			HeaderMatchSpecifier: &routepb.HeaderMatcher_ExactMatch{
+				ExactMatch: claimRoute.Value,
+			},
+		})
+		cr.GetRoute().ClusterSpecifier = &routepb.RouteAction_Cluster{
+			Cluster: claimRoute.ClusterName,
+		}
+		cr.GetRoute().HostRewriteSpecifier = &routepb.RouteAction_HostRewriteLiteral{
+			HostRewriteLiteral: util.HostHeaderValue(claimRoute.Hostname),
+		}
+		claimRoutes = append(claimRoutes, cr)
+	}
+	return claimRoutes
+}
+
 func makeRoute(routeMatcher *routepb.RouteMatch, method *configinfo.MethodInfo) *routepb.Route {
 	retryPolicy := &routepb.RetryPolicy{
 		RetryOn: method.BackendInfo.RetryOns,
@@ -435,6 +631,42 @@ func makeRoute(routeMatcher *routepb.RouteMatch, method *configinfo.MethodInfo)
 	}
 }
 
+// makeStaticResponseRoute serves the static response of the method directly.
+func makeStaticResponseRoute(routeMatcher *routepb.RouteMatch, method *configinfo.MethodInfo) *routepb.Route {
+	directResponse := &routepb.DirectResponseAction{
+		Status: method.StaticResponse.Status,
+	}
+	r := &routepb.Route{
+		Name:  method.Operation(),
+		Match: routeMatcher,
+		Action: &routepb.Route_DirectResponse{
+			DirectResponse: directResponse,
+		},
+		Decorator: &routepb.Decorator{
+			Operation: fmt.Sprintf("%s %s", util.SpanNamePrefix, method.ShortName),
+		},
+	}
+	if len(method.StaticResponse.Body) > 0 {
+		directResponse.Body = &corepb.DataSource{
+			Specifier: &corepb.DataSource_InlineBytes{
+				InlineBytes: method.StaticResponse.Body,
+			},
+		}
+		r.ResponseHeadersToAdd = []*corepb.HeaderValueOption{
+			{
+				Header: &corepb.HeaderValue{
+					Key:   "content-type",
+					Value: method.StaticResponse.ContentType,
+				},
+				Append: &wrapperspb.BoolValue{
+					Value: false,
+				},
+			},
+		}
+	}
+	return r
+}
+
 func makeMethodNotAllowedRoute(methodNotAllowedRouteMatcher *routepb.RouteMatch, uriTemplateInSc string) *routepb.Route {
 	spanName := util.MaybeTruncateSpanName(fmt.Sprintf("%s UnknownHttpMethodForPath_%s", util.SpanNamePrefix, uriTemplateInSc))
 
@@ -455,6 +687,144 @@ func makeMethodNotAllowedRoute(methodNotAllowedRouteMatcher *routepb.RouteMatch,
 		},
 	}
 }
+
+// makeDisabledCors returns the CORS policy of the routes of CORS disabled
+// operations. The CORS filter skips their requests.
+func makeDisabledCors() *routepb.CorsPolicy {
+	return &routepb.CorsPolicy{
+		EnabledSpecifier: &routepb.CorsPolicy_FilterEnabled{
+			FilterEnabled: &corepb.RuntimeFractionalPercent{
+				DefaultValue: &typepb.FractionalPercent{
+					Numerator:   0,
+					Denominator: typepb.FractionalPercent_HUNDRED,
+				},
+			},
+		},
+	}
+}
+
+// makeCorsDisabledPreflightRoute rejects the CORS preflight requests to the
+// path of the route matcher. The CORS filter skips routes without a route
+// action, so no CORS headers are added.
+func makeCorsDisabledPreflightRoute(routeMatcher *routepb.RouteMatch, uriTemplateInSc string) *routepb.Route {
+	match := proto.Clone(routeMatcher).(*routepb.RouteMatch)
+	match.Headers = []*routepb.HeaderMatcher{
+		{
+			Name: ":method",
+			HeaderMatchSpecifier: &routepb.HeaderMatcher_ExactMatch{
+				ExactMatch: util.OPTIONS,
+			},
+		},
+	}
+	spanName := util.MaybeTruncateSpanName(fmt.Sprintf("%s CorsDisabled_%s", util.SpanNamePrefix, uriTemplateInSc))
+
+	return &routepb.Route{
+		Match: match,
+		Action: &routepb.Route_DirectResponse{
+			DirectResponse: &routepb.DirectResponseAction{
+				Status: http.StatusMethodNotAllowed,
+				Body: &corepb.DataSource{
+					Specifier: &corepb.DataSource_InlineString{
+						InlineString: fmt.Sprintf("CORS is disabled for the url template \"%s\"", uriTemplateInSc),
+					},
+				},
+			},
+		},
+		Decorator: &routepb.Decorator{
+			Operation: spanName,
+		},
+	}
+}
+
+// makeQueryParameterNotAllowedRoute rejects the requests matched by the route
+// matcher if they have any query parameter not in the allowed ones.
+func makeQueryParameterNotAllowedRoute(routeMatcher *routepb.RouteMatch, allowedParams []string) *routepb.Route {
+	var quotedParams []string
+	for _, param := range allowedParams {
+		quotedParams = append(quotedParams, regexp.QuoteMeta(param))
+	}
+	// Empty segments, e.g. from a trailing '&' or "&&", are allowed too.
+	allowedParam := fmt.Sprintf(`((%s)(=[^&]*)?)?`, strings.Join(quotedParams, "|"))
+
+	match := proto.Clone(routeMatcher).(*routepb.RouteMatch)
+	match.Headers = append(match.Headers, &routepb.HeaderMatcher{
+		// The `:path` header contains the query string.
+		Name: ":path",
+		HeaderMatchSpecifier: &routepb.HeaderMatcher_SafeRegexMatch{
+			SafeRegexMatch: &matcher.RegexMatcher{
+				EngineType: &matcher.RegexMatcher_GoogleRe2{
+					GoogleRe2: &matcher.RegexMatcher_GoogleRE2{},
+				},
+				Regex: fmt.Sprintf(`^[^?]*(\?%s(&%s)*)?$`, allowedParam, allowedParam),
+			},
+		},
+		InvertMatch: true,
+	})
+
+	return &routepb.Route{
+		Match: match,
+		Action: &routepb.Route_DirectResponse{
+			DirectResponse: &routepb.DirectResponseAction{
+				Status: http.StatusBadRequest,
+				Body: &corepb.DataSource{
+					Specifier: &corepb.DataSource_InlineString{
+						InlineString: "The current request has a query parameter that is not allowed.",
+					},
+				},
+			},
+		},
+		Decorator: &routepb.Decorator{
+			Operation: fmt.Sprintf("%s UnknownOperationName", util.SpanNamePrefix),
+		},
+	}
+}
+
+// makeRequiredHeaderRoutes rejects the requests missing any of the required
+// headers before they are routed. CORS preflight requests are exempted, as
+// browsers don't send custom headers in them.
+func makeRequiredHeaderRoutes(serviceInfo *configinfo.ServiceInfo) []*routepb.Route {
+	var routes []*routepb.Route
+	for _, header := range serviceInfo.RequiredRequestHeaders {
+		routes = append(routes, &routepb.Route{
+			Match: &routepb.RouteMatch{
+				PathSpecifier: &routepb.RouteMatch_Prefix{
+					Prefix: "/",
+				},
+				Headers: []*routepb.HeaderMatcher{
+					{
+						Name: header,
+						HeaderMatchSpecifier: &routepb.HeaderMatcher_PresentMatch{
+							PresentMatch: true,
+						},
+						InvertMatch: true,
+					},
+					{
+						Name: ":method",
+						HeaderMatchSpecifier: &routepb.HeaderMatcher_ExactMatch{
+							ExactMatch: "OPTIONS",
+						},
+						InvertMatch: true,
+					},
+				},
+			},
+			Action: &routepb.Route_DirectResponse{
+				DirectResponse: &routepb.DirectResponseAction{
+					Status: http.StatusBadRequest,
+					Body: &corepb.DataSource{
+						Specifier: &corepb.DataSource_InlineString{
+							InlineString: fmt.Sprintf("The current request is missing the required header %s.", header),
+						},
+					},
+				},
+			},
+			Decorator: &routepb.Decorator{
+				Operation: fmt.Sprintf("%s UnknownOperationName", util.SpanNamePrefix),
+			},
+		})
+	}
+	return routes
+}
+
 func makeCatchAllNotFoundRoute() *routepb.Route {
 	return &routepb.Route{
 		Match: &routepb.RouteMatch{
@@ -556,6 +926,49 @@ func makeHttpRouteMatchers(httpRule *httppattern.Pattern, seenUriTemplatesInRout
 	return routeMatchers, methodNotAllowedRouteMatchers, nil
 }
 
+// makeOperationVirtualClusters creates one virtual cluster per http rule, so
+// Envoy emits request counts and latencies under "vcluster.<operation>".
+// Multiple http rules of the same operation share the same stats.
+func makeOperationVirtualClusters(serviceInfo *configinfo.ServiceInfo) ([]*routepb.VirtualCluster, error) {
+	httpPatternMethods, err := getSortMethodsByHttpPattern(serviceInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var virtualClusters []*routepb.VirtualCluster
+	for _, httpPatternMethod := range *httpPatternMethods {
+		// The `:path` header contains the query string, which should not affect the match.
+		pathRegex := strings.TrimSuffix(httpPatternMethod.UriTemplate.Regex(), "$") + `(\?.*)?$`
+		headers := []*routepb.HeaderMatcher{
+			{
+				Name: ":path",
+				HeaderMatchSpecifier: &routepb.HeaderMatcher_SafeRegexMatch{
+					SafeRegexMatch: &matcher.RegexMatcher{
+						EngineType: &matcher.RegexMatcher_GoogleRe2{
+							GoogleRe2: &matcher.RegexMatcher_GoogleRE2{},
+						},
+						Regex: pathRegex,
+					},
+				},
+			},
+		}
+		if httpPatternMethod.HttpMethod != httppattern.HttpMethodWildCard {
+			headers = append(headers, &routepb.HeaderMatcher{
+				Name: ":method",
+				HeaderMatchSpecifier: &routepb.HeaderMatcher_ExactMatch{
+					ExactMatch: httpPatternMethod.HttpMethod,
+				},
+			})
+		}
+
+		virtualClusters = append(virtualClusters, &routepb.VirtualCluster{
+			Name:    strings.ReplaceAll(httpPatternMethod.Operation, ".", "_"),
+			Headers: headers,
+		})
+	}
+	return virtualClusters, nil
+}
+
 func getSortMethodsByHttpPattern(serviceInfo *configinfo.ServiceInfo) (*httppattern.MethodSlice, error) {
 	httpPatternMethods := &httppattern.MethodSlice{}
 	for _, operation := range serviceInfo.Operations {
@@ -568,6 +981,16 @@ func getSortMethodsByHttpPattern(serviceInfo *configinfo.ServiceInfo) (*httppatt
 		}
 	}
 
+	if serviceInfo.Options.AllowDuplicateHttpPatterns {
+		if err := httppattern.SortKeepingFirst(httpPatternMethods, func(dropped, kept *httppattern.Method) {
+			glog.Warningf("ignoring http pattern `%s %s` of operation %s, it duplicates http pattern `%s %s` of operation %s",
+				dropped.HttpMethod, dropped.UriTemplate.Origin, dropped.Operation, kept.HttpMethod, kept.UriTemplate.Origin, kept.Operation)
+		}); err != nil {
+			return nil, err
+		}
+		return httpPatternMethods, nil
+	}
+
 	if err := httppattern.Sort(httpPatternMethods); err != nil {
 		return nil, err
 	}
@@ -16,6 +16,11 @@ package configgenerator
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -25,6 +30,7 @@ import (
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
@@ -4030,6 +4036,59 @@ func TestMakeRouteConfigForCors(t *testing.T) {
 	}
 }
 
+func TestMakeRouteConfigForRequiredHeaders(t *testing.T) {
+	gotRouteConfig, err := makeRouteConfig(&configinfo.ServiceInfo{
+		Name:                   "test-api",
+		Options:                options.DefaultConfigGeneratorOptions(),
+		RequiredRequestHeaders: []string{"X-Correlation-Id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRoute := &routepb.Route{
+		Match: &routepb.RouteMatch{
+			PathSpecifier: &routepb.RouteMatch_Prefix{
+				Prefix: "/",
+			},
+			Headers: []*routepb.HeaderMatcher{
+				{
+					Name: "X-Correlation-Id",
+					HeaderMatchSpecifier: &routepb.HeaderMatcher_PresentMatch{
+						PresentMatch: true,
+					},
+					InvertMatch: true,
+				},
+				{
+					Name: ":method",
+					HeaderMatchSpecifier: &routepb.HeaderMatcher_ExactMatch{
+						ExactMatch: "OPTIONS",
+					},
+					InvertMatch: true,
+				},
+			},
+		},
+		Action: &routepb.Route_DirectResponse{
+			DirectResponse: &routepb.DirectResponseAction{
+				Status: 400,
+				Body: &corepb.DataSource{
+					Specifier: &corepb.DataSource_InlineString{
+						InlineString: "The current request is missing the required header X-Correlation-Id.",
+					},
+				},
+			},
+		},
+		Decorator: &routepb.Decorator{
+			Operation: "ingress UnknownOperationName",
+		},
+	}
+
+	gotRoutes := gotRouteConfig.GetVirtualHosts()[0].GetRoutes()
+	if len(gotRoutes) == 0 || !proto.Equal(gotRoutes[0], wantRoute) {
+		t.Errorf("makeRouteConfig failed, want first route: %v, got routes: %v", wantRoute, gotRoutes)
+	}
+}
+
 func TestHeadersToAdd(t *testing.T) {
 	testData := []struct {
 		desc                  string
@@ -4037,6 +4096,8 @@ func TestHeadersToAdd(t *testing.T) {
 		appendRequestHeaders  string
 		addResponseHeaders    string
 		appendResponseHeaders string
+		localBackendCluster   *configinfo.BackendRoutingCluster
+		remoteBackendClusters []*configinfo.BackendRoutingCluster
 		wantedError           string
 		wantedRequestHeaders  []*corepb.HeaderValueOption
 		wantedResponseHeaders []*corepb.HeaderValueOption
@@ -4130,6 +4191,50 @@ func TestHeadersToAdd(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "fallback backend header for the local backend with a fallback",
+			localBackendCluster: &configinfo.BackendRoutingCluster{
+				ClusterName:      "backend-cluster-test-api_local",
+				Hostname:         "127.0.0.1",
+				Port:             8082,
+				FallbackHostname: "read-only.example.com",
+				FallbackPort:     8080,
+			},
+			wantedResponseHeaders: []*corepb.HeaderValueOption{
+				&corepb.HeaderValueOption{
+					Header: &corepb.HeaderValue{
+						Key:   "X-Endpoint-Backend-Fallback",
+						Value: `%UPSTREAM_METADATA(["espv2", "fallback"])%`,
+					},
+					Append: &wrapperspb.BoolValue{
+						Value: false,
+					},
+				},
+			},
+		},
+		{
+			desc: "fallback backend header for a remote backend with a fallback",
+			remoteBackendClusters: []*configinfo.BackendRoutingCluster{
+				{
+					ClusterName:      "backend-cluster-abc.com:443",
+					Hostname:         "abc.com",
+					Port:             443,
+					FallbackHostname: "read-only.example.com",
+					FallbackPort:     443,
+				},
+			},
+			wantedResponseHeaders: []*corepb.HeaderValueOption{
+				&corepb.HeaderValueOption{
+					Header: &corepb.HeaderValue{
+						Key:   "X-Endpoint-Backend-Fallback",
+						Value: `%UPSTREAM_METADATA(["espv2", "fallback"])%`,
+					},
+					Append: &wrapperspb.BoolValue{
+						Value: false,
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testData {
@@ -4140,8 +4245,10 @@ func TestHeadersToAdd(t *testing.T) {
 		opts.AppendResponseHeaders = tc.appendResponseHeaders
 
 		gotRoute, err := makeRouteConfig(&configinfo.ServiceInfo{
-			Name:    "test-api",
-			Options: opts,
+			Name:                  "test-api",
+			Options:               opts,
+			LocalBackendCluster:   tc.localBackendCluster,
+			RemoteBackendClusters: tc.remoteBackendClusters,
 		})
 		if tc.wantedError != "" {
 			if err == nil || !strings.Contains(err.Error(), tc.wantedError) {
@@ -4183,3 +4290,1008 @@ func getOverSizeRegexForTest() string {
 	}
 	return overSizeRegex
 }
+
+func TestMakeRouteTableForJwtClaimRoutes(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: fmt.Sprintf("%s.Echo", testApiName),
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/echo",
+					},
+				},
+			},
+		},
+		Control: &confpb.Control{
+			Environment: "servicecontrol.googleapis.com",
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.JwtClaimBackendRoutes = "tier:enterprise=https://enterprise.example.com"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each route is preceded by its copy that matches the claim header.
+	wantRoutes := []string{
+		`{"name":"endpoints.examples.bookstore.Bookstore.Echo","match":{"path":"/echo","headers":[{"name":":method","exactMatch":"GET"},{"name":"X-Endpoint-JWT-Claim-tier","exactMatch":"enterprise"}]},"route":{"cluster":"backend-cluster-enterprise.example.com:443","hostRewriteLiteral":"enterprise.example.com","timeout":"15s","idleTimeout":"300s","retryPolicy":{"retryOn":"reset,connect-failure,refused-stream","numRetries":1}},"decorator":{"operation":"ingress Echo"}}`,
+		`{"name":"endpoints.examples.bookstore.Bookstore.Echo","match":{"path":"/echo","headers":[{"name":":method","exactMatch":"GET"}]},"route":{"cluster":"backend-cluster-bookstore.endpoints.project123.cloud.goog_local","timeout":"15s","idleTimeout":"300s","retryPolicy":{"retryOn":"reset,connect-failure,refused-stream","numRetries":1}},"decorator":{"operation":"ingress Echo"}}`,
+		`{"name":"endpoints.examples.bookstore.Bookstore.Echo","match":{"path":"/echo/","headers":[{"name":":method","exactMatch":"GET"},{"name":"X-Endpoint-JWT-Claim-tier","exactMatch":"enterprise"}]},"route":{"cluster":"backend-cluster-enterprise.example.com:443","hostRewriteLiteral":"enterprise.example.com","timeout":"15s","idleTimeout":"300s","retryPolicy":{"retryOn":"reset,connect-failure,refused-stream","numRetries":1}},"decorator":{"operation":"ingress Echo"}}`,
+		`{"name":"endpoints.examples.bookstore.Bookstore.Echo","match":{"path":"/echo/","headers":[{"name":":method","exactMatch":"GET"}]},"route":{"cluster":"backend-cluster-bookstore.endpoints.project123.cloud.goog_local","timeout":"15s","idleTimeout":"300s","retryPolicy":{"retryOn":"reset,connect-failure,refused-stream","numRetries":1}},"decorator":{"operation":"ingress Echo"}}`,
+	}
+	if len(gotRoutes) != len(wantRoutes) {
+		t.Fatalf("got %d routes, want %d", len(gotRoutes), len(wantRoutes))
+	}
+	for i, gotRoute := range gotRoutes {
+		gotJson, err := util.ProtoToJson(gotRoute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := util.JsonEqual(wantRoutes[i], gotJson); err != nil {
+			t.Errorf("route %d mismatch, \n %v", i, err)
+		}
+	}
+}
+
+func TestMakeRouteTableForMirrorBackend(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: fmt.Sprintf("%s.Echo", testApiName),
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/echo",
+					},
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.MirrorBackendAddress = "http://shadow.example.com:8080"
+	opts.MirrorPercent = 12.5
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRoutes := []string{
+		`{"name":"endpoints.examples.bookstore.Bookstore.Echo","match":{"path":"/echo","headers":[{"name":":method","exactMatch":"POST"}]},"route":{"cluster":"backend-cluster-bookstore.endpoints.project123.cloud.goog_local","timeout":"15s","idleTimeout":"300s","retryPolicy":{"retryOn":"reset,connect-failure,refused-stream","numRetries":1},"requestMirrorPolicies":[{"cluster":"backend-cluster-shadow.example.com:8080","runtimeFraction":{"defaultValue":{"numerator":125000,"denominator":"MILLION"}}}]},"decorator":{"operation":"ingress Echo"}}`,
+		`{"name":"endpoints.examples.bookstore.Bookstore.Echo","match":{"path":"/echo/","headers":[{"name":":method","exactMatch":"POST"}]},"route":{"cluster":"backend-cluster-bookstore.endpoints.project123.cloud.goog_local","timeout":"15s","idleTimeout":"300s","retryPolicy":{"retryOn":"reset,connect-failure,refused-stream","numRetries":1},"requestMirrorPolicies":[{"cluster":"backend-cluster-shadow.example.com:8080","runtimeFraction":{"defaultValue":{"numerator":125000,"denominator":"MILLION"}}}]},"decorator":{"operation":"ingress Echo"}}`,
+	}
+	if len(gotRoutes) != len(wantRoutes) {
+		t.Fatalf("got %d routes, want %d", len(gotRoutes), len(wantRoutes))
+	}
+	for i, gotRoute := range gotRoutes {
+		gotJson, err := util.ProtoToJson(gotRoute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := util.JsonEqual(wantRoutes[i], gotJson); err != nil {
+			t.Errorf("route %d mismatch, \n %v", i, err)
+		}
+	}
+}
+
+func TestMakeRouteTableForIPv6Backend(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: fmt.Sprintf("%s.Echo", testApiName),
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/echo",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Selector:        fmt.Sprintf("%s.Echo", testApiName),
+					Address:         "http://[2001:db8::1]:8080",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendAddress = "grpc://[::1]:8082"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRoute := gotRoutes[0].GetRoute()
+	if got, want := gotRoute.GetCluster(), "backend-cluster-[2001:db8::1]:8080"; got != want {
+		t.Errorf("got cluster %v, want %v", got, want)
+	}
+	if got, want := gotRoute.GetHostRewriteLiteral(), "[2001:db8::1]"; got != want {
+		t.Errorf("got host rewrite %v, want %v", got, want)
+	}
+}
+
+func TestMakeRouteTableForMaxGrpcTimeout(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                  string
+		maxGrpcTimeout        time.Duration
+		wantMaxStreamDuration *routepb.RouteAction_MaxStreamDuration
+	}{
+		{
+			desc: "grpc-timeout is not honored by default",
+		},
+		{
+			desc:           "grpc-timeout is honored up to the max",
+			maxGrpcTimeout: 30 * time.Second,
+			wantMaxStreamDuration: &routepb.RouteAction_MaxStreamDuration{
+				GrpcTimeoutHeaderMax: ptypes.DurationProto(30 * time.Second),
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "grpc://127.0.0.1:8082"
+			opts.MaxGrpcTimeout = tc.maxGrpcTimeout
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, gotRoute := range gotRoutes {
+				if got := gotRoute.GetRoute().GetMaxStreamDuration(); !proto.Equal(got, tc.wantMaxStreamDuration) {
+					t.Errorf("route %v: got max stream duration %v, want %v", gotRoute.GetName(), got, tc.wantMaxStreamDuration)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeRouteTableForTranscodingMaxMessageBytes(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+					{
+						Name: "Upload",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.Echo",
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/echo",
+					},
+					Body: "*",
+				},
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.Upload",
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/upload",
+					},
+					Body: "*",
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                       string
+		backendAddress             string
+		transcodingMaxMessageBytes int
+		disableTranscoding         bool
+		wantBufferLimitBytes       map[string]uint32
+		wantError                  string
+	}{
+		{
+			desc:           "Buffer limit is not set by default",
+			backendAddress: "grpc://127.0.0.1:8082",
+		},
+		{
+			desc:                       "Buffer limit is raised on the routes to gRPC backends, except for streamed requests",
+			backendAddress:             "grpc://127.0.0.1:8082",
+			transcodingMaxMessageBytes: 8388608,
+			wantBufferLimitBytes: map[string]uint32{
+				"endpoints.examples.bookstore.Bookstore.Echo": 8388608,
+			},
+		},
+		{
+			desc:                       "Max message size is ignored for http backends",
+			backendAddress:             "http://127.0.0.1:8082",
+			transcodingMaxMessageBytes: 8388608,
+		},
+		{
+			desc:                       "Negative max message size",
+			backendAddress:             "grpc://127.0.0.1:8082",
+			transcodingMaxMessageBytes: -1,
+			wantError:                  "flag --transcoding_max_message_bytes must not be negative, got -1",
+		},
+		{
+			desc:                       "Max message size without transcoding",
+			backendAddress:             "grpc://127.0.0.1:8082",
+			transcodingMaxMessageBytes: 8388608,
+			disableTranscoding:         true,
+			wantError:                  "flag --transcoding_max_message_bytes cannot be used with --disable_transcoding",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = tc.backendAddress
+			opts.TranscodingMaxMessageBytes = tc.transcodingMaxMessageBytes
+			opts.DisableTranscoding = tc.disableTranscoding
+			opts.StreamingPassthroughOperations = "endpoints.examples.bookstore.Bookstore.Upload"
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, gotRoute := range gotRoutes {
+				gotBufferLimit := gotRoute.GetPerRequestBufferLimitBytes()
+				wantBufferLimit, ok := tc.wantBufferLimitBytes[gotRoute.GetName()]
+				if !ok {
+					if gotBufferLimit != nil {
+						t.Errorf("route %v: got buffer limit %v, want none", gotRoute.GetName(), gotBufferLimit.GetValue())
+					}
+					continue
+				}
+				if gotBufferLimit.GetValue() != wantBufferLimit {
+					t.Errorf("route %v: got buffer limit %v, want %v", gotRoute.GetName(), gotBufferLimit.GetValue(), wantBufferLimit)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeRouteTableForStreamingResponses(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+					{
+						Name: "Events",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.Echo",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/echo",
+					},
+				},
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.Events",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/events",
+					},
+				},
+			},
+		},
+	}
+	noTransformHeader := []*corepb.HeaderValueOption{
+		{
+			Header: &corepb.HeaderValue{
+				Key:   "Cache-Control",
+				Value: "no-transform",
+			},
+			Append: &wrapperspb.BoolValue{
+				Value: true,
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                      string
+		enableResponseCompression bool
+		wantTimeouts              map[string]time.Duration
+		wantIdleTimeouts          map[string]time.Duration
+		wantResponseHeaders       map[string][]*corepb.HeaderValueOption
+	}{
+		{
+			desc: "Streaming responses are not cut by the deadline, but still by the idle timeout",
+			wantTimeouts: map[string]time.Duration{
+				"endpoints.examples.bookstore.Bookstore.Echo":   util.DefaultResponseDeadline,
+				"endpoints.examples.bookstore.Bookstore.Events": 0,
+			},
+			wantIdleTimeouts: map[string]time.Duration{
+				"endpoints.examples.bookstore.Bookstore.Echo":   util.DefaultIdleTimeout,
+				"endpoints.examples.bookstore.Bookstore.Events": util.DefaultIdleTimeout,
+			},
+		},
+		{
+			desc:                      "Streaming responses are not compressed",
+			enableResponseCompression: true,
+			wantTimeouts: map[string]time.Duration{
+				"endpoints.examples.bookstore.Bookstore.Echo":   util.DefaultResponseDeadline,
+				"endpoints.examples.bookstore.Bookstore.Events": 0,
+			},
+			wantIdleTimeouts: map[string]time.Duration{
+				"endpoints.examples.bookstore.Bookstore.Echo":   util.DefaultIdleTimeout,
+				"endpoints.examples.bookstore.Bookstore.Events": util.DefaultIdleTimeout,
+			},
+			wantResponseHeaders: map[string][]*corepb.HeaderValueOption{
+				"endpoints.examples.bookstore.Bookstore.Events": noTransformHeader,
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.StreamingResponseOperations = "endpoints.examples.bookstore.Bookstore.Events"
+			opts.EnableResponseCompression = tc.enableResponseCompression
+			fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, gotRoute := range gotRoutes {
+				wantTimeout, ok := tc.wantTimeouts[gotRoute.GetName()]
+				if !ok {
+					continue
+				}
+				if got := gotRoute.GetRoute().GetTimeout(); !proto.Equal(got, ptypes.DurationProto(wantTimeout)) {
+					t.Errorf("route %v: got timeout %v, want %v", gotRoute.GetName(), got, wantTimeout)
+				}
+				if got, want := gotRoute.GetRoute().GetIdleTimeout(), tc.wantIdleTimeouts[gotRoute.GetName()]; !proto.Equal(got, ptypes.DurationProto(want)) {
+					t.Errorf("route %v: got idle timeout %v, want %v", gotRoute.GetName(), got, want)
+				}
+				gotHeaders := &routepb.Route{ResponseHeadersToAdd: gotRoute.GetResponseHeadersToAdd()}
+				wantHeaders := &routepb.Route{ResponseHeadersToAdd: tc.wantResponseHeaders[gotRoute.GetName()]}
+				if !proto.Equal(gotHeaders, wantHeaders) {
+					t.Errorf("route %v: got response headers %v, want %v", gotRoute.GetName(), gotHeaders.ResponseHeadersToAdd, wantHeaders.ResponseHeadersToAdd)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeRouteTableForQueryParameterAllowlist(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+					{
+						Name: "Foo",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.Echo",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/echo",
+					},
+				},
+				{
+					Selector: "endpoints.examples.bookstore.Bookstore.Foo",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/foo",
+					},
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.QueryParameterAllowlistByOperation = "endpoints.examples.bookstore.Bookstore.Echo=msg"
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each route of Echo is preceded by a route rejecting its requests with
+	// disallowed query parameters, and Foo has none.
+	var echoRoutes, rejectRoutes int
+	for i, gotRoute := range gotRoutes {
+		if gotRoute.GetName() == "endpoints.examples.bookstore.Bookstore.Echo" {
+			echoRoutes++
+		}
+		if gotRoute.GetDirectResponse() == nil {
+			continue
+		}
+		rejectRoutes++
+		if i+1 >= len(gotRoutes) || gotRoutes[i+1].GetName() != "endpoints.examples.bookstore.Bookstore.Echo" {
+			t.Errorf("the reject route should be right before a route of Echo, got routes %v", gotRoutes)
+		}
+		gotPath := &routepb.RouteMatch{PathSpecifier: gotRoute.GetMatch().GetPathSpecifier()}
+		wantPath := &routepb.RouteMatch{PathSpecifier: gotRoutes[i+1].GetMatch().GetPathSpecifier()}
+		if !proto.Equal(gotPath, wantPath) {
+			t.Errorf("the reject route should match the path of the route of Echo, got %v", gotRoute.GetMatch())
+		}
+		if got := gotRoute.GetDirectResponse().GetStatus(); got != http.StatusBadRequest {
+			t.Errorf("got reject status %v, want %v", got, http.StatusBadRequest)
+		}
+	}
+	if rejectRoutes == 0 || rejectRoutes != echoRoutes {
+		t.Fatalf("got %v reject routes, want one for each of the %v routes of Echo", rejectRoutes, echoRoutes)
+	}
+
+	headers := gotRoutes[0].GetMatch().GetHeaders()
+	pathMatcher := headers[len(headers)-1]
+	if pathMatcher.GetName() != ":path" || !pathMatcher.GetInvertMatch() {
+		t.Fatalf("got header matcher %v, want an inverted :path matcher", pathMatcher)
+	}
+	pathRegex := regexp.MustCompile(pathMatcher.GetSafeRegexMatch().GetRegex())
+	for path, wantAllowed := range map[string]bool{
+		"/echo":                   true,
+		"/echo?":                  true,
+		"/echo?msg=hello":         true,
+		"/echo?msg=hello&key=abc": true,
+		"/echo?api_key=abc&msg":   true,
+		"/echo?msg=hello&":        true,
+		"/echo?&msg=hello&&key=1": true,
+		"/echo?&&":                true,
+		"/echo?msg=hello&&debug":  false,
+		"/echo?debug=1":           false,
+		"/echo?msg=hello&debug=1": false,
+		"/echo?message=hello":     false,
+	} {
+		if got := pathRegex.MatchString(path); got != wantAllowed {
+			t.Errorf("path %v: got allowed %v, want %v", path, got, wantAllowed)
+		}
+	}
+}
+
+func TestMakeRouteTableForBackendHostRewrites(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+					{
+						Name: "Foo",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: fmt.Sprintf("%s.Echo", testApiName),
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/echo",
+					},
+				},
+				{
+					Selector: fmt.Sprintf("%s.Foo", testApiName),
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/foo",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Selector:        fmt.Sprintf("%s.Echo", testApiName),
+					Address:         "https://backend.example.com/api",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Selector:        fmt.Sprintf("%s.Foo", testApiName),
+					Address:         "https://backend.example.com/api",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.BackendHostRewriteByOperation = fmt.Sprintf("%s.Echo=api.example.com", testApiName)
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRoutes, _, err := MakeRouteTable(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHostRewrites := map[string]string{
+		fmt.Sprintf("%s.Echo", testApiName): "api.example.com",
+		fmt.Sprintf("%s.Foo", testApiName):  "backend.example.com",
+	}
+	for _, gotRoute := range gotRoutes {
+		if got, want := gotRoute.GetRoute().GetHostRewriteLiteral(), wantHostRewrites[gotRoute.Name]; got != want {
+			t.Errorf("route %v: got host rewrite %v, want %v", gotRoute.Name, got, want)
+		}
+	}
+}
+
+func TestMakeRouteConfigForCorsDisabledOperations(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+					{
+						Name: "Foo",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: fmt.Sprintf("%s.Echo", testApiName),
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/echo",
+					},
+				},
+				{
+					Selector: fmt.Sprintf("%s.Foo", testApiName),
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/foo",
+					},
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.CorsPreset = "basic"
+	opts.CorsAllowOrigin = "*"
+	opts.CorsDisabledOperations = fmt.Sprintf("%s.Echo", testApiName)
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routeConfig, err := makeRouteConfig(fakeServiceInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotCorsDisabledPreflightIndex, gotCatchAllPreflightIndex int
+	for i, route := range routeConfig.VirtualHosts[0].Routes {
+		switch {
+		case route.Name == fmt.Sprintf("%s.Echo", testApiName):
+			if got := route.GetRoute().GetCors().GetFilterEnabled().GetDefaultValue(); got == nil || got.Numerator != 0 {
+				t.Errorf("route %v: got CORS filter enabled %v, want 0%%", route.Name, got)
+			}
+		case route.Name == fmt.Sprintf("%s.Foo", testApiName):
+			if got := route.GetRoute().GetCors(); got != nil {
+				t.Errorf("route %v: got CORS policy %v, want the one of the virtual host", route.Name, got)
+			}
+		case route.GetMatch().GetPath() == "/echo" && len(route.GetMatch().GetHeaders()) == 1 &&
+			route.GetMatch().GetHeaders()[0].GetExactMatch() == "OPTIONS":
+			if route.GetDirectResponse().GetStatus() != http.StatusMethodNotAllowed {
+				t.Errorf("the preflight route of the CORS disabled operation should reply 405, got %v", route)
+			}
+			gotCorsDisabledPreflightIndex = i
+		case route.GetMatch().GetPrefix() == "/" && route.GetRoute() != nil:
+			gotCatchAllPreflightIndex = i
+		}
+	}
+	if gotCorsDisabledPreflightIndex == 0 || gotCorsDisabledPreflightIndex > gotCatchAllPreflightIndex {
+		t.Errorf("the preflight route of the CORS disabled operation (%v) should be before the catch-all preflight route (%v)", gotCorsDisabledPreflightIndex, gotCatchAllPreflightIndex)
+	}
+}
+
+func TestMakeRouteConfigForOperationStats(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "Echo",
+					},
+					{
+						Name: "GetShelf",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: fmt.Sprintf("%s.Echo", testApiName),
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/echo",
+					},
+				},
+				{
+					Selector: fmt.Sprintf("%s.GetShelf", testApiName),
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/shelves/{shelf}",
+					},
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                 string
+		enableOperationStats bool
+		wantVirtualClusters  string
+	}{
+		{
+			desc:                "operation stats are disabled by default",
+			wantVirtualClusters: `{}`,
+		},
+		{
+			desc:                 "one virtual cluster per http rule",
+			enableOperationStats: true,
+			wantVirtualClusters: `{
+  "virtualClusters": [
+    {
+      "name": "endpoints_examples_bookstore_Bookstore_Echo",
+      "headers": [
+        {
+          "name": ":path",
+          "safeRegexMatch": {
+            "googleRe2": {},
+            "regex": "^/echo\\/?(\\?.*)?$"
+          }
+        },
+        {
+          "name": ":method",
+          "exactMatch": "POST"
+        }
+      ]
+    },
+    {
+      "name": "endpoints_examples_bookstore_Bookstore_GetShelf",
+      "headers": [
+        {
+          "name": ":path",
+          "safeRegexMatch": {
+            "googleRe2": {},
+            "regex": "^/shelves/[^\\/]+\\/?(\\?.*)?$"
+          }
+        },
+        {
+          "name": ":method",
+          "exactMatch": "GET"
+        }
+      ]
+    }
+  ]
+}`,
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.EnableOperationStats = tc.enableOperationStats
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotRouteConfig, err := makeRouteConfig(fakeServiceInfo)
+		if err != nil {
+			t.Fatalf("Test (%s): makeRouteConfig got error: %v", tc.desc, err)
+		}
+
+		gotJson, err := util.ProtoToJson(&routepb.VirtualHost{
+			VirtualClusters: gotRouteConfig.VirtualHosts[0].VirtualClusters,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := util.JsonEqual(tc.wantVirtualClusters, gotJson); err != nil {
+			t.Errorf("Test (%s): makeRouteConfig virtual clusters mismatch, \n %v", tc.desc, err)
+		}
+	}
+}
+
+func TestMakeRouteConfigForDuplicateHttpPatterns(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "GetShelf",
+					},
+					{
+						Name: "GetShelfV2",
+					},
+					{
+						Name: "GetFeaturedShelf",
+					},
+				},
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: fmt.Sprintf("%s.GetShelf", testApiName),
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/shelves/{shelf}",
+					},
+				},
+				{
+					Selector: fmt.Sprintf("%s.GetShelfV2", testApiName),
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/shelves/{id}",
+					},
+				},
+				{
+					Selector: fmt.Sprintf("%s.GetFeaturedShelf", testApiName),
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/shelves/featured",
+					},
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                       string
+		allowDuplicateHttpPatterns bool
+		wantOperations             []string
+		wantError                  string
+	}{
+		{
+			desc:      "duplicate http patterns are rejected by default",
+			wantError: "fail to sort route match, endpoints.examples.bookstore.Bookstore.GetShelfV2 has duplicate http pattern `GET /shelves/{id}`",
+		},
+		{
+			desc:                       "the first operation is kept and the most specific path is matched first",
+			allowDuplicateHttpPatterns: true,
+			wantOperations: []string{
+				"ingress GetFeaturedShelf",
+				"ingress GetShelf",
+				"ingress UnknownHttpMethodForPath_/shelves/featured",
+				"ingress UnknownHttpMethodForPath_/shelves/{shelf}",
+				"ingress UnknownOperationName",
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.AllowDuplicateHttpPatterns = tc.allowDuplicateHttpPatterns
+		fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotRouteConfig, err := makeRouteConfig(fakeServiceInfo)
+		if tc.wantError != "" {
+			if err == nil || err.Error() != tc.wantError {
+				t.Errorf("Test (%s): got error %v, want %v", tc.desc, err, tc.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): makeRouteConfig got error: %v", tc.desc, err)
+		}
+
+		var gotOperations []string
+		for _, route := range gotRouteConfig.VirtualHosts[0].Routes {
+			operation := route.GetDecorator().GetOperation()
+			if operation == "" || (len(gotOperations) > 0 && gotOperations[len(gotOperations)-1] == operation) {
+				continue
+			}
+			gotOperations = append(gotOperations, operation)
+		}
+		if !reflect.DeepEqual(gotOperations, tc.wantOperations) {
+			t.Errorf("Test (%s): got route operations %v, want %v", tc.desc, gotOperations, tc.wantOperations)
+		}
+	}
+}
+
+func TestMakeRouteConfigForStaticResponses(t *testing.T) {
+	// Larger than the default limit of Envoy on direct response bodies.
+	favicon := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 5000)...)
+	faviconPath := filepath.Join(t.TempDir(), "favicon.png")
+	if err := ioutil.WriteFile(faviconPath, favicon, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: testApiName,
+				Methods: []*apipb.Method{
+					{
+						Name: "ListShelves",
+					},
+				},
+			},
+		},
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.StaticResponses = fmt.Sprintf("/favicon.ico=%s, /robots.txt", faviconPath)
+	fakeServiceInfo, err := configinfo.NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRouteConfig, err := makeRouteConfig(fakeServiceInfo)
+	if err != nil {
+		t.Fatalf("makeRouteConfig got error: %v", err)
+	}
+	if got, want := gotRouteConfig.GetMaxDirectResponseBodySizeBytes().GetValue(), uint32(len(favicon)); got != want {
+		t.Errorf("got max direct response body size %v, want %v", got, want)
+	}
+
+	wantRoutes := map[string]struct {
+		path        string
+		status      uint32
+		body        []byte
+		contentType string
+	}{
+		"espv2_deployment.ESPv2_Autogenerated_StaticResponse_0": {
+			path:        "/favicon.ico",
+			status:      http.StatusOK,
+			body:        favicon,
+			contentType: "image/png",
+		},
+		"espv2_deployment.ESPv2_Autogenerated_StaticResponse_1": {
+			path:   "/robots.txt",
+			status: http.StatusNoContent,
+		},
+	}
+	for _, route := range gotRouteConfig.VirtualHosts[0].Routes {
+		want, ok := wantRoutes[route.GetName()]
+		if !ok {
+			continue
+		}
+		delete(wantRoutes, route.GetName())
+
+		if got := route.GetMatch().GetPath(); got != want.path {
+			t.Errorf("route (%v): got path %v, want %v", route.GetName(), got, want.path)
+		}
+		if route.GetMatch().GetHeaders() != nil {
+			t.Errorf("route (%v): should match any http method, got headers %v", route.GetName(), route.GetMatch().GetHeaders())
+		}
+		directResponse := route.GetDirectResponse()
+		if directResponse == nil {
+			t.Fatalf("route (%v): should be a direct response, got %v", route.GetName(), route)
+		}
+		if directResponse.GetStatus() != want.status {
+			t.Errorf("route (%v): got status %v, want %v", route.GetName(), directResponse.GetStatus(), want.status)
+		}
+		if got := directResponse.GetBody().GetInlineBytes(); !reflect.DeepEqual(got, want.body) {
+			t.Errorf("route (%v): got body of %d bytes, want %d bytes", route.GetName(), len(got), len(want.body))
+		}
+		var gotContentType string
+		for _, header := range route.GetResponseHeadersToAdd() {
+			if header.GetHeader().GetKey() == "content-type" {
+				gotContentType = header.GetHeader().GetValue()
+			}
+		}
+		if gotContentType != want.contentType {
+			t.Errorf("route (%v): got content-type %q, want %q", route.GetName(), gotContentType, want.contentType)
+		}
+	}
+	for name := range wantRoutes {
+		t.Errorf("route (%v) is not found", name)
+	}
+}
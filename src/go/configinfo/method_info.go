@@ -40,6 +40,17 @@ type MethodInfo struct {
 	MetricCosts        []*scpb.MetricCost
 	// All non-unary gRPC methods are considered streaming.
 	IsStreaming bool
+	// Requests are streamed to the backend, neither buffered nor transcoded.
+	StreamingPassthrough bool
+	// Responses are streamed to the client, neither compressed nor cut by the deadline.
+	StreamingResponses bool
+	// The Envoy CORS policy doesn't apply to the operation, and its CORS
+	// preflight requests are rejected.
+	CorsDisabled bool
+	// Query parameters allowed in requests, the others are rejected. All are allowed if empty.
+	AllowedQueryParameters []string
+	// Response served by Envoy instead of routing to the backend, if set.
+	StaticResponse *staticResponse
 
 	// The request type name (not the entire type URL).
 	RequestTypeName string
@@ -54,6 +65,13 @@ type MethodInfo struct {
 	PerRouteConfigGens []*PerRouteConfigGenerator
 }
 
+// staticResponse is a response of --static_responses.
+type staticResponse struct {
+	Status      uint32
+	Body        []byte
+	ContentType string
+}
+
 // backendInfo stores information from Backend rule for backend rerouting.
 type backendInfo struct {
 	ClusterName     string
@@ -65,6 +83,22 @@ type backendInfo struct {
 	// If empty, backend auth should be disabled for the method.
 	JwtAudience string
 
+	// File holding a static bearer token sent to the backend instead of a JWT.
+	StaticTokenFile string
+
+	// Request header the backend auth token is sent in, instead of Authorization.
+	TokenHeader string
+
+	// Host header sent to the backend, instead of Hostname.
+	HostRewrite string
+
+	// Path prefix removed from the request path before it is sent to the backend.
+	StripPrefix string
+
+	// RE2 regex rewrite of the path sent to the backend, if the pattern is set.
+	RegexRewritePattern      string
+	RegexRewriteSubstitution string
+
 	// Response timeout for the backend.
 	Deadline    time.Duration
 	IdleTimeout time.Duration
@@ -74,6 +108,12 @@ type backendInfo struct {
 	RetryNum             uint
 	RetriableStatusCodes []uint32
 	PerTryTimeout        time.Duration
+
+	// Requests are buffered and sent with a Content-Length instead of chunked.
+	BufferRequests bool
+
+	// Requests with larger bodies are rejected, if it is not 0.
+	MaxRequestBytes uint32
 }
 
 type SnakeToJsonSegments = map[string]string
@@ -82,6 +122,17 @@ func (m *MethodInfo) Operation() string {
 	return m.ApiName + "." + m.ShortName
 }
 
+// BuffersRequests returns whether the requests are buffered by the buffer
+// filter, either to send them with a Content-Length or to limit their size.
+// The requests of streaming passthrough methods are never buffered, even if
+// they share their backend info with a buffered method.
+func (m *MethodInfo) BuffersRequests() bool {
+	if m.StreamingPassthrough {
+		return false
+	}
+	return m.BackendInfo != nil && (m.BackendInfo.BufferRequests || m.BackendInfo.MaxRequestBytes > 0)
+}
+
 type PerRouteConfigGenerator struct {
 	FilterName string
 	PerRouteConfigGenFunc
@@ -0,0 +1,76 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configinfo
+
+import (
+	"testing"
+)
+
+func TestBuffersRequests(t *testing.T) {
+	testData := []struct {
+		desc                 string
+		backendInfo          *backendInfo
+		streamingPassthrough bool
+		want                 bool
+	}{
+		{
+			desc:        "No backend info",
+			backendInfo: nil,
+			want:        false,
+		},
+		{
+			desc:        "Requests are not buffered by default",
+			backendInfo: &backendInfo{},
+			want:        false,
+		},
+		{
+			desc: "Requests are buffered to be sent with a Content-Length",
+			backendInfo: &backendInfo{
+				BufferRequests: true,
+			},
+			want: true,
+		},
+		{
+			desc: "Requests are buffered to limit their size",
+			backendInfo: &backendInfo{
+				MaxRequestBytes: 1048576,
+			},
+			want: true,
+		},
+		{
+			// The backend info is shared with the generated CORS method,
+			// which can have a size limit.
+			desc: "Streaming passthrough requests are never buffered",
+			backendInfo: &backendInfo{
+				BufferRequests:  true,
+				MaxRequestBytes: 1048576,
+			},
+			streamingPassthrough: true,
+			want:                 false,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			method := &MethodInfo{
+				BackendInfo:          tc.backendInfo,
+				StreamingPassthrough: tc.streamingPassthrough,
+			}
+			if got := method.BuffersRequests(); got != tc.want {
+				t.Errorf("got BuffersRequests %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -16,7 +16,11 @@ package configinfo
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math"
+	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -25,6 +29,7 @@ import (
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util/httppattern"
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 
 	commonpb "github.com/GoogleCloudPlatform/esp-v2/src/go/proto/api/envoy/v10/http/common"
@@ -57,6 +62,9 @@ type ServiceInfo struct {
 	// Stores all the query parameters to be ignored for json-grpc transcoder.
 	AllTranscodingIgnoredQueryParams map[string]bool
 
+	// Request headers that every request must carry.
+	RequiredRequestHeaders []string
+
 	AllowCors         bool
 	ServiceControlURI string
 	GcpAttributes     *scpb.GcpAttributes
@@ -70,6 +78,16 @@ type ServiceInfo struct {
 	GrpcSupportRequired   bool
 	LocalBackendCluster   *BackendRoutingCluster
 	RemoteBackendClusters []*BackendRoutingCluster
+
+	// Routes to dedicated backends based on claims of the verified JWT.
+	JwtClaimRoutes []*JwtClaimRoute
+
+	// Maps the id of each provider added for an additional issuer to the id of
+	// the provider it is copied from.
+	JwtProviderAdditionalIssuerBases map[string]string
+
+	// The cluster that requests to backends are mirrored to, if any.
+	MirrorBackendClusterName string
 }
 
 type BackendRoutingCluster struct {
@@ -78,6 +96,39 @@ type BackendRoutingCluster struct {
 	Port        uint32
 	UseTLS      bool
 	Protocol    util.BackendProtocol
+
+	// The backend that takes over when no host of the cluster is healthy.
+	// Empty if there is none.
+	FallbackHostname string
+	FallbackPort     uint32
+
+	// Connect timeout set for an operation of the backend. If it is 0, the
+	// connect timeout from the options is used.
+	ConnectTimeout time.Duration
+
+	// TLS SNI set for an operation of the backend. If it is empty, the
+	// Hostname is used.
+	Sni string
+
+	// ALPN protocol set for an operation of the backend, which also decides
+	// the Protocol. If it is empty, the ALPN follows the Protocol.
+	Alpn string
+}
+
+// jwtClaimNameRegex matches claim names that can be used in a header name.
+var jwtClaimNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// headerNameRegex matches the HTTP header names that can be configured, i.e.
+// the tokens of RFC 7230.
+var headerNameRegex = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9a-zA-Z-]+$")
+
+// JwtClaimRoute routes requests whose verified JWT has the claim value to a
+// dedicated backend.
+type JwtClaimRoute struct {
+	Claim       string
+	Value       string
+	ClusterName string
+	Hostname    string
 }
 
 // NewServiceInfoFromServiceConfig returns an instance of ServiceInfo.
@@ -124,6 +175,12 @@ func NewServiceInfoFromServiceConfig(serviceConfig *confpb.Service, id string, o
 	if err := serviceInfo.processBackendRule(); err != nil {
 		return nil, err
 	}
+	if err := serviceInfo.processJwtClaimRoutes(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processMirrorBackend(); err != nil {
+		return nil, err
+	}
 	if err := serviceInfo.processHttpRule(); err != nil {
 		return nil, err
 	}
@@ -145,18 +202,69 @@ func NewServiceInfoFromServiceConfig(serviceConfig *confpb.Service, id string, o
 		return nil, err
 	}
 
+	if err := serviceInfo.processJwtProviderAdditionalIssuers(); err != nil {
+		return nil, err
+	}
 	if err := serviceInfo.processEmptyJwksUriByOpenID(); err != nil {
 		return nil, err
 	}
+	if err := serviceInfo.processJwksWarmOnStartup(); err != nil {
+		return nil, err
+	}
 	if err := serviceInfo.processLocalBackendOperations(); err != nil {
 		return nil, err
 	}
 	if err := serviceInfo.processAllBackends(); err != nil {
 		return nil, err
 	}
+	if err := serviceInfo.processBackendAuthStaticTokenFiles(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendAuthTokenHeaders(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendHostRewrites(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendStripPrefixes(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendRegexRewrites(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendConnectTimeouts(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendSnis(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendAlpns(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processBackendFallbackAddresses(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processDisableChunkedEncodingBackends(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processStreamingPassthroughOperations(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processStreamingResponseOperations(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processMaxRequestBytes(); err != nil {
+		return nil, err
+	}
+	if err := serviceInfo.processQueryParameterAllowlists(); err != nil {
+		return nil, err
+	}
 	if err := serviceInfo.processAuthRequirement(); err != nil {
 		return nil, err
 	}
+	if err := serviceInfo.processRequiredRequestHeaders(); err != nil {
+		return nil, err
+	}
 
 	return serviceInfo, nil
 }
@@ -184,6 +292,23 @@ func (s *ServiceInfo) buildLocalBackend() error {
 		Hostname:    hostname,
 		Port:        port,
 	}
+
+	if s.Options.BackendFallbackAddress != "" {
+		fallbackScheme, fallbackHostname, fallbackPort, _, err := util.ParseURI(s.Options.BackendFallbackAddress)
+		if err != nil {
+			return fmt.Errorf("error parsing fallback backend uri: %v", err)
+		}
+		// The fallback backend shares the cluster, and so its protocol and TLS
+		// settings, with the local backend.
+		if fallbackScheme != scheme {
+			return fmt.Errorf("fallback backend address %q must use the same scheme as the backend address %q", s.Options.BackendFallbackAddress, s.Options.BackendAddress)
+		}
+		s.LocalBackendCluster.FallbackHostname = fallbackHostname
+		s.LocalBackendCluster.FallbackPort = fallbackPort
+	} else if s.Options.BackendFallbackAddressByOperation == "" && (s.Options.BackendHealthCheckPath != "" || s.Options.BackendHealthCheckHost != "" || s.Options.BackendHealthCheckExpectedStatuses != "") {
+		// Only the backends with a fallback are health checked.
+		return fmt.Errorf("flags --backend_health_check_path, --backend_health_check_host and --backend_health_check_expected_statuses require --backend_fallback_address or --backend_fallback_address_by_operation")
+	}
 	return nil
 }
 
@@ -219,6 +344,102 @@ func (s *ServiceInfo) processEmptyJwksUriByOpenID() error {
 	return nil
 }
 
+// processJwtProviderAdditionalIssuers adds a copy of an authentication provider
+// for each of its additional issuers, and lets every requirement on the
+// provider also be satisfied by the copies.
+func (s *ServiceInfo) processJwtProviderAdditionalIssuers() error {
+	if s.Options.JwtProviderAdditionalIssuers == "" {
+		return nil
+	}
+
+	// The service config may be shared with the caller, so only modify a copy.
+	s.serviceConfig = proto.Clone(s.serviceConfig).(*confpb.Service)
+	authn := s.serviceConfig.GetAuthentication()
+
+	providers := make(map[string]*confpb.AuthProvider)
+	for _, provider := range authn.GetProviders() {
+		providers[provider.GetId()] = provider
+	}
+
+	aliases := make(map[string][]string)
+	for _, entry := range strings.Split(s.Options.JwtProviderAdditionalIssuers, ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) == 2 {
+			kv[0], kv[1] = strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		}
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid entry (%v) in --jwt_provider_additional_issuers, it should be provider_id=issuer or provider_id=issuer|jwks_uri", entry)
+		}
+		base, ok := providers[kv[0]]
+		if !ok {
+			return fmt.Errorf("additional issuer is set for provider (%v), which is not an authentication provider in the service config", kv[0])
+		}
+
+		issuerAndJwksUri := strings.SplitN(kv[1], "|", 2)
+		alias := proto.Clone(base).(*confpb.AuthProvider)
+		alias.Id = fmt.Sprintf("%s_issuer_%d", base.GetId(), len(aliases[base.GetId()])+1)
+		alias.Issuer = strings.TrimSpace(issuerAndJwksUri[0])
+		alias.JwksUri = ""
+		if len(issuerAndJwksUri) == 2 {
+			alias.JwksUri = strings.TrimSpace(issuerAndJwksUri[1])
+		}
+		if _, ok := providers[alias.Id]; ok {
+			return fmt.Errorf("additional issuer (%v) of provider (%v) conflicts with the existing authentication provider (%v)", alias.Issuer, base.GetId(), alias.Id)
+		}
+
+		providers[alias.Id] = alias
+		aliases[base.GetId()] = append(aliases[base.GetId()], alias.Id)
+		if s.JwtProviderAdditionalIssuerBases == nil {
+			s.JwtProviderAdditionalIssuerBases = make(map[string]string)
+		}
+		s.JwtProviderAdditionalIssuerBases[alias.Id] = base.GetId()
+		authn.Providers = append(authn.Providers, alias)
+	}
+
+	for _, rule := range authn.GetRules() {
+		var requirements []*confpb.AuthRequirement
+		for _, requirement := range rule.GetRequirements() {
+			requirements = append(requirements, requirement)
+			for _, aliasId := range aliases[requirement.GetProviderId()] {
+				requirements = append(requirements, &confpb.AuthRequirement{
+					ProviderId: aliasId,
+					Audiences:  requirement.GetAudiences(),
+				})
+			}
+		}
+		rule.Requirements = requirements
+	}
+	return nil
+}
+
+// processJwksWarmOnStartup fetches the JWKS of all authentication providers,
+// so unreachable providers are found before serving any request.
+func (s *ServiceInfo) processJwksWarmOnStartup() error {
+	switch s.Options.JwksWarmOnStartup {
+	case "":
+		return nil
+	case "optional", "required":
+	default:
+		return fmt.Errorf(`invalid value %q for --jwks_warm_on_startup, it should be "optional" or "required"`, s.Options.JwksWarmOnStartup)
+	}
+	// JWKS is not needed when JWTs are validated upstream.
+	if s.Options.TrustPreauthenticatedJwtHeader != "" {
+		return nil
+	}
+
+	for _, provider := range s.serviceConfig.GetAuthentication().GetProviders() {
+		if err := util.FetchJwks(provider.GetJwksUri(), s.Options.HttpRequestTimeout); err != nil {
+			if s.Options.JwksWarmOnStartup == "required" {
+				return fmt.Errorf("error warming jwks of authentication provider (%v): %v", provider.Id, err)
+			}
+			glog.Warningf("fail to warm jwks of authentication provider (%v), it is fetched on demand: %v", provider.Id, err)
+			continue
+		}
+		glog.Infof("warmed jwks of authentication provider (%v)", provider.Id)
+	}
+	return nil
+}
+
 func (s *ServiceInfo) processApis() error {
 	for _, api := range s.serviceConfig.GetApis() {
 		s.ApiNames = append(s.ApiNames, api.Name)
@@ -311,24 +532,39 @@ func (s *ServiceInfo) processAccessToken() {
 
 func (s *ServiceInfo) processQuota() error {
 	for _, metricRule := range s.ServiceConfig().GetQuota().GetMetricRules() {
-		var metricCosts []*scpb.MetricCost
-		for name, cost := range metricRule.GetMetricCosts() {
-			metricCosts = append(metricCosts, &scpb.MetricCost{
-				Name: name,
-				Cost: cost,
-			})
-		}
-
 		mi, err := s.getMethod(metricRule.GetSelector())
 		if err != nil {
 			return fmt.Errorf("error processing quota metric rule: %v", err)
 		}
-		mi.MetricCosts = metricCosts
+
+		// A method may be selected by multiple metric rules, so the costs are
+		// merged instead of replaced. Costs for the same metric are summed.
+		for name, cost := range metricRule.GetMetricCosts() {
+			mi.MetricCosts = addMetricCost(mi.MetricCosts, name, cost)
+		}
+
+		// Sort to keep the generated config stable, map iteration is random.
+		sort.Slice(mi.MetricCosts, func(i, j int) bool {
+			return mi.MetricCosts[i].GetName() < mi.MetricCosts[j].GetName()
+		})
 	}
 
 	return nil
 }
 
+func addMetricCost(metricCosts []*scpb.MetricCost, name string, cost int64) []*scpb.MetricCost {
+	for _, metricCost := range metricCosts {
+		if metricCost.GetName() == name {
+			metricCost.Cost += cost
+			return metricCosts
+		}
+	}
+	return append(metricCosts, &scpb.MetricCost{
+		Name: name,
+		Cost: cost,
+	})
+}
+
 func (s *ServiceInfo) processEndpoints() {
 	for _, endpoint := range s.ServiceConfig().GetEndpoints() {
 		if endpoint.GetName() == s.ServiceConfig().GetName() && endpoint.GetAllowCors() {
@@ -414,6 +650,11 @@ func (s *ServiceInfo) processHttpRule() error {
 		}
 	}
 
+	corsDisabledOperations, err := s.processCorsDisabledOperations()
+	if err != nil {
+		return err
+	}
+
 	// In order to support CORS. HTTP method OPTIONS needs to be added to all
 	// urls except the ones already with options.
 	if s.AllowCors {
@@ -422,6 +663,9 @@ func (s *ServiceInfo) processHttpRule() error {
 			if err != nil {
 				return fmt.Errorf("error processing http rule for operation (%v): %v", r.GetSelector(), err)
 			}
+			if corsDisabledOperations[r.GetSelector()] {
+				continue
+			}
 
 			for _, httpRule := range method.HttpRule {
 				if httpRule.HttpMethod != util.OPTIONS {
@@ -470,9 +714,87 @@ func (s *ServiceInfo) processHttpRule() error {
 		hcMethod.IsGenerated = true
 	}
 
+	// Add HttpRules for paths that are routed to the backend without service control.
+	if s.Options.SkipServiceControlPaths != "" {
+		methodName := fmt.Sprintf("%s.%s_SkipServiceControl", util.EspOperation, util.AutogeneratedOperationPrefix)
+
+		skipMethod, err := s.getOrCreateMethod(methodName)
+		if err != nil {
+			return fmt.Errorf("error creating auto-generated http rule for operation (%v): %v", methodName, err)
+		}
+
+		for _, path := range strings.Split(s.Options.SkipServiceControlPaths, ",") {
+			path = strings.TrimSpace(path)
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("invalid path %q to skip service control, it should start with /", path)
+			}
+			uriTemplate, err := httppattern.ParseUriTemplate(path)
+			if err != nil {
+				return fmt.Errorf("error parsing path %q to skip service control: %v", path, err)
+			}
+			skipMethod.HttpRule = append(skipMethod.HttpRule, &httppattern.Pattern{
+				UriTemplate: uriTemplate,
+				HttpMethod:  httppattern.HttpMethodWildCard,
+			})
+		}
+		skipMethod.SkipServiceControl = true
+		skipMethod.IsGenerated = true
+	}
+
+	// Add HttpRules for paths that are served by Envoy without the backend.
+	if s.Options.StaticResponses != "" {
+		for i, staticResponse := range strings.Split(s.Options.StaticResponses, ",") {
+			path, file := strings.TrimSpace(staticResponse), ""
+			if j := strings.Index(path, "="); j >= 0 {
+				path, file = strings.TrimSpace(path[:j]), strings.TrimSpace(path[j+1:])
+			}
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("invalid path %q of static response, it should start with /", path)
+			}
+			uriTemplate, err := httppattern.ParseUriTemplate(path)
+			if err != nil {
+				return fmt.Errorf("error parsing path %q of static response: %v", path, err)
+			}
+
+			methodName := fmt.Sprintf("%s.%s_StaticResponse_%d", util.EspOperation, util.AutogeneratedOperationPrefix, i)
+			staticMethod, err := s.getOrCreateMethod(methodName)
+			if err != nil {
+				return fmt.Errorf("error creating auto-generated http rule for operation (%v): %v", methodName, err)
+			}
+			if staticMethod.StaticResponse, err = makeStaticResponse(file); err != nil {
+				return fmt.Errorf("error making static response of path %q: %v", path, err)
+			}
+			staticMethod.HttpRule = append(staticMethod.HttpRule, &httppattern.Pattern{
+				UriTemplate: uriTemplate,
+				HttpMethod:  httppattern.HttpMethodWildCard,
+			})
+			staticMethod.SkipServiceControl = true
+			staticMethod.IsGenerated = true
+		}
+	}
+
 	return nil
 }
 
+// makeStaticResponse makes a 200 response with the content of the file, or an
+// empty 204 response without a file.
+func makeStaticResponse(file string) (*staticResponse, error) {
+	if file == "" {
+		return &staticResponse{
+			Status: http.StatusNoContent,
+		}, nil
+	}
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return &staticResponse{
+		Status:      http.StatusOK,
+		Body:        body,
+		ContentType: http.DetectContentType(body),
+	}, nil
+}
+
 func (s *ServiceInfo) addOptionMethod(originalMethod *MethodInfo, httpRule *httppattern.Pattern) error {
 	if httpRule.HttpMethod != util.OPTIONS {
 		return fmt.Errorf("find `%s %s` when adding OPTIONS method for operation(%s)", httpRule.HttpMethod, httpRule.Origin, originalMethod.Operation())
@@ -511,7 +833,7 @@ func (s *ServiceInfo) processBackendRule() error {
 			if err != nil {
 				return fmt.Errorf("error parsing remote backend rule's address for operation (%v), %v", r.Selector, err)
 			}
-			address := fmt.Sprintf("%v:%v", hostname, port)
+			address := util.JoinHostPort(hostname, port)
 
 			if _, exist := backendRoutingClustersMap[address]; !exist {
 				// Create cluster for the remote backend.
@@ -545,6 +867,106 @@ func (s *ServiceInfo) processBackendRule() error {
 	return nil
 }
 
+// processJwtClaimRoutes parses the JWT claim routes and creates clusters for
+// their backends.
+func (s *ServiceInfo) processJwtClaimRoutes() error {
+	if s.Options.JwtClaimBackendRoutes == "" {
+		return nil
+	}
+	// The claim headers are only set, and sanitized, by the service control
+	// filter. Without it, clients could pick the backend by sending them.
+	if s.Options.SkipServiceControlFilter || s.ServiceConfig().GetControl().GetEnvironment() == "" {
+		return fmt.Errorf("JWT claim routes require the service control filter")
+	}
+	// The claim headers are set from the JWT payload, which is not verified
+	// yet when the service control filter runs first.
+	if s.Options.CheckApiKeyBeforeJwt {
+		return fmt.Errorf("JWT claim routes cannot be used with --check_api_key_before_jwt")
+	}
+
+	for _, route := range strings.Split(s.Options.JwtClaimBackendRoutes, ",") {
+		route = strings.TrimSpace(route)
+		kv := strings.SplitN(route, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid JWT claim route %q, it should be in the format claim:value=backend_address", route)
+		}
+		claimValue := strings.SplitN(kv[0], ":", 2)
+		if len(claimValue) != 2 || !jwtClaimNameRegex.MatchString(claimValue[0]) || claimValue[1] == "" {
+			return fmt.Errorf("invalid JWT claim route %q, it should be in the format claim:value=backend_address", route)
+		}
+
+		scheme, hostname, port, _, err := util.ParseURI(kv[1])
+		if err != nil {
+			return fmt.Errorf("error parsing backend address of JWT claim route %q: %v", route, err)
+		}
+		protocol, tls, err := util.ParseBackendProtocol(scheme, "")
+		if err != nil {
+			return fmt.Errorf("error parsing backend protocol of JWT claim route %q: %v", route, err)
+		}
+
+		clusterName := s.addRemoteBackendCluster(hostname, port, protocol, tls)
+		s.JwtClaimRoutes = append(s.JwtClaimRoutes, &JwtClaimRoute{
+			Claim:       claimValue[0],
+			Value:       claimValue[1],
+			ClusterName: clusterName,
+			Hostname:    hostname,
+		})
+	}
+	return nil
+}
+
+// processMirrorBackend parses the mirror backend address and creates a cluster
+// for it.
+func (s *ServiceInfo) processMirrorBackend() error {
+	if s.Options.MirrorBackendAddress == "" {
+		return nil
+	}
+	if s.Options.MirrorPercent <= 0 || s.Options.MirrorPercent > 100 {
+		return fmt.Errorf("flag --mirror_percent must be in (0, 100], got %v", s.Options.MirrorPercent)
+	}
+
+	scheme, hostname, port, _, err := util.ParseURI(s.Options.MirrorBackendAddress)
+	if err != nil {
+		return fmt.Errorf("error parsing mirror backend address: %v", err)
+	}
+	protocol, tls, err := util.ParseBackendProtocol(scheme, "")
+	if err != nil {
+		return fmt.Errorf("error parsing mirror backend protocol: %v", err)
+	}
+
+	s.MirrorBackendClusterName = s.addRemoteBackendCluster(hostname, port, protocol, tls)
+	return nil
+}
+
+// addRemoteBackendCluster adds a cluster for the remote backend, unless one
+// already exists, and returns its name.
+func (s *ServiceInfo) addRemoteBackendCluster(hostname string, port uint32, protocol util.BackendProtocol, tls bool) string {
+	clusterName := util.BackendClusterName(util.JoinHostPort(hostname, port))
+	for _, cluster := range s.RemoteBackendClusters {
+		if cluster.ClusterName == clusterName {
+			return clusterName
+		}
+	}
+
+	if protocol == util.GRPC {
+		s.GrpcSupportRequired = true
+	}
+	s.RemoteBackendClusters = append(s.RemoteBackendClusters,
+		&BackendRoutingCluster{
+			ClusterName: clusterName,
+			UseTLS:      tls,
+			Protocol:    protocol,
+			Hostname:    hostname,
+			Port:        port,
+		})
+	return clusterName
+}
+
+// JwtClaimHeader returns the request header carrying the given JWT claim.
+func (s *ServiceInfo) JwtClaimHeader(claim string) string {
+	return s.Options.GeneratedHeaderPrefix + util.JwtClaimHeaderInfix + claim
+}
+
 func (s *ServiceInfo) addBackendInfoToMethod(r *confpb.BackendRule, scheme string, hostname string, path string, backendClusterName string) error {
 	method, err := s.getMethod(r.GetSelector())
 	if err != nil {
@@ -664,6 +1086,512 @@ func (s *ServiceInfo) processAllBackends() error {
 	return nil
 }
 
+// processBackendAuthStaticTokenFiles associates methods with the static token
+// files they send to the backend. Static tokens replace the backend JWT.
+func (s *ServiceInfo) processBackendAuthStaticTokenFiles() error {
+	if s.Options.BackendAuthStaticTokenFiles == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(s.Options.BackendAuthStaticTokenFiles, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid backend auth static token file %q, it should be in the format selector=path", pair)
+		}
+		method, err := s.getMethod(kv[0])
+		if err != nil {
+			return fmt.Errorf("error processing backend auth static token file for operation (%v): %v", kv[0], err)
+		}
+		method.BackendInfo.StaticTokenFile = kv[1]
+		method.BackendInfo.JwtAudience = ""
+	}
+	return nil
+}
+
+// processBackendAuthTokenHeaders associates methods with the request header
+// their backend auth token is sent in.
+func (s *ServiceInfo) processBackendAuthTokenHeaders() error {
+	if s.Options.BackendAuthTokenHeaders == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(s.Options.BackendAuthTokenHeaders, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || !headerNameRegex.MatchString(kv[1]) {
+			return fmt.Errorf("invalid backend auth token header %q, it should be in the format selector=header", pair)
+		}
+		method, err := s.getMethod(kv[0])
+		if err != nil {
+			return fmt.Errorf("error processing backend auth token header for operation (%v): %v", kv[0], err)
+		}
+		if method.BackendInfo == nil || (method.BackendInfo.JwtAudience == "" && method.BackendInfo.StaticTokenFile == "") {
+			return fmt.Errorf("error processing backend auth token header for operation (%v): backend auth is not enabled for the operation", kv[0])
+		}
+		method.BackendInfo.TokenHeader = kv[1]
+	}
+	return nil
+}
+
+// processCorsDisabledOperations returns the set of operations that get no
+// auto-generated CORS preflight routes.
+func (s *ServiceInfo) processCorsDisabledOperations() (map[string]bool, error) {
+	disabled := make(map[string]bool)
+	if s.Options.CorsDisabledOperations == "" {
+		return disabled, nil
+	}
+	for _, selector := range strings.Split(s.Options.CorsDisabledOperations, ",") {
+		selector = strings.TrimSpace(selector)
+		method, err := s.getMethod(selector)
+		if err != nil {
+			return nil, fmt.Errorf("error processing cors disabled operation (%v): %v", selector, err)
+		}
+		method.CorsDisabled = true
+		disabled[selector] = true
+	}
+	return disabled, nil
+}
+
+// processBackendHostRewrites associates methods with the Host header they send
+// to the backend.
+func (s *ServiceInfo) processBackendHostRewrites() error {
+	// The Host header is only rewritten on the request to the backend, service
+	// control and routing still see the Host header sent by the client.
+	if s.Options.BackendHostRewrite != "" {
+		for _, method := range s.Methods {
+			method.BackendInfo.HostRewrite = s.Options.BackendHostRewrite
+		}
+	}
+
+	if s.Options.BackendHostRewriteByOperation == "" {
+		return nil
+	}
+	kvs, err := util.ParseKeyValuePairs(s.Options.BackendHostRewriteByOperation, "backend host rewrite", "selector=host")
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		method, err := s.getMethod(kv.Key)
+		if err != nil {
+			return fmt.Errorf("error processing backend host rewrite for operation (%v): %v", kv.Key, err)
+		}
+		method.BackendInfo.HostRewrite = kv.Value
+	}
+	return nil
+}
+
+// processBackendStripPrefixes associates methods with the path prefix removed
+// from their requests before they are sent to the backend.
+func (s *ServiceInfo) processBackendStripPrefixes() error {
+	if s.Options.BackendStripPrefixByOperation == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(s.Options.BackendStripPrefixByOperation, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid backend strip prefix %q, it should be in the format selector=prefix", pair)
+		}
+		method, err := s.getMethod(kv[0])
+		if err != nil {
+			return fmt.Errorf("error processing backend strip prefix for operation (%v): %v", kv[0], err)
+		}
+		if !strings.HasPrefix(kv[1], "/") || kv[1] == "/" || strings.ContainsAny(kv[1], "?&#") {
+			return fmt.Errorf("error processing backend strip prefix for operation (%v): invalid prefix %q, it should start with / and not contain a query or fragment", kv[0], kv[1])
+		}
+		if method.BackendInfo.TranslationType == confpb.BackendRule_CONSTANT_ADDRESS {
+			return fmt.Errorf("error processing backend strip prefix for operation (%v): it is not supported with CONSTANT_ADDRESS path translation", kv[0])
+		}
+		method.BackendInfo.StripPrefix = kv[1]
+	}
+	return nil
+}
+
+// processBackendRegexRewrites associates methods with the regex rewrite of
+// the path sent to their backend.
+func (s *ServiceInfo) processBackendRegexRewrites() error {
+	if s.Options.BackendRegexRewriteByOperation == "" {
+		return nil
+	}
+	for _, rewrite := range splitRegexRewrite(s.Options.BackendRegexRewriteByOperation, ',', -1) {
+		parts := splitRegexRewrite(strings.TrimSpace(rewrite), '=', 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return fmt.Errorf("invalid backend regex rewrite %q, it should be in the format selector=pattern=substitution", rewrite)
+		}
+		method, err := s.getMethod(parts[0])
+		if err != nil {
+			return fmt.Errorf("error processing backend regex rewrite for operation (%v): %v", parts[0], err)
+		}
+		if _, err := regexp.Compile(parts[1]); err != nil {
+			return fmt.Errorf("error processing backend regex rewrite for operation (%v): invalid pattern %q: %v", parts[0], parts[1], err)
+		}
+		if method.BackendInfo.TranslationType == confpb.BackendRule_CONSTANT_ADDRESS {
+			return fmt.Errorf("error processing backend regex rewrite for operation (%v): it is not supported with CONSTANT_ADDRESS path translation", parts[0])
+		}
+		if method.BackendInfo.StripPrefix != "" {
+			return fmt.Errorf("error processing backend regex rewrite for operation (%v): it cannot be used with a backend strip prefix", parts[0])
+		}
+		method.BackendInfo.RegexRewritePattern = parts[1]
+		method.BackendInfo.RegexRewriteSubstitution = parts[2]
+	}
+	return nil
+}
+
+// splitRegexRewrite splits s on sep into at most n parts, or all parts if n is
+// negative, like strings.SplitN. A sep escaped by a backslash, or inside the
+// braces of a repetition like `{1,3}`, does not split, so the patterns may
+// contain them. The escapes are kept, RE2 matches `\,` and `\=` literally.
+func splitRegexRewrite(s string, sep byte, n int) []string {
+	var parts []string
+	start, braces := 0, 0
+	for i := 0; i < len(s) && n != len(parts)+1; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			braces++
+		case '}':
+			if braces > 0 {
+				braces--
+			}
+		case sep:
+			if braces == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// processBackendConnectTimeouts sets the connect timeouts of the backend
+// clusters from the per-operation overrides.
+func (s *ServiceInfo) processBackendConnectTimeouts() error {
+	if s.Options.BackendConnectTimeout < 0 {
+		return fmt.Errorf("flag --backend_connect_timeout must not be negative, got %v", s.Options.BackendConnectTimeout)
+	}
+	if s.Options.BackendConnectTimeoutByOperation == "" {
+		return nil
+	}
+
+	clusters := map[string]*BackendRoutingCluster{
+		s.LocalBackendCluster.ClusterName: s.LocalBackendCluster,
+	}
+	for _, cluster := range s.RemoteBackendClusters {
+		clusters[cluster.ClusterName] = cluster
+	}
+
+	for _, pair := range strings.Split(s.Options.BackendConnectTimeoutByOperation, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid backend connect timeout %q, it should be in the format selector=duration", pair)
+		}
+		method, err := s.getMethod(kv[0])
+		if err != nil {
+			return fmt.Errorf("error processing backend connect timeout for operation (%v): %v", kv[0], err)
+		}
+		timeout, err := time.ParseDuration(kv[1])
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("error processing backend connect timeout for operation (%v): invalid duration %q", kv[0], kv[1])
+		}
+
+		cluster, ok := clusters[method.BackendInfo.ClusterName]
+		if !ok {
+			return fmt.Errorf("error processing backend connect timeout for operation (%v): backend cluster (%v) is not found", kv[0], method.BackendInfo.ClusterName)
+		}
+		if cluster.ConnectTimeout != 0 && cluster.ConnectTimeout != timeout {
+			return fmt.Errorf("error processing backend connect timeout for operation (%v): backend cluster (%v) already has a different connect timeout %v", kv[0], cluster.ClusterName, cluster.ConnectTimeout)
+		}
+		cluster.ConnectTimeout = timeout
+	}
+	return nil
+}
+
+// processBackendSnis sets the TLS SNIs of the backend clusters from the
+// per-operation overrides.
+func (s *ServiceInfo) processBackendSnis() error {
+	if s.Options.BackendSniByOperation == "" {
+		return nil
+	}
+
+	clusters := map[string]*BackendRoutingCluster{
+		s.LocalBackendCluster.ClusterName: s.LocalBackendCluster,
+	}
+	for _, cluster := range s.RemoteBackendClusters {
+		clusters[cluster.ClusterName] = cluster
+	}
+
+	for _, pair := range strings.Split(s.Options.BackendSniByOperation, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid backend SNI %q, it should be in the format selector=sni", pair)
+		}
+		method, err := s.getMethod(kv[0])
+		if err != nil {
+			return fmt.Errorf("error processing backend SNI for operation (%v): %v", kv[0], err)
+		}
+
+		cluster, ok := clusters[method.BackendInfo.ClusterName]
+		if !ok {
+			return fmt.Errorf("error processing backend SNI for operation (%v): backend cluster (%v) is not found", kv[0], method.BackendInfo.ClusterName)
+		}
+		if !cluster.UseTLS {
+			return fmt.Errorf("error processing backend SNI for operation (%v): backend cluster (%v) does not use TLS", kv[0], cluster.ClusterName)
+		}
+		if cluster.Sni != "" && cluster.Sni != kv[1] {
+			return fmt.Errorf("error processing backend SNI for operation (%v): backend cluster (%v) already has a different SNI %v", kv[0], cluster.ClusterName, cluster.Sni)
+		}
+		cluster.Sni = kv[1]
+	}
+	return nil
+}
+
+// processBackendAlpns sets the ALPN protocols of the backend clusters from
+// the per-operation overrides, and switches their protocol to match.
+func (s *ServiceInfo) processBackendAlpns() error {
+	if s.Options.BackendAlpnByOperation == "" {
+		return nil
+	}
+
+	clusters := map[string]*BackendRoutingCluster{
+		s.LocalBackendCluster.ClusterName: s.LocalBackendCluster,
+	}
+	for _, cluster := range s.RemoteBackendClusters {
+		clusters[cluster.ClusterName] = cluster
+	}
+
+	for _, pair := range strings.Split(s.Options.BackendAlpnByOperation, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid backend ALPN %q, it should be in the format selector=alpn", pair)
+		}
+		method, err := s.getMethod(kv[0])
+		if err != nil {
+			return fmt.Errorf("error processing backend ALPN for operation (%v): %v", kv[0], err)
+		}
+
+		cluster, ok := clusters[method.BackendInfo.ClusterName]
+		if !ok {
+			return fmt.Errorf("error processing backend ALPN for operation (%v): backend cluster (%v) is not found", kv[0], method.BackendInfo.ClusterName)
+		}
+		if !cluster.UseTLS {
+			return fmt.Errorf("error processing backend ALPN for operation (%v): backend cluster (%v) does not use TLS", kv[0], cluster.ClusterName)
+		}
+		if cluster.Alpn != "" && cluster.Alpn != kv[1] {
+			return fmt.Errorf("error processing backend ALPN for operation (%v): backend cluster (%v) already has a different ALPN %v", kv[0], cluster.ClusterName, cluster.Alpn)
+		}
+
+		switch kv[1] {
+		case "h2":
+			if cluster.Protocol != util.GRPC {
+				cluster.Protocol = util.HTTP2
+			}
+		case "http/1.1":
+			if cluster.Protocol == util.GRPC {
+				return fmt.Errorf("error processing backend ALPN for operation (%v): gRPC backend cluster (%v) only supports h2", kv[0], cluster.ClusterName)
+			}
+			cluster.Protocol = util.HTTP1
+		case util.AutoAlpn:
+			if cluster.Protocol == util.GRPC {
+				return fmt.Errorf("error processing backend ALPN for operation (%v): gRPC backend cluster (%v) only supports h2", kv[0], cluster.ClusterName)
+			}
+			// The protocol is negotiated per connection, the cluster is
+			// configured as HTTP/1.1 otherwise, e.g. for health checks.
+			cluster.Protocol = util.HTTP1
+		default:
+			return fmt.Errorf(`error processing backend ALPN for operation (%v): unknown ALPN %q, should be one of "h2", "http/1.1" or "auto"`, kv[0], kv[1])
+		}
+		cluster.Alpn = kv[1]
+	}
+	return nil
+}
+
+// processBackendFallbackAddresses sets the fallback backends of the backend
+// clusters from the per-operation overrides.
+func (s *ServiceInfo) processBackendFallbackAddresses() error {
+	if s.Options.BackendFallbackAddressByOperation == "" {
+		return nil
+	}
+
+	clusters := map[string]*BackendRoutingCluster{
+		s.LocalBackendCluster.ClusterName: s.LocalBackendCluster,
+	}
+	for _, cluster := range s.RemoteBackendClusters {
+		clusters[cluster.ClusterName] = cluster
+	}
+
+	kvs, err := util.ParseKeyValuePairs(s.Options.BackendFallbackAddressByOperation, "backend fallback address", "selector=address")
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		method, err := s.getMethod(kv.Key)
+		if err != nil {
+			return fmt.Errorf("error processing backend fallback address for operation (%v): %v", kv.Key, err)
+		}
+		scheme, hostname, port, _, err := util.ParseURI(kv.Value)
+		if err != nil {
+			return fmt.Errorf("error processing backend fallback address for operation (%v): %v", kv.Key, err)
+		}
+		protocol, tls, err := util.ParseBackendProtocol(scheme, "")
+		if err != nil {
+			return fmt.Errorf("error processing backend fallback address for operation (%v): %v", kv.Key, err)
+		}
+
+		cluster, ok := clusters[method.BackendInfo.ClusterName]
+		if !ok {
+			return fmt.Errorf("error processing backend fallback address for operation (%v): backend cluster (%v) is not found", kv.Key, method.BackendInfo.ClusterName)
+		}
+		// The fallback backend shares the cluster, and so its protocol and TLS
+		// settings, with the backend.
+		if tls != cluster.UseTLS || (protocol == util.GRPC) != (cluster.Protocol == util.GRPC) {
+			return fmt.Errorf("error processing backend fallback address for operation (%v): %q must use the same scheme as backend cluster (%v)", kv.Key, kv.Value, cluster.ClusterName)
+		}
+		if cluster.FallbackHostname != "" && (cluster.FallbackHostname != hostname || cluster.FallbackPort != port) {
+			return fmt.Errorf("error processing backend fallback address for operation (%v): backend cluster (%v) already has a different fallback address %v", kv.Key, cluster.ClusterName, util.JoinHostPort(cluster.FallbackHostname, cluster.FallbackPort))
+		}
+		cluster.FallbackHostname = hostname
+		cluster.FallbackPort = port
+	}
+	return nil
+}
+
+// processDisableChunkedEncodingBackends marks the methods whose requests are
+// buffered, so that they are sent to their backend with a Content-Length.
+func (s *ServiceInfo) processDisableChunkedEncodingBackends() error {
+	if s.Options.DisableChunkedEncodingBackends == "" {
+		return nil
+	}
+
+	clusters := append([]*BackendRoutingCluster{s.LocalBackendCluster}, s.RemoteBackendClusters...)
+	bufferedClusterNames := make(map[string]bool)
+	for _, address := range strings.Split(s.Options.DisableChunkedEncodingBackends, ",") {
+		address = strings.TrimSpace(address)
+		_, hostname, port, _, err := util.ParseURI(address)
+		if err != nil {
+			return fmt.Errorf("error parsing backend address %q of --disable_chunked_encoding_backends: %v", address, err)
+		}
+
+		found := false
+		for _, cluster := range clusters {
+			if cluster.Hostname == hostname && cluster.Port == port {
+				bufferedClusterNames[cluster.ClusterName] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("backend address %q of --disable_chunked_encoding_backends is not --backend_address or a backend address in the service config", address)
+		}
+	}
+
+	for _, method := range s.Methods {
+		if bufferedClusterNames[method.BackendInfo.ClusterName] {
+			method.BackendInfo.BufferRequests = true
+		}
+	}
+	return nil
+}
+
+// processStreamingPassthroughOperations marks the operations whose requests
+// are streamed to the backend. They are never buffered, which overrides
+// --disable_chunked_encoding_backends for them.
+func (s *ServiceInfo) processStreamingPassthroughOperations() error {
+	if s.Options.StreamingPassthroughOperations == "" {
+		return nil
+	}
+	for _, selector := range strings.Split(s.Options.StreamingPassthroughOperations, ",") {
+		selector = strings.TrimSpace(selector)
+		method, err := s.getMethod(selector)
+		if err != nil {
+			return fmt.Errorf("error processing streaming passthrough operation (%v): %v", selector, err)
+		}
+		method.StreamingPassthrough = true
+		method.BackendInfo.BufferRequests = false
+	}
+	return nil
+}
+
+// processStreamingResponseOperations marks the operations whose responses,
+// e.g. Server-Sent Events, are flushed to the client as they arrive.
+func (s *ServiceInfo) processStreamingResponseOperations() error {
+	if s.Options.StreamingResponseOperations == "" {
+		return nil
+	}
+	for _, selector := range strings.Split(s.Options.StreamingResponseOperations, ",") {
+		selector = strings.TrimSpace(selector)
+		method, err := s.getMethod(selector)
+		if err != nil {
+			return fmt.Errorf("error processing streaming response operation (%v): %v", selector, err)
+		}
+		method.StreamingResponses = true
+	}
+	return nil
+}
+
+// processMaxRequestBytes associates methods with the limit of their request
+// body size. It is enforced by buffering the requests.
+func (s *ServiceInfo) processMaxRequestBytes() error {
+	if s.Options.MaxRequestBytesByOperation == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(s.Options.MaxRequestBytesByOperation, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid max request bytes %q, it should be in the format selector=bytes", pair)
+		}
+		method, err := s.getMethod(kv[0])
+		if err != nil {
+			return fmt.Errorf("error processing max request bytes for operation (%v): %v", kv[0], err)
+		}
+		maxBytes, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil || maxBytes == 0 {
+			return fmt.Errorf("error processing max request bytes for operation (%v): %q is not a positive 32-bit integer", kv[0], kv[1])
+		}
+		if method.StreamingPassthrough {
+			return fmt.Errorf("error processing max request bytes for operation (%v): streaming passthrough operations are not buffered", kv[0])
+		}
+		method.BackendInfo.MaxRequestBytes = uint32(maxBytes)
+	}
+	return nil
+}
+
+// processQueryParameterAllowlists associates methods with the query parameters
+// allowed in their requests. The query parameters ignored by transcoding, e.g.
+// the API key and JWT ones, are always allowed.
+func (s *ServiceInfo) processQueryParameterAllowlists() error {
+	if s.Options.QueryParameterAllowlistByOperation == "" {
+		return nil
+	}
+
+	var alwaysAllowed []string
+	for param := range s.AllTranscodingIgnoredQueryParams {
+		alwaysAllowed = append(alwaysAllowed, param)
+	}
+	sort.Strings(alwaysAllowed)
+
+	kvs, err := util.ParseKeyValuePairs(s.Options.QueryParameterAllowlistByOperation, "query parameter allowlist", "selector=param1|param2")
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		method, err := s.getMethod(kv.Key)
+		if err != nil {
+			return fmt.Errorf("error processing query parameter allowlist for operation (%v): %v", kv.Key, err)
+		}
+
+		seen := map[string]bool{}
+		var params []string
+		for _, param := range append(strings.Split(kv.Value, "|"), alwaysAllowed...) {
+			param = strings.TrimSpace(param)
+			if param == "" {
+				return fmt.Errorf("error processing query parameter allowlist for operation (%v): empty query parameter in %q", kv.Key, kv.Value)
+			}
+			if !seen[param] {
+				seen[param] = true
+				params = append(params, param)
+			}
+		}
+		method.AllowedQueryParameters = params
+	}
+	return nil
+}
+
 func (s *ServiceInfo) processLocalBackendOperations() error {
 
 	// For methods that are not associated with any backend rules, create one
@@ -890,8 +1818,30 @@ func (s *ServiceInfo) LocalBackendClusterName() string {
 	return util.BackendClusterName(fmt.Sprintf("%s_local", s.Name))
 }
 
+func (s *ServiceInfo) processRequiredRequestHeaders() error {
+	if s.Options.RequireRequestHeaders == "" {
+		return nil
+	}
+	for _, header := range strings.Split(s.Options.RequireRequestHeaders, ",") {
+		header = strings.TrimSpace(header)
+		if !headerNameRegex.MatchString(header) {
+			return fmt.Errorf("invalid header %q in --require_request_headers", header)
+		}
+		s.RequiredRequestHeaders = append(s.RequiredRequestHeaders, header)
+	}
+	return nil
+}
+
 func (s *ServiceInfo) processAuthRequirement() error {
 	auth := s.serviceConfig.GetAuthentication()
+	providerIds := make(map[string]bool)
+	for _, provider := range auth.GetProviders() {
+		providerIds[provider.GetId()] = true
+	}
+
+	// Report every undefined provider at once, instead of the first one failing
+	// deep in the JWT Authn filter config.
+	var undefinedProviders []string
 	for _, rule := range auth.GetRules() {
 		if len(rule.GetRequirements()) > 0 {
 			mi, err := s.getMethod(rule.GetSelector())
@@ -900,6 +1850,14 @@ func (s *ServiceInfo) processAuthRequirement() error {
 			}
 			mi.RequireAuth = true
 		}
+		for _, requirement := range rule.GetRequirements() {
+			if !providerIds[requirement.GetProviderId()] {
+				undefinedProviders = append(undefinedProviders, fmt.Sprintf("operation (%v) requires provider (%v)", rule.GetSelector(), requirement.GetProviderId()))
+			}
+		}
+	}
+	if len(undefinedProviders) > 0 {
+		return fmt.Errorf("error processing authentication rules, providers are not defined in Authentication.providers: %v", strings.Join(undefinedProviders, "; "))
 	}
 	return nil
 }
@@ -17,8 +17,10 @@ package configinfo
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -2374,6 +2376,16 @@ func TestProcessBackendRuleForClusterName(t *testing.T) {
 			Address:     "grpcs://127.0.0.1:8080/api/",
 			ClusterName: "backend-cluster-127.0.0.1:8080",
 		},
+		{
+			desc:        "IPv6 with default https port",
+			Address:     "https://[::1]/api/",
+			ClusterName: "backend-cluster-[::1]:443",
+		},
+		{
+			desc:        "IPv6 with custom grpc port",
+			Address:     "grpc://[2001:db8::1]:8080/api/",
+			ClusterName: "backend-cluster-[2001:db8::1]:8080",
+		},
 	}
 
 	for _, tc := range testData {
@@ -2707,274 +2719,1990 @@ func TestProcessBackendRuleForJwtAudience(t *testing.T) {
 	}
 }
 
-func TestProcessBackendRuleForRetry(t *testing.T) {
-	testData := []struct {
-		desc                          string
-		fakeServiceConfig             *confpb.Service
-		backendRetryOns               string
-		backendRetryNum               uint
-		backendPerTryTimeout          time.Duration
-		backendRetryOnStatusCode      string
-		wantBackendRetryOns           string
-		wantBackendRetryNum           uint
-		wantBackendPerTryTimeout      time.Duration
-		wantBackendRetryOnStatusCodes []uint32
-		wantError                     string
-	}{
-		{
-			desc: "pass backend retry parameters",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
+func TestProcessBackendAuthStaticTokenFiles(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
 					{
-						Name: "abc.com",
-						Methods: []*apipb.Method{
-							{
-								Name: "api",
-							},
-						},
+						Name: "foo",
 					},
-				},
-			},
-			backendRetryOns:          "foo,bar",
-			backendRetryNum:          5,
-			backendPerTryTimeout:     time.Second * 60,
-			wantBackendRetryOns:      "foo,bar",
-			wantBackendRetryNum:      5,
-			wantBackendPerTryTimeout: time.Second * 60,
-		},
-		{
-			desc: "invalid retriable status code in wrong format",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
 					{
-						Name: "abc.com",
-						Methods: []*apipb.Method{
-							{
-								Name: "api",
-							},
-						},
+						Name: "bar",
 					},
 				},
 			},
-			backendRetryOns:          "",
-			backendRetryOnStatusCode: "invalid-status-code",
-			wantError:                "invalid http status code",
 		},
-		{
-			desc: "invalid retriable status code in wrong range",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
-					{
-						Name: "abc.com",
-						Methods: []*apipb.Method{
-							{
-								Name: "api",
-							},
-						},
-					},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:        "https://abc.com/api",
+					Selector:       "abc.com.foo",
+					Authentication: &confpb.BackendRule_JwtAudience{JwtAudience: "audience-foo"},
+				},
+				{
+					Address:        "https://abc.com/api",
+					Selector:       "abc.com.bar",
+					Authentication: &confpb.BackendRule_JwtAudience{JwtAudience: "audience-bar"},
 				},
 			},
-			backendRetryOns:          "",
-			backendRetryOnStatusCode: "600",
-			wantError:                "invalid http status code",
 		},
+	}
+
+	testData := []struct {
+		desc                string
+		staticTokenFiles    string
+		wantJwtAudience     map[string]string
+		wantStaticTokenFile map[string]string
+		wantError           string
+	}{
 		{
-			desc: "set RetryOnStatusCodes and add `retriable-status-codes` to retryOns if it is empty",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
-					{
-						Name: "abc.com",
-						Methods: []*apipb.Method{
-							{
-								Name: "api",
-							},
-						},
-					},
-				},
+			desc:             "Static token file replaces the jwt audience",
+			staticTokenFiles: "abc.com.bar=/etc/token",
+			wantJwtAudience: map[string]string{
+				"abc.com.foo": "audience-foo",
+				"abc.com.bar": "",
+			},
+			wantStaticTokenFile: map[string]string{
+				"abc.com.foo": "",
+				"abc.com.bar": "/etc/token",
 			},
-			backendRetryOns:               "",
-			backendRetryOnStatusCode:      "500,501",
-			wantBackendRetryOns:           "retriable-status-codes",
-			wantBackendRetryOnStatusCodes: []uint32{500, 501},
 		},
 		{
-			desc: "set RetryOnStatusCodes and add `retriable-status-codes` to retryOns if it is un-empty but doesn't have `retriable-status-codes`",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
-					{
-						Name: "abc.com",
-						Methods: []*apipb.Method{
-							{
-								Name: "api",
-							},
-						},
-					},
-				},
-			},
-			backendRetryOns:               "foo,bar",
-			backendRetryOnStatusCode:      "500,501",
-			wantBackendRetryOns:           "foo,bar,retriable-status-codes",
-			wantBackendRetryOnStatusCodes: []uint32{500, 501},
+			desc:             "Invalid format",
+			staticTokenFiles: "abc.com.bar",
+			wantError:        `invalid backend auth static token file "abc.com.bar", it should be in the format selector=path`,
 		},
 		{
-			desc: "set RetryOnStatusCodes and no op on retryOns when it contains `retriable-status-codes`",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
-					{
-						Name: "abc.com",
-						Methods: []*apipb.Method{
-							{
-								Name: "api",
-							},
-						},
-					},
-				},
-			},
-			backendRetryOns:               "foo,bar,retriable-status-codes",
-			backendRetryOnStatusCode:      "500,501",
-			wantBackendRetryOns:           "foo,bar,retriable-status-codes",
-			wantBackendRetryOnStatusCodes: []uint32{500, 501},
+			desc:             "Unknown selector",
+			staticTokenFiles: "abc.com.baz=/etc/token",
+			wantError:        "error processing backend auth static token file for operation (abc.com.baz): selector (abc.com.baz) was not defined in the API",
 		},
 	}
 
 	for _, tc := range testData {
 		t.Run(tc.desc, func(t *testing.T) {
 			opts := options.DefaultConfigGeneratorOptions()
-			opts.BackendRetryOns = tc.backendRetryOns
-			opts.BackendRetryNum = tc.backendRetryNum
-			opts.BackendPerTryTimeout = tc.backendPerTryTimeout
-			opts.BackendRetryOnStatusCodes = tc.backendRetryOnStatusCode
-			s, err := NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
+			opts.BackendAuthStaticTokenFiles = tc.staticTokenFiles
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
 			if tc.wantError != "" {
-				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
-					t.Fatalf("different error, want: %s, get: %v", tc.wantError, err)
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
 				}
 				return
 			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
 
-			for _, method := range s.Methods {
-				backendInfo := method.BackendInfo
-				if backendInfo.RetryOns != tc.wantBackendRetryOns {
-					t.Errorf("different RetryOns, want: %v, get: %v", tc.wantBackendRetryOns, backendInfo.RetryOns)
-				}
-				if backendInfo.RetryNum != tc.wantBackendRetryNum {
-					t.Errorf("different RetryNum, want: %v, get: %v", tc.wantBackendRetryNum, backendInfo.RetryNum)
-				}
-				if backendInfo.PerTryTimeout != tc.wantBackendPerTryTimeout {
-					t.Errorf("different PerTryTimeout, want: %v, get: %v", tc.wantBackendPerTryTimeout, backendInfo.PerTryTimeout)
+			for selector, want := range tc.wantJwtAudience {
+				if got := s.Methods[selector].BackendInfo.JwtAudience; got != want {
+					t.Errorf("JwtAudience mismatch for %v, got: %v, want: %v", selector, got, want)
 				}
-				if !reflect.DeepEqual(backendInfo.RetriableStatusCodes, tc.wantBackendRetryOnStatusCodes) {
-					t.Errorf("different RetriableStatusCodes, want: %v, get: %v", tc.wantBackendRetryOnStatusCodes, backendInfo.RetriableStatusCodes)
+			}
+			for selector, want := range tc.wantStaticTokenFile {
+				if got := s.Methods[selector].BackendInfo.StaticTokenFile; got != want {
+					t.Errorf("StaticTokenFile mismatch for %v, got: %v, want: %v", selector, got, want)
 				}
 			}
 		})
 	}
 }
-func TestBackendAddressOverride(t *testing.T) {
+
+func TestProcessBackendAuthTokenHeaders(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:        "https://abc.com/api",
+					Selector:       "abc.com.foo",
+					Authentication: &confpb.BackendRule_JwtAudience{JwtAudience: "audience-foo"},
+				},
+				{
+					Address:        "https://abc.com/api",
+					Selector:       "abc.com.bar",
+					Authentication: &confpb.BackendRule_DisableAuth{DisableAuth: true},
+				},
+			},
+		},
+	}
+
 	testData := []struct {
-		desc                         string
-		backendAddress               string
-		enableBackendAddressOverride bool
-		// Map of selector to the expected backend cluster.
-		wantedMethodBackendCluster map[string]string
+		desc            string
+		tokenHeaders    string
+		wantTokenHeader map[string]string
+		wantError       string
 	}{
 		{
-			desc:                         "When disabled, backend.rule.address has priority",
-			backendAddress:               "http://127.0.0.1:9000",
-			enableBackendAddressOverride: false,
-			wantedMethodBackendCluster: map[string]string{
-				"abc.com.a": "backend-cluster-abc.com:80",
-				"abc.com.b": "backend-cluster-echo.endpoints_local",
+			desc:         "Token header is set for the operation",
+			tokenHeaders: "abc.com.foo=X-Serverless-Authorization",
+			wantTokenHeader: map[string]string{
+				"abc.com.foo": "X-Serverless-Authorization",
 			},
 		},
 		{
-			desc:                         "When enabled, backend.rule.address losses priority",
-			backendAddress:               "http://127.0.0.1:9000",
-			enableBackendAddressOverride: true,
-			wantedMethodBackendCluster: map[string]string{
-				"abc.com.a": "backend-cluster-echo.endpoints_local",
-				"abc.com.b": "backend-cluster-echo.endpoints_local",
+			desc:         "Header name with RFC 7230 token characters",
+			tokenHeaders: "abc.com.foo=X-Serverless.Auth~1",
+			wantTokenHeader: map[string]string{
+				"abc.com.foo": "X-Serverless.Auth~1",
 			},
 		},
+		{
+			desc:         "Invalid header name",
+			tokenHeaders: "abc.com.foo=X-Serverless:Authorization",
+			wantError:    `invalid backend auth token header "abc.com.foo=X-Serverless:Authorization", it should be in the format selector=header`,
+		},
+		{
+			desc:         "Unknown selector",
+			tokenHeaders: "abc.com.baz=X-Serverless-Authorization",
+			wantError:    "error processing backend auth token header for operation (abc.com.baz): selector (abc.com.baz) was not defined in the API",
+		},
+		{
+			desc:         "Backend auth is disabled",
+			tokenHeaders: "abc.com.bar=X-Serverless-Authorization",
+			wantError:    "error processing backend auth token header for operation (abc.com.bar): backend auth is not enabled for the operation",
+		},
 	}
 
 	for _, tc := range testData {
 		t.Run(tc.desc, func(t *testing.T) {
-
-			fakeServiceConfig := &confpb.Service{
-				Name: "echo.endpoints",
-				Apis: []*apipb.Api{
-					{
-						Name: "abc.com",
-						Methods: []*apipb.Method{
-							{
-								Name: "a",
-							},
-							{
-								Name: "b",
-							},
-						},
-					},
-				},
-				Backend: &confpb.Backend{
-					Rules: []*confpb.BackendRule{
-						{
-							Address:  "grpc://abc.com/a/",
-							Selector: "abc.com.a",
-						},
-					},
-				},
-			}
-
 			opts := options.DefaultConfigGeneratorOptions()
-			opts.BackendAddress = tc.backendAddress
-			opts.EnableBackendAddressOverride = tc.enableBackendAddressOverride
+			opts.BackendAuthTokenHeaders = tc.tokenHeaders
 			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
-
-			if err != nil {
-				t.Errorf("error not expected, got: %v", err)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
 				return
 			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
 
-			for operation, mi := range s.Methods {
-				gotBackendCluster := mi.BackendInfo.ClusterName
-				wantBackendCluster := tc.wantedMethodBackendCluster[operation]
-
-				if gotBackendCluster != wantBackendCluster {
-					t.Errorf("Backend cluster name not expected, got: %v, want: %v", gotBackendCluster, wantBackendCluster)
+			for selector, want := range tc.wantTokenHeader {
+				if got := s.Methods[selector].BackendInfo.TokenHeader; got != want {
+					t.Errorf("TokenHeader mismatch for %v, got: %v, want: %v", selector, got, want)
 				}
 			}
 		})
 	}
 }
 
-func TestProcessQuota(t *testing.T) {
-	testData := []struct {
-		desc              string
-		fakeServiceConfig *confpb.Service
-		wantMethods       map[string]*MethodInfo
-		wantError         string
-	}{
-		{
-			desc: "Succeed, simple case",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
+func TestProcessBackendHostRewrites(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
 					{
-						Name: testApiName,
-						Methods: []*apipb.Method{
-							{
-								Name: "ListShelves",
-							},
-						},
+						Name: "foo",
+					},
+					{
+						Name: "bar",
 					},
 				},
-				Quota: &confpb.Quota{
-					MetricRules: []*confpb.MetricRule{
-						{
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc            string
+		hostRewrite     string
+		hostRewrites    string
+		wantHostRewrite map[string]string
+		wantError       string
+	}{
+		{
+			desc: "No host rewrite by default",
+			wantHostRewrite: map[string]string{
+				"abc.com.foo": "",
+				"abc.com.bar": "",
+			},
+		},
+		{
+			desc:        "Host rewrite for all backends",
+			hostRewrite: "api.example.com",
+			wantHostRewrite: map[string]string{
+				"abc.com.foo": "api.example.com",
+				"abc.com.bar": "api.example.com",
+			},
+		},
+		{
+			desc:         "Host rewrites for operations take precedence over the host rewrite for all backends",
+			hostRewrite:  "api.example.com",
+			hostRewrites: "abc.com.bar=local.example.com",
+			wantHostRewrite: map[string]string{
+				"abc.com.foo": "api.example.com",
+				"abc.com.bar": "local.example.com",
+			},
+		},
+		{
+			desc:         "Host rewrites for remote and local backends",
+			hostRewrites: "abc.com.foo=api.example.com, abc.com.bar=local.example.com",
+			wantHostRewrite: map[string]string{
+				"abc.com.foo": "api.example.com",
+				"abc.com.bar": "local.example.com",
+			},
+		},
+		{
+			desc:         "Invalid format",
+			hostRewrites: "abc.com.foo",
+			wantError:    `invalid backend host rewrite "abc.com.foo", it should be in the format selector=host`,
+		},
+		{
+			desc:         "Unknown selector",
+			hostRewrites: "abc.com.baz=api.example.com",
+			wantError:    "error processing backend host rewrite for operation (abc.com.baz): selector (abc.com.baz) was not defined in the API",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendHostRewrite = tc.hostRewrite
+			opts.BackendHostRewriteByOperation = tc.hostRewrites
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			for selector, want := range tc.wantHostRewrite {
+				if got := s.Methods[selector].BackendInfo.HostRewrite; got != want {
+					t.Errorf("HostRewrite mismatch for %v, got: %v, want: %v", selector, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessBackendStripPrefixes(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+					{
+						Name: "baz",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Address:         "https://abc.com/other",
+					Selector:        "abc.com.baz",
+					PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc            string
+		stripPrefixes   string
+		wantStripPrefix map[string]string
+		wantError       string
+	}{
+		{
+			desc: "No strip prefix by default",
+			wantStripPrefix: map[string]string{
+				"abc.com.foo": "",
+				"abc.com.bar": "",
+			},
+		},
+		{
+			desc:          "Strip prefixes for remote and local backends",
+			stripPrefixes: "abc.com.foo=/api/v1, abc.com.bar=/v2/",
+			wantStripPrefix: map[string]string{
+				"abc.com.foo": "/api/v1",
+				"abc.com.bar": "/v2/",
+			},
+		},
+		{
+			desc:          "Invalid format",
+			stripPrefixes: "abc.com.foo",
+			wantError:     `invalid backend strip prefix "abc.com.foo", it should be in the format selector=prefix`,
+		},
+		{
+			desc:          "Unknown selector",
+			stripPrefixes: "abc.com.qux=/api",
+			wantError:     "error processing backend strip prefix for operation (abc.com.qux): selector (abc.com.qux) was not defined in the API",
+		},
+		{
+			desc:          "Prefix without leading slash",
+			stripPrefixes: "abc.com.foo=api/v1",
+			wantError:     `error processing backend strip prefix for operation (abc.com.foo): invalid prefix "api/v1", it should start with / and not contain a query or fragment`,
+		},
+		{
+			desc:          "Root prefix",
+			stripPrefixes: "abc.com.foo=/",
+			wantError:     `error processing backend strip prefix for operation (abc.com.foo): invalid prefix "/", it should start with / and not contain a query or fragment`,
+		},
+		{
+			desc:          "Prefix with query",
+			stripPrefixes: "abc.com.foo=/api?a",
+			wantError:     `error processing backend strip prefix for operation (abc.com.foo): invalid prefix "/api?a", it should start with / and not contain a query or fragment`,
+		},
+		{
+			desc:          "CONSTANT_ADDRESS is not supported",
+			stripPrefixes: "abc.com.baz=/api",
+			wantError:     "error processing backend strip prefix for operation (abc.com.baz): it is not supported with CONSTANT_ADDRESS path translation",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendStripPrefixByOperation = tc.stripPrefixes
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			for selector, want := range tc.wantStripPrefix {
+				if got := s.Methods[selector].BackendInfo.StripPrefix; got != want {
+					t.Errorf("StripPrefix mismatch for %v, got: %v, want: %v", selector, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessBackendRegexRewrites(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+					{
+						Name: "baz",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Address:         "https://abc.com/other",
+					Selector:        "abc.com.baz",
+					PathTranslation: confpb.BackendRule_CONSTANT_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc             string
+		regexRewrites    string
+		stripPrefixes    string
+		wantPattern      map[string]string
+		wantSubstitution map[string]string
+		wantError        string
+	}{
+		{
+			desc: "No regex rewrite by default",
+			wantPattern: map[string]string{
+				"abc.com.foo": "",
+				"abc.com.bar": "",
+			},
+		},
+		{
+			desc:          "Regex rewrites for remote and local backends",
+			regexRewrites: `abc.com.foo=^/v1/users/([^/]+)/profile$=/profile?user=\1, abc.com.bar=^/v1/(.{3})$=/\1`,
+			wantPattern: map[string]string{
+				"abc.com.foo": "^/v1/users/([^/]+)/profile$",
+				"abc.com.bar": "^/v1/(.{3})$",
+			},
+			wantSubstitution: map[string]string{
+				"abc.com.foo": `/profile?user=\1`,
+				"abc.com.bar": `/\1`,
+			},
+		},
+		{
+			desc:          "Commas in repetitions and escaped commas and equal signs are part of the pattern",
+			regexRewrites: `abc.com.foo=^/v1/([a-z]{1,3})\,(\d+)\=$=/\1?n=\2,abc.com.bar=^/v2/(.{2,})$=/\1`,
+			wantPattern: map[string]string{
+				"abc.com.foo": `^/v1/([a-z]{1,3})\,(\d+)\=$`,
+				"abc.com.bar": "^/v2/(.{2,})$",
+			},
+			wantSubstitution: map[string]string{
+				"abc.com.foo": `/\1?n=\2`,
+				"abc.com.bar": `/\1`,
+			},
+		},
+		{
+			desc:          "Invalid format",
+			regexRewrites: "abc.com.foo=^/v1",
+			wantError:     `invalid backend regex rewrite "abc.com.foo=^/v1", it should be in the format selector=pattern=substitution`,
+		},
+		{
+			desc:          "Unknown selector",
+			regexRewrites: "abc.com.qux=^/v1=/v2",
+			wantError:     "error processing backend regex rewrite for operation (abc.com.qux): selector (abc.com.qux) was not defined in the API",
+		},
+		{
+			desc:          "Invalid pattern",
+			regexRewrites: "abc.com.foo=^/v1/(=/v2",
+			wantError:     "error processing backend regex rewrite for operation (abc.com.foo): invalid pattern \"^/v1/(\": error parsing regexp: missing closing ): `^/v1/(`",
+		},
+		{
+			desc:          "CONSTANT_ADDRESS is not supported",
+			regexRewrites: "abc.com.baz=^/v1=/v2",
+			wantError:     "error processing backend regex rewrite for operation (abc.com.baz): it is not supported with CONSTANT_ADDRESS path translation",
+		},
+		{
+			desc:          "Strip prefix is not supported",
+			regexRewrites: "abc.com.foo=^/v1=/v2",
+			stripPrefixes: "abc.com.foo=/api",
+			wantError:     "error processing backend regex rewrite for operation (abc.com.foo): it cannot be used with a backend strip prefix",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendRegexRewriteByOperation = tc.regexRewrites
+			opts.BackendStripPrefixByOperation = tc.stripPrefixes
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			for selector, want := range tc.wantPattern {
+				if got := s.Methods[selector].BackendInfo.RegexRewritePattern; got != want {
+					t.Errorf("RegexRewritePattern mismatch for %v, got: %v, want: %v", selector, got, want)
+				}
+				if got := s.Methods[selector].BackendInfo.RegexRewriteSubstitution; got != tc.wantSubstitution[selector] {
+					t.Errorf("RegexRewriteSubstitution mismatch for %v, got: %v, want: %v", selector, got, tc.wantSubstitution[selector])
+				}
+			}
+		})
+	}
+}
+
+func TestProcessBackendConnectTimeouts(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+					{
+						Name: "baz",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Address:         "https://abc.com/other",
+					Selector:        "abc.com.baz",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                  string
+		connectTimeouts       string
+		wantLocalTimeout      time.Duration
+		wantRemoteTimeout     time.Duration
+		backendConnectTimeout time.Duration
+		wantError             string
+	}{
+		{
+			desc: "No connect timeout overrides by default",
+		},
+		{
+			desc:              "Connect timeouts for remote and local backends",
+			connectTimeouts:   "abc.com.foo=5s, abc.com.bar=1m",
+			wantRemoteTimeout: 5 * time.Second,
+			wantLocalTimeout:  time.Minute,
+		},
+		{
+			desc:              "Operations sharing a backend set the same connect timeout",
+			connectTimeouts:   "abc.com.foo=5s,abc.com.baz=5s",
+			wantRemoteTimeout: 5 * time.Second,
+		},
+		{
+			desc:            "Operations sharing a backend set different connect timeouts",
+			connectTimeouts: "abc.com.foo=5s,abc.com.baz=10s",
+			wantError:       "error processing backend connect timeout for operation (abc.com.baz): backend cluster (backend-cluster-abc.com:443) already has a different connect timeout 5s",
+		},
+		{
+			desc:            "Invalid format",
+			connectTimeouts: "abc.com.foo",
+			wantError:       `invalid backend connect timeout "abc.com.foo", it should be in the format selector=duration`,
+		},
+		{
+			desc:            "Invalid duration",
+			connectTimeouts: "abc.com.foo=-1s",
+			wantError:       `error processing backend connect timeout for operation (abc.com.foo): invalid duration "-1s"`,
+		},
+		{
+			desc:            "Unknown selector",
+			connectTimeouts: "abc.com.qux=5s",
+			wantError:       "error processing backend connect timeout for operation (abc.com.qux): selector (abc.com.qux) was not defined in the API",
+		},
+		{
+			desc:                  "Negative backend connect timeout",
+			backendConnectTimeout: -time.Second,
+			wantError:             "flag --backend_connect_timeout must not be negative, got -1s",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendConnectTimeoutByOperation = tc.connectTimeouts
+			opts.BackendConnectTimeout = tc.backendConnectTimeout
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			if got := s.LocalBackendCluster.ConnectTimeout; got != tc.wantLocalTimeout {
+				t.Errorf("ConnectTimeout mismatch for the local backend, got: %v, want: %v", got, tc.wantLocalTimeout)
+			}
+			if got := s.RemoteBackendClusters[0].ConnectTimeout; got != tc.wantRemoteTimeout {
+				t.Errorf("ConnectTimeout mismatch for the remote backend, got: %v, want: %v", got, tc.wantRemoteTimeout)
+			}
+		})
+	}
+}
+
+func TestProcessBackendSnis(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+					{
+						Name: "baz",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Address:         "https://abc.com/other",
+					Selector:        "abc.com.baz",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc          string
+		snis          string
+		wantRemoteSni string
+		wantError     string
+	}{
+		{
+			desc: "No SNI overrides by default",
+		},
+		{
+			desc:          "SNI for a remote backend",
+			snis:          "abc.com.foo=backend.example.com",
+			wantRemoteSni: "backend.example.com",
+		},
+		{
+			desc:          "Operations sharing a backend set the same SNI",
+			snis:          "abc.com.foo=backend.example.com, abc.com.baz=backend.example.com",
+			wantRemoteSni: "backend.example.com",
+		},
+		{
+			desc:      "Operations sharing a backend set different SNIs",
+			snis:      "abc.com.foo=backend.example.com,abc.com.baz=other.example.com",
+			wantError: "error processing backend SNI for operation (abc.com.baz): backend cluster (backend-cluster-abc.com:443) already has a different SNI backend.example.com",
+		},
+		{
+			desc:      "SNI for the plaintext local backend",
+			snis:      "abc.com.bar=backend.example.com",
+			wantError: "error processing backend SNI for operation (abc.com.bar): backend cluster (backend-cluster-_local) does not use TLS",
+		},
+		{
+			desc:      "Invalid format",
+			snis:      "abc.com.foo",
+			wantError: `invalid backend SNI "abc.com.foo", it should be in the format selector=sni`,
+		},
+		{
+			desc:      "Unknown selector",
+			snis:      "abc.com.qux=backend.example.com",
+			wantError: "error processing backend SNI for operation (abc.com.qux): selector (abc.com.qux) was not defined in the API",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendSniByOperation = tc.snis
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			if got := s.RemoteBackendClusters[0].Sni; got != tc.wantRemoteSni {
+				t.Errorf("Sni mismatch for the remote backend, got: %v, want: %v", got, tc.wantRemoteSni)
+			}
+		})
+	}
+}
+
+func TestProcessBackendAlpns(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+					{
+						Name: "baz",
+					},
+					{
+						Name: "qux",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Address:         "https://abc.com/other",
+					Selector:        "abc.com.baz",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Address:  "grpcs://grpc.abc.com",
+					Selector: "abc.com.qux",
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc               string
+		alpns              string
+		wantRemoteAlpn     string
+		wantRemoteProtocol util.BackendProtocol
+		wantError          string
+	}{
+		{
+			desc:               "No ALPN overrides by default",
+			wantRemoteProtocol: util.HTTP1,
+		},
+		{
+			desc:               "h2 ALPN switches the backend to HTTP/2",
+			alpns:              "abc.com.foo=h2",
+			wantRemoteAlpn:     "h2",
+			wantRemoteProtocol: util.HTTP2,
+		},
+		{
+			desc:               "Operations sharing a backend set the same ALPN",
+			alpns:              "abc.com.foo=http/1.1, abc.com.baz=http/1.1",
+			wantRemoteAlpn:     "http/1.1",
+			wantRemoteProtocol: util.HTTP1,
+		},
+		{
+			desc:      "Operations sharing a backend set different ALPNs",
+			alpns:     "abc.com.foo=h2,abc.com.baz=http/1.1",
+			wantError: "error processing backend ALPN for operation (abc.com.baz): backend cluster (backend-cluster-abc.com:443) already has a different ALPN h2",
+		},
+		{
+			desc:      "http/1.1 ALPN for a gRPC backend",
+			alpns:     "abc.com.qux=http/1.1",
+			wantError: "error processing backend ALPN for operation (abc.com.qux): gRPC backend cluster (backend-cluster-grpc.abc.com:443) only supports h2",
+		},
+		{
+			desc:               "auto ALPN keeps the backend config as HTTP/1.1",
+			alpns:              "abc.com.foo=auto",
+			wantRemoteAlpn:     "auto",
+			wantRemoteProtocol: util.HTTP1,
+		},
+		{
+			desc:      "auto ALPN for a gRPC backend",
+			alpns:     "abc.com.qux=auto",
+			wantError: "error processing backend ALPN for operation (abc.com.qux): gRPC backend cluster (backend-cluster-grpc.abc.com:443) only supports h2",
+		},
+		{
+			desc:      "Unknown ALPN",
+			alpns:     "abc.com.foo=h3",
+			wantError: `error processing backend ALPN for operation (abc.com.foo): unknown ALPN "h3", should be one of "h2", "http/1.1" or "auto"`,
+		},
+		{
+			desc:      "ALPN for the plaintext local backend",
+			alpns:     "abc.com.bar=h2",
+			wantError: "error processing backend ALPN for operation (abc.com.bar): backend cluster (backend-cluster-_local) does not use TLS",
+		},
+		{
+			desc:      "Invalid format",
+			alpns:     "abc.com.foo",
+			wantError: `invalid backend ALPN "abc.com.foo", it should be in the format selector=alpn`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAlpnByOperation = tc.alpns
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			if got := s.RemoteBackendClusters[0].Alpn; got != tc.wantRemoteAlpn {
+				t.Errorf("Alpn mismatch for the remote backend, got: %v, want: %v", got, tc.wantRemoteAlpn)
+			}
+			if got := s.RemoteBackendClusters[0].Protocol; got != tc.wantRemoteProtocol {
+				t.Errorf("Protocol mismatch for the remote backend, got: %v, want: %v", got, tc.wantRemoteProtocol)
+			}
+		})
+	}
+}
+
+func TestProcessDisableChunkedEncodingBackends(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                string
+		backends            string
+		wantBufferedMethods []string
+		wantError           string
+	}{
+		{
+			desc: "No requests are buffered by default",
+		},
+		{
+			desc:                "Buffer requests to the remote backend, regardless of its path",
+			backends:            "https://abc.com",
+			wantBufferedMethods: []string{"abc.com.foo"},
+		},
+		{
+			desc:                "Buffer requests to the local and remote backends",
+			backends:            "http://127.0.0.1:8082, https://abc.com:443/api",
+			wantBufferedMethods: []string{"abc.com.bar", "abc.com.foo"},
+		},
+		{
+			desc:      "Unknown backend address",
+			backends:  "https://xyz.com",
+			wantError: `backend address "https://xyz.com" of --disable_chunked_encoding_backends is not --backend_address or a backend address in the service config`,
+		},
+		{
+			desc:      "Invalid backend address",
+			backends:  "https://abc.com:port",
+			wantError: `error parsing backend address "https://abc.com:port" of --disable_chunked_encoding_backends: parse "https://abc.com:port": invalid port ":port" after host`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableChunkedEncodingBackends = tc.backends
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			var gotBufferedMethods []string
+			for operation, method := range s.Methods {
+				if method.BackendInfo.BufferRequests {
+					gotBufferedMethods = append(gotBufferedMethods, operation)
+				}
+			}
+			sort.Strings(gotBufferedMethods)
+			if !reflect.DeepEqual(gotBufferedMethods, tc.wantBufferedMethods) {
+				t.Errorf("buffered methods mismatch, got: %v, want: %v", gotBufferedMethods, tc.wantBufferedMethods)
+			}
+		})
+	}
+}
+
+func TestProcessCorsDisabledOperations(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "ListShelves",
+					},
+					{
+						Name: "CreateShelf",
+					},
+					{
+						Name: "DeleteShelf",
+					},
+				},
+			},
+		},
+		Endpoints: []*confpb.Endpoint{
+			{
+				Name:      testProjectName,
+				AllowCors: true,
+			},
+		},
+		Http: &annotationspb.Http{
+			Rules: []*annotationspb.HttpRule{
+				{
+					Selector: "abc.com.ListShelves",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/shelves",
+					},
+				},
+				{
+					Selector: "abc.com.CreateShelf",
+					Pattern: &annotationspb.HttpRule_Post{
+						Post: "/shelves/create",
+					},
+				},
+				{
+					Selector: "abc.com.DeleteShelf",
+					Pattern: &annotationspb.HttpRule_Delete{
+						Delete: "/shelves/{shelf}",
+					},
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc                   string
+		corsDisabledOperations string
+		wantCorsEnabled        map[string]bool
+		wantError              string
+	}{
+		{
+			desc: "CORS is enabled for all operations by default",
+			wantCorsEnabled: map[string]bool{
+				"abc.com.ListShelves": true,
+				"abc.com.CreateShelf": true,
+				"abc.com.DeleteShelf": true,
+			},
+		},
+		{
+			desc:                   "CORS is disabled for the write operations",
+			corsDisabledOperations: "abc.com.CreateShelf, abc.com.DeleteShelf",
+			wantCorsEnabled: map[string]bool{
+				"abc.com.ListShelves": true,
+				"abc.com.CreateShelf": false,
+				"abc.com.DeleteShelf": false,
+			},
+		},
+		{
+			desc:                   "Unknown selector",
+			corsDisabledOperations: "abc.com.UpdateShelf",
+			wantError:              "error processing cors disabled operation (abc.com.UpdateShelf): selector (abc.com.UpdateShelf) was not defined in the API",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.CorsDisabledOperations = tc.corsDisabledOperations
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			for selector, want := range tc.wantCorsEnabled {
+				if got := s.Methods[selector].GeneratedCorsMethod != nil; got != want {
+					t.Errorf("CORS enabled mismatch for %v, got: %v, want: %v", selector, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessJwtClaimRoutes(t *testing.T) {
+	testData := []struct {
+		desc                  string
+		jwtClaimBackendRoutes string
+		skipServiceControl    bool
+		checkApiKeyBeforeJwt  bool
+		wantJwtClaimRoutes    []*JwtClaimRoute
+		wantClusters          []*BackendRoutingCluster
+		wantError             string
+	}{
+		{
+			desc:                  "Routes sharing a backend share the cluster",
+			jwtClaimBackendRoutes: "tier:enterprise=https://enterprise.example.com, tier:premium=https://enterprise.example.com,region:eu=http://eu.example.com:8080",
+			wantJwtClaimRoutes: []*JwtClaimRoute{
+				{
+					Claim:       "tier",
+					Value:       "enterprise",
+					ClusterName: "backend-cluster-enterprise.example.com:443",
+					Hostname:    "enterprise.example.com",
+				},
+				{
+					Claim:       "tier",
+					Value:       "premium",
+					ClusterName: "backend-cluster-enterprise.example.com:443",
+					Hostname:    "enterprise.example.com",
+				},
+				{
+					Claim:       "region",
+					Value:       "eu",
+					ClusterName: "backend-cluster-eu.example.com:8080",
+					Hostname:    "eu.example.com",
+				},
+			},
+			wantClusters: []*BackendRoutingCluster{
+				{
+					ClusterName: "backend-cluster-enterprise.example.com:443",
+					Hostname:    "enterprise.example.com",
+					Port:        443,
+					UseTLS:      true,
+					Protocol:    util.HTTP1,
+				},
+				{
+					ClusterName: "backend-cluster-eu.example.com:8080",
+					Hostname:    "eu.example.com",
+					Port:        8080,
+					Protocol:    util.HTTP1,
+				},
+			},
+		},
+		{
+			desc:                  "Missing claim value",
+			jwtClaimBackendRoutes: "tier=https://enterprise.example.com",
+			wantError:             `invalid JWT claim route "tier=https://enterprise.example.com", it should be in the format claim:value=backend_address`,
+		},
+		{
+			desc:                  "Claim name not usable in a header",
+			jwtClaimBackendRoutes: "ti er:enterprise=https://enterprise.example.com",
+			wantError:             `invalid JWT claim route "ti er:enterprise=https://enterprise.example.com", it should be in the format claim:value=backend_address`,
+		},
+		{
+			desc:                  "Invalid backend protocol",
+			jwtClaimBackendRoutes: "tier:enterprise=ftp://enterprise.example.com",
+			wantError:             `error parsing backend protocol of JWT claim route "tier:enterprise=ftp://enterprise.example.com"`,
+		},
+		{
+			desc:                  "Service control filter is skipped",
+			jwtClaimBackendRoutes: "tier:enterprise=https://enterprise.example.com",
+			skipServiceControl:    true,
+			wantError:             "JWT claim routes require the service control filter",
+		},
+		{
+			desc:                  "API key is checked before JWT",
+			jwtClaimBackendRoutes: "tier:enterprise=https://enterprise.example.com",
+			checkApiKeyBeforeJwt:  true,
+			wantError:             "JWT claim routes cannot be used with --check_api_key_before_jwt",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+				Control: &confpb.Control{
+					Environment: "servicecontrol.googleapis.com",
+				},
+			}
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.JwtClaimBackendRoutes = tc.jwtClaimBackendRoutes
+			opts.SkipServiceControlFilter = tc.skipServiceControl
+			opts.CheckApiKeyBeforeJwt = tc.checkApiKeyBeforeJwt
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			if !reflect.DeepEqual(s.JwtClaimRoutes, tc.wantJwtClaimRoutes) {
+				t.Errorf("JwtClaimRoutes mismatch, got: %+v, want: %+v", s.JwtClaimRoutes, tc.wantJwtClaimRoutes)
+			}
+			if !reflect.DeepEqual(s.RemoteBackendClusters, tc.wantClusters) {
+				t.Errorf("RemoteBackendClusters mismatch, got: %+v, want: %+v", s.RemoteBackendClusters, tc.wantClusters)
+			}
+		})
+	}
+}
+
+func TestProcessMirrorBackend(t *testing.T) {
+	testData := []struct {
+		desc                 string
+		mirrorBackendAddress string
+		mirrorPercent        float64
+		wantClusterName      string
+		wantClusters         []*BackendRoutingCluster
+		wantError            string
+	}{
+		{
+			desc:          "No mirror backend by default",
+			mirrorPercent: 100,
+		},
+		{
+			desc:                 "Mirror backend gets a remote cluster",
+			mirrorBackendAddress: "https://shadow.example.com",
+			mirrorPercent:        100,
+			wantClusterName:      "backend-cluster-shadow.example.com:443",
+			wantClusters: []*BackendRoutingCluster{
+				{
+					ClusterName: "backend-cluster-shadow.example.com:443",
+					Hostname:    "shadow.example.com",
+					Port:        443,
+					UseTLS:      true,
+					Protocol:    util.HTTP1,
+				},
+			},
+		},
+		{
+			desc:                 "Invalid mirror backend protocol",
+			mirrorBackendAddress: "ftp://shadow.example.com",
+			mirrorPercent:        100,
+			wantError:            "error parsing mirror backend protocol",
+		},
+		{
+			desc:                 "Mirror percent out of range",
+			mirrorBackendAddress: "https://shadow.example.com",
+			mirrorPercent:        150,
+			wantError:            "flag --mirror_percent must be in (0, 100], got 150",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			}
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.MirrorBackendAddress = tc.mirrorBackendAddress
+			opts.MirrorPercent = tc.mirrorPercent
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			if s.MirrorBackendClusterName != tc.wantClusterName {
+				t.Errorf("MirrorBackendClusterName mismatch, got: %v, want: %v", s.MirrorBackendClusterName, tc.wantClusterName)
+			}
+			if !reflect.DeepEqual(s.RemoteBackendClusters, tc.wantClusters) {
+				t.Errorf("RemoteBackendClusters mismatch, got: %+v, want: %+v", s.RemoteBackendClusters, tc.wantClusters)
+			}
+		})
+	}
+}
+
+func TestBuildLocalBackendWithFallback(t *testing.T) {
+	testData := []struct {
+		desc                   string
+		backendAddress         string
+		backendFallbackAddress string
+		healthCheckPath        string
+		wantCluster            *BackendRoutingCluster
+		wantError              string
+	}{
+		{
+			desc:           "No fallback backend by default",
+			backendAddress: "http://127.0.0.1:8082",
+			wantCluster: &BackendRoutingCluster{
+				ClusterName: "backend-cluster-abc.com_local",
+				Hostname:    "127.0.0.1",
+				Port:        8082,
+				Protocol:    util.HTTP1,
+			},
+		},
+		{
+			desc:                   "Fallback backend is added to the local backend",
+			backendAddress:         "grpc://127.0.0.1:8082",
+			backendFallbackAddress: "grpc://read-only.example.com:9000",
+			wantCluster: &BackendRoutingCluster{
+				ClusterName:      "backend-cluster-abc.com_local",
+				Hostname:         "127.0.0.1",
+				Port:             8082,
+				Protocol:         util.GRPC,
+				FallbackHostname: "read-only.example.com",
+				FallbackPort:     9000,
+			},
+		},
+		{
+			desc:                   "Fallback backend with a different scheme",
+			backendAddress:         "http://127.0.0.1:8082",
+			backendFallbackAddress: "https://read-only.example.com",
+			wantError:              `fallback backend address "https://read-only.example.com" must use the same scheme as the backend address "http://127.0.0.1:8082"`,
+		},
+		{
+			desc:                   "Invalid fallback backend address",
+			backendAddress:         "http://127.0.0.1:8082",
+			backendFallbackAddress: "http://read-only.example.com:port",
+			wantError:              "error parsing fallback backend uri",
+		},
+		{
+			desc:            "Health check without a fallback backend",
+			backendAddress:  "http://127.0.0.1:8082",
+			healthCheckPath: "/healthz",
+			wantError:       "flags --backend_health_check_path, --backend_health_check_host and --backend_health_check_expected_statuses require --backend_fallback_address or --backend_fallback_address_by_operation",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Name: "abc.com",
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			}
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = tc.backendAddress
+			opts.BackendFallbackAddress = tc.backendFallbackAddress
+			opts.BackendHealthCheckPath = tc.healthCheckPath
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			if !reflect.DeepEqual(s.LocalBackendCluster, tc.wantCluster) {
+				t.Errorf("LocalBackendCluster mismatch, got: %+v, want: %+v", s.LocalBackendCluster, tc.wantCluster)
+			}
+		})
+	}
+}
+
+func TestProcessBackendFallbackAddresses(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+					{
+						Name: "baz",
+					},
+				},
+			},
+		},
+		Backend: &confpb.Backend{
+			Rules: []*confpb.BackendRule{
+				{
+					Address:         "https://abc.com/api",
+					Selector:        "abc.com.foo",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+				{
+					Address:         "https://abc.com/other",
+					Selector:        "abc.com.baz",
+					PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc               string
+		fallbackAddresses  string
+		wantRemoteFallback string
+		wantLocalFallback  string
+		wantError          string
+	}{
+		{
+			desc: "No fallback backends by default",
+		},
+		{
+			desc:               "Fallback backend for a remote backend",
+			fallbackAddresses:  "abc.com.foo=https://read-only.example.com",
+			wantRemoteFallback: "read-only.example.com:443",
+		},
+		{
+			desc:              "Fallback backend for the local backend",
+			fallbackAddresses: "abc.com.bar=http://read-only.example.com:8080",
+			wantLocalFallback: "read-only.example.com:8080",
+		},
+		{
+			desc:               "Operations sharing a backend set the same fallback backend",
+			fallbackAddresses:  "abc.com.foo=https://read-only.example.com,abc.com.baz=https://read-only.example.com:443",
+			wantRemoteFallback: "read-only.example.com:443",
+		},
+		{
+			desc:              "Operations sharing a backend set different fallback backends",
+			fallbackAddresses: "abc.com.foo=https://read-only.example.com,abc.com.baz=https://other.example.com",
+			wantError:         "error processing backend fallback address for operation (abc.com.baz): backend cluster (backend-cluster-abc.com:443) already has a different fallback address read-only.example.com:443",
+		},
+		{
+			desc:              "Fallback backend with a different scheme",
+			fallbackAddresses: "abc.com.foo=http://read-only.example.com",
+			wantError:         `error processing backend fallback address for operation (abc.com.foo): "http://read-only.example.com" must use the same scheme as backend cluster (backend-cluster-abc.com:443)`,
+		},
+		{
+			desc:              "Invalid format",
+			fallbackAddresses: "abc.com.foo",
+			wantError:         `invalid backend fallback address "abc.com.foo", it should be in the format selector=address`,
+		},
+		{
+			desc:              "Unknown selector",
+			fallbackAddresses: "abc.com.qux=https://read-only.example.com",
+			wantError:         "error processing backend fallback address for operation (abc.com.qux): selector (abc.com.qux) was not defined in the API",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendFallbackAddressByOperation = tc.fallbackAddresses
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			gotFallback := func(cluster *BackendRoutingCluster) string {
+				if cluster.FallbackHostname == "" {
+					return ""
+				}
+				return util.JoinHostPort(cluster.FallbackHostname, cluster.FallbackPort)
+			}
+			if got := gotFallback(s.RemoteBackendClusters[0]); got != tc.wantRemoteFallback {
+				t.Errorf("fallback mismatch for the remote backend, got: %v, want: %v", got, tc.wantRemoteFallback)
+			}
+			if got := gotFallback(s.LocalBackendCluster); got != tc.wantLocalFallback {
+				t.Errorf("fallback mismatch for the local backend, got: %v, want: %v", got, tc.wantLocalFallback)
+			}
+		})
+	}
+}
+
+func TestProcessSkipServiceControlPaths(t *testing.T) {
+	testData := []struct {
+		desc                    string
+		skipServiceControlPaths string
+		wantHttpRules           []string
+		wantError               string
+	}{
+		{
+			desc: "No generated method by default",
+		},
+		{
+			desc:                    "Paths are added to a generated method that skips service control",
+			skipServiceControlPaths: "/internal/health, /internal/status/{name}",
+			wantHttpRules: []string{
+				"* /internal/health",
+				"* /internal/status/{name=*}",
+			},
+		},
+		{
+			desc:                    "Path without leading slash",
+			skipServiceControlPaths: "internal/health",
+			wantError:               `invalid path "internal/health" to skip service control, it should start with /`,
+		},
+		{
+			desc:                    "Path is not a valid URI template",
+			skipServiceControlPaths: "/internal/{health",
+			wantError:               `error parsing path "/internal/{health" to skip service control`,
+		},
+	}
+
+	methodName := "espv2_deployment.ESPv2_Autogenerated_SkipServiceControl"
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			}
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "http://127.0.0.1:8082"
+			opts.SkipServiceControlPaths = tc.skipServiceControlPaths
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			method, ok := s.Methods[methodName]
+			if tc.wantHttpRules == nil {
+				if ok {
+					t.Fatalf("method %v should not be generated", methodName)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("method %v should be generated", methodName)
+			}
+			if !method.SkipServiceControl || !method.IsGenerated {
+				t.Errorf("method %v should be generated and skip service control, got: %+v", methodName, method)
+			}
+			if method.BackendInfo == nil {
+				t.Errorf("method %v should be routed to the local backend", methodName)
+			}
+
+			var gotHttpRules []string
+			for _, httpRule := range method.HttpRule {
+				gotHttpRules = append(gotHttpRules, fmt.Sprintf("%s %s", httpRule.HttpMethod, httpRule.UriTemplate.String()))
+			}
+			if !reflect.DeepEqual(gotHttpRules, tc.wantHttpRules) {
+				t.Errorf("HttpRule mismatch, got: %v, want: %v", gotHttpRules, tc.wantHttpRules)
+			}
+		})
+	}
+}
+
+func TestProcessStaticResponses(t *testing.T) {
+	faviconPath := filepath.Join(t.TempDir(), "favicon.ico")
+	if err := ioutil.WriteFile(faviconPath, []byte("favicon"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		desc            string
+		staticResponses string
+		wantHttpRules   map[string]string
+		wantError       string
+	}{
+		{
+			desc:            "Each path is added to a generated method that skips service control",
+			staticResponses: "/favicon.ico=" + faviconPath + ", /robots.txt",
+			wantHttpRules: map[string]string{
+				"espv2_deployment.ESPv2_Autogenerated_StaticResponse_0": "* /favicon.ico",
+				"espv2_deployment.ESPv2_Autogenerated_StaticResponse_1": "* /robots.txt",
+			},
+		},
+		{
+			desc:            "Path without leading slash",
+			staticResponses: "favicon.ico",
+			wantError:       `invalid path "favicon.ico" of static response, it should start with /`,
+		},
+		{
+			desc:            "File does not exist",
+			staticResponses: "/favicon.ico=/etc/espv2/not_exist.ico",
+			wantError:       `error making static response of path "/favicon.ico": open /etc/espv2/not_exist.ico: no such file or directory`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			}
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "http://127.0.0.1:8082"
+			opts.StaticResponses = tc.staticResponses
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			for methodName, wantHttpRule := range tc.wantHttpRules {
+				method, ok := s.Methods[methodName]
+				if !ok {
+					t.Fatalf("method %v should be generated", methodName)
+				}
+				if !method.SkipServiceControl || !method.IsGenerated || method.StaticResponse == nil {
+					t.Errorf("method %v should be generated with a static response and skip service control, got: %+v", methodName, method)
+				}
+				if len(method.HttpRule) != 1 {
+					t.Fatalf("method %v should have one http rule, got: %v", methodName, method.HttpRule)
+				}
+				if got := fmt.Sprintf("%s %s", method.HttpRule[0].HttpMethod, method.HttpRule[0].UriTemplate.String()); got != wantHttpRule {
+					t.Errorf("HttpRule mismatch, got: %v, want: %v", got, wantHttpRule)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessBackendRuleForRetry(t *testing.T) {
+	testData := []struct {
+		desc                          string
+		fakeServiceConfig             *confpb.Service
+		backendRetryOns               string
+		backendRetryNum               uint
+		backendPerTryTimeout          time.Duration
+		backendRetryOnStatusCode      string
+		wantBackendRetryOns           string
+		wantBackendRetryNum           uint
+		wantBackendPerTryTimeout      time.Duration
+		wantBackendRetryOnStatusCodes []uint32
+		wantError                     string
+	}{
+		{
+			desc: "pass backend retry parameters",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			},
+			backendRetryOns:          "foo,bar",
+			backendRetryNum:          5,
+			backendPerTryTimeout:     time.Second * 60,
+			wantBackendRetryOns:      "foo,bar",
+			wantBackendRetryNum:      5,
+			wantBackendPerTryTimeout: time.Second * 60,
+		},
+		{
+			desc: "invalid retriable status code in wrong format",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			},
+			backendRetryOns:          "",
+			backendRetryOnStatusCode: "invalid-status-code",
+			wantError:                "invalid http status code",
+		},
+		{
+			desc: "invalid retriable status code in wrong range",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			},
+			backendRetryOns:          "",
+			backendRetryOnStatusCode: "600",
+			wantError:                "invalid http status code",
+		},
+		{
+			desc: "set RetryOnStatusCodes and add `retriable-status-codes` to retryOns if it is empty",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			},
+			backendRetryOns:               "",
+			backendRetryOnStatusCode:      "500,501",
+			wantBackendRetryOns:           "retriable-status-codes",
+			wantBackendRetryOnStatusCodes: []uint32{500, 501},
+		},
+		{
+			desc: "set RetryOnStatusCodes and add `retriable-status-codes` to retryOns if it is un-empty but doesn't have `retriable-status-codes`",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			},
+			backendRetryOns:               "foo,bar",
+			backendRetryOnStatusCode:      "500,501",
+			wantBackendRetryOns:           "foo,bar,retriable-status-codes",
+			wantBackendRetryOnStatusCodes: []uint32{500, 501},
+		},
+		{
+			desc: "set RetryOnStatusCodes and no op on retryOns when it contains `retriable-status-codes`",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "api",
+							},
+						},
+					},
+				},
+			},
+			backendRetryOns:               "foo,bar,retriable-status-codes",
+			backendRetryOnStatusCode:      "500,501",
+			wantBackendRetryOns:           "foo,bar,retriable-status-codes",
+			wantBackendRetryOnStatusCodes: []uint32{500, 501},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendRetryOns = tc.backendRetryOns
+			opts.BackendRetryNum = tc.backendRetryNum
+			opts.BackendPerTryTimeout = tc.backendPerTryTimeout
+			opts.BackendRetryOnStatusCodes = tc.backendRetryOnStatusCode
+			s, err := NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("different error, want: %s, get: %v", tc.wantError, err)
+				}
+				return
+			}
+
+			for _, method := range s.Methods {
+				backendInfo := method.BackendInfo
+				if backendInfo.RetryOns != tc.wantBackendRetryOns {
+					t.Errorf("different RetryOns, want: %v, get: %v", tc.wantBackendRetryOns, backendInfo.RetryOns)
+				}
+				if backendInfo.RetryNum != tc.wantBackendRetryNum {
+					t.Errorf("different RetryNum, want: %v, get: %v", tc.wantBackendRetryNum, backendInfo.RetryNum)
+				}
+				if backendInfo.PerTryTimeout != tc.wantBackendPerTryTimeout {
+					t.Errorf("different PerTryTimeout, want: %v, get: %v", tc.wantBackendPerTryTimeout, backendInfo.PerTryTimeout)
+				}
+				if !reflect.DeepEqual(backendInfo.RetriableStatusCodes, tc.wantBackendRetryOnStatusCodes) {
+					t.Errorf("different RetriableStatusCodes, want: %v, get: %v", tc.wantBackendRetryOnStatusCodes, backendInfo.RetriableStatusCodes)
+				}
+			}
+		})
+	}
+}
+func TestBackendAddressOverride(t *testing.T) {
+	testData := []struct {
+		desc                         string
+		backendAddress               string
+		enableBackendAddressOverride bool
+		// Map of selector to the expected backend cluster.
+		wantedMethodBackendCluster map[string]string
+	}{
+		{
+			desc:                         "When disabled, backend.rule.address has priority",
+			backendAddress:               "http://127.0.0.1:9000",
+			enableBackendAddressOverride: false,
+			wantedMethodBackendCluster: map[string]string{
+				"abc.com.a": "backend-cluster-abc.com:80",
+				"abc.com.b": "backend-cluster-echo.endpoints_local",
+			},
+		},
+		{
+			desc:                         "When enabled, backend.rule.address losses priority",
+			backendAddress:               "http://127.0.0.1:9000",
+			enableBackendAddressOverride: true,
+			wantedMethodBackendCluster: map[string]string{
+				"abc.com.a": "backend-cluster-echo.endpoints_local",
+				"abc.com.b": "backend-cluster-echo.endpoints_local",
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+
+			fakeServiceConfig := &confpb.Service{
+				Name: "echo.endpoints",
+				Apis: []*apipb.Api{
+					{
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "a",
+							},
+							{
+								Name: "b",
+							},
+						},
+					},
+				},
+				Backend: &confpb.Backend{
+					Rules: []*confpb.BackendRule{
+						{
+							Address:  "grpc://abc.com/a/",
+							Selector: "abc.com.a",
+						},
+					},
+				},
+			}
+
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = tc.backendAddress
+			opts.EnableBackendAddressOverride = tc.enableBackendAddressOverride
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+
+			if err != nil {
+				t.Errorf("error not expected, got: %v", err)
+				return
+			}
+
+			for operation, mi := range s.Methods {
+				gotBackendCluster := mi.BackendInfo.ClusterName
+				wantBackendCluster := tc.wantedMethodBackendCluster[operation]
+
+				if gotBackendCluster != wantBackendCluster {
+					t.Errorf("Backend cluster name not expected, got: %v, want: %v", gotBackendCluster, wantBackendCluster)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessQuota(t *testing.T) {
+	testData := []struct {
+		desc              string
+		fakeServiceConfig *confpb.Service
+		wantMethods       map[string]*MethodInfo
+		wantError         string
+	}{
+		{
+			desc: "Succeed, simple case",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+						Methods: []*apipb.Method{
+							{
+								Name: "ListShelves",
+							},
+						},
+					},
+				},
+				Quota: &confpb.Quota{
+					MetricRules: []*confpb.MetricRule{
+						{
+							Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+							MetricCosts: map[string]int64{
+								"metric_a": 2,
+								"metric_b": 1,
+							},
+						},
+					},
+				},
+			},
+			wantMethods: map[string]*MethodInfo{
+				fmt.Sprintf("%s.%s", testApiName, "ListShelves"): &MethodInfo{
+					ShortName: "ListShelves",
+					ApiName:   testApiName,
+					HttpRule: []*httppattern.Pattern{
+						{
+							UriTemplate: parseUriTemplate(fmt.Sprintf("/%s/%s", testApiName, "ListShelves")),
+							HttpMethod:  util.POST,
+						},
+					},
+					MetricCosts: []*scpb.MetricCost{
+						{
+							Name: "metric_a",
+							Cost: 2,
+						},
+						{
+							Name: "metric_b",
+							Cost: 1,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "Succeed, two metric cost items",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+						Methods: []*apipb.Method{
+							{
+								Name: "ListShelves",
+							},
+						},
+					},
+				},
+				Quota: &confpb.Quota{
+					MetricRules: []*confpb.MetricRule{
+						{
+							Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+							MetricCosts: map[string]int64{
+								"metric_c": 2,
+								"metric_a": 3,
+							},
+						},
+					},
+				},
+			},
+			wantMethods: map[string]*MethodInfo{
+				fmt.Sprintf("%s.%s", testApiName, "ListShelves"): &MethodInfo{
+					ShortName: "ListShelves",
+					ApiName:   testApiName,
+					HttpRule: []*httppattern.Pattern{
+						{
+							UriTemplate: parseUriTemplate(fmt.Sprintf("/%s/%s", testApiName, "ListShelves")),
+							HttpMethod:  util.POST,
+						},
+					},
+					MetricCosts: []*scpb.MetricCost{
+						{
+							Name: "metric_a",
+							Cost: 3,
+						},
+						{
+							Name: "metric_c",
+							Cost: 2,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "Succeed, metric costs from multiple metric rules are merged",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+						Methods: []*apipb.Method{
+							{
+								Name: "ListShelves",
+							},
+						},
+					},
+				},
+				Quota: &confpb.Quota{
+					MetricRules: []*confpb.MetricRule{
+						{
 							Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
 							MetricCosts: map[string]int64{
-								"metric_a": 2,
-								"metric_b": 1,
+								"reads": 1,
+							},
+						},
+						{
+							Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+							MetricCosts: map[string]int64{
+								"compute_units": 5,
+								"reads":         2,
 							},
 						},
 					},
@@ -2992,207 +4720,727 @@ func TestProcessQuota(t *testing.T) {
 					},
 					MetricCosts: []*scpb.MetricCost{
 						{
-							Name: "metric_a",
-							Cost: 2,
+							Name: "compute_units",
+							Cost: 5,
 						},
 						{
-							Name: "metric_b",
-							Cost: 1,
+							Name: "reads",
+							Cost: 3,
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "Succeed, two metric cost items",
+			desc: "Typo in operation name does not crash",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+					},
+				},
+				Quota: &confpb.Quota{
+					MetricRules: []*confpb.MetricRule{
+						{
+							Selector: "endpoints.examples.bookstore.Bookstore.BadOperationName",
+							MetricCosts: map[string]int64{
+								"metric_a": 2,
+								"metric_b": 1,
+							},
+						},
+					},
+				},
+			},
+			wantError: "error processing quota metric rule: selector (endpoints.examples.bookstore.Bookstore.BadOperationName) was not defined in the API",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.BackendAddress = "grpc://127.0.0.1:80"
+			serviceInfo, err := NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
+
+			if err != nil {
+				if tc.wantError == "" || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("error mismatch, \ngot : %s, \nwant: %s", err.Error(), tc.wantError)
+				}
+				return
+			}
+
+			for key, gotMethod := range serviceInfo.Methods {
+				wantMethod := tc.wantMethods[key]
+
+				// We're not testing backend info here.
+				gotMethod.BackendInfo = nil
+
+				if eq := cmp.Equal(gotMethod, wantMethod, cmp.Comparer(proto.Equal)); !eq {
+					t.Errorf("Method mismatch \ngot : %+v,\nwant: %+v", gotMethod, wantMethod)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessEmptyJwksUriByOpenID(t *testing.T) {
+	r := mux.NewRouter()
+	jwksUriEntry, _ := json.Marshal(map[string]string{"jwks_uri": "this-is-jwksUri"})
+	r.Path(util.OpenIDDiscoveryCfgURLSuffix).Methods("GET").Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(jwksUriEntry)
+		}))
+	openIDServer := httptest.NewServer(r)
+
+	testData := []struct {
+		desc                 string
+		fakeServiceConfig    *confpb.Service
+		disableOidcDiscovery bool
+		wantedJwksUri        string
+		wantErr              bool
+	}{
+		{
+			desc: "Success, empty JWKS URI, so it's acquired using OpenID Connect Discovery.",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+					},
+				},
+				Authentication: &confpb.Authentication{
+					Providers: []*confpb.AuthProvider{
+						{
+							Id:     "auth_provider",
+							Issuer: openIDServer.URL,
+						},
+					},
+				},
+			},
+			wantedJwksUri: "this-is-jwksUri",
+		},
+		{
+			desc: "Fail, empty JWKS URI and Open ID Connect Discovery failed.",
+			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+					},
+				},
+				Authentication: &confpb.Authentication{
+					Providers: []*confpb.AuthProvider{
+						{
+							Id:     "auth_provider",
+							Issuer: "aaaaa.bbbbbb.ccccc/inaccessible_uri/",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Fail, empty JWKS URI but OpenID Connect Discovery disabled.",
 			fakeServiceConfig: &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+					},
+				},
+				Authentication: &confpb.Authentication{
+					Providers: []*confpb.AuthProvider{
+						{
+							Id:     "auth_provider",
+							Issuer: openIDServer.URL,
+						},
+					},
+				},
+			},
+			disableOidcDiscovery: true,
+			wantErr:              true,
+		},
+	}
+
+	for i, tc := range testData {
+		opts := options.DefaultConfigGeneratorOptions()
+		opts.DisableOidcDiscovery = tc.disableOidcDiscovery
+		serviceInfo, err := NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
+
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Test Desc(%d): %s, process jwksUri got: no err, but expected err", i, tc.desc)
+			}
+		} else if err != nil {
+			t.Errorf("Test Desc(%d): %s, process jwksUri got: %v, but expected no err", i, tc.desc, err)
+		} else if jwksUri := serviceInfo.serviceConfig.Authentication.Providers[0].JwksUri; jwksUri != tc.wantedJwksUri {
+			t.Errorf("Test Desc(%d): %s, process jwksUri got: %v, want: %v", i, tc.desc, jwksUri, tc.wantedJwksUri)
+		}
+	}
+}
+
+func TestProcessJwksWarmOnStartup(t *testing.T) {
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer jwksServer.Close()
+	slowJwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer slowJwksServer.Close()
+
+	testData := []struct {
+		desc              string
+		jwksUri           string
+		jwksWarmOnStartup string
+		wantError         string
+	}{
+		{
+			desc:    "Success, unreachable JWKS is not fetched by default",
+			jwksUri: "http://127.0.0.1:1/jwks",
+		},
+		{
+			desc:              "Success, reachable JWKS is fetched",
+			jwksUri:           jwksServer.URL,
+			jwksWarmOnStartup: "required",
+		},
+		{
+			desc:              "Success, unreachable JWKS is optional",
+			jwksUri:           "http://127.0.0.1:1/jwks",
+			jwksWarmOnStartup: "optional",
+		},
+		{
+			desc:              "Fail, unreachable JWKS is required",
+			jwksUri:           "http://127.0.0.1:1/jwks",
+			jwksWarmOnStartup: "required",
+			wantError:         "error warming jwks of authentication provider (auth_provider): Failed to fetch jwks from http://127.0.0.1:1/jwks",
+		},
+		{
+			desc:              "Fail, slow JWKS times out",
+			jwksUri:           slowJwksServer.URL,
+			jwksWarmOnStartup: "required",
+			wantError:         "error warming jwks of authentication provider (auth_provider): Failed to fetch jwks from " + slowJwksServer.URL,
+		},
+		{
+			desc:              "Fail, invalid value",
+			jwksUri:           jwksServer.URL,
+			jwksWarmOnStartup: "always",
+			wantError:         `invalid value "always" for --jwks_warm_on_startup, it should be "optional" or "required"`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Apis: []*apipb.Api{
+					{
+						Name: testApiName,
+					},
+				},
+				Authentication: &confpb.Authentication{
+					Providers: []*confpb.AuthProvider{
+						{
+							Id:      "auth_provider",
+							Issuer:  "issuer",
+							JwksUri: tc.jwksUri,
+						},
+					},
+				},
+			}
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.JwksWarmOnStartup = tc.jwksWarmOnStartup
+			opts.HttpRequestTimeout = 100 * time.Millisecond
+			_, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessJwtProviderAdditionalIssuers(t *testing.T) {
+	testData := []struct {
+		desc                         string
+		jwtProviderAdditionalIssuers string
+		wantProviders                []*confpb.AuthProvider
+		wantRequirements             []*confpb.AuthRequirement
+		wantBases                    map[string]string
+		wantError                    string
+	}{
+		{
+			desc: "Success, no additional issuers",
+			wantProviders: []*confpb.AuthProvider{
+				{
+					Id:      "auth_provider",
+					Issuer:  "issuer",
+					JwksUri: "https://issuer.com/jwks",
+				},
+			},
+			wantRequirements: []*confpb.AuthRequirement{
+				{
+					ProviderId: "auth_provider",
+					Audiences:  "audience",
+				},
+			},
+		},
+		{
+			desc:                         "Success, additional issuers are added to the provider and its requirements",
+			jwtProviderAdditionalIssuers: "auth_provider=issuer-1|https://issuer-1.com/jwks,auth_provider=issuer-2|https://issuer-2.com/jwks",
+			wantProviders: []*confpb.AuthProvider{
+				{
+					Id:      "auth_provider",
+					Issuer:  "issuer",
+					JwksUri: "https://issuer.com/jwks",
+				},
+				{
+					Id:      "auth_provider_issuer_1",
+					Issuer:  "issuer-1",
+					JwksUri: "https://issuer-1.com/jwks",
+				},
+				{
+					Id:      "auth_provider_issuer_2",
+					Issuer:  "issuer-2",
+					JwksUri: "https://issuer-2.com/jwks",
+				},
+			},
+			wantRequirements: []*confpb.AuthRequirement{
+				{
+					ProviderId: "auth_provider",
+					Audiences:  "audience",
+				},
+				{
+					ProviderId: "auth_provider_issuer_1",
+					Audiences:  "audience",
+				},
+				{
+					ProviderId: "auth_provider_issuer_2",
+					Audiences:  "audience",
+				},
+			},
+			wantBases: map[string]string{
+				"auth_provider_issuer_1": "auth_provider",
+				"auth_provider_issuer_2": "auth_provider",
+			},
+		},
+		{
+			desc:                         "Success, spaces around the entries are trimmed",
+			jwtProviderAdditionalIssuers: " auth_provider = issuer-1 | https://issuer-1.com/jwks , auth_provider=issuer-2|https://issuer-2.com/jwks ",
+			wantProviders: []*confpb.AuthProvider{
+				{
+					Id:      "auth_provider",
+					Issuer:  "issuer",
+					JwksUri: "https://issuer.com/jwks",
+				},
+				{
+					Id:      "auth_provider_issuer_1",
+					Issuer:  "issuer-1",
+					JwksUri: "https://issuer-1.com/jwks",
+				},
+				{
+					Id:      "auth_provider_issuer_2",
+					Issuer:  "issuer-2",
+					JwksUri: "https://issuer-2.com/jwks",
+				},
+			},
+			wantRequirements: []*confpb.AuthRequirement{
+				{
+					ProviderId: "auth_provider",
+					Audiences:  "audience",
+				},
+				{
+					ProviderId: "auth_provider_issuer_1",
+					Audiences:  "audience",
+				},
+				{
+					ProviderId: "auth_provider_issuer_2",
+					Audiences:  "audience",
+				},
+			},
+			wantBases: map[string]string{
+				"auth_provider_issuer_1": "auth_provider",
+				"auth_provider_issuer_2": "auth_provider",
+			},
+		},
+		{
+			desc:                         "Fail, malformed entry",
+			jwtProviderAdditionalIssuers: "auth_provider",
+			wantError:                    "invalid entry (auth_provider) in --jwt_provider_additional_issuers",
+		},
+		{
+			desc:                         "Fail, unknown provider",
+			jwtProviderAdditionalIssuers: "unknown_provider=issuer-1|https://issuer-1.com/jwks",
+			wantError:                    "additional issuer is set for provider (unknown_provider), which is not an authentication provider in the service config",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
 				Apis: []*apipb.Api{
 					{
 						Name: testApiName,
 						Methods: []*apipb.Method{
 							{
-								Name: "ListShelves",
+								Name: "Echo",
 							},
 						},
 					},
 				},
-				Quota: &confpb.Quota{
-					MetricRules: []*confpb.MetricRule{
+				Authentication: &confpb.Authentication{
+					Providers: []*confpb.AuthProvider{
 						{
-							Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
-							MetricCosts: map[string]int64{
-								"metric_c": 2,
-								"metric_a": 3,
-							},
+							Id:      "auth_provider",
+							Issuer:  "issuer",
+							JwksUri: "https://issuer.com/jwks",
 						},
 					},
-				},
-			},
-			wantMethods: map[string]*MethodInfo{
-				fmt.Sprintf("%s.%s", testApiName, "ListShelves"): &MethodInfo{
-					ShortName: "ListShelves",
-					ApiName:   testApiName,
-					HttpRule: []*httppattern.Pattern{
+					Rules: []*confpb.AuthenticationRule{
 						{
-							UriTemplate: parseUriTemplate(fmt.Sprintf("/%s/%s", testApiName, "ListShelves")),
-							HttpMethod:  util.POST,
+							Selector: fmt.Sprintf("%s.Echo", testApiName),
+							Requirements: []*confpb.AuthRequirement{
+								{
+									ProviderId: "auth_provider",
+									Audiences:  "audience",
+								},
+							},
 						},
 					},
-					MetricCosts: []*scpb.MetricCost{
-						{
-							Name: "metric_a",
-							Cost: 3,
-						},
-						{
-							Name: "metric_c",
-							Cost: 2,
-						},
+				},
+			}
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.JwtProviderAdditionalIssuers = tc.jwtProviderAdditionalIssuers
+			serviceInfo, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, got: %v", err)
+			}
+
+			authn := serviceInfo.ServiceConfig().GetAuthentication()
+			if diff := cmp.Diff(tc.wantProviders, authn.GetProviders(), protocmp.Transform()); diff != "" {
+				t.Errorf("providers diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantRequirements, authn.GetRules()[0].GetRequirements(), protocmp.Transform()); diff != "" {
+				t.Errorf("requirements diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantBases, serviceInfo.JwtProviderAdditionalIssuerBases); diff != "" {
+				t.Errorf("additional issuer bases diff (-want +got):\n%s", diff)
+			}
+			if len(fakeServiceConfig.GetAuthentication().GetProviders()) != 1 {
+				t.Errorf("the input service config should not be modified, got providers: %v", fakeServiceConfig.GetAuthentication().GetProviders())
+			}
+		})
+	}
+}
+
+func TestProcessMaxRequestBytes(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
+					{
+						Name: "CreateShelf",
+					},
+					{
+						Name: "UploadBook",
 					},
 				},
 			},
 		},
+	}
+
+	testData := []struct {
+		desc                  string
+		maxRequestBytes       string
+		passthroughOperations string
+		wantMaxRequestBytes   map[string]uint32
+		wantError             string
+	}{
 		{
-			desc: "Typo in operation name does not crash",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
+			desc:            "Each operation gets its own limit",
+			maxRequestBytes: "abc.com.CreateShelf=1048576, abc.com.UploadBook=52428800",
+			wantMaxRequestBytes: map[string]uint32{
+				"abc.com.CreateShelf": 1048576,
+				"abc.com.UploadBook":  52428800,
+			},
+		},
+		{
+			desc:            "Invalid format",
+			maxRequestBytes: "abc.com.CreateShelf",
+			wantError:       `invalid max request bytes "abc.com.CreateShelf", it should be in the format selector=bytes`,
+		},
+		{
+			desc:            "Limit is not a positive integer",
+			maxRequestBytes: "abc.com.CreateShelf=0",
+			wantError:       `error processing max request bytes for operation (abc.com.CreateShelf): "0" is not a positive 32-bit integer`,
+		},
+		{
+			desc:                  "Streaming passthrough operations cannot be limited",
+			maxRequestBytes:       "abc.com.UploadBook=52428800",
+			passthroughOperations: "abc.com.UploadBook",
+			wantError:             "error processing max request bytes for operation (abc.com.UploadBook): streaming passthrough operations are not buffered",
+		},
+		{
+			desc:                  "Other operations are limited along with streaming passthrough operations",
+			maxRequestBytes:       "abc.com.CreateShelf=1048576",
+			passthroughOperations: "abc.com.UploadBook",
+			wantMaxRequestBytes: map[string]uint32{
+				"abc.com.CreateShelf": 1048576,
+				"abc.com.UploadBook":  0,
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.MaxRequestBytesByOperation = tc.maxRequestBytes
+			opts.StreamingPassthroughOperations = tc.passthroughOperations
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for operation, want := range tc.wantMaxRequestBytes {
+				if got := s.Methods[operation].BackendInfo.MaxRequestBytes; got != want {
+					t.Errorf("operation %v: got MaxRequestBytes %v, want %v", operation, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessQueryParameterAllowlists(t *testing.T) {
+	fakeServiceConfig := &confpb.Service{
+		Name: testProjectName,
+		Apis: []*apipb.Api{
+			{
+				Name: "abc.com",
+				Methods: []*apipb.Method{
 					{
-						Name: testApiName,
+						Name: "ListShelves",
 					},
-				},
-				Quota: &confpb.Quota{
-					MetricRules: []*confpb.MetricRule{
-						{
-							Selector: "endpoints.examples.bookstore.Bookstore.BadOperationName",
-							MetricCosts: map[string]int64{
-								"metric_a": 2,
-								"metric_b": 1,
-							},
-						},
+					{
+						Name: "ListBooks",
 					},
 				},
 			},
-			wantError: "error processing quota metric rule: selector (endpoints.examples.bookstore.Bookstore.BadOperationName) was not defined in the API",
+		},
+	}
+
+	testData := []struct {
+		desc                       string
+		allowlist                  string
+		wantAllowedQueryParameters map[string][]string
+		wantError                  string
+	}{
+		{
+			desc:      "Each operation gets its own allowlist, with the API key query parameters",
+			allowlist: "abc.com.ListShelves=page_size, abc.com.ListBooks=page_size|filter|key,",
+			wantAllowedQueryParameters: map[string][]string{
+				"abc.com.ListShelves": {"page_size", "api_key", "key"},
+				"abc.com.ListBooks":   {"page_size", "filter", "key", "api_key"},
+			},
+		},
+		{
+			desc:      "Invalid format",
+			allowlist: "abc.com.ListShelves",
+			wantError: `invalid query parameter allowlist "abc.com.ListShelves", it should be in the format selector=param1|param2`,
+		},
+		{
+			desc:      "Empty query parameter",
+			allowlist: "abc.com.ListShelves=page_size||filter",
+			wantError: `error processing query parameter allowlist for operation (abc.com.ListShelves): empty query parameter in "page_size||filter"`,
+		},
+		{
+			desc:      "Unknown operation",
+			allowlist: "abc.com.Unknown=page_size",
+			wantError: `error processing query parameter allowlist for operation (abc.com.Unknown): selector (abc.com.Unknown) was not defined in the API`,
 		},
 	}
 
 	for _, tc := range testData {
 		t.Run(tc.desc, func(t *testing.T) {
 			opts := options.DefaultConfigGeneratorOptions()
-			opts.BackendAddress = "grpc://127.0.0.1:80"
-			serviceInfo, err := NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
-
-			if err != nil {
-				if tc.wantError == "" || !strings.Contains(err.Error(), tc.wantError) {
-					t.Fatalf("error mismatch, \ngot : %s, \nwant: %s", err.Error(), tc.wantError)
+			opts.QueryParameterAllowlistByOperation = tc.allowlist
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
 				}
 				return
 			}
-
-			for key, gotMethod := range serviceInfo.Methods {
-				wantMethod := tc.wantMethods[key]
-
-				// We're not testing backend info here.
-				gotMethod.BackendInfo = nil
-
-				sort.Slice(gotMethod.MetricCosts, func(i, j int) bool { return gotMethod.MetricCosts[i].Name < gotMethod.MetricCosts[j].Name })
-				if eq := cmp.Equal(gotMethod, wantMethod, cmp.Comparer(proto.Equal)); !eq {
-					t.Errorf("Method mismatch \ngot : %+v,\nwant: %+v", gotMethod, wantMethod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for operation, want := range tc.wantAllowedQueryParameters {
+				if got := s.Methods[operation].AllowedQueryParameters; !reflect.DeepEqual(got, want) {
+					t.Errorf("operation %v: got AllowedQueryParameters %v, want %v", operation, got, want)
 				}
 			}
 		})
 	}
 }
 
-func TestProcessEmptyJwksUriByOpenID(t *testing.T) {
-	r := mux.NewRouter()
-	jwksUriEntry, _ := json.Marshal(map[string]string{"jwks_uri": "this-is-jwksUri"})
-	r.Path(util.OpenIDDiscoveryCfgURLSuffix).Methods("GET").Handler(
-		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Write(jwksUriEntry)
-		}))
-	openIDServer := httptest.NewServer(r)
-
+func TestProcessRequiredRequestHeaders(t *testing.T) {
 	testData := []struct {
-		desc                 string
-		fakeServiceConfig    *confpb.Service
-		disableOidcDiscovery bool
-		wantedJwksUri        string
-		wantErr              bool
+		desc                string
+		requireHeaders      string
+		wantRequiredHeaders []string
+		wantError           string
 	}{
 		{
-			desc: "Success, empty JWKS URI, so it's acquired using OpenID Connect Discovery.",
-			fakeServiceConfig: &confpb.Service{
+			desc:                "Headers are trimmed",
+			requireHeaders:      "X-Correlation-Id, X-Tenant",
+			wantRequiredHeaders: []string{"X-Correlation-Id", "X-Tenant"},
+		},
+		{
+			desc:           "Invalid header name",
+			requireHeaders: "X-Correlation-Id,X Tenant",
+			wantError:      `invalid header "X Tenant" in --require_request_headers`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.RequireRequestHeaders = tc.requireHeaders
+			s, err := NewServiceInfoFromServiceConfig(&confpb.Service{
+				Name: testProjectName,
 				Apis: []*apipb.Api{
 					{
-						Name: testApiName,
+						Name: "abc.com",
 					},
 				},
-				Authentication: &confpb.Authentication{
-					Providers: []*confpb.AuthProvider{
+			}, testConfigID, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s.RequiredRequestHeaders, tc.wantRequiredHeaders) {
+				t.Errorf("got RequiredRequestHeaders %v, want %v", s.RequiredRequestHeaders, tc.wantRequiredHeaders)
+			}
+		})
+	}
+}
+
+func TestProcessAuthRequirement(t *testing.T) {
+	testData := []struct {
+		desc            string
+		rules           []*confpb.AuthenticationRule
+		wantRequireAuth map[string]bool
+		wantError       string
+	}{
+		{
+			desc: "Operations with requirements of defined providers require auth",
+			rules: []*confpb.AuthenticationRule{
+				{
+					Selector: "abc.com.CreateShelf",
+					Requirements: []*confpb.AuthRequirement{
 						{
-							Id:     "auth_provider",
-							Issuer: openIDServer.URL,
+							ProviderId: "auth_provider",
 						},
 					},
 				},
 			},
-			wantedJwksUri: "this-is-jwksUri",
+			wantRequireAuth: map[string]bool{
+				"abc.com.ListShelves": false,
+				"abc.com.CreateShelf": true,
+			},
 		},
 		{
-			desc: "Fail, empty JWKS URI and Open ID Connect Discovery failed.",
-			fakeServiceConfig: &confpb.Service{
-				Apis: []*apipb.Api{
-					{
-						Name: testApiName,
+			desc: "Every undefined provider is reported with its operation",
+			rules: []*confpb.AuthenticationRule{
+				{
+					Selector: "abc.com.ListShelves",
+					Requirements: []*confpb.AuthRequirement{
+						{
+							ProviderId: "missing_provider",
+						},
 					},
 				},
-				Authentication: &confpb.Authentication{
-					Providers: []*confpb.AuthProvider{
+				{
+					Selector: "abc.com.CreateShelf",
+					Requirements: []*confpb.AuthRequirement{
 						{
-							Id:     "auth_provider",
-							Issuer: "aaaaa.bbbbbb.ccccc/inaccessible_uri/",
+							ProviderId: "auth_provider",
+						},
+						{
+							ProviderId: "other_missing_provider",
 						},
 					},
 				},
 			},
-			wantErr: true,
+			wantError: "error processing authentication rules, providers are not defined in Authentication.providers: " +
+				"operation (abc.com.ListShelves) requires provider (missing_provider); " +
+				"operation (abc.com.CreateShelf) requires provider (other_missing_provider)",
 		},
-		{
-			desc: "Fail, empty JWKS URI but OpenID Connect Discovery disabled.",
-			fakeServiceConfig: &confpb.Service{
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeServiceConfig := &confpb.Service{
+				Name: testProjectName,
 				Apis: []*apipb.Api{
 					{
-						Name: testApiName,
+						Name: "abc.com",
+						Methods: []*apipb.Method{
+							{
+								Name: "ListShelves",
+							},
+							{
+								Name: "CreateShelf",
+							},
+						},
 					},
 				},
 				Authentication: &confpb.Authentication{
 					Providers: []*confpb.AuthProvider{
 						{
-							Id:     "auth_provider",
-							Issuer: openIDServer.URL,
+							Id:      "auth_provider",
+							Issuer:  "issuer",
+							JwksUri: "https://issuer.example.com/jwks",
 						},
 					},
+					Rules: tc.rules,
 				},
-			},
-			disableOidcDiscovery: true,
-			wantErr:              true,
-		},
-	}
-
-	for i, tc := range testData {
-		opts := options.DefaultConfigGeneratorOptions()
-		opts.DisableOidcDiscovery = tc.disableOidcDiscovery
-		serviceInfo, err := NewServiceInfoFromServiceConfig(tc.fakeServiceConfig, testConfigID, opts)
+			}
 
-		if tc.wantErr {
-			if err == nil {
-				t.Errorf("Test Desc(%d): %s, process jwksUri got: no err, but expected err", i, tc.desc)
+			s, err := NewServiceInfoFromServiceConfig(fakeServiceConfig, testConfigID, options.DefaultConfigGeneratorOptions())
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
 			}
-		} else if err != nil {
-			t.Errorf("Test Desc(%d): %s, process jwksUri got: %v, but expected no err", i, tc.desc, err)
-		} else if jwksUri := serviceInfo.serviceConfig.Authentication.Providers[0].JwksUri; jwksUri != tc.wantedJwksUri {
-			t.Errorf("Test Desc(%d): %s, process jwksUri got: %v, want: %v", i, tc.desc, jwksUri, tc.wantedJwksUri)
-		}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for operation, want := range tc.wantRequireAuth {
+				if got := s.Methods[operation].RequireAuth; got != want {
+					t.Errorf("operation %v: got RequireAuth %v, want %v", operation, got, want)
+				}
+			}
+		})
 	}
 }
 
@@ -22,8 +22,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/bootstrap"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/configinfo"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/metadata"
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/options"
@@ -32,10 +38,15 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 
 	gen "github.com/GoogleCloudPlatform/esp-v2/src/go/configgenerator"
 	sc "github.com/GoogleCloudPlatform/esp-v2/src/go/serviceconfig"
+	bootstrappb "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	clusterpb "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerpb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	discoverypb "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 )
 
@@ -51,6 +62,17 @@ var (
 					GCP metadata server will not be called to fetch access token, and
 					following flags will be ignored; --service_config_id, --service,
 					--rollout_strategy`)
+	ConfigDumpPath = flag.String("config_dump_path", "", `file path to write the generated Envoy config to, as a bootstrap
+					config in JSON with the listeners and clusters as static resources. It is
+					rewritten on each service config update. Used for debugging.`)
+	ConfigSwapGracePeriod = flag.Duration("config_swap_grace_period", 0, `the period after a new service config
+					is applied during which the previous config is kept. If Envoy rejects the new config within the period,
+					the previous config is restored and the new config is not applied again. Disabled by default.`)
+	AdditionalServices = flag.String("additional_services", "", `additional services served on their own listeners, as
+					comma-separated pairs of service_json_path=listener_port. Each service config is only read from its file, once
+					at startup. It is neither fetched from Service Management nor rolled out, and changes to the file take effect
+					after a restart, while its Envoy config is regenerated with each update of the main service.
+					The services share all other flags, such as --backend_address, with the main service.`)
 )
 
 // Config Manager handles service configuration fetching and updating.
@@ -58,25 +80,82 @@ var (
 type ConfigManager struct {
 	serviceName        string
 	envoyConfigOptions options.ConfigGeneratorOptions
-	serviceInfo        *configinfo.ServiceInfo
 	cache              cache.SnapshotCache
 
 	metadataFetcher         *metadata.MetadataFetcher
 	serviceConfigFetcher    *sc.ServiceConfigFetcher
 	rolloutIdChangeDetector *sc.RolloutIdChangeDetector
 
+	// mu guards the service configs and snapshots below, which are updated by
+	// the rollout checks and by the xDS streams.
+	mu               sync.Mutex
 	curServiceConfig *confpb.Service
+	curSnapshot      *cache.Snapshot
+
+	// The service config and snapshot replaced by the last config swap or
+	// reload of --auth_bypass_file, kept until prevDeadline to roll back to if
+	// Envoy rejects the new snapshot. After a reload, prevServiceConfig is the
+	// current service config.
+	prevServiceConfig *confpb.Service
+	prevSnapshot      *cache.Snapshot
+	prevAuthBypass    map[string]bool
+	prevDeadline      time.Time
+
+	// Ids of the service configs rejected by Envoy, which are not applied again.
+	// It is cleared when another service config is applied.
+	rejectedConfigIds map[string]bool
+
+	// The snapshot version of each xDS response sent to Envoy and not yet
+	// acknowledged, by stream id and response nonce.
+	pushedVersions map[int64]map[string]string
+
+	// The services set by --additional_services, whose clusters and listeners
+	// are made with each snapshot of the main service.
+	additionalServiceInfos []*configinfo.ServiceInfo
+
+	// The content of --client_crl_file last written to the SDS file of
+	// --client_crl_sds_path.
+	clientCrl []byte
+
+	// The operations of --auth_bypass_file whose authentication rules are
+	// removed, and the number of times the file has been reloaded for the
+	// current service config. The map is replaced, never modified.
+	authBypass        map[string]bool
+	authBypassReloads int
+
+	// done is closed to stop checking the files above for changes.
+	done chan struct{}
 }
 
 // NewConfigManager creates new instance of Config Manager.
 // mf is set to nil on non-gcp deployments
 func NewConfigManager(mf *metadata.MetadataFetcher, opts options.ConfigGeneratorOptions) (*ConfigManager, error) {
+	backendAddress, err := expandEnvVars(opts.BackendAddress)
+	if err != nil {
+		return nil, fmt.Errorf("fail to resolve --backend_address: %v", err)
+	}
+	opts.BackendAddress = backendAddress
+	if err := gen.ResolveClientCrlSdsPath(&opts); err != nil {
+		return nil, err
+	}
+
 	m := &ConfigManager{
 		metadataFetcher:    mf,
 		envoyConfigOptions: opts,
+		rejectedConfigIds:  make(map[string]bool),
+		pushedVersions:     make(map[int64]map[string]string),
+		done:               make(chan struct{}),
 	}
 	m.cache = cache.NewSnapshotCache(true, m, m)
 
+	if err := m.loadAdditionalServices(*AdditionalServices); err != nil {
+		return nil, err
+	}
+	if err := m.watchClientCrl(m.envoyConfigOptions.ClientCrlReloadInterval); err != nil {
+		return nil, err
+	}
+	m.watchAuthBypass(m.envoyConfigOptions.AuthBypassFile, m.envoyConfigOptions.AuthBypassReloadInterval)
+
 	// If service config is provided as a file, just use it and disable managed rollout
 	if *ServicePath != "" {
 		// Following flags will not be used
@@ -100,7 +179,6 @@ func NewConfigManager(mf *metadata.MetadataFetcher, opts options.ConfigGenerator
 
 	m.serviceName = *ServiceName
 	checkMetadata := *CheckMetadata
-	var err error
 
 	if m.serviceName == "" && checkMetadata && mf != nil {
 		m.serviceName, err = mf.FetchServiceName()
@@ -200,6 +278,9 @@ func (m *ConfigManager) fetchAndApplyServiceConfig(latestConfigId string) error
 		glog.Infof("no new configuration to load for service %v, current configuration Id %v", m.serviceName, m.curConfigId())
 		return nil
 	}
+	if m.isRejected(latestConfigId) {
+		return fmt.Errorf("service config (%v) was rejected by Envoy, keep serving service config (%v)", latestConfigId, m.curConfigId())
+	}
 
 	serviceConfig, err := m.serviceConfigFetcher.FetchConfig(latestConfigId)
 	if err != nil {
@@ -210,18 +291,124 @@ func (m *ConfigManager) fetchAndApplyServiceConfig(latestConfigId string) error
 }
 
 func (m *ConfigManager) readAndApplyServiceConfig(servicePath string) error {
+	serviceConfig, err := readServiceConfig(servicePath)
+	if err != nil {
+		return err
+	}
+
+	m.serviceName = serviceConfig.GetName()
+	return m.applyServiceConfig(serviceConfig)
+}
+
+func readServiceConfig(servicePath string) (*confpb.Service, error) {
 	config, err := ioutil.ReadFile(servicePath)
 	if err != nil {
-		return fmt.Errorf("fail to read service config file: %s, error: %s", servicePath, err)
+		return nil, fmt.Errorf("fail to read service config file: %s, error: %s", servicePath, err)
 	}
 
 	serviceConfig, err := util.UnmarshalServiceConfig(bytes.NewReader(config))
 	if err != nil {
-		return fmt.Errorf("fail to unmarshal service config: %v, error: %s", config, err)
+		return nil, fmt.Errorf("fail to unmarshal service config: %v, error: %s", config, err)
 	}
+	return serviceConfig, nil
+}
 
-	m.serviceName = serviceConfig.GetName()
-	return m.applyServiceConfig(serviceConfig)
+// loadAdditionalServices makes the clusters and listeners of the additional
+// services, each served on its own listener port.
+func (m *ConfigManager) loadAdditionalServices(additionalServices string) error {
+	if additionalServices == "" {
+		return nil
+	}
+
+	listenerPorts := map[int]bool{
+		m.envoyConfigOptions.ListenerPort: true,
+	}
+	for _, pair := range strings.Split(additionalServices, ",") {
+		kv := strings.Split(strings.TrimSpace(pair), "=")
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid additional service %q, it should be in the format service_json_path=listener_port", pair)
+		}
+		port, err := strconv.Atoi(kv[1])
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid listener port of additional service %q, it should be in the range (0, 65535]", pair)
+		}
+		if listenerPorts[port] {
+			return fmt.Errorf("listener port of additional service %q is already used by another service", pair)
+		}
+		listenerPorts[port] = true
+
+		serviceConfig, err := readServiceConfig(kv[0])
+		if err != nil {
+			return err
+		}
+
+		opts := m.envoyConfigOptions
+		opts.ListenerPort = port
+		serviceInfo, err := configinfo.NewServiceInfoFromServiceConfig(serviceConfig, serviceConfig.Id, opts)
+		if err != nil {
+			return fmt.Errorf("fail to initialize ServiceInfo of additional service (%v), %s", serviceConfig.GetName(), err)
+		}
+		if m.metadataFetcher != nil {
+			if attrs, err := m.metadataFetcher.FetchGCPAttributes(); err == nil {
+				serviceInfo.GcpAttributes = attrs
+			}
+		}
+
+		m.additionalServiceInfos = append(m.additionalServiceInfos, serviceInfo)
+		glog.Infof("additional service (%v) with configuration id (%v) is served on listener port %v", serviceConfig.GetName(), serviceConfig.GetId(), port)
+	}
+
+	// Fail at startup, rather than on each snapshot, if the services can't be
+	// served together.
+	_, _, err := m.makeAdditionalResources()
+	return err
+}
+
+// makeAdditionalResources makes the clusters and listeners of the services set
+// by --additional_services.
+func (m *ConfigManager) makeAdditionalResources() ([]*clusterpb.Cluster, []*listenerpb.Listener, error) {
+	var additionalClusters []*clusterpb.Cluster
+	var additionalListeners []*listenerpb.Listener
+	for _, serviceInfo := range m.additionalServiceInfos {
+		clusters, err := gen.MakeClusters(serviceInfo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fail to make clusters of additional service (%v), %s", serviceInfo.Name, err)
+		}
+		additionalClusters, err = mergeClusters(additionalClusters, clusters)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		listeners, err := gen.MakeListeners(serviceInfo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fail to make listeners of additional service (%v), %s", serviceInfo.Name, err)
+		}
+		for _, lis := range listeners {
+			lis.Name = fmt.Sprintf("%s_%d", lis.GetName(), serviceInfo.Options.ListenerPort)
+			additionalListeners = append(additionalListeners, lis)
+		}
+	}
+	return additionalClusters, additionalListeners, nil
+}
+
+// mergeClusters adds the clusters to the existing ones. The services share
+// the clusters of the same name, which must be identical.
+func mergeClusters(existing, clusters []*clusterpb.Cluster) ([]*clusterpb.Cluster, error) {
+	byName := make(map[string]*clusterpb.Cluster)
+	for _, cluster := range existing {
+		byName[cluster.GetName()] = cluster
+	}
+	for _, cluster := range clusters {
+		if c, ok := byName[cluster.GetName()]; ok {
+			if !proto.Equal(c, cluster) {
+				return nil, fmt.Errorf("cluster (%v) differs between services, such as when they use different service control environments", cluster.GetName())
+			}
+			continue
+		}
+		byName[cluster.GetName()] = cluster
+		existing = append(existing, cluster)
+	}
+	return existing, nil
 }
 
 func (m *ConfigManager) applyServiceConfig(serviceConfig *confpb.Service) error {
@@ -229,11 +416,55 @@ func (m *ConfigManager) applyServiceConfig(serviceConfig *confpb.Service) error
 		return fmt.Errorf("applid service config is empty")
 	}
 
-	var err error
+	m.mu.Lock()
+	authBypass := m.authBypass
+	m.mu.Unlock()
+	if unknown := unknownOperations(serviceConfig, authBypass); len(unknown) > 0 {
+		glog.Errorf("flag --auth_bypass_file lists operations not in service config (%v), no operation is bypassed: %v", serviceConfig.GetId(), unknown)
+		authBypass = nil
+	}
+
+	// The current config keeps being served until the new one is made and
+	// validated successfully.
+	snapshot, err := m.makeServiceSnapshot(serviceConfig, serviceConfig.Id, authBypass)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.curSnapshot != nil && *ConfigSwapGracePeriod > 0 {
+		m.prevServiceConfig = m.curServiceConfig
+		m.prevSnapshot = m.curSnapshot
+		m.prevAuthBypass = m.authBypass
+		m.prevDeadline = time.Now().Add(*ConfigSwapGracePeriod)
+	} else {
+		m.prevServiceConfig = nil
+		m.prevSnapshot = nil
+		m.prevAuthBypass = nil
+	}
+	if m.curSnapshot == nil {
+		logAuthBypass(nil, authBypass)
+	} else {
+		logAuthBypass(m.authBypass, authBypass)
+	}
 	m.curServiceConfig = serviceConfig
-	m.serviceInfo, err = configinfo.NewServiceInfoFromServiceConfig(serviceConfig, serviceConfig.Id, m.envoyConfigOptions)
+	m.curSnapshot = snapshot
+	m.rejectedConfigIds = make(map[string]bool)
+	// A bypass reloaded in the meantime is not in the snapshot, and is applied
+	// again on the next interval.
+	m.authBypass = authBypass
+	m.authBypassReloads = 0
+	return m.cache.SetSnapshot(m.envoyConfigOptions.Node, *snapshot)
+}
+
+// makeServiceSnapshot makes the snapshot of the service config with the given
+// version, without the authentication rules of the operations in authBypass.
+func (m *ConfigManager) makeServiceSnapshot(serviceConfig *confpb.Service, version string, authBypass map[string]bool) (*cache.Snapshot, error) {
+	serviceConfig = withoutAuthentication(serviceConfig, authBypass)
+	serviceInfo, err := configinfo.NewServiceInfoFromServiceConfig(serviceConfig, serviceConfig.Id, m.envoyConfigOptions)
 	if err != nil {
-		return fmt.Errorf("fail to initialize ServiceInfo, %s", err)
+		return nil, fmt.Errorf("fail to initialize ServiceInfo, %s", err)
 	}
 
 	if m.metadataFetcher != nil {
@@ -241,48 +472,438 @@ func (m *ConfigManager) applyServiceConfig(serviceConfig *confpb.Service) error
 		if err != nil {
 			m.Infof("metadata server was not reached, skipping GCP Attributes: %v", err)
 		} else {
-			m.serviceInfo.GcpAttributes = attrs
+			serviceInfo.GcpAttributes = attrs
 		}
 	}
 
-	snapshot, err := m.makeSnapshot()
+	snapshot, err := m.makeSnapshot(serviceInfo, version)
 	if err != nil {
-		return fmt.Errorf("fail to make a snapshot, %s", err)
+		return nil, fmt.Errorf("fail to make a snapshot, %s", err)
 	}
+	return snapshot, nil
+}
+
+// watchClientCrl writes the CRL of --client_crl_file to the SDS file before
+// the first snapshot is made, and checks it for changes at each interval. Envoy
+// reloads the SDS file on its own, so the listeners are not drained.
+func (m *ConfigManager) watchClientCrl(interval time.Duration) error {
+	if m.envoyConfigOptions.ClientCrlFile == "" {
+		return nil
+	}
+	if err := m.reloadClientCrl(); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+	m.runEvery(interval, func() {
+		if err := m.reloadClientCrl(); err != nil {
+			glog.Errorf("fail to reload the CRL, keeping the previous one: %v", err)
+		}
+	})
+	return nil
+}
+
+// reloadClientCrl rewrites the SDS file of --client_crl_sds_path if the
+// content of --client_crl_file has changed.
+func (m *ConfigManager) reloadClientCrl() error {
+	crl, err := gen.ReadClientCrl(m.envoyConfigOptions.ClientCrlFile)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(crl, m.clientCrl) {
+		return nil
+	}
+	if err := gen.WriteClientCrlSdsFile(m.envoyConfigOptions.ClientCrlSdsPath, crl); err != nil {
+		return err
+	}
+	if m.clientCrl != nil {
+		glog.Infof("reloaded the CRL of --client_crl_file")
+	}
+	m.clientCrl = crl
+	return nil
+}
+
+// runEvery calls f at each interval until the config manager is closed.
+func (m *ConfigManager) runEvery(interval time.Duration, f func()) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops checking the files of the config manager for changes.
+func (m *ConfigManager) Close() {
+	close(m.done)
+}
+
+// watchAuthBypass reads --auth_bypass_file before the first snapshot is made,
+// which validates it, and checks it for changes at each interval.
+func (m *ConfigManager) watchAuthBypass(path string, interval time.Duration) {
+	if path == "" {
+		return
+	}
+	authBypass, err := readAuthBypass(path)
+	if err != nil {
+		glog.Errorf("fail to read the auth bypass, no operation is bypassed: %v", err)
+	}
+	m.authBypass = authBypass
+	if interval <= 0 {
+		return
+	}
+	m.runEvery(interval, func() {
+		if err := m.reloadAuthBypass(path); err != nil {
+			glog.Errorf("fail to reload the auth bypass, keeping the previous one: %v", err)
+		}
+	})
+}
+
+// reloadAuthBypass remakes the snapshot of the current service config if the
+// operations listed in the file of --auth_bypass_file have changed.
+func (m *ConfigManager) reloadAuthBypass(path string) error {
+	authBypass, err := readAuthBypass(path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	serviceConfig := m.curServiceConfig
+	prevAuthBypass := m.authBypass
+	version := fmt.Sprintf("%v-auth%d", serviceConfig.GetId(), m.authBypassReloads+1)
+	m.mu.Unlock()
+	if sameOperations(authBypass, prevAuthBypass) || serviceConfig == nil {
+		return nil
+	}
+	if unknown := unknownOperations(serviceConfig, authBypass); len(unknown) > 0 {
+		return fmt.Errorf("flag --auth_bypass_file lists operations not in service config (%v): %v", serviceConfig.GetId(), unknown)
+	}
+
+	snapshot, err := m.makeServiceSnapshot(serviceConfig, version, authBypass)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A service config applied in the meantime is checked again on the next
+	// interval.
+	if m.curServiceConfig != serviceConfig || !sameOperations(m.authBypass, prevAuthBypass) {
+		return nil
+	}
+	// A rejected reload rolls back to the current snapshot, without rejecting
+	// the service config.
+	if *ConfigSwapGracePeriod > 0 {
+		m.prevServiceConfig = serviceConfig
+		m.prevSnapshot = m.curSnapshot
+		m.prevAuthBypass = prevAuthBypass
+		m.prevDeadline = time.Now().Add(*ConfigSwapGracePeriod)
+	} else {
+		m.prevServiceConfig = nil
+		m.prevSnapshot = nil
+		m.prevAuthBypass = nil
+	}
+	logAuthBypass(prevAuthBypass, authBypass)
+	m.authBypass = authBypass
+	m.authBypassReloads++
+	m.curSnapshot = snapshot
 	return m.cache.SetSnapshot(m.envoyConfigOptions.Node, *snapshot)
 }
 
-func (m *ConfigManager) makeSnapshot() (*cache.Snapshot, error) {
-	m.Infof("making configuration for api: %v", m.serviceInfo.Name)
+// readAuthBypass reads the operation selectors of the file, skipping blank
+// lines and comments starting with "#". A missing file lists no operation,
+// so removing it reverts the bypass.
+func readAuthBypass(path string) (map[string]bool, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read flag --auth_bypass_file: %v", err)
+	}
+	authBypass := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		selector := strings.TrimSpace(line)
+		if selector == "" || strings.HasPrefix(selector, "#") {
+			continue
+		}
+		authBypass[selector] = true
+	}
+	return authBypass, nil
+}
+
+// logAuthBypass logs the operations whose authentication is bypassed or
+// restored, as an audit trail of the changes to --auth_bypass_file.
+func logAuthBypass(prev, cur map[string]bool) {
+	var bypassed, restored []string
+	for selector := range cur {
+		if !prev[selector] {
+			bypassed = append(bypassed, selector)
+		}
+	}
+	for selector := range prev {
+		if !cur[selector] {
+			restored = append(restored, selector)
+		}
+	}
+	sort.Strings(bypassed)
+	sort.Strings(restored)
+	if len(bypassed) > 0 {
+		glog.Warningf("authentication is bypassed by --auth_bypass_file for operations: %v", bypassed)
+	}
+	if len(restored) > 0 {
+		glog.Warningf("authentication is restored by --auth_bypass_file for operations: %v", restored)
+	}
+}
+
+func sameOperations(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for selector := range a {
+		if !b[selector] {
+			return false
+		}
+	}
+	return true
+}
+
+// unknownOperations returns the sorted operations of authBypass that are not
+// methods of the APIs of the service config.
+func unknownOperations(serviceConfig *confpb.Service, authBypass map[string]bool) []string {
+	operations := make(map[string]bool)
+	for _, api := range serviceConfig.GetApis() {
+		for _, method := range api.GetMethods() {
+			operations[fmt.Sprintf("%s.%s", api.GetName(), method.GetName())] = true
+		}
+	}
+	var unknown []string
+	for selector := range authBypass {
+		if !operations[selector] {
+			unknown = append(unknown, selector)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// withoutAuthentication returns a copy of the service config where the
+// operations in authBypass need neither a JWT nor an API key: their
+// authentication rules are removed and their usage rules allow unregistered
+// calls. The operations must be in the service config, see unknownOperations.
+func withoutAuthentication(serviceConfig *confpb.Service, authBypass map[string]bool) *confpb.Service {
+	if len(authBypass) == 0 {
+		return serviceConfig
+	}
+
+	serviceConfig = proto.Clone(serviceConfig).(*confpb.Service)
+	if serviceConfig.GetAuthentication() != nil {
+		var rules []*confpb.AuthenticationRule
+		for _, rule := range serviceConfig.GetAuthentication().GetRules() {
+			if !authBypass[rule.GetSelector()] {
+				rules = append(rules, rule)
+			}
+		}
+		serviceConfig.Authentication.Rules = rules
+	}
+
+	if serviceConfig.Usage == nil {
+		serviceConfig.Usage = &confpb.Usage{}
+	}
+	allowed := make(map[string]bool)
+	for _, rule := range serviceConfig.Usage.Rules {
+		if authBypass[rule.GetSelector()] {
+			rule.AllowUnregisteredCalls = true
+			allowed[rule.GetSelector()] = true
+		}
+	}
+	var selectors []string
+	for selector := range authBypass {
+		if !allowed[selector] {
+			selectors = append(selectors, selector)
+		}
+	}
+	sort.Strings(selectors)
+	for _, selector := range selectors {
+		serviceConfig.Usage.Rules = append(serviceConfig.Usage.Rules, &confpb.UsageRule{
+			Selector:               selector,
+			AllowUnregisteredCalls: true,
+		})
+	}
+	return serviceConfig
+}
+
+func (m *ConfigManager) makeSnapshot(serviceInfo *configinfo.ServiceInfo, version string) (*cache.Snapshot, error) {
+	m.Infof("making configuration for api: %v", serviceInfo.Name)
 
 	var clusterResources, endpoints, secrets, runtimes, routes, listenerResources []types.Resource
-	clusters, err := gen.MakeClusters(m.serviceInfo)
+	clusters, err := gen.MakeClusters(serviceInfo)
+	if err != nil {
+		return nil, err
+	}
+	// The additional services are made with each snapshot. Their listeners
+	// share the CRL SDS file of the main service.
+	additionalClusters, additionalListeners, err := m.makeAdditionalResources()
+	if err != nil {
+		return nil, err
+	}
+	clusters, err = mergeClusters(clusters, additionalClusters)
 	if err != nil {
 		return nil, err
 	}
 	for i := range clusters {
+		if err := clusters[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid cluster (%v): %v", clusters[i].GetName(), err)
+		}
 		clusterResources = append(clusterResources, clusters[i])
 	}
 
-	m.Infof("adding Listeners configuration for api: %v", m.serviceInfo.Name)
-	listeners, err := gen.MakeListeners(m.serviceInfo)
+	m.Infof("adding Listeners configuration for api: %v", serviceInfo.Name)
+	listeners, err := gen.MakeListeners(serviceInfo)
 	if err != nil {
 		return nil, err
 	}
+	listeners = append(listeners, additionalListeners...)
 	for _, lis := range listeners {
+		if err := lis.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid listener (%v): %v", lis.GetName(), err)
+		}
 		listenerResources = append(listenerResources, lis)
 	}
 
-	snapshot := cache.NewSnapshot(m.curConfigId(), endpoints, clusterResources, routes, listenerResources, runtimes, secrets)
+	if *ConfigDumpPath != "" {
+		// Failing to dump the config should not stop serving it.
+		if err := m.dumpConfig(*ConfigDumpPath, clusters, listeners); err != nil {
+			glog.Errorf("fail to dump Envoy config to %v: %v", *ConfigDumpPath, err)
+		} else {
+			m.Infof("Envoy configuration is dumped to %v", *ConfigDumpPath)
+		}
+	}
+
+	snapshot := cache.NewSnapshot(version, endpoints, clusterResources, routes, listenerResources, runtimes, secrets)
 	m.Infof("Envoy Dynamic Configuration is cached for service: %v", m.serviceName)
 	return &snapshot, nil
 }
 
+// dumpConfig writes the generated clusters and listeners to the given path, as
+// the static resources of an Envoy bootstrap config.
+func (m *ConfigManager) dumpConfig(path string, clusters []*clusterpb.Cluster, listeners []*listenerpb.Listener) error {
+	overloadManager, err := bootstrap.CreateOverloadManager(m.envoyConfigOptions.CommonOptions)
+	if err != nil {
+		return err
+	}
+	layeredRuntime, err := bootstrap.CreateLayeredRuntime(m.envoyConfigOptions.CommonOptions)
+	if err != nil {
+		return err
+	}
+	statsSinks, err := bootstrap.CreateStatsSinks(m.envoyConfigOptions.CommonOptions)
+	if err != nil {
+		return err
+	}
+
+	bt := &bootstrappb.Bootstrap{
+		Node:            bootstrap.CreateNode(m.envoyConfigOptions.CommonOptions),
+		Admin:           bootstrap.CreateAdmin(m.envoyConfigOptions.CommonOptions),
+		LayeredRuntime:  layeredRuntime,
+		OverloadManager: overloadManager,
+		StatsSinks:      statsSinks,
+		StaticResources: &bootstrappb.Bootstrap_StaticResources{
+			Listeners: listeners,
+			Clusters:  clusters,
+		},
+	}
+	config, err := util.ProtoToJson(bt)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomically(path, config)
+}
+
 func (m *ConfigManager) curConfigId() string {
-	if m.curServiceConfig == nil {
-		return ""
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.curServiceConfig.GetId()
+}
+
+func (m *ConfigManager) isRejected(configId string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rejectedConfigIds[configId]
+}
+
+// OnStreamResponse is called on each xDS response to Envoy, and records the
+// snapshot version sent with the response nonce.
+func (m *ConfigManager) OnStreamResponse(streamId int64, _ *discoverypb.DiscoveryRequest, resp *discoverypb.DiscoveryResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pushedVersions[streamId] == nil {
+		m.pushedVersions[streamId] = make(map[string]string)
+	}
+	m.pushedVersions[streamId][resp.GetNonce()] = resp.GetVersionInfo()
+}
+
+// OnStreamClosed is called when an xDS stream is closed.
+func (m *ConfigManager) OnStreamClosed(streamId int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pushedVersions, streamId)
+}
+
+// OnStreamRequest is called on each xDS request from Envoy. If Envoy rejects
+// the snapshot of the last config swap within the grace period, the previous
+// snapshot is restored.
+func (m *ConfigManager) OnStreamRequest(streamId int64, req *discoverypb.DiscoveryRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if req.GetResponseNonce() == "" {
+		return nil
+	}
+	pushedVersion, ok := m.pushedVersions[streamId][req.GetResponseNonce()]
+	delete(m.pushedVersions[streamId], req.GetResponseNonce())
+	if req.GetErrorDetail() == nil {
+		return nil
+	}
+
+	// A request carries the last version Envoy accepted, so only a rejection
+	// of the current snapshot, which Envoy doesn't run yet, rolls back.
+	curVersion := m.curSnapshot.GetVersion(req.GetTypeUrl())
+	if !ok || pushedVersion != curVersion || req.GetVersionInfo() == curVersion {
+		glog.Errorf("Envoy rejected the %v of version (%v), keep serving service config (%v): %v", req.GetTypeUrl(), pushedVersion, m.curServiceConfig.GetId(), req.GetErrorDetail().GetMessage())
+		return nil
 	}
-	return m.curServiceConfig.Id
+	if m.prevSnapshot == nil || time.Now().After(m.prevDeadline) {
+		glog.Errorf("Envoy rejected the %v of service config (%v): %v", req.GetTypeUrl(), m.curServiceConfig.GetId(), req.GetErrorDetail().GetMessage())
+		return nil
+	}
+
+	if m.prevServiceConfig == m.curServiceConfig {
+		// Only the reload of --auth_bypass_file is rejected, the service config
+		// is kept. The file is applied again on the next interval.
+		glog.Errorf("Envoy rejected the %v of --auth_bypass_file for service config (%v), rolling back to the previous bypass: %v",
+			req.GetTypeUrl(), m.curServiceConfig.GetId(), req.GetErrorDetail().GetMessage())
+	} else {
+		glog.Errorf("Envoy rejected the %v of service config (%v), rolling back to service config (%v): %v",
+			req.GetTypeUrl(), m.curServiceConfig.GetId(), m.prevServiceConfig.GetId(), req.GetErrorDetail().GetMessage())
+		m.rejectedConfigIds[m.curServiceConfig.GetId()] = true
+	}
+	m.curServiceConfig = m.prevServiceConfig
+	m.curSnapshot = m.prevSnapshot
+	m.authBypass = m.prevAuthBypass
+	m.prevServiceConfig = nil
+	m.prevSnapshot = nil
+	m.prevAuthBypass = nil
+	if err := m.cache.SetSnapshot(m.envoyConfigOptions.Node, *m.curSnapshot); err != nil {
+		glog.Errorf("fail to roll back to service config (%v): %v", m.curServiceConfig.GetId(), err)
+	}
+	return nil
 }
 
 func (m *ConfigManager) ID(node *corepb.Node) string {
@@ -310,6 +931,23 @@ func (m *ConfigManager) Errorf(format string, args ...interface{}) { glog.Errorf
 // Cache returns snapshot cache.
 func (m *ConfigManager) Cache() cache.Cache { return m.cache }
 
+// expandEnvVars replaces ${VAR} or $VAR in the value with the environment
+// variables. Unset variables are reported instead of silently becoming empty.
+func expandEnvVars(value string) (string, error) {
+	var missing []string
+	expanded := os.Expand(value, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables %v referenced by %q are not set", missing, value)
+	}
+	return expanded, nil
+}
+
 func httpsClient(opts options.ConfigGeneratorOptions) (*http.Client, error) {
 	caCert, err := ioutil.ReadFile(opts.SslSidestreamClientRootCertsPath)
 	if err != nil {
@@ -17,8 +17,11 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -37,13 +40,21 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/go-cmp/cmp"
 
+	bootstrappb "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
 	clusterpb "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerpb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	jwtpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	hcmpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tlspb "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discoverypb "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 	servicecontrolpb "google.golang.org/genproto/googleapis/api/servicecontrol/v1"
 	smpb "google.golang.org/genproto/googleapis/api/servicemanagement/v1"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	apipb "google.golang.org/genproto/protobuf/api"
 )
 
 func TestFetchListeners(t *testing.T) {
@@ -697,6 +708,729 @@ func unmarshalJsonTestToPbMessage(input string, output proto.Message) error {
 	return nil
 }
 
+func TestConfigDump(t *testing.T) {
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+
+	configDumpDir := t.TempDir()
+	configDumpPath := filepath.Join(configDumpDir, "envoy_config.json")
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	_ = flag.Set("config_dump_path", configDumpPath)
+	defer flag.Set("config_dump_path", "")
+
+	if _, err := NewConfigManager(nil, opts); err != nil {
+		t.Fatal("fail to initialize Config Manager: ", err)
+	}
+
+	f, err := os.Open(configDumpPath)
+	if err != nil {
+		t.Fatalf("fail to open the config dump: %v", err)
+	}
+	defer f.Close()
+
+	bt := &bootstrappb.Bootstrap{}
+	if err := jsonpb.Unmarshal(f, bt); err != nil {
+		t.Fatalf("fail to unmarshal the config dump as Envoy bootstrap: %v", err)
+	}
+	if err := bt.Validate(); err != nil {
+		t.Errorf("the config dump is not a valid Envoy bootstrap: %v", err)
+	}
+
+	// The temporary file is renamed to the config dump.
+	if files, err := ioutil.ReadDir(configDumpDir); err != nil || len(files) != 1 {
+		t.Errorf("the config dump directory got files: %v, error: %v, want only the config dump", files, err)
+	}
+
+	if got, want := len(bt.GetStaticResources().GetClusters()), len(testdata.FakeWantedClustersForDynamicRouting); got != want {
+		t.Errorf("the config dump got %d clusters, want %d", got, want)
+	}
+	listeners := bt.GetStaticResources().GetListeners()
+	if len(listeners) != 1 || listeners[0].GetName() != "ingress_listener" {
+		t.Errorf("the config dump got listeners: %v, want ingress_listener", listeners)
+	}
+}
+
+func TestConfigSwapRollback(t *testing.T) {
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	_ = flag.Set("config_swap_grace_period", "30s")
+	defer flag.Set("config_swap_grace_period", "0s")
+	configManager, err := NewConfigManager(nil, opts)
+	if err != nil {
+		t.Fatal("fail to initialize Config Manager: ", err)
+	}
+	oldConfigId := configManager.curConfigId()
+
+	checkVersion := func(desc, want string) {
+		snapshot, err := configManager.cache.GetSnapshot(opts.Node)
+		if err != nil {
+			t.Fatalf("%s: fail to get the snapshot: %v", desc, err)
+		}
+		if got := snapshot.GetVersion(resource.ListenerType); got != want {
+			t.Errorf("%s: got snapshot version: %v, want: %v", desc, got, want)
+		}
+		if got := configManager.curConfigId(); got != want {
+			t.Errorf("%s: got config id: %v, want: %v", desc, got, want)
+		}
+	}
+
+	// A service config that fails to generate is not applied.
+	invalidConfig := &confpb.Service{
+		Name: "invalid-service",
+		Id:   "invalid-config-id",
+	}
+	if err := configManager.applyServiceConfig(invalidConfig); err == nil {
+		t.Errorf("applying an invalid service config should fail")
+	}
+	checkVersion("Invalid service config is not applied", oldConfigId)
+
+	push := func(nonce, version string) {
+		configManager.OnStreamResponse(1, nil, &discoverypb.DiscoveryResponse{
+			VersionInfo: version,
+			Nonce:       nonce,
+			TypeUrl:     resource.ListenerType,
+		})
+	}
+	reject := func(nonce, version string) {
+		if err := configManager.OnStreamRequest(1, &discoverypb.DiscoveryRequest{
+			VersionInfo:   version,
+			ResponseNonce: nonce,
+			TypeUrl:       resource.ListenerType,
+			ErrorDetail: &statuspb.Status{
+				Message: "invalid listener",
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	push("1", oldConfigId)
+	newConfig := proto.Clone(configManager.curServiceConfig).(*confpb.Service)
+	newConfig.Id = "new-config-id"
+	if err := configManager.applyServiceConfig(newConfig); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion("New service config is applied", "new-config-id")
+	push("2", "new-config-id")
+
+	// Envoy rejecting a response of the old snapshot, or an unknown
+	// response, keeps the new snapshot.
+	reject("1", "")
+	checkVersion("Rejected old service config is not rolled back", "new-config-id")
+	reject("3", oldConfigId)
+	checkVersion("Rejected unknown response is not rolled back", "new-config-id")
+
+	// Envoy rejecting the new snapshot restores the old one.
+	reject("2", oldConfigId)
+	checkVersion("Rejected service config is rolled back", oldConfigId)
+	if !configManager.isRejected("new-config-id") {
+		t.Errorf("the rejected service config should not be applied again")
+	}
+
+	// The rejected service configs are cleared when another one is applied.
+	anotherConfig := proto.Clone(newConfig).(*confpb.Service)
+	anotherConfig.Id = "another-config-id"
+	if err := configManager.applyServiceConfig(anotherConfig); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion("Another service config is applied", "another-config-id")
+	if configManager.isRejected("new-config-id") {
+		t.Errorf("the rejected service configs should be cleared")
+	}
+
+	// Without a grace period, Envoy rejecting a snapshot keeps it.
+	_ = flag.Set("config_swap_grace_period", "0s")
+	push("4", "another-config-id")
+	lastConfig := proto.Clone(newConfig).(*confpb.Service)
+	lastConfig.Id = "last-config-id"
+	if err := configManager.applyServiceConfig(lastConfig); err != nil {
+		t.Fatal(err)
+	}
+	push("5", "last-config-id")
+	reject("5", "another-config-id")
+	checkVersion("Rejected service config is kept without a grace period", "last-config-id")
+}
+
+func TestAdditionalServices(t *testing.T) {
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+
+	mainConfig, err := readServiceConfig(platform.GetFilePath(platform.FixedDrServiceConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	additionalConfig := proto.Clone(mainConfig).(*confpb.Service)
+	additionalConfig.Name = "additional-service.endpoints.cloudesf-testing.cloud.goog"
+	additionalConfigJson, err := util.ProtoToJson(additionalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	additionalConfigPath := filepath.Join(t.TempDir(), "additional_service.json")
+	if err := ioutil.WriteFile(additionalConfigPath, []byte(additionalConfigJson), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	defer flag.Set("additional_services", "")
+
+	testData := []struct {
+		desc               string
+		additionalServices string
+		wantListeners      map[string]uint32
+		wantClusters       []string
+		wantError          string
+	}{
+		{
+			desc:               "Success, each service gets its own listener and backend cluster",
+			additionalServices: additionalConfigPath + "=8081",
+			wantListeners: map[string]uint32{
+				"ingress_listener":      uint32(opts.ListenerPort),
+				"ingress_listener_8081": 8081,
+			},
+			wantClusters: []string{
+				fmt.Sprintf("backend-cluster-%s_local", mainConfig.GetName()),
+				fmt.Sprintf("backend-cluster-%s_local", additionalConfig.GetName()),
+			},
+		},
+		{
+			desc:               "Fail, the listener port is used by the main service",
+			additionalServices: fmt.Sprintf("%s=%d", additionalConfigPath, opts.ListenerPort),
+			wantError:          "is already used by another service",
+		},
+		{
+			desc:               "Fail, invalid format",
+			additionalServices: additionalConfigPath,
+			wantError:          "it should be in the format service_json_path=listener_port",
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			_ = flag.Set("additional_services", tc.additionalServices)
+			configManager, err := NewConfigManager(nil, opts)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("fail to initialize Config Manager: ", err)
+			}
+
+			checkSnapshot := func(version string) {
+				snapshot, err := configManager.cache.GetSnapshot(opts.Node)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := snapshot.GetVersion(resource.ListenerType); got != version {
+					t.Errorf("got snapshot version: %v, want: %v", got, version)
+				}
+				gotListeners := make(map[string]uint32)
+				for name, res := range snapshot.GetResources(resource.ListenerType) {
+					gotListeners[name] = res.(*listenerpb.Listener).GetAddress().GetSocketAddress().GetPortValue()
+				}
+				if diff := cmp.Diff(tc.wantListeners, gotListeners); diff != "" {
+					t.Errorf("listeners diff (-want +got):\n%s", diff)
+				}
+				clusters := snapshot.GetResources(resource.ClusterType)
+				for _, name := range tc.wantClusters {
+					if _, ok := clusters[name]; !ok {
+						t.Errorf("cluster (%v) is not found in the snapshot", name)
+					}
+				}
+			}
+			checkSnapshot(mainConfig.GetId())
+
+			// The additional services are kept with a new main service config.
+			newConfig := proto.Clone(mainConfig).(*confpb.Service)
+			newConfig.Id = "new-config-id"
+			if err := configManager.applyServiceConfig(newConfig); err != nil {
+				t.Fatal(err)
+			}
+			checkSnapshot("new-config-id")
+		})
+	}
+}
+
+func TestClientCrlReload(t *testing.T) {
+	emptyCrl, err := ioutil.ReadFile(platform.GetFilePath(platform.TestClientCrlEmpty))
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedCrl, err := ioutil.ReadFile(platform.GetFilePath(platform.TestClientCrl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlPath := filepath.Join(t.TempDir(), "client.crl")
+	if err := ioutil.WriteFile(crlPath, emptyCrl, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+	opts.SslServerCertPath = "/etc/ssl/endpoints/"
+	opts.SslServerRootCertPath = "/etc/ssl/endpoints/root.crt"
+	opts.ClientCrlFile = crlPath
+	opts.ClientCrlSdsPath = filepath.Join(t.TempDir(), "client_crl_sds.json")
+	// Disabled to reload the CRL by hand.
+	opts.ClientCrlReloadInterval = 0
+
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	configManager, err := NewConfigManager(nil, opts)
+	if err != nil {
+		t.Fatal("fail to initialize Config Manager: ", err)
+	}
+	defer configManager.Close()
+	configId := configManager.curConfigId()
+
+	checkCrl := func(desc string, wantCrl []byte) {
+		got, err := ioutil.ReadFile(opts.ClientCrlSdsPath)
+		if err != nil {
+			t.Fatalf("%s: fail to read the SDS file: %v", desc, err)
+		}
+		want, err := util.CreateClientCrlSdsFile(wantCrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got SDS file %s, want %s", desc, got, want)
+		}
+
+		// The listener only references the SDS file, so it is not remade.
+		snapshot, err := configManager.cache.GetSnapshot(opts.Node)
+		if err != nil {
+			t.Fatalf("%s: fail to get the snapshot: %v", desc, err)
+		}
+		if got := snapshot.GetVersion(resource.ListenerType); got != configId {
+			t.Errorf("%s: got snapshot version: %v, want: %v", desc, got, configId)
+		}
+		listener := snapshot.GetResources(resource.ListenerType)["ingress_listener"].(*listenerpb.Listener)
+		tlsContext := &tlspb.DownstreamTlsContext{}
+		if err := ptypes.UnmarshalAny(listener.GetFilterChains()[0].GetTransportSocket().GetTypedConfig(), tlsContext); err != nil {
+			t.Fatalf("%s: %v", desc, err)
+		}
+		sdsConfig := tlsContext.GetCommonTlsContext().GetCombinedValidationContext().GetValidationContextSdsSecretConfig()
+		if got := sdsConfig.GetSdsConfig().GetPath(); got != opts.ClientCrlSdsPath {
+			t.Errorf("%s: got CRL SDS path %q, want %q", desc, got, opts.ClientCrlSdsPath)
+		}
+	}
+	checkCrl("The CRL is written before the first snapshot", emptyCrl)
+
+	if err := configManager.reloadClientCrl(); err != nil {
+		t.Fatal(err)
+	}
+	checkCrl("An unchanged CRL is not reloaded", emptyCrl)
+
+	if err := ioutil.WriteFile(crlPath, revokedCrl, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.reloadClientCrl(); err != nil {
+		t.Fatal(err)
+	}
+	checkCrl("A changed CRL is reloaded", revokedCrl)
+
+	if err := os.Remove(crlPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.reloadClientCrl(); err == nil {
+		t.Errorf("reloading a removed CRL should fail")
+	}
+	checkCrl("A removed CRL keeps the previous one", revokedCrl)
+}
+
+func TestClientCrlInvalidAtStartup(t *testing.T) {
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+	opts.SslServerCertPath = "/etc/ssl/endpoints/"
+	opts.SslServerRootCertPath = "/etc/ssl/endpoints/root.crt"
+	opts.ClientCrlFile = platform.GetFilePath(platform.TestRootCaCerts)
+	opts.ClientCrlSdsPath = filepath.Join(t.TempDir(), "client_crl_sds.json")
+
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	wantError := fmt.Sprintf("flag --client_crl_file must be a PEM certificate revocation list, got %q", opts.ClientCrlFile)
+	if _, err := NewConfigManager(nil, opts); err == nil || err.Error() != wantError {
+		t.Errorf("got error %v, want %v", err, wantError)
+	}
+}
+
+func TestBackendAddressEnvVars(t *testing.T) {
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	os.Setenv("TEST_BACKEND_HOST", "backend.example.com")
+	defer os.Unsetenv("TEST_BACKEND_HOST")
+
+	testData := []struct {
+		desc           string
+		backendAddress string
+		wantHostname   string
+		wantError      string
+	}{
+		{
+			desc:           "Environment variables are resolved",
+			backendAddress: "http://${TEST_BACKEND_HOST}:8080",
+			wantHostname:   "backend.example.com",
+		},
+		{
+			desc:           "Unset environment variables are rejected",
+			backendAddress: "http://${TEST_BACKEND_HOST_UNSET}:8080",
+			wantError:      `fail to resolve --backend_address: environment variables [TEST_BACKEND_HOST_UNSET] referenced by "http://${TEST_BACKEND_HOST_UNSET}:8080" are not set`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := options.DefaultConfigGeneratorOptions()
+			opts.DisableTracing = true
+			opts.BackendAddress = tc.backendAddress
+
+			configManager, err := NewConfigManager(nil, opts)
+			if tc.wantError != "" {
+				if err == nil || err.Error() != tc.wantError {
+					t.Fatalf("got error %v, want %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("fail to initialize Config Manager: ", err)
+			}
+			defer configManager.Close()
+
+			snapshot, err := configManager.cache.GetSnapshot(opts.Node)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotHostnames []string
+			for name, res := range snapshot.GetResources(resource.ClusterType) {
+				if !strings.HasSuffix(name, "_local") {
+					continue
+				}
+				for _, endpoint := range res.(*clusterpb.Cluster).GetLoadAssignment().GetEndpoints() {
+					for _, lbEndpoint := range endpoint.GetLbEndpoints() {
+						gotHostnames = append(gotHostnames, lbEndpoint.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+					}
+				}
+			}
+			if len(gotHostnames) != 1 || gotHostnames[0] != tc.wantHostname {
+				t.Errorf("got local backend addresses %v, want [%v]", gotHostnames, tc.wantHostname)
+			}
+		})
+	}
+}
+
+func TestRunEveryStopsOnClose(t *testing.T) {
+	configManager := &ConfigManager{
+		done: make(chan struct{}),
+	}
+	calls := make(chan struct{}, 100)
+	configManager.runEvery(time.Millisecond, func() {
+		calls <- struct{}{}
+	})
+	<-calls
+	configManager.Close()
+
+	// A call already in progress may still complete after Close.
+	time.Sleep(10 * time.Millisecond)
+	got := len(calls)
+	time.Sleep(20 * time.Millisecond)
+	if len(calls) != got {
+		t.Errorf("got %d calls after Close, want none", len(calls)-got)
+	}
+}
+
+func TestAuthBypassReload(t *testing.T) {
+	selector := "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo"
+	bypassPath := filepath.Join(t.TempDir(), "auth_bypass")
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+	opts.AuthBypassFile = bypassPath
+	// Disabled to reload the file by hand.
+	opts.AuthBypassReloadInterval = 0
+
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	configManager, err := NewConfigManager(nil, opts)
+	if err != nil {
+		t.Fatal("fail to initialize Config Manager: ", err)
+	}
+	defer configManager.Close()
+
+	// The Echo operation requires a JWT.
+	serviceConfig := proto.Clone(configManager.curServiceConfig).(*confpb.Service)
+	serviceConfig.Id = "auth-config-id"
+	serviceConfig.Authentication = &confpb.Authentication{
+		Providers: []*confpb.AuthProvider{
+			{
+				Id:      "test_provider",
+				Issuer:  "test-issuer",
+				JwksUri: "https://test-issuer.com/jwks",
+			},
+		},
+		Rules: []*confpb.AuthenticationRule{
+			{
+				Selector: selector,
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: "test_provider",
+					},
+				},
+			},
+		},
+	}
+	if err := configManager.applyServiceConfig(serviceConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAuth := func(desc, wantVersion string, wantRequirement bool) {
+		snapshot, err := configManager.cache.GetSnapshot(opts.Node)
+		if err != nil {
+			t.Fatalf("%s: fail to get the snapshot: %v", desc, err)
+		}
+		if got := snapshot.GetVersion(resource.ListenerType); got != wantVersion {
+			t.Errorf("%s: got snapshot version: %v, want: %v", desc, got, wantVersion)
+		}
+		listener := snapshot.GetResources(resource.ListenerType)["ingress_listener"].(*listenerpb.Listener)
+		hcm := &hcmpb.HttpConnectionManager{}
+		if err := ptypes.UnmarshalAny(listener.GetFilterChains()[0].GetFilters()[0].GetTypedConfig(), hcm); err != nil {
+			t.Fatalf("%s: %v", desc, err)
+		}
+		jwtAuthn := &jwtpb.JwtAuthentication{}
+		for _, filter := range hcm.GetHttpFilters() {
+			if filter.GetName() == util.JwtAuthn {
+				if err := ptypes.UnmarshalAny(filter.GetTypedConfig(), jwtAuthn); err != nil {
+					t.Fatalf("%s: %v", desc, err)
+				}
+			}
+		}
+		if _, got := jwtAuthn.GetRequirementMap()[selector]; got != wantRequirement {
+			t.Errorf("%s: got JWT requirement: %v, want: %v", desc, got, wantRequirement)
+		}
+	}
+	checkAuth("A missing file bypasses no operation", "auth-config-id", true)
+
+	if err := ioutil.WriteFile(bypassPath, []byte("# Incident 42\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.reloadAuthBypass(bypassPath); err != nil {
+		t.Fatal(err)
+	}
+	checkAuth("A file without operations is not reloaded", "auth-config-id", true)
+
+	if err := ioutil.WriteFile(bypassPath, []byte("# Incident 42\n"+selector+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.reloadAuthBypass(bypassPath); err != nil {
+		t.Fatal(err)
+	}
+	checkAuth("The listed operation is made public", "auth-config-id-auth1", false)
+	if got := configManager.curConfigId(); got != "auth-config-id" {
+		t.Errorf("got config id: %v, want: %v", got, "auth-config-id")
+	}
+
+	if err := ioutil.WriteFile(bypassPath, []byte(selector+"\nunknown.Operation\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wantError := "flag --auth_bypass_file lists operations not in service config (auth-config-id): [unknown.Operation]"
+	if err := configManager.reloadAuthBypass(bypassPath); err == nil || err.Error() != wantError {
+		t.Errorf("got error %v, want %v", err, wantError)
+	}
+	checkAuth("A file with unknown operations keeps the previous bypass", "auth-config-id-auth1", false)
+
+	if err := os.Remove(bypassPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.reloadAuthBypass(bypassPath); err != nil {
+		t.Fatal(err)
+	}
+	checkAuth("Removing the file reverts the bypass", "auth-config-id-auth2", true)
+}
+
+func TestAuthBypassUnknownOperationsAtStartup(t *testing.T) {
+	bypassPath := filepath.Join(t.TempDir(), "auth_bypass")
+	if err := ioutil.WriteFile(bypassPath, []byte("1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo\nunknown.Operation\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+	opts.AuthBypassFile = bypassPath
+	opts.AuthBypassReloadInterval = 0
+
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	configManager, err := NewConfigManager(nil, opts)
+	if err != nil {
+		t.Fatal("fail to initialize Config Manager: ", err)
+	}
+	defer configManager.Close()
+
+	// The file is rejected as a whole, like when it is reloaded.
+	if len(configManager.authBypass) != 0 {
+		t.Errorf("got bypassed operations %v, want none", configManager.authBypass)
+	}
+}
+
+func TestAuthBypassRollback(t *testing.T) {
+	bypassPath := filepath.Join(t.TempDir(), "auth_bypass")
+
+	opts := options.DefaultConfigGeneratorOptions()
+	opts.DisableTracing = true
+	opts.AuthBypassFile = bypassPath
+	opts.AuthBypassReloadInterval = 0
+
+	_ = flag.Set("service_json_path", platform.GetFilePath(platform.FixedDrServiceConfig))
+	_ = flag.Set("config_swap_grace_period", "30s")
+	defer flag.Set("config_swap_grace_period", "0s")
+	configManager, err := NewConfigManager(nil, opts)
+	if err != nil {
+		t.Fatal("fail to initialize Config Manager: ", err)
+	}
+	defer configManager.Close()
+	configId := configManager.curConfigId()
+
+	configManager.OnStreamResponse(1, nil, &discoverypb.DiscoveryResponse{
+		VersionInfo: configId,
+		Nonce:       "1",
+		TypeUrl:     resource.ListenerType,
+	})
+	if err := ioutil.WriteFile(bypassPath, []byte("1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configManager.reloadAuthBypass(bypassPath); err != nil {
+		t.Fatal(err)
+	}
+	configManager.OnStreamResponse(1, nil, &discoverypb.DiscoveryResponse{
+		VersionInfo: configId + "-auth1",
+		Nonce:       "2",
+		TypeUrl:     resource.ListenerType,
+	})
+
+	// Envoy rejecting the reloaded bypass restores the previous snapshot, and
+	// keeps the service config.
+	if err := configManager.OnStreamRequest(1, &discoverypb.DiscoveryRequest{
+		VersionInfo:   configId,
+		ResponseNonce: "2",
+		TypeUrl:       resource.ListenerType,
+		ErrorDetail: &statuspb.Status{
+			Message: "invalid listener",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := configManager.cache.GetSnapshot(opts.Node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot.GetVersion(resource.ListenerType); got != configId {
+		t.Errorf("got snapshot version: %v, want: %v", got, configId)
+	}
+	if got := configManager.curConfigId(); got != configId {
+		t.Errorf("got config id: %v, want: %v", got, configId)
+	}
+	if configManager.isRejected(configId) {
+		t.Errorf("the service config should not be rejected by a rejected bypass")
+	}
+	if len(configManager.authBypass) != 0 {
+		t.Errorf("got bypassed operations %v, want none", configManager.authBypass)
+	}
+}
+
+func TestWithoutAuthentication(t *testing.T) {
+	serviceConfig := &confpb.Service{
+		Id: "test-config-id",
+		Apis: []*apipb.Api{
+			{
+				Name: "test.Api",
+				Methods: []*apipb.Method{
+					{
+						Name: "Public",
+					},
+					{
+						Name: "Jwt",
+					},
+					{
+						Name: "ApiKey",
+					},
+				},
+			},
+		},
+		Authentication: &confpb.Authentication{
+			Rules: []*confpb.AuthenticationRule{
+				{
+					Selector: "test.Api.Jwt",
+				},
+				{
+					Selector: "test.Api.ApiKey",
+				},
+			},
+		},
+		Usage: &confpb.Usage{
+			Rules: []*confpb.UsageRule{
+				{
+					Selector:               "test.Api.Public",
+					AllowUnregisteredCalls: true,
+				},
+				{
+					Selector: "test.Api.ApiKey",
+				},
+			},
+		},
+	}
+
+	testData := []struct {
+		desc               string
+		authBypass         map[string]bool
+		wantAuthentication *confpb.Authentication
+		wantUsage          *confpb.Usage
+	}{
+		{
+			desc:               "No operation is bypassed",
+			wantAuthentication: serviceConfig.Authentication,
+			wantUsage:          serviceConfig.Usage,
+		},
+		{
+			desc: "The JWT and API key checks of the operations are removed",
+			authBypass: map[string]bool{
+				"test.Api.Jwt":    true,
+				"test.Api.ApiKey": true,
+			},
+			wantAuthentication: &confpb.Authentication{},
+			wantUsage: &confpb.Usage{
+				Rules: []*confpb.UsageRule{
+					{
+						Selector:               "test.Api.Public",
+						AllowUnregisteredCalls: true,
+					},
+					{
+						Selector:               "test.Api.ApiKey",
+						AllowUnregisteredCalls: true,
+					},
+					{
+						Selector:               "test.Api.Jwt",
+						AllowUnregisteredCalls: true,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			original := proto.Clone(serviceConfig)
+			got := withoutAuthentication(serviceConfig, tc.authBypass)
+			if !proto.Equal(got.GetAuthentication(), tc.wantAuthentication) {
+				t.Errorf("got authentication %v, want %v", got.GetAuthentication(), tc.wantAuthentication)
+			}
+			if !proto.Equal(got.GetUsage(), tc.wantUsage) {
+				t.Errorf("got usage %v, want %v", got.GetUsage(), tc.wantUsage)
+			}
+			if !proto.Equal(serviceConfig, original) {
+				t.Errorf("the service config was modified")
+			}
+		})
+	}
+}
+
 func genProtoBinary(input string, msg proto.Message, dest *safeData) error {
 	if err := unmarshalJsonTestToPbMessage(input, msg); err != nil {
 		return err
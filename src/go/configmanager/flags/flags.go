@@ -33,7 +33,7 @@ var (
 	// When adding or changing default values, update options.DefaultConfigGeneratorOptions.
 
 	// Cors related configurations.
-	CorsAllowCredentials = flag.Bool("cors_allow_credentials", false, "whether include the Access-Control-Allow-Credentials header with the value true in responses or not")
+	CorsAllowCredentials = flag.Bool("cors_allow_credentials", false, "whether include the Access-Control-Allow-Credentials header with the value true in responses or not. The request origin, instead of *, is echoed in Access-Control-Allow-Origin")
 	CorsAllowHeaders     = flag.String("cors_allow_headers", "", "set Access-Control-Allow-Headers to the specified HTTP headers")
 	CorsAllowMethods     = flag.String("cors_allow_methods", "", "set Access-Control-Allow-Methods to the specified HTTP methods")
 	CorsAllowOrigin      = flag.String("cors_allow_origin", "", "set Access-Control-Allow-Origin to a specific origin")
@@ -42,14 +42,44 @@ var (
 	CorsMaxAge           = flag.Duration("cors_max_age", 480*time.Hour, "set Access-Control-Max-Age response header for CORS preflight request.")
 	CorsPreset           = flag.String("cors_preset", "", `enable CORS support, must be either "basic" or "cors_with_regex"`)
 
+	CorsDisabledOperations = flag.String("cors_disabled_operations", "", `Comma-separated selectors of operations with CORS disabled. When the endpoint sets allow_cors,
+        ESPv2 doesn't generate CORS preflight routes for them, so their preflight requests are rejected instead of being passed to the backend.
+        A preflight request to a path that is shared with an operation with CORS enabled is still passed to the backend.
+        When --cors_preset is in use, the CORS policy doesn't apply to them, and their preflight requests are rejected without CORS headers.`)
+
 	// Backend routing configurations.
 	BackendDnsLookupFamily = flag.String("backend_dns_lookup_family", "auto", `Define the dns lookup family for all backends. The options are "auto", "v4only" and "v6only". The default is "auto".`)
 
 	// Envoy specific configurations.
-	ClusterConnectTimeout = flag.Duration("cluster_connect_timeout", 20*time.Second, "cluster connect timeout in seconds")
+	ClusterConnectTimeout = flag.Duration("cluster_connect_timeout", 20*time.Second, `cluster connect timeout in seconds. It applies to all clusters,
+	and backend clusters use it unless --backend_connect_timeout or --backend_connect_timeout_by_operation is set.`)
+	BackendConnectTimeout = flag.Duration("backend_connect_timeout", 0, `Connect timeout for the backend clusters, including the TLS handshake for HTTPS backends.
+	It overrides --cluster_connect_timeout for backend clusters only, and is overridden by --backend_connect_timeout_by_operation.
+	If it is 0, --cluster_connect_timeout is used.`)
+
+	BackendConnectTimeoutByOperation = flag.String("backend_connect_timeout_by_operation", "", `Override the backend connect timeout for operations, separated by comma, e.g. "selector1=5s,selector2=30s".
+	It takes precedence over both --backend_connect_timeout and --cluster_connect_timeout.
+	The timeout applies to the cluster of the operation's backend, so operations sharing a backend address must not set different timeouts.`)
+	BackendSniByOperation = flag.String("backend_sni_by_operation", "", `Override the TLS SNI sent to the HTTPS backend of operations, separated by comma, e.g. "selector1=backend.example.com".
+	By default, the SNI is the hostname of the backend address. The SNI applies to the cluster of the operation's backend, so operations sharing
+	a backend address must not set different SNIs.`)
+	BackendAlpnByOperation = flag.String("backend_alpn_by_operation", "", `Override the ALPN protocol advertised to the HTTPS backend of operations, separated by comma, e.g. "selector1=http/1.1".
+	It is either "h2" or "http/1.1", and the backend is called with that protocol regardless of the protocol in its backend rule. It can also be
+	"auto" to advertise both and use the one the backend selects, for backends that don't all support h2. gRPC backends only support "h2". The ALPN applies to the cluster of the operation's backend, so operations sharing a backend address must not set different ALPNs.`)
+	BackendStripPrefixByOperation = flag.String("backend_strip_prefix_by_operation", "", `Remove a path prefix from the request path before it is sent to the backend of operations, separated by comma,
+	e.g. "selector1=/api/v1" sends "/api/v1/books" to the backend as "/books". The path of the backend address, if any, is prepended after the prefix
+	is removed. It is not supported for operations with CONSTANT_ADDRESS path translation.`)
+	BackendRegexRewriteByOperation = flag.String("backend_regex_rewrite_by_operation", "", `Rewrite the path sent to the backend of operations with a RE2 regex, separated by comma,
+	e.g. "selector1=^/v1/users/([^/]+)/profile$=/profile?user=\1" sends "/v1/users/123/profile?key=abc" to the backend as "/profile?user=123&key=abc".
+	Commas and equal signs in the pattern must be escaped as "\," and "\=", except for the commas of repetitions like "{1,3}".
+	The pattern is matched against the path without its query string, which is joined to the query string
+	of the substitution, if any. The path of the backend address, if any, is prepended after the substitution.
+	It is not supported for operations with CONSTANT_ADDRESS path translation or --backend_strip_prefix_by_operation.`)
+	MaxGrpcTimeout = flag.Duration("max_grpc_timeout", 0, `If set, gRPC requests are timed out after their grpc-timeout, capped by this value, e.g. "30s", with
+	DEADLINE_EXCEEDED. The deadline of the backend still applies. Disabled by default.`)
 
 	// Network related configurations.
-	BackendAddress               = flag.String("backend_address", "http://127.0.0.1:8082", `The application server URI to which ESPv2 proxies requests.`)
+	BackendAddress               = flag.String("backend_address", "http://127.0.0.1:8082", `The application server URI to which ESPv2 proxies requests. It may reference environment variables, e.g. "http://${BACKEND_HOST}:8080", which are resolved at startup.`)
 	ListenerAddress              = flag.String("listener_address", "0.0.0.0", "listener socket ip address")
 	ServiceManagementURL         = flag.String("service_management_url", "https://servicemanagement.googleapis.com", "url of service management server")
 	ServiceControlURL            = flag.String("service_control_url", "https://servicecontrol.googleapis.com", "url of service control server")
@@ -61,6 +91,11 @@ var (
 	SslServerCertPath                = flag.String("ssl_server_cert_path", "", "Path to the certificate and key that ESPv2 uses to act as a HTTPS server")
 	SslServerCipherSuites            = flag.String("ssl_server_cipher_suites", "", "Cipher suites to use for downstream connections as a comma-separated list.")
 	SslServerRootCertsPath           = flag.String("ssl_server_root_cert_path", "", "The file path of root certificates that ESPv2 uses to verify downstream client certificate. If not specified, ESPv2 doesn't verify client certificates by default")
+	SslServerSpiffeTrustBundlePath   = flag.String("ssl_server_spiffe_trust_bundle_path", "", "The file path of the SPIFFE trust bundle that ESPv2 uses to verify downstream client SVIDs. Requires client certificates and cannot be used with --ssl_server_root_cert_path.")
+	SslServerAllowedSpiffeIds        = flag.String("ssl_server_allowed_spiffe_ids", "", "The SPIFFE IDs of downstream clients allowed to connect as a comma-separated list, e.g. spiffe://example.org/ns/default/sa/client. Requires --ssl_server_spiffe_trust_bundle_path.")
+	ClientCrlFile                    = flag.String("client_crl_file", "", "The file path of the certificate revocation list in PEM that ESPv2 uses to reject revoked downstream client certificates. The file is checked for changes every --client_crl_reload_interval and a changed CRL is sent to Envoy through the file of --client_crl_sds_path, without draining the listeners. With a static bootstrap config the CRL is only read when the bootstrap is generated. Requires --ssl_server_root_cert_path or --ssl_server_spiffe_trust_bundle_path.")
+	ClientCrlSdsPath                 = flag.String("client_crl_sds_path", "", "The file path where ESPv2 writes the CRL of --client_crl_file as an SDS secret watched by Envoy. Its directory must be writable. If empty, the file is written in a new temporary directory, so that ESPv2 instances on the same host don't share it.")
+	ClientCrlReloadInterval          = flag.Duration("client_crl_reload_interval", 60*time.Second, "The interval to check the file of --client_crl_file for changes. 0 disables the reload.")
 	SslSidestreamClientRootCertsPath = flag.String("ssl_sidestream_client_root_certs_path", util.DefaultRootCAPaths, "Path to the root certificates to make TLS connection to all external services other than the backend.")
 	SslBackendClientCertPath         = flag.String("ssl_backend_client_cert_path", "", "Path to the certificate and key that ESPv2 uses to enable TLS mutual authentication for HTTPS backend")
 	SslBackendClientRootCertsPath    = flag.String("ssl_backend_client_root_certs_path", util.DefaultRootCAPaths, "Path to the root certificates to make TLS connection to the HTTPS backend.")
@@ -70,6 +105,13 @@ var (
 	EnableHSTS                       = flag.Bool("enable_strict_transport_security", false, "Enable HSTS (HTTP Strict Transport Security).")
 	DnsResolverAddresses             = flag.String("dns_resolver_addresses", "", `The addresses of dns resolvers. Each address should be in format of either IP_ADDR or IP_ADDR:PORT and they are separated by ';'.`)
 
+	ForwardClientCertDetails = flag.String("forward_client_cert_details", "", `How to handle the X-Forwarded-Client-Cert (XFCC) header sent to the backend for
+        mTLS client connections. One of "sanitize", "forward_only", "append_forward", "sanitize_set" or "always_forward_only".
+        The default is "sanitize", which removes the header.`)
+	SetCurrentClientCertDetails = flag.String("set_current_client_cert_details", "", `The fields of the client certificate added to the X-Forwarded-Client-Cert header, as a
+        comma-separated list of "subject", "uri", "dns", "cert" and "chain". Requires --forward_client_cert_details to be
+        "append_forward" or "sanitize_set".`)
+
 	AddRequestHeaders = flag.String("add_request_headers", "", `Add HTTP headers to the request before sent to the upstream backend. Multiple headers are separated by ';'.
          For example --add_request_headers=key1=value1;key2=value2. If a header is already in the request, its value will be replaced with the new one.`)
 	AppendRequestHeaders = flag.String("append_request_headers", "", `Append HTTP headers to the request before sent to the upstream backend. Multiple headers are separated by ';'.
@@ -79,6 +121,11 @@ var (
 	AppendResponseHeaders = flag.String("append_response_headers", "", `Append HTTP headers to the response before sent to the upstream backend. Multiple headers are separated by ';'.
          For example --append_response_headers=key1=value1;key2=value2. If a header is already in the response, the new value will be append.`)
 	EnableOperationNameHeader = flag.Bool("enable_operation_name_header", false, "If enabled, the operation name for the matched route will be sent to the upstream as a request header.")
+	ResponseHeadersAllowlist  = flag.String("response_headers_allowlist", "", `Comma-separated response headers, e.g. "x-request-id,cache-control", forwarded to clients.
+         If set, all other response headers from the backends are removed, except the essential ones like content-type, content-length and
+         the gRPC status headers grpc-status, grpc-message and grpc-status-details-bin. Response trailers are not changed.`)
+	EnableResponseCompression = flag.Bool("enable_response_compression", false, `Compress responses with gzip if the request has "Accept-Encoding: gzip", including the JSON responses
+         transcoded from gRPC backends. Only text content types like application/json are compressed, gRPC responses are not.`)
 
 	// Flags for non_gcp deployment.
 	ServiceAccountKey = flag.String("service_account_key", "", `Use the service account key JSON file to access the service control and the
@@ -101,25 +148,69 @@ var (
 	If unset, the following format will be used.
 	https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log#default-format-string
 	For the detailed format grammar, please refer to the following document.
-	https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log#format-strings`)
+	https://www.envoyproxy.io/docs/envoy/latest/configuration/observability/access_log#format-strings
+	To diagnose failed requests, log %RESPONSE_FLAGS% and %UPSTREAM_CLUSTER%. The common response flags are UF (upstream
+	connection failure), UH (no healthy upstream host), UT (upstream request timeout), UC (upstream connection termination),
+	UR (upstream remote reset), URX (upstream retry limit exceeded) and NR (no route configured).`)
+	AccessLogGrpcAddress = flag.String("access_log_grpc_address", "", `Address of a gRPC access log service to which the access log entries will be streamed,
+	e.g. "grpc://als.example.com:9001", or "grpcs://als.example.com" to use TLS. It is independent of --access_log.`)
+	AccessLogGrpcLogName = flag.String("access_log_grpc_log_name", "espv2", `The log name sent with the entries to the service of --access_log_grpc_address,
+	to tell ESPv2 apart from other Envoy proxies logging to the same service.`)
 
 	EnvoyUseRemoteAddress  = flag.Bool("envoy_use_remote_address", false, "Envoy HttpConnectionManager configuration, please refer to envoy documentation for detailed information.")
 	EnvoyXffNumTrustedHops = flag.Int("envoy_xff_num_trusted_hops", 2, "Envoy HttpConnectionManager configuration, please refer to envoy documentation for detailed information.")
 
+	EnableOperationStats = flag.Bool("enable_operation_stats", false, `If enabled, per-operation request counts and latencies are emitted as Envoy virtual cluster stats,
+	named "vhost.backend.vcluster.<operation>.*" with dots in the operation name replaced by underscores.
+	Request latency percentiles are available from the "vhost.backend.vcluster.<operation>.upstream_rq_time" histogram.`)
+	EnableConsumerStats = flag.Bool("enable_consumer_stats", false, `If enabled, per-consumer request counts and latencies are emitted as Envoy stats named
+	"http.ingress_http.service_control.consumer.<project_number>.*", with the consumer project number from the Service Control
+	check response. Requests without a consumer, e.g. without an API key, are not counted.`)
+
 	LogJwtPayloads = flag.String("log_jwt_payloads", "", `Log corresponding JWT JSON payload primitive fields through service control, separated by comma. Example, when --log_jwt_payload=sub,project_id, log
 	will have jwt_payload: sub=[SUBJECT];project_id=[PROJECT_ID] if the fields are available. The value must be a primitive field, JSON objects and arrays will not be logged.`)
 	LogRequestHeaders = flag.String("log_request_headers", "", `Log corresponding request headers through service control, separated by comma. Example, when --log_request_headers=
 	foo,bar, endpoint log will have request_headers: foo=foo_value;bar=bar_value if values are available;`)
 	LogResponseHeaders = flag.String("log_response_headers", "", `Log corresponding response headers through service control, separated by comma. Example, when --log_response_headers=
 	foo,bar,endpoint log will have response_headers: foo=foo_value;bar=bar_value if values are available.`)
+	ServiceControlExtraLabels = flag.String("service_control_extra_labels", "", `Report request headers as service control labels, as comma-separated pairs of header=label, e.g.
+	X-Client-Version=/client_version. The label is omitted if the header is not in the request. Labels reported by ESPv2 itself, such as
+	/response_code or any label in a googleapis.com namespace, are rejected.`)
+	ServiceControlJwtClaimLabels = flag.String("service_control_jwt_claim_labels", "", `Report top-level string claims of the verified JWT as service control labels, as comma-separated pairs of
+	claim=label, e.g. sub=/jwt_sub,email=/jwt_email. The label is omitted if the claim is not in the JWT.`)
+	LogEntryFields = flag.String("log_entry_fields", "", `Add optional request fields to the service control log entry, separated by comma. Supported fields are
+	consumer_project_number, http_method, request_id, request_latency_in_ms and user_agent. The consumer project number is resolved
+	by the service control Check, so it is only added to requests that are checked.`)
+	ServiceControlCredentialIdPrefix = flag.String("service_control_credential_id_prefix", "", `Prefix prepended to the /credential_id label reported to service control, e.g. "billing-" reports
+	billing-apikey:KEY for API key requests and billing-jwtauth:issuer=... for JWT requests.`)
+	ServiceControlSuccessStatusCodes = flag.String("service_control_success_status_codes", "", `HTTP response codes reported to service control as successful, separated by comma, e.g. "404,409".
+	They are not reported as errors: the /error_type label is omitted, /response_code_class is 2xx and the log entry severity is INFO.
+	Only backend responses are considered, and gRPC statuses are mapped to HTTP codes, e.g. NOT_FOUND to 404. Errors replied by ESPv2
+	itself are still reported as errors.`)
+	ServiceControlUnmatchedOperation = flag.String("service_control_unmatched_operation", "", `The operation name reported to service control for requests that don't match any operation, e.g. "<unregistered>".
+	Default is "<Unknown Operation Name>".`)
+	RemoveApiKeyFromRequest = flag.Bool("remove_api_key_from_request", false, `Remove the API key headers and query parameters from the request once the API key is extracted,
+	so that they are not forwarded to the backend. By default, they are forwarded. API keys in cookies are always forwarded.`)
 	MinStreamReportIntervalMs = flag.Uint64("min_stream_report_interval_ms", 0, `Minimum amount of time (milliseconds) between sending intermediate reports on a stream and the default is 10000 if not set.`)
+	ServiceControlStatsReport = flag.String("service_control_stats_report", "", `Envoy stats periodically reported to service control as log entries, as comma-separated full stat names of
+	counters and gauges, e.g. "listener.0.0.0.0_8080.downstream_cx_total,cluster.backend-cluster-example.com_443.upstream_rq_total".`)
+	ServiceControlStatsReportInterval = flag.Duration("service_control_stats_report_interval", time.Minute, `The interval between two reports of the Envoy stats selected by --service_control_stats_report.`)
 
 	SuppressEnvoyHeaders = flag.Bool("suppress_envoy_headers", true, `Do not add any additional x-envoy- headers to requests or responses. This only affects the router filter
 	generated *x-envoy-* headers, other Envoy filters and the HTTP connection manager may continue to set x-envoy- headers.`)
 	UnderscoresInHeaders         = flag.Bool("underscores_in_headers", false, `When true, ESPv2 allows HTTP headers name has underscore and pass it through. Otherwise, rejects the request.`)
+	MaxRequestHeadersKb          = flag.Int("max_request_headers_kb", 0, `The maximum size of all request headers in KB, up to 8192. Requests with larger headers, e.g. due to a large JWT, are rejected with 413. 0 uses the Envoy default of 60.`)
+	MaxRequestDuration           = flag.Duration("max_request_duration", 0, `The maximum duration of a request regardless of upstream activity, e.g. 5m. Longer requests are aborted with 504, or reset if the response has started. 0 means no limit.`)
 	NormalizePath                = flag.Bool("normalize_path", true, `Normalizes the path according to RFC 3986 before processing requests.`)
 	MergeSlashesInPath           = flag.Bool("merge_slashes_in_path", true, `Determines if adjacent slashes in the path are merged into one before processing requests.`)
 	DisallowEscapedSlashesInPath = flag.Bool("disallow_escaped_slashes_in_path", false, `Determines if [%2F, %2f, %2C, %2c] characters in the path are disallowed.`)
+	DecodeEscapedSlashesInPath   = flag.Bool("decode_escaped_slashes_in_path", false, `Determines if [%2F, %2f, %2C, %2c] characters in the path are decoded and the request is forwarded with the decoded path. Cannot be used with --disallow_escaped_slashes_in_path.`)
+	RequireRequestHeaders        = flag.String("require_request_headers", "", `Comma-separated headers that every request must carry, e.g. "X-Correlation-Id". Requests missing any of them are
+	rejected with 400 before they are routed. CORS preflight requests are exempted.`)
+
+	AllowDuplicateHttpPatterns = flag.Bool("allow_duplicate_http_patterns", false, `If enabled, when multiple http rules have the same http method and path, the one of the operation
+	listed first in the service config is kept and the others are ignored with a warning. Otherwise, they fail the config generation. Overlapping but different paths are always matched
+	from the most specific to the least specific, e.g. "/shelves/featured" before "/shelves/{shelf}".`)
 
 	ServiceControlNetworkFailOpen = flag.Bool("service_control_network_fail_open", true, ` In case of network failures when connecting to Google service control,
         the requests will be allowed if this flag is on. The default is on.`)
@@ -129,34 +220,114 @@ var (
 	ConnectionBufferLimitBytes = flag.Int("connection_buffer_limit_bytes", -1, `Configure the maximum amount of data that is buffered for each request/response body. 
 			If not provided, Envoy will decide the default value.`)
 
+	EnableProxyProtocol = flag.Bool("enable_proxy_protocol", false, `Expect a PROXY protocol (v1 or v2) header on every downstream connection, as
+        prepended by some load balancers. The client address it carries is used as the downstream address
+        in logs and reports. Connections without the header are rejected.`)
+
+	AcceptHttp10 = flag.Bool("accept_http_10", false, `Accept HTTP/1.0 requests from legacy clients. Otherwise they are rejected with 426 Upgrade Required.
+        The requests are sent to the backend using HTTP/1.1.`)
+	DefaultHostForHttp10 = flag.String("default_host_for_http_10", "", `The host used for HTTP/1.0 requests without a Host header, e.g. "api.example.com".
+        Only used with --accept_http_10.`)
+
+	ListenerTcpKeepaliveTime = flag.Duration("listener_tcp_keepalive_time", 0, `Enable TCP keepalive on downstream connections, sending the first probe after
+        the connection has been idle for this long. Must be at least 1s. Disabled if not set.`)
+	ListenerTcpKeepaliveInterval = flag.Duration("listener_tcp_keepalive_interval", 0, `The interval between TCP keepalive probes on downstream connections. Must be at
+        least 1s. If not set, the system default is used. Requires --listener_tcp_keepalive_time.`)
+	ListenerTcpKeepaliveProbes = flag.Int("listener_tcp_keepalive_probes", 0, `The number of unanswered TCP keepalive probes after which a downstream connection
+        is dropped. If not set, the system default is used. Requires --listener_tcp_keepalive_time.`)
+	ListenerHttp2KeepaliveInterval = flag.Duration("listener_http2_keepalive_interval", 0, `Send HTTP/2 PING frames to idle downstream connections at this interval, so that
+        long-lived gRPC streams are not dropped by intermediaries. Must be at least 1s. Disabled if not set.`)
+	ListenerHttp2KeepaliveTimeout = flag.Duration("listener_http2_keepalive_timeout", 20*time.Second, `How long to wait for a response to an HTTP/2 keepalive PING before the
+        downstream connection is closed. Only used with --listener_http2_keepalive_interval.`)
+	ListenerHttp2MaxConcurrentStreams = flag.Int("listener_http2_max_concurrent_streams", 0, `The maximum number of concurrent streams per downstream HTTP/2
+        connection, so that a single gRPC client cannot starve the others. If not set, the Envoy default of 2147483647 is used.`)
+	EnableReusePort = flag.Bool("enable_reuse_port", false, `Set SO_REUSEPORT on the listener so that each Envoy worker thread gets its own listening
+        socket and the kernel balances new connections across them.`)
+	BackendUnavailableMessage = flag.String("backend_unavailable_message", "", `The message of the 503 responses when the backend is unavailable, i.e. the connection
+        to it fails, none of its hosts is healthy, or its circuit breaker is open, e.g. "Bookstore is temporarily unavailable".
+        If not set, the Envoy default message is used.`)
+
 	DisableJwksAsyncFetch = flag.Bool("disable_jwks_async_fetch", false, `When the feature is enabled, JWKS is fetched before processing any requests. When disabled, JWKS is fetched on-demand when processing the requests.`)
 	JwksCacheDurationInS  = flag.Int("jwks_cache_duration_in_s", 300, "Specify JWT public key cache duration in seconds. The default is 5 minutes.")
+	JwksWarmOnStartup     = flag.String("jwks_warm_on_startup", "", `Fetch the JWKS of all authentication providers when the config is generated. When "required",
+        the config is rejected, and so startup fails, if any JWKS cannot be fetched. When "optional", a warning is logged instead.
+        Each fetch times out after --http_request_timeout_s. Disabled by default.`)
+	JwksCacheDurationByProvider = flag.String("jwks_cache_duration_by_provider", "", `Override the JWT public key cache duration for specific
+        authentication providers, as comma-separated pairs of provider_id=seconds, e.g. "google_id_token=3600,auth0=60".
+        Providers that are not listed use --jwks_cache_duration_in_s.`)
+	JwtSkipAudienceCheck = flag.String("jwt_skip_audience_check", "", `Skip the audience check of the JWTs issued by specific authentication providers, as comma-separated
+        provider ids, e.g. "internal_provider". Their signature and issuer are still verified, for providers that issue JWTs without an "aud" claim.`)
+	JwtProviderAdditionalIssuers = flag.String("jwt_provider_additional_issuers", "", `Let an authentication provider also accept JWTs from other issuers, as comma-separated
+        provider_id=issuer|jwks_uri entries, e.g. "auth0=https://new-tenant.auth0.com/|https://new-tenant.auth0.com/.well-known/jwks.json".
+        The jwks_uri is optional and is found by OpenID Connect Discovery when omitted. Operations requiring the provider accept JWTs
+        from any of its issuers, with the same audiences. The n-th additional issuer of a provider gets the provider id "<provider_id>_issuer_<n>".`)
+
+	AuthBypassFile = flag.String("auth_bypass_file", "", `File path listing the selectors of operations, one per line, whose JWT and API key checks
+        are removed at runtime to make them public, e.g. during an incident. Changes to the file are sent to Envoy with the current service config
+        and logged as warnings, and a file listing operations not in the service config is rejected. A missing or empty file bypasses no operation.
+        Not set by default, which disables the bypass.`)
+	AuthBypassReloadInterval = flag.Duration("auth_bypass_reload_interval", 10*time.Second, `The interval to check the file of --auth_bypass_file for changes.
+        0 only reads the file at startup.`)
 
 	JwksFetchNumRetries                 = flag.Int("jwks_fetch_num_retries", 0, `Specify the remote JWKS fetch retry policy's number of retries. The default is 0, meaning no retry policy applied.`)
 	JwksFetchRetryBackOffBaseIntervalMs = flag.Int("jwks_fetch_retry_back_off_base_interval_ms", 200, `Specify JWKS fetch retry exponential back off base interval in milliseconds. The default is 200 milliseconds.`)
 	JwksFetchRetryBackOffMaxIntervalMs  = flag.Int("jwks_fetch_retry_back_off_max_interval_ms", 32000, `Specify JWKS fetch retry exponential back off maximum interval in milliseconds. The default is 32 seconds.`)
 
+	TrustPreauthenticatedJwtHeader = flag.String("trust_preauthenticated_jwt_header", "", `The request header carrying a JWT that was already validated by an upstream hop.
+	When a request carries this header, its JWT is NOT verified and JWT authentication is skipped; the payload is forwarded to the backend and
+	used for reporting. Requests without this header still need a JWT verified by ESPv2. This is insecure unless every request reaches ESPv2
+	through the trusted hop. The default is empty, which disables it.`)
+	CheckApiKeyBeforeJwt = flag.Bool("check_api_key_before_jwt", false, `For operations requiring both an API key and a JWT, check the API key before verifying the JWT.
+	By default the JWT is verified first, so a request missing both is rejected with 401 "Jwt is missing". With this flag, it is rejected with
+	401 for the missing API key instead, and requests with an invalid JWT are checked with service control before they are rejected.
+	Cannot be used with --jwt_claim_backend_routes.`)
+
 	ScCheckTimeoutMs  = flag.Int("service_control_check_timeout_ms", 0, `Set the timeout in millisecond for service control Check request. Must be > 0 and the default is 1000 if not set.`)
 	ScQuotaTimeoutMs  = flag.Int("service_control_quota_timeout_ms", 0, `Set the timeout in millisecond for service control Quota request. Must be > 0 and the default is 1000 if not set.`)
 	ScReportTimeoutMs = flag.Int("service_control_report_timeout_ms", 0, `Set the timeout in millisecond for service control Report request. Must be > 0 and the default is 2000 if not set.`)
 
+	ScCheckTimeout = flag.Duration("service_control_check_timeout", 0, `Set the timeout for service control Check request, e.g. "500ms". If set, it overrides --service_control_check_timeout_ms.
+	After the timeout, the request fails, or is allowed with the network fail open policy of the service config.`)
+
 	ScCheckRetries  = flag.Int("service_control_check_retries", -1, `Set the retry times for service control Check request. Must be >= 0 and the default is 3 if not set.`)
 	ScQuotaRetries  = flag.Int("service_control_quota_retries", -1, `Set the retry times for service control Quota request. Must be >= 0 and the default is 1 if not set.`)
 	ScReportRetries = flag.Int("service_control_report_retries", -1, `Set the retry times for service control Report request. Must be >= 0 and the default is 5 if not set.`)
 
+	ScReportRetryBackoffMs = flag.Int("service_control_report_retry_backoff_ms", -1, `Set the initial backoff in millisecond between retries of service control Report request, it doubles on each retry.
+	Must be >= 0 and the default is 100 if not set.`)
+	ScMaxPendingReports = flag.Int("service_control_max_pending_reports", 0, `Set the maximum number of service control Report requests in flight, including the ones waiting to be retried.
+	Further reports are dropped until some requests finish. The bound applies to each Envoy worker thread separately.
+	Must be > 0 and the default is 1000 if not set.`)
+	ScReportFlushJitterMs = flag.Int("service_control_report_flush_jitter_ms", 0, `Set the maximum random delay in millisecond added to each periodic flush of the aggregated service control
+	Report requests, so that instances started at the same time don't flush at the same time. The default is 0, i.e. no jitter.`)
+
 	ComputePlatformOverride = flag.String("compute_platform_override", "", "the overridden platform where the proxy is running at")
 
+	ScOperationNameStripPrefix = flag.String("service_control_operation_name_strip_prefix", "", `Strip this prefix from the operation names in service control Report calls,
+	e.g. "1.echo_api_endpoints_cloudesf_testing_cloud_goog.". Routing, Check and Quota calls still use the full selectors.`)
+	ScOperationNameMap = flag.String("service_control_operation_name_map", "", `Report operations under different names in service control Report calls, as comma-separated pairs of
+	selector=name, e.g. "1.echo_api.Echo=Echo,1.echo_api.Root=Root". It takes precedence over --service_control_operation_name_strip_prefix.`)
+	ScReportApiVersionFromPath = flag.Bool("service_control_report_api_version_from_path", false, `Report the version segment of the route path, e.g. "v2" for "/v2/shelves",
+	as the API version to service control. Routes without such a segment report the version of their API.`)
+
 	// Flags for testing purpose. They are not exposed to the user via start_proxy.py
 	SkipJwtAuthnFilter       = flag.Bool("skip_jwt_authn_filter", false, "skip jwt authn filter, for test purpose")
 	SkipServiceControlFilter = flag.Bool("skip_service_control_filter", false, "skip service control filter, for test purpose")
 	StreamIdleTimeout        = flag.Duration("stream_idle_timeout_test_only", util.DefaultIdleTimeout, "The amount of time HTTP/2 streams can exist without any activity. "+
 		"Set `deadline` in the service config to override this global value on a per-route basis.")
 
+	DisableTranscoding = flag.Bool("disable_transcoding", false, `If enabled, the gRPC-JSON transcoder filter is not added for gRPC backends.
+	Only native gRPC (and gRPC-Web) requests are supported, HTTP/JSON requests are forwarded to the backend without transcoding.`)
+
 	TranscodingAlwaysPrintPrimitiveFields   = flag.Bool("transcoding_always_print_primitive_fields", false, "Whether to always print primitive fields for grpc-json transcoding")
 	TranscodingAlwaysPrintEnumsAsInts       = flag.Bool("transcoding_always_print_enums_as_ints", false, "Whether to always print enums as ints for grpc-json transcoding")
 	TranscodingPreserveProtoFieldNames      = flag.Bool("transcoding_preserve_proto_field_names", false, "Whether to preserve proto field names for grpc-json transcoding")
 	TranscodingIgnoreQueryParameters        = flag.String("transcoding_ignore_query_parameters", "", "A list of query parameters(separated by comma) to be ignored for transcoding method mapping in grpc-json transcoding.")
 	TranscodingIgnoreUnknownQueryParameters = flag.Bool("transcoding_ignore_unknown_query_parameters", false, "Whether to ignore query parameters that cannot be mapped to a corresponding protobuf field in grpc-json transcoding.")
+	TranscodingMatchIncomingRequestRoute    = flag.Bool("transcoding_match_incoming_request_route", false, "Whether to keep the route matched by the incoming HTTP request after grpc-json transcoding, instead of re-matching the route with the gRPC path.")
+	TranscodingMaxMessageBytes              = flag.Int("transcoding_max_message_bytes", 0, `The maximum size of a request or response message in grpc-json transcoding.
+        The transcoder buffers whole messages within the request buffer limit, which is raised to this size on the routes to gRPC backends.
+        If not provided, messages are limited by --connection_buffer_limit_bytes. Cannot be used with --disable_transcoding.`)
 
 	BackendRetryOns = flag.String("backend_retry_ons", "reset,connect-failure,refused-stream",
 		`The conditions under which ESPv2 does retry on the backends. One or more
@@ -182,6 +353,76 @@ var (
         addition to the status codes enabled for retry through other retry
         policies set in "--backend_retry_ons".
         The format is a comma-delimited String, like "501, 503`)
+	BackendAuthStaticTokenFiles = flag.String("backend_auth_static_token_files", "",
+		`Send a static bearer token read from a file as the Authorization header to the
+        backend, as comma-separated pairs of selector=path, e.g. "1.echo_api.Echo=/etc/token".
+        The file is reloaded whenever it changes. It takes precedence over the jwt_audience
+        of the backend rule for the given operations.`)
+	BackendAuthTokenHeaders = flag.String("backend_auth_token_headers", "",
+		`Send the backend auth token in a custom request header instead of the Authorization header, as
+        comma-separated pairs of selector=header, e.g. "1.echo_api.Echo=X-Serverless-Authorization".
+        The token is sent as "Bearer <token>" and the Authorization header is left unchanged.`)
+	BackendHostRewrite = flag.String("backend_host_rewrite", "",
+		`Rewrite the Host header sent to all backends, e.g. "api.example.com", so that the backend is
+        presented a different authority than the client used. Service control and routing still use the
+        Host header sent by the client. It is overridden by --backend_host_rewrite_by_operation for the given operations.`)
+	BackendHostRewriteByOperation = flag.String("backend_host_rewrite_by_operation", "",
+		`Rewrite the Host header sent to the backend, as comma-separated pairs of selector=host, e.g.
+        "1.echo_api.Echo=api.example.com". By default, the Host header is rewritten to the hostname of
+        the backend rule address for remote backends, and kept as is for the local backend.`)
+	JwtClaimBackendRoutes = flag.String("jwt_claim_backend_routes", "",
+		`Route requests whose verified JWT has a string claim with the given value to a dedicated
+        backend, as comma-separated triples of claim:value=backend_address, e.g.
+        "tier:enterprise=https://enterprise-backend.example.com". Other requests use the usual
+        backend. Requires the service control filter.`)
+	SkipServiceControlPaths = flag.String("skip_service_control_paths", "", `Comma-separated paths, e.g. "/internal/health", that are routed to the backend for
+        any HTTP method without service control Check and Report, and without requiring an API key. The paths may be
+        URI templates, and must not be defined by the service config.`)
+	StaticResponses = flag.String("static_responses", "", `Comma-separated paths served by ESPv2 itself for any HTTP method, without the backend
+        and service control, e.g. "/favicon.ico=/etc/espv2/favicon.ico,/robots.txt". A path with a file gets 200 with the
+        file content, one without a file gets 204. The paths must not be defined by the service config.`)
+	MirrorBackendAddress = flag.String("mirror_backend_address", "", `Mirror requests to backends to this address, e.g. "https://shadow-backend.example.com".
+        Responses from the mirror backend are discarded and do not affect the client.`)
+	MirrorPercent          = flag.Float64("mirror_percent", 100, `The percentage of requests, from 0 to 100, that are mirrored to --mirror_backend_address.`)
+	BackendFallbackAddress = flag.String("backend_fallback_address", "", `Route requests for --backend_address to this address when none of its hosts is healthy, e.g.
+        "http://read-only-backend:8080". Both backends are health checked by TCP connect, unless --backend_health_check_path is set, and responses from the fallback
+        backend carry the X-Endpoint-Backend-Fallback header. It must use the same scheme as --backend_address.`)
+	BackendFallbackAddressByOperation = flag.String("backend_fallback_address_by_operation", "", `Route requests of operations to these addresses when none of the hosts of
+        their backend is healthy, separated by comma, e.g. "selector1=https://read-only-backend:8443". The fallback is set on the backend cluster,
+        so operations sharing a backend must use the same fallback address, and it shares the protocol and TLS settings of the backend.`)
+	BackendHealthCheckPath = flag.String("backend_health_check_path", "", `If set, the backends with a fallback are health checked by HTTP GET requests
+        to this path, e.g. "/healthz", instead of TCP connect.`)
+	BackendHealthCheckHost = flag.String("backend_health_check_host", "", `The Host header of the health checks by --backend_health_check_path. Defaults to the
+        cluster name of the backend.`)
+	BackendHealthCheckExpectedStatuses = flag.String("backend_health_check_expected_statuses", "", `Comma-separated HTTP status codes, e.g. "200,204", of the health
+        checks by --backend_health_check_path that mark a backend healthy. Defaults to 200.`)
+	DisableChunkedEncodingBackends = flag.String("disable_chunked_encoding_backends", "", `Comma-separated backend addresses, e.g. "https://legacy-backend.example.com", that cannot
+        handle chunked requests. Requests to them are buffered, up to 10 MB, and sent with a Content-Length. The
+        addresses are either --backend_address or backend addresses in the service config.`)
+	StreamingPassthroughOperations = flag.String("streaming_passthrough_operations", "", `Comma-separated selectors of operations whose requests are streamed to the backend,
+        e.g. large uploads. Their requests are neither buffered, even if the backend is in --disable_chunked_encoding_backends,
+        nor transcoded to gRPC.`)
+	StreamingResponseOperations = flag.String("streaming_response_operations", "", `Comma-separated selectors of operations whose responses are streamed to the client as
+        they arrive, e.g. Server-Sent Events. Their responses are not held back by --enable_response_compression, and the deadline
+        only bounds the wait for each chunk, through the stream idle timeout, instead of the whole response.`)
+	MaxRequestBytesByOperation = flag.String("max_request_bytes_by_operation", "", `Limit the request body size of operations in bytes, separated by comma,
+        e.g. "selector1=1048576,selector2=52428800". Requests of these operations are buffered up to the limit, and larger
+        ones are rejected with 413. It is not supported for --streaming_passthrough_operations.`)
+	QueryParameterAllowlistByOperation = flag.String("query_parameter_allowlist_by_operation", "", `Allow only the listed query parameters in requests of operations, separated by comma,
+        e.g. "selector1=shelf|page_size,selector2=filter". Requests with any other query parameter are rejected with 400.
+        The API key and JWT query parameters, and the ones in --transcoding_ignore_query_parameters, are always allowed.`)
+
+	FaultAbortPercent = flag.Float64("fault_abort_percent", 0, `For chaos testing, the percentage of requests, from 0 to 100, that are aborted by ESPv2
+        with --fault_abort_status instead of being sent to the backend. Disabled by default.`)
+	FaultAbortStatus  = flag.Int("fault_abort_status", 503, `The HTTP status code of requests aborted by --fault_abort_percent.`)
+	FaultDelayPercent = flag.Float64("fault_delay_percent", 0, `For chaos testing, the percentage of requests, from 0 to 100, that are delayed by
+        --fault_delay_duration before being sent to the backend. Disabled by default.`)
+	FaultDelayDuration = flag.Duration("fault_delay_duration", 0, `The delay injected into requests selected by --fault_delay_percent, e.g. "2s".`)
+	FaultHeader        = flag.String("fault_header", "", `If set, faults are only injected into requests with this header, e.g. "x-inject-fault".
+        Otherwise faults are injected into all requests, except health checks.`)
+	FaultApiKeys = flag.String("fault_api_keys", "", `Comma-separated API keys, e.g. "qa-test-key". If set, faults are only injected into requests
+        with one of these API keys, in any of the API key locations of the service config. API keys in cookies are not
+        matched. Can be combined with --fault_header.`)
 )
 
 func EnvoyConfigOptionsFromFlags() options.ConfigGeneratorOptions {
@@ -191,7 +432,12 @@ func EnvoyConfigOptionsFromFlags() options.ConfigGeneratorOptions {
 		EnableBackendAddressOverride:            *EnableBackendAddressOverride,
 		AccessLog:                               *AccessLog,
 		AccessLogFormat:                         *AccessLogFormat,
+		AccessLogGrpcAddress:                    *AccessLogGrpcAddress,
+		AccessLogGrpcLogName:                    *AccessLogGrpcLogName,
 		ComputePlatformOverride:                 *ComputePlatformOverride,
+		ScOperationNameStripPrefix:              *ScOperationNameStripPrefix,
+		ScOperationNameMap:                      *ScOperationNameMap,
+		ScReportApiVersionFromPath:              *ScReportApiVersionFromPath,
 		CorsAllowCredentials:                    *CorsAllowCredentials,
 		CorsAllowHeaders:                        *CorsAllowHeaders,
 		CorsAllowMethods:                        *CorsAllowMethods,
@@ -200,8 +446,16 @@ func EnvoyConfigOptionsFromFlags() options.ConfigGeneratorOptions {
 		CorsExposeHeaders:                       *CorsExposeHeaders,
 		CorsMaxAge:                              *CorsMaxAge,
 		CorsPreset:                              *CorsPreset,
+		CorsDisabledOperations:                  *CorsDisabledOperations,
 		BackendDnsLookupFamily:                  *BackendDnsLookupFamily,
 		ClusterConnectTimeout:                   *ClusterConnectTimeout,
+		BackendConnectTimeout:                   *BackendConnectTimeout,
+		BackendConnectTimeoutByOperation:        *BackendConnectTimeoutByOperation,
+		BackendSniByOperation:                   *BackendSniByOperation,
+		BackendAlpnByOperation:                  *BackendAlpnByOperation,
+		BackendStripPrefixByOperation:           *BackendStripPrefixByOperation,
+		BackendRegexRewriteByOperation:          *BackendRegexRewriteByOperation,
+		MaxGrpcTimeout:                          *MaxGrpcTimeout,
 		StreamIdleTimeout:                       *StreamIdleTimeout,
 		ListenerAddress:                         *ListenerAddress,
 		ServiceManagementURL:                    *ServiceManagementURL,
@@ -215,6 +469,13 @@ func EnvoyConfigOptionsFromFlags() options.ConfigGeneratorOptions {
 		SslServerCertPath:                       *SslServerCertPath,
 		SslServerCipherSuites:                   *SslServerCipherSuites,
 		SslServerRootCertPath:                   *SslServerRootCertsPath,
+		SslServerSpiffeTrustBundlePath:          *SslServerSpiffeTrustBundlePath,
+		SslServerAllowedSpiffeIds:               *SslServerAllowedSpiffeIds,
+		ClientCrlFile:                           *ClientCrlFile,
+		ClientCrlSdsPath:                        *ClientCrlSdsPath,
+		ClientCrlReloadInterval:                 *ClientCrlReloadInterval,
+		ForwardClientCertDetails:                *ForwardClientCertDetails,
+		SetCurrentClientCertDetails:             *SetCurrentClientCertDetails,
 		SslMinimumProtocol:                      *SslMinimumProtocol,
 		SslMaximumProtocol:                      *SslMaximumProtocol,
 		EnableHSTS:                              *EnableHSTS,
@@ -224,6 +485,8 @@ func EnvoyConfigOptionsFromFlags() options.ConfigGeneratorOptions {
 		AddResponseHeaders:                      *AddResponseHeaders,
 		AppendResponseHeaders:                   *AppendResponseHeaders,
 		EnableOperationNameHeader:               *EnableOperationNameHeader,
+		ResponseHeadersAllowlist:                *ResponseHeadersAllowlist,
+		EnableResponseCompression:               *EnableResponseCompression,
 		ServiceAccountKey:                       *ServiceAccountKey,
 		TokenAgentPort:                          *TokenAgentPort,
 		DisableOidcDiscovery:                    *DisableOidcDiscovery,
@@ -232,38 +495,107 @@ func EnvoyConfigOptionsFromFlags() options.ConfigGeneratorOptions {
 		SkipServiceControlFilter:                *SkipServiceControlFilter,
 		EnvoyUseRemoteAddress:                   *EnvoyUseRemoteAddress,
 		EnvoyXffNumTrustedHops:                  *EnvoyXffNumTrustedHops,
+		EnableOperationStats:                    *EnableOperationStats,
+		EnableConsumerStats:                     *EnableConsumerStats,
 		LogJwtPayloads:                          *LogJwtPayloads,
 		LogRequestHeaders:                       *LogRequestHeaders,
 		LogResponseHeaders:                      *LogResponseHeaders,
+		ServiceControlExtraLabels:               *ServiceControlExtraLabels,
+		ServiceControlJwtClaimLabels:            *ServiceControlJwtClaimLabels,
+		LogEntryFields:                          *LogEntryFields,
+		ServiceControlCredentialIdPrefix:        *ServiceControlCredentialIdPrefix,
+		ServiceControlSuccessStatusCodes:        *ServiceControlSuccessStatusCodes,
+		ServiceControlUnmatchedOperation:        *ServiceControlUnmatchedOperation,
+		RemoveApiKeyFromRequest:                 *RemoveApiKeyFromRequest,
 		MinStreamReportIntervalMs:               *MinStreamReportIntervalMs,
+		ServiceControlStatsReport:               *ServiceControlStatsReport,
+		ServiceControlStatsReportInterval:       *ServiceControlStatsReportInterval,
 		SuppressEnvoyHeaders:                    *SuppressEnvoyHeaders,
 		UnderscoresInHeaders:                    *UnderscoresInHeaders,
+		MaxRequestHeadersKb:                     *MaxRequestHeadersKb,
+		MaxRequestDuration:                      *MaxRequestDuration,
 		NormalizePath:                           *NormalizePath,
 		MergeSlashesInPath:                      *MergeSlashesInPath,
 		DisallowEscapedSlashesInPath:            *DisallowEscapedSlashesInPath,
+		DecodeEscapedSlashesInPath:              *DecodeEscapedSlashesInPath,
+		AllowDuplicateHttpPatterns:              *AllowDuplicateHttpPatterns,
+		RequireRequestHeaders:                   *RequireRequestHeaders,
 		ServiceControlNetworkFailOpen:           *ServiceControlNetworkFailOpen,
 		EnableGrpcForHttp1:                      *EnableGrpcForHttp1,
 		ConnectionBufferLimitBytes:              *ConnectionBufferLimitBytes,
+		EnableProxyProtocol:                     *EnableProxyProtocol,
+		AcceptHttp10:                            *AcceptHttp10,
+		DefaultHostForHttp10:                    *DefaultHostForHttp10,
+		ListenerTcpKeepaliveTime:                *ListenerTcpKeepaliveTime,
+		ListenerTcpKeepaliveInterval:            *ListenerTcpKeepaliveInterval,
+		ListenerTcpKeepaliveProbes:              *ListenerTcpKeepaliveProbes,
+		ListenerHttp2KeepaliveInterval:          *ListenerHttp2KeepaliveInterval,
+		ListenerHttp2KeepaliveTimeout:           *ListenerHttp2KeepaliveTimeout,
+		ListenerHttp2MaxConcurrentStreams:       *ListenerHttp2MaxConcurrentStreams,
+		EnableReusePort:                         *EnableReusePort,
+		BackendUnavailableMessage:               *BackendUnavailableMessage,
 		DisableJwksAsyncFetch:                   *DisableJwksAsyncFetch,
+		JwksWarmOnStartup:                       *JwksWarmOnStartup,
 		JwksCacheDurationInS:                    *JwksCacheDurationInS,
+		AuthBypassFile:                          *AuthBypassFile,
+		AuthBypassReloadInterval:                *AuthBypassReloadInterval,
+		JwksCacheDurationByProvider:             *JwksCacheDurationByProvider,
+		JwtSkipAudienceCheck:                    *JwtSkipAudienceCheck,
+		JwtProviderAdditionalIssuers:            *JwtProviderAdditionalIssuers,
 		JwksFetchNumRetries:                     *JwksFetchNumRetries,
 		JwksFetchRetryBackOffBaseInterval:       time.Duration(*JwksFetchRetryBackOffBaseIntervalMs) * time.Millisecond,
 		JwksFetchRetryBackOffMaxInterval:        time.Duration(*JwksFetchRetryBackOffMaxIntervalMs) * time.Millisecond,
+		TrustPreauthenticatedJwtHeader:          *TrustPreauthenticatedJwtHeader,
+		CheckApiKeyBeforeJwt:                    *CheckApiKeyBeforeJwt,
 		BackendRetryOns:                         *BackendRetryOns,
 		BackendRetryNum:                         *BackendRetryNum,
 		BackendPerTryTimeout:                    *BackendPerTryTimeout,
 		BackendRetryOnStatusCodes:               *BackendRetryOnStatusCodes,
+		BackendAuthStaticTokenFiles:             *BackendAuthStaticTokenFiles,
+		BackendAuthTokenHeaders:                 *BackendAuthTokenHeaders,
+		BackendHostRewrite:                      *BackendHostRewrite,
+		BackendHostRewriteByOperation:           *BackendHostRewriteByOperation,
+		JwtClaimBackendRoutes:                   *JwtClaimBackendRoutes,
+		SkipServiceControlPaths:                 *SkipServiceControlPaths,
+		StaticResponses:                         *StaticResponses,
+		MirrorBackendAddress:                    *MirrorBackendAddress,
+		MirrorPercent:                           *MirrorPercent,
+		BackendFallbackAddress:                  *BackendFallbackAddress,
+		BackendFallbackAddressByOperation:       *BackendFallbackAddressByOperation,
+		BackendHealthCheckPath:                  *BackendHealthCheckPath,
+		BackendHealthCheckHost:                  *BackendHealthCheckHost,
+		BackendHealthCheckExpectedStatuses:      *BackendHealthCheckExpectedStatuses,
+		DisableChunkedEncodingBackends:          *DisableChunkedEncodingBackends,
+		StreamingPassthroughOperations:          *StreamingPassthroughOperations,
+		StreamingResponseOperations:             *StreamingResponseOperations,
+		MaxRequestBytesByOperation:              *MaxRequestBytesByOperation,
+		QueryParameterAllowlistByOperation:      *QueryParameterAllowlistByOperation,
+		FaultAbortPercent:                       *FaultAbortPercent,
+		FaultAbortStatus:                        *FaultAbortStatus,
+		FaultDelayPercent:                       *FaultDelayPercent,
+		FaultDelayDuration:                      *FaultDelayDuration,
+		FaultHeader:                             *FaultHeader,
+		FaultApiKeys:                            *FaultApiKeys,
 		ScCheckTimeoutMs:                        *ScCheckTimeoutMs,
 		ScQuotaTimeoutMs:                        *ScQuotaTimeoutMs,
 		ScReportTimeoutMs:                       *ScReportTimeoutMs,
 		ScCheckRetries:                          *ScCheckRetries,
 		ScQuotaRetries:                          *ScQuotaRetries,
 		ScReportRetries:                         *ScReportRetries,
+		ScReportRetryBackoffMs:                  *ScReportRetryBackoffMs,
+		ScMaxPendingReports:                     *ScMaxPendingReports,
+		ScReportFlushJitterMs:                   *ScReportFlushJitterMs,
+		DisableTranscoding:                      *DisableTranscoding,
 		TranscodingAlwaysPrintPrimitiveFields:   *TranscodingAlwaysPrintPrimitiveFields,
 		TranscodingAlwaysPrintEnumsAsInts:       *TranscodingAlwaysPrintEnumsAsInts,
 		TranscodingPreserveProtoFieldNames:      *TranscodingPreserveProtoFieldNames,
 		TranscodingIgnoreQueryParameters:        *TranscodingIgnoreQueryParameters,
 		TranscodingIgnoreUnknownQueryParameters: *TranscodingIgnoreUnknownQueryParameters,
+		TranscodingMatchIncomingRequestRoute:    *TranscodingMatchIncomingRequestRoute,
+		TranscodingMaxMessageBytes:              *TranscodingMaxMessageBytes,
+	}
+	if *ScCheckTimeout > 0 {
+		opts.ScCheckTimeoutMs = int(ScCheckTimeout.Milliseconds())
 	}
 
 	glog.Infof("Config Generator options: %+v", opts)
@@ -15,6 +15,7 @@
 package flags
 
 import (
+	"flag"
 	"reflect"
 	"testing"
 
@@ -30,3 +31,46 @@ func TestDefaultEnvoyConfigOptions(t *testing.T) {
 			defaultOptions, actualOptions)
 	}
 }
+
+func TestServiceControlCheckTimeout(t *testing.T) {
+	testData := []struct {
+		desc                 string
+		checkTimeoutMs       string
+		checkTimeout         string
+		wantScCheckTimeoutMs int
+	}{
+		{
+			desc:                 "Timeout in milliseconds",
+			checkTimeoutMs:       "100",
+			wantScCheckTimeoutMs: 100,
+		},
+		{
+			desc:                 "Timeout as a duration",
+			checkTimeout:         "1.5s",
+			wantScCheckTimeoutMs: 1500,
+		},
+		{
+			desc:                 "Timeout as a duration overrides the one in milliseconds",
+			checkTimeoutMs:       "100",
+			checkTimeout:         "500ms",
+			wantScCheckTimeoutMs: 500,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			defer flag.Set("service_control_check_timeout_ms", "0")
+			defer flag.Set("service_control_check_timeout", "0")
+			if tc.checkTimeoutMs != "" {
+				_ = flag.Set("service_control_check_timeout_ms", tc.checkTimeoutMs)
+			}
+			if tc.checkTimeout != "" {
+				_ = flag.Set("service_control_check_timeout", tc.checkTimeout)
+			}
+
+			if got := EnvoyConfigOptionsFromFlags().ScCheckTimeoutMs; got != tc.wantScCheckTimeoutMs {
+				t.Errorf("got ScCheckTimeoutMs %v, want %v", got, tc.wantScCheckTimeoutMs)
+			}
+		})
+	}
+}
@@ -53,7 +53,11 @@ func main() {
 	if err != nil {
 		glog.Exitf("fail to initialize config manager: %v", err)
 	}
-	server := xds.NewServer(ctx, m.Cache(), nil)
+	server := xds.NewServer(ctx, m.Cache(), xds.CallbackFuncs{
+		StreamRequestFunc:  m.OnStreamRequest,
+		StreamResponseFunc: m.OnStreamResponse,
+		StreamClosedFunc:   m.OnStreamClosed,
+	})
 	grpcServer := grpc.NewServer()
 	lis, err := net.Listen("unix", opts.AdsNamedPipe)
 	if err != nil {
@@ -30,7 +30,53 @@ const (
                         "code": "%RESPONSE_CODE%",
                         "message":"%LOCAL_REPLY_BODY%"
                       }
-                    }
+                    },
+                    "mappers": [
+                      {
+                        "body": {
+                          "inlineString": "Request headers, including any JWT, exceed the 60 KB limit."
+                        },
+                        "filter": {
+                          "andFilter": {
+                            "filters": [
+                              {
+                                "statusCodeFilter": {
+                                  "comparison": {
+                                    "value": {
+                                      "defaultValue": 431,
+                                      "runtimeKey": "espv2.request_headers_too_large"
+                                    }
+                                  }
+                                }
+                              },
+                              {
+                                "responseFlagFilter": {
+                                  "flags": [
+                                    "DPE"
+                                  ]
+                                }
+                              }
+                            ]
+                          }
+                        },
+                        "statusCode": 413
+                      },
+                      {
+                        "body": {
+                          "inlineString": "Request body exceeds the buffer limit."
+                        },
+                        "filter": {
+                          "statusCodeFilter": {
+                            "comparison": {
+                              "value": {
+                                "defaultValue": 413,
+                                "runtimeKey": "espv2.request_body_too_large"
+                              }
+                            }
+                          }
+                        }
+                      }
+                    ]
                   }`
 )
 
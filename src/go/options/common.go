@@ -26,7 +26,19 @@ type CommonOptions struct {
 	AdminPort             int
 	AdsNamedPipe          string
 	Node                  string
+	NodeCluster           string
 	GeneratedHeaderPrefix string
+	EnvoyRuntime          string
+
+	// Flags for the overload manager
+	OverloadMaxHeapSizeBytes                  uint64
+	OverloadStopAcceptingRequestsThreshold    float64
+	OverloadStopAcceptingConnectionsThreshold float64
+
+	// Flags for the statsd stats sink
+	StatsdAddress   string
+	StatsdPrefix    string
+	EnableDogStatsd bool
 
 	// Flags for tracing
 	DisableTracing             bool
@@ -95,5 +107,8 @@ func DefaultCommonOptions() CommonOptions {
 		MetadataURL:                "http://169.254.169.254",
 		IamURL:                     "https://iamcredentials.googleapis.com",
 		GeneratedHeaderPrefix:      "X-Endpoint-",
+
+		OverloadStopAcceptingRequestsThreshold:    0.95,
+		OverloadStopAcceptingConnectionsThreshold: 0.98,
 	}
 }
@@ -37,6 +37,9 @@ type ConfigGeneratorOptions struct {
 	CorsExposeHeaders    string
 	CorsMaxAge           time.Duration
 	CorsPreset           string
+	// Comma-separated selectors that get no auto-generated CORS preflight
+	// routes when the endpoint sets allow_cors.
+	CorsDisabledOperations string
 
 	// Backend routing configurations.
 	BackendDnsLookupFamily string
@@ -44,6 +47,28 @@ type ConfigGeneratorOptions struct {
 	// Envoy specific configurations.
 	ClusterConnectTimeout time.Duration
 	StreamIdleTimeout     time.Duration
+	// Connect timeout for backend clusters, ClusterConnectTimeout if it is 0.
+	// The precedence for a backend cluster is BackendConnectTimeoutByOperation,
+	// then BackendConnectTimeout, then ClusterConnectTimeout.
+	BackendConnectTimeout time.Duration
+	// Comma-separated selector=duration overrides of BackendConnectTimeout.
+	// They apply to the whole cluster of the operation's backend.
+	BackendConnectTimeoutByOperation string
+	// Comma-separated selector=sni overrides of the TLS SNI sent to the
+	// operation's backend, which is its hostname by default.
+	BackendSniByOperation string
+	// Comma-separated selector=alpn overrides of the ALPN protocol advertised
+	// to the operation's HTTPS backend, either "h2" or "http/1.1".
+	BackendAlpnByOperation string
+	// Comma-separated selector=prefix path prefixes removed from the request
+	// path before it is sent to the operation's backend.
+	BackendStripPrefixByOperation string
+	// Comma-separated selector=pattern=substitution regex rewrites of the
+	// path sent to the operation's backend.
+	BackendRegexRewriteByOperation string
+	// Cap of the grpc-timeout honored for gRPC requests. The grpc-timeout is
+	// not honored if it is 0.
+	MaxGrpcTimeout time.Duration
 
 	// Full URI to the backend: scheme, address/hostname, port
 	BackendAddress               string
@@ -58,6 +83,11 @@ type ConfigGeneratorOptions struct {
 	SslServerCertPath                string
 	SslServerCipherSuites            string
 	SslServerRootCertPath            string
+	SslServerSpiffeTrustBundlePath   string
+	SslServerAllowedSpiffeIds        string
+	ClientCrlFile                    string
+	ClientCrlSdsPath                 string
+	ClientCrlReloadInterval          time.Duration
 	SslMinimumProtocol               string
 	SslMaximumProtocol               string
 	EnableHSTS                       bool
@@ -67,12 +97,22 @@ type ConfigGeneratorOptions struct {
 	SslBackendClientCipherSuites     string
 	DnsResolverAddresses             string
 
+	// X-Forwarded-Client-Cert handling for mTLS clients. ForwardClientCertDetails
+	// is one of "", "sanitize", "forward_only", "append_forward", "sanitize_set"
+	// or "always_forward_only".
+	ForwardClientCertDetails    string
+	SetCurrentClientCertDetails string
+
 	// Headers manipulation:
 	AddRequestHeaders         string
 	AppendRequestHeaders      string
 	AddResponseHeaders        string
 	AppendResponseHeaders     string
 	EnableOperationNameHeader bool
+	// Comma-separated response headers forwarded to clients, all others are removed.
+	ResponseHeadersAllowlist string
+	// Compress responses with gzip if the client accepts it.
+	EnableResponseCompression bool
 
 	// Flags for non_gcp deployment.
 	ServiceAccountKey string
@@ -89,23 +129,73 @@ type ConfigGeneratorOptions struct {
 	// Envoy configurations.
 	AccessLog       string
 	AccessLogFormat string
+	// Stream the access log entries to a gRPC access log service, with the
+	// log name identifying ESPv2 in the service.
+	AccessLogGrpcAddress string
+	AccessLogGrpcLogName string
 
 	EnvoyUseRemoteAddress  bool
 	EnvoyXffNumTrustedHops int
 
-	LogJwtPayloads            string
-	LogRequestHeaders         string
-	LogResponseHeaders        string
-	MinStreamReportIntervalMs uint64
+	// Emit per-operation request counts and latencies via Envoy virtual clusters.
+	EnableOperationStats bool
+	// Emit per-consumer request counts and latencies from the Service Control filter.
+	EnableConsumerStats bool
+
+	LogJwtPayloads                   string
+	LogRequestHeaders                string
+	LogResponseHeaders               string
+	ServiceControlExtraLabels        string
+	ServiceControlJwtClaimLabels     string
+	LogEntryFields                   string
+	ServiceControlCredentialIdPrefix string
+	ServiceControlSuccessStatusCodes string
+	ServiceControlUnmatchedOperation string
+	RemoveApiKeyFromRequest          bool
+	MinStreamReportIntervalMs        uint64
+
+	// Periodically report the selected Envoy stats to service control.
+	ServiceControlStatsReport         string
+	ServiceControlStatsReportInterval time.Duration
 
 	SuppressEnvoyHeaders          bool
 	UnderscoresInHeaders          bool
+	MaxRequestHeadersKb           int
+	MaxRequestDuration            time.Duration
 	NormalizePath                 bool
 	MergeSlashesInPath            bool
 	DisallowEscapedSlashesInPath  bool
+	DecodeEscapedSlashesInPath    bool
+	RequireRequestHeaders         string
 	ServiceControlNetworkFailOpen bool
 	EnableGrpcForHttp1            bool
 	ConnectionBufferLimitBytes    int
+	EnableProxyProtocol           bool
+
+	// Keep the first of the http rules with the same http pattern, instead of
+	// failing the config generation.
+	AllowDuplicateHttpPatterns bool
+
+	// Accept HTTP/1.0 requests, using DefaultHostForHttp10 if they have no Host header.
+	AcceptHttp10         bool
+	DefaultHostForHttp10 string
+
+	// Listener socket configurations.
+	ListenerTcpKeepaliveTime     time.Duration
+	ListenerTcpKeepaliveInterval time.Duration
+	ListenerTcpKeepaliveProbes   int
+	EnableReusePort              bool
+
+	// HTTP/2 PING keepalive toward downstream clients, e.g. gRPC clients.
+	ListenerHttp2KeepaliveInterval time.Duration
+	ListenerHttp2KeepaliveTimeout  time.Duration
+
+	// Maximum concurrent streams per downstream HTTP/2 connection, the Envoy
+	// default if it is 0.
+	ListenerHttp2MaxConcurrentStreams int
+	// Message of the local replies when the backend is unavailable, the
+	// Envoy default if it is empty.
+	BackendUnavailableMessage string
 
 	// JwtAuthn related flags
 	DisableJwksAsyncFetch             bool
@@ -113,6 +203,29 @@ type ConfigGeneratorOptions struct {
 	JwksFetchNumRetries               int
 	JwksFetchRetryBackOffBaseInterval time.Duration
 	JwksFetchRetryBackOffMaxInterval  time.Duration
+	TrustPreauthenticatedJwtHeader    string
+	// One of "", "optional" or "required".
+	JwksWarmOnStartup string
+	// Comma-separated provider_id=seconds pairs overriding JwksCacheDurationInS.
+	JwksCacheDurationByProvider string
+	// Comma-separated provider ids whose JWT audiences are not checked.
+	JwtSkipAudienceCheck string
+	// Comma-separated provider_id=issuer|jwks_uri entries, each adding an
+	// issuer accepted by the provider.
+	JwtProviderAdditionalIssuers string
+	// Whether the API key is checked, by the Service Control filter, before
+	// the JWT is verified. The JWT is verified first by default.
+	CheckApiKeyBeforeJwt bool
+	// File listing the operations whose JWT and API key checks are removed at
+	// runtime, checked for changes at each AuthBypassReloadInterval.
+	AuthBypassFile           string
+	AuthBypassReloadInterval time.Duration
+
+	// Comma-separated paths that are routed to the backend without service control.
+	SkipServiceControlPaths string
+	// Comma-separated path=file entries served by Envoy without the backend and
+	// service control. Paths without a file get an empty 204 response.
+	StaticResponses string
 
 	ScCheckTimeoutMs  int
 	ScQuotaTimeoutMs  int
@@ -122,18 +235,79 @@ type ConfigGeneratorOptions struct {
 	BackendRetryNum           uint
 	BackendPerTryTimeout      time.Duration
 	BackendRetryOnStatusCodes string
-	ScCheckRetries            int
-	ScQuotaRetries            int
-	ScReportRetries           int
+	// Comma-separated selector=path pairs of static bearer token files.
+	BackendAuthStaticTokenFiles string
+	// Comma-separated selector=header pairs of headers carrying the backend auth token.
+	BackendAuthTokenHeaders string
+	// Host header sent to all backends, unless overridden by BackendHostRewriteByOperation.
+	BackendHostRewrite string
+	// Comma-separated selector=host pairs of Host header overrides.
+	BackendHostRewriteByOperation string
+	// Comma-separated claim:value=backend_address routes.
+	JwtClaimBackendRoutes string
+	MirrorBackendAddress  string
+	MirrorPercent         float64
+	// Address of the backend used when no host of BackendAddress is healthy.
+	BackendFallbackAddress string
+	// Comma-separated selector=address pairs of the backends used when no
+	// host of the backend of the operation is healthy.
+	BackendFallbackAddressByOperation string
+	// Path of the HTTP health checks of the backends with a fallback.
+	BackendHealthCheckPath string
+	// Host header of the HTTP health checks.
+	BackendHealthCheckHost string
+	// Comma-separated HTTP status codes of healthy backends.
+	BackendHealthCheckExpectedStatuses string
+	// Comma-separated backend addresses that cannot handle chunked requests.
+	DisableChunkedEncodingBackends string
+	// Comma-separated selectors of operations that are neither buffered nor transcoded.
+	StreamingPassthroughOperations string
+	// Comma-separated selectors of operations whose responses are streamed to the client.
+	StreamingResponseOperations string
+	// Comma-separated selector=bytes limits of the request body size.
+	MaxRequestBytesByOperation string
+	// Comma-separated selector=param1|param2 query parameters allowed in requests.
+	QueryParameterAllowlistByOperation string
+
+	// Fault injection for chaos testing, disabled when both percentages are 0.
+	FaultAbortPercent  float64
+	FaultAbortStatus   int
+	FaultDelayPercent  float64
+	FaultDelayDuration time.Duration
+	// If set, faults are only injected into requests with this header.
+	FaultHeader string
+	// Comma-separated API keys. If set, faults are only injected into requests
+	// with one of them.
+	FaultApiKeys string
+
+	ScCheckRetries         int
+	ScQuotaRetries         int
+	ScReportRetries        int
+	ScReportRetryBackoffMs int
+	ScMaxPendingReports    int
+	ScReportFlushJitterMs  int
 
 	ComputePlatformOverride string
 
+	ScOperationNameStripPrefix string
+	ScOperationNameMap         string
+
+	// Report the version segment of the route path, e.g. "v1", as the API version.
+	ScReportApiVersionFromPath bool
+
+	// Omit the gRPC-JSON transcoder filter, only native gRPC requests are supported.
+	DisableTranscoding bool
+
 	TranscodingAlwaysPrintPrimitiveFields   bool
 	TranscodingAlwaysPrintEnumsAsInts       bool
 	TranscodingPreserveProtoFieldNames      bool
 	TranscodingIgnoreQueryParameters        string
 	TranscodingIgnoreUnknownQueryParameters bool
 	TranscodingFilePath                     string
+	TranscodingMatchIncomingRequestRoute    bool
+	// The maximum size of a transcoded request or response message. The
+	// buffer limit of the routes to gRPC backends is raised to fit it.
+	TranscodingMaxMessageBytes int
 }
 
 // DefaultConfigGeneratorOptions returns ConfigGeneratorOptions with default values.
@@ -149,25 +323,32 @@ func DefaultConfigGeneratorOptions() ConfigGeneratorOptions {
 		ClusterConnectTimeout:             20 * time.Second,
 		StreamIdleTimeout:                 util.DefaultIdleTimeout,
 		EnvoyXffNumTrustedHops:            2,
+		FaultAbortStatus:                  503,
 		DisableJwksAsyncFetch:             false,
 		JwksCacheDurationInS:              300,
+		AuthBypassReloadInterval:          10 * time.Second,
 		JwksFetchNumRetries:               0,
 		JwksFetchRetryBackOffBaseInterval: 200 * time.Millisecond,
 		JwksFetchRetryBackOffMaxInterval:  32 * time.Second,
 		ListenerAddress:                   "0.0.0.0",
 		ListenerPort:                      8080,
+		ListenerHttp2KeepaliveTimeout:     20 * time.Second,
 		TokenAgentPort:                    8791,
 		DisableOidcDiscovery:              false,
 		DependencyErrorBehavior:           commonpb.DependencyErrorBehavior_BLOCK_INIT_ON_ANY_ERROR.String(),
 		SslSidestreamClientRootCertsPath:  util.DefaultRootCAPaths,
 		SslBackendClientRootCertsPath:     util.DefaultRootCAPaths,
+		ClientCrlReloadInterval:           60 * time.Second,
 		SuppressEnvoyHeaders:              true,
 		NormalizePath:                     true,
 		MergeSlashesInPath:                true,
 		DisallowEscapedSlashesInPath:      false,
+		DecodeEscapedSlashesInPath:        false,
 		ServiceControlNetworkFailOpen:     true,
 		EnableGrpcForHttp1:                true,
 		ConnectionBufferLimitBytes:        -1,
+		MirrorPercent:                     100,
+		AccessLogGrpcLogName:              "espv2",
 		ServiceManagementURL:              "https://servicemanagement.googleapis.com",
 		ServiceControlURL:                 "https://servicecontrol.googleapis.com",
 		BackendRetryNum:                   1,
@@ -175,6 +356,8 @@ func DefaultConfigGeneratorOptions() ConfigGeneratorOptions {
 		ScCheckRetries:                    -1,
 		ScQuotaRetries:                    -1,
 		ScReportRetries:                   -1,
+		ScReportRetryBackoffMs:            -1,
+		ServiceControlStatsReportInterval: time.Minute,
 		CorsMaxAge:                        480 * time.Hour,
 	}
 }
@@ -30,9 +30,25 @@ type MethodSlice []*Method
 // The time complexity is O(W * L), where W is the size of slice
 // and L is the size of uri template segments
 func Sort(methods *MethodSlice) error {
+	return sortMethods(methods, nil)
+}
+
+// SortKeepingFirst sorts the slice of methods like Sort, except that a method
+// with the same http pattern as an earlier method is dropped instead of
+// raising an error. onDuplicate is called with each dropped method and the
+// earlier method kept in its place.
+func SortKeepingFirst(methods *MethodSlice, onDuplicate func(dropped, kept *Method)) error {
+	return sortMethods(methods, onDuplicate)
+}
+
+func sortMethods(methods *MethodSlice, onDuplicate func(dropped, kept *Method)) error {
 	s := newHttpPatternTrie()
 	for _, m := range *methods {
 		if err := s.insert(m); err != nil {
+			if dupErr, ok := err.(*duplicatePatternError); ok && onDuplicate != nil {
+				onDuplicate(m, dupErr.kept)
+				continue
+			}
 			return fmt.Errorf("%s has %s", m.Operation, err)
 		}
 	}
@@ -48,6 +48,18 @@ type lookupResult struct {
 	isMultiple bool
 }
 
+// duplicatePatternError is returned by insert when an inserted method has the
+// same http pattern as the method kept in the trie.
+type duplicatePatternError struct {
+	kept       *Method
+	httpMethod string
+	origin     string
+}
+
+func (e *duplicatePatternError) Error() string {
+	return fmt.Sprintf("duplicate http pattern `%s %s`", e.httpMethod, e.origin)
+}
+
 func newHttpPatternTrie() *httpPatternTrie {
 	return &httpPatternTrie{
 		RootPtr:     newHttpPatternTrieNode(),
@@ -71,8 +83,12 @@ func (h *httpPatternTrie) insert(method *Method) error {
 		Variable: uriTemplate.Variables,
 	}
 
-	if !h.RootPtr.insertPath(pathInfo, httpMethod, methodData, true) {
-		return fmt.Errorf("duplicate http pattern `%s %s`", httpMethod, uriTemplate.Origin)
+	if kept := h.RootPtr.insertPath(pathInfo, httpMethod, methodData, true); kept != nil {
+		return &duplicatePatternError{
+			kept:       kept.data.Method,
+			httpMethod: httpMethod,
+			origin:     uriTemplate.Origin,
+		}
 	}
 
 	if uriTemplate.Verb != "" {
@@ -102,19 +118,21 @@ func transferFromUriTemplate(ht *UriTemplate) []string {
 	return pathParts
 }
 
-func (hn *httpPatternTrieNode) insertTemplate(pathParts []string, pathPartsIdxCur int, httpMethod string, methodData *methodData, markDuplicate bool) bool {
+// insertTemplate returns nil if the method is inserted, or the existing
+// result with the same http pattern otherwise.
+func (hn *httpPatternTrieNode) insertTemplate(pathParts []string, pathPartsIdxCur int, httpMethod string, methodData *methodData, markDuplicate bool) *lookupResult {
 	if pathPartsIdxCur == len(pathParts) {
 		if val, ok := hn.ResultMap[httpMethod]; ok {
 			if markDuplicate {
 				val.isMultiple = true
 			}
-			return false
+			return val
 		}
 		hn.ResultMap[httpMethod] = &lookupResult{
 			data:       methodData,
 			isMultiple: false,
 		}
-		return true
+		return nil
 	}
 
 	curSeg := pathParts[pathPartsIdxCur]
@@ -131,7 +149,7 @@ func (hn *httpPatternTrieNode) insertTemplate(pathParts []string, pathPartsIdxCu
 	return child.insertTemplate(pathParts, pathPartsIdxCur+1, httpMethod, methodData, markDuplicate)
 }
 
-func (hn *httpPatternTrieNode) insertPath(pathParts []string, httpMethod string, methodData *methodData, markDuplicate bool) bool {
+func (hn *httpPatternTrieNode) insertPath(pathParts []string, httpMethod string, methodData *methodData, markDuplicate bool) *lookupResult {
 	return hn.insertTemplate(pathParts, 0, httpMethod, methodData, markDuplicate)
 }
 
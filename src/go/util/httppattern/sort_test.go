@@ -75,6 +75,47 @@ func TestSortDuplicateHttpPattern(t *testing.T) {
 	}
 }
 
+func TestSortKeepingFirst(t *testing.T) {
+	methods := &MethodSlice{}
+	for i, hp := range []string{
+		"GET /a/{id=*}",
+		"GET /a/b",
+		"GET /a/{name=*}",
+		"POST /a/{name=*}",
+	} {
+		httpMethod, uriTemplate := parsePattern(hp)
+		u, _ := ParseUriTemplate(uriTemplate)
+		methods.AppendMethod(&Method{
+			Pattern: &Pattern{
+				HttpMethod:  httpMethod,
+				UriTemplate: u,
+			},
+			Operation: fmt.Sprintf("operation_%d", i),
+		})
+	}
+
+	var gotDuplicates []string
+	if err := SortKeepingFirst(methods, func(dropped, kept *Method) {
+		gotDuplicates = append(gotDuplicates, fmt.Sprintf("%s dropped for %s", dropped.Operation, kept.Operation))
+	}); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	wantDuplicates := []string{"operation_2 dropped for operation_0"}
+	if fmt.Sprint(gotDuplicates) != fmt.Sprint(wantDuplicates) {
+		t.Errorf("got duplicates %v, want %v", gotDuplicates, wantDuplicates)
+	}
+
+	var gotOperations []string
+	for _, m := range *methods {
+		gotOperations = append(gotOperations, m.Operation)
+	}
+	wantOperations := []string{"operation_1", "operation_0", "operation_3"}
+	if fmt.Sprint(gotOperations) != fmt.Sprint(wantOperations) {
+		t.Errorf("got sorted operations %v, want %v", gotOperations, wantOperations)
+	}
+}
+
 func TestSort(t *testing.T) {
 	testCases := []struct {
 		desc              string
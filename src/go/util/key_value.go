@@ -0,0 +1,51 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyValue is a key=value pair of a flag value.
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// ParseKeyValuePairs parses comma-separated key=value pairs, e.g.
+// "selector1=value1,selector2=value2", keeping their order. Each pair is split
+// on its first '=', so values may contain '=' but keys may not. Spaces around
+// the keys and values are trimmed, and empty pairs are ignored. The
+// description and format are used in the error, e.g.
+// `invalid backend host rewrite "foo", it should be in the format selector=host`.
+func ParseKeyValuePairs(pairs, description, format string) ([]KeyValue, error) {
+	var kvs []KeyValue
+	for _, pair := range strings.Split(pairs, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid %s %q, it should be in the format %s", description, strings.TrimSpace(pair), format)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if key == "" || value == "" {
+			return nil, fmt.Errorf("invalid %s %q, it should be in the format %s", description, strings.TrimSpace(pair), format)
+		}
+		kvs = append(kvs, KeyValue{Key: key, Value: value})
+	}
+	return kvs, nil
+}
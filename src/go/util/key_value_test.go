@@ -0,0 +1,91 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyValuePairs(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		pairs   string
+		wantKvs []KeyValue
+		wantErr string
+	}{
+		{
+			desc:  "empty value has no pairs",
+			pairs: "",
+		},
+		{
+			desc:  "pairs are kept in order and trimmed",
+			pairs: " b = 2 ,a=1",
+			wantKvs: []KeyValue{
+				{Key: "b", Value: "2"},
+				{Key: "a", Value: "1"},
+			},
+		},
+		{
+			desc:  "empty pairs are ignored",
+			pairs: "a=1,, ,b=2,",
+			wantKvs: []KeyValue{
+				{Key: "a", Value: "1"},
+				{Key: "b", Value: "2"},
+			},
+		},
+		{
+			desc:    "pair without '=' is rejected",
+			pairs:   "a=1,b",
+			wantErr: `invalid test pair "b", it should be in the format key=value`,
+		},
+		{
+			desc:  "pair is split on the first '='",
+			pairs: "a=x=y,b==",
+			wantKvs: []KeyValue{
+				{Key: "a", Value: "x=y"},
+				{Key: "b", Value: "="},
+			},
+		},
+		{
+			desc:    "pair with an empty key is rejected",
+			pairs:   " =1",
+			wantErr: `invalid test pair "=1", it should be in the format key=value`,
+		},
+		{
+			desc:    "pair with an empty value is rejected",
+			pairs:   "a= ",
+			wantErr: `invalid test pair "a=", it should be in the format key=value`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			gotKvs, err := ParseKeyValuePairs(tc.pairs, "test pair", "key=value")
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotKvs, tc.wantKvs) {
+				t.Errorf("want %+v, got %+v", tc.wantKvs, gotKvs)
+			}
+		})
+	}
+}
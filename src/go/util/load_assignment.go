@@ -17,6 +17,7 @@ package util
 import (
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpointpb "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	structpb "github.com/golang/protobuf/ptypes/struct"
 )
 
 // CreateLoadAssignment creates a cluster for a TCP/IP port.
@@ -48,6 +49,29 @@ func CreateLoadAssignment(hostname string, port uint32) *endpointpb.ClusterLoadA
 	}
 }
 
+// CreateFailoverLoadAssignment creates a cluster for a TCP/IP port that fails
+// over to the fallback TCP/IP port, at a lower priority, when the former is
+// unhealthy. The fallback endpoint is marked by the BackendFallbackMetadataKey
+// metadata.
+func CreateFailoverLoadAssignment(hostname string, port uint32, fallbackHostname string, fallbackPort uint32) *endpointpb.ClusterLoadAssignment {
+	loadAssignment := CreateLoadAssignment(hostname, port)
+	fallback := CreateLoadAssignment(fallbackHostname, fallbackPort).Endpoints[0]
+	fallback.Priority = 1
+	fallback.LbEndpoints[0].Metadata = &corepb.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			BackendFallbackMetadataNamespace: {
+				Fields: map[string]*structpb.Value{
+					BackendFallbackMetadataKey: {
+						Kind: &structpb.Value_StringValue{StringValue: "true"},
+					},
+				},
+			},
+		},
+	}
+	loadAssignment.Endpoints = append(loadAssignment.Endpoints, fallback)
+	return loadAssignment
+}
+
 // CreateUdsLoadAssignment creates a cluster for a unix domain socket.
 func CreateUdsLoadAssignment(clusterName string) *endpointpb.ClusterLoadAssignment {
 	return &endpointpb.ClusterLoadAssignment{
@@ -117,6 +117,8 @@ var Resolver = FuncResolver(func(url string) (proto.Message, error) {
 		return new(statspb.StatsSink), nil
 	case "type.googleapis.com/envoy.config.metrics.v3.StatsdSink":
 		return new(statspb.StatsdSink), nil
+	case "type.googleapis.com/envoy.config.metrics.v3.DogStatsdSink":
+		return new(statspb.DogStatsdSink), nil
 	case "type.googleapis.com/envoy.config.trace.v3.OpenCensusConfig":
 		return new(tracepb.OpenCensusConfig), nil
 	default:
@@ -41,6 +41,7 @@ func TestResolver(t *testing.T) {
 		{msg: &accessgrpcpb.CommonGrpcAccessLogConfig{}},
 		{msg: &statspb.StatsSink{}},
 		{msg: &statspb.StatsdSink{}},
+		{msg: &statspb.DogStatsdSink{}},
 		{msg: &statspb.StatsConfig{}},
 	}
 
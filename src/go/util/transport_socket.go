@@ -22,6 +22,9 @@ import (
 
 	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	tlspb "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discoverypb "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	anypb "github.com/golang/protobuf/ptypes/any"
 	wrapperspb "github.com/golang/protobuf/ptypes/wrappers"
 )
 
@@ -75,8 +78,11 @@ func CreateUpstreamTransportSocket(hostname, rootCertsPath, sslClientPath string
 	}, nil
 }
 
-// CreateDownstreamTransportSocket creates a TransportSocket for Downstream
-func CreateDownstreamTransportSocket(sslServerPath, sslServerRootPath, sslMinimumProtocol, sslMaximumProtocol string, cipherSuites string) (*corepb.TransportSocket, error) {
+// CreateDownstreamTransportSocket creates a TransportSocket for Downstream.
+// If allowedSpiffeIds is not empty, only client certificates with one of these
+// SPIFFE IDs in their URI SAN are accepted. If crlSdsPath is not empty, client
+// certificates revoked by the CRL of the SDS secret in that file are rejected.
+func CreateDownstreamTransportSocket(sslServerPath, sslServerRootPath string, allowedSpiffeIds []string, crlSdsPath string, sslMinimumProtocol, sslMaximumProtocol string, cipherSuites string) (*corepb.TransportSocket, error) {
 	if sslServerPath == "" {
 		return nil, fmt.Errorf("SSL path cannot be empty.")
 	}
@@ -92,6 +98,46 @@ func CreateDownstreamTransportSocket(sslServerPath, sslServerRootPath, sslMinimu
 		return nil, err
 	}
 	commonTls.AlpnProtocols = []string{"h2", "http/1.1"}
+
+	if len(allowedSpiffeIds) > 0 {
+		if sslServerRootPath == "" {
+			return nil, fmt.Errorf("SPIFFE IDs cannot be matched without a trust bundle.")
+		}
+		validationContext := commonTls.GetValidationContext()
+		for _, spiffeId := range allowedSpiffeIds {
+			if !strings.HasPrefix(spiffeId, "spiffe://") {
+				return nil, fmt.Errorf("invalid SPIFFE ID %q, it must start with spiffe://", spiffeId)
+			}
+			validationContext.MatchSubjectAltNames = append(validationContext.MatchSubjectAltNames, &matcher.StringMatcher{
+				MatchPattern: &matcher.StringMatcher_Exact{
+					Exact: spiffeId,
+				},
+			})
+		}
+	}
+
+	if crlSdsPath != "" {
+		if sslServerRootPath == "" {
+			return nil, fmt.Errorf("CRL cannot be checked without root certificates.")
+		}
+		// The CRL is merged into the validation context by Envoy, and reloaded
+		// when the file changes without draining the listener.
+		commonTls.ValidationContextType = &tlspb.CommonTlsContext_CombinedValidationContext{
+			CombinedValidationContext: &tlspb.CommonTlsContext_CombinedCertificateValidationContext{
+				DefaultValidationContext: commonTls.GetValidationContext(),
+				ValidationContextSdsSecretConfig: &tlspb.SdsSecretConfig{
+					Name: ClientCrlSecret,
+					SdsConfig: &corepb.ConfigSource{
+						ResourceApiVersion: corepb.ApiVersion_V3,
+						ConfigSourceSpecifier: &corepb.ConfigSource_Path{
+							Path: crlSdsPath,
+						},
+					},
+				},
+			},
+		}
+	}
+
 	downstreamTlsContext := &tlspb.DownstreamTlsContext{
 		CommonTlsContext: commonTls,
 	}
@@ -112,6 +158,29 @@ func CreateDownstreamTransportSocket(sslServerPath, sslServerRootPath, sslMinimu
 	}, nil
 }
 
+// CreateClientCrlSdsFile creates the content of the SDS file of the client CRL
+// referenced by CreateDownstreamTransportSocket, in JSON.
+func CreateClientCrlSdsFile(crl []byte) (string, error) {
+	secret, err := ptypes.MarshalAny(&tlspb.Secret{
+		Name: ClientCrlSecret,
+		Type: &tlspb.Secret_ValidationContext{
+			ValidationContext: &tlspb.CertificateValidationContext{
+				Crl: &corepb.DataSource{
+					Specifier: &corepb.DataSource_InlineBytes{
+						InlineBytes: crl,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return ProtoToJson(&discoverypb.DiscoveryResponse{
+		Resources: []*anypb.Any{secret},
+	})
+}
+
 func createCommonTlsContext(rootCertsPath, sslPath, sslFileName, sslMinimumProtocol, sslMaximumProtocol string, cipherSuites string) (*tlspb.CommonTlsContext, error) {
 	commonTls := &tlspb.CommonTlsContext{}
 	// Add TLS certificate
@@ -157,6 +157,8 @@ func TestCreateDownstreamTransportSocket(t *testing.T) {
 		desc                string
 		sslPath             string
 		sslRootCertPath     string
+		allowedSpiffeIds    []string
+		crlSdsPath          string
 		sslMinimumProtocol  string
 		sslMaximumProtocol  string
 		cipherSuites        string
@@ -226,6 +228,91 @@ func TestCreateDownstreamTransportSocket(t *testing.T) {
 				}
 			}`,
 		},
+		{
+			desc:             "Downstream Transport Socket for mTLS with SPIFFE IDs",
+			sslPath:          "/etc/ssl/endpoints/",
+			sslRootCertPath:  "/etc/ssl/endpoints/spiffe_bundle.pem",
+			allowedSpiffeIds: []string{"spiffe://example.org/ns/default/sa/client", "spiffe://example.org/ns/default/sa/admin"},
+			wantTransportSocket: `{
+				"name": "envoy.transport_sockets.tls",
+				"typedConfig": {
+					"@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext",
+					"commonTlsContext": {
+						"alpnProtocols": [
+							"h2",
+							"http/1.1"
+						],
+						"tlsCertificates": [
+							{
+								"certificateChain": {
+									"filename": "/etc/ssl/endpoints/server.crt"
+								},
+								"privateKey": {
+									"filename": "/etc/ssl/endpoints/server.key"
+								}
+							}
+						],
+						"validationContext": {
+							"matchSubjectAltNames": [
+								{
+									"exact": "spiffe://example.org/ns/default/sa/client"
+								},
+								{
+									"exact": "spiffe://example.org/ns/default/sa/admin"
+								}
+							],
+							"trustedCa": {
+								"filename": "/etc/ssl/endpoints/spiffe_bundle.pem"
+							}
+						}
+					},
+					"requireClientCertificate": true
+				}
+			}`,
+		},
+		{
+			desc:            "Downstream Transport Socket for mTLS with CRL",
+			sslPath:         "/etc/ssl/endpoints/",
+			sslRootCertPath: "/etc/ssl/endpoints/root.crt",
+			crlSdsPath:      "/tmp/client_crl_sds.json",
+			wantTransportSocket: `{
+				"name": "envoy.transport_sockets.tls",
+				"typedConfig": {
+					"@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext",
+					"commonTlsContext": {
+						"alpnProtocols": [
+							"h2",
+							"http/1.1"
+						],
+						"tlsCertificates": [
+							{
+								"certificateChain": {
+									"filename": "/etc/ssl/endpoints/server.crt"
+								},
+								"privateKey": {
+									"filename": "/etc/ssl/endpoints/server.key"
+								}
+							}
+						],
+						"combinedValidationContext": {
+							"defaultValidationContext": {
+								"trustedCa": {
+									"filename": "/etc/ssl/endpoints/root.crt"
+								}
+							},
+							"validationContextSdsSecretConfig": {
+								"name": "client_crl",
+								"sdsConfig": {
+									"path": "/tmp/client_crl_sds.json",
+									"resourceApiVersion": "V3"
+								}
+							}
+						}
+					},
+					"requireClientCertificate": true
+				}
+			}`,
+		},
 		{
 			desc:               "Downstream Transport Socket for TLS, with version requirements",
 			sslPath:            "/etc/ssl/endpoints/",
@@ -290,7 +377,7 @@ func TestCreateDownstreamTransportSocket(t *testing.T) {
 	}
 
 	for i, tc := range testData {
-		gotTransportSocket, err := CreateDownstreamTransportSocket(tc.sslPath, tc.sslRootCertPath, tc.sslMinimumProtocol, tc.sslMaximumProtocol, tc.cipherSuites)
+		gotTransportSocket, err := CreateDownstreamTransportSocket(tc.sslPath, tc.sslRootCertPath, tc.allowedSpiffeIds, tc.crlSdsPath, tc.sslMinimumProtocol, tc.sslMaximumProtocol, tc.cipherSuites)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -304,3 +391,60 @@ func TestCreateDownstreamTransportSocket(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateDownstreamTransportSocketError(t *testing.T) {
+	testData := []struct {
+		desc             string
+		sslRootCertPath  string
+		allowedSpiffeIds []string
+		crlSdsPath       string
+		wantErr          string
+	}{
+		{
+			desc:             "SPIFFE IDs without trust bundle",
+			allowedSpiffeIds: []string{"spiffe://example.org/ns/default/sa/client"},
+			wantErr:          "SPIFFE IDs cannot be matched without a trust bundle.",
+		},
+		{
+			desc:             "SPIFFE ID with wrong scheme",
+			sslRootCertPath:  "/etc/ssl/endpoints/spiffe_bundle.pem",
+			allowedSpiffeIds: []string{"https://example.org/ns/default/sa/client"},
+			wantErr:          `invalid SPIFFE ID "https://example.org/ns/default/sa/client", it must start with spiffe://`,
+		},
+		{
+			desc:       "CRL without root certificates",
+			crlSdsPath: "/tmp/client_crl_sds.json",
+			wantErr:    "CRL cannot be checked without root certificates.",
+		},
+	}
+
+	for i, tc := range testData {
+		_, err := CreateDownstreamTransportSocket("/etc/ssl/endpoints/", tc.sslRootCertPath, tc.allowedSpiffeIds, tc.crlSdsPath, "", "", "")
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("Test Desc(%d): %s, CreateDownstreamTransportSocket got error: %v, want: %v", i, tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCreateClientCrlSdsFile(t *testing.T) {
+	got, err := CreateClientCrlSdsFile([]byte("crl-data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+		"resources": [
+			{
+				"@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret",
+				"name": "client_crl",
+				"validationContext": {
+					"crl": {
+						"inlineBytes": "Y3JsLWRhdGE="
+					}
+				}
+			}
+		]
+	}`
+	if err := JsonEqual(want, got); err != nil {
+		t.Errorf("CreateClientCrlSdsFile failed,\n %v", err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -146,6 +147,33 @@ func ResolveJwksUriUsingOpenID(uri string) (string, error) {
 	return jwksURI, nil
 }
 
+// FetchJwks fetches the JWKS from the uri within the timeout and checks it is
+// a JSON object.
+func FetchJwks(uri string, timeout time.Duration) error {
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Get(uri)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch jwks from %s: %v", uri, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to fetch jwks from %s: returns not 200 OK: %v", uri, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch jwks from %s: %v", uri, err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return fmt.Errorf("Invalid jwks from %s: %v", uri, err)
+	}
+	return nil
+}
+
 func IamIdentityTokenPath(IamServiceAccount string) string {
 	return fmt.Sprintf("/v1/projects/-/serviceAccounts/%s:generateIdToken", IamServiceAccount)
 }
@@ -159,7 +187,22 @@ func ExtractAddressFromURI(uri string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("Fail to parse uri %s with error %v", uri, err)
 	}
-	return fmt.Sprintf("%s:%v", hostname, port), nil
+	return JoinHostPort(hostname, port), nil
+}
+
+// JoinHostPort combines the hostname returned by ParseURI and the port into an
+// address. IPv6 literals are enclosed in brackets, e.g. "[::1]:8080".
+func JoinHostPort(hostname string, port uint32) string {
+	return net.JoinHostPort(hostname, strconv.FormatUint(uint64(port), 10))
+}
+
+// HostHeaderValue returns the hostname returned by ParseURI in the form used by
+// the Host header. IPv6 literals are enclosed in brackets, e.g. "[::1]".
+func HostHeaderValue(hostname string) string {
+	if strings.Contains(hostname, ":") {
+		return "[" + hostname + "]"
+	}
+	return hostname
 }
 
 var (
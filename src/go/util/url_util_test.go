@@ -216,6 +216,21 @@ func TestParseURI(t *testing.T) {
 			url:     `http://www.google\0.com`,
 			wantErr: `parse "http://www.google\\0.com": invalid character "\\" in host name`,
 		},
+		{
+			desc:           "successful for ipv6 url with custom port",
+			url:            "http://[::1]:8080/api/",
+			wantedScheme:   "http",
+			wantedHostname: "::1",
+			wantedPort:     8080,
+			wantPath:       "/api",
+		},
+		{
+			desc:           "successful for ipv6 url with default port",
+			url:            "grpcs://[2001:db8::1]",
+			wantedScheme:   "grpcs",
+			wantedHostname: "2001:db8::1",
+			wantedPort:     443,
+		},
 		{
 			desc:    "bad brackets in ipv6 address",
 			url:     "https://[::1:80",
@@ -463,6 +478,11 @@ func TestExtraAddressFromURI(t *testing.T) {
 			uri:           "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com",
 			wantedAddress: "www.googleapis.com:443",
 		},
+		{
+			desc:          "Succeeded to parse uri with ipv6 address",
+			uri:           "http://[::1]:8080/jwks",
+			wantedAddress: "[::1]:8080",
+		},
 		{
 			desc:        "Failed with wrong-format uri",
 			uri:         "%",
@@ -481,6 +501,32 @@ func TestExtraAddressFromURI(t *testing.T) {
 	}
 }
 
+func TestHostHeaderValue(t *testing.T) {
+	testData := []struct {
+		hostname string
+		want     string
+	}{
+		{
+			hostname: "abc.example.org",
+			want:     "abc.example.org",
+		},
+		{
+			hostname: "127.0.0.1",
+			want:     "127.0.0.1",
+		},
+		{
+			hostname: "::1",
+			want:     "[::1]",
+		},
+	}
+
+	for _, tc := range testData {
+		if got := HostHeaderValue(tc.hostname); got != tc.want {
+			t.Errorf("HostHeaderValue(%v) got: %v, want: %v", tc.hostname, got, tc.want)
+		}
+	}
+}
+
 func TestFetchConfigRelatedUrl(t *testing.T) {
 	sm := "https://servicemanagement.googleapis.com"
 	sn := "service-name"
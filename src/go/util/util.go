@@ -15,6 +15,9 @@
 package util
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -82,6 +85,9 @@ const (
 	// Default idle timeout applied globally if not specified via flag.
 	DefaultIdleTimeout = 5 * time.Minute
 
+	// The maximum size of requests buffered to be sent with a Content-Length.
+	BufferMaxRequestBytes = 10 * 1024 * 1024
+
 	// A limit configured to restrict resource usage in Envoy's SafeRegex GoogleRE2 matcher.
 	// It will be validated on configmanager side though it may use different GoogleRE2 library.
 	// b/148606900: It is safe to set this to a fairly high value.
@@ -100,11 +106,20 @@ const (
 	// Default api key locations
 	DefaultApiKeyQueryParamKey    = "key"
 	DefaultApiKeyQueryParamApiKey = "api_key"
+	DefaultApiKeyHeader           = "x-api-key"
 
 	// Strict Transport Security header key and value
 	HSTSHeaderKey   = "Strict-Transport-Security"
 	HSTSHeaderValue = "max-age=31536000; includeSubdomains"
 
+	// The backend ALPN that negotiates either h2 or http/1.1 with the backend.
+	AutoAlpn = "auto"
+
+	// Cache-Control directive that stops the compressor filter from
+	// compressing, and so holding back, a response.
+	CacheControlHeaderKey   = "Cache-Control"
+	CacheControlNoTransform = "no-transform"
+
 	// Standard type url prefix.
 	TypeUrlPrefix = "type.googleapis.com/"
 
@@ -129,6 +144,18 @@ const (
 
 	// The suffix that forms the operation name header.
 	OperationHeaderSuffix = "Api-Operation-Name"
+
+	// The infix that forms the JWT claim headers used for claim based routing,
+	// followed by the claim name.
+	JwtClaimHeaderInfix = "JWT-Claim-"
+
+	// The suffix of the response header set when the fallback backend served
+	// the request.
+	BackendFallbackHeaderSuffix = "Backend-Fallback"
+
+	// The endpoint metadata namespace and key that mark the fallback backend.
+	BackendFallbackMetadataNamespace = "espv2"
+	BackendFallbackMetadataKey       = "fallback"
 )
 
 type BackendProtocol int32
@@ -151,3 +178,25 @@ func MaybeTruncateSpanName(spanName string) string {
 	newSpanName := spanName[:SpanNameMaxByteNum-3] + "..."
 	return newSpanName
 }
+
+// WriteFileAtomically writes the content to a temporary file in the same
+// directory and renames it to the path, so that readers never see a partially
+// written file.
+func WriteFileAtomically(path, content string) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
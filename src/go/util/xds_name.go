@@ -31,6 +31,12 @@ const (
 	Router = "envoy.filters.http.router"
 	// Health checking HTTP filter
 	HealthCheck = "envoy.filters.http.health_check"
+	// Fault injection HTTP filter
+	Fault = "envoy.filters.http.fault"
+	// Compressor HTTP filter
+	Compressor = "envoy.filters.http.compressor"
+	// Gzip compressor library of the Compressor HTTP filter
+	GzipCompressor = "envoy.compression.gzip.compressor"
 	// Echo network filter
 	Echo = "envoy.filters.network.echo"
 	// HTTPConnectionManager network filter
@@ -39,8 +45,26 @@ const (
 	JwtAuthn = "envoy.filters.http.jwt_authn"
 	// TLSTransportSocket is Envoy TLS Transport Socket name.
 	TLSTransportSocket = "envoy.transport_sockets.tls"
+	// ClientCrlSecret is the SDS secret of the downstream client CRL.
+	ClientCrlSecret = "client_crl"
+	// UpstreamHttpProtocolOptions are the HTTP protocol options of clusters.
+	UpstreamHttpProtocolOptions = "envoy.extensions.upstreams.http.v3.HttpProtocolOptions"
 	// AccessFileLogger filter name
 	AccessFileLogger = "envoy.access_loggers.file"
+	// AccessGrpcLogger filter name
+	AccessGrpcLogger = "envoy.access_loggers.http_grpc"
+	// ProxyProtocol listener filter
+	ProxyProtocol = "envoy.filters.listener.proxy_protocol"
+	// FixedHeapResourceMonitor overload manager resource monitor
+	FixedHeapResourceMonitor = "envoy.resource_monitors.fixed_heap"
+	// StopAcceptingRequestsOverloadAction overload manager action
+	StopAcceptingRequestsOverloadAction = "envoy.overload_actions.stop_accepting_requests"
+	// StopAcceptingConnectionsOverloadAction overload manager action
+	StopAcceptingConnectionsOverloadAction = "envoy.overload_actions.stop_accepting_connections"
+	// StatsdSink stats sink
+	StatsdSink = "envoy.stat_sinks.statsd"
+	// DogStatsdSink stats sink
+	DogStatsdSink = "envoy.stat_sinks.dog_statsd"
 
 	// ESPv2 custom http filters.
 
@@ -52,6 +76,8 @@ const (
 	BackendAuth = "com.google.espv2.filters.http.backend_auth"
 	// gRPC Metadata Scrubber filter.
 	GrpcMetadataScrubber = "com.google.espv2.filters.http.grpc_metadata_scrubber"
+	// Header Allowlist filter.
+	HeaderAllowlist = "com.google.espv2.filters.http.header_allowlist"
 
 	// The metadata server cluster name.
 	MetadataServerClusterName = "metadata-cluster"
@@ -65,6 +91,9 @@ const (
 	// The service control server cluster name.
 	ServiceControlClusterName = "service-control-cluster"
 
+	// The gRPC access log service cluster name.
+	AccessLogGrpcClusterName = "access-log-grpc-cluster"
+
 	IngressListenerName  = "ingress_listener"
 	LoopbackListenerName = "loopback_listener"
 )
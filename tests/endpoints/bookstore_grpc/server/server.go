@@ -21,10 +21,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -193,6 +196,10 @@ func (s *BookstoreServer) StopServer() {
 	s.bs2.grpcServer.Stop()
 }
 
+// delayTestHeaderPrefix is the prefix of the test header value that delays
+// the response by the duration following it.
+const delayTestHeaderPrefix = "DELAY_"
+
 func testDecorator(ctx context.Context) error {
 	// Retrieve headers from call
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -208,6 +215,16 @@ func testDecorator(ctx context.Context) error {
 	}
 	first := values[0]
 
+	// Delay the response by the given duration, e.g. "DELAY_200ms".
+	if strings.HasPrefix(first, delayTestHeaderPrefix) {
+		delay, err := time.ParseDuration(strings.TrimPrefix(first, delayTestHeaderPrefix))
+		if err != nil {
+			return status.New(codes.InvalidArgument, first).Err()
+		}
+		time.Sleep(delay)
+		return nil
+	}
+
 	// Return proper response code
 	switch first {
 	case "ABORTED":
@@ -216,6 +233,24 @@ func testDecorator(ctx context.Context) error {
 		return status.New(codes.Internal, first).Err()
 	case "DATA_LOSS":
 		return status.New(codes.DataLoss, first).Err()
+	case "INVALID_ARGUMENT_WITH_DETAILS":
+		st, err := status.New(codes.InvalidArgument, first).WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{
+					Field:       "shelf.theme",
+					Description: "theme must not be empty",
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return st.Err()
+	case "SLOW":
+		// Ignores the deadline of the request, so that it is enforced by the
+		// proxy instead.
+		time.Sleep(5 * time.Second)
+		return nil
 	default:
 		glog.Warningf("Unknown metadata: %v", first)
 		return nil
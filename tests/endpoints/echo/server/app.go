@@ -46,6 +46,8 @@ var (
 	alwaysRespondRST      = flag.Bool("always_respond_rst", false, "If true, the backend will respond RST all the time")
 	rejectRequestNum      = flag.Int("reject_request_num", 0, "The first N requests that the backend will reject")
 	rejectRequestStatus   = flag.Int("reject_request_status", 0, `The http status code when the backend uses to reject the first N requests defined by reject_request_num`)
+	tlsHandshakeDelay     = flag.Duration("tls_handshake_delay", 0, "If set, the HTTPS server delays each TLS handshake to simulate a backend that is slow to accept connections")
+	requiredSni           = flag.String("required_sni", "", "If set, the HTTPS server rejects TLS handshakes with a different SNI, to simulate a TLS frontend that selects its cert by SNI")
 	webSocketUpgrader     = websocket.Upgrader{}
 )
 
@@ -80,6 +82,8 @@ func main() {
 		HandlerFunc(echoHandler)
 	r.Path("/simpleget").Methods("GET").
 		HandlerFunc(simpleGet)
+	r.Path("/events").Methods("GET").
+		HandlerFunc(eventsHandler)
 	r.Path("/simpleget/304").Methods("GET").
 		HandlerFunc(simpleGetNotModified)
 	r.Path("/simpleget/403").Methods("GET").
@@ -248,6 +252,13 @@ func echoHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	// The framing headers are removed from r.Header, echo them separately.
+	w.Header().Set("Echo-Request-Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	if len(r.TransferEncoding) > 0 {
+		w.Header().Set("Echo-Request-Transfer-Encoding", strings.Join(r.TransferEncoding, ","))
+	}
+	// The protocol negotiated with the client, e.g. "HTTP/2.0".
+	w.Header().Set("Echo-Request-Protocol", r.Proto)
 	w.Write(b)
 }
 
@@ -259,6 +270,7 @@ func echoHeaderHandler(w http.ResponseWriter, r *http.Request) {
 			w.Header().Add(fmt.Sprintf("Echo-%s", key), val)
 		}
 	}
+	w.Header().Set("Echo-Query", r.URL.RawQuery)
 
 }
 
@@ -285,6 +297,28 @@ func websocketEchoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// eventsHandler streams 3 Server-Sent Events, one per second. The content
+// type can be overridden by the "content_type" query parameter.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorf(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	contentType := r.URL.Query().Get("content_type")
+	if contentType == "" {
+		contentType = "text/event-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+		fmt.Fprintf(w, "data: event %d\n\n", i)
+		flusher.Flush()
+	}
+}
+
 // dynamicRoutingHandler reads URL from request header, and writes it back out.
 func dynamicRoutingHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle sleeps
@@ -372,6 +406,11 @@ func bearerTokenHandler(w http.ResponseWriter, r *http.Request) {
 		resp += fmt.Sprintf(`, "X-Forwarded-Authorization": "%s"`, xForwarded)
 	}
 
+	xServerless := r.Header.Get("X-Serverless-Authorization")
+	if xServerless != "" {
+		resp += fmt.Sprintf(`, "X-Serverless-Authorization": "%s"`, xServerless)
+	}
+
 	xEndpoint := r.Header.Get("X-Endpoint-API-UserInfo")
 	if xEndpoint != "" {
 		resp += fmt.Sprintf(`, "X-Endpoint-API-UserInfo": "%s"`, xEndpoint)
@@ -411,6 +450,23 @@ func createServer() (*http.Server, error) {
 		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
 	}
 
+	// Delay the TLS handshake, so clients see a slow connection establishment,
+	// and reject the TLS handshake without the required SNI.
+	if *tlsHandshakeDelay > 0 || *requiredSni != "" {
+		if !*isHttps || *mtlsCertFile != "" {
+			return nil, fmt.Errorf("TLS handshake delay and required SNI are only supported by HTTPS server without mTLS")
+		}
+		server.TLSConfig = &tls.Config{
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				time.Sleep(*tlsHandshakeDelay)
+				if *requiredSni != "" && hello.ServerName != *requiredSni {
+					return nil, fmt.Errorf("got SNI %q, want %q", hello.ServerName, *requiredSni)
+				}
+				return nil, nil
+			},
+		}
+	}
+
 	if *mtlsCertFile == "" {
 		return server, nil
 	}
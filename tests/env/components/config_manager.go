@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/golang/glog"
@@ -33,7 +34,9 @@ func NewConfigManagerServer(debugMode bool, ports *platform.Ports, args []string
 	adsNamedPipe := fmt.Sprintf("@espv2-ads-cluster-integ-test-%v", ports.TestId)
 
 	// Set config manager flags.
-	args = append(args, "--listener_address", platform.GetAnyAddress())
+	if !hasFlag(args, "listener_address") {
+		args = append(args, "--listener_address", platform.GetAnyAddress())
+	}
 	args = append(args, "--backend_dns_lookup_family", platform.GetDnsFamily())
 	args = append(args, "--ssl_backend_client_root_certs_path", platform.GetFilePath(platform.ProxyCert))
 	args = append(args, fmt.Sprintf("--ads_named_pipe=%v", adsNamedPipe))
@@ -55,6 +58,16 @@ func NewConfigManagerServer(debugMode bool, ports *platform.Ports, args []string
 	}, nil
 }
 
+// hasFlag returns true if the flag is set by the test.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--"+name || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
 func (s ConfigManagerServer) String() string {
 	return "Config Manager gRPC Server"
 }
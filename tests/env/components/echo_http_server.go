@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 )
@@ -35,6 +36,8 @@ type EchoHTTPServerFlags struct {
 	BackendAlwaysRespondRST    bool
 	BackendRejectRequestNum    int
 	BackendRejectRequestStatus int
+	TLSHandshakeDelay          time.Duration
+	RequiredSni                string
 }
 
 func NewEchoHTTPServer(port uint16, useWrongCert bool, flags *EchoHTTPServerFlags) (*EchoHTTPServer, error) {
@@ -50,6 +53,14 @@ func NewEchoHTTPServer(port uint16, useWrongCert bool, flags *EchoHTTPServerFlag
 		serverArgs = append(serverArgs, fmt.Sprintf("--reject_request_num=%v", flags.BackendRejectRequestNum))
 	}
 
+	if flags.TLSHandshakeDelay != 0 {
+		serverArgs = append(serverArgs, fmt.Sprintf("--tls_handshake_delay=%v", flags.TLSHandshakeDelay))
+	}
+
+	if flags.RequiredSni != "" {
+		serverArgs = append(serverArgs, fmt.Sprintf("--required_sni=%v", flags.RequiredSni))
+	}
+
 	if flags.BackendRejectRequestStatus != 0 {
 		serverArgs = append(serverArgs, fmt.Sprintf("--reject_request_status=%v", flags.BackendRejectRequestStatus))
 	}
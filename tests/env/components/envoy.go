@@ -16,14 +16,28 @@ package components
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+
+	bootstrappb "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+
+	// Registers the typed configs the bootstrapper may generate, so that the
+	// bootstrap config can be unmarshaled for the hook.
+	_ "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/fixed_heap/v3"
 )
 
+// BootstrapHook mutates the generated bootstrap config before Envoy starts,
+// for settings that are not configurable by the bootstrapper flags.
+type BootstrapHook func(bootstrap *bootstrappb.Bootstrap) error
+
 // Envoy stores data for Envoy process
 type Envoy struct {
 	*Cmd
@@ -31,13 +45,14 @@ type Envoy struct {
 }
 
 // createEnvoyConf create envoy config.
-func createEnvoyConf(configPath string, bootstrapArgs []string, ports *platform.Ports) error {
+func createEnvoyConf(configPath string, bootstrapArgs []string, ports *platform.Ports, hook BootstrapHook) error {
 
 	glog.Infof("Outputting envoy bootstrap config to: %v", configPath)
 
+	// The default admin address goes first so that tests can override it.
+	bootstrapArgs = append([]string{"--admin_address", platform.GetAnyAddress()}, bootstrapArgs...)
 	bootstrapArgs = append(bootstrapArgs, fmt.Sprintf("--ads_named_pipe=@espv2-ads-cluster-integ-test-%v", ports.TestId))
 	bootstrapArgs = append(bootstrapArgs, fmt.Sprintf("--admin_port=%v", ports.AdminPort))
-	bootstrapArgs = append(bootstrapArgs, "--admin_address", platform.GetAnyAddress())
 	bootstrapArgs = append(bootstrapArgs, configPath)
 
 	// Call bootstrapper to create the bootstrap config
@@ -45,20 +60,52 @@ func createEnvoyConf(configPath string, bootstrapArgs []string, ports *platform.
 	cmd := exec.Command(platform.GetFilePath(platform.Bootstrapper), bootstrapArgs...)
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	if hook == nil {
+		return nil
+	}
+	return applyBootstrapHook(configPath, hook)
+}
+
+// applyBootstrapHook rewrites the bootstrap config at configPath with the
+// changes made by the hook.
+func applyBootstrapHook(configPath string, hook BootstrapHook) error {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("fail to read bootstrap config %v: %v", configPath, err)
+	}
+	bootstrap := &bootstrappb.Bootstrap{}
+	if err := jsonpb.Unmarshal(strings.NewReader(string(content)), bootstrap); err != nil {
+		return fmt.Errorf("fail to unmarshal bootstrap config %v: %v", configPath, err)
+	}
+
+	if err := hook(bootstrap); err != nil {
+		return fmt.Errorf("bootstrap hook failed: %v", err)
+	}
+
+	jsonStr, err := util.ProtoToJson(bootstrap)
+	if err != nil {
+		return fmt.Errorf("fail to marshal bootstrap config: %v", err)
+	}
+	glog.Infof("Outputting bootstrap config changed by the hook to: %v", configPath)
+	return ioutil.WriteFile(configPath, []byte(jsonStr), 0644)
 }
 
-// NewEnvoy creates a new Envoy struct and starts envoy.
-func NewEnvoy(args []string, bootstrapArgs []string, confPath string, ports *platform.Ports) (*Envoy, error) {
+// NewEnvoy creates a new Envoy struct and starts envoy. The bootstrap hook,
+// if not nil, is applied to the generated bootstrap config.
+func NewEnvoy(args []string, bootstrapArgs []string, confPath string, concurrency int, ports *platform.Ports, bootstrapHook BootstrapHook) (*Envoy, error) {
 
-	if err := createEnvoyConf(confPath, bootstrapArgs, ports); err != nil {
+	if err := createEnvoyConf(confPath, bootstrapArgs, ports, bootstrapHook); err != nil {
 		return nil, err
 	}
 
 	args = append(args,
 		"-c", confPath,
-		// Set concurrency to 1 to have only one worker thread to test client cache.
-		"--concurrency", "1",
+		// Tests use a single worker thread by default to test client cache.
+		"--concurrency", strconv.Itoa(concurrency),
 		// Allows multiple envoys to run on a single machine. If one test fails to stop envoy, this ID
 		// will allow other tests to run afterwords without conflicting.
 		// See: https://www.envoyproxy.io/docs/envoy/latest/operations/cli#cmdoption-base-id
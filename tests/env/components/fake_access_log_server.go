@@ -0,0 +1,120 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package components
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+
+	alpb "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	alspb "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+)
+
+// FakeAccessLogServer receives the HTTP access log entries that envoy streams
+// to a gRPC access log service, and records them with their log name.
+type FakeAccessLogServer struct {
+	alspb.UnimplementedAccessLogServiceServer
+	lis        net.Listener
+	grpcServer *grpc.Server
+
+	mu       sync.Mutex
+	logNames map[string]bool
+	entries  []*alpb.HTTPAccessLogEntry
+}
+
+// NewFakeAccessLogServer starts a fake gRPC access log service on a loopback
+// TCP port picked by the OS.
+func NewFakeAccessLogServer() (*FakeAccessLogServer, error) {
+	lis, err := net.Listen("tcp", net.JoinHostPort(platform.GetLoopbackAddress(), "0"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to start fake access log server: %v", err)
+	}
+	s := &FakeAccessLogServer{
+		lis:        lis,
+		grpcServer: grpc.NewServer(),
+		logNames:   make(map[string]bool),
+	}
+	alspb.RegisterAccessLogServiceServer(s.grpcServer, s)
+	glog.Infof("Fake access log server listening on %v", s.Address())
+	go func() {
+		if err := s.grpcServer.Serve(lis); err != nil {
+			glog.Errorf("fake access log server fail to serve: %v", err)
+		}
+	}()
+	return s, nil
+}
+
+// StreamAccessLogs implements the AccessLogService. Only the first message of
+// a stream identifies the log name.
+func (s *FakeAccessLogServer) StreamAccessLogs(stream alspb.AccessLogService_StreamAccessLogsServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&alspb.StreamAccessLogsResponse{})
+		}
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		if logName := msg.GetIdentifier().GetLogName(); logName != "" {
+			s.logNames[logName] = true
+		}
+		s.entries = append(s.entries, msg.GetHttpLogs().GetLogEntry()...)
+		s.mu.Unlock()
+	}
+}
+
+// Address returns the TCP address, in the form of "ip:port", the fake access
+// log server is listening on.
+func (s *FakeAccessLogServer) Address() string {
+	return s.lis.Addr().String()
+}
+
+// HasLogName returns whether entries were streamed with the given log name.
+func (s *FakeAccessLogServer) HasLogName(logName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logNames[logName]
+}
+
+// WaitForEntries waits until at least the given number of entries are
+// received, and returns all of them. Envoy flushes the entries every second.
+func (s *FakeAccessLogServer) WaitForEntries(count int, timeout time.Duration) ([]*alpb.HTTPAccessLogEntry, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		entries := append([]*alpb.HTTPAccessLogEntry(nil), s.entries...)
+		s.mu.Unlock()
+		if len(entries) >= count {
+			return entries, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("timed out after %v waiting for %v access log entries", timeout, count)
+}
+
+// StopAndWait stops the fake access log server.
+func (s *FakeAccessLogServer) StopAndWait() {
+	glog.Infof("Stopping fake access log server")
+	s.grpcServer.Stop()
+}
@@ -83,7 +83,46 @@ func (s *FakeTraceServer) StartStackdriverServer(port uint16) {
 }
 
 func (s *FakeTraceServer) RetrieveSpanNames() ([]string, error) {
+	spans, err := s.retrieveSpans()
+	if err != nil {
+		return nil, err
+	}
+
 	names := make([]string, 0)
+	for _, span := range spans {
+		names = append(names, span.DisplayName.Value)
+	}
+	glog.Infof("got spans: %+q", names)
+	return names, nil
+}
+
+// RetrieveTraceIds returns the distinct trace ids of the received spans, in
+// the order they are first seen.
+func (s *FakeTraceServer) RetrieveTraceIds() ([]string, error) {
+	spans, err := s.retrieveSpans()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	traceIds := make([]string, 0)
+	for _, span := range spans {
+		// The span name is "projects/<project>/traces/<trace id>/spans/<span id>".
+		parts := strings.Split(span.Name, "/")
+		if len(parts) != 6 || parts[2] != "traces" {
+			return nil, fmt.Errorf("expected span %s to have the trace id in its name, but got name: %s", span.DisplayName.Value, span.Name)
+		}
+		if !seen[parts[3]] {
+			seen[parts[3]] = true
+			traceIds = append(traceIds, parts[3])
+		}
+	}
+	glog.Infof("got trace ids: %+q", traceIds)
+	return traceIds, nil
+}
+
+func (s *FakeTraceServer) retrieveSpans() ([]*cloudtracepb.Span, error) {
+	spans := make([]*cloudtracepb.Span, 0)
 	for true {
 
 		select {
@@ -99,12 +138,11 @@ func (s *FakeTraceServer) RetrieveSpanNames() ([]string, error) {
 				return nil, fmt.Errorf("expected span %s to have the project id in its name, but got name: %s", span.DisplayName.Value, span.Name)
 			}
 
-			names = append(names, span.DisplayName.Value)
+			spans = append(spans, span)
 
 		case <-time.After(1 * time.Second):
 			// No more spans received by the server.
-			glog.Infof("got spans: %+q", names)
-			return names, nil
+			return spans, nil
 		}
 	}
 
@@ -0,0 +1,101 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package components
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/golang/glog"
+)
+
+// FakeStatsdServer receives the stats that envoy flushes to a statsd sink
+// over UDP, and records the names of the received stats.
+type FakeStatsdServer struct {
+	conn net.PacketConn
+
+	mu    sync.Mutex
+	stats map[string]bool
+}
+
+// NewFakeStatsdServer starts a fake statsd server on a loopback UDP port
+// picked by the OS.
+func NewFakeStatsdServer() (*FakeStatsdServer, error) {
+	conn, err := net.ListenPacket("udp", fmt.Sprintf("%v:0", platform.GetLoopbackAddress()))
+	if err != nil {
+		return nil, fmt.Errorf("fail to start fake statsd server: %v", err)
+	}
+	s := &FakeStatsdServer{
+		conn:  conn,
+		stats: make(map[string]bool),
+	}
+	glog.Infof("Fake statsd server listening on %v", s.Address())
+	go s.serve()
+	return s, nil
+}
+
+func (s *FakeStatsdServer) serve() {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			// The connection is closed.
+			return
+		}
+
+		s.mu.Lock()
+		// A packet holds one or more newline separated lines, such as
+		// "envoy.http.ingress_http.downstream_rq_total:1|c".
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if i := strings.Index(line, ":"); i > 0 {
+				s.stats[line[:i]] = true
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Address returns the UDP address, in the form of "ip:port", the fake statsd
+// server is listening on.
+func (s *FakeStatsdServer) Address() string {
+	return s.conn.LocalAddr().String()
+}
+
+// WaitForStat waits until a stat with the given name is received.
+func (s *FakeStatsdServer) WaitForStat(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		received := s.stats[name]
+		s.mu.Unlock()
+		if received {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out after %v waiting for stat %q", timeout, name)
+}
+
+// StopAndWait stops the fake statsd server.
+func (s *FakeStatsdServer) StopAndWait() {
+	glog.Infof("Stopping fake statsd server")
+	if err := s.conn.Close(); err != nil {
+		glog.Errorf("error closing fake statsd server: %v", err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"sync/atomic"
 
 	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
@@ -71,7 +72,7 @@ func (fjs *FakeJwtService) SetupJwt(requestedProviders map[string]bool, ports *p
 		}
 
 		// Create fake provider
-		addr := fmt.Sprintf("%v:%v", platform.GetLoopbackHost(), ports.JwtRangeBase+uint16(i))
+		addr := net.JoinHostPort(platform.GetLoopbackHost(), strconv.Itoa(int(ports.JwtRangeBase)+i))
 		if config.IsInvalid {
 			provider, err = newMockInvalidJwtProvider(addr)
 		} else if config.IsNonexistent {
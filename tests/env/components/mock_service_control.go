@@ -37,6 +37,8 @@ type serviceResponse struct {
 	reqType        utils.ServiceRequestType
 	respBody       []byte
 	respStatusCode int
+	// The delay before responding, to simulate a slow service control.
+	respDelay time.Duration
 }
 
 // MockServiceMrg mocks the Service Management server.
@@ -47,6 +49,7 @@ type MockServiceCtrl struct {
 	url                string
 	count              *int32
 	serviceName        string
+	otherServiceNames  []string
 	checkHandler       http.Handler
 	quotaHandler       http.Handler
 	reportHandler      http.Handler
@@ -68,6 +71,9 @@ func (h *serviceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	req.ReqBody, _ = ioutil.ReadAll(r.Body)
 	h.m.ch <- req
 
+	if h.resp.respDelay > 0 {
+		time.Sleep(h.resp.respDelay)
+	}
 	if h.resp.respStatusCode != 0 {
 		w.WriteHeader(h.resp.respStatusCode)
 		return
@@ -138,15 +144,23 @@ func (m *MockServiceCtrl) SetCert(serverCerts *tls.Certificate) {
 	m.serverCerts = serverCerts
 }
 
+// AddServiceName lets the server also handle the requests of another service
+// before setup.
+func (m *MockServiceCtrl) AddServiceName(serviceName string) {
+	m.otherServiceNames = append(m.otherServiceNames, serviceName)
+}
+
 func (m *MockServiceCtrl) Setup() {
 	r := mux.NewRouter()
-	checkPath := "/v1/services/" + m.serviceName + ":check"
-	quotaPath := "/v1/services/" + m.serviceName + ":allocateQuota"
-	reportPath := "/v1/services/" + m.serviceName + ":report"
-
-	r.Path(checkPath).Methods("POST").Handler(m.checkHandler)
-	r.Path(quotaPath).Methods("POST").Handler(m.quotaHandler)
-	r.Path(reportPath).Methods("POST").Handler(m.reportHandler)
+	for _, serviceName := range append([]string{m.serviceName}, m.otherServiceNames...) {
+		checkPath := "/v1/services/" + serviceName + ":check"
+		quotaPath := "/v1/services/" + serviceName + ":allocateQuota"
+		reportPath := "/v1/services/" + serviceName + ":report"
+
+		r.Path(checkPath).Methods("POST").Handler(m.checkHandler)
+		r.Path(quotaPath).Methods("POST").Handler(m.quotaHandler)
+		r.Path(reportPath).Methods("POST").Handler(m.reportHandler)
+	}
 
 	glog.Infof("Start mock service control server for service: %s\n", m.serviceName)
 	m.s = httptest.NewUnstartedServer(r)
@@ -224,6 +238,11 @@ func (m *MockServiceCtrl) SetCheckResponseStatus(status int) {
 	(m.checkHandler).(*serviceHandler).resp.respStatusCode = status
 }
 
+// SetCheckResponseDelay sets the delay before responding to the check of the service control.
+func (m *MockServiceCtrl) SetCheckResponseDelay(delay time.Duration) {
+	(m.checkHandler).(*serviceHandler).resp.respDelay = delay
+}
+
 // SetQuotaResponseStatus sets the response status code for the quota of the service control.
 func (m *MockServiceCtrl) SetQuotaResponseStatus(status int) {
 	(m.quotaHandler).(*serviceHandler).resp.respStatusCode = status
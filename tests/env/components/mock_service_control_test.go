@@ -202,3 +202,25 @@ func TestMockServiceControlReportStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestMockServiceControlCheckDelay(t *testing.T) {
+	s := NewMockServiceCtrl("mmm", "test-rollout-id")
+	s.SetCheckResponseDelay(300 * time.Millisecond)
+	s.Setup()
+
+	req_body, _ := proto.Marshal(&scpb.CheckRequest{
+		ServiceName: "mmm",
+	})
+	start := time.Now()
+	resp, err := http.Post(s.GetURL()+"/v1/services/mmm:check", "application/x-protobuf", bytes.NewReader(req_body))
+	if err != nil {
+		t.Fatalf("Failed in request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Wrong response status: %v", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Check responded in %v, want it delayed by 300ms", elapsed)
+	}
+}
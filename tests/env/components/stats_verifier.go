@@ -50,6 +50,76 @@ func (sv StatsVerifier) CheckExpectedCounters(wantCounters utils.StatCounters) e
 		return err
 	}
 
+	return compareCounters(wantCounters, counters)
+}
+
+// CheckExpectedOperationCounters checks the per-operation virtual cluster
+// counters, such as "vhost.backend.vcluster.<operation>.upstream_rq_total".
+func (sv StatsVerifier) CheckExpectedOperationCounters(wantCounters utils.StatCounters) error {
+	glog.Infof("Checking envoy per-operation counters")
+	time.Sleep(fetchDelay)
+
+	counters, _, err := utils.FetchOperationStats(sv.adminPort)
+	if err != nil {
+		return err
+	}
+
+	return compareCounters(wantCounters, counters)
+}
+
+// CheckOperationHistogramRecorded checks the per-operation virtual cluster
+// histogram, such as "vhost.backend.vcluster.<operation>.upstream_rq_time",
+// has recorded samples with a non-zero maximum.
+func (sv StatsVerifier) CheckOperationHistogramRecorded(name string) error {
+	glog.Infof("Checking envoy per-operation histogram %v", name)
+	time.Sleep(fetchDelay)
+
+	_, histograms, err := utils.FetchOperationStats(sv.adminPort)
+	if err != nil {
+		return err
+	}
+
+	vals, ok := histograms[name]
+	if !ok || len(vals) == 0 {
+		return fmt.Errorf("expected histogram %v not in the got histograms: %v", name, histograms)
+	}
+
+	maxVal := 0.0
+	for _, val := range vals {
+		if val > maxVal {
+			maxVal = val
+		}
+	}
+	if maxVal <= 0 {
+		return fmt.Errorf("histogram %v has not recorded any latency, got vals: %v", name, vals)
+	}
+	return nil
+}
+
+// GetStat returns the current value of the counter or gauge with the full
+// name, e.g. "http.ingress_http.downstream_rq_5xx".
+func (sv StatsVerifier) GetStat(name string) (int, error) {
+	glog.Infof("Getting envoy stat %v", name)
+	time.Sleep(fetchDelay)
+
+	return utils.FetchStat(sv.adminPort, name)
+}
+
+// ExpectStat checks the value of the counter or gauge with the full name
+// satisfies the predicate.
+func (sv StatsVerifier) ExpectStat(name string, predicate func(val int) bool) error {
+	val, err := sv.GetStat(name)
+	if err != nil {
+		return err
+	}
+
+	if !predicate(val) {
+		return fmt.Errorf("stat %v with value %v does not satisfy the expectation", name, val)
+	}
+	return nil
+}
+
+func compareCounters(wantCounters, counters utils.StatCounters) error {
 	for wantCounter, wantCounterVal := range wantCounters {
 		if getCountVal, ok := counters[wantCounter]; !ok {
 			return fmt.Errorf("expected counter %v not in the got counters: %v", wantCounter, counters)
@@ -15,13 +15,21 @@
 package env
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/components"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
@@ -30,6 +38,7 @@ import (
 	bookserver "github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/server"
 	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+	apipb "google.golang.org/genproto/protobuf/api"
 )
 
 const (
@@ -71,10 +80,20 @@ type TestEnv struct {
 	backendAddress                  string
 	ports                           *platform.Ports
 	envoyDrainTimeInSec             int
+	envoyConcurrency                int
+	envoyRuntime                    string
+	adminAddress                    string
+	bootstrapHook                   components.BootstrapHook
 	ServiceControlServer            *components.MockServiceCtrl
 	FakeStackdriverServer           *components.FakeTraceServer
 	enableTracing                   bool
 	tracingSampleRate               float32
+	FakeStatsdServer                *components.FakeStatsdServer
+	enableStatsd                    bool
+	statsdPrefix                    string
+	FakeAccessLogServer             *components.FakeAccessLogServer
+	enableAccessLogGrpc             bool
+	accessLogGrpcLogName            string
 	healthRegistry                  *components.HealthRegistry
 	FakeJwtService                  *components.FakeJwtService
 	skipHealthChecks                bool
@@ -88,7 +107,24 @@ type TestEnv struct {
 	backendNotStart             bool
 	backendRejectRequestNum     int
 	backendRejectRequestStatus  int
+	backendTLSHandshakeDelay    time.Duration
+	backendRequiredSni          string
 	disableHttp2ForHttpsBackend bool
+
+	// Certificate files for a TLS listener, empty for a plaintext listener.
+	listenerCertFile string
+	listenerKeyFile  string
+	listenerCaFile   string
+	listenerCertDir  string
+
+	// Services served on their own listeners, besides fakeServiceConfig.
+	additionalServices    []additionalService
+	additionalServicesDir string
+}
+
+type additionalService struct {
+	serviceConfig *confpb.Service
+	listenerPort  uint16
 }
 
 func NewTestEnv(testId uint16, backend platform.Backend) *TestEnv {
@@ -115,6 +151,39 @@ func (e *TestEnv) SetEnvoyDrainTimeInSec(envoyDrainTimeInSec int) {
 	e.envoyDrainTimeInSec = envoyDrainTimeInSec
 }
 
+// SetEnvoyConcurrency sets the number of Envoy worker threads, 1 by default.
+func (e *TestEnv) SetEnvoyConcurrency(envoyConcurrency int) {
+	e.envoyConcurrency = envoyConcurrency
+}
+
+// SetEnvoyRuntime sets static Envoy runtime values in the bootstrap, in the
+// format key1=value1;key2=value2.
+func (e *TestEnv) SetEnvoyRuntime(envoyRuntime string) {
+	e.envoyRuntime = envoyRuntime
+}
+
+// SetBootstrapHook sets a hook to mutate the generated Envoy bootstrap config
+// before Envoy starts, e.g. to add stats sinks.
+func (e *TestEnv) SetBootstrapHook(hook components.BootstrapHook) {
+	e.bootstrapHook = hook
+}
+
+// SetAdminAddress sets the address the Envoy admin interface binds to, all
+// interfaces by default.
+func (e *TestEnv) SetAdminAddress(adminAddress string) {
+	e.adminAddress = adminAddress
+}
+
+// AddAdditionalService serves another service on its own listener port. Its
+// requests to service control are sent to ServiceControlServer.
+func (e *TestEnv) AddAdditionalService(serviceConfig *confpb.Service, listenerPort uint16) {
+	e.additionalServices = append(e.additionalServices, additionalService{
+		serviceConfig: serviceConfig,
+		listenerPort:  listenerPort,
+	})
+	e.ServiceControlServer.AddServiceName(serviceConfig.GetName())
+}
+
 // OverrideMockMetadata overrides mock metadata values given path to response map.
 func (e *TestEnv) OverrideMockMetadata(newImdsData map[string]string, imdsFailures int) {
 	e.mockMetadataOverride = newImdsData
@@ -178,6 +247,88 @@ func (e *TestEnv) SetBackendRejectRequestStatus(backendFaRequestStatus int) {
 	e.backendRejectRequestStatus = backendFaRequestStatus
 }
 
+// SetBackendTLSHandshakeDelay delays the TLS handshake of the remote echo
+// backend, to simulate a backend that is slow to accept connections.
+func (e *TestEnv) SetBackendTLSHandshakeDelay(delay time.Duration) {
+	e.backendTLSHandshakeDelay = delay
+}
+
+// SetBackendRequiredSni makes the remote echo backend reject TLS handshakes
+// with a different SNI.
+func (e *TestEnv) SetBackendRequiredSni(sni string) {
+	e.backendRequiredSni = sni
+}
+
+// EnableListenerTLS makes the listener serve TLS with the certificate and key
+// files. If caFile is not empty, client certificates are required and verified
+// against it. Use ListenerURL and ListenerHttpClient to call the listener.
+func (e *TestEnv) EnableListenerTLS(certFile, keyFile, caFile string) {
+	e.listenerCertFile = certFile
+	e.listenerKeyFile = keyFile
+	e.listenerCaFile = caFile
+}
+
+// ListenerURL returns the URL of the path on the listener, with the scheme
+// matching the listener.
+func (e *TestEnv) ListenerURL(path string) string {
+	if e.listenerCertFile == "" {
+		return fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), e.ports.ListenerPort, path)
+	}
+	// FIXME: Use of localhost. Difficult to generate certs with ip addresses.
+	return fmt.Sprintf("https://%v:%v%v", platform.GetLocalhost(), e.ports.ListenerPort, path)
+}
+
+// ListenerHttpClient returns a client for the listener. With TLS enabled, the
+// client trusts the listener certificate.
+func (e *TestEnv) ListenerHttpClient() (*http.Client, error) {
+	if e.listenerCertFile == "" {
+		return &http.Client{}, nil
+	}
+	cert, err := ioutil.ReadFile(e.listenerCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read listener cert: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(cert) {
+		return nil, fmt.Errorf("fail to parse listener cert %v", e.listenerCertFile)
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: rootCAs,
+			},
+		},
+	}, nil
+}
+
+// setupListenerTLS copies the listener certificate and key into a directory,
+// under the names the config manager expects, and returns the flags for it.
+func (e *TestEnv) setupListenerTLS() ([]string, error) {
+	dir, err := ioutil.TempDir("", fmt.Sprintf("apiproxy-testdata-listener-tls-%v-", e.ports.TestId))
+	if err != nil {
+		return nil, err
+	}
+	e.listenerCertDir = dir
+	for src, dst := range map[string]string{
+		e.listenerCertFile: "server.crt",
+		e.listenerKeyFile:  "server.key",
+	} {
+		content, err := ioutil.ReadFile(src)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, dst), content, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{"--ssl_server_cert_path=" + dir}
+	if e.listenerCaFile != "" {
+		args = append(args, "--ssl_server_root_cert_path="+e.listenerCaFile)
+	}
+	return args, nil
+}
+
 // SetBackendMTLSCert sets the backend cert file to enable mutual authentication.
 func (e *TestEnv) SetBackendMTLSCert(fileName string) {
 	e.backendMTLSCertFile = fileName
@@ -201,6 +352,34 @@ func (e *TestEnv) OverrideRolloutIdAndConfigId(newRolloutId, newConfigId string)
 	e.ServiceControlServer.SetRolloutIdConfigIdInReport(newRolloutId)
 }
 
+// writeAdditionalServices writes the additional service configs to files, and
+// returns the value of --additional_services.
+func (e *TestEnv) writeAdditionalServices() (string, error) {
+	dir, err := ioutil.TempDir("", fmt.Sprintf("apiproxy-testdata-additional-services-%v-", e.ports.TestId))
+	if err != nil {
+		return "", err
+	}
+	e.additionalServicesDir = dir
+
+	var pairs []string
+	for i, service := range e.additionalServices {
+		testdata.SetFakeControlEnvironment(service.serviceConfig, e.ServiceControlServer.GetURL())
+		if err := testdata.AppendLogMetrics(service.serviceConfig); err != nil {
+			return "", err
+		}
+		serviceConfigJson, err := util.ProtoToJson(service.serviceConfig)
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("service_config_%d.json", i))
+		if err := ioutil.WriteFile(path, []byte(serviceConfigJson), 0644); err != nil {
+			return "", err
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%d", path, service.listenerPort))
+	}
+	return strings.Join(pairs, ","), nil
+}
+
 func (e *TestEnv) ServiceConfigId() string {
 	if e.fakeServiceConfig == nil {
 		return ""
@@ -237,11 +416,71 @@ func (e *TestEnv) RemoveAllBackendRules() {
 	e.fakeServiceConfig.Backend = &confpb.Backend{}
 }
 
+// RemoveMethod removes the method with the given selector from Service.Apis,
+// along with the http, authentication, usage and backend rules for it.
+func (e *TestEnv) RemoveMethod(selector string) {
+	for _, api := range e.fakeServiceConfig.Apis {
+		var methods []*apipb.Method
+		for _, method := range api.Methods {
+			if fmt.Sprintf("%s.%s", api.Name, method.Name) != selector {
+				methods = append(methods, method)
+			}
+		}
+		api.Methods = methods
+	}
+
+	if e.fakeServiceConfig.Http != nil {
+		var rules []*annotationspb.HttpRule
+		for _, rule := range e.fakeServiceConfig.Http.Rules {
+			if rule.Selector != selector {
+				rules = append(rules, rule)
+			}
+		}
+		e.fakeServiceConfig.Http.Rules = rules
+	}
+
+	if e.fakeServiceConfig.Authentication != nil {
+		var rules []*confpb.AuthenticationRule
+		for _, rule := range e.fakeServiceConfig.Authentication.Rules {
+			if rule.Selector != selector {
+				rules = append(rules, rule)
+			}
+		}
+		e.fakeServiceConfig.Authentication.Rules = rules
+	}
+
+	if e.fakeServiceConfig.Usage != nil {
+		var rules []*confpb.UsageRule
+		for _, rule := range e.fakeServiceConfig.Usage.Rules {
+			if rule.Selector != selector {
+				rules = append(rules, rule)
+			}
+		}
+		e.fakeServiceConfig.Usage.Rules = rules
+	}
+
+	if e.fakeServiceConfig.Backend != nil {
+		var rules []*confpb.BackendRule
+		for _, rule := range e.fakeServiceConfig.Backend.Rules {
+			if rule.Selector != selector {
+				rules = append(rules, rule)
+			}
+		}
+		e.fakeServiceConfig.Backend.Rules = rules
+	}
+}
+
 // EnableScNetworkFailOpen sets enableScNetworkFailOpen to be true.
 func (e *TestEnv) EnableScNetworkFailOpen() {
 	e.enableScNetworkFailOpen = true
 }
 
+// SetServiceControlCheckDelay delays the responses to service control Check,
+// e.g. to exceed --service_control_check_timeout_ms.
+func (e *TestEnv) SetServiceControlCheckDelay(delay time.Duration) {
+	e.ServiceControlServer.SetCheckResponseDelay(delay)
+}
+
 // AppendUsageRules appends Service.Usage.Rules.
 func (e *TestEnv) AppendUsageRules(rules []*confpb.UsageRule) {
 	e.fakeServiceConfig.Usage.Rules = append(e.fakeServiceConfig.Usage.Rules, rules...)
@@ -293,6 +532,20 @@ func (e *TestEnv) SetupFakeTraceServer(sampleRate float32) {
 	e.tracingSampleRate = sampleRate
 }
 
+// SetupFakeStatsdServer starts a fake statsd server in Setup, and configures
+// envoy to flush its stats to it with the given prefix.
+func (e *TestEnv) SetupFakeStatsdServer(prefix string) {
+	e.enableStatsd = true
+	e.statsdPrefix = prefix
+}
+
+// SetupFakeAccessLogServer starts a fake gRPC access log service in Setup, and
+// configures envoy to stream its access log to it with the given log name.
+func (e *TestEnv) SetupFakeAccessLogServer(logName string) {
+	e.enableAccessLogGrpc = true
+	e.accessLogGrpcLogName = logName
+}
+
 func (e *TestEnv) DisableHttp2ForHttpsBackend() {
 	e.disableHttp2ForHttpsBackend = true
 }
@@ -337,6 +590,14 @@ func (e *TestEnv) Setup(confArgs []string) error {
 		}
 
 		confArgs = append(confArgs, "--service_management_url="+e.MockServiceManagementServer.Start())
+
+		if len(e.additionalServices) > 0 {
+			additionalServicesArg, err := e.writeAdditionalServices()
+			if err != nil {
+				return err
+			}
+			confArgs = append(confArgs, "--additional_services="+additionalServicesArg)
+		}
 	}
 
 	if !e.enableScNetworkFailOpen {
@@ -349,6 +610,38 @@ func (e *TestEnv) Setup(confArgs []string) error {
 		bootstrapperArgs = append(bootstrapperArgs, "--metadata_url="+e.MockMetadataServer.GetURL())
 	}
 
+	if e.envoyRuntime != "" {
+		bootstrapperArgs = append(bootstrapperArgs, "--envoy_runtime="+e.envoyRuntime)
+	}
+
+	if e.adminAddress != "" {
+		bootstrapperArgs = append(bootstrapperArgs, "--admin_address="+e.adminAddress)
+	}
+
+	if e.enableStatsd {
+		var err error
+		e.FakeStatsdServer, err = components.NewFakeStatsdServer()
+		if err != nil {
+			return err
+		}
+		bootstrapperArgs = append(bootstrapperArgs, "--statsd_address="+e.FakeStatsdServer.Address())
+		if e.statsdPrefix != "" {
+			bootstrapperArgs = append(bootstrapperArgs, "--statsd_prefix="+e.statsdPrefix)
+		}
+	}
+
+	if e.enableAccessLogGrpc {
+		var err error
+		e.FakeAccessLogServer, err = components.NewFakeAccessLogServer()
+		if err != nil {
+			return err
+		}
+		confArgs = append(confArgs, "--access_log_grpc_address=grpc://"+e.FakeAccessLogServer.Address())
+		if e.accessLogGrpcLogName != "" {
+			confArgs = append(confArgs, "--access_log_grpc_log_name="+e.accessLogGrpcLogName)
+		}
+	}
+
 	if e.mockIamResps != nil || e.mockIamFailures != 0 || e.mockIamRespTime != 0 {
 		e.MockIamServer = components.NewIamMetadata(e.mockIamResps, e.mockIamFailures, e.mockIamRespTime)
 		confArgs = append(confArgs, "--iam_url="+e.MockIamServer.GetURL())
@@ -371,6 +664,13 @@ func (e *TestEnv) Setup(confArgs []string) error {
 	}
 
 	confArgs = append(confArgs, fmt.Sprintf("--listener_port=%v", e.ports.ListenerPort))
+	if e.listenerCertFile != "" {
+		tlsArgs, err := e.setupListenerTLS()
+		if err != nil {
+			return fmt.Errorf("unable to set up listener TLS: %v", err)
+		}
+		confArgs = append(confArgs, tlsArgs...)
+	}
 	confArgs = append(confArgs, fmt.Sprintf("--service=%v", e.fakeServiceConfig.Name))
 
 	// Tracing configuration.
@@ -424,7 +724,12 @@ func (e *TestEnv) Setup(confArgs []string) error {
 		envoyArgs = append(envoyArgs, "--drain-time-s", strconv.Itoa(e.envoyDrainTimeInSec))
 	}
 
-	e.envoy, err = components.NewEnvoy(envoyArgs, bootstrapperArgs, envoyConfPath, e.ports)
+	envoyConcurrency := 1
+	if e.envoyConcurrency != 0 {
+		envoyConcurrency = e.envoyConcurrency
+	}
+
+	e.envoy, err = components.NewEnvoy(envoyArgs, bootstrapperArgs, envoyConfPath, envoyConcurrency, e.ports, e.bootstrapHook)
 	if err != nil {
 		glog.Errorf("unable to create Envoy %v", err)
 		return err
@@ -469,6 +774,8 @@ func (e *TestEnv) Setup(confArgs []string) error {
 				BackendAlwaysRespondRST:    e.backendAlwaysRespondRST,
 				BackendRejectRequestNum:    e.backendRejectRequestNum,
 				BackendRejectRequestStatus: e.backendRejectRequestStatus,
+				TLSHandshakeDelay:          e.backendTLSHandshakeDelay,
+				RequiredSni:                e.backendRequiredSni,
 			})
 			if err != nil {
 				return err
@@ -610,13 +917,32 @@ func (e *TestEnv) TearDown(t *testing.T) {
 
 	e.FakeStackdriverServer.StopAndWait()
 
+	if e.FakeStatsdServer != nil {
+		e.FakeStatsdServer.StopAndWait()
+	}
+
+	if e.FakeAccessLogServer != nil {
+		e.FakeAccessLogServer.StopAndWait()
+	}
+
+	if e.listenerCertDir != "" {
+		if err := os.RemoveAll(e.listenerCertDir); err != nil {
+			glog.Errorf("error removing listener cert dir: %v", err)
+		}
+	}
+	if e.additionalServicesDir != "" {
+		if err := os.RemoveAll(e.additionalServicesDir); err != nil {
+			glog.Errorf("error removing additional services dir: %v", err)
+		}
+	}
+
 	glog.Infof("finish tearing down...")
 }
 
 // Form the backend address.
 func formBackendAddress(ports *platform.Ports, backend platform.Backend) (string, error) {
 
-	backendAddress := fmt.Sprintf("%v:%v", platform.GetLoopbackHost(), ports.BackendServerPort)
+	backendAddress := net.JoinHostPort(platform.GetLoopbackHost(), strconv.Itoa(int(ports.BackendServerPort)))
 
 	switch backend {
 	case platform.GrpcEchoRemote, platform.EchoRemote, platform.GrpcBookstoreRemote:
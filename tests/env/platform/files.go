@@ -46,11 +46,25 @@ const (
 	DownstreamClientKey
 	MismatchCert
 	MismatchKey
+	SpiffeClientCert
+	SpiffeClientKey
+	CrlCaCert
+	CrlClientCert
+	CrlClientKey
+	CrlRevokedClientCert
+	CrlRevokedClientKey
+	CrlRevoked
+	CrlEmpty
+	Favicon
 	LogMetrics
 	Version
 	AccessLog
+	AccessLogRequestId
+	AccessLogResponseFlags
 	ServiceAccountFile
 	TestRootCaCerts
+	TestClientCrl
+	TestClientCrlEmpty
 	TestDataFolder
 
 	// Configurations from examples directory
@@ -102,8 +116,20 @@ var fileMap = map[RuntimeFile]string{
 	DownstreamClientKey:         "../../env/testdata/downstream_client.key",
 	MismatchCert:                "../../env/testdata/mismatch.crt",
 	MismatchKey:                 "../../env/testdata/mismatch.key",
+	SpiffeClientCert:            "../../env/testdata/spiffe_client.crt",
+	SpiffeClientKey:             "../../env/testdata/spiffe_client.key",
+	CrlCaCert:                   "../../env/testdata/crl_ca.crt",
+	CrlClientCert:               "../../env/testdata/crl_client.crt",
+	CrlClientKey:                "../../env/testdata/crl_client.key",
+	CrlRevokedClientCert:        "../../env/testdata/crl_revoked_client.crt",
+	CrlRevokedClientKey:         "../../env/testdata/crl_revoked_client.key",
+	CrlRevoked:                  "../../env/testdata/crl_revoked.crl",
+	CrlEmpty:                    "../../env/testdata/crl_empty.crl",
+	Favicon:                     "../../env/testdata/favicon.ico",
 	LogMetrics:                  "../../env/testdata/logs_metrics.pb.txt",
 	AccessLog:                   "../../env/testdata/access_log.txt",
+	AccessLogRequestId:          "../../env/testdata/access_log_request_id.txt",
+	AccessLogResponseFlags:      "../../env/testdata/access_log_response_flags.txt",
 	TestDataFolder:              "../../env/testdata/",
 
 	// Used by static bootstrap unit tests.
@@ -122,6 +148,8 @@ var fileMap = map[RuntimeFile]string{
 
 	// Used by other unit tests.
 	TestRootCaCerts:        "../../../tests/env/testdata/roots.pem",
+	TestClientCrl:          "../../../tests/env/testdata/crl_revoked.crl",
+	TestClientCrlEmpty:     "../../../tests/env/testdata/crl_empty.crl",
 	FixedDrServiceConfig:   "../../../tests/env/testdata/service_config_for_fixed_dynamic_routing.json",
 	FakeServiceAccountFile: "./service_account.json",
 }
@@ -155,6 +155,97 @@ const (
 	TestTranscodingIgnoreQueryParameters
 	TestTranscodingPrintOptions
 	TestWebsocket
+	TestDownstreamSpiffeMTLS
+	TestServiceControlOperationName
+	TestServiceControlExtraLabels
+	TestServiceControlLogEntryFields
+	TestServiceControlReportSizes
+	TestServiceControlBackendLatency
+	TestServiceControlMaxPendingReports
+	TestServiceControlReportRetryWithBackoff
+	TestAccessLogRequestId
+	TestBackendAuthStaticToken
+	TestJwtClaimRouting
+	TestProxyProtocolClientAddress
+	TestGRPCUndeclaredMethod
+	TestRequestMirroring
+	TestServiceControlSkipPath
+	TestServiceControlQuotaMultipleMetricRules
+	TestListenerAddress
+	TestOversizedJwt
+	TestServiceControlJwtClaimLabels
+	TestServiceControlCredentialIdPrefix
+	TestBackendFallback
+	TestBackendHostRewrite
+	TestListenerTLS
+	TestDownstreamMTLSForwardClientCert
+	TestPreflightRequestWithAllowCorsDisabledOperations
+	TestProxyHandleCorsSimpleRequestsWithCredentials
+	TestListenerHttp2Keepalive
+	TestEnvoyConcurrency
+	TestStatisticsPerOperation
+	TestStatisticsDownstream5xx
+	TestEnvoyRuntime
+	TestBackendConnectTimeout
+	TestTranscodingErrorDetails
+	TestFaultInjection
+	TestFaultInjectionByApiKey
+	TestAcceptHttp10
+	TestDisableChunkedEncoding
+	TestBackendHostRewriteForServiceControl
+	TestResponseHeadersAllowlist
+	TestBackendSni
+	TestBackendAlpn
+	TestBackendStripPrefix
+	TestBackendRegexRewrite
+	TestServiceControlSuccessStatusCodes
+	TestTranscodingCompression
+	TestAdminLoopback
+	TestServiceControlUnmatchedOperation
+	TestServiceControlStatsReport
+	TestJwtSkipAudienceCheck
+	TestJwtProviderAdditionalIssuers
+	TestBackendAuthTokenHeader
+	TestConfigSwapRollback
+	TestAdditionalServices
+	TestGRPCNonexistentMethod
+	TestStreamingPassthrough
+	TestAccessLogResponseFlags
+	TestMaxRequestBytesByOperation
+	TestCorsPreflightWithoutApiKey
+	TestJwtPerRouteAudiences
+	TestRequireRequestHeaders
+	TestBackendHealthCheck
+	TestBackendUnavailableMessage
+	TestMaxGrpcTimeout
+	TestCheckApiKeyBeforeJwt
+	TestServiceControlReportApiVersion
+	TestStreamingResponse
+	TestBootstrapHook
+	TestServiceControlCheckDelay
+	TestStatisticsPerOperationLatency
+	TestDisableTranscoding
+	TestTranscodingMatchIncomingRequestRoute
+	TestQueryParameterAllowlist
+	TestReportTraceIdMatchesSpans
+	TestStatsdSink
+	TestServiceControlAPIKeyForwardedToBackend
+	TestServiceControlReportFlushJitter
+	TestDownstreamClientCrl
+	TestMaxRequestDuration
+	TestStaticResponses
+	TestTranscodingMaxMessageBytes
+	TestAuthBypass
+	TestAccessLogGrpc
+	TestTrustPreauthenticatedJwtHeader
+	TestBackendAuthPerRouteOptOut
+	TestIPv6ListenerAndBackend
+	TestProxyHandlesCorsPreflightRequestsDisabledOperations
+	TestServiceControlSuccessStatusCodesGrpc
+	TestStreamingPassthroughGrpc
+	TestJwksWarmOnStartup
+	TestStatisticsPerConsumer
+	TestEnvoyRuntimeTakesEffect
 	// The number of total tests. has to be the last one.
 	maxTestNum
 )
@@ -191,6 +282,7 @@ type Ports struct {
 	BackendServerPort         uint16
 	DynamicRoutingBackendPort uint16
 	ListenerPort              uint16
+	AdditionalListenerPort    uint16
 	AdminPort                 uint16
 	FakeStackdriverPort       uint16
 	DnsResolverPort           uint16
@@ -253,6 +345,7 @@ func NewPorts(testId uint16) *Ports {
 		BackendServerPort:         base,
 		DynamicRoutingBackendPort: base + 1,
 		ListenerPort:              base + 2,
+		AdditionalListenerPort:    base + 3,
 		AdminPort:                 base + 4,
 		FakeStackdriverPort:       base + 5,
 		DnsResolverPort:           base + 6,
@@ -155,6 +155,12 @@ const (
 			"kid":"DHFbpoIUqrY8t2zpA2qXfCmr5VO5ZEr4RzHU_-envvQ",
 			"kty":"RSA",
 			"n":"xAE7eB6qugXyCAG3yhh7pkDkT65pHymX-P7KfIupjf59vsdo91bSP9C8H07pSAGQO1MV_xFj9VswgsCg4R6otmg5PV2He95lZdHtOcU5DXIg_pbhLdKXbi66GlVeK6ABZOUW3WYtnNHD-91gVuoeJT_DwtGGcp4ignkgXfkiEm4sw-4sfb4qdt5oLbyVpmW6x9cfa7vs2WTfURiCrBoUqgBo_-4WTiULmmHSGZHOjzwa8WtrtOQGsAFjIbno85jp6MnGGGZPYZbDAa_b3y5u-YpW7ypZrvD8BgtKVjgtQgZhLAGezMt0ua3DRrWnKqTZ0BJ_EyxOGuHJrLsn00fnMQ"
+		},
+		{
+			"e":"AQAB",
+			"kid":"sc-jwt-claims",
+			"kty":"RSA",
+			"n":"wzguXfDVkwyjz9yC7Bf6qKGh3TGjHNiaqmC2VvjASIfFYtmdAe10WQITDHajWvzoA6DGmEuadh8zPXI9oYDqO0m-HHT0kFc-rQNXVqeUC07YdLOs7a0Xv85n-9F-2WUIBkhhab0CDQb_rJ1iT4wOUc5x0YHqhvLc5FL-FeptSRv553wP_TbTWiiSlbjTDeppeckEqtuEJKXQse2-Pq_FUGT9D6wiUCUquO2ES0tvWJ-i8-wwDROJ8J8OtR41hUJpK3m5MtvuyHT8rA54U55mqL-BpyHZMyxCRwEE_KvQxwyhVvJLfzg-cmIPQqtcK3ypT7bJa5Jlbhb7fhNJWx2wOQ"
 		}
 	 ]
 	}`
@@ -221,6 +227,26 @@ const (
 		"01IgOPc5s2XMUjnWoSk_is1Hc527jvIOQhnSDZyHqt9QfsDKdNvZ0qj7E_3p2rbaaTiIno" +
 		"gDsvj0aA"
 
+	// Signed with the "sc-jwt-claims" key of ServiceControlJwtPayloadPubKeys.
+	// Generated with payload:
+	//	{
+	//	"aud": "ok_audience_1",
+	//	"email": "es256-issuer@example.com",
+	//	"exp": 4703162488,
+	//	"iat": 1549562488,
+	//	"iss": "es256-issuer",
+	//	"sub": "es256-issuer"
+	//	}
+	ServiceControlJwtClaimsToken = "eyJhbGciOiJSUzI1NiIsImtpZCI6InNjLWp3dC1jbGFpbXMiLCJ0eXAiOiJKV1QifQ.eyJh" +
+		"dWQiOiJva19hdWRpZW5jZV8xIiwiZW1haWwiOiJlczI1Ni1pc3N1ZXJAZXhhbXBsZS5jb20" +
+		"iLCJleHAiOjQ3MDMxNjI0ODgsImlhdCI6MTU0OTU2MjQ4OCwiaXNzIjoiZXMyNTYtaXNzdW" +
+		"VyIiwic3ViIjoiZXMyNTYtaXNzdWVyIn0.C249hd9WXAkuNVJUPSbcsWPyCH-kL_-EOXJlB" +
+		"ovEiRQYckMTG5PFf9NCueK3YRcbVG5BYIs31bXGiv5nHKF3TWrGq8RVhb4WeYar21KZUkzj" +
+		"urmXa6hsrua58VvWFfQ7F3D7hk7PZuNtcviIl3ACXy9JPm4ywonZKSNnhHRdCV8vLQWlLwq" +
+		"RWjWvXbzH797s2THaOpRDCuW9NsGnrtqY-8TitryS3PGeLYWZr70yixwcLB266UsO94gqVM" +
+		"yTh_SO1Q3LYooT7PQhky2brz4Iwyl0oIwZrLvZ6shmzra2xqEMp1N5W4XCF01p-qhz5I4TD" +
+		"ih_Xq9ANm8iK6PyjSIIDg"
+
 	Es256Token = "eyJhbGciOiJFUzI1NiIsInR5cCI6IkpXVCIsImtpZCI6IjFhIn0.eyJpc3MiO" +
 		"iJlczI1Ni1pc3N1ZXIiLCJzdWIiOiJlczI1Ni1pc3N1ZXIiLCJhdWQiOiJva19hdWRpZW5" +
 		"jZSJ9.hz9IUedX6WTbuxQSbcXBSKfvF2hK48o06CnxJn-5vyOkWfUNroJjb3JokQpweF9X" +
@@ -20,10 +20,15 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	alpb "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
 )
 
 func tryRemoveFile(path string) error {
@@ -120,3 +125,210 @@ func TestAccessLog(t *testing.T) {
 		_t()
 	}
 }
+
+func TestAccessLogRequestId(t *testing.T) {
+	t.Parallel()
+
+	accessLogFilePath := platform.GetFilePath(platform.AccessLogRequestId)
+	if err := tryRemoveFile(accessLogFilePath); err != nil {
+		t.Fatalf("fail to remove accessLogFile, %v", err)
+	}
+	defer tryRemoveFile(accessLogFilePath)
+
+	testCases := []struct {
+		desc          string
+		requestHeader map[string]string
+		wantRequestId string
+	}{
+		{
+			desc: "request id is generated if absent",
+		},
+		{
+			desc: "request id from the client is preserved",
+			requestHeader: map[string]string{
+				"X-Request-Id": "test-request-id",
+			},
+			wantRequestId: "test-request-id",
+		},
+	}
+
+	for _, tc := range testCases {
+		// Envoy keeps the access log file open, so each case gets a fresh env
+		// writing to a new file.
+		_t := func() {
+			args := []string{"--service_config_id=test-config-id",
+				"--rollout_strategy=fixed", "--access_log=" + accessLogFilePath, "--access_log_format=%REQ(X-REQUEST-ID)%\n",
+				"--log_entry_fields=request_id"}
+
+			s := env.NewTestEnv(platform.TestAccessLogRequestId, platform.EchoSidecar)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("Test (%s): fail to setup test env, %v", tc.desc, err)
+			}
+			defer s.TearDown(t)
+
+			url := fmt.Sprintf("http://%v:%v/echoHeader?key=test-api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			respHeader, _, err := utils.DoWithHeaders(url, "GET", "", tc.requestHeader)
+			if err != nil {
+				t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+			}
+
+			// The backend echoes the request headers it received.
+			backendRequestId := respHeader.Get("Echo-X-Request-Id")
+			if backendRequestId == "" {
+				t.Fatalf("Test (%s): failed, backend did not receive a request id", tc.desc)
+			}
+			if tc.wantRequestId != "" && backendRequestId != tc.wantRequestId {
+				t.Errorf("Test (%s): failed, backend got request id %q, want %q", tc.desc, backendRequestId, tc.wantRequestId)
+			}
+
+			scRequests, err := s.ServiceControlServer.GetRequests(2)
+			if err != nil {
+				t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err)
+			}
+			report, err := utils.UnmarshalReportRequest(scRequests[1].ReqBody)
+			if err != nil {
+				t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+			}
+			fields := report.GetOperations()[0].GetLogEntries()[0].GetStructPayload().GetFields()
+			if got := fields["request_id"].GetStringValue(); got != backendRequestId {
+				t.Errorf("Test (%s): failed, report got request id %q, want %q", tc.desc, got, backendRequestId)
+			}
+
+			bytes, err := ioutil.ReadFile(accessLogFilePath)
+			if err != nil {
+				t.Fatalf("Test (%s): fail to read access log file: %v", tc.desc, err)
+			}
+			if got, want := string(bytes), backendRequestId+"\n"; got != want {
+				t.Errorf("Test (%s): failed, access log got %q, want %q", tc.desc, got, want)
+			}
+
+			if err := tryRemoveFile(accessLogFilePath); err != nil {
+				t.Fatalf("Test (%s): fail to remove accessLogFile, %v", tc.desc, err)
+			}
+		}
+
+		_t()
+	}
+}
+
+func TestAccessLogResponseFlags(t *testing.T) {
+	t.Parallel()
+
+	accessLogFilePath := platform.GetFilePath(platform.AccessLogResponseFlags)
+	if err := tryRemoveFile(accessLogFilePath); err != nil {
+		t.Fatalf("fail to remove accessLogFile, %v", err)
+	}
+	defer tryRemoveFile(accessLogFilePath)
+
+	args := []string{"--service_config_id=test-config-id",
+		"--rollout_strategy=fixed", "--access_log=" + accessLogFilePath,
+		"--access_log_format=%RESPONSE_CODE% %RESPONSE_FLAGS% %UPSTREAM_CLUSTER%\n"}
+
+	// The backend is not started, so connections to it fail.
+	s := env.NewTestEnv(platform.TestAccessLogResponseFlags, platform.EchoSidecar)
+	s.SetBackendNotStart(true)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	makeOneRequest(t, s, "/echoHeader", `http response status is not 200 OK: 503 Service Unavailable`)
+
+	bytes, err := ioutil.ReadFile(accessLogFilePath)
+	if err != nil {
+		t.Fatalf("fail to read access log file: %v", err)
+	}
+	fields := strings.Fields(string(bytes))
+	if len(fields) != 3 {
+		t.Fatalf("got access log %q, want response code, flags and upstream cluster", string(bytes))
+	}
+	if got, want := fields[0], "503"; got != want {
+		t.Errorf("got response code %q, want %q", got, want)
+	}
+	// Retries on connect failure may add URX, e.g. "URX,UF".
+	if got := strings.Split(fields[1], ","); !containsFlag(got, "UF") {
+		t.Errorf("got response flags %q, want UF for the upstream connection failure", fields[1])
+	}
+	if got, want := fields[2], "backend-cluster-echo-api.endpoints.cloudesf-testing.cloud.goog_local"; got != want {
+		t.Errorf("got upstream cluster %q, want %q", got, want)
+	}
+}
+
+func TestAccessLogGrpc(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--service_config_id=test-config-id", "--rollout_strategy=fixed"}
+	s := env.NewTestEnv(platform.TestAccessLogGrpc, platform.EchoSidecar)
+	s.SetupFakeAccessLogServer("espv2-test")
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testCases := []struct {
+		desc                string
+		requestPath         string
+		wantError           string
+		wantPath            string
+		wantResponseCode    uint32
+		wantUpstreamCluster string
+	}{
+		{
+			desc:                "successful request",
+			requestPath:         "/echoHeader",
+			wantPath:            "/echoHeader?key=test-api-key",
+			wantResponseCode:    200,
+			wantUpstreamCluster: "backend-cluster-echo-api.endpoints.cloudesf-testing.cloud.goog_local",
+		},
+		{
+			desc:             "request failed in path matcher",
+			requestPath:      "/noexistpath",
+			wantError:        `http response status is not 200 OK: 404 Not Found`,
+			wantPath:         "/noexistpath?key=test-api-key",
+			wantResponseCode: 404,
+		},
+	}
+	for _, tc := range testCases {
+		makeOneRequest(t, s, tc.requestPath, tc.wantError)
+	}
+
+	// Envoy flushes the access log entries to the service every second.
+	entries, err := s.FakeAccessLogServer.WaitForEntries(len(testCases), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.FakeAccessLogServer.HasLogName("espv2-test") {
+		t.Errorf("access log entries are not streamed with log name espv2-test")
+	}
+
+	for _, tc := range testCases {
+		var entry *alpb.HTTPAccessLogEntry
+		for _, e := range entries {
+			if e.GetRequest().GetPath() == tc.wantPath {
+				entry = e
+			}
+		}
+		if entry == nil {
+			t.Errorf("Test (%s): no access log entry of path %v, got: %v", tc.desc, tc.wantPath, entries)
+			continue
+		}
+		if got := entry.GetRequest().GetRequestMethod(); got != corepb.RequestMethod_GET {
+			t.Errorf("Test (%s): got request method %v, want GET", tc.desc, got)
+		}
+		if got := entry.GetResponse().GetResponseCode().GetValue(); got != tc.wantResponseCode {
+			t.Errorf("Test (%s): got response code %v, want %v", tc.desc, got, tc.wantResponseCode)
+		}
+		if got := entry.GetCommonProperties().GetUpstreamCluster(); got != tc.wantUpstreamCluster {
+			t.Errorf("Test (%s): got upstream cluster %q, want %q", tc.desc, got, tc.wantUpstreamCluster)
+		}
+	}
+}
+
+func containsFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package additional_services_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+const additionalServiceName = "additional-echo-api.endpoints.cloudesf-testing.cloud.goog"
+
+func TestAdditionalServices(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--service_config_id=test-config-id", "--rollout_strategy=fixed"}
+	s := env.NewTestEnv(platform.TestAdditionalServices, platform.EchoSidecar)
+
+	additionalServiceConfig := testdata.SetupServiceConfig(platform.EchoSidecar)
+	additionalServiceConfig.Name = additionalServiceName
+	additionalServiceConfig.Id = "additional-config-id"
+	s.AddAdditionalService(additionalServiceConfig, s.Ports().AdditionalListenerPort)
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc            string
+		port            uint16
+		wantServiceName string
+	}{
+		{
+			desc:            "Success, the main service is served and reported on its listener",
+			port:            s.Ports().ListenerPort,
+			wantServiceName: "echo-api.endpoints.cloudesf-testing.cloud.goog",
+		},
+		{
+			desc:            "Success, the additional service is served and reported on its own listener",
+			port:            s.Ports().AdditionalListenerPort,
+			wantServiceName: additionalServiceName,
+		},
+	}
+
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), tc.port)
+		resp, err := client.DoPost(url, "hello")
+		if err != nil {
+			t.Fatalf("Test (%s): failed, got unexpected error: %v", tc.desc, err)
+		}
+		if wantResp := `{"message":"hello"}`; string(resp) != wantResp {
+			t.Errorf("Test (%s): failed, got response %s, want %s", tc.desc, resp, wantResp)
+		}
+
+		// One check and one report.
+		scRequests, err := s.ServiceControlServer.GetRequests(2)
+		if err != nil {
+			t.Fatalf("Test (%s): GetRequests returns error: %v", tc.desc, err)
+		}
+		for _, scRequest := range scRequests {
+			var gotServiceName string
+			switch scRequest.ReqType {
+			case utils.CheckRequest:
+				check, err := utils.UnmarshalCheckRequest(scRequest.ReqBody)
+				if err != nil {
+					t.Fatalf("Test (%s): fail to unmarshal check request: %v", tc.desc, err)
+				}
+				gotServiceName = check.GetServiceName()
+			case utils.ReportRequest:
+				report, err := utils.UnmarshalReportRequest(scRequest.ReqBody)
+				if err != nil {
+					t.Fatalf("Test (%s): fail to unmarshal report request: %v", tc.desc, err)
+				}
+				gotServiceName = report.GetServiceName()
+			default:
+				t.Fatalf("Test (%s): got unexpected service control request type: %v", tc.desc, scRequest.ReqType)
+			}
+			if gotServiceName != tc.wantServiceName {
+				t.Errorf("Test (%s): got service control request for service %v, want %v", tc.desc, gotServiceName, tc.wantServiceName)
+			}
+		}
+	}
+}
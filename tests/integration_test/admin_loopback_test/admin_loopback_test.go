@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin_loopback_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+)
+
+// externalAddress returns a non-loopback IPv4 address of this host.
+func externalAddress() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no non-loopback IPv4 address found")
+}
+
+func TestAdminLoopback(t *testing.T) {
+	t.Parallel()
+
+	host, err := externalAddress()
+	if err != nil {
+		t.Skipf("skipping test: %v", err)
+	}
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed"}
+
+	s := env.NewTestEnv(platform.TestAdminLoopback, platform.EchoSidecar)
+	s.SetAdminAddress(platform.GetLoopbackAddress())
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// Health checks, including the admin stats check, still run over loopback.
+	if err := s.StatsVerifier.CheckHealth(); err != nil {
+		t.Errorf("admin health check failed over loopback: %v", err)
+	}
+
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	adminPort := s.Ports().AdminPort
+
+	resp, err := httpClient.Get(fmt.Sprintf("http://%v:%v/ready", platform.GetLoopbackHost(), adminPort))
+	if err != nil {
+		t.Fatalf("admin request over loopback failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("admin request over loopback got status %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	resp, err = httpClient.Get(fmt.Sprintf("http://%v:%v/ready", host, adminPort))
+	if err == nil {
+		resp.Body.Close()
+		t.Errorf("admin request over external address %v succeeded, want connection refused", host)
+	}
+}
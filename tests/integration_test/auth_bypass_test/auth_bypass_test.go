@@ -0,0 +1,118 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth_bypass_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestAuthBypass(t *testing.T) {
+	t.Parallel()
+
+	selector := "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo"
+	bypassPath := filepath.Join(t.TempDir(), "auth_bypass")
+
+	args := utils.CommonArgs()
+	args = append(args, "--auth_bypass_file="+bypassPath, "--auth_bypass_reload_interval=500ms")
+
+	s := env.NewTestEnv(platform.TestAuthBypass, platform.EchoSidecar)
+	s.OverrideAuthentication(&confpb.Authentication{
+		Providers: []*confpb.AuthProvider{
+			{
+				Id:      "test_provider",
+				Issuer:  "test-issuer",
+				JwksUri: "http://127.0.0.1:1/jwks",
+			},
+		},
+		Rules: []*confpb.AuthenticationRule{
+			{
+				Selector: selector,
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: "test_provider",
+					},
+				},
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc       string
+		authBypass string
+		wantResp   string
+		wantError  string
+	}{
+		{
+			desc:      "Fail, the operation requires JWT",
+			wantError: `401 Unauthorized, {"code":401,"message":"Jwt is missing"}`,
+		},
+		{
+			desc:       "Fail, a file listing an unknown operation is rejected",
+			authBypass: selector + "\nunknown.Operation\n",
+			wantError:  `401 Unauthorized, {"code":401,"message":"Jwt is missing"}`,
+		},
+		{
+			desc:       "Success, the operation is made public at runtime, without a JWT or an API key",
+			authBypass: "# Incident 42\n" + selector + "\n",
+			wantResp:   `{"message":"hello"}`,
+		},
+		{
+			desc:       "Fail, the operation requires JWT again once the bypass is reverted",
+			authBypass: "",
+			wantError:  `401 Unauthorized, {"code":401,"message":"Jwt is missing"}`,
+		},
+	}
+
+	for i, tc := range testData {
+		if i > 0 {
+			if err := ioutil.WriteFile(bypassPath, []byte(tc.authBypass), 0644); err != nil {
+				t.Fatalf("Test (%s): fail to write the auth bypass file, %v", tc.desc, err)
+			}
+			time.Sleep(time.Second * 3)
+		}
+
+		url := fmt.Sprintf("http://%v:%v/echo", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+		resp, err := client.DoPost(url, "hello")
+
+		if tc.wantError != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+				t.Errorf("Test (%s): failed, got error %v, want error %v", tc.desc, err, tc.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): failed, got unexpected error: %v", tc.desc, err)
+		}
+		if !strings.Contains(string(resp), tc.wantResp) {
+			t.Errorf("Test (%s): failed, got response %s, want %s", tc.desc, resp, tc.wantResp)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_alpn_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestBackendAlpn(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc                string
+		flags               []string
+		disableBackendHttp2 bool
+		wantProtocol        string
+	}{
+		{
+			desc:         "HTTP/1.1 without ALPN by default",
+			wantProtocol: "HTTP/1.1",
+		},
+		{
+			desc:         "h2 ALPN negotiates HTTP/2",
+			flags:        []string{"--backend_alpn_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=h2"},
+			wantProtocol: "HTTP/2.0",
+		},
+		{
+			desc:         "http/1.1 ALPN negotiates HTTP/1.1",
+			flags:        []string{"--backend_alpn_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=http/1.1"},
+			wantProtocol: "HTTP/1.1",
+		},
+		{
+			desc:         "auto ALPN negotiates HTTP/2 with an h2-capable backend",
+			flags:        []string{"--backend_alpn_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=auto"},
+			wantProtocol: "HTTP/2.0",
+		},
+		{
+			desc:                "auto ALPN negotiates HTTP/1.1 with an h1-only backend",
+			flags:               []string{"--backend_alpn_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=auto"},
+			disableBackendHttp2: true,
+			wantProtocol:        "HTTP/1.1",
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestBackendAlpn, platform.EchoRemote)
+			if tc.disableBackendHttp2 {
+				s.DisableHttp2ForHttpsBackend()
+			}
+			defer s.TearDown(t)
+			if err := s.Setup(append(utils.CommonArgs(), tc.flags...)); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			// The echo backend responds with the protocol negotiated with ESPv2.
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			header, _, err := utils.DoWithHeaders(url, "POST", "hello", nil)
+			if err != nil {
+				t.Fatalf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+			}
+			if got := header.Get("Echo-Request-Protocol"); got != tc.wantProtocol {
+				t.Errorf("Test (%s): failed, got backend protocol %q, want %q", tc.desc, got, tc.wantProtocol)
+			}
+		}()
+	}
+}
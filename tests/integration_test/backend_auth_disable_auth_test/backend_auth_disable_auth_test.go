@@ -23,6 +23,8 @@ import (
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
 )
 
 func TestBackendAuthDisableAuth(t *testing.T) {
@@ -109,3 +111,72 @@ func TestBackendAuthDisableAuth(t *testing.T) {
 		}
 	}
 }
+
+func TestBackendAuthPerRouteOptOut(t *testing.T) {
+	t.Parallel()
+
+	selector := "1.echo_api_endpoints_cloudesf_testing_cloud_goog.EchoHeader"
+	testData := []struct {
+		desc              string
+		backendRule       *confpb.BackendRule
+		wantAuthorization string
+	}{
+		{
+			desc:              "No identity token is sent when the backend rule has no address",
+			wantAuthorization: "",
+		},
+		{
+			desc: "The identity token is sent when the backend rule has a JwtAudience",
+			backendRule: &confpb.BackendRule{
+				Selector: selector,
+				Authentication: &confpb.BackendRule_JwtAudience{
+					JwtAudience: "https://authed.example.com",
+				},
+			},
+			wantAuthorization: "Bearer ya29.JwtAudienceSet",
+		},
+		{
+			desc: "No identity token is sent when the backend rule opts out with DisableAuth",
+			backendRule: &confpb.BackendRule{
+				Selector: selector,
+				Authentication: &confpb.BackendRule_DisableAuth{
+					DisableAuth: true,
+				},
+			},
+			wantAuthorization: "",
+		},
+	}
+
+	for _, tc := range testData {
+		// The test envs share the same ports, so they are run one after the other.
+		func() {
+			s := env.NewTestEnv(platform.TestBackendAuthPerRouteOptOut, platform.EchoSidecar)
+			s.OverrideMockMetadata(
+				map[string]string{
+					fmt.Sprintf("%v?format=standard&audience=https://authed.example.com", util.IdentityTokenPath): "ya29.JwtAudienceSet",
+				}, 0)
+			if tc.backendRule != nil {
+				// The backend rule points to the sidecar backend itself.
+				tc.backendRule.Address = fmt.Sprintf("http://%v:%v", platform.GetLoopbackAddress(), s.Ports().BackendServerPort)
+				tc.backendRule.PathTranslation = confpb.BackendRule_APPEND_PATH_TO_ADDRESS
+				s.AppendBackendRules([]*confpb.BackendRule{tc.backendRule})
+			}
+
+			defer s.TearDown(t)
+			if err := s.Setup(utils.CommonArgs()); err != nil {
+				t.Fatalf("Test Desc(%s): fail to setup test env, %v", tc.desc, err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echoHeader?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			respHeader, _, err := utils.DoWithHeaders(url, "GET", "", nil)
+			if err != nil {
+				t.Fatalf("Test Desc(%s): %v", tc.desc, err)
+			}
+
+			// The backend echoes the request headers it received.
+			if got := respHeader.Get("Echo-Authorization"); got != tc.wantAuthorization {
+				t.Errorf("Test Desc(%s): backend got Authorization %q, want %q", tc.desc, got, tc.wantAuthorization)
+			}
+		}()
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_auth_static_token_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+const (
+	staticTokenSelector = "1.echo_api_endpoints_cloudesf_testing_cloud_goog.dynamic_routing_BearertokenConstantAddress"
+)
+
+func TestBackendAuthStaticToken(t *testing.T) {
+	t.Parallel()
+
+	tokenDir, err := ioutil.TempDir("", "backend_auth_static_token")
+	if err != nil {
+		t.Fatalf("fail to create token dir: %v", err)
+	}
+	defer os.RemoveAll(tokenDir)
+	tokenFile := filepath.Join(tokenDir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("static-token-1\n"), 0644); err != nil {
+		t.Fatalf("fail to write token file: %v", err)
+	}
+
+	s := env.NewTestEnv(platform.TestBackendAuthStaticToken, platform.EchoRemote)
+	s.OverrideMockMetadata(
+		map[string]string{
+			fmt.Sprintf("%v?format=standard&audience=https://%v", util.IdentityTokenPath, platform.GetLoopbackAddress()): "ya29.DefaultAuth",
+		}, 0)
+
+	defer s.TearDown(t)
+	args := utils.CommonArgs()
+	args = append(args, fmt.Sprintf("--backend_auth_static_token_files=%v=%v", staticTokenSelector, tokenFile))
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	staticTokenUrl := fmt.Sprintf("http://%v:%v/bearertoken/constant/0", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	jwtTokenUrl := fmt.Sprintf("http://%v:%v/authenticationnotset/constant/0", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+
+	checkResp := func(desc, url string, headers map[string]string, wantResp string) {
+		resp, err := client.DoWithHeaders(url, "GET", "", headers)
+		if err != nil {
+			t.Fatalf("Test Desc(%s): %v", desc, err)
+		}
+		if err := util.JsonEqual(wantResp, string(resp)); err != nil {
+			t.Errorf("Test Desc(%s) failed, \n %v", desc, err)
+		}
+	}
+
+	checkResp("Static token is sent to the backend", staticTokenUrl, nil,
+		`{"Authorization": "Bearer static-token-1", "RequestURI": "/bearertoken/constant?foo=0"}`)
+	checkResp("Original Authorization header is copied to X-Forwarded-Authorization", staticTokenUrl,
+		map[string]string{"Authorization": "Bearer origin-token"},
+		`{"Authorization": "Bearer static-token-1", "RequestURI": "/bearertoken/constant?foo=0", "X-Forwarded-Authorization": "Bearer origin-token"}`)
+	checkResp("Other routes still use the backend JWT", jwtTokenUrl, nil,
+		`{"Authorization": "Bearer ya29.DefaultAuth", "RequestURI": "/bearertoken/constant?foo=0"}`)
+
+	// Rotate the token by atomically replacing the file.
+	tmpFile := filepath.Join(tokenDir, "token.tmp")
+	if err := ioutil.WriteFile(tmpFile, []byte("static-token-2\n"), 0644); err != nil {
+		t.Fatalf("fail to write token file: %v", err)
+	}
+	if err := os.Rename(tmpFile, tokenFile); err != nil {
+		t.Fatalf("fail to replace token file: %v", err)
+	}
+
+	wantResp := `{"Authorization": "Bearer static-token-2", "RequestURI": "/bearertoken/constant?foo=0"}`
+	var lastErr error
+	for i := 0; i < 20; i++ {
+		resp, err := client.DoWithHeaders(staticTokenUrl, "GET", "", nil)
+		if err != nil {
+			t.Fatalf("Test Desc(Rotated token is picked up): %v", err)
+		}
+		if lastErr = util.JsonEqual(wantResp, string(resp)); lastErr == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if lastErr != nil {
+		t.Errorf("Test Desc(Rotated token is picked up) failed, \n %v", lastErr)
+	}
+}
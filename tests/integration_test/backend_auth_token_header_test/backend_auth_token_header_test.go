@@ -0,0 +1,77 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_auth_token_header_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+const (
+	tokenHeaderSelector = "1.echo_api_endpoints_cloudesf_testing_cloud_goog.dynamic_routing_BearertokenConstantAddress"
+)
+
+func TestBackendAuthTokenHeader(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestBackendAuthTokenHeader, platform.EchoRemote)
+	defer s.TearDown(t)
+	args := utils.CommonArgs()
+	args = append(args, fmt.Sprintf("--backend_auth_token_headers=%v=X-Serverless-Authorization", tokenHeaderSelector))
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc     string
+		url      string
+		headers  map[string]string
+		wantResp string
+	}{
+		{
+			desc:     "Token is sent in the configured header, not in Authorization",
+			url:      "/bearertoken/constant/42",
+			wantResp: `{"Authorization": "", "RequestURI": "/bearertoken/constant?foo=42", "X-Serverless-Authorization": "Bearer ya29.new"}`,
+		},
+		{
+			desc:     "Original Authorization header is kept",
+			url:      "/bearertoken/constant/42",
+			headers:  map[string]string{"Authorization": "Bearer origin-token"},
+			wantResp: `{"Authorization": "Bearer origin-token", "RequestURI": "/bearertoken/constant?foo=42", "X-Serverless-Authorization": "Bearer ya29.new"}`,
+		},
+		{
+			desc:     "Other routes still use the Authorization header",
+			url:      "/authenticationnotset/constant/42",
+			wantResp: `{"Authorization": "Bearer ya29.new", "RequestURI": "/bearertoken/constant?foo=42"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.url)
+		resp, err := client.DoWithHeaders(url, "GET", "", tc.headers)
+		if err != nil {
+			t.Fatalf("Test Desc(%s): %v", tc.desc, err)
+		}
+		if err := util.JsonEqual(tc.wantResp, string(resp)); err != nil {
+			t.Errorf("Test Desc(%s) failed, \n %v", tc.desc, err)
+		}
+	}
+}
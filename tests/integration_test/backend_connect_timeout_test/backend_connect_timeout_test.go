@@ -0,0 +1,79 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_connect_timeout_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestBackendConnectTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The remote backend takes this long to complete the TLS handshake, which
+	// counts towards the connect timeout.
+	handshakeDelay := 2 * time.Second
+
+	testData := []struct {
+		desc      string
+		flags     []string
+		wantError string
+	}{
+		{
+			desc:      "Connect timeout shorter than the handshake delay fails with 503",
+			flags:     []string{"--backend_connect_timeout=1s"},
+			wantError: "503 Service Unavailable",
+		},
+		{
+			desc:  "Connect timeout longer than the handshake delay succeeds",
+			flags: []string{"--backend_connect_timeout=5s"},
+		},
+		{
+			desc: "Per-operation connect timeout overrides the short backend connect timeout",
+			flags: []string{"--backend_connect_timeout=1s",
+				"--backend_connect_timeout_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=5s"},
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestBackendConnectTimeout, platform.EchoRemote)
+			s.SetBackendTLSHandshakeDelay(handshakeDelay)
+			defer s.TearDown(t)
+			if err := s.Setup(append(utils.CommonArgs(), tc.flags...)); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			_, err := client.DoPost(url, "hello")
+			if tc.wantError == "" {
+				if err != nil {
+					t.Errorf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+				t.Errorf("Test (%s): failed, want error: %v, got error: %v", tc.desc, tc.wantError, err)
+			}
+		}()
+	}
+}
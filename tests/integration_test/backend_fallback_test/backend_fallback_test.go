@@ -0,0 +1,71 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_fallback_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+)
+
+func TestBackendFallback(t *testing.T) {
+	t.Parallel()
+
+	// The fallback backend serves the requests while the primary backend is
+	// down.
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"fallback backend response"}`))
+	}))
+	defer fallback.Close()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--backend_fallback_address=" + fallback.URL}
+
+	s := env.NewTestEnv(platform.TestBackendFallback, platform.EchoSidecar)
+	s.SetBackendNotStart(true)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	resp, err := http.Post(url, "application/json", strings.NewReader(`"hello"`))
+	if err != nil {
+		t.Fatalf("fail to call echo, %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("fail to read the response, %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status code %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if want := `{"message":"fallback backend response"}`; string(body) != want {
+		t.Errorf("got response %s, want %s", body, want)
+	}
+	if got := resp.Header.Get("X-Endpoint-Backend-Fallback"); got != "true" {
+		t.Errorf("got X-Endpoint-Backend-Fallback header %q, want %q", got, "true")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_health_check_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+)
+
+func TestBackendHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	// The primary backend is only healthy for health checks to /healthz with
+	// the Host header health.example.com.
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			if r.Host != "health.example.com" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"primary backend response"}`))
+	}))
+	defer primary.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"fallback backend response"}`))
+	}))
+	defer fallback.Close()
+
+	testData := []struct {
+		desc            string
+		healthCheckArgs []string
+		wantResp        string
+	}{
+		{
+			desc:            "Primary backend is healthy with the configured path and Host header",
+			healthCheckArgs: []string{"--backend_health_check_path=/healthz", "--backend_health_check_host=health.example.com"},
+			wantResp:        `{"message":"primary backend response"}`,
+		},
+		{
+			desc:            "Primary backend is unhealthy with the default Host header",
+			healthCheckArgs: []string{"--backend_health_check_path=/healthz"},
+			wantResp:        `{"message":"fallback backend response"}`,
+		},
+		{
+			desc:            "Primary backend is unhealthy with an unexpected status",
+			healthCheckArgs: []string{"--backend_health_check_path=/healthz", "--backend_health_check_expected_statuses=204"},
+			wantResp:        `{"message":"fallback backend response"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			args := append([]string{"--service_config_id=test-config-id",
+				"--rollout_strategy=fixed", "--backend_fallback_address=" + fallback.URL}, tc.healthCheckArgs...)
+
+			s := env.NewTestEnv(platform.TestBackendHealthCheck, platform.EchoSidecar)
+			s.SetBackendNotStart(true)
+			s.SetBackendAddress(primary.URL)
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			resp, err := http.Post(url, "application/json", strings.NewReader(`"hello"`))
+			if err != nil {
+				t.Fatalf("Test (%s): fail to call echo, %v", tc.desc, err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Test (%s): fail to read the response, %v", tc.desc, err)
+			}
+			if string(body) != tc.wantResp {
+				t.Errorf("Test (%s): got response %s, want %s", tc.desc, body, tc.wantResp)
+			}
+		}()
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_host_rewrite_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestBackendHostRewrite(t *testing.T) {
+	t.Parallel()
+
+	// The remote backend responds with the Host header and path it receives.
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf(`{"host":"%s","path":"%s"}`, r.Host, r.URL.Path)))
+	}))
+	defer backend.Close()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--backend_host_rewrite_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=api.example.com"}
+
+	s := env.NewTestEnv(platform.TestBackendHostRewrite, platform.EchoSidecar)
+	s.AppendBackendRules([]*confpb.BackendRule{
+		{
+			Selector:        "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			Address:         backend.URL + "/api",
+			PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+			Authentication: &confpb.BackendRule_DisableAuth{
+				DisableAuth: true,
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	resp, err := client.DoPost(url, "hello")
+	if err != nil {
+		t.Fatalf("fail to call echo, %v", err)
+	}
+
+	if want := `{"host":"api.example.com","path":"/api/echo"}`; string(resp) != want {
+		t.Errorf("got response %s, want %s", resp, want)
+	}
+}
+
+func TestBackendHostRewriteForServiceControl(t *testing.T) {
+	t.Parallel()
+
+	// The remote backend responds with the Host header it receives.
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf(`{"host":"%s"}`, r.Host)))
+	}))
+	defer backend.Close()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--backend_host_rewrite=api.example.com", "--log_request_headers=:authority"}
+
+	s := env.NewTestEnv(platform.TestBackendHostRewriteForServiceControl, platform.EchoSidecar)
+	s.AppendBackendRules([]*confpb.BackendRule{
+		{
+			Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			Address:  backend.URL,
+			Authentication: &confpb.BackendRule_DisableAuth{
+				DisableAuth: true,
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	host := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	resp, err := client.DoPost(fmt.Sprintf("http://%v/echo?key=api-key", host), "hello")
+	if err != nil {
+		t.Fatalf("fail to call echo, %v", err)
+	}
+	if want := `{"host":"api.example.com"}`; string(resp) != want {
+		t.Errorf("got response %s, want %s", resp, want)
+	}
+
+	// Service control still sees the Host sent by the client.
+	wantScRequests := []interface{}{
+		&utils.ExpectedCheck{
+			Version:         utils.ESPv2Version(),
+			ServiceName:     "echo-api.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID: configId,
+			ConsumerID:      "api_key:api-key",
+			OperationName:   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			CallerIp:        platform.GetLoopbackAddress(),
+		},
+		&utils.ExpectedReport{
+			Version:                      utils.ESPv2Version(),
+			ServiceName:                  "echo-api.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID:              configId,
+			URL:                          "/echo?key=api-key",
+			ApiKeyInOperationAndLogEntry: "api-key",
+			ApiKeyState:                  "VERIFIED",
+			ApiMethod:                    "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			ApiVersion:                   "1.0.0",
+			ApiName:                      "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+			ProducerProjectID:            "producer-project",
+			ConsumerProjectID:            "123456",
+			FrontendProtocol:             "http",
+			HttpMethod:                   "POST",
+			LogMessage:                   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo is called",
+			StatusCode:                   "0",
+			ResponseCode:                 200,
+			Platform:                     util.GCE,
+			Location:                     "test-zone",
+			RequestHeaders:               ":authority=" + host + ";",
+		},
+	}
+	scRequests, err := s.ServiceControlServer.GetRequests(len(wantScRequests))
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	utils.CheckScRequest(t, scRequests, wantScRequests, "TestBackendHostRewriteForServiceControl")
+}
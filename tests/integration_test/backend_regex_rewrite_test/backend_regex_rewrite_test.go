@@ -0,0 +1,103 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_regex_rewrite_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+
+	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestBackendRegexRewrite(t *testing.T) {
+	t.Parallel()
+
+	// The remote backend responds with the path and query it receives.
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf(`{"path":"%s","query":"%s"}`, r.URL.Path, r.URL.RawQuery)))
+	}))
+	defer backend.Close()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", `--backend_regex_rewrite_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=^/v1/users/([^/]+)/profile$=/profile?user=\1`}
+
+	s := env.NewTestEnv(platform.TestBackendRegexRewrite, platform.EchoSidecar)
+	s.AppendHttpRules([]*annotationspb.HttpRule{
+		{
+			Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			Pattern: &annotationspb.HttpRule_Post{
+				Post: "/v1/users/{id}/profile",
+			},
+		},
+	})
+	s.AppendBackendRules([]*confpb.BackendRule{
+		{
+			Selector:        "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			Address:         backend.URL,
+			PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+			Authentication: &confpb.BackendRule_DisableAuth{
+				DisableAuth: true,
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc     string
+		path     string
+		headers  map[string]string
+		wantResp string
+	}{
+		{
+			desc: "The path segment is moved to the query",
+			path: "/v1/users/123/profile",
+			headers: map[string]string{
+				"x-api-key": "api-key",
+			},
+			wantResp: `{"path":"/profile","query":"user=123"}`,
+		},
+		{
+			desc:     "The query string with the API key is joined to the query of the substitution",
+			path:     "/v1/users/123/profile?key=api-key&view=full",
+			wantResp: `{"path":"/profile","query":"user=123&key=api-key&view=full"}`,
+		},
+		{
+			desc:     "Paths not matching the pattern are not modified",
+			path:     "/echo?key=api-key",
+			wantResp: `{"path":"/echo","query":"key=api-key"}`,
+		},
+	}
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.path)
+		resp, err := client.DoPostWithHeaders(url, "hello", tc.headers)
+		if err != nil {
+			t.Fatalf("Test (%s): fail to call backend, %v", tc.desc, err)
+		}
+		if string(resp) != tc.wantResp {
+			t.Errorf("Test (%s): got response %s, want %s", tc.desc, resp, tc.wantResp)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_sni_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestBackendSni(t *testing.T) {
+	t.Parallel()
+
+	// The remote backend rejects TLS handshakes without this SNI.
+	requiredSni := "backend.example.com"
+
+	testData := []struct {
+		desc      string
+		flags     []string
+		wantError string
+	}{
+		{
+			desc:      "Backend hostname as SNI fails the TLS handshake with 503",
+			wantError: "503 Service Unavailable",
+		},
+		{
+			desc:  "Per-operation SNI passes the TLS handshake",
+			flags: []string{"--backend_sni_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=" + requiredSni},
+		},
+		{
+			desc:      "Per-operation SNI different from the required one fails the TLS handshake with 503",
+			flags:     []string{"--backend_sni_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=other.example.com"},
+			wantError: "503 Service Unavailable",
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestBackendSni, platform.EchoRemote)
+			s.SetBackendRequiredSni(requiredSni)
+			defer s.TearDown(t)
+			if err := s.Setup(append(utils.CommonArgs(), tc.flags...)); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			_, err := client.DoPost(url, "hello")
+			if tc.wantError == "" {
+				if err != nil {
+					t.Errorf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+				t.Errorf("Test (%s): failed, want error: %v, got error: %v", tc.desc, tc.wantError, err)
+			}
+		}()
+	}
+}
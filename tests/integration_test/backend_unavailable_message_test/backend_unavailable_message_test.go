@@ -0,0 +1,59 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend_unavailable_message_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+)
+
+func TestBackendUnavailableMessage(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--service_config_id=test-config-id",
+		"--rollout_strategy=fixed", "--backend_unavailable_message=Bookstore is temporarily unavailable"}
+
+	// The backend is not started, so connections to it fail.
+	s := env.NewTestEnv(platform.TestBackendUnavailableMessage, platform.EchoSidecar)
+	s.SetBackendNotStart(true)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	resp, err := http.Post(url, "application/json", strings.NewReader(`"hello"`))
+	if err != nil {
+		t.Fatalf("fail to call echo, %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("fail to read the response, %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status code %v, want %v", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if want := `{"code":503,"message":"Bookstore is temporarily unavailable"}`; string(body) != want {
+		t.Errorf("got response %s, want %s", body, want)
+	}
+}
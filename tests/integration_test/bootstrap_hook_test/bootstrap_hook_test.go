@@ -0,0 +1,102 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap_hook_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+	"github.com/golang/protobuf/ptypes"
+
+	bootstrappb "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	statspb "github.com/envoyproxy/go-control-plane/envoy/config/metrics/v3"
+)
+
+func TestBootstrapHook(t *testing.T) {
+	t.Parallel()
+
+	// The statsd server the custom stats sink sends the stats to.
+	statsdConn, err := net.ListenPacket("udp", fmt.Sprintf("%v:0", platform.GetLoopbackAddress()))
+	if err != nil {
+		t.Fatalf("fail to start statsd server, %v", err)
+	}
+	defer statsdConn.Close()
+	statsdPort := statsdConn.LocalAddr().(*net.UDPAddr).Port
+
+	s := env.NewTestEnv(platform.TestBootstrapHook, platform.EchoSidecar)
+	s.SetBootstrapHook(func(bootstrap *bootstrappb.Bootstrap) error {
+		statsdSink, err := ptypes.MarshalAny(&statspb.StatsdSink{
+			StatsdSpecifier: &statspb.StatsdSink_Address{
+				Address: &corepb.Address{
+					Address: &corepb.Address_SocketAddress{
+						SocketAddress: &corepb.SocketAddress{
+							Protocol: corepb.SocketAddress_UDP,
+							Address:  platform.GetLoopbackAddress(),
+							PortSpecifier: &corepb.SocketAddress_PortValue{
+								PortValue: uint32(statsdPort),
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		bootstrap.StatsSinks = append(bootstrap.StatsSinks, &statspb.StatsSink{
+			Name: "envoy.stat_sinks.statsd",
+			ConfigType: &statspb.StatsSink_TypedConfig{
+				TypedConfig: statsdSink,
+			},
+		})
+		bootstrap.StatsFlushInterval = ptypes.DurationProto(time.Second)
+		return nil
+	})
+
+	defer s.TearDown(t)
+	if err := s.Setup(utils.CommonArgs()); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	if _, err := client.DoWithHeaders(url, "POST", "hello", nil); err != nil {
+		t.Fatalf("fail to make request, %v", err)
+	}
+
+	// The request is counted in the stats flushed to the statsd server.
+	wantStat := "http.ingress_http.downstream_rq_total"
+	buf := make([]byte, 65536)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if err := statsdConn.SetReadDeadline(deadline); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := statsdConn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("stat %q is not received by the statsd server, %v", wantStat, err)
+		}
+		if strings.Contains(string(buf[:n]), wantStat) {
+			return
+		}
+	}
+}
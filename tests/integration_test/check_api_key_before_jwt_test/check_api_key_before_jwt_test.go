@@ -0,0 +1,108 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check_api_key_before_jwt_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+const (
+	jwtMissingError    = `401 Unauthorized, {"code":401,"message":"Jwt is missing"}`
+	apiKeyMissingError = `401 Unauthorized, {"code":401,"message":"UNAUTHENTICATED:Method doesn't allow unregistered callers (callers without established identity). Please use API Key or other form of API consumer identity to call this API."}`
+)
+
+// The operation of /auth/info/googlejwt requires both an API key and a JWT.
+func TestCheckApiKeyBeforeJwt(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc                 string
+		checkApiKeyBeforeJwt bool
+		wantMissingKeyError  string
+		wantMissingJwtError  string
+		wantMissingBothError string
+	}{
+		{
+			desc:                 "JWT is verified first by default",
+			wantMissingKeyError:  apiKeyMissingError,
+			wantMissingJwtError:  jwtMissingError,
+			wantMissingBothError: jwtMissingError,
+		},
+		{
+			desc:                 "API key is checked first",
+			checkApiKeyBeforeJwt: true,
+			wantMissingKeyError:  apiKeyMissingError,
+			wantMissingJwtError:  jwtMissingError,
+			wantMissingBothError: apiKeyMissingError,
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			args := utils.CommonArgs()
+			if tc.checkApiKeyBeforeJwt {
+				args = append(args, "--check_api_key_before_jwt")
+			}
+
+			s := env.NewTestEnv(platform.TestCheckApiKeyBeforeJwt, platform.EchoSidecar)
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			host := fmt.Sprintf("http://%v:%v/auth/info/googlejwt", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			jwtHeaders := map[string]string{
+				"Authorization": "Bearer " + testdata.FakeCloudTokenMultiAudiences,
+			}
+
+			requests := []struct {
+				desc      string
+				url       string
+				headers   map[string]string
+				wantError string
+			}{
+				{
+					desc:      "missing API key only",
+					url:       host,
+					headers:   jwtHeaders,
+					wantError: tc.wantMissingKeyError,
+				},
+				{
+					desc:      "missing JWT only",
+					url:       host + "?key=api-key",
+					wantError: tc.wantMissingJwtError,
+				},
+				{
+					desc:      "missing both",
+					url:       host,
+					wantError: tc.wantMissingBothError,
+				},
+			}
+			for _, req := range requests {
+				_, _, err := utils.DoWithHeaders(req.url, "GET", "", req.headers)
+				if err == nil || !strings.Contains(err.Error(), req.wantError) {
+					t.Errorf("Test (%s), %s: expected error (%v), got (%v)", tc.desc, req.desc, req.wantError, err)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_swap_rollback_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+
+	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+// makeAuthentication requires a JWT for the Echo operation, read from the
+// given header.
+func makeAuthentication(jwtHeader string) *confpb.Authentication {
+	return &confpb.Authentication{
+		Providers: []*confpb.AuthProvider{
+			{
+				Id:      "test_provider",
+				Issuer:  "test-issuer",
+				JwksUri: "http://127.0.0.1:1/jwks",
+				JwtLocations: []*confpb.JwtLocation{
+					{
+						In: &confpb.JwtLocation_Header{
+							Header: jwtHeader,
+						},
+					},
+				},
+			},
+		},
+		Rules: []*confpb.AuthenticationRule{
+			{
+				Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: "test_provider",
+					},
+				},
+			},
+		},
+	}
+}
+
+// getListenerVersions returns the xDS versions of the listeners Envoy serves,
+// from the admin config dump.
+func getListenerVersions(adminPort uint16) ([]string, error) {
+	url := fmt.Sprintf("http://%v:%v/config_dump?resource=dynamic_listeners", platform.GetLoopbackAddress(), adminPort)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get the envoy config dump, %v", err)
+	}
+	defer resp.Body.Close()
+
+	var configDump struct {
+		Configs []struct {
+			ActiveState struct {
+				VersionInfo string `json:"version_info"`
+			} `json:"active_state"`
+		} `json:"configs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&configDump); err != nil {
+		return nil, fmt.Errorf("fail to decode the envoy config dump, %v", err)
+	}
+	var versions []string
+	for _, config := range configDump.Configs {
+		versions = append(versions, config.ActiveState.VersionInfo)
+	}
+	return versions, nil
+}
+
+func TestConfigSwapRollback(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--rollout_strategy=managed", "--check_rollout_interval=500ms", "--config_swap_grace_period=30s"}
+	s := env.NewTestEnv(platform.TestConfigSwapRollback, platform.EchoSidecar)
+	s.SetEnvoyDrainTimeInSec(1)
+	// Envoy rejects the route of this rule once it is added, as its regex is
+	// larger than the RE2 program size allowed by Envoy.
+	longPathRule := &annotationspb.HttpRule{
+		Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+		Pattern: &annotationspb.HttpRule_Post{
+			Post: "/echo_rollback/{id}",
+		},
+	}
+	s.AppendHttpRules([]*annotationspb.HttpRule{longPathRule})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+	initialConfigId := s.ServiceConfigId()
+
+	testData := []struct {
+		desc           string
+		authentication *confpb.Authentication
+		longPath       bool
+		configId       string
+		wantVersion    string
+		wantResp       string
+		wantError      string
+	}{
+		{
+			desc:        "Success, the initial service config doesn't require JWT",
+			wantVersion: initialConfigId,
+			wantResp:    `{"message":"hello"}`,
+		},
+		{
+			desc:           "Fail, the valid new service config requires JWT",
+			authentication: makeAuthentication("X-Jwt"),
+			configId:       "jwt-service-config-id",
+			wantVersion:    "jwt-service-config-id",
+			wantError:      `401 Unauthorized, {"code":401,"message":"Jwt is missing"}`,
+		},
+		{
+			// The config manager generates the config, but Envoy rejects it,
+			// so the previous service config, which requires JWT, is served
+			// again.
+			desc:        "Fail, the new service config rejected by Envoy is rolled back",
+			longPath:    true,
+			configId:    "rejected-service-config-id",
+			wantVersion: "jwt-service-config-id",
+			wantError:   `401 Unauthorized, {"code":401,"message":"Jwt is missing"}`,
+		},
+		{
+			desc:        "Success, a service config after the rejected one is applied",
+			configId:    "no-jwt-service-config-id",
+			wantVersion: "no-jwt-service-config-id",
+			wantResp:    `{"message":"hello"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		if tc.configId != "" {
+			s.OverrideAuthentication(tc.authentication)
+			longPathRule.Pattern = &annotationspb.HttpRule_Post{
+				Post: "/echo_rollback/{id}",
+			}
+			if tc.longPath {
+				longPathRule.Pattern = &annotationspb.HttpRule_Post{
+					Post: "/echo_rollback/{id}/" + strings.Repeat("a", 200),
+				}
+			}
+			s.OverrideRolloutIdAndConfigId(tc.configId, tc.configId)
+			time.Sleep(time.Second * 3)
+		}
+
+		versions, err := getListenerVersions(s.Ports().AdminPort)
+		if err != nil {
+			t.Fatalf("Test (%s): %v", tc.desc, err)
+		}
+		if len(versions) == 0 {
+			t.Errorf("Test (%s): failed, got no listeners", tc.desc)
+		}
+		for _, version := range versions {
+			if version != tc.wantVersion {
+				t.Errorf("Test (%s): failed, got listener version %v, want %v", tc.desc, version, tc.wantVersion)
+			}
+		}
+
+		url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+		resp, err := client.DoPost(url, "hello")
+
+		if tc.wantError != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+				t.Errorf("Test (%s): failed, got error %v, want error %v", tc.desc, err, tc.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): failed, got unexpected error: %v", tc.desc, err)
+		}
+		if !strings.Contains(string(resp), tc.wantResp) {
+			t.Errorf("Test (%s): failed, got response %s, want %s", tc.desc, resp, tc.wantResp)
+		}
+	}
+}
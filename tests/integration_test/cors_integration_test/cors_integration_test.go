@@ -108,6 +108,82 @@ func TestProxyHandleCorsSimpleRequestsBasic(t *testing.T) {
 	}
 }
 
+// Credentialed simple requests get the request origin echoed, not "*", paired
+// with Access-Control-Allow-Credentials, even when all origins are allowed.
+func TestProxyHandleCorsSimpleRequestsWithCredentials(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+	corsOrigin := "http://cloud.google.com"
+
+	testData := []struct {
+		desc                 string
+		allowOrigin          string
+		allowCredentials     bool
+		origin               string
+		wantAllowOrigin      string
+		wantAllowCredentials string
+	}{
+		{
+			desc:                 "Origin matches the allowed origin, the origin is echoed with credentials allowed.",
+			allowOrigin:          corsOrigin,
+			allowCredentials:     true,
+			origin:               corsOrigin,
+			wantAllowOrigin:      corsOrigin,
+			wantAllowCredentials: "true",
+		},
+		{
+			desc:                 "All origins are allowed, the origin is echoed instead of * with credentials allowed.",
+			allowOrigin:          "*",
+			allowCredentials:     true,
+			origin:               corsOrigin,
+			wantAllowOrigin:      corsOrigin,
+			wantAllowCredentials: "true",
+		},
+		{
+			desc:            "Credentials are not allowed, so the header is not in the response.",
+			allowOrigin:     corsOrigin,
+			origin:          corsOrigin,
+			wantAllowOrigin: corsOrigin,
+		},
+		{
+			desc:             "Origin does not match, so neither header is in the response.",
+			allowOrigin:      corsOrigin,
+			allowCredentials: true,
+			origin:           "https://some.unknown.origin.com",
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			args := []string{"--service_config_id=" + configId,
+				"--rollout_strategy=fixed", "--cors_preset=basic",
+				"--cors_allow_origin=" + tc.allowOrigin}
+			if tc.allowCredentials {
+				args = append(args, "--cors_allow_credentials")
+			}
+
+			s := env.NewTestEnv(platform.TestProxyHandleCorsSimpleRequestsWithCredentials, platform.EchoSidecar)
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echo")
+			respHeader, err := client.DoCorsSimpleRequest(url, "POST", tc.origin, echoMsg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := respHeader.Get("Access-Control-Allow-Origin"); got != tc.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin expected: %s, got: %s", tc.wantAllowOrigin, got)
+			}
+			if got := respHeader.Get("Access-Control-Allow-Credentials"); got != tc.wantAllowCredentials {
+				t.Errorf("Access-Control-Allow-Credentials expected: %s, got: %s", tc.wantAllowCredentials, got)
+			}
+		})
+	}
+}
+
 // ESPv2 handles CORS with the regex preset.
 // Tests only "simple requests". These do not trigger preflight OPTIONS in browsers.
 // https://developer.mozilla.org/en-US/docs/Web/HTTP/CORS#simple_requests
@@ -546,6 +622,112 @@ func TestPreflightRequestWithAllowCors(t *testing.T) {
 	}
 }
 
+func TestPreflightRequestWithAllowCorsDisabledOperations(t *testing.T) {
+	t.Parallel()
+
+	serviceName := "echo-api.endpoints.cloudesf-testing.cloud.goog"
+	configId := "test-config-id"
+	corsRequestMethod := "PATCH"
+	corsRequestHeader := "X-PINGOTHER"
+	corsOrigin := "http://cloud.google.com"
+
+	args := []string{"--service=" + serviceName, "--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--cors_disabled_operations=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Auth_info_firebase"}
+
+	s := env.NewTestEnv(platform.TestPreflightRequestWithAllowCorsDisabledOperations, platform.EchoSidecar)
+	s.SetAllowCors()
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc            string
+		url             string
+		wantCorsHeaders bool
+	}{
+		{
+			desc:            "Succeed, preflight to a route with CORS enabled is passed to backend",
+			url:             fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/simplegetcors"),
+			wantCorsHeaders: true,
+		},
+		{
+			desc: "Succeed, preflight to a route with CORS disabled has no CORS headers",
+			url:  fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/auth/info/firebase"),
+		},
+	}
+	for _, tc := range testData {
+		respHeader, err := client.DoCorsPreflightRequest(tc.url, corsOrigin, corsRequestMethod, corsRequestHeader, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, key := range []string{
+			"Access-Control-Allow-Origin",
+			"Access-Control-Allow-Methods",
+			"Access-Control-Allow-Headers",
+			"Access-Control-Allow-Credentials",
+		} {
+			if got := respHeader.Get(key) != ""; got != tc.wantCorsHeaders {
+				t.Errorf("Test (%s): %s got %q, want present: %v", tc.desc, key, respHeader.Get(key), tc.wantCorsHeaders)
+			}
+		}
+	}
+}
+
+func TestProxyHandlesCorsPreflightRequestsDisabledOperations(t *testing.T) {
+	t.Parallel()
+
+	serviceName := "test-echo"
+	configId := "test-config-id"
+	corsAllowOriginValue := "http://cloud.google.com"
+
+	args := []string{"--service=" + serviceName, "--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--cors_preset=basic",
+		"--cors_allow_origin=" + corsAllowOriginValue,
+		"--cors_disabled_operations=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Simplegetcors"}
+
+	s := env.NewTestEnv(platform.TestProxyHandlesCorsPreflightRequestsDisabledOperations, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc       string
+		path       string
+		wantError  string
+		wantOrigin string
+	}{
+		{
+			desc:       "Succeed, preflight to a route with CORS enabled is handled by Envoy",
+			path:       "/echo",
+			wantOrigin: corsAllowOriginValue,
+		},
+		{
+			desc:      "Fail, preflight to a route with CORS disabled is rejected without CORS headers",
+			path:      "/simplegetcors",
+			wantError: `405 Method Not Allowed, {"code":405,"message":"CORS is disabled for the url template`,
+		},
+	}
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.path)
+		respHeaders, _, err := utils.DoWithHeaders(url, "OPTIONS", "", map[string]string{
+			"Origin":                        corsAllowOriginValue,
+			"Access-Control-Request-Method": "GET",
+		})
+		if tc.wantError == "" && err != nil {
+			t.Errorf("Test (%s): got unexpected error: %v", tc.desc, err)
+		} else if tc.wantError != "" && (err == nil || !strings.Contains(err.Error(), tc.wantError)) {
+			t.Errorf("Test (%s): want error %s, got %v", tc.desc, tc.wantError, err)
+		}
+
+		if got := respHeaders.Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+			t.Errorf("Test (%s): got Access-Control-Allow-Origin %q, want %q", tc.desc, got, tc.wantOrigin)
+		}
+	}
+}
+
 func TestServiceControlRequestWithAllowCors(t *testing.T) {
 	t.Parallel()
 
@@ -852,3 +1034,58 @@ func TestStartupDuplicatedPathsWithAllowCors(t *testing.T) {
 		t.Fatalf("fail to setup test env, %v", err)
 	}
 }
+
+// Preflight requests carry no API key, so they must never be checked by
+// service control, whether ESPv2 or the backend answers them.
+func TestCorsPreflightWithoutApiKey(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc      string
+		allowCors bool
+		args      []string
+	}{
+		{
+			desc: "Preflight is answered by ESPv2 with --cors_preset",
+			args: []string{"--cors_preset=basic", "--cors_allow_origin=http://cloud.google.com"},
+		},
+		{
+			desc:      "Preflight is passed to the backend with allow_cors",
+			allowCors: true,
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			args := append([]string{"--service_config_id=test-config-id", "--rollout_strategy=fixed"}, tc.args...)
+			s := env.NewTestEnv(platform.TestCorsPreflightWithoutApiKey, platform.EchoSidecar)
+			if tc.allowCors {
+				s.SetAllowCors()
+			}
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			// The GET operation of the path requires an API key.
+			url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/simplegetcors")
+			reqHeaders := map[string]string{
+				"Origin":                        "http://cloud.google.com",
+				"Access-Control-Request-Method": "GET",
+			}
+			respHeaders, _, err := utils.DoWithHeaders(url, "OPTIONS", "", reqHeaders)
+			if err != nil {
+				t.Fatalf("Test (%s): preflight failed, %v", tc.desc, err)
+			}
+			if got := respHeaders.Get("Access-Control-Allow-Origin"); got == "" {
+				t.Errorf("Test (%s): preflight response has no Access-Control-Allow-Origin", tc.desc)
+			}
+
+			for _, scRequest := range s.ServiceControlServer.GetAllRequests() {
+				if scRequest.ReqType == utils.CheckRequest {
+					t.Errorf("Test (%s): preflight was checked by service control", tc.desc)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disable_chunked_encoding_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestDisableChunkedEncoding(t *testing.T) {
+	t.Parallel()
+
+	body := `{"message":"hello"}`
+
+	testData := []struct {
+		desc                 string
+		disableChunked       bool
+		wantContentLength    string
+		wantTransferEncoding string
+	}{
+		{
+			desc:                 "Chunked requests are forwarded chunked by default",
+			wantContentLength:    "-1",
+			wantTransferEncoding: "chunked",
+		},
+		{
+			desc:              "Chunked requests are buffered and forwarded with a Content-Length",
+			disableChunked:    true,
+			wantContentLength: strconv.Itoa(len(body)),
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestDisableChunkedEncoding, platform.EchoSidecar)
+			defer s.TearDown(t)
+			args := utils.CommonArgs()
+			if tc.disableChunked {
+				backendAddress := fmt.Sprintf("http://%v:%v", platform.GetLoopbackAddress(), s.Ports().BackendServerPort)
+				args = append(args, "--disable_chunked_encoding_backends="+backendAddress)
+			}
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			// The body has no known length, so the client sends it chunked.
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			req, err := http.NewRequest("POST", url, ioutil.NopCloser(strings.NewReader(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Test (%s): fail to send request: %v", tc.desc, err)
+			}
+			defer resp.Body.Close()
+
+			respBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Test (%s): got status %v, want 200 OK, body: %s", tc.desc, resp.StatusCode, respBody)
+			}
+			if got := resp.Header.Get("Echo-Request-Content-Length"); got != tc.wantContentLength {
+				t.Errorf("Test (%s): backend got Content-Length %q, want %q", tc.desc, got, tc.wantContentLength)
+			}
+			if got := resp.Header.Get("Echo-Request-Transfer-Encoding"); got != tc.wantTransferEncoding {
+				t.Errorf("Test (%s): backend got Transfer-Encoding %q, want %q", tc.desc, got, tc.wantTransferEncoding)
+			}
+		}()
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disable_transcoding_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+)
+
+// With --disable_transcoding, native gRPC requests are still routed to the
+// backend, but HTTP/JSON requests are forwarded as-is. The gRPC backend resets
+// the streams that are not gRPC, which Envoy replies to with 503.
+func TestDisableTranscoding(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID, "--rollout_strategy=fixed", "--disable_transcoding"}
+
+	s := env.NewTestEnv(platform.TestDisableTranscoding, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		clientProtocol string
+		httpMethod     string
+		method         string
+		wantResp       string
+		wantError      string
+	}{
+		{
+			desc:           "Succeed, native gRPC request is routed to the backend",
+			clientProtocol: "grpc",
+			method:         "GetShelf",
+			wantResp:       `{"id":"100","theme":"Kids"}`,
+		},
+		{
+			desc:           "Fail, HTTP/JSON request is forwarded as-is and reset by the gRPC backend",
+			clientProtocol: "http",
+			httpMethod:     "GET",
+			method:         "/v1/shelves/100",
+			wantError:      `503 Service Unavailable, {"code":503,"message":"upstream connect error or disconnect/reset before headers. reset reason: remote reset"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			header := http.Header{"x-api-key": []string{"api-key"}}
+			resp, err := client.MakeCall(tc.clientProtocol, addr, tc.httpMethod, tc.method, "", header)
+
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("Test (%s): failed\nexpected err: %v\ngot: %v", tc.desc, tc.wantError, err)
+				}
+			} else if err != nil {
+				t.Errorf("Test (%s): failed, %v", tc.desc, err)
+			} else if !strings.Contains(resp, tc.wantResp) {
+				t.Errorf("Test (%s): failed\nexpected: %s\ngot: %s", tc.desc, tc.wantResp, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy_concurrency_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestEnvoyConcurrency(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestEnvoyConcurrency, platform.EchoSidecar)
+	s.SetEnvoyConcurrency(2)
+	defer s.TearDown(t)
+	if err := s.Setup(utils.CommonArgs()); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/server_info", platform.GetLoopbackAddress(), s.Ports().AdminPort)
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("fail to get the envoy server info, %v", err)
+	}
+	defer resp.Body.Close()
+
+	var serverInfo struct {
+		CommandLineOptions struct {
+			Concurrency int `json:"concurrency"`
+		} `json:"command_line_options"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&serverInfo); err != nil {
+		t.Fatalf("fail to decode the envoy server info, %v", err)
+	}
+	if got := serverInfo.CommandLineOptions.Concurrency; got != 2 {
+		t.Errorf("got envoy concurrency %v, want 2", got)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy_runtime_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+// The runtime key overriding the max requests of the circuit breaker of the
+// echo backend cluster.
+const backendMaxRequestsKey = "circuit_breakers.backend-cluster-echo-api.endpoints.cloudesf-testing.cloud.goog_local.default.max_requests"
+
+func TestEnvoyRuntime(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestEnvoyRuntime, platform.EchoSidecar)
+	s.SetEnvoyRuntime("envoy.reloadable_features.preserve_downstream_scheme=true;re2.max_program_size.warn_level=500")
+	defer s.TearDown(t)
+	if err := s.Setup(utils.CommonArgs()); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/runtime", platform.GetLoopbackAddress(), s.Ports().AdminPort)
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("fail to get the envoy runtime, %v", err)
+	}
+	defer resp.Body.Close()
+
+	var runtime struct {
+		Layers  []string `json:"layers"`
+		Entries map[string]struct {
+			FinalValue string `json:"final_value"`
+		} `json:"entries"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&runtime); err != nil {
+		t.Fatalf("fail to decode the envoy runtime, %v", err)
+	}
+
+	wantLayers := []string{"static-runtime", "custom-static-runtime"}
+	if len(runtime.Layers) != len(wantLayers) {
+		t.Fatalf("got runtime layers %v, want %v", runtime.Layers, wantLayers)
+	}
+	for i, wantLayer := range wantLayers {
+		if runtime.Layers[i] != wantLayer {
+			t.Errorf("got runtime layers %v, want %v", runtime.Layers, wantLayers)
+		}
+	}
+
+	// The custom layer overrides the value set by the default layer.
+	wantEntries := map[string]string{
+		"envoy.reloadable_features.preserve_downstream_scheme": "true",
+		"re2.max_program_size.error_level":                     "1000",
+		"re2.max_program_size.warn_level":                      "500",
+	}
+	for key, want := range wantEntries {
+		if got := runtime.Entries[key].FinalValue; got != want {
+			t.Errorf("got runtime value %q for %v, want %q", got, key, want)
+		}
+	}
+}
+
+func TestEnvoyRuntimeTakesEffect(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc         string
+		envoyRuntime string
+		wantError    string
+	}{
+		{
+			desc: "Success, requests are sent to the backend without a runtime override",
+		},
+		{
+			desc:         "Fail, the circuit breaker of the backend overflows when its max requests is overridden to 0",
+			envoyRuntime: backendMaxRequestsKey + "=0",
+			wantError:    "503 Service Unavailable",
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestEnvoyRuntimeTakesEffect, platform.EchoSidecar)
+			s.SetEnvoyRuntime(tc.envoyRuntime)
+			defer s.TearDown(t)
+			if err := s.Setup(utils.CommonArgs()); err != nil {
+				t.Fatalf("Test (%s): fail to setup test env, %v", tc.desc, err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			_, err := client.DoPost(url, "hello")
+			if tc.wantError == "" {
+				if err != nil {
+					t.Errorf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantError) || !strings.Contains(err.Error(), "overflow") {
+				t.Errorf("Test (%s): failed, want error: %v caused by overflow, got error: %v", tc.desc, tc.wantError, err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fault_injection_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestFaultInjection(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc       string
+		flags      []string
+		headers    map[string]string
+		wantError  string
+		wantMinDur time.Duration
+	}{
+		{
+			desc:      "All requests are aborted with the configured status",
+			flags:     []string{"--fault_abort_percent=100", "--fault_abort_status=418"},
+			wantError: "418 I'm a teapot",
+		},
+		{
+			desc:  "Requests without the fault header are not aborted",
+			flags: []string{"--fault_abort_percent=100", "--fault_header=x-inject-fault"},
+		},
+		{
+			desc:  "Requests with the fault header are aborted",
+			flags: []string{"--fault_abort_percent=100", "--fault_header=x-inject-fault"},
+			headers: map[string]string{
+				"x-inject-fault": "true",
+			},
+			wantError: "503 Service Unavailable",
+		},
+		{
+			desc:       "All requests are delayed",
+			flags:      []string{"--fault_delay_percent=100", "--fault_delay_duration=2s"},
+			wantMinDur: 2 * time.Second,
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestFaultInjection, platform.EchoSidecar)
+			defer s.TearDown(t)
+			if err := s.Setup(append(utils.CommonArgs(), tc.flags...)); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			startTime := time.Now()
+			_, _, err := utils.DoWithHeaders(url, "POST", "hello", tc.headers)
+			gotDur := time.Since(startTime)
+
+			if tc.wantError == "" {
+				if err != nil {
+					t.Errorf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+				t.Errorf("Test (%s): failed, want error: %v, got error: %v", tc.desc, tc.wantError, err)
+			}
+
+			if gotDur < tc.wantMinDur {
+				t.Errorf("Test (%s): failed, want latency at least %v, got %v", tc.desc, tc.wantMinDur, gotDur)
+			}
+		}()
+	}
+}
+
+func TestFaultInjectionByApiKey(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestFaultInjectionByApiKey, platform.EchoSidecar)
+	defer s.TearDown(t)
+	args := append(utils.CommonArgs(), "--fault_abort_percent=100", "--fault_api_keys=qa-test-key")
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc      string
+		query     string
+		headers   map[string]string
+		wantError string
+	}{
+		{
+			desc:      "Requests with the flagged API key in the query are aborted",
+			query:     "?key=qa-test-key",
+			wantError: "503 Service Unavailable",
+		},
+		{
+			desc: "Requests with the flagged API key in the header are aborted",
+			headers: map[string]string{
+				"x-api-key": "qa-test-key",
+			},
+			wantError: "503 Service Unavailable",
+		},
+		{
+			desc:  "Requests with another API key are not aborted",
+			query: "?key=api-key",
+		},
+		{
+			desc: "Requests with another API key in the header are not aborted",
+			headers: map[string]string{
+				"x-api-key": "api-key",
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v/echo%s", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.query)
+		_, _, err := utils.DoWithHeaders(url, "POST", "hello", tc.headers)
+
+		if tc.wantError == "" {
+			if err != nil {
+				t.Errorf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+			t.Errorf("Test (%s): failed, want error: %v, got error: %v", tc.desc, tc.wantError, err)
+		}
+	}
+}
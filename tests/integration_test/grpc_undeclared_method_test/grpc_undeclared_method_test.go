@@ -0,0 +1,134 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc_undeclared_method_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	emptypb "github.com/golang/protobuf/ptypes/empty"
+)
+
+// Only the gRPC methods declared in the service config are routed to the
+// backend. Calls to any other method of the backend are rejected by ESPv2 with
+// UNIMPLEMENTED, even though the backend implements them.
+func TestGRPCUndeclaredMethod(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID, "--rollout_strategy=fixed"}
+
+	s := env.NewTestEnv(platform.TestGRPCUndeclaredMethod, platform.GrpcBookstoreSidecar)
+	s.RemoveMethod("endpoints.examples.bookstore.Bookstore.DeleteShelf")
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		method         string
+		wantResp       string
+		wantError      string
+		wantScRequests int
+	}{
+		{
+			desc:     "Succeed, declared method is routed to the backend",
+			method:   "GetShelf",
+			wantResp: `{"id":"100","theme":"Kids"}`,
+			// Check and report.
+			wantScRequests: 2,
+		},
+		{
+			desc:      "Fail, undeclared method is rejected by ESPv2",
+			method:    "DeleteShelf",
+			wantError: `code = Unimplemented, message = {"code":404,"message":"The current request is not defined by this API."}`,
+			// Only report, as an unknown operation.
+			wantScRequests: 1,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			header := http.Header{"x-api-key": []string{"api-key"}}
+			resp, err := client.MakeCall("grpc", addr, "", tc.method, "", header)
+
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("Test (%s): failed\nexpected err: %v\ngot: %v", tc.desc, tc.wantError, err)
+				}
+			} else if err != nil {
+				t.Errorf("Test (%s): failed, %v", tc.desc, err)
+			} else if !strings.Contains(resp, tc.wantResp) {
+				t.Errorf("Test (%s): failed\nexpected: %s\ngot: %s", tc.desc, tc.wantResp, resp)
+			}
+
+			if _, err := s.ServiceControlServer.GetRequests(tc.wantScRequests); err != nil {
+				t.Errorf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err)
+			}
+		})
+	}
+}
+
+// A method that neither the service config nor the backend defines must be
+// rejected with a gRPC UNIMPLEMENTED status in the trailers, not a bare HTTP
+// 404 that gRPC clients cannot interpret.
+func TestGRPCNonexistentMethod(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID, "--rollout_strategy=fixed"}
+
+	s := env.NewTestEnv(platform.TestGRPCNonexistentMethod, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("fail to dial %v: %v", addr, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = conn.Invoke(ctx, "/endpoints.examples.bookstore.Bookstore/NoSuchMethod", &emptypb.Empty{}, &emptypb.Empty{})
+	if got := status.Code(err); got != codes.Unimplemented {
+		t.Fatalf("expected gRPC status %v, got %v (err: %v)", codes.Unimplemented, got, err)
+	}
+	if want := "The current request is not defined by this API."; !strings.Contains(status.Convert(err).Message(), want) {
+		t.Errorf("expected gRPC message to contain %q, got %q", want, status.Convert(err).Message())
+	}
+
+	// Only report, as an unknown operation.
+	if _, err := s.ServiceControlServer.GetRequests(1); err != nil {
+		t.Errorf("GetRequests returns error: %v", err)
+	}
+}
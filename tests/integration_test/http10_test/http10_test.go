@@ -0,0 +1,90 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http10_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestAcceptHttp10(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc       string
+		flags      []string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:       "HTTP/1.0 requests are rejected by default",
+			wantStatus: http.StatusUpgradeRequired,
+		},
+		{
+			desc:       "HTTP/1.0 requests without a Host header are handled with the default host",
+			flags:      []string{"--accept_http_10", "--default_host_for_http_10=localhost"},
+			wantStatus: http.StatusOK,
+			wantBody:   "simple get message",
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestAcceptHttp10, platform.EchoSidecar)
+			defer s.TearDown(t)
+			if err := s.Setup(append(utils.CommonArgs(), tc.flags...)); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+			if err != nil {
+				t.Fatalf("Test (%s): fail to dial %v: %v", tc.desc, addr, err)
+			}
+			defer conn.Close()
+
+			// The Go HTTP client cannot send HTTP/1.0 requests, so write it directly.
+			if _, err := conn.Write([]byte("GET /simpleget?key=api-key HTTP/1.0\r\n\r\n")); err != nil {
+				t.Fatalf("Test (%s): fail to write request: %v", tc.desc, err)
+			}
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("Test (%s): fail to read response: %v", tc.desc, err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("Test (%s): got status %v, want %v, body: %s", tc.desc, resp.StatusCode, tc.wantStatus, body)
+			}
+			if tc.wantBody != "" && string(body) != tc.wantBody {
+				t.Errorf("Test (%s): got body %q, want %q", tc.desc, body, tc.wantBody)
+			}
+		}()
+	}
+}
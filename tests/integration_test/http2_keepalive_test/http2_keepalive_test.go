@@ -0,0 +1,176 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http2_keepalive_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	bspbv1 "github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/proto/v1"
+)
+
+const streamID = 1
+
+func TestListenerHttp2Keepalive(t *testing.T) {
+	t.Parallel()
+
+	keepaliveInterval := time.Second
+	args := utils.CommonArgs()
+	args = append(args, fmt.Sprintf("--listener_http2_keepalive_interval=%v", keepaliveInterval))
+
+	s := env.NewTestEnv(platform.TestListenerHttp2Keepalive, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort))
+	if err != nil {
+		t.Fatalf("fail to connect to the listener, %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		t.Fatalf("fail to write the HTTP/2 preface, %v", err)
+	}
+	framer := http2.NewFramer(conn, conn)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatalf("fail to write the HTTP/2 settings, %v", err)
+	}
+
+	// Open a GetShelf stream, but hold back the request message so that the
+	// stream stays idle.
+	var headerBlock bytes.Buffer
+	encoder := hpack.NewEncoder(&headerBlock)
+	for _, field := range []hpack.HeaderField{
+		{Name: ":method", Value: "POST"},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":path", Value: "/endpoints.examples.bookstore.Bookstore/GetShelf"},
+		{Name: ":authority", Value: "localhost"},
+		{Name: "content-type", Value: "application/grpc"},
+		{Name: "te", Value: "trailers"},
+		{Name: "x-api-key", Value: "api-key"},
+	} {
+		if err := encoder.WriteField(field); err != nil {
+			t.Fatalf("fail to encode header %v, %v", field.Name, err)
+		}
+	}
+	if err := framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: headerBlock.Bytes(),
+		EndHeaders:    true,
+	}); err != nil {
+		t.Fatalf("fail to open the stream, %v", err)
+	}
+
+	// Stay idle for several keepalive intervals, answering every PING.
+	pings := 0
+	idleUntil := time.Now().Add(3*keepaliveInterval + keepaliveInterval/2)
+	for {
+		if err := conn.SetReadDeadline(idleUntil); err != nil {
+			t.Fatal(err)
+		}
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				break
+			}
+			t.Fatalf("connection is dropped while idle, %v", err)
+		}
+		if done := handleControlFrame(t, framer, frame); done {
+			t.Fatalf("got an unexpected response on the idle stream")
+		}
+		if ping, ok := frame.(*http2.PingFrame); ok && !ping.IsAck() {
+			pings++
+		}
+	}
+	if pings < 2 {
+		t.Errorf("got %v keepalive PINGs while idle for 3 intervals, want at least 2", pings)
+	}
+
+	// The stream survived the idle period, so finish the request.
+	reqMsg, err := proto.Marshal(&bspbv1.GetShelfRequest{Shelf: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcFrame := make([]byte, 5+len(reqMsg))
+	binary.BigEndian.PutUint32(grpcFrame[1:5], uint32(len(reqMsg)))
+	copy(grpcFrame[5:], reqMsg)
+	if err := framer.WriteData(streamID, true, grpcFrame); err != nil {
+		t.Fatalf("fail to send the request message, %v", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	decoder := hpack.NewDecoder(4096, nil)
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatalf("fail to read the response, %v", err)
+		}
+		if done := handleControlFrame(t, framer, frame); !done {
+			continue
+		}
+
+		fields, err := decoder.DecodeFull(frame.(*http2.HeadersFrame).HeaderBlockFragment())
+		if err != nil {
+			t.Fatalf("fail to decode the response headers, %v", err)
+		}
+		for _, field := range fields {
+			if field.Name == ":status" && field.Value != "200" {
+				t.Errorf("got response status %v, want 200", field.Value)
+			}
+		}
+		return
+	}
+}
+
+// handleControlFrame answers SETTINGS and PING frames, fails on GOAWAY and
+// RST_STREAM, and returns true on the response headers of the stream.
+func handleControlFrame(t *testing.T, framer *http2.Framer, frame http2.Frame) bool {
+	switch f := frame.(type) {
+	case *http2.SettingsFrame:
+		if !f.IsAck() {
+			if err := framer.WriteSettingsAck(); err != nil {
+				t.Fatalf("fail to ack settings, %v", err)
+			}
+		}
+	case *http2.PingFrame:
+		if !f.IsAck() {
+			if err := framer.WritePing(true, f.Data); err != nil {
+				t.Fatalf("fail to ack ping, %v", err)
+			}
+		}
+	case *http2.GoAwayFrame:
+		t.Fatalf("got GOAWAY with error code %v", f.ErrCode)
+	case *http2.RSTStreamFrame:
+		t.Fatalf("got RST_STREAM with error code %v", f.ErrCode)
+	case *http2.HeadersFrame:
+		return f.StreamID == streamID
+	}
+	return false
+}
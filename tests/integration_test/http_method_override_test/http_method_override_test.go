@@ -24,6 +24,8 @@ import (
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
 )
 
 func TestMethodOverrideBackendMethod(t *testing.T) {
@@ -167,6 +169,14 @@ func TestMethodOverrideScReport(t *testing.T) {
 	t.Parallel()
 
 	s := env.NewTestEnv(platform.TestMethodOverrideScReport, platform.EchoSidecar)
+	s.AppendHttpRules([]*annotationspb.HttpRule{
+		{
+			Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoDELETE",
+			Pattern: &annotationspb.HttpRule_Delete{
+				Delete: "/echoMethod",
+			},
+		},
+	})
 	defer s.TearDown(t)
 	if err := s.Setup(utils.CommonArgs()); err != nil {
 		t.Fatalf("fail to setup test env, %v", err)
@@ -222,6 +232,45 @@ func TestMethodOverrideScReport(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc:   "Overridden POST is matched to the DELETE operation, success case",
+			url:    fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echoMethod?key=api-key"),
+			method: "POST",
+			requestHeader: map[string]string{
+				"X-HTTP-Method-Override": "DELETE",
+			},
+			wantResp: `{"RequestMethod": "DELETE"}`,
+			wantScRequests: []interface{}{
+				&utils.ExpectedCheck{
+					Version:         utils.ESPv2Version(),
+					ServiceName:     "echo-api.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID: "test-config-id",
+					ConsumerID:      "api_key:api-key",
+					OperationName:   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoDELETE",
+					CallerIp:        platform.GetLoopbackAddress(),
+				},
+				&utils.ExpectedReport{
+					Version:                      utils.ESPv2Version(),
+					ServiceName:                  "echo-api.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID:              "test-config-id",
+					URL:                          "/echoMethod?key=api-key",
+					ApiKeyInOperationAndLogEntry: "api-key",
+					ApiKeyState:                  "VERIFIED",
+					ApiMethod:                    "1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoDELETE",
+					ApiName:                      "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+					ApiVersion:                   "1.0.0",
+					ProducerProjectID:            "producer-project",
+					ConsumerProjectID:            "123456",
+					HttpMethod:                   "DELETE",
+					FrontendProtocol:             "http",
+					LogMessage:                   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoDELETE is called",
+					StatusCode:                   "0",
+					ResponseCode:                 200,
+					Platform:                     util.GCE,
+					Location:                     "test-zone",
+				},
+			},
+		},
 	}
 	for _, tc := range testData {
 		resp, err := client.DoWithHeaders(tc.url, tc.method, tc.message, tc.requestHeader)
@@ -0,0 +1,99 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipv6_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestIPv6ListenerAndBackend(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback is not available, %v", err)
+	}
+
+	// The remote backend only listens on the IPv6 loopback and responds with
+	// the Host header and the path it receives.
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf(`{"host":"%s","path":"%s"}`, r.Host, r.URL.Path)))
+	}))
+	backend.Listener.Close()
+	backend.Listener = l
+	backend.Start()
+	defer backend.Close()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed",
+		"--listener_address=::1"}
+
+	s := env.NewTestEnv(platform.TestIPv6ListenerAndBackend, platform.EchoSidecar)
+	s.AppendBackendRules([]*confpb.BackendRule{
+		{
+			Selector:        "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			Address:         backend.URL,
+			PathTranslation: confpb.BackendRule_APPEND_PATH_TO_ADDRESS,
+			Authentication: &confpb.BackendRule_DisableAuth{
+				DisableAuth: true,
+			},
+		},
+	})
+	// The Envoy health check connects over the IPv4 loopback, which the
+	// listener does not bind to.
+	s.SkipEnvoyHealthChecks()
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://[::1]:%v/echo?key=api-key", s.Ports().ListenerPort)
+	var resp []byte
+	for i := 0; i < 10; i++ {
+		resp, err = client.DoPost(url, "hello")
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		t.Fatalf("request over the IPv6 listener should succeed, got error: %v", err)
+	}
+
+	// The cluster address and the Host header both use the bracketed literal.
+	wantResp := `{"host":"[::1]","path":"/echo"}`
+	if string(resp) != wantResp {
+		t.Errorf("got response %s, want %s", resp, wantResp)
+	}
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	conn, err := net.DialTimeout(platform.GetNetworkProtocol(), addr, 5*time.Second)
+	if err == nil {
+		conn.Close()
+		t.Errorf("connection to the IPv4 loopback %v should be refused", addr)
+	}
+}
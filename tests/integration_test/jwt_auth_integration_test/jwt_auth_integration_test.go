@@ -16,6 +16,8 @@ package jwt_auth_integration_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"strings"
 	"testing"
 	"time"
@@ -460,3 +462,151 @@ func TestFrontendAndBackendAuthHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestOversizedJwt(t *testing.T) {
+	t.Parallel()
+
+	// An 80 KB token is larger than the default 60 KB limit of all request headers.
+	oversizedToken := strings.Repeat("a", 80*1024)
+	// A 2 MiB book is larger than the 1 MiB buffer limit of the transcoder.
+	oversizedBody := fmt.Sprintf(`{"id": 4, "type": 1, "author":"%s"}`, strings.Repeat("a", 2*1024*1024))
+
+	testData := []struct {
+		desc      string
+		extraArgs []string
+		body      string
+		wantError string
+	}{
+		{
+			desc:      "Failed, oversized JWT is rejected with a descriptive message by default",
+			wantError: `413 Payload Too Large, {"code":413,"message":"Request headers, including any JWT, exceed the 60 KB limit."}`,
+		},
+		{
+			desc:      "Failed, oversized JWT reaches jwt_authn when the header limit is raised",
+			extraArgs: []string{"--max_request_headers_kb=128"},
+			wantError: `401 Unauthorized, {"code":401,"message":"Jwt is not in the form of Header.Payload.Signature`,
+		},
+		{
+			desc:      "Failed, oversized body is rejected with a descriptive message",
+			body:      oversizedBody,
+			wantError: `413 Payload Too Large, {"code":413,"message":"Request body exceeds the buffer limit."}`,
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			configID := "test-config-id"
+			args := append([]string{"--service_config_id=" + configID,
+				"--rollout_strategy=fixed"}, tc.extraArgs...)
+
+			s := env.NewTestEnv(platform.TestOversizedJwt, platform.GrpcBookstoreSidecar)
+			s.OverrideAuthentication(&confpb.Authentication{
+				Rules: []*confpb.AuthenticationRule{
+					{
+						Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+						Requirements: []*confpb.AuthRequirement{
+							{
+								ProviderId: testdata.TestAuthProvider,
+								Audiences:  "ok_audience",
+							},
+						},
+					},
+				},
+			})
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("Test (%s): fail to setup test env, %v", tc.desc, err)
+			}
+
+			addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			var err error
+			if tc.body != "" {
+				err = postOversizedBody(fmt.Sprintf("http://%v/v1/shelves/100/books?key=api-key", addr), tc.body)
+			} else {
+				_, err = client.MakeCall("http", addr, "GET", "/v1/shelves?key=api-key", oversizedToken, nil)
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+				t.Errorf("Test (%s): failed, expected err: %v, got: %v", tc.desc, tc.wantError, err)
+			}
+		}()
+	}
+}
+
+// postOversizedBody posts the JSON body and returns an error describing the
+// response if it is not 200.
+func postOversizedBody(url, body string) error {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http response status is not 200 OK: %s, %s", resp.Status, utils.RpcStatusDeterministicJsonFormat(respBody))
+	}
+	return nil
+}
+
+func TestJwksWarmOnStartup(t *testing.T) {
+	tests := []struct {
+		desc        string
+		configArgs  []string
+		expectedErr string
+	}{
+		{
+			desc: "Fail when the JWKS of a provider is required but the provider is down.",
+			configArgs: append([]string{
+				"--jwks_warm_on_startup=required",
+			}, utils.CommonArgs()...),
+			expectedErr: "health check response was not healthy",
+		},
+		{
+			desc: "Succeed with a warning when the JWKS of a provider is optional but the provider is down.",
+			configArgs: append([]string{
+				"--jwks_warm_on_startup=optional",
+			}, utils.CommonArgs()...),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := env.NewTestEnv(platform.TestJwksWarmOnStartup, platform.GrpcBookstoreSidecar)
+			s.OverrideAuthentication(&confpb.Authentication{
+				Rules: []*confpb.AuthenticationRule{
+					{
+						Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+						Requirements: []*confpb.AuthRequirement{
+							{
+								ProviderId: testdata.NonexistentProvider,
+								Audiences:  "ok_audience",
+							},
+						},
+					},
+				},
+			})
+
+			err := s.Setup(tc.configArgs)
+
+			// LIFO ordering. Disable health checks before teardown if a failure is
+			// expected.
+			defer s.TearDown(t)
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("fail to setup test env, %v", err)
+				}
+				return
+			}
+			defer s.SkipHealthChecks()
+
+			if err == nil {
+				t.Errorf("failed, expected error, got no err")
+			} else if !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("failed, expected err: %v, got err: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt_claim_routing_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+)
+
+func TestJwtClaimRouting(t *testing.T) {
+	t.Parallel()
+
+	// The fake JWT tokens are pre-signed, so route on the issuer claim, which
+	// differs between the two default bookstore tokens. Requests with the
+	// dedicated issuer are sent to a backend that is not listening, which
+	// proves they did not reach the default backend.
+	args := []string{
+		"--service_config_id=test-config-id",
+		"--rollout_strategy=fixed",
+		fmt.Sprintf("--jwt_claim_backend_routes=iss:jwt-client.endpoints.sample.google.com=grpc://%v:%v",
+			platform.GetLoopbackAddress(), platform.InvalidBackendPort),
+	}
+
+	s := env.NewTestEnv(platform.TestJwtClaimRouting, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc      string
+		token     string
+		wantResp  string
+		wantError string
+	}{
+		{
+			desc:     "Succeed, token without a matching claim is routed to the default backend",
+			token:    testdata.FakeCloudGrpcBookstoreDefaultToken,
+			wantResp: "{}",
+		},
+		{
+			desc:      "Fail, token with a matching claim is routed to the dedicated backend",
+			token:     testdata.FakeEndpointsGrpcBookstoreDefaultToken,
+			wantError: `503 Service Unavailable, {"code":503,"message":"upstream connect error or disconnect/reset before headers. reset reason: connection failure"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			resp, err := client.MakeCall("http", addr, "DELETE", "/v1/shelves/120?key=api-key", tc.token, nil)
+
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("Test (%s): failed\nexpected err: %v\ngot: %v", tc.desc, tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+			}
+			if !strings.Contains(resp, tc.wantResp) {
+				t.Errorf("Test (%s): failed\nexpected: %s\ngot: %s", tc.desc, tc.wantResp, resp)
+			}
+		})
+	}
+}
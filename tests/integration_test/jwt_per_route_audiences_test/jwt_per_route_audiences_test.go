@@ -0,0 +1,117 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt_per_route_audiences_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+// Two operations require the same provider with different audiences, each
+// one only accepts the tokens issued for its own audience.
+func TestJwtPerRouteAudiences(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId, "--rollout_strategy=fixed"}
+
+	s := env.NewTestEnv(platform.TestJwtPerRouteAudiences, platform.EchoSidecar)
+	s.OverrideAuthentication(&confpb.Authentication{
+		Rules: []*confpb.AuthenticationRule{
+			{
+				Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: testdata.GoogleJwtProvider,
+						Audiences:  "bookstore_test_client.cloud.goog",
+					},
+				},
+			},
+			{
+				Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo_nokey",
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: testdata.GoogleJwtProvider,
+						Audiences:  "admin.cloud.goog",
+					},
+				},
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc      string
+		path      string
+		token     string
+		wantError string
+	}{
+		{
+			desc:  "Succeed, the token of the first audience is accepted on its route",
+			path:  "/echo?key=api-key",
+			token: testdata.FakeCloudTokenSingleAudience1,
+		},
+		{
+			desc:      "Fail, the token of the first audience is rejected on the route of the second audience",
+			path:      "/echo/nokey",
+			token:     testdata.FakeCloudTokenSingleAudience1,
+			wantError: `403 Forbidden, {"code":403,"message":"Audiences in Jwt are not allowed"}`,
+		},
+		{
+			desc:  "Succeed, the token of the second audience is accepted on its route",
+			path:  "/echo/nokey",
+			token: testdata.FakeCloudTokenSingleAudience2,
+		},
+		{
+			desc:      "Fail, the token of the second audience is rejected on the route of the first audience",
+			path:      "/echo?key=api-key",
+			token:     testdata.FakeCloudTokenSingleAudience2,
+			wantError: `403 Forbidden, {"code":403,"message":"Audiences in Jwt are not allowed"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.path)
+			resp, err := client.DoPostWithHeaders(url, "hello", map[string]string{
+				"Authorization": "Bearer " + tc.token,
+			})
+
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("got error %v, want error %v", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			if want := `{"message":"hello"}`; !strings.Contains(string(resp), want) {
+				t.Errorf("got response %s, want %s", resp, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt_provider_additional_issuers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestJwtProviderAdditionalIssuers(t *testing.T) {
+	t.Parallel()
+
+	// Serves the JWKS of the additional issuer.
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testdata.FakeEndpointsJwks))
+	}))
+	defer jwksServer.Close()
+
+	additionalIssuerArgs := []string{
+		fmt.Sprintf("--jwt_provider_additional_issuers=%s=%s|%s", testdata.GoogleJwtProvider, testdata.JwtEndpointsIssuer, jwksServer.URL),
+		// The token of the additional issuer has no audiences.
+		fmt.Sprintf("--jwt_skip_audience_check=%s_issuer_1", testdata.GoogleJwtProvider),
+	}
+
+	testData := []struct {
+		desc      string
+		args      []string
+		token     string
+		wantResp  string
+		wantError string
+	}{
+		{
+			desc:     "Succeed, a token of the provider issuer is accepted",
+			args:     additionalIssuerArgs,
+			token:    testdata.FakeCloudTokenSingleAudience1,
+			wantResp: `{"message":"hello"}`,
+		},
+		{
+			desc:     "Succeed, a token of the additional issuer is accepted on the same route",
+			args:     additionalIssuerArgs,
+			token:    testdata.FakeEndpointsToken,
+			wantResp: `{"message":"hello"}`,
+		},
+		{
+			desc:      "Fail, a token of another issuer is rejected without additional issuers",
+			token:     testdata.FakeEndpointsToken,
+			wantError: `401 Unauthorized, {"code":401,"message":"Jwt issuer is not configured"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		// The test envs share the same ports, so they are run one after the other.
+		func() {
+			configId := "test-config-id"
+			args := append([]string{"--service_config_id=" + configId,
+				"--rollout_strategy=fixed"}, tc.args...)
+
+			s := env.NewTestEnv(platform.TestJwtProviderAdditionalIssuers, platform.EchoSidecar)
+			s.OverrideAuthentication(&confpb.Authentication{
+				Rules: []*confpb.AuthenticationRule{
+					{
+						Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+						Requirements: []*confpb.AuthRequirement{
+							{
+								ProviderId: testdata.GoogleJwtProvider,
+								Audiences:  "bookstore_test_client.cloud.goog",
+							},
+						},
+					},
+				},
+			})
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			resp, err := client.DoPostWithHeaders(url, "hello", map[string]string{
+				"Authorization": "Bearer " + tc.token,
+			})
+
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("Test (%s): failed, got error %v, want error %v", tc.desc, err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test (%s): failed, got unexpected error: %v", tc.desc, err)
+			}
+			if !strings.Contains(string(resp), tc.wantResp) {
+				t.Errorf("Test (%s): failed, got response %s, want %s", tc.desc, resp, tc.wantResp)
+			}
+		}()
+	}
+}
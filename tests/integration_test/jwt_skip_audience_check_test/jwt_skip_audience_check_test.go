@@ -0,0 +1,94 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt_skip_audience_check_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestJwtSkipAudienceCheck(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc      string
+		args      []string
+		wantResp  string
+		wantError string
+	}{
+		{
+			desc:      "Fail, a token without audiences is rejected by the default audience check",
+			wantError: `403 Forbidden, {"code":403,"message":"Audiences in Jwt are not allowed"}`,
+		},
+		{
+			desc:     "Succeed, a token without audiences is accepted when the audience check is skipped",
+			args:     []string{"--jwt_skip_audience_check=" + testdata.EndpointsJwtProvider},
+			wantResp: `{"message":"hello"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		// The test envs share the same ports, so they are run one after the other.
+		func() {
+			configId := "test-config-id"
+			args := append([]string{"--service_config_id=" + configId,
+				"--rollout_strategy=fixed"}, tc.args...)
+
+			s := env.NewTestEnv(platform.TestJwtSkipAudienceCheck, platform.EchoSidecar)
+			s.OverrideAuthentication(&confpb.Authentication{
+				Rules: []*confpb.AuthenticationRule{
+					{
+						Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+						Requirements: []*confpb.AuthRequirement{
+							{
+								ProviderId: testdata.EndpointsJwtProvider,
+							},
+						},
+					},
+				},
+			})
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			resp, err := client.DoPostWithHeaders(url, "hello", map[string]string{
+				"Authorization": "Bearer " + testdata.FakeEndpointsToken,
+			})
+
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("Test (%s): failed, got error %v, want error %v", tc.desc, err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test (%s): failed, got unexpected error: %v", tc.desc, err)
+			}
+			if !strings.Contains(string(resp), tc.wantResp) {
+				t.Errorf("Test (%s): failed, got response %s, want %s", tc.desc, resp, tc.wantResp)
+			}
+		}()
+	}
+}
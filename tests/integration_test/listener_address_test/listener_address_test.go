@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener_address_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+)
+
+// nonLoopbackAddress returns an IPv4 address of this host that is not the
+// loopback, or an empty string if there is none.
+func nonLoopbackAddress() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		return ipNet.IP.String(), nil
+	}
+	return "", nil
+}
+
+func TestListenerAddress(t *testing.T) {
+	t.Parallel()
+
+	otherAddress, err := nonLoopbackAddress()
+	if err != nil {
+		t.Fatalf("fail to get interface addresses, %v", err)
+	}
+	if otherAddress == "" {
+		t.Skip("no non-loopback interface to test against")
+	}
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID,
+		"--rollout_strategy=fixed",
+		"--listener_address=" + platform.GetLoopbackAddress()}
+
+	s := env.NewTestEnv(platform.TestListenerAddress, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echo?key=api-key")
+	resp, err := client.DoPost(url, "hello")
+	if err != nil {
+		t.Fatalf("request over the loopback should succeed, got error: %v", err)
+	}
+	if wantResp := `{"message":"hello"}`; !strings.Contains(string(resp), wantResp) {
+		t.Errorf("expected: %s, got: %s", wantResp, string(resp))
+	}
+
+	addr := fmt.Sprintf("%v:%v", otherAddress, s.Ports().ListenerPort)
+	conn, err := net.DialTimeout(platform.GetNetworkProtocol(), addr, 5*time.Second)
+	if err == nil {
+		conn.Close()
+		t.Fatalf("connection to the non-configured address %v should be refused", addr)
+	}
+}
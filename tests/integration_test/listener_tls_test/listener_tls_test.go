@@ -0,0 +1,76 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener_tls_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestListenerTLS(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestListenerTLS, platform.EchoSidecar)
+	s.EnableListenerTLS(platform.GetFilePath(platform.ServerCert), platform.GetFilePath(platform.ServerKey), "")
+	defer s.TearDown(t)
+	if err := s.Setup(utils.CommonArgs()); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	httpClient, err := s.ListenerHttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := s.ListenerURL("/echo?key=api-key")
+	if !strings.HasPrefix(url, "https://") {
+		t.Fatalf("got listener url %v, want an https url", url)
+	}
+	resp, err := httpClient.Post(url, "application/json", strings.NewReader(`"hello"`))
+	if err != nil {
+		t.Fatalf("fail to call echo over TLS, %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS == nil || !resp.TLS.HandshakeComplete {
+		t.Fatalf("got no completed TLS handshake")
+	}
+	if resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("got TLS version %x, want at least TLS 1.2", resp.TLS.Version)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("fail to read the response, %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status code %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if want := `{"message":"hello"}`; string(body) != want {
+		t.Errorf("got response %s, want %s", body, want)
+	}
+
+	// A plaintext request to the TLS listener fails.
+	if _, err := http.Post(strings.Replace(url, "https://", "http://", 1), "application/json", strings.NewReader(`"hello"`)); err == nil {
+		t.Errorf("plaintext request to the TLS listener succeeded, want an error")
+	}
+}
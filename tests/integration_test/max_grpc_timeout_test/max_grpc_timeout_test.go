@@ -0,0 +1,114 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package max_grpc_timeout_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+	"golang.org/x/net/http2"
+)
+
+func TestMaxGrpcTimeout(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--service_config_id=test-config-id",
+		"--rollout_strategy=fixed", "--max_grpc_timeout=3s"}
+
+	s := env.NewTestEnv(platform.TestMaxGrpcTimeout, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// The gRPC request is sent over plain HTTP/2, as gRPC clients enforce
+	// their own deadline and would hide the one enforced by the proxy.
+	cli := http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+
+	testData := []struct {
+		desc        string
+		grpcTimeout string
+		maxElapsed  time.Duration
+	}{
+		{
+			desc:        "Slow call is timed out after its grpc-timeout",
+			grpcTimeout: "1S",
+			maxElapsed:  2 * time.Second,
+		},
+		{
+			desc:        "grpc-timeout is capped by the max",
+			grpcTimeout: "60S",
+			maxElapsed:  4 * time.Second,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			url := fmt.Sprintf("http://%v:%v/endpoints.examples.bookstore.Bookstore/ListShelves", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			// The length-prefixed message of an empty ListShelves request.
+			req, err := http.NewRequest("POST", url, bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/grpc")
+			req.Header.Set("TE", "trailers")
+			req.Header.Set("Authorization", "Bearer "+testdata.FakeCloudTokenMultiAudiences)
+			req.Header.Set("x-api-key", "api-key")
+			req.Header.Set("grpc-timeout", tc.grpcTimeout)
+			// The backend responds after 5s.
+			req.Header.Set(client.TestHeaderKey, "SLOW")
+
+			start := time.Now()
+			resp, err := cli.Do(req)
+			if err != nil {
+				t.Fatalf("fail to call ListShelves: %v", err)
+			}
+			defer resp.Body.Close()
+			if _, err := ioutil.ReadAll(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+			elapsed := time.Since(start)
+
+			// Trailers-only responses carry the status in the headers.
+			gotStatus := resp.Header.Get("grpc-status")
+			if gotStatus == "" {
+				gotStatus = resp.Trailer.Get("grpc-status")
+			}
+			if gotStatus != "4" {
+				t.Errorf("got grpc-status %q, want 4 (DEADLINE_EXCEEDED)", gotStatus)
+			}
+			if elapsed > tc.maxElapsed {
+				t.Errorf("got response after %v, want it within %v", elapsed, tc.maxElapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package max_request_bytes_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestMaxRequestBytesByOperation(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestMaxRequestBytesByOperation, platform.EchoSidecar)
+	defer s.TearDown(t)
+	args := append(utils.CommonArgs(), "--max_request_bytes_by_operation="+
+		"1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=1024,"+
+		"1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo_nokey=4096")
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		path           string
+		messageSize    int
+		wantStatusCode int
+	}{
+		{
+			desc:           "Request within the limit of the operation is accepted",
+			path:           "/echo?key=api-key",
+			messageSize:    512,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "Request over the limit of the operation is rejected",
+			path:           "/echo?key=api-key",
+			messageSize:    2048,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:           "Same request is accepted by the operation with a larger limit",
+			path:           "/echo/nokey",
+			messageSize:    2048,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "Request over the larger limit is rejected",
+			path:           "/echo/nokey",
+			messageSize:    8192,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.path)
+			body := fmt.Sprintf(`{"message":"%s"}`, strings.Repeat("a", tc.messageSize))
+			resp, err := http.Post(url, "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("fail to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			respBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.wantStatusCode {
+				t.Errorf("got status %v, want %v, body: %s", resp.StatusCode, tc.wantStatusCode, respBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package max_request_duration_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestMaxRequestDuration(t *testing.T) {
+	t.Parallel()
+
+	maxRequestDuration := 3 * time.Second
+	args := utils.CommonArgs()
+	args = append(args, fmt.Sprintf("--max_request_duration=%v", maxRequestDuration))
+
+	s := env.NewTestEnv(platform.TestMaxRequestDuration, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc        string
+		reqDuration time.Duration
+		wantErr     string
+	}{
+		{
+			desc:        "Success, the backend responds within the max request duration",
+			reqDuration: time.Second,
+		},
+		{
+			desc:        "Fail, the backend does not respond within the max request duration",
+			reqDuration: time.Minute,
+			wantErr:     `504 Gateway Timeout, {"code":504,"message":"downstream duration timeout"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			url := fmt.Sprintf("http://%v:%v/sleep?duration=%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.reqDuration.String())
+			start := time.Now()
+			_, err := client.DoWithHeaders(url, "GET", "", nil)
+			elapsed := time.Since(start)
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Test (%s): failed, got err (%v), expected err (%v)", tc.desc, err, tc.wantErr)
+			}
+			// The default response deadline of 15s is not reached first.
+			if elapsed < maxRequestDuration || elapsed > maxRequestDuration+2*time.Second {
+				t.Errorf("Test (%s): failed, the request is terminated after %v, expected after %v", tc.desc, elapsed, maxRequestDuration)
+			}
+		})
+	}
+}
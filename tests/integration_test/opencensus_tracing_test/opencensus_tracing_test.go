@@ -773,3 +773,48 @@ func TestReportTraceId(t *testing.T) {
 		})
 	}
 }
+
+// Without an incoming trace context, the trace id generated by ESPv2 is placed
+// in the SC Report, and it is the same one of the spans sent to Stackdriver.
+func TestReportTraceIdMatchesSpans(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestReportTraceIdMatchesSpans, platform.EchoSidecar)
+	s.SetupFakeTraceServer(1)
+	defer s.TearDown(t)
+	if err := s.Setup(utils.CommonArgs()); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echo/nokey")
+	if _, err := client.DoWithHeaders(url, "POST", `{"message":"hello"}`, nil); err != nil {
+		t.Fatalf("fail to make call to backend: %v", err)
+	}
+
+	scRequests, err := s.ServiceControlServer.GetRequests(1)
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	report, err := utils.UnmarshalReportRequest(scRequests[0].ReqBody)
+	if err != nil {
+		t.Fatalf("fail to unmarshal the SC Report: %v", err)
+	}
+	if len(report.Operations) != 1 || len(report.Operations[0].LogEntries) != 1 {
+		t.Fatalf("expected 1 operation with 1 log entry in the SC Report, got %v", report)
+	}
+	gotTrace := report.Operations[0].LogEntries[0].Trace
+
+	time.Sleep(5 * time.Second)
+	traceIds, err := s.FakeStackdriverServer.RetrieveTraceIds()
+	if err != nil {
+		t.Fatalf("fail to retrieve the trace ids: %v", err)
+	}
+	if len(traceIds) != 1 {
+		t.Fatalf("expected all spans in 1 trace, got trace ids %v", traceIds)
+	}
+
+	wantTrace := "projects/" + comp.FakeProjectID + "/traces/" + traceIds[0]
+	if gotTrace != wantTrace {
+		t.Errorf("expected trace %v in the SC Report, got %v", wantTrace, gotTrace)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy_protocol_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+// proxyProtocolV2Header builds a PROXY protocol v2 header for a TCP over IPv4
+// connection from src to dst.
+func proxyProtocolV2Header(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	header := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	// Version 2, PROXY command.
+	header = append(header, 0x21)
+	// AF_INET, STREAM.
+	header = append(header, 0x11)
+
+	addrs := make([]byte, 12)
+	copy(addrs[0:4], src.To4())
+	copy(addrs[4:8], dst.To4())
+	binary.BigEndian.PutUint16(addrs[8:10], srcPort)
+	binary.BigEndian.PutUint16(addrs[10:12], dstPort)
+
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(addrs)))
+	header = append(header, length...)
+	return append(header, addrs...)
+}
+
+func TestProxyProtocolClientAddress(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--enable_proxy_protocol"}
+
+	s := env.NewTestEnv(platform.TestProxyProtocolClientAddress, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// The address of the original client, as seen by the load balancer.
+	clientIp := "192.0.2.10"
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("fail to dial %v: %v", addr, err)
+	}
+	defer conn.Close()
+
+	header := proxyProtocolV2Header(net.ParseIP(clientIp), net.ParseIP(platform.GetLoopbackAddress()), 56324, s.Ports().ListenerPort)
+	if _, err := conn.Write(header); err != nil {
+		t.Fatalf("fail to write PROXY protocol header: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%v/simpleget?key=api-key", addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("fail to write request: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("fail to read response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v, want 200 OK, body: %s", resp.StatusCode, body)
+	}
+	if string(body) != "simple get message" {
+		t.Errorf("got body %q, want %q", body, "simple get message")
+	}
+
+	wantScRequests := []interface{}{
+		&utils.ExpectedCheck{
+			Version:         utils.ESPv2Version(),
+			ServiceName:     "echo-api.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID: "test-config-id",
+			ConsumerID:      "api_key:api-key",
+			OperationName:   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Simpleget",
+			CallerIp:        clientIp,
+		},
+		&utils.ExpectedReport{
+			Version:                      utils.ESPv2Version(),
+			ServiceName:                  "echo-api.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID:              "test-config-id",
+			URL:                          "/simpleget?key=api-key",
+			ApiKeyInOperationAndLogEntry: "api-key",
+			ApiKeyState:                  "VERIFIED",
+			ApiMethod:                    "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Simpleget",
+			ApiName:                      "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+			ApiVersion:                   "1.0.0",
+			ProducerProjectID:            "producer-project",
+			ConsumerProjectID:            "123456",
+			FrontendProtocol:             "http",
+			HttpMethod:                   "GET",
+			LogMessage:                   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Simpleget is called",
+			StatusCode:                   "0",
+			ResponseCode:                 200,
+			Platform:                     util.GCE,
+			Location:                     "test-zone",
+		},
+	}
+
+	scRequests, err := s.ServiceControlServer.GetRequests(len(wantScRequests))
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	utils.CheckScRequest(t, scRequests, wantScRequests, "proxy protocol client address")
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query_parameter_allowlist_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestQueryParameterAllowlist(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestQueryParameterAllowlist, platform.EchoSidecar)
+	defer s.TearDown(t)
+	args := append(utils.CommonArgs(), "--query_parameter_allowlist_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=lang|region")
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc      string
+		query     string
+		wantResp  string
+		wantError string
+	}{
+		{
+			desc:     "Request with the allowed query parameter and API key is passed through",
+			query:    "key=api-key&lang=en",
+			wantResp: `{"message":"hello"}`,
+		},
+		{
+			desc:     "Request with empty query parameters, e.g. a trailing '&', is passed through",
+			query:    "key=api-key&&region=us&",
+			wantResp: `{"message":"hello"}`,
+		},
+		{
+			desc:      "Request with a disallowed query parameter is rejected",
+			query:     "key=api-key&debug=true",
+			wantError: `400 Bad Request, {"code":400,"message":"The current request has a query parameter that is not allowed."}`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			url := fmt.Sprintf("http://%v:%v/echo?%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.query)
+			_, resp, err := utils.DoWithHeaders(url, "POST", "hello", nil)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(resp), tc.wantResp) {
+				t.Errorf("expected response (%v), got (%s)", tc.wantResp, resp)
+			}
+		})
+	}
+}
@@ -216,6 +216,16 @@ func TestReportGCPAttributesPerPlatform(t *testing.T) {
 			wantLocation: "global",
 			wantPlatform: "UNKNOWN(ESPv2)",
 		},
+		{
+			desc: "Overrides platform for non-GCP deployment",
+			confArgs: append([]string{
+				"--non_gcp",
+				"--service_account_key=" + customSa.FileName,
+				"--compute_platform_override=On-Prem",
+			}, utils.CommonArgs()...),
+			wantLocation: "global",
+			wantPlatform: "On-Prem",
+		},
 	}
 
 	for _, tc := range testdata {
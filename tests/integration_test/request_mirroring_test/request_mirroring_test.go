@@ -0,0 +1,81 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package request_mirroring_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+)
+
+type mirroredRequest struct {
+	path string
+	body string
+}
+
+func TestRequestMirroring(t *testing.T) {
+	t.Parallel()
+
+	// The shadow backend records the requests it receives. Its responses must
+	// never reach the client.
+	mirroredRequests := make(chan mirroredRequest, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mirroredRequests <- mirroredRequest{
+			path: r.URL.Path,
+			body: string(body),
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message":"shadow backend response"}`))
+	}))
+	defer shadow.Close()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--mirror_backend_address=" + shadow.URL}
+
+	s := env.NewTestEnv(platform.TestRequestMirroring, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	resp, err := client.DoPost(url, "hello")
+	if err != nil {
+		t.Fatalf("fail to call echo, %v", err)
+	}
+
+	// The client gets the response of the primary backend.
+	if want := `{"message":"hello"}`; string(resp) != want {
+		t.Errorf("got response %s, want %s", resp, want)
+	}
+
+	select {
+	case got := <-mirroredRequests:
+		if got.path != "/echo" || got.body != "hello" {
+			t.Errorf("got mirrored request %+v, want path /echo and body hello", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout waiting for the mirrored request")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package require_request_headers_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestRequireRequestHeaders(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestRequireRequestHeaders, platform.EchoSidecar)
+	defer s.TearDown(t)
+	args := append(utils.CommonArgs(), "--require_request_headers=X-Correlation-Id")
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc      string
+		headers   map[string]string
+		wantResp  string
+		wantError string
+	}{
+		{
+			desc:      "Request without the required header is rejected",
+			wantError: `400 Bad Request, {"code":400,"message":"The current request is missing the required header X-Correlation-Id."}`,
+		},
+		{
+			desc: "Request with the required header is passed through",
+			headers: map[string]string{
+				"X-Correlation-Id": "abc",
+			},
+			wantResp: `{"message":"hello"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			_, resp, err := utils.DoWithHeaders(url, "POST", "hello", tc.headers)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("expected error (%v), got (%v)", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(resp), tc.wantResp) {
+				t.Errorf("expected response (%v), got (%s)", tc.wantResp, resp)
+			}
+		})
+	}
+}
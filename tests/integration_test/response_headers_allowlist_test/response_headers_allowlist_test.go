@@ -0,0 +1,69 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response_headers_allowlist_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestResponseHeadersAllowlist(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--response_headers_allowlist=Echo-Fake-Header-Key0,echo-fake-header-key1"}
+
+	s := env.NewTestEnv(platform.TestResponseHeadersAllowlist, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// The echo backend sends back each Fake-Header-Key request header as an
+	// Echo-Fake-Header-Key response header.
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	header, body, err := utils.DoWithHeaders(url, "POST", "hello", map[string]string{
+		"Fake-Header-Key0": "FakeHeaderVal0",
+		"Fake-Header-Key1": "FakeHeaderVal1",
+		"Fake-Header-Key2": "FakeHeaderVal2",
+	})
+	if err != nil {
+		t.Fatalf("fail to call echo, %v", err)
+	}
+	if want := `{"message":"hello"}`; string(body) != want {
+		t.Errorf("got response body %s, want %s", body, want)
+	}
+
+	wantHeaders := map[string]string{
+		"Echo-Fake-Header-Key0": "FakeHeaderVal0",
+		"Echo-Fake-Header-Key1": "FakeHeaderVal1",
+		"Content-Type":          "text/plain; charset=utf-8",
+	}
+	for key, want := range wantHeaders {
+		if got := header.Get(key); got != want {
+			t.Errorf("got response header %s: %q, want %q", key, got, want)
+		}
+	}
+	for _, key := range []string{"Echo-Fake-Header-Key2", "Echo-Request-Content-Length"} {
+		if got := header.Get(key); got != "" {
+			t.Errorf("response header %s: %q is not in the allowlist and should be removed", key, got)
+		}
+	}
+}
@@ -220,3 +220,57 @@ func TestServiceControlAPIKeyCustomLocation(t *testing.T) {
 		utils.CheckAPIKey(t, scRequests[0], tc.wantApiKey, tc.desc)
 	}
 }
+
+// ESPv2 does not consume the api key, the backend receives it unchanged,
+// unless --remove_api_key_from_request is set.
+func TestServiceControlAPIKeyForwardedToBackend(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc         string
+		removeApiKey bool
+		wantApiKey   string
+		wantQuery    string
+	}{
+		{
+			desc:       "The api key header and query parameter are forwarded by default",
+			wantApiKey: "api-key-in-header",
+			wantQuery:  "key=api-key&lang=en",
+		},
+		{
+			desc:         "The api key header and query parameter are removed with --remove_api_key_from_request",
+			removeApiKey: true,
+			wantQuery:    "lang=en",
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			args := utils.CommonArgs()
+			if tc.removeApiKey {
+				args = append(args, "--remove_api_key_from_request")
+			}
+
+			s := env.NewTestEnv(platform.TestServiceControlAPIKeyForwardedToBackend, platform.EchoSidecar)
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echoHeader?key=api-key&lang=en", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			headers, _, err := utils.DoWithHeaders(url, "GET", "", map[string]string{
+				"x-api-key": "api-key-in-header",
+			})
+			if err != nil {
+				t.Fatalf("Test (%s): fail to make request: %v", tc.desc, err)
+			}
+
+			if got := headers.Get("Echo-X-Api-Key"); got != tc.wantApiKey {
+				t.Errorf("Test (%s): the backend got x-api-key %q, want %q", tc.desc, got, tc.wantApiKey)
+			}
+			if got := headers.Get("Echo-Query"); got != tc.wantQuery {
+				t.Errorf("Test (%s): the backend got query %q, want %q", tc.desc, got, tc.wantQuery)
+			}
+		}()
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_check_delay_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestServiceControlCheckDelay(t *testing.T) {
+	t.Parallel()
+
+	// Check responds long after --service_control_check_timeout, the
+	// requests must not wait for it.
+	const checkDelay = 2 * time.Second
+
+	testData := []struct {
+		desc            string
+		networkFailOpen bool
+		wantResp        string
+		wantError       string
+	}{
+		{
+			desc:      "Check exceeding the timeout fails the request with network fail closed",
+			wantError: `503 Service Unavailable, {"code":503,"message":"UNAVAILABLE:Calling Google Service Control API failed with: 504 and body: upstream request timeout"}`,
+		},
+		{
+			desc:            "Check exceeding the timeout allows the request with network fail open",
+			networkFailOpen: true,
+			wantResp:        `{"message":"hello"}`,
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			args := []string{"--service_config_id=test-config-id", "--rollout_strategy=fixed",
+				"--service_control_check_timeout=100ms", "--service_control_check_retries=0"}
+			s := env.NewTestEnv(platform.TestServiceControlCheckDelay, platform.EchoSidecar)
+			s.SetServiceControlCheckDelay(checkDelay)
+			if tc.networkFailOpen {
+				s.EnableScNetworkFailOpen()
+			}
+
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			start := time.Now()
+			_, resp, err := utils.DoWithHeaders(url, "POST", "hello", nil)
+			if elapsed := time.Since(start); elapsed >= checkDelay/2 {
+				t.Errorf("Test (%s): failed, the request took %v, want it resolved within the Check timeout", tc.desc, elapsed)
+			}
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Errorf("Test (%s): failed, expected err: %v, got: %v", tc.desc, tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+			}
+			if !strings.Contains(string(resp), tc.wantResp) {
+				t.Errorf("Test (%s): failed, expected: %s, got: %s", tc.desc, tc.wantResp, string(resp))
+			}
+		}()
+	}
+}
@@ -148,3 +148,127 @@ func TestServiceControlCredentialId(t *testing.T) {
 		utils.CheckScRequest(t, scRequests, tc.wantScRequests, tc.desc)
 	}
 }
+
+func TestServiceControlCredentialIdPrefix(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers", "--service_control_credential_id_prefix=billing-",
+	}
+	s := env.NewTestEnv(platform.TestServiceControlCredentialIdPrefix, platform.GrpcBookstoreSidecar)
+
+	s.OverrideAuthentication(&confpb.Authentication{Rules: []*confpb.AuthenticationRule{
+		{
+			Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+			Requirements: []*confpb.AuthRequirement{
+				{
+					ProviderId: testdata.GoogleJwtProvider,
+					Audiences:  "bookstore_test_client.cloud.goog",
+				},
+			},
+		},
+	},
+	})
+
+	s.AppendUsageRules([]*confpb.UsageRule{
+		{
+			Selector:               "endpoints.examples.bookstore.Bookstore.ListShelves",
+			AllowUnregisteredCalls: true,
+		},
+	})
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		method         string
+		wantScRequests []interface{}
+	}{
+		{
+			desc:   "success, the jwt credential id is prefixed",
+			method: "/v1/shelves",
+			wantScRequests: []interface{}{
+				&utils.ExpectedReport{
+					Version:             utils.ESPv2Version(),
+					ApiVersion:          "1.0.0",
+					ServiceName:         "bookstore.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID:     "test-config-id",
+					URL:                 "/v1/shelves",
+					ApiKeyState:         "NOT CHECKED",
+					JwtAuthCredentialId: "issuer=YXBpLXByb3h5LXRlc3RpbmdAY2xvdWQuZ29vZw&audience=Ym9va3N0b3JlX3Rlc3RfY2xpZW50LmNsb3VkLmdvb2c",
+					CredentialIdPrefix:  "billing-",
+					ApiMethod:           "endpoints.examples.bookstore.Bookstore.ListShelves",
+					ApiName:             "endpoints.examples.bookstore.Bookstore",
+					ProducerProjectID:   "producer project",
+					FrontendProtocol:    "http",
+					BackendProtocol:     "grpc",
+					HttpMethod:          "GET",
+					LogMessage:          "endpoints.examples.bookstore.Bookstore.ListShelves is called",
+					StatusCode:          "0",
+					ResponseCode:        200,
+					Platform:            util.GCE,
+					Location:            "test-zone",
+				},
+			},
+		},
+		{
+			desc:   "success, the api key credential id is prefixed",
+			method: "/v1/shelves?key=api-key",
+			wantScRequests: []interface{}{
+				&utils.ExpectedCheck{
+					Version:         utils.ESPv2Version(),
+					ServiceName:     "bookstore.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID: "test-config-id",
+					ConsumerID:      "api_key:api-key",
+					OperationName:   "endpoints.examples.bookstore.Bookstore.ListShelves",
+					CallerIp:        platform.GetLoopbackAddress(),
+				},
+				&utils.ExpectedReport{
+					Version:                      utils.ESPv2Version(),
+					ApiVersion:                   "1.0.0",
+					ServiceName:                  "bookstore.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID:              "test-config-id",
+					URL:                          "/v1/shelves?key=api-key",
+					ApiKeyInOperationAndLogEntry: "api-key",
+					ApiKeyState:                  "VERIFIED",
+					CredentialIdPrefix:           "billing-",
+					ApiMethod:                    "endpoints.examples.bookstore.Bookstore.ListShelves",
+					ApiName:                      "endpoints.examples.bookstore.Bookstore",
+					ProducerProjectID:            "producer project",
+					ConsumerProjectID:            "123456",
+					FrontendProtocol:             "http",
+					BackendProtocol:              "grpc",
+					HttpMethod:                   "GET",
+					LogMessage:                   "endpoints.examples.bookstore.Bookstore.ListShelves is called",
+					StatusCode:                   "0",
+					ResponseCode:                 200,
+					Platform:                     util.GCE,
+					Location:                     "test-zone",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+		resp, err := bsClient.MakeCall("http", addr, "GET", tc.method, testdata.FakeCloudTokenSingleAudience1, http.Header{})
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+		wantResp := `{"shelves":[{"id":"100","theme":"Kids"},{"id":"200","theme":"Classic"}]}`
+		if !strings.Contains(string(resp), wantResp) {
+			t.Errorf("Test (%s): failed,  expected: %s, got: %s", tc.desc, wantResp, string(resp))
+		}
+
+		scRequests, err1 := s.ServiceControlServer.GetRequests(len(tc.wantScRequests))
+		if err1 != nil {
+			t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err1)
+		}
+		utils.CheckScRequest(t, scRequests, tc.wantScRequests, tc.desc)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_latency_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+const (
+	backendLatenciesMetric  = "serviceruntime.googleapis.com/api/producer/backend_latencies"
+	overheadLatenciesMetric = "serviceruntime.googleapis.com/api/producer/request_overhead_latencies"
+	totalLatenciesMetric    = "serviceruntime.googleapis.com/api/producer/total_latencies"
+)
+
+func TestServiceControlBackendLatency(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--service_config_id=test-config-id",
+		"--rollout_strategy=fixed", "--suppress_envoy_headers"}
+
+	s := env.NewTestEnv(platform.TestServiceControlBackendLatency, platform.EchoSidecar)
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc         string
+		backendDelay time.Duration
+	}{
+		{
+			desc:         "backend latency reflects a short delay",
+			backendDelay: 200 * time.Millisecond,
+		},
+		{
+			desc:         "backend latency reflects a long delay",
+			backendDelay: 1 * time.Second,
+		},
+	}
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v/sleep?duration=%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.backendDelay)
+		if _, err := client.DoWithHeaders(url, "GET", "", nil); err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+
+		scRequests, err := s.ServiceControlServer.GetRequests(1)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err)
+		}
+		if scRequests[0].ReqType != utils.ReportRequest {
+			t.Fatalf("Test (%s): failed, service control request should be Report", tc.desc)
+		}
+		report, err := utils.UnmarshalReportRequest(scRequests[0].ReqBody)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+
+		latencies := make(map[string]float64)
+		for _, metricValueSet := range report.GetOperations()[0].GetMetricValueSets() {
+			for _, metricValue := range metricValueSet.GetMetricValues() {
+				latencies[metricValueSet.GetMetricName()] = metricValue.GetDistributionValue().GetMean()
+			}
+		}
+
+		// Latencies are reported in seconds.
+		backendLatency := latencies[backendLatenciesMetric]
+		if backendLatency < tc.backendDelay.Seconds() {
+			t.Errorf("Test (%s): failed, got backend latency %vs, want at least %vs", tc.desc, backendLatency, tc.backendDelay.Seconds())
+		}
+		if _, ok := latencies[overheadLatenciesMetric]; !ok {
+			t.Errorf("Test (%s): failed, overhead latency is not reported", tc.desc)
+		}
+		if totalLatency := latencies[totalLatenciesMetric]; totalLatency < backendLatency {
+			t.Errorf("Test (%s): failed, got total latency %vs less than backend latency %vs", tc.desc, totalLatency, backendLatency)
+		}
+	}
+}
@@ -16,6 +16,8 @@ package service_control_log_test
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
@@ -29,6 +31,7 @@ import (
 
 	bsClient "github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/client"
 	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+	scpb "google.golang.org/genproto/googleapis/api/servicecontrol/v1"
 )
 
 func TestServiceControlLogHeaders(t *testing.T) {
@@ -138,6 +141,89 @@ func TestServiceControlLogHeaders(t *testing.T) {
 	}
 }
 
+func TestServiceControlExtraLabels(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers", "--service_control_extra_labels=X-Client-Version=/client_version"}
+
+	s := env.NewTestEnv(platform.TestServiceControlExtraLabels, platform.EchoSidecar)
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc            string
+		requestHeader   map[string]string
+		wantExtraLabels map[string]string
+	}{
+		{
+			desc: "succeed, the header is reported as a label",
+			requestHeader: map[string]string{
+				"X-Client-Version": "1.2.3",
+			},
+			wantExtraLabels: map[string]string{
+				"/client_version": "1.2.3",
+			},
+		},
+		{
+			desc: "succeed, the label is omitted if the header is missing",
+		},
+	}
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echo", "?key=api-key-2")
+		resp, err := client.DoPostWithHeaders(url, "hello", tc.requestHeader)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+		if !strings.Contains(string(resp), `{"message":"hello"}`) {
+			t.Errorf("Test (%s): failed,  expected: %s, got: %s", tc.desc, `{"message":"hello"}`, string(resp))
+		}
+
+		wantScRequests := []interface{}{
+			&utils.ExpectedCheck{
+				Version:         utils.ESPv2Version(),
+				ServiceName:     "echo-api.endpoints.cloudesf-testing.cloud.goog",
+				ServiceConfigID: "test-config-id",
+				ConsumerID:      "api_key:api-key-2",
+				OperationName:   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+				CallerIp:        platform.GetLoopbackAddress(),
+			},
+			&utils.ExpectedReport{
+				Version:                      utils.ESPv2Version(),
+				ServiceName:                  "echo-api.endpoints.cloudesf-testing.cloud.goog",
+				ServiceConfigID:              "test-config-id",
+				URL:                          "/echo?key=api-key-2",
+				ApiKeyInOperationAndLogEntry: "api-key-2",
+				ApiKeyState:                  "VERIFIED",
+				ApiMethod:                    "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+				ApiVersion:                   "1.0.0",
+				ApiName:                      "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+				ProducerProjectID:            "producer-project",
+				ConsumerProjectID:            "123456",
+				FrontendProtocol:             "http",
+				HttpMethod:                   "POST",
+				LogMessage:                   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo is called",
+				StatusCode:                   "0",
+				ResponseCode:                 200,
+				Platform:                     util.GCE,
+				Location:                     "test-zone",
+				ExtraLabels:                  tc.wantExtraLabels,
+			},
+		}
+
+		scRequests, err1 := s.ServiceControlServer.GetRequests(len(wantScRequests))
+		if err1 != nil {
+			t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err1)
+		}
+		utils.CheckScRequest(t, scRequests, wantScRequests, tc.desc)
+	}
+}
+
 func TestServiceControlLogJwtPayloads(t *testing.T) {
 	t.Parallel()
 
@@ -256,3 +342,331 @@ func TestServiceControlLogJwtPayloads(t *testing.T) {
 		utils.CheckScRequest(t, scRequests, tc.wantScRequests, tc.desc)
 	}
 }
+
+func TestServiceControlJwtClaimLabels(t *testing.T) {
+	t.Parallel()
+
+	serviceName := "test-bookstore"
+	configId := "test-config-id"
+
+	args := []string{"--service=" + serviceName, "--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers", "--service_control_jwt_claim_labels=sub=/jwt_sub,iss=/jwt_iss,email=/jwt_email",
+	}
+
+	s := env.NewTestEnv(platform.TestServiceControlJwtClaimLabels, platform.GrpcBookstoreSidecar)
+	s.OverrideAuthentication(&confpb.Authentication{
+		Rules: []*confpb.AuthenticationRule{
+			{
+				Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: testdata.ServiceControlProvider,
+						Audiences:  "ok_audience_1",
+					},
+				},
+			},
+		},
+	})
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		token          string
+		wantScRequests []interface{}
+	}{
+		{
+			// The token carries no "email" claim, so that label is omitted.
+			desc:  "succeed, string claims are reported as labels",
+			token: testdata.ServiceControlJwtPayloadToken,
+			wantScRequests: []interface{}{
+				&utils.ExpectedCheck{
+					Version:         utils.ESPv2Version(),
+					ServiceName:     "bookstore.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID: "test-config-id",
+					ConsumerID:      "api_key:api-key",
+					OperationName:   "endpoints.examples.bookstore.Bookstore.ListShelves",
+					CallerIp:        platform.GetLoopbackAddress(),
+				},
+				&utils.ExpectedReport{
+					Version:                      utils.ESPv2Version(),
+					ServiceName:                  "bookstore.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID:              "test-config-id",
+					URL:                          "/v1/shelves?key=api-key",
+					ApiKeyInOperationAndLogEntry: "api-key",
+					ApiKeyState:                  "VERIFIED",
+					ApiMethod:                    "endpoints.examples.bookstore.Bookstore.ListShelves",
+					ApiVersion:                   "1.0.0",
+					ApiName:                      "endpoints.examples.bookstore.Bookstore",
+					ProducerProjectID:            "producer project",
+					ConsumerProjectID:            "123456",
+					FrontendProtocol:             "http",
+					BackendProtocol:              "grpc",
+					HttpMethod:                   "GET",
+					LogMessage:                   "endpoints.examples.bookstore.Bookstore.ListShelves is called",
+					StatusCode:                   "0",
+					ResponseCode:                 200,
+					Platform:                     util.GCE,
+					Location:                     "test-zone",
+					ExtraLabels: map[string]string{
+						"/jwt_sub": "es256-issuer",
+						"/jwt_iss": "es256-issuer",
+					},
+				},
+			},
+		},
+		{
+			desc:  "succeed, the email claim is reported as a label",
+			token: testdata.ServiceControlJwtClaimsToken,
+			wantScRequests: []interface{}{
+				&utils.ExpectedReport{
+					Version:                      utils.ESPv2Version(),
+					ServiceName:                  "bookstore.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID:              "test-config-id",
+					URL:                          "/v1/shelves?key=api-key",
+					ApiKeyInOperationAndLogEntry: "api-key",
+					ApiKeyState:                  "VERIFIED",
+					ApiMethod:                    "endpoints.examples.bookstore.Bookstore.ListShelves",
+					ApiVersion:                   "1.0.0",
+					ApiName:                      "endpoints.examples.bookstore.Bookstore",
+					ProducerProjectID:            "producer project",
+					ConsumerProjectID:            "123456",
+					FrontendProtocol:             "http",
+					BackendProtocol:              "grpc",
+					HttpMethod:                   "GET",
+					LogMessage:                   "endpoints.examples.bookstore.Bookstore.ListShelves is called",
+					StatusCode:                   "0",
+					ResponseCode:                 200,
+					Platform:                     util.GCE,
+					Location:                     "test-zone",
+					ExtraLabels: map[string]string{
+						"/jwt_sub":   "es256-issuer",
+						"/jwt_iss":   "es256-issuer",
+						"/jwt_email": "es256-issuer@example.com",
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testData {
+		addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+		resp, err := bsClient.MakeCall("http", addr, "GET", "/v1/shelves?key=api-key", tc.token, http.Header{})
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+		wantResp := `{"shelves":[{"id":"100","theme":"Kids"},{"id":"200","theme":"Classic"}]}`
+		if !strings.Contains(string(resp), wantResp) {
+			t.Errorf("Test (%s): failed,  expected: %s, got: %s", tc.desc, wantResp, string(resp))
+		}
+
+		scRequests, err1 := s.ServiceControlServer.GetRequests(len(tc.wantScRequests))
+		if err1 != nil {
+			t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err1)
+		}
+		utils.CheckScRequest(t, scRequests, tc.wantScRequests, tc.desc)
+	}
+}
+
+func TestServiceControlLogEntryFields(t *testing.T) {
+	t.Parallel()
+
+	serviceName := "test-bookstore"
+	configId := "test-config-id"
+
+	args := []string{"--service=" + serviceName, "--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers", "--log_entry_fields=consumer_project_number,http_method,request_latency_in_ms,user_agent",
+	}
+
+	s := env.NewTestEnv(platform.TestServiceControlLogEntryFields, platform.GrpcBookstoreSidecar)
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+	// The project number differs from the consumer number, so that the
+	// reported one can be told apart.
+	s.ServiceControlServer.SetCheckResponse(&scpb.CheckResponse{
+		CheckInfo: &scpb.CheckResponse_CheckInfo{
+			ConsumerInfo: &scpb.CheckResponse_ConsumerInfo{
+				ProjectNumber:  987654,
+				ConsumerNumber: 123456,
+				Type:           scpb.CheckResponse_ConsumerInfo_PROJECT,
+			},
+		},
+	})
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	header := http.Header{
+		"User-Agent": []string{"bookstore-client/1.0"},
+	}
+	resp, err := bsClient.MakeCall("http", addr, "GET", "/v1/shelves?key=api-key", "", header)
+	if err != nil {
+		t.Fatalf("fail to make call, %v", err)
+	}
+	wantResp := `{"shelves":[{"id":"100","theme":"Kids"},{"id":"200","theme":"Classic"}]}`
+	if !strings.Contains(string(resp), wantResp) {
+		t.Errorf("expected: %s, got: %s", wantResp, string(resp))
+	}
+
+	wantScRequests := []interface{}{
+		&utils.ExpectedCheck{
+			Version:         utils.ESPv2Version(),
+			ServiceName:     "bookstore.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID: "test-config-id",
+			ConsumerID:      "api_key:api-key",
+			OperationName:   "endpoints.examples.bookstore.Bookstore.ListShelves",
+			CallerIp:        platform.GetLoopbackAddress(),
+		},
+		&utils.ExpectedReport{
+			Version:                      utils.ESPv2Version(),
+			ServiceName:                  "bookstore.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID:              "test-config-id",
+			URL:                          "/v1/shelves?key=api-key",
+			ApiKeyInOperationAndLogEntry: "api-key",
+			ApiKeyState:                  "VERIFIED",
+			ApiMethod:                    "endpoints.examples.bookstore.Bookstore.ListShelves",
+			ApiVersion:                   "1.0.0",
+			ApiName:                      "endpoints.examples.bookstore.Bookstore",
+			ProducerProjectID:            "producer project",
+			ConsumerProjectID:            "987654",
+			FrontendProtocol:             "http",
+			BackendProtocol:              "grpc",
+			HttpMethod:                   "GET",
+			LogMessage:                   "endpoints.examples.bookstore.Bookstore.ListShelves is called",
+			StatusCode:                   "0",
+			ResponseCode:                 200,
+			Platform:                     util.GCE,
+			Location:                     "test-zone",
+			LogEntryFields: map[string]string{
+				"consumer_project_number": "987654",
+				"http_method":             "GET",
+				"user_agent":              "bookstore-client/1.0",
+			},
+		},
+	}
+
+	scRequests, err := s.ServiceControlServer.GetRequests(len(wantScRequests))
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	utils.CheckScRequest(t, scRequests, wantScRequests, "log entry fields")
+}
+
+func TestServiceControlReportSizes(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--service_config_id=test-config-id",
+		"--rollout_strategy=fixed", "--suppress_envoy_headers"}
+
+	s := env.NewTestEnv(platform.TestServiceControlReportSizes, platform.EchoSidecar)
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// Headers are counted in the reported sizes, allow some room for them.
+	const headerTolerance = 1024
+	// Each case uses its own API key, so the Check is not served from the
+	// cache and every request sends both a Check and a Report.
+	testData := []struct {
+		desc     string
+		apiKey   string
+		bodySize int
+		chunked  bool
+	}{
+		{
+			desc:     "small body",
+			apiKey:   "api-key-small",
+			bodySize: 100,
+		},
+		{
+			desc:     "large body streamed in multiple chunks",
+			apiKey:   "api-key-chunked",
+			bodySize: 1024 * 1024,
+			chunked:  true,
+		},
+	}
+	for _, tc := range testData {
+		message := strings.Repeat("a", tc.bodySize)
+		url := fmt.Sprintf("http://%v:%v%v?key=%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echo", tc.apiKey)
+		body := fmt.Sprintf(`{"message":"%s"}`, message)
+		var resp []byte
+		var err error
+		if tc.chunked {
+			resp, err = doChunkedPost(url, body)
+		} else {
+			resp, err = client.DoPost(url, message)
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+
+		// The request body is the JSON encoded message, the response is echoed.
+		wantRequestSize := int64(len(body))
+		wantResponseSize := int64(len(resp))
+
+		scRequests, err := s.ServiceControlServer.GetRequests(2)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err)
+		}
+		if scRequests[1].ReqType != utils.ReportRequest {
+			t.Fatalf("Test (%s): failed, service control request %v is not a report", tc.desc, scRequests[1])
+		}
+		report, err := utils.UnmarshalReportRequest(scRequests[1].ReqBody)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+
+		httpRequest := report.GetOperations()[0].GetLogEntries()[0].GetHttpRequest()
+		if got := httpRequest.GetRequestSize(); got < wantRequestSize || got > wantRequestSize+headerTolerance {
+			t.Errorf("Test (%s): failed, got request size %v, want %v plus headers", tc.desc, got, wantRequestSize)
+		}
+		if got := httpRequest.GetResponseSize(); got < wantResponseSize || got > wantResponseSize+headerTolerance {
+			t.Errorf("Test (%s): failed, got response size %v, want %v plus headers", tc.desc, got, wantResponseSize)
+		}
+	}
+}
+
+// doChunkedPost sends the body through a pipe, so its length is unknown
+// to the client and the request uses chunked transfer encoding.
+func doChunkedPost(url, body string) ([]byte, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		const chunkSize = 64 * 1024
+		for i := 0; i < len(body); i += chunkSize {
+			end := i + chunkSize
+			if end > len(body) {
+				end = len(body)
+			}
+			if _, err := io.WriteString(pw, body[i:end]); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	req, err := http.NewRequest("POST", url, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http response status is not 200 OK: %s, %s", resp.Status, respBody)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_operation_name_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestServiceControlOperationName(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers",
+		"--service_control_operation_name_strip_prefix=1.echo_api_endpoints_cloudesf_testing_cloud_goog."}
+
+	s := env.NewTestEnv(platform.TestServiceControlOperationName, platform.EchoSidecar)
+	s.EnableEchoServerRootPathHandler()
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		url            string
+		method         string
+		wantResp       string
+		wantScRequests []interface{}
+	}{
+		{
+			desc:     "Succeed, the reported operation name is stripped while the request is still routed to the backend",
+			url:      fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/"),
+			method:   "GET",
+			wantResp: `{"RequestURI": "/"}`,
+			wantScRequests: []interface{}{
+				&utils.ExpectedReport{
+					ApiName:           "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+					Version:           utils.ESPv2Version(),
+					ServiceName:       "echo-api.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID:   "test-config-id",
+					URL:               "/",
+					ApiMethod:         "Root",
+					ApiKeyState:       "NOT CHECKED",
+					ApiVersion:        "1.0.0",
+					ProducerProjectID: "producer-project",
+					FrontendProtocol:  "http",
+					HttpMethod:        "GET",
+					LogMessage:        "Root is called",
+					StatusCode:        "0",
+					ResponseCode:      200,
+					Platform:          util.GCE,
+					Location:          "test-zone",
+				},
+			},
+		},
+		{
+			desc:     "Succeed, the Check call keeps the full operation name while the Report call strips it",
+			url:      fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/simpleget?key=api-key"),
+			method:   "GET",
+			wantResp: `simple get message`,
+			wantScRequests: []interface{}{
+				&utils.ExpectedCheck{
+					Version:         utils.ESPv2Version(),
+					ServiceName:     "echo-api.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID: "test-config-id",
+					ConsumerID:      "api_key:api-key",
+					OperationName:   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Simpleget",
+					CallerIp:        platform.GetLoopbackAddress(),
+				},
+				&utils.ExpectedReport{
+					Version:                      utils.ESPv2Version(),
+					ServiceName:                  "echo-api.endpoints.cloudesf-testing.cloud.goog",
+					ServiceConfigID:              "test-config-id",
+					URL:                          "/simpleget?key=api-key",
+					ApiKeyInOperationAndLogEntry: "api-key",
+					ApiKeyState:                  "VERIFIED",
+					ApiMethod:                    "Simpleget",
+					ApiName:                      "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+					ApiVersion:                   "1.0.0",
+					ProducerProjectID:            "producer-project",
+					ConsumerProjectID:            "123456",
+					FrontendProtocol:             "http",
+					HttpMethod:                   "GET",
+					LogMessage:                   "Simpleget is called",
+					StatusCode:                   "0",
+					ResponseCode:                 200,
+					Platform:                     util.GCE,
+					Location:                     "test-zone",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		resp, err := client.DoWithHeaders(tc.url, tc.method, "", nil)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+		if !strings.Contains(string(resp), tc.wantResp) {
+			t.Errorf("Test (%s): failed,  expected: %s, got: %s", tc.desc, tc.wantResp, string(resp))
+		}
+
+		scRequests, err1 := s.ServiceControlServer.GetRequests(len(tc.wantScRequests))
+		if err1 != nil {
+			t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err1)
+		}
+		utils.CheckScRequest(t, scRequests, tc.wantScRequests, tc.desc)
+	}
+}
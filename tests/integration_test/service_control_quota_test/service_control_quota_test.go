@@ -195,6 +195,65 @@ func TestServiceControlQuota(t *testing.T) {
 	}
 }
 
+func TestServiceControlQuotaMultipleMetricRules(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers"}
+
+	s := env.NewTestEnv(platform.TestServiceControlQuotaMultipleMetricRules, platform.GrpcBookstoreSidecar)
+	s.OverrideQuota(&confpb.Quota{
+		MetricRules: []*confpb.MetricRule{
+			{
+				Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+				MetricCosts: map[string]int64{
+					"reads": 1,
+				},
+			},
+			{
+				Selector: "endpoints.examples.bookstore.Bookstore.ListShelves",
+				MetricCosts: map[string]int64{
+					"compute_units": 5,
+				},
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	resp, err := bsClient.MakeCall("http", addr, "GET", "/v1/shelves?key=api-key", testdata.FakeCloudTokenMultiAudiences, http.Header{})
+	if err != nil {
+		t.Fatalf("fail to make call, %v", err)
+	}
+	wantResp := `{"shelves":[{"id":"100","theme":"Kids"},{"id":"200","theme":"Classic"}]}`
+	if !strings.Contains(string(resp), wantResp) {
+		t.Errorf("expected: %s, got: %s", wantResp, string(resp))
+	}
+
+	scRequests, err := s.ServiceControlServer.GetRequests(3)
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	utils.CheckScRequest(t, scRequests[1:2], []interface{}{
+		&utils.ExpectedQuota{
+			ServiceName: "bookstore.endpoints.cloudesf-testing.cloud.goog",
+			MethodName:  "endpoints.examples.bookstore.Bookstore.ListShelves",
+			ConsumerID:  "api_key:api-key",
+			QuotaMetrics: map[string]int64{
+				"compute_units": 5,
+				"reads":         1,
+			},
+			QuotaMode:       scpb.QuotaOperation_BEST_EFFORT,
+			ServiceConfigID: "test-config-id",
+		},
+	}, "both metrics from multiple metric rules are allocated")
+}
+
 type unavailableQuotaServiceHandler struct {
 	m *comp.MockServiceCtrl
 }
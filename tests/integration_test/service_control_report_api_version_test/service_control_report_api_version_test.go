@@ -0,0 +1,144 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_report_api_version_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestServiceControlReportApiVersion(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers",
+		"--service_control_report_api_version_from_path"}
+
+	s := env.NewTestEnv(platform.TestServiceControlReportApiVersion, platform.EchoSidecar)
+	s.EnableEchoServerRootPathHandler()
+	s.AppendHttpRules([]*annotationspb.HttpRule{
+		{
+			Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoGET",
+			Pattern: &annotationspb.HttpRule_Get{
+				Get: "/v1/echoMethod",
+			},
+		},
+		{
+			Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoPOST",
+			Pattern: &annotationspb.HttpRule_Post{
+				Post: "/v2/echoMethod",
+			},
+		},
+	})
+
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		path           string
+		method         string
+		operation      string
+		wantResp       string
+		wantApiVersion string
+	}{
+		{
+			desc:           "Succeed, the version v1 of the path is reported",
+			path:           "/v1/echoMethod",
+			method:         "GET",
+			operation:      "echoGET",
+			wantResp:       `{"RequestURI": "/v1/echoMethod?key=api-key"}`,
+			wantApiVersion: "v1",
+		},
+		{
+			desc:           "Succeed, the version v2 of the path is reported",
+			path:           "/v2/echoMethod",
+			method:         "POST",
+			operation:      "echoPOST",
+			wantResp:       `{"RequestURI": "/v2/echoMethod?key=api-key"}`,
+			wantApiVersion: "v2",
+		},
+		{
+			desc:           "Succeed, the version of the API is reported for a path without version",
+			path:           "/echoMethod",
+			method:         "GET",
+			operation:      "echoGET",
+			wantResp:       `{"RequestMethod": "GET"}`,
+			wantApiVersion: "1.0.0",
+		},
+	}
+
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v%v?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.path)
+		resp, err := client.DoWithHeaders(url, tc.method, "", nil)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+		if !strings.Contains(string(resp), tc.wantResp) {
+			t.Errorf("Test (%s): failed,  expected: %s, got: %s", tc.desc, tc.wantResp, string(resp))
+		}
+
+		operationName := "1.echo_api_endpoints_cloudesf_testing_cloud_goog." + tc.operation
+		wantScRequests := []interface{}{
+			&utils.ExpectedCheck{
+				Version:         utils.ESPv2Version(),
+				ServiceName:     "echo-api.endpoints.cloudesf-testing.cloud.goog",
+				ServiceConfigID: "test-config-id",
+				ConsumerID:      "api_key:api-key",
+				OperationName:   operationName,
+				CallerIp:        platform.GetLoopbackAddress(),
+			},
+			&utils.ExpectedReport{
+				Version:                      utils.ESPv2Version(),
+				ServiceName:                  "echo-api.endpoints.cloudesf-testing.cloud.goog",
+				ServiceConfigID:              "test-config-id",
+				URL:                          tc.path + "?key=api-key",
+				ApiKeyInOperationAndLogEntry: "api-key",
+				ApiKeyState:                  "VERIFIED",
+				ApiMethod:                    operationName,
+				ApiName:                      "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+				ApiVersion:                   tc.wantApiVersion,
+				ProducerProjectID:            "producer-project",
+				ConsumerProjectID:            "123456",
+				FrontendProtocol:             "http",
+				HttpMethod:                   tc.method,
+				LogMessage:                   operationName + " is called",
+				StatusCode:                   "0",
+				ResponseCode:                 200,
+				Platform:                     util.GCE,
+				Location:                     "test-zone",
+			},
+		}
+
+		scRequests, err1 := s.ServiceControlServer.GetRequests(len(wantScRequests))
+		if err1 != nil {
+			t.Fatalf("Test (%s): failed, GetRequests returns error: %v", tc.desc, err1)
+		}
+		utils.CheckScRequest(t, scRequests, wantScRequests, tc.desc)
+	}
+}
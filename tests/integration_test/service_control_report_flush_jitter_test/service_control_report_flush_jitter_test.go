@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_report_flush_jitter_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+	"github.com/golang/protobuf/proto"
+
+	scpb "google.golang.org/genproto/googleapis/api/servicecontrol/v1"
+)
+
+// reportTimeHandler records the arrival time of each Report request.
+type reportTimeHandler struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (h *reportTimeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.times = append(h.times, time.Now())
+	h.mu.Unlock()
+
+	body, _ := proto.Marshal(&scpb.ReportResponse{})
+	_, _ = w.Write(body)
+}
+
+// flushIntervals groups the Report requests sent by the same flush and
+// returns the intervals between the flushes.
+func (h *reportTimeHandler) flushIntervals() []time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var flushes []time.Time
+	for _, t := range h.times {
+		if len(flushes) == 0 || t.Sub(flushes[len(flushes)-1]) > 200*time.Millisecond {
+			flushes = append(flushes, t)
+		}
+	}
+
+	var intervals []time.Duration
+	for i := 1; i < len(flushes); i++ {
+		intervals = append(intervals, flushes[i].Sub(flushes[i-1]))
+	}
+	return intervals
+}
+
+func TestServiceControlReportFlushJitter(t *testing.T) {
+	t.Parallel()
+
+	args := append(utils.CommonArgs(), "--service_control_report_flush_jitter_ms=1000")
+
+	s := env.NewTestEnv(platform.TestServiceControlReportFlushJitter, platform.EchoSidecar)
+	handler := &reportTimeHandler{}
+	s.ServiceControlServer.OverrideReportHandler(handler)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// Keep sending requests, so every flush has reports to send.
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := client.DoWithHeaders(url, "POST", "hello", nil); err != nil {
+			t.Fatalf("fail to make request, %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// Wait for the last flush.
+	time.Sleep(2500 * time.Millisecond)
+
+	// The reports are flushed every second plus a random jitter of up to a
+	// second. Without the jitter, the intervals would all be about a second.
+	intervals := handler.flushIntervals()
+	if len(intervals) < 4 {
+		t.Fatalf("got %d flush intervals %v, want at least 4", len(intervals), intervals)
+	}
+	minInterval, maxInterval := intervals[0], intervals[0]
+	for _, interval := range intervals {
+		if interval < minInterval {
+			minInterval = interval
+		}
+		if interval > maxInterval {
+			maxInterval = interval
+		}
+	}
+	if maxInterval-minInterval < 200*time.Millisecond {
+		t.Errorf("flush intervals %v are not jittered", intervals)
+	}
+}
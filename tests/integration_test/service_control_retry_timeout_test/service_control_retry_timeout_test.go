@@ -259,3 +259,134 @@ func TestServiceControlReportRetry(t *testing.T) {
 		}
 	}
 }
+
+func TestServiceControlReportRetryWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	serviceName := "bookstore-service"
+	configID := "test-config-id"
+	backoff := 200 * time.Millisecond
+	args := []string{
+		"--service=" + serviceName,
+		"--service_config_id=" + configID,
+
+		"--rollout_strategy=fixed",
+		"--service_control_report_retries=3",
+		// The retries are made 200ms, 400ms and 800ms after each failure.
+		fmt.Sprintf("--service_control_report_retry_backoff_ms=%v", backoff.Milliseconds()),
+	}
+	s := env.NewTestEnv(platform.TestServiceControlReportRetryWithBackoff, platform.GrpcBookstoreSidecar)
+
+	handler := utils.FailingServiceHandler{
+		FailStatusCode: 503,
+		RequestTimes:   make(chan time.Time, 10),
+	}
+	s.ServiceControlServer.OverrideReportHandler(&handler)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	tests := []struct {
+		desc                    string
+		method                  string
+		failTimes               int32
+		wantHandlerRequestCount int
+		wantStat                string
+	}{
+		{
+			desc:                    "The report is delivered after transient failures",
+			method:                  "/v1/shelves?key=api-key-0",
+			failTimes:               2,
+			wantHandlerRequestCount: 3,
+			wantStat:                "http.ingress_http.service_control.report.OK",
+		},
+		{
+			desc:                    "The report is dropped after exhausting the retries",
+			method:                  "/v1/shelves/200?key=api-key-1",
+			failTimes:               10,
+			wantHandlerRequestCount: 4,
+			wantStat:                "http.ingress_http.service_control.report.UNAVAILABLE",
+		},
+	}
+
+	for _, tc := range tests {
+		handler.RequestCount = 0
+		handler.FailTimes = tc.failTimes
+
+		addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+		_, _ = bsclient.MakeCall("http", addr, "GET", tc.method, "", nil)
+
+		var requestTimes []time.Time
+		for len(requestTimes) < tc.wantHandlerRequestCount {
+			select {
+			case requestTime := <-handler.RequestTimes:
+				requestTimes = append(requestTimes, requestTime)
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Test (%s): failed, got %v report requests, want %v", tc.desc, len(requestTimes), tc.wantHandlerRequestCount)
+			}
+		}
+
+		// Each retry waits at least twice as long as the previous one.
+		wantGap := backoff
+		for i := 1; i < len(requestTimes); i++ {
+			if gap := requestTimes[i].Sub(requestTimes[i-1]); gap < wantGap {
+				t.Errorf("Test (%s): failed, retry %v was made %v after the previous call, want at least %v", tc.desc, i, gap, wantGap)
+			}
+			wantGap *= 2
+		}
+
+		// The report call finishes once, with the status of its last attempt.
+		if err := s.StatsVerifier.ExpectStat(tc.wantStat, func(val int) bool { return val == 1 }); err != nil {
+			t.Errorf("Test (%s): failed, %v", tc.desc, err)
+		}
+	}
+}
+
+func TestServiceControlMaxPendingReports(t *testing.T) {
+	t.Parallel()
+
+	serviceName := "bookstore-service"
+	configID := "test-config-id"
+	args := []string{
+		"--service=" + serviceName,
+		"--service_config_id=" + configID,
+
+		"--rollout_strategy=fixed",
+		"--service_control_report_retries=0",
+		"--service_control_report_timeout_ms=10000",
+		// Only one report can be in flight at a time.
+		"--service_control_max_pending_reports=1",
+	}
+	s := env.NewTestEnv(platform.TestServiceControlMaxPendingReports, platform.GrpcBookstoreSidecar)
+
+	// The first report stays in flight until it is released.
+	handler := utils.BlockingServiceHandler{
+		Received: make(chan struct{}, 10),
+		Release:  make(chan struct{}),
+	}
+	s.ServiceControlServer.OverrideReportHandler(&handler)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+	defer close(handler.Release)
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	_, _ = bsclient.MakeCall("http", addr, "GET", "/v1/shelves?key=api-key-0", "", nil)
+	select {
+	case <-handler.Received:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the first report was not sent")
+	}
+
+	// The report of another request is dropped while the first one is still in
+	// flight.
+	_, _ = bsclient.MakeCall("http", addr, "GET", "/v1/shelves/200?key=api-key-1", "", nil)
+	if err := s.StatsVerifier.ExpectStat("http.ingress_http.service_control.report.RESOURCE_EXHAUSTED", func(val int) bool { return val == 1 }); err != nil {
+		t.Error(err)
+	}
+	if handler.RequestCount != 1 {
+		t.Errorf("expected report request count: 1, got: %v", handler.RequestCount)
+	}
+}
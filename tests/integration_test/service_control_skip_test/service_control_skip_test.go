@@ -90,3 +90,65 @@ func TestServiceControlSkipUsage(t *testing.T) {
 		}
 	}
 }
+
+func TestServiceControlSkipPath(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--suppress_envoy_headers",
+		"--skip_service_control_paths=/echoMethod/internal/health"}
+
+	s := env.NewTestEnv(platform.TestServiceControlSkipPath, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc               string
+		url                string
+		method             string
+		wantResp           string
+		wantScRequestCount int
+	}{
+		{
+			desc:               "succeed, just show the service control works for normal request",
+			url:                fmt.Sprintf("http://%v:%v%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/simplegetcors", "?key=api-key"),
+			method:             "GET",
+			wantResp:           `simple get message`,
+			wantScRequestCount: 2,
+		},
+		{
+			desc:               "succeed, the path to skip service control reaches the backend without api key",
+			url:                fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echoMethod/internal/health"),
+			method:             "GET",
+			wantResp:           `{"RequestMethod": "GET"}`,
+			wantScRequestCount: 0,
+		},
+		{
+			desc:               "succeed, the path to skip service control matches any http method",
+			url:                fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, "/echoMethod/internal/health"),
+			method:             "DELETE",
+			wantResp:           `{"RequestMethod": "DELETE"}`,
+			wantScRequestCount: 0,
+		},
+	}
+	for _, tc := range testData {
+		s.ServiceControlServer.ResetRequestCount()
+		resp, err := client.DoWithHeaders(tc.url, tc.method, "", nil)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+
+		if !strings.Contains(string(resp), tc.wantResp) {
+			t.Errorf("Test (%s): failed,  expected: %s, got: %s", tc.desc, tc.wantResp, string(resp))
+		}
+
+		err = s.ServiceControlServer.VerifyRequestCount(tc.wantScRequestCount)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %s", tc.desc, err.Error())
+		}
+	}
+}
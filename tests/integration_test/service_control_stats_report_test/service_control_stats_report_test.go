@@ -0,0 +1,99 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_stats_report_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+const statsReportOperationName = "<Envoy Stats>"
+
+// findReportedStats returns the stats in an Envoy stats report, or nil for other requests.
+func findReportedStats(scRequest *utils.ServiceRequest) (map[string]float64, error) {
+	if scRequest.ReqType != utils.ReportRequest {
+		return nil, nil
+	}
+	report, err := utils.UnmarshalReportRequest(scRequest.ReqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats map[string]float64
+	for _, op := range report.GetOperations() {
+		if op.GetOperationName() != statsReportOperationName {
+			continue
+		}
+		for _, logEntry := range op.GetLogEntries() {
+			stats = make(map[string]float64)
+			for name, value := range logEntry.GetStructPayload().GetFields()["stats"].GetStructValue().GetFields() {
+				stats[name] = value.GetNumberValue()
+			}
+		}
+	}
+	return stats, nil
+}
+
+func TestServiceControlStatsReport(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed",
+		"--service_control_stats_report=server.live,http.ingress_http.downstream_rq_total",
+		"--service_control_stats_report_interval=1s"}
+
+	s := env.NewTestEnv(platform.TestServiceControlStatsReport, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	if _, err := client.DoPost(url, "hello"); err != nil {
+		t.Fatalf("got unexpected error: %v", err)
+	}
+
+	// Reports keep coming on the interval, wait for one sampled after the request.
+	var stats map[string]float64
+	for start := time.Now(); time.Since(start) < 10*time.Second; {
+		scRequests, err := s.ServiceControlServer.GetRequests(1)
+		if err != nil {
+			continue
+		}
+		if stats, err = findReportedStats(scRequests[0]); err != nil {
+			t.Fatalf("fail to parse service control request: %v", err)
+		}
+		if stats["http.ingress_http.downstream_rq_total"] >= 1 {
+			break
+		}
+	}
+	if stats == nil {
+		t.Fatalf("got no Envoy stats report in service control")
+	}
+
+	if got := stats["server.live"]; got != 1 {
+		t.Errorf("got reported server.live %v, want 1", got)
+	}
+	if got := stats["http.ingress_http.downstream_rq_total"]; got < 1 {
+		t.Errorf("got reported http.ingress_http.downstream_rq_total %v, want at least 1", got)
+	}
+}
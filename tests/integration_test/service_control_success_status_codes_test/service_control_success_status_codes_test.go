@@ -0,0 +1,158 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_success_status_codes_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	bookstore "github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/bookstore_grpc/client"
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestServiceControlSuccessStatusCodes(t *testing.T) {
+	t.Parallel()
+
+	// The remote backend responds with 404 for a legitimate "not found".
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "book not found", http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--service_control_success_status_codes=401,404"}
+
+	s := env.NewTestEnv(platform.TestServiceControlSuccessStatusCodes, platform.EchoSidecar)
+	s.AppendBackendRules([]*confpb.BackendRule{
+		{
+			Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			Address:  backend.URL,
+			Authentication: &confpb.BackendRule_DisableAuth{
+				DisableAuth: true,
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	_, err := client.DoPost(url, "hello")
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("got error %v, want 404 Not Found", err)
+	}
+
+	// The 404 is reported with its actual response code, but not as an error.
+	wantScRequests := []interface{}{
+		&utils.ExpectedCheck{
+			Version:         utils.ESPv2Version(),
+			ServiceName:     "echo-api.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID: configId,
+			ConsumerID:      "api_key:api-key",
+			OperationName:   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			CallerIp:        platform.GetLoopbackAddress(),
+		},
+		&utils.ExpectedReport{
+			Version:                      utils.ESPv2Version(),
+			ServiceName:                  "echo-api.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID:              configId,
+			URL:                          "/echo?key=api-key",
+			ApiKeyInOperationAndLogEntry: "api-key",
+			ApiKeyState:                  "VERIFIED",
+			ApiMethod:                    "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+			ApiVersion:                   "1.0.0",
+			ApiName:                      "1.echo_api_endpoints_cloudesf_testing_cloud_goog",
+			ProducerProjectID:            "producer-project",
+			ConsumerProjectID:            "123456",
+			FrontendProtocol:             "http",
+			HttpMethod:                   "POST",
+			LogMessage:                   "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo is called",
+			StatusCode:                   "0",
+			ResponseCode:                 404,
+			SuccessStatusCode:            true,
+			Platform:                     util.GCE,
+			Location:                     "test-zone",
+		},
+	}
+	scRequests, err := s.ServiceControlServer.GetRequests(len(wantScRequests))
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	utils.CheckScRequest(t, scRequests, wantScRequests, "TestServiceControlSuccessStatusCodes")
+
+	// The 401 local reply for the missing API key is still reported as an
+	// error, even though 401 is a success status code for the backend.
+	url = fmt.Sprintf("http://%v:%v/echo", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	_, err = client.DoPost(url, "hello")
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Fatalf("got error %v, want 401 Unauthorized", err)
+	}
+	scRequests, err = s.ServiceControlServer.GetRequests(1)
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	if err := utils.VerifyReportRequestOperationLabel(scRequests[0].ReqBody, "/error_type", "4xx"); err != nil {
+		t.Errorf("the local reply is not reported as an error: %v", err)
+	}
+}
+
+func TestServiceControlSuccessStatusCodesGrpc(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--service_control_success_status_codes=404"}
+
+	s := env.NewTestEnv(platform.TestServiceControlSuccessStatusCodesGrpc, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// The gRPC NOT_FOUND status is reported as 404, so it is not an error.
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	header := http.Header{bookstore.APIKeyHeaderKey: []string{"api-key"}}
+	_, err := bookstore.MakeCall("grpc", addr, "GET", "GetShelfInvalid", "", header)
+	if err == nil || !strings.Contains(err.Error(), "code = NotFound") {
+		t.Fatalf("got error %v, want NotFound", err)
+	}
+
+	// The Check request is followed by the Report request.
+	scRequests, err := s.ServiceControlServer.GetRequests(2)
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	body := scRequests[len(scRequests)-1].ReqBody
+	if err := utils.VerifyReportRequestOperationLabel(body, "/response_code", "404"); err != nil {
+		t.Error(err)
+	}
+	if err := utils.VerifyReportRequestOperationLabel(body, "/response_code_class", "2xx"); err != nil {
+		t.Error(err)
+	}
+	if err := utils.VerifyReportRequestOperationLabel(body, "/error_type", ""); err == nil || !strings.Contains(err.Error(), "No operations contained label /error_type") {
+		t.Errorf("got /error_type label, want none: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_control_unmatched_operation_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/src/go/util"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestServiceControlUnmatchedOperation(t *testing.T) {
+	t.Parallel()
+
+	configId := "test-config-id"
+	args := []string{"--service_config_id=" + configId,
+		"--rollout_strategy=fixed", "--service_control_unmatched_operation=<unregistered>"}
+
+	s := env.NewTestEnv(platform.TestServiceControlUnmatchedOperation, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/unregistered/path", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	_, err := client.DoPost(url, "hello")
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("got error %v, want 404 Not Found", err)
+	}
+
+	// The unmatched request is only reported, with the configured operation name.
+	wantScRequests := []interface{}{
+		&utils.ExpectedReport{
+			Version:            utils.ESPv2Version(),
+			ServiceName:        "echo-api.endpoints.cloudesf-testing.cloud.goog",
+			ServiceConfigID:    configId,
+			URL:                "/unregistered/path",
+			ApiMethod:          "<unregistered>",
+			ProducerProjectID:  "producer-project",
+			FrontendProtocol:   "http",
+			HttpMethod:         "POST",
+			LogMessage:         "<unregistered> is called",
+			StatusCode:         "0",
+			ResponseCode:       404,
+			Platform:           util.GCE,
+			Location:           "test-zone",
+			ResponseCodeDetail: "direct_response",
+		},
+	}
+	scRequests, err := s.ServiceControlServer.GetRequests(len(wantScRequests))
+	if err != nil {
+		t.Fatalf("GetRequests returns error: %v", err)
+	}
+	utils.CheckScRequest(t, scRequests, wantScRequests, "TestServiceControlUnmatchedOperation")
+}
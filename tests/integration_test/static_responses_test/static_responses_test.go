@@ -0,0 +1,113 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static_responses_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestStaticResponses(t *testing.T) {
+	t.Parallel()
+
+	favicon, err := ioutil.ReadFile(platform.GetFilePath(platform.Favicon))
+	if err != nil {
+		t.Fatalf("fail to read favicon: %v", err)
+	}
+
+	args := utils.CommonArgs()
+	args = append(args, fmt.Sprintf("--static_responses=/favicon.ico=%s,/robots.txt", platform.GetFilePath(platform.Favicon)))
+
+	s := env.NewTestEnv(platform.TestStaticResponses, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc               string
+		path               string
+		method             string
+		wantStatusCode     int
+		wantContentType    string
+		wantBody           []byte
+		wantScRequestCount int
+	}{
+		{
+			desc:               "succeed, just show the service control works for normal request",
+			path:               "/echo?key=api-key",
+			method:             "POST",
+			wantStatusCode:     http.StatusOK,
+			wantBody:           []byte(`{"message":"hello"}`),
+			wantScRequestCount: 2,
+		},
+		{
+			desc:               "succeed, the static asset is served without the backend or service control",
+			path:               "/favicon.ico",
+			method:             "GET",
+			wantStatusCode:     http.StatusOK,
+			wantContentType:    "image/x-icon",
+			wantBody:           favicon,
+			wantScRequestCount: 0,
+		},
+		{
+			desc:               "succeed, the path without a file responds with no content for any http method",
+			path:               "/robots.txt",
+			method:             "POST",
+			wantStatusCode:     http.StatusNoContent,
+			wantBody:           []byte{},
+			wantScRequestCount: 0,
+		},
+	}
+	for _, tc := range testData {
+		s.ServiceControlServer.ResetRequestCount()
+		url := fmt.Sprintf("http://%v:%v%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.path)
+		req, err := http.NewRequest(tc.method, url, strings.NewReader(`{"message":"hello"}`))
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Test (%s): failed, %v", tc.desc, err)
+		}
+
+		if resp.StatusCode != tc.wantStatusCode {
+			t.Errorf("Test (%s): failed, expected status code: %v, got: %v", tc.desc, tc.wantStatusCode, resp.StatusCode)
+		}
+		if tc.wantContentType != "" && resp.Header.Get("Content-Type") != tc.wantContentType {
+			t.Errorf("Test (%s): failed, expected content type: %s, got: %s", tc.desc, tc.wantContentType, resp.Header.Get("Content-Type"))
+		}
+		if !bytes.Equal(body, tc.wantBody) {
+			t.Errorf("Test (%s): failed, expected body: %q, got: %q", tc.desc, tc.wantBody, body)
+		}
+
+		if err := s.ServiceControlServer.VerifyRequestCount(tc.wantScRequestCount); err != nil {
+			t.Fatalf("Test (%s): failed, %s", tc.desc, err.Error())
+		}
+	}
+}
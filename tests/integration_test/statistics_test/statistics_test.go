@@ -16,6 +16,7 @@ package statistics_test
 
 import (
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
@@ -88,6 +89,150 @@ func TestStatistics(t *testing.T) {
 	}
 }
 
+func TestStatisticsDownstream5xx(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestStatisticsDownstream5xx, platform.EchoRemote)
+	defer s.TearDown(t)
+	if err := s.Setup(utils.CommonArgs()); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	const downstream5xx = "http.ingress_http.downstream_rq_5xx"
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+
+	if _, err := client.DoPost(url, "hello"); err != nil {
+		t.Fatalf("expected no err, got err (%v)", err)
+	}
+	if err := s.StatsVerifier.ExpectStat(downstream5xx, func(val int) bool { return val == 0 }); err != nil {
+		t.Errorf("after a successful request: %v", err)
+	}
+
+	// Induce a failure by stopping the backend, so Envoy responds with 503.
+	if err := s.StopBackendServer(); err != nil {
+		t.Fatalf("fail to stop backend server, %v", err)
+	}
+	if _, err := client.DoPost(url, "hello"); err == nil {
+		t.Fatalf("expected err after the backend is stopped, got none")
+	}
+	if err := s.StatsVerifier.ExpectStat(downstream5xx, func(val int) bool { return val > 0 }); err != nil {
+		t.Errorf("after a failed request: %v", err)
+	}
+}
+
+func TestStatisticsPerOperation(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID,
+		"--rollout_strategy=fixed", "--enable_operation_stats"}
+
+	s := env.NewTestEnv(platform.TestStatisticsPerOperation, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc         string
+		method       string
+		path         string
+		reqCnt       int
+		wantCounters utils.StatCounters
+	}{
+		{
+			desc:   "requests to ListBooks are counted for its operation",
+			method: "GET",
+			path:   "/v1/shelves/100/books?key=api-key",
+			reqCnt: 2,
+			wantCounters: utils.StatCounters{
+				"vhost.backend.vcluster.endpoints_examples_bookstore_Bookstore_ListBooks.upstream_rq_total": 2,
+			},
+		},
+		{
+			desc:   "requests to GetShelf are counted separately from ListBooks",
+			method: "GET",
+			path:   "/v1/shelves/100?key=api-key",
+			reqCnt: 1,
+			wantCounters: utils.StatCounters{
+				"vhost.backend.vcluster.endpoints_examples_bookstore_Bookstore_GetShelf.upstream_rq_total":  1,
+				"vhost.backend.vcluster.endpoints_examples_bookstore_Bookstore_ListBooks.upstream_rq_total": 2,
+			},
+		},
+	}
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	for _, tc := range testData {
+		for i := 0; i < tc.reqCnt; i += 1 {
+			if _, err := bsclient.MakeCall("http", addr, tc.method, tc.path, "", nil); err != nil {
+				t.Fatalf("Test (%s): failed, expected no err, got err (%v)", tc.desc, err)
+			}
+		}
+
+		if err := s.StatsVerifier.CheckExpectedOperationCounters(tc.wantCounters); err != nil {
+			t.Errorf("Test (%v) failed: %v", tc.desc, err)
+		}
+	}
+}
+
+func TestStatisticsPerOperationLatency(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID,
+		"--rollout_strategy=fixed", "--enable_operation_stats"}
+
+	s := env.NewTestEnv(platform.TestStatisticsPerOperationLatency, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	for _, delay := range []string{"0s", "50ms", "100ms", "200ms", "400ms"} {
+		header := http.Header{}
+		header.Set(bsclient.TestHeaderKey, "DELAY_"+delay)
+		if _, err := bsclient.MakeCall("http", addr, "GET", "/v1/shelves/100/books?key=api-key", "", header); err != nil {
+			t.Fatalf("fail to call ListBooks with delay %v, got err (%v)", delay, err)
+		}
+	}
+
+	name := "vhost.backend.vcluster.endpoints_examples_bookstore_Bookstore_ListBooks.upstream_rq_time"
+	if err := s.StatsVerifier.CheckOperationHistogramRecorded(name); err != nil {
+		t.Errorf("Test failed: %v", err)
+	}
+}
+
+func TestStatisticsPerConsumer(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID,
+		"--rollout_strategy=fixed", "--enable_consumer_stats"}
+
+	s := env.NewTestEnv(platform.TestStatisticsPerConsumer, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	for i := 0; i < 2; i += 1 {
+		if _, err := bsclient.MakeCall("http", addr, "GET", "/v1/shelves/100/books?key=api-key", "", nil); err != nil {
+			t.Fatalf("fail to call ListBooks, got err (%v)", err)
+		}
+	}
+
+	// The consumer is the project number in the check response of the mock
+	// service control.
+	wantCounters := utils.StatCounters{
+		"http.ingress_http.service_control.consumer.123456.rq_total": 2,
+	}
+	if err := s.StatsVerifier.CheckExpectedCounters(wantCounters); err != nil {
+		t.Errorf("Test failed: %v", err)
+	}
+}
+
 func TestStatisticsServiceControlCallStatus(t *testing.T) {
 	t.Parallel()
 
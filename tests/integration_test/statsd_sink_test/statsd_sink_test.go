@@ -0,0 +1,48 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd_sink_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+)
+
+func TestStatsdSink(t *testing.T) {
+	t.Parallel()
+
+	s := env.NewTestEnv(platform.TestStatsdSink, platform.EchoSidecar)
+	s.SetupFakeStatsdServer("espv2")
+	defer s.TearDown(t)
+	if err := s.Setup(utils.CommonArgs()); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	if _, err := client.DoWithHeaders(url, "POST", "hello", nil); err != nil {
+		t.Fatalf("fail to make request, %v", err)
+	}
+
+	// Envoy flushes its stats every 5 seconds by default.
+	wantStat := "espv2.http.ingress_http.downstream_rq_total"
+	if err := s.FakeStatsdServer.WaitForStat(wantStat, 15*time.Second); err != nil {
+		t.Errorf("stat is not received by the statsd server: %v", err)
+	}
+}
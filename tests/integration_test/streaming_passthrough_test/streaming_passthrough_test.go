@@ -0,0 +1,196 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming_passthrough_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+// The request body is larger than the 10 MB that ESPv2 buffers, so it is only
+// accepted if it is streamed to the backend.
+const bodySize = 12 * 1024 * 1024
+
+// Envoy counts the requests rejected for exceeding the buffer limit.
+const rqTooLargeStat = "http.ingress_http.downstream_rq_too_large"
+
+func TestStreamingPassthrough(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc                 string
+		passthrough          bool
+		wantSuccess          bool
+		wantTransferEncoding string
+		wantRqTooLarge       int
+	}{
+		{
+			desc:           "Large request to a buffered backend is rejected",
+			wantRqTooLarge: 1,
+		},
+		{
+			desc:                 "Large request of a streaming passthrough operation is streamed to the buffered backend",
+			passthrough:          true,
+			wantSuccess:          true,
+			wantTransferEncoding: "chunked",
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			s := env.NewTestEnv(platform.TestStreamingPassthrough, platform.EchoSidecar)
+			defer s.TearDown(t)
+			backendAddress := fmt.Sprintf("http://%v:%v", platform.GetLoopbackAddress(), s.Ports().BackendServerPort)
+			args := append(utils.CommonArgs(), "--disable_chunked_encoding_backends="+backendAddress)
+			if tc.passthrough {
+				args = append(args, "--streaming_passthrough_operations=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo")
+			}
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			// Stream the body in small chunks, so the client never holds it all.
+			pr, pw := io.Pipe()
+			go func() {
+				chunk := strings.Repeat("a", 64*1024)
+				_, _ = io.WriteString(pw, `{"message":"`)
+				for i := 0; i < bodySize; i += len(chunk) {
+					if _, err := io.WriteString(pw, chunk); err != nil {
+						return
+					}
+				}
+				_, _ = io.WriteString(pw, `"}`)
+				pw.Close()
+			}()
+
+			url := fmt.Sprintf("http://%v:%v/echo?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			req, err := http.NewRequest("POST", url, pr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			defer func() {
+				if got, err := s.StatsVerifier.GetStat(rqTooLargeStat); err != nil || got != tc.wantRqTooLarge {
+					t.Errorf("Test (%s): got stat %v = %v (err: %v), want %v", tc.desc, rqTooLargeStat, got, err, tc.wantRqTooLarge)
+				}
+			}()
+			if !tc.wantSuccess {
+				// ESPv2 either replies 413 or resets the stream while the body is still being sent.
+				if err == nil {
+					defer resp.Body.Close()
+					if resp.StatusCode != http.StatusRequestEntityTooLarge {
+						t.Errorf("Test (%s): got status %v, want 413", tc.desc, resp.StatusCode)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test (%s): fail to send request: %v", tc.desc, err)
+			}
+			defer resp.Body.Close()
+
+			respBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Test (%s): got status %v, want 200 OK", tc.desc, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Echo-Request-Transfer-Encoding"); got != tc.wantTransferEncoding {
+				t.Errorf("Test (%s): backend got Transfer-Encoding %q, want %q", tc.desc, got, tc.wantTransferEncoding)
+			}
+			if want := fmt.Sprintf(`{"message":"%s"}`, strings.Repeat("a", bodySize)); strings.TrimSpace(string(respBody)) != want {
+				t.Errorf("Test (%s): backend did not receive the whole stream, echoed %d bytes", tc.desc, len(respBody))
+			}
+		}()
+	}
+}
+
+func TestStreamingPassthroughGrpc(t *testing.T) {
+	t.Parallel()
+
+	// A book of 2MiB, larger than the default connection buffer limit of 1MiB
+	// that the transcoder buffers messages within.
+	body := fmt.Sprintf(`{"id": 4, "type": 1, "author":"%s", "priceInUsd": 100}`, strings.Repeat("Mark", 512*1024))
+	backendRqStat := "cluster.backend-cluster-bookstore.endpoints.cloudesf-testing.cloud.goog_local.upstream_rq_total"
+
+	testData := []struct {
+		desc           string
+		passthrough    bool
+		wantTooLarge   bool
+		wantBackendRqs int
+	}{
+		{
+			desc:         "Large request is rejected by the transcoder before it reaches the gRPC backend",
+			wantTooLarge: true,
+		},
+		{
+			// The backend rejects the JSON request that is not transcoded to
+			// gRPC, but only after ESPv2 has streamed it.
+			desc:           "Large request of a streaming passthrough operation is streamed to the gRPC backend untranscoded",
+			passthrough:    true,
+			wantBackendRqs: 1,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := env.NewTestEnv(platform.TestStreamingPassthroughGrpc, platform.GrpcBookstoreSidecar)
+			s.OverrideAuthentication(&confpb.Authentication{
+				Rules: []*confpb.AuthenticationRule{},
+			})
+			defer s.TearDown(t)
+			args := []string{"--service_config_id=test-config-id", "--rollout_strategy=fixed"}
+			if tc.passthrough {
+				args = append(args, "--streaming_passthrough_operations=endpoints.examples.bookstore.Bookstore.CreateBook")
+			}
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/v1/shelves/100/books?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			req, err := http.NewRequest("POST", url, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("fail to call bookstore, %v", err)
+			}
+			resp.Body.Close()
+
+			if gotTooLarge := resp.StatusCode == http.StatusRequestEntityTooLarge; gotTooLarge != tc.wantTooLarge {
+				t.Errorf("got status %v, want rejected as too large: %v", resp.StatusCode, tc.wantTooLarge)
+			}
+			if got, err := s.StatsVerifier.GetStat(backendRqStat); err != nil || got != tc.wantBackendRqs {
+				t.Errorf("got stat %v = %v (err: %v), want %v", backendRqStat, got, err, tc.wantBackendRqs)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming_response_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+
+	annotationspb "google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestStreamingResponse(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc                string
+		streamingResponses  bool
+		wantContentEncoding string
+		wantIncremental     bool
+	}{
+		{
+			desc:               "Streaming responses are neither compressed nor held back",
+			streamingResponses: true,
+			wantIncremental:    true,
+		},
+		{
+			desc:                "Without the flag, the compressor holds back the events until the stream ends",
+			wantContentEncoding: "gzip",
+			wantIncremental:     false,
+		},
+	}
+
+	for _, tc := range testData {
+		func() {
+			args := []string{"--service_config_id=test-config-id",
+				"--rollout_strategy=fixed", "--enable_response_compression"}
+			if tc.streamingResponses {
+				args = append(args, "--streaming_response_operations=1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoGET")
+			}
+
+			s := env.NewTestEnv(platform.TestStreamingResponse, platform.EchoSidecar)
+			s.AppendHttpRules([]*annotationspb.HttpRule{
+				{
+					Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.echoGET",
+					Pattern: &annotationspb.HttpRule_Get{
+						Get: "/events",
+					},
+				},
+			})
+
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			// The events are sent as text/plain, which the compressor compresses
+			// by default, unlike text/event-stream.
+			url := fmt.Sprintf("http://%v:%v/events?key=api-key&content_type=text/plain", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			req, err := http.NewRequest("GET", url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Test (%s): got status %v, want 200", tc.desc, resp.StatusCode)
+			}
+			gotContentEncoding := resp.Header.Get("Content-Encoding")
+			if gotContentEncoding != tc.wantContentEncoding {
+				t.Errorf("Test (%s): got Content-Encoding %q, want %q", tc.desc, gotContentEncoding, tc.wantContentEncoding)
+			}
+
+			var body io.Reader = resp.Body
+			if gotContentEncoding == "gzip" {
+				gz, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("Test (%s): fail to read the gzip response, %v", tc.desc, err)
+				}
+				defer gz.Close()
+				body = gz
+			}
+
+			// The backend sends an event per second. Streamed events are received
+			// as soon as they are sent, held back ones all at the end of the stream.
+			var events []string
+			var receivedAt []time.Time
+			reader := bufio.NewReader(body)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					break
+				}
+				if strings.HasPrefix(line, "data: ") {
+					events = append(events, strings.TrimSpace(strings.TrimPrefix(line, "data: ")))
+					receivedAt = append(receivedAt, time.Now())
+				}
+			}
+
+			wantEvents := []string{"event 0", "event 1", "event 2"}
+			if strings.Join(events, ",") != strings.Join(wantEvents, ",") {
+				t.Fatalf("Test (%s): got events %v, want %v", tc.desc, events, wantEvents)
+			}
+			elapsed := receivedAt[len(receivedAt)-1].Sub(receivedAt[0])
+			if tc.wantIncremental && elapsed < 1500*time.Millisecond {
+				t.Errorf("Test (%s): the events were received within %v, want them to arrive incrementally", tc.desc, elapsed)
+			}
+			if !tc.wantIncremental && elapsed > 500*time.Millisecond {
+				t.Errorf("Test (%s): the events were received over %v, want them to arrive together", tc.desc, elapsed)
+			}
+		}()
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcoding_compression_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestTranscodingCompression(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID,
+		"--rollout_strategy=fixed", "--enable_response_compression"}
+
+	s := env.NewTestEnv(platform.TestTranscodingCompression, platform.GrpcBookstoreSidecar)
+	s.OverrideAuthentication(&confpb.Authentication{
+		Rules: []*confpb.AuthenticationRule{},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// A large book, so the transcoded response is well above the minimum
+	// content length of the compressor.
+	author := strings.Repeat("Mark", 4096)
+	wantResp := fmt.Sprintf(`{"id":"4","author":"%s","type":"COMIC","priceInUsd":100}`, author)
+
+	testData := []struct {
+		desc           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{
+			desc:           "The transcoded response is compressed if the client accepts gzip",
+			acceptEncoding: "gzip",
+			wantEncoding:   "gzip",
+		},
+		{
+			desc: "The transcoded response is not compressed if the client does not accept gzip",
+		},
+	}
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v/v1/shelves/100/books?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+		req, err := http.NewRequest("POST", url, bytes.NewBufferString(fmt.Sprintf(`{"id": 4, "type": 1, "author":"%s", "priceInUsd": 100}`, author)))
+		if err != nil {
+			t.Fatalf("Test (%s): fail to create request, %v", tc.desc, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		// Setting Accept-Encoding explicitly stops the client from transparently
+		// decompressing the response, so the body is checked as sent by ESPv2.
+		if tc.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Test (%s): fail to call bookstore, %v", tc.desc, err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Test (%s): fail to read response body, %v", tc.desc, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Test (%s): got status %v, want 200, body: %s", tc.desc, resp.StatusCode, body)
+		}
+
+		if got := resp.Header.Get("Content-Encoding"); got != tc.wantEncoding {
+			t.Errorf("Test (%s): got Content-Encoding %q, want %q", tc.desc, got, tc.wantEncoding)
+		}
+		if tc.wantEncoding == "gzip" {
+			if resp.Header.Get("Content-Length") != "" && resp.ContentLength != int64(len(body)) {
+				t.Errorf("Test (%s): got Content-Length %v, but the body has %v bytes", tc.desc, resp.ContentLength, len(body))
+			}
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("Test (%s): fail to decompress response, %v", tc.desc, err)
+			}
+			body, err = ioutil.ReadAll(gz)
+			if err != nil {
+				t.Fatalf("Test (%s): fail to decompress response, %v", tc.desc, err)
+			}
+		}
+		if string(body) != wantResp {
+			t.Errorf("Test (%s): got response of %v bytes, want %v bytes: %s", tc.desc, len(body), len(wantResp), body)
+		}
+	}
+}
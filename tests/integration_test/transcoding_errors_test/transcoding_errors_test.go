@@ -23,6 +23,7 @@ import (
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
 )
 
 type TranscodingTestType struct {
@@ -74,6 +75,73 @@ func TestTranscodingBackendUnavailableError(t *testing.T) {
 	}
 }
 
+func TestTranscodingErrorDetails(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID,
+		"--rollout_strategy=fixed"}
+
+	s := env.NewTestEnv(platform.TestTranscodingErrorDetails, platform.GrpcBookstoreSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc           string
+		headers        map[string]string
+		wantStatus     string
+		wantBodyParts  []string
+		wantNoBodyPart string
+	}{
+		{
+			desc: "error details in the trailer are transcoded into the JSON error body",
+			headers: map[string]string{
+				client.TestHeaderKey: "INVALID_ARGUMENT_WITH_DETAILS",
+			},
+			wantStatus: "400 Bad Request",
+			wantBodyParts: []string{
+				`"message":"INVALID_ARGUMENT_WITH_DETAILS"`,
+				`"@type":"type.googleapis.com/google.rpc.BadRequest"`,
+				`"fieldViolations":[{"field":"shelf.theme","description":"theme must not be empty"}]`,
+			},
+		},
+		{
+			desc: "error without details has no details in the JSON error body",
+			headers: map[string]string{
+				client.TestHeaderKey: "ABORTED",
+			},
+			wantStatus: "409 Conflict",
+			wantBodyParts: []string{
+				`"message":"ABORTED"`,
+			},
+			wantNoBodyPart: `"details"`,
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			url := fmt.Sprintf("http://%v:%v/v1/shelves/100?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			_, body, err := utils.DoWithHeaders(url, "GET", "", tc.headers)
+			if err == nil || !strings.Contains(err.Error(), tc.wantStatus) {
+				t.Fatalf("expected err with status %v, got: %v", tc.wantStatus, err)
+			}
+
+			// The JSON body is compared in parts since the error helpers only keep
+			// the code and the message of the google.rpc.Status.
+			gotBody := string(body)
+			for _, wantPart := range tc.wantBodyParts {
+				if !strings.Contains(gotBody, wantPart) {
+					t.Errorf("expected body to contain %s, got: %s", wantPart, gotBody)
+				}
+			}
+			if tc.wantNoBodyPart != "" && strings.Contains(gotBody, tc.wantNoBodyPart) {
+				t.Errorf("expected body not to contain %s, got: %s", tc.wantNoBodyPart, gotBody)
+			}
+		})
+	}
+}
+
 func TestTranscodingErrors(t *testing.T) {
 	t.Parallel()
 
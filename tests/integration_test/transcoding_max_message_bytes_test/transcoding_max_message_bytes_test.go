@@ -0,0 +1,98 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcoding_max_message_bytes_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestTranscodingMaxMessageBytes(t *testing.T) {
+	t.Parallel()
+
+	// A book of 2MiB, larger than the default buffer limit of 1MiB that the
+	// transcoder buffers messages within, unless the route raises it.
+	author := strings.Repeat("Mark", 512*1024)
+	wantResp := fmt.Sprintf(`{"id":"4","author":"%s","type":"COMIC","priceInUsd":100}`, author)
+
+	testData := []struct {
+		desc    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			desc:    "Transcoding fails with the default limit",
+			wantErr: true,
+		},
+		{
+			desc: "Transcoding succeeds when the max message size is raised",
+			args: []string{"--transcoding_max_message_bytes=4194304"},
+		},
+	}
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			args := append([]string{"--service_config_id=test-config-id",
+				"--rollout_strategy=fixed"}, tc.args...)
+
+			s := env.NewTestEnv(platform.TestTranscodingMaxMessageBytes, platform.GrpcBookstoreSidecar)
+			s.OverrideAuthentication(&confpb.Authentication{
+				Rules: []*confpb.AuthenticationRule{},
+			})
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("http://%v:%v/v1/shelves/100/books?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+			req, err := http.NewRequest("POST", url, bytes.NewBufferString(fmt.Sprintf(`{"id": 4, "type": 1, "author":"%s", "priceInUsd": 100}`, author)))
+			if err != nil {
+				t.Fatalf("fail to create request, %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("fail to call bookstore, %v", err)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatalf("fail to read response body, %v", err)
+			}
+
+			if tc.wantErr {
+				if resp.StatusCode == http.StatusOK {
+					t.Errorf("got status 200, want the oversized message to be rejected")
+				}
+				return
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got status %v, want 200, body: %s", resp.StatusCode, body)
+			}
+			if string(body) != wantResp {
+				t.Errorf("got response of %v bytes, want %v bytes", len(body), len(wantResp))
+			}
+		})
+	}
+}
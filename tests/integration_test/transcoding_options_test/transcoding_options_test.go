@@ -212,3 +212,64 @@ func TestTranscodingIgnoreParameters(t *testing.T) {
 		}()
 	}
 }
+
+// The HTTP rules of the bookstore share prefixes, e.g. "/v1/shelves",
+// "/v1/shelves/{shelf}" and "/v1/shelves/{shelf}/books". With
+// --transcoding_match_incoming_request_route, the route matched by the HTTP
+// request is kept after transcoding, and each request must still be
+// transcoded to its own method.
+func TestTranscodingMatchIncomingRequestRoute(t *testing.T) {
+	t.Parallel()
+
+	configID := "test-config-id"
+	args := []string{"--service_config_id=" + configID,
+		"--rollout_strategy=fixed", "--transcoding_match_incoming_request_route"}
+
+	s := env.NewTestEnv(platform.TestTranscodingMatchIncomingRequestRoute, platform.GrpcBookstoreSidecar)
+	s.OverrideAuthentication(&confpb.Authentication{
+		Rules: []*confpb.AuthenticationRule{},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	testData := []struct {
+		desc     string
+		method   string
+		wantResp string
+	}{
+		{
+			desc:     "Succeed, transcoded to ListShelves",
+			method:   "/v1/shelves?key=api-key",
+			wantResp: `{"shelves":[{"id":"100","theme":"Kids"},{"id":"200","theme":"Classic"}]}`,
+		},
+		{
+			desc:     "Succeed, transcoded to GetShelf",
+			method:   "/v1/shelves/200?key=api-key",
+			wantResp: `{"id":"200","theme":"Classic"}`,
+		},
+		{
+			desc:     "Succeed, transcoded to ListBooks",
+			method:   "/v1/shelves/100/books?key=api-key",
+			wantResp: `{"books":[{"id":"1001","title":"Alphabet"}]}`,
+		},
+		{
+			desc:     "Succeed, transcoded to GetBook",
+			method:   "/v1/shelves/200/books/2001?key=api-key",
+			wantResp: `{"id":"2001","author":"Shakspeare","title":"Hamlet"}`,
+		},
+	}
+
+	addr := fmt.Sprintf("%v:%v", platform.GetLoopbackAddress(), s.Ports().ListenerPort)
+	for _, tc := range testData {
+		resp, err := client.MakeCall("http", addr, "GET", tc.method, "", nil)
+		if err != nil {
+			t.Errorf("Test (%s): failed, %v", tc.desc, err)
+			continue
+		}
+		if !strings.Contains(resp, tc.wantResp) {
+			t.Errorf("Test (%s): failed, expected: %s, got: %s", tc.desc, tc.wantResp, resp)
+		}
+	}
+}
@@ -16,8 +16,11 @@ package transport_security_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/esp-v2/tests/endpoints/echo/client"
 	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
@@ -311,6 +314,151 @@ func TestDownstreamMTLS(t *testing.T) {
 	}
 }
 
+func TestDownstreamMTLSForwardClientCert(t *testing.T) {
+	t.Parallel()
+
+	args := utils.CommonArgs()
+	args = append(args, "--ssl_server_cert_path="+platform.GetFilePath(platform.TestDataFolder))
+	args = append(args, "--ssl_server_root_cert_path="+platform.GetFilePath(platform.DownstreamClientCert))
+	args = append(args, "--forward_client_cert_details=sanitize_set")
+	args = append(args, "--set_current_client_cert_details=subject")
+
+	s := env.NewTestEnv(platform.TestDownstreamMTLSForwardClientCert, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// FIXME: Use of localhost. Difficult to generate certs with ip addresses.
+	url := fmt.Sprintf("https://%v:%v/echoHeader?key=api-key", platform.GetLocalhost(), s.Ports().ListenerPort)
+	header, _, err := client.DoHttpsGet(url, 1, platform.GetFilePath(platform.ServerCert), platform.GetFilePath(platform.DownstreamClientCert), platform.GetFilePath(platform.DownstreamClientKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The echo backend echoes the request headers back with the `Echo-` prefix.
+	xfcc := header.Get("Echo-X-Forwarded-Client-Cert")
+	wantSubject := `Subject="CN=localhost,emailAddress=esp-eng@google.com,OU=ESPv2,O=TI,L=Mountain View,ST=California,C=US"`
+	if !strings.Contains(xfcc, wantSubject) {
+		t.Errorf("got X-Forwarded-Client-Cert %q, want it to contain %s", xfcc, wantSubject)
+	}
+}
+
+func TestDownstreamSpiffeMTLS(t *testing.T) {
+	t.Parallel()
+
+	testData := []struct {
+		desc             string
+		allowedSpiffeIds string
+		wantError        bool
+	}{
+		{
+			desc:             "success, client SVID is allowed",
+			allowedSpiffeIds: "spiffe://example.org/ns/default/sa/admin,spiffe://example.org/ns/default/sa/client",
+		},
+		{
+			desc:             "failure, client SVID is not allowed",
+			allowedSpiffeIds: "spiffe://example.org/ns/default/sa/admin",
+			wantError:        true,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.desc, func(t *testing.T) {
+			args := utils.CommonArgs()
+			args = append(args, "--ssl_server_cert_path="+platform.GetFilePath(platform.TestDataFolder))
+			args = append(args, "--ssl_server_spiffe_trust_bundle_path="+platform.GetFilePath(platform.SpiffeClientCert))
+			args = append(args, "--ssl_server_allowed_spiffe_ids="+tc.allowedSpiffeIds)
+
+			s := env.NewTestEnv(platform.TestDownstreamSpiffeMTLS, platform.EchoSidecar)
+			defer s.TearDown(t)
+			if err := s.Setup(args); err != nil {
+				t.Fatalf("fail to setup test env, %v", err)
+			}
+
+			url := fmt.Sprintf("https://%v:%v/simpleget?key=api-key", platform.GetLocalhost(), s.Ports().ListenerPort)
+			_, resp, err := client.DoHttpsGet(url, 1, platform.GetFilePath(platform.ServerCert), platform.GetFilePath(platform.SpiffeClientCert), platform.GetFilePath(platform.SpiffeClientKey))
+			if tc.wantError {
+				if err == nil {
+					t.Errorf("Test (%s): expected the SVID to be rejected, got response: %s", tc.desc, string(resp))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(resp), "simple get message") {
+				t.Errorf("Test (%s): expected: simple get message, got: %s", tc.desc, string(resp))
+			}
+		})
+	}
+}
+
+func TestDownstreamClientCrl(t *testing.T) {
+	t.Parallel()
+
+	crlPath := filepath.Join(t.TempDir(), "client.crl")
+	copyCrl := func(src platform.RuntimeFile) {
+		crl, err := ioutil.ReadFile(platform.GetFilePath(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(crlPath, crl, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copyCrl(platform.CrlRevoked)
+
+	args := utils.CommonArgs()
+	args = append(args, "--ssl_server_cert_path="+platform.GetFilePath(platform.TestDataFolder))
+	args = append(args, "--ssl_server_root_cert_path="+platform.GetFilePath(platform.CrlCaCert))
+	args = append(args, "--client_crl_file="+crlPath)
+	args = append(args, "--client_crl_sds_path="+filepath.Join(t.TempDir(), "client_crl_sds.json"))
+	args = append(args, "--client_crl_reload_interval=1s")
+
+	s := env.NewTestEnv(platform.TestDownstreamClientCrl, platform.EchoSidecar)
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	url := fmt.Sprintf("https://%v:%v/simpleget?key=api-key", platform.GetLocalhost(), s.Ports().ListenerPort)
+	get := func(cert, key platform.RuntimeFile) ([]byte, error) {
+		_, resp, err := client.DoHttpsGet(url, 1, platform.GetFilePath(platform.ServerCert), platform.GetFilePath(cert), platform.GetFilePath(key))
+		return resp, err
+	}
+
+	resp, err := get(platform.CrlClientCert, platform.CrlClientKey)
+	if err != nil {
+		t.Fatalf("expected the valid client cert to be accepted, got error: %v", err)
+	}
+	if !strings.Contains(string(resp), "simple get message") {
+		t.Errorf("expected: simple get message, got: %s", string(resp))
+	}
+
+	if resp, err := get(platform.CrlRevokedClientCert, platform.CrlRevokedClientKey); err == nil {
+		t.Errorf("expected the revoked client cert to be rejected, got response: %s", string(resp))
+	}
+
+	// Once the CRL no longer revokes it, the client cert is accepted without a
+	// restart.
+	copyCrl(platform.CrlEmpty)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err := get(platform.CrlRevokedClientCert, platform.CrlRevokedClientKey)
+		if err == nil {
+			if !strings.Contains(string(resp), "simple get message") {
+				t.Errorf("expected: simple get message, got: %s", string(resp))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the client cert to be accepted after the CRL is reloaded, got error: %v", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
 func TestHSTS(t *testing.T) {
 	t.Parallel()
 	args := utils.CommonArgs()
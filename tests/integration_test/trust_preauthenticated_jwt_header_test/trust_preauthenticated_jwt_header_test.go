@@ -0,0 +1,144 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trust_preauthenticated_jwt_header_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/platform"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/env/testdata"
+	"github.com/GoogleCloudPlatform/esp-v2/tests/utils"
+
+	confpb "google.golang.org/genproto/googleapis/api/serviceconfig"
+)
+
+func TestTrustPreauthenticatedJwtHeader(t *testing.T) {
+	t.Parallel()
+
+	args := utils.CommonArgs()
+	args = append(args, "--trust_preauthenticated_jwt_header=X-Forwarded-Authorization")
+	// JWKS is only fetched when a JWT is verified, so the trusted requests
+	// must not trigger any fetch.
+	args = append(args, "--disable_jwks_async_fetch")
+
+	s := env.NewTestEnv(platform.TestTrustPreauthenticatedJwtHeader, platform.EchoSidecar)
+	s.OverrideAuthentication(&confpb.Authentication{
+		Rules: []*confpb.AuthenticationRule{
+			{
+				Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo",
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: testdata.EndpointsJwtProvider,
+					},
+				},
+			},
+			{
+				Selector: "1.echo_api_endpoints_cloudesf_testing_cloud_goog.EchoHeader",
+				Requirements: []*confpb.AuthRequirement{
+					{
+						ProviderId: testdata.EndpointsJwtProvider,
+					},
+				},
+			},
+		},
+	})
+	defer s.TearDown(t)
+	if err := s.Setup(args); err != nil {
+		t.Fatalf("fail to setup test env, %v", err)
+	}
+
+	// The claims of the trusted JWT are forwarded to the backend as they are.
+	trustedPayload := strings.Split(testdata.FakeEndpointsToken, ".")[1]
+
+	testData := []struct {
+		desc        string
+		method      string
+		path        string
+		headers     map[string]string
+		wantResp    string
+		wantHeaders map[string]string
+		wantError   string
+	}{
+		{
+			desc:      "Fail, a request with neither a JWT nor the trusted header is rejected",
+			method:    "POST",
+			path:      "/echo",
+			wantError: `401 Unauthorized, {"code":401,"message":"Jwt is missing"}`,
+		},
+		{
+			desc:   "Fail, a request without the trusted header still has its JWT verified",
+			method: "POST",
+			path:   "/echo",
+			headers: map[string]string{
+				"Authorization": "Bearer invalid-token",
+			},
+			wantError: `401 Unauthorized, {"code":401,"message":"Jwt is not in the form of Header.Payload.Signature`,
+		},
+		{
+			desc:   "Success, a request with the trusted header is not verified again",
+			method: "POST",
+			path:   "/echo",
+			headers: map[string]string{
+				"X-Forwarded-Authorization": "Bearer " + testdata.FakeEndpointsToken,
+			},
+			wantResp: `{"message":"hello"}`,
+		},
+		{
+			desc:   "Success, the claims of the trusted header reach the backend",
+			method: "GET",
+			path:   "/echoHeader",
+			headers: map[string]string{
+				"X-Forwarded-Authorization": "Bearer " + testdata.FakeEndpointsToken,
+			},
+			wantHeaders: map[string]string{
+				"Echo-X-Endpoint-Api-Userinfo": trustedPayload,
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		url := fmt.Sprintf("http://%v:%v%v?key=api-key", platform.GetLoopbackAddress(), s.Ports().ListenerPort, tc.path)
+		message := ""
+		if tc.method == "POST" {
+			message = "hello"
+		}
+		respHeaders, resp, err := utils.DoWithHeaders(url, tc.method, message, tc.headers)
+
+		if tc.wantError != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+				t.Errorf("Test (%s): failed, got error %v, want error %v", tc.desc, err, tc.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test (%s): failed, got unexpected error: %v", tc.desc, err)
+		}
+		if !strings.Contains(string(resp), tc.wantResp) {
+			t.Errorf("Test (%s): failed, got response %s, want %s", tc.desc, resp, tc.wantResp)
+		}
+		for key, want := range tc.wantHeaders {
+			if got := respHeaders.Get(key); got != want {
+				t.Errorf("Test (%s): failed, got header %s: %q, want %q", tc.desc, key, got, want)
+			}
+		}
+	}
+
+	if got := s.FakeJwtService.ProviderMap[testdata.EndpointsJwtProvider].GetReqCnt(); got != 0 {
+		t.Errorf("got %d JWKS fetches, want none", got)
+	}
+}
@@ -37,6 +37,36 @@ class TestStartProxy(unittest.TestCase):
             ([], ['bin/bootstrap',
                   '--logtostderr', '--admin_port', '0',
                   '/tmp/bootstrap.json']),
+            (["--admin_port=8001", "--admin_address=127.0.0.1"],
+             ['bin/bootstrap', '--logtostderr', '--admin_port', '8001',
+              '--admin_address', '127.0.0.1',
+              '/tmp/bootstrap.json']),
+            (["--overload_max_heap_size_bytes=1073741824",
+              "--overload_stop_accepting_requests_threshold=0.9",
+              "--overload_stop_accepting_connections_threshold=0.95"],
+             ['bin/bootstrap', '--logtostderr', '--admin_port', '0',
+              '--overload_max_heap_size_bytes', '1073741824',
+              '--overload_stop_accepting_requests_threshold', '0.9',
+              '--overload_stop_accepting_connections_threshold', '0.95',
+              '/tmp/bootstrap.json']),
+            (["--envoy_runtime=envoy.reloadable_features.preserve_downstream_scheme=true",
+              "--envoy_runtime=re2.max_program_size.warn_level=500"],
+             ['bin/bootstrap', '--logtostderr', '--admin_port', '0',
+              '--envoy_runtime',
+              'envoy.reloadable_features.preserve_downstream_scheme=true;re2.max_program_size.warn_level=500',
+              '/tmp/bootstrap.json']),
+            (["--node_id=fleet-a-instance-1", "--node_cluster=fleet-a"],
+             ['bin/bootstrap', '--logtostderr', '--admin_port', '0',
+              '--node', 'fleet-a-instance-1',
+              '--node_cluster', 'fleet-a',
+              '/tmp/bootstrap.json']),
+            (["--statsd_address=127.0.0.1:8125", "--statsd_prefix=espv2",
+              "--enable_dog_statsd"],
+             ['bin/bootstrap', '--logtostderr', '--admin_port', '0',
+              '--statsd_address', '127.0.0.1:8125',
+              '--statsd_prefix', 'espv2',
+              '--enable_dog_statsd',
+              '/tmp/bootstrap.json']),
         ]
 
         for flags, wantedArgs in testcases:
@@ -183,6 +213,41 @@ class TestStartProxy(unittest.TestCase):
               '--listener_port', '8080', '--ssl_server_root_cert_path',
               '/etc/endpoint/ssl/root.cert', '--disable_tracing'
               ]),
+            # SPIFFE trust bundle and allowed SPIFFE IDs specified
+            (['-R=managed','--listener_port=8080',  '--disable_tracing',
+              '--ssl_server_spiffe_trust_bundle_path=/etc/endpoint/ssl/bundle.pem',
+              '--ssl_server_allowed_spiffe_ids=spiffe://example.org/ns/default/sa/client'],
+             ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'managed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--listener_port', '8080',
+              '--ssl_server_spiffe_trust_bundle_path', '/etc/endpoint/ssl/bundle.pem',
+              '--ssl_server_allowed_spiffe_ids', 'spiffe://example.org/ns/default/sa/client',
+              '--disable_tracing'
+              ]),
+            # Client CRL file specified
+            (['-R=managed','--listener_port=8080',  '--disable_tracing',
+              '--ssl_server_root_cert_path=/etc/endpoint/ssl/root.cert',
+              '--client_crl_file=/etc/endpoint/ssl/client.crl'],
+             ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'managed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--listener_port', '8080', '--ssl_server_root_cert_path',
+              '/etc/endpoint/ssl/root.cert',
+              '--client_crl_file', '/etc/endpoint/ssl/client.crl',
+              '--disable_tracing'
+              ]),
+            # XFCC header forwarding specified
+            (['-R=managed','--listener_port=8080',  '--disable_tracing',
+              '--ssl_server_root_cert_path=/etc/endpoint/ssl/root.cert',
+              '--forward_client_cert_details=sanitize_set',
+              '--set_current_client_cert_details=subject,uri'],
+             ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'managed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--listener_port', '8080', '--ssl_server_root_cert_path',
+              '/etc/endpoint/ssl/root.cert',
+              '--forward_client_cert_details', 'sanitize_set',
+              '--set_current_client_cert_details', 'subject,uri',
+              '--disable_tracing'
+              ]),
             # legacy ssl_port specified
             (['-R=managed','--ssl_port=9000', '--disable_tracing'],
              ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'managed',
@@ -347,6 +412,18 @@ class TestStartProxy(unittest.TestCase):
               '--service_config_id', '2019-11-09r0',
               '--disable_tracing',
               ]),
+            # node id and cluster
+            (['--service=test_bookstore.gloud.run',
+              '--backend=grpc://127.0.0.1:8000',
+              '--node_id=fleet-a-instance-1', '--node_cluster=fleet-a',
+              '--disable_tracing'],
+             ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'grpc://127.0.0.1:8000', '--v', '0',
+              '--service', 'test_bookstore.gloud.run',
+              '--node', 'fleet-a-instance-1',
+              '--node_cluster', 'fleet-a',
+              '--disable_tracing',
+              ]),
             # json-grpc transcoder json print options
             (['--service=test_bookstore.gloud.run',
               '--backend=grpc://127.0.0.1:8000',
@@ -386,6 +463,39 @@ class TestStartProxy(unittest.TestCase):
               '--disable_tracing',
               '--transcoding_ignore_unknown_query_parameters'
               ]),
+            (['--service=test_bookstore.gloud.run',
+              '--backend=grpc://127.0.0.1:8000',
+              '--disable_transcoding',
+              '--disable_tracing',
+              ],
+             ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'grpc://127.0.0.1:8000', '--v', '0',
+              '--service', 'test_bookstore.gloud.run',
+              '--disable_tracing',
+              '--disable_transcoding'
+              ]),
+            (['--service=test_bookstore.gloud.run',
+              '--backend=grpc://127.0.0.1:8000',
+              '--transcoding_match_incoming_request_route',
+              '--disable_tracing',
+              ],
+             ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'grpc://127.0.0.1:8000', '--v', '0',
+              '--service', 'test_bookstore.gloud.run',
+              '--disable_tracing',
+              '--transcoding_match_incoming_request_route'
+              ]),
+            (['--service=test_bookstore.gloud.run',
+              '--backend=grpc://127.0.0.1:8000',
+              '--transcoding_max_message_bytes=8388608',
+              '--disable_tracing',
+              ],
+             ['bin/configmanager', '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'grpc://127.0.0.1:8000', '--v', '0',
+              '--service', 'test_bookstore.gloud.run',
+              '--disable_tracing',
+              '--transcoding_max_message_bytes', '8388608'
+              ]),
             # Connection buffer limit bytes
             (['--service=test_bookstore.gloud.run',
               '--backend=grpc://127.0.0.1:8000',
@@ -426,6 +536,21 @@ class TestStartProxy(unittest.TestCase):
               '--access_log_format', '%START_TIME%',
               '--disable_tracing',
               ]),
+            (['--service=test_bookstore.gloud.run',
+              '--backend=127.0.0.1:8000',
+              '--access_log_grpc_address=grpc://als.example.com:9001',
+              '--access_log_grpc_log_name=bookstore',
+              '--disable_tracing',
+              ],
+             ['bin/configmanager', '--logtostderr',
+              '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8000',
+              '--v', '0',
+              '--service', 'test_bookstore.gloud.run',
+              '--access_log_grpc_address', 'grpc://als.example.com:9001',
+              '--access_log_grpc_log_name', 'bookstore',
+              '--disable_tracing',
+              ]),
             # Tracing disabled on non-gcp
             (['--service=test_bookstore.gloud.run',
               '--backend=http://127.0.0.1',
@@ -679,6 +804,33 @@ class TestStartProxy(unittest.TestCase):
               '--service_json_path', '/tmp/service_config.json',
               '--disallow_escaped_slashes_in_path',
               ]),
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--decode_escaped_slashes_in_path'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--decode_escaped_slashes_in_path',
+              ]),
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--allow_duplicate_http_patterns'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--allow_duplicate_http_patterns',
+              ]),
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--require_request_headers=X-Correlation-Id,X-Tenant'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--require_request_headers', 'X-Correlation-Id,X-Tenant',
+              ]),
             # Operation name header.
             (['--rollout_strategy=fixed',
               '--service_json_path=/tmp/service_config.json',
@@ -689,79 +841,769 @@ class TestStartProxy(unittest.TestCase):
               '--enable_operation_name_header',
               '--service_json_path', '/tmp/service_config.json',
               ]),
-        ]
-
-        i = 0
-        for flags, wantedArgs in testcases:
-            gotArgs = gen_proxy_config(self.parser.parse_args(flags))
-            self.assertEqual(gotArgs, wantedArgs,
-                             msg="Fail for input #{} [{}] : got != want".format(i, ', '.join(flags)))
-            i += 1
-
-    def test_gen_proxy_config_error(self):
-        testcases = [
-            ['--unknown_flag'],
-            ['--rollout_strategy=mangaed'],
-            ['--rollout_strategy=managed','--v=2019-11-09r0'],
-            ['--service=test_bookstore.gloud.run',
-             '--service_json_path=/tmp/service.json'],
-            ['--version=2019-11-09r0',
-             '--service_json_path=/tmp/service.json'],
-            ['--rollout_strategy=managed',
-             '--service_json_path=/tmp/service.json'],
-            ['--backend_dns_lookup_family=v4'],
-            ['--non_gcp'],
-            # Duplicate port flags.
-            ['--http_port=8000', '--http2_port=8000'],
-            ['--http_port=8000', '--listener_port=8000'],
-            ['--listener_port=8000', '--ssl_port=9000'],
-            # Privileged ports.
-            ['--listener_port=80'],
-            ['--http_port=80'],
-            ['--http2_port=80'],
-            ['--ssl_port=443'],
-            # SSL config.
-            ['--ssl_server_cert_path=/etc/endpoint/ssl', '--ssl_port=9000'],
-            ['--ssl_server_cert_path=/etc/endpoint/ssl', '--generate_self_signed_cert'],
-            ['--ssl_backend_client_cert_path=/etc/endpoint/ssl', '--tls_mutual_auth'],
-            ['--ssl_client_cert_path=/etc/endpoint/ssl', '--tls_mutual_auth'],
-            ['--ssl_protocols=TLSv1.3',  '--ssl_minimum_protocol=TLSv1.1'],
-            ['--ssl_minimum_protocol=TLSv11'],
-            ['--ssl_backend_client_root_certs_file', '--enable_grpc_backend_ssl'],
-            ['--ssl_client_root_certs_file', '--enable_grpc_backend_ssl'],
-            ['--transcoding_ignore_query_parameters=foo,bar',
-             '--transcoding_ignore_unknown_query_parameters'],
-            ['--access_log_format'],
-            ['--dns=127.0.0.1', '--dns_resolver_address=127.0.0.1'],
-            ['--ssl_client_cert_path=/tmp', '--ssl_backend_client_cert_path=/tmp'],
-            ['--ssl_client_root_certs_file=/tmp/server.crt', '--ssl_backend_client_root_certs_file=/tmp/server.crt']
-          ]
-
-        for flags in testcases:
-          with self.assertRaises(SystemExit) as cm:
-            gen_proxy_config(self.parser.parse_args(flags))
-          print(cm.exception)
-          self.assertEqual(cm.exception.code, 1)
-
-    def test_gen_envoy_args(self):
-      testcases = [
-          # Default
-          (
-              [],
-              ["bin/envoy", "-c", "/tmp/bootstrap.json",
-               "--disable-hot-restart",
-               "--log-format %L%m%d %T.%e %t envoy] [%t][%n]%v",
-               "--log-format-escaped"]
-          ),
-          # Debug mode enabled
-          (
-              ["--enable_debug"],
-              ["bin/envoy", "-c", "/tmp/bootstrap.json",
-               "--disable-hot-restart",
-               "--log-format %L%m%d %T.%e %t envoy] [%t][%n]%v",
-               "--log-format-escaped",
-               "-l debug",
-               "--component-log-level upstream:info,main:info"]
+            # Operation stats.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--enable_operation_stats'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--enable_operation_stats',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Consumer stats.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--enable_consumer_stats'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--enable_consumer_stats',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Service control extra labels.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_extra_labels=X-Client-Version=/client_version'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_control_extra_labels', 'X-Client-Version=/client_version',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Optional log entry fields.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--log_entry_fields=http_method,user_agent'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--log_entry_fields', 'http_method,user_agent',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Service control report retry backoff, pending report bound and
+            # flush jitter.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_report_retries=3',
+              '--service_control_report_retry_backoff_ms=200',
+              '--service_control_max_pending_reports=50',
+              '--service_control_report_flush_jitter_ms=500'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_control_report_retries', '3',
+              '--service_control_report_retry_backoff_ms', '200',
+              '--service_control_max_pending_reports', '50',
+              '--service_control_report_flush_jitter_ms', '500',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Service control operation name transformation.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_operation_name_strip_prefix=1.echo_api.',
+              '--service_control_operation_name_map=1.echo_api.Echo=Echo'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_control_operation_name_strip_prefix', '1.echo_api.',
+              '--service_control_operation_name_map', '1.echo_api.Echo=Echo',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Report the API version from the route path.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_report_api_version_from_path'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_control_report_api_version_from_path',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Trust JWT validated upstream.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--trust_preauthenticated_jwt_header=X-Forwarded-Authorization'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--trust_preauthenticated_jwt_header', 'X-Forwarded-Authorization',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Check API key before JWT.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--check_api_key_before_jwt'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--check_api_key_before_jwt',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Static bearer token for backend auth.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_auth_static_token_files=1.echo_api.Echo=/etc/token'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_auth_static_token_files', '1.echo_api.Echo=/etc/token',
+              ]),
+            # Custom header for the backend auth token.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_auth_token_headers=1.echo_api.Echo=X-Serverless-Authorization'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_auth_token_headers', '1.echo_api.Echo=X-Serverless-Authorization',
+              ]),
+            # Host header rewrites for backends.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_host_rewrite_by_operation=1.echo_api.Echo=api.example.com'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_host_rewrite_by_operation', '1.echo_api.Echo=api.example.com',
+              ]),
+            # Route on JWT claim values.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--jwt_claim_backend_routes=tier:enterprise=https://enterprise.example.com'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--jwt_claim_backend_routes', 'tier:enterprise=https://enterprise.example.com',
+              ]),
+            # Proxy protocol listener filter.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--enable_proxy_protocol'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--enable_proxy_protocol',
+              ]),
+            # Listener TCP keepalive and SO_REUSEPORT.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--listener_tcp_keepalive_time=60s',
+              '--listener_tcp_keepalive_interval=10s',
+              '--listener_tcp_keepalive_probes=3',
+              '--enable_reuse_port'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--listener_tcp_keepalive_time', '60s',
+              '--listener_tcp_keepalive_interval', '10s',
+              '--listener_tcp_keepalive_probes', '3',
+              '--enable_reuse_port',
+              ]),
+            # Backend unavailable message.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_unavailable_message=Bookstore is temporarily unavailable'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_unavailable_message', 'Bookstore is temporarily unavailable',
+              ]),
+            # Listener HTTP/2 keepalive.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--listener_http2_keepalive_interval=30s',
+              '--listener_http2_keepalive_timeout=10s'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--listener_http2_keepalive_interval', '30s',
+              '--listener_http2_keepalive_timeout', '10s',
+              ]),
+            # Cap the concurrent streams of downstream HTTP/2 connections.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--listener_http2_max_concurrent_streams=100'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--listener_http2_max_concurrent_streams', '100',
+              ]),
+            # Request mirroring.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--mirror_backend_address=https://shadow.example.com',
+              '--mirror_percent=10'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--mirror_backend_address', 'https://shadow.example.com',
+              '--mirror_percent', '10',
+              ]),
+            # Paths that skip service control.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--skip_service_control_paths=/internal/health,/internal/status'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--skip_service_control_paths', '/internal/health,/internal/status',
+              ]),
+            # Paths served with static responses.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--static_responses=/favicon.ico=/etc/espv2/favicon.ico,/robots.txt'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--static_responses', '/favicon.ico=/etc/espv2/favicon.ico,/robots.txt',
+              ]),
+            # Platform override for service control.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_platform_override=On-Prem'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--compute_platform_override', 'On-Prem',
+              ]),
+            # Platform override takes precedence over serverless.
+            (['--on_serverless',
+              '--http_port=8080',
+              '--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_platform_override=GKE'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--envoy_xff_num_trusted_hops', '0',
+              '--listener_port', '8080',
+              '--service_json_path', '/tmp/service_config.json',
+              '--compute_platform_override', 'GKE',
+              ]),
+            # Listener bind address.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--listener_address=127.0.0.1'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--listener_address', '127.0.0.1',
+              ]),
+            # Request headers size limit.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--max_request_headers_kb=96'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--max_request_headers_kb', '96',
+              ]),
+            # Request duration limit.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--max_request_duration=5m'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--max_request_duration', '5m',
+              ]),
+            # Optional JWT claims reported as labels.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_jwt_claim_labels=sub=/jwt_sub,email=/jwt_email'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--service_control_jwt_claim_labels', 'sub=/jwt_sub,email=/jwt_email',
+              ]),
+            # Credential id prefix.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_credential_id_prefix=billing-'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--service_control_credential_id_prefix', 'billing-',
+              ]),
+            # Service control success status codes.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_success_status_codes=404,409'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--service_control_success_status_codes', '404,409',
+              ]),
+            # Service control Envoy stats report.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_stats_report=server.live,http.ingress_http.downstream_rq_total',
+              '--service_control_stats_report_interval=30s'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--service_control_stats_report', 'server.live,http.ingress_http.downstream_rq_total',
+              '--service_control_stats_report_interval', '30s',
+              ]),
+            # Service control operation name for unmatched requests.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--service_control_unmatched_operation=<unregistered>'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--service_control_unmatched_operation', '<unregistered>',
+              ]),
+            # Remove the API key from the request.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--remove_api_key_from_request'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--remove_api_key_from_request',
+              ]),
+            # Fallback backend.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_fallback_address=http://read-only-backend:8080'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_fallback_address', 'http://read-only-backend:8080',
+              ]),
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_fallback_address=http://read-only-backend:8080',
+              '--backend_health_check_path=/healthz',
+              '--backend_health_check_host=health.example.com',
+              '--backend_health_check_expected_statuses=200,204'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_fallback_address', 'http://read-only-backend:8080',
+              '--backend_health_check_path', '/healthz',
+              '--backend_health_check_host', 'health.example.com',
+              '--backend_health_check_expected_statuses', '200,204',
+              ]),
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_fallback_address_by_operation=1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=https://read-only-backend',
+              '--backend_health_check_path=/healthz'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_fallback_address_by_operation', '1.echo_api_endpoints_cloudesf_testing_cloud_goog.Echo=https://read-only-backend',
+              '--backend_health_check_path', '/healthz',
+              ]),
+            # Warm JWKS on startup.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--jwks_warm_on_startup=required'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--jwks_warm_on_startup', 'required',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Per-provider JWKS cache duration.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--jwks_cache_duration_by_provider=google_id_token=3600,auth0=60'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--jwks_cache_duration_by_provider', 'google_id_token=3600,auth0=60',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Skip the JWT audience check for some providers.
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--jwt_skip_audience_check=internal_provider'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--jwt_skip_audience_check', 'internal_provider',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # JWT provider additional issuers
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--jwt_provider_additional_issuers=auth0=https://new.auth0.com/|https://new.auth0.com/jwks'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--jwt_provider_additional_issuers', 'auth0=https://new.auth0.com/|https://new.auth0.com/jwks',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # CORS disabled operations
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--cors_disabled_operations=1.echo_api.CreateShelf,1.echo_api.DeleteShelf'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--cors_disabled_operations', '1.echo_api.CreateShelf,1.echo_api.DeleteShelf',
+              ]),
+            # Backend connect timeout
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_connect_timeout=5s',
+              '--backend_connect_timeout_by_operation=1.echo_api.Slow=30s'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_connect_timeout', '5s',
+              '--backend_connect_timeout_by_operation', '1.echo_api.Slow=30s',
+              ]),
+            # Fault injection
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--fault_abort_percent=10',
+              '--fault_abort_status=429',
+              '--fault_delay_percent=50',
+              '--fault_delay_duration=2s',
+              '--fault_header=x-inject-fault'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--fault_abort_percent', '10',
+              '--fault_abort_status', '429',
+              '--fault_delay_percent', '50',
+              '--fault_delay_duration', '2s',
+              '--fault_header', 'x-inject-fault',
+              ]),
+            # Fault injection by API key
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--fault_abort_percent=100',
+              '--fault_api_keys=qa-test-key'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--fault_abort_percent', '100',
+              '--fault_api_keys', 'qa-test-key',
+              ]),
+            # Accept HTTP/1.0
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--accept_http_10',
+              '--default_host_for_http_10=api.example.com'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--accept_http_10',
+              '--default_host_for_http_10', 'api.example.com',
+              ]),
+            # Disable chunked encoding to backends
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--disable_chunked_encoding_backends=https://legacy-backend.example.com'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--disable_chunked_encoding_backends', 'https://legacy-backend.example.com',
+              ]),
+            # Stream the requests of operations to the backend
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--streaming_passthrough_operations=1.echo_api.Upload'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--streaming_passthrough_operations', '1.echo_api.Upload',
+              ]),
+            # Stream the responses of operations to the client
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--streaming_response_operations=1.echo_api.Events'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--streaming_response_operations', '1.echo_api.Events',
+              ]),
+            # Limit the request body size of operations
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--max_request_bytes_by_operation=1.echo_api.Create=1048576,1.echo_api.Upload=52428800'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--max_request_bytes_by_operation', '1.echo_api.Create=1048576,1.echo_api.Upload=52428800',
+              ]),
+            # Allowlist the query parameters of operations
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--query_parameter_allowlist_by_operation=1.echo_api.List=page_size|filter,1.echo_api.Get=view'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--query_parameter_allowlist_by_operation', '1.echo_api.List=page_size|filter,1.echo_api.Get=view',
+              ]),
+            # Rewrite the Host header sent to all backends
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_host_rewrite=api.example.com'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_host_rewrite', 'api.example.com',
+              ]),
+            # Allowlist the response headers forwarded to clients
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--response_headers_allowlist=x-request-id,cache-control'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--response_headers_allowlist', 'x-request-id,cache-control',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Compress responses
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--enable_response_compression'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--enable_response_compression',
+              '--service_json_path', '/tmp/service_config.json',
+              ]),
+            # Dump the generated Envoy config
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--config_dump_path=/tmp/envoy_config.json'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--config_dump_path', '/tmp/envoy_config.json',
+              ]),
+            # Service control Check timeout as a duration
+            (['--rollout_strategy=managed',
+              '--service=test_bookstore.gloud.run',
+              '--service_control_check_timeout=500ms'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'managed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service', 'test_bookstore.gloud.run',
+              '--service_control_check_timeout', '500ms',
+              ]),
+            # Grace period to roll back a rejected service config
+            (['--rollout_strategy=managed',
+              '--service=test_bookstore.gloud.run',
+              '--config_swap_grace_period=10s'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'managed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service', 'test_bookstore.gloud.run',
+              '--config_swap_grace_period', '10s',
+              ]),
+            # Operations made public at runtime
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--auth_bypass_file=/etc/espv2/auth_bypass'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--auth_bypass_file', '/etc/espv2/auth_bypass',
+              ]),
+            # Additional services on their own listeners
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--additional_services=/tmp/second_service.json=8081'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--additional_services', '/tmp/second_service.json=8081',
+              ]),
+            # Override the TLS SNI sent to backends
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_sni_by_operation=1.echo_api.Echo=backend.example.com'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_sni_by_operation', '1.echo_api.Echo=backend.example.com',
+              ]),
+            # Override the ALPN advertised to backends
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_alpn_by_operation=1.echo_api.Echo=http/1.1'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_alpn_by_operation', '1.echo_api.Echo=http/1.1',
+              ]),
+            # Strip a path prefix before calling backends
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_strip_prefix_by_operation=1.echo_api.Echo=/api/v1'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_strip_prefix_by_operation', '1.echo_api.Echo=/api/v1',
+              ]),
+            # Rewrite the path sent to backends with a regex
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--backend_regex_rewrite_by_operation=1.echo_api.Echo=^/v1/(.*)$=/v2/\\1'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--backend_regex_rewrite_by_operation', '1.echo_api.Echo=^/v1/(.*)$=/v2/\\1',
+              ]),
+            # Honor grpc-timeout of gRPC requests
+            (['--rollout_strategy=fixed',
+              '--service_json_path=/tmp/service_config.json',
+              '--max_grpc_timeout=30s'
+              ],
+             ['bin/configmanager',  '--logtostderr', '--rollout_strategy', 'fixed',
+              '--backend_address', 'http://127.0.0.1:8082', '--v', '0',
+              '--service_json_path', '/tmp/service_config.json',
+              '--max_grpc_timeout', '30s',
+              ]),
+        ]
+
+        i = 0
+        for flags, wantedArgs in testcases:
+            gotArgs = gen_proxy_config(self.parser.parse_args(flags))
+            self.assertEqual(gotArgs, wantedArgs,
+                             msg="Fail for input #{} [{}] : got != want".format(i, ', '.join(flags)))
+            i += 1
+
+    def test_gen_proxy_config_backend_env_vars(self):
+        # The environment variables are resolved by the config manager.
+        gotArgs = gen_proxy_config(self.parser.parse_args([
+            '--rollout_strategy=fixed',
+            '--service_json_path=/tmp/service_config.json',
+            '--backend=${TEST_BACKEND_HOST}:8080']))
+        self.assertEqual(gotArgs, [
+            'bin/configmanager', '--logtostderr', '--rollout_strategy', 'fixed',
+            '--backend_address', 'http://${TEST_BACKEND_HOST}:8080', '--v', '0',
+            '--service_json_path', '/tmp/service_config.json',
+        ])
+
+    def test_gen_proxy_config_error(self):
+        testcases = [
+            ['--unknown_flag'],
+            ['--rollout_strategy=mangaed'],
+            ['--rollout_strategy=managed','--v=2019-11-09r0'],
+            ['--service=test_bookstore.gloud.run',
+             '--service_json_path=/tmp/service.json'],
+            ['--version=2019-11-09r0',
+             '--service_json_path=/tmp/service.json'],
+            ['--rollout_strategy=managed',
+             '--service_json_path=/tmp/service.json'],
+            ['--backend_dns_lookup_family=v4'],
+            ['--non_gcp'],
+            # Duplicate port flags.
+            ['--http_port=8000', '--http2_port=8000'],
+            ['--http_port=8000', '--listener_port=8000'],
+            ['--listener_port=8000', '--ssl_port=9000'],
+            # Privileged ports.
+            ['--listener_port=80'],
+            ['--http_port=80'],
+            ['--http2_port=80'],
+            ['--ssl_port=443'],
+            # SSL config.
+            ['--ssl_server_cert_path=/etc/endpoint/ssl', '--ssl_port=9000'],
+            ['--ssl_server_cert_path=/etc/endpoint/ssl', '--generate_self_signed_cert'],
+            ['--ssl_backend_client_cert_path=/etc/endpoint/ssl', '--tls_mutual_auth'],
+            ['--ssl_client_cert_path=/etc/endpoint/ssl', '--tls_mutual_auth'],
+            ['--ssl_protocols=TLSv1.3',  '--ssl_minimum_protocol=TLSv1.1'],
+            ['--ssl_minimum_protocol=TLSv11'],
+            ['--ssl_backend_client_root_certs_file', '--enable_grpc_backend_ssl'],
+            ['--ssl_client_root_certs_file', '--enable_grpc_backend_ssl'],
+            ['--transcoding_ignore_query_parameters=foo,bar',
+             '--transcoding_ignore_unknown_query_parameters'],
+            ['--transcoding_max_message_bytes=8388608', '--disable_transcoding'],
+            ['--access_log_format'],
+            ['--access_log_grpc_log_name=bookstore'],
+            ['--dns=127.0.0.1', '--dns_resolver_address=127.0.0.1'],
+            ['--ssl_client_cert_path=/tmp', '--ssl_backend_client_cert_path=/tmp'],
+            ['--ssl_client_root_certs_file=/tmp/server.crt', '--ssl_backend_client_root_certs_file=/tmp/server.crt'],
+            # Health check without a fallback backend.
+            ['--backend_health_check_path=/healthz'],
+          ]
+
+        for flags in testcases:
+          with self.assertRaises(SystemExit) as cm:
+            gen_proxy_config(self.parser.parse_args(flags))
+          print(cm.exception)
+          self.assertEqual(cm.exception.code, 1)
+
+    def test_gen_envoy_args(self):
+      testcases = [
+          # Default
+          (
+              [],
+              ["bin/envoy", "-c", "/tmp/bootstrap.json",
+               "--disable-hot-restart",
+               "--log-format %L%m%d %T.%e %t envoy] [%t][%n]%v",
+               "--log-format-escaped"]
+          ),
+          # Debug mode enabled
+          (
+              ["--enable_debug"],
+              ["bin/envoy", "-c", "/tmp/bootstrap.json",
+               "--disable-hot-restart",
+               "--log-format %L%m%d %T.%e %t envoy] [%t][%n]%v",
+               "--log-format-escaped",
+               "-l debug",
+               "--component-log-level upstream:info,main:info"]
+          ),
+          # Envoy concurrency set
+          (
+              ["--envoy_concurrency=2"],
+              ["bin/envoy", "-c", "/tmp/bootstrap.json",
+               "--disable-hot-restart",
+               "--log-format %L%m%d %T.%e %t envoy] [%t][%n]%v",
+               "--log-format-escaped",
+               "--concurrency", "2"]
           )
       ]
 
@@ -38,6 +38,45 @@ func (h *RetryServiceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte(""))
 }
 
+// FailingServiceHandler responds with FailStatusCode to the first FailTimes
+// requests, then responds with 200. If RequestTimes is set, the arrival time of
+// each request is sent to it.
+type FailingServiceHandler struct {
+	RequestCount   int32
+	FailTimes      int32
+	FailStatusCode int
+	RequestTimes   chan time.Time
+}
+
+func (h *FailingServiceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.RequestCount += 1
+	if h.RequestTimes != nil {
+		h.RequestTimes <- time.Now()
+	}
+	if h.RequestCount <= h.FailTimes {
+		w.WriteHeader(h.FailStatusCode)
+		return
+	}
+
+	w.Write([]byte(""))
+}
+
+// BlockingServiceHandler holds every request until Release is closed. The
+// arrival of each request is signaled on Received.
+type BlockingServiceHandler struct {
+	RequestCount int32
+	Received     chan struct{}
+	Release      chan struct{}
+}
+
+func (h *BlockingServiceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.RequestCount += 1
+	h.Received <- struct{}{}
+	<-h.Release
+
+	w.Write([]byte(""))
+}
+
 type ExpectHeaderHandler struct {
 	RequestCount    int32
 	ExpectedHeaders http.Header
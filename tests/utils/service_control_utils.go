@@ -92,11 +92,17 @@ type ExpectedReport struct {
 	BackendProtocol              string
 	Platform                     string
 	JwtAuthCredentialId          string
+	CredentialIdPrefix           string
 	RequestHeaders               string
 	ResponseHeaders              string
 	ResponseCodeDetail           string
 	JwtPayloads                  string
 	Trace                        string
+	ExtraLabels                  map[string]string
+	// Optional string fields of the log entry payload, keyed by field name.
+	LogEntryFields map[string]string
+	// Whether ResponseCode is configured to be reported as successful.
+	SuccessStatusCode bool
 }
 
 type distOptions struct {
@@ -195,6 +201,7 @@ var (
 	}
 	randomLogEntries = []string{
 		"timestamp",
+		"request_latency_in_ms",
 	}
 	fakeDistVal  = 1000
 	fakeInt64Val = 200
@@ -244,6 +251,9 @@ func responseCodes(code int) (response, class string) {
 
 func createReportLabels(er *ExpectedReport) map[string]string {
 	response, class := responseCodes(er.ResponseCode)
+	if er.SuccessStatusCode {
+		class = "2xx"
+	}
 	labels := map[string]string{
 		"servicecontrol.googleapis.com/service_agent": "ESPv2/" + er.Version,
 		"servicecontrol.googleapis.com/user_agent":    "ESPv2",
@@ -279,10 +289,14 @@ func createReportLabels(er *ExpectedReport) map[string]string {
 	}
 
 	if er.ApiKeyInOperationAndLogEntry != "" {
-		labels["/credential_id"] = "apikey:" + er.ApiKeyInOperationAndLogEntry
+		labels["/credential_id"] = er.CredentialIdPrefix + "apikey:" + er.ApiKeyInOperationAndLogEntry
 	}
 	if er.JwtAuthCredentialId != "" {
-		labels["/credential_id"] = "jwtauth:" + er.JwtAuthCredentialId
+		labels["/credential_id"] = er.CredentialIdPrefix + "jwtauth:" + er.JwtAuthCredentialId
+	}
+
+	for label, value := range er.ExtraLabels {
+		labels[label] = value
 	}
 
 	return labels
@@ -364,9 +378,12 @@ func createLogEntry(er *ExpectedReport) *scpb.LogEntry {
 		pl["error_cause"] = makeStringValue(er.ErrorCause)
 	}
 	pl["service_config_id"] = makeStringValue("test-config-id")
+	for name, value := range er.LogEntryFields {
+		pl[name] = makeStringValue(value)
+	}
 
 	severity := ltypepb.LogSeverity_INFO
-	if er.ResponseCode >= 400 {
+	if er.ResponseCode >= 400 && !er.SuccessStatusCode {
 		severity = ltypepb.LogSeverity_ERROR
 	}
 
@@ -17,6 +17,8 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"regexp"
 	"sort"
 
 	"github.com/golang/glog"
@@ -28,6 +30,13 @@ type StatHistograms map[string][]float64
 const (
 	// Path with filtering for ESPv2 stats.
 	ESpv2FiltersStatsPath = "/stats?format=json&usedonly&filter=http.ingress_http.(backend_auth|service_control|path_rewrite)"
+
+	// Path with filtering for per-operation virtual cluster stats.
+	OperationStatsPath = "/stats?format=json&usedonly&filter=vhost.backend.vcluster."
+
+	// Path with filtering for a single stat, the stat name is appended as an
+	// escaped regex. Unused stats are included so zero values can be checked.
+	SingleStatPathPrefix = "/stats?format=json&filter="
 )
 
 // Stats is the struct to decode envoy admin json raw data.
@@ -64,10 +73,35 @@ type Point struct {
 }
 
 func FetchStats(adminPort uint16) (StatCounters, StatHistograms, error) {
+	return FetchStatsWithPath(adminPort, ESpv2FiltersStatsPath)
+}
+
+// FetchOperationStats fetches the per-operation stats emitted when
+// --enable_operation_stats is set.
+func FetchOperationStats(adminPort uint16) (StatCounters, StatHistograms, error) {
+	return FetchStatsWithPath(adminPort, OperationStatsPath)
+}
+
+// FetchStat fetches the value of a single counter or gauge by its full name.
+func FetchStat(adminPort uint16, name string) (int, error) {
+	filter := url.QueryEscape("^" + regexp.QuoteMeta(name) + "$")
+	counters, _, err := FetchStatsWithPath(adminPort, SingleStatPathPrefix+filter)
+	if err != nil {
+		return 0, err
+	}
+
+	val, ok := counters[name]
+	if !ok {
+		return 0, fmt.Errorf("stat %v is not found", name)
+	}
+	return val, nil
+}
+
+func FetchStatsWithPath(adminPort uint16, statsPath string) (StatCounters, StatHistograms, error) {
 	glog.Infof("Fetching stats from envoy")
 
 	// Fetch from envoy admin.
-	statsUrl := fmt.Sprintf("http://localhost:%v%v", adminPort, ESpv2FiltersStatsPath)
+	statsUrl := fmt.Sprintf("http://localhost:%v%v", adminPort, statsPath)
 	_, statsResp, err := DoWithHeaders(statsUrl, "GET", "", nil)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to fetch envoy stats: %v", err)